@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// splitItems splits a comma-separated flag value into its trimmed items,
+// dropping empty ones
+func splitItems(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// draftPrompter asks for the parts of a task add-task wasn't given
+type draftPrompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// line asks a question and returns the trimmed answer; io.EOF is an empty
+// answer
+func (p draftPrompter) line(question string) (string, error) {
+	fmt.Fprint(p.out, question)
+	answer, err := p.in.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimSpace(answer), nil
+}
+
+// lines asks a question and returns the answers given one per line, up to
+// an empty line
+func (p draftPrompter) lines(question string) ([]string, error) {
+	fmt.Fprintln(p.out, question)
+	var items []string
+	for {
+		answer, err := p.in.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		answer = strings.TrimSpace(answer)
+		if answer == "" {
+			return items, nil
+		}
+		items = append(items, answer)
+		if err == io.EOF {
+			return items, nil
+		}
+	}
+}
+
+// fill asks for each part of d that is still empty
+func (p draftPrompter) fill(d tasks.Draft) (tasks.Draft, error) {
+	var err error
+	if strings.TrimSpace(d.Title) == "" {
+		if d.Title, err = p.line("Title: "); err != nil {
+			return d, err
+		}
+	}
+	if strings.TrimSpace(d.Context) == "" {
+		var context []string
+		if context, err = p.lines("Context (why the task exists and what the agent should know; empty line to finish):"); err != nil {
+			return d, err
+		}
+		d.Context = strings.Join(context, "\n")
+	}
+	if len(d.Criteria) == 0 {
+		if d.Criteria, err = p.lines("Acceptance criteria, one per line (empty line to finish):"); err != nil {
+			return d, err
+		}
+	}
+	if len(d.Files) == 0 {
+		if d.Files, err = p.lines("Files to modify, one per line (empty line for none):"); err != nil {
+			return d, err
+		}
+	}
+	if len(d.Dependencies) == 0 {
+		if d.Dependencies, err = p.lines("Tasks this one depends on, one per line (empty line for none):"); err != nil {
+			return d, err
+		}
+	}
+	if len(d.Labels) == 0 {
+		var labels string
+		if labels, err = p.line("Labels, comma-separated, e.g. type:feature, milestone:auth (empty for none): "); err != nil {
+			return d, err
+		}
+		d.Labels = splitItems(labels)
+	}
+	return d, nil
+}
+
+// ask fills in d, then shows its task block and asks whether to add it
+func (p draftPrompter) ask(d tasks.Draft) (tasks.Draft, bool, error) {
+	d, err := p.fill(d)
+	if err == nil {
+		err = d.Check()
+	}
+	if err != nil {
+		return d, false, err
+	}
+	fmt.Fprintf(p.out, "\n%s\n\n", d.Block())
+	answer, err := p.line("Add this task? [Y/n] ")
+	if err != nil {
+		return d, false, err
+	}
+	answer = strings.ToLower(answer)
+	return d, answer == "" || answer == "y" || answer == "yes", nil
+}
+
+// addManualTask appends a task written by hand to tasks.md, or stages it
+// under milestone. A title that already has a task is an error. It returns
+// the dependencies that name no task, which won't hold the task up.
+func addManualTask(d tasks.Draft, milestone string, stage bool) ([]string, error) {
+	if err := d.Check(); err != nil {
+		return nil, err
+	}
+	existing, err := existingTaskTitles()
+	if err != nil {
+		return nil, err
+	}
+	title := strings.TrimSpace(d.Title)
+	known := make(map[string]bool, len(existing))
+	for t := range existing {
+		if strings.EqualFold(t, title) {
+			return nil, fmt.Errorf("%q already has a task", t)
+		}
+		known[strings.ToLower(t)] = true
+	}
+	var unknown []string
+	for _, dep := range d.Dependencies {
+		if !known[strings.ToLower(strings.TrimSpace(dep))] {
+			unknown = append(unknown, dep)
+		}
+	}
+	return unknown, addGeneratedTasks([]string{title}, []string{d.Block()}, milestone, stage)
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/runner"
+)
+
+// agentsFormats are the output formats of 'agents list'
+var agentsFormats = []string{"table", "json"}
+
+// Where an agents list row's model is known from, besides the probe's
+// runner.ModelsFromCLI and runner.ModelsFromBuiltin
+const (
+	modelsFromConfig  = "config"
+	modelsFromJournal = "journal"
+)
+
+// How the configuration uses an agents list row
+const (
+	agentPrimary  = "primary"
+	agentFallback = "fallback"
+)
+
+// agentModel is one row of 'agents list': a model of a backend, whether it
+// can run, how iterate-loop is configured to use it and how its recent runs
+// went
+type agentModel struct {
+	Backend   string `json:"backend"`
+	Model     string `json:"model"`
+	Installed bool   `json:"installed"`
+	Version   string `json:"version,omitempty"`
+	// Source is where the model is known from: cli, built-in, config or
+	// journal
+	Source string `json:"source"`
+	// Config is "primary" or "fallback" for the model iterate-loop runs on
+	// the backend, and empty for the others
+	Config         string  `json:"config,omitempty"`
+	Runs           int     `json:"runs"`
+	Completed      int     `json:"completed"`
+	Failed         int     `json:"failed"`
+	SuccessRate    float64 `json:"success_rate"`
+	AvgDurationSec float64 `json:"avg_duration_sec"`
+}
+
+// loopSetting is a setting as iterate-loop sees it: from its section or the
+// top level of the config file, else def
+func loopSetting(key, def string) string {
+	if v, ok := repoConfig.Lookup("iterate-loop", key); ok {
+		return v
+	}
+	return def
+}
+
+// loopAgents returns the backends iterate-loop runs tasks on, primary first
+// then the fallbacks, and the model it asks each of them for. Like
+// StartTask, fallbacks get their default model since the configured one
+// belongs to the primary backend.
+func loopAgents() ([]runner.Backend, map[runner.Backend]string, error) {
+	useCodex, _ := strconv.ParseBool(loopSetting("codex", "false"))
+	useClaude, _ := strconv.ParseBool(loopSetting("claude", "false"))
+	if useCodex && useClaude {
+		return nil, nil, fmt.Errorf("%s sets both codex and claude for iterate-loop", configPath())
+	}
+	fallbacks, err := runner.ParseChain(loopSetting("fallback", envOr("AGENT_FALLBACK", "")))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid fallback: %v", err)
+	}
+	chain := chainFrom(primaryBackend(useCodex, useClaude), fallbacks)
+	models := make(map[runner.Backend]string, len(chain))
+	for _, b := range chain {
+		models[b] = runner.DefaultModel(b, "auto")
+	}
+	models[chain[0]] = runner.DefaultModel(chain[0], loopSetting("model", envOr("MODEL", "auto")))
+	return chain, models, nil
+}
+
+// probeAgents probes every known backend at once
+func probeAgents(ctx context.Context) []runner.Probe {
+	backends := runner.KnownBackends()
+	probes := make([]runner.Probe, len(backends))
+	var wg sync.WaitGroup
+	for i, b := range backends {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			probes[i] = runner.ProbeBackend(ctx, b)
+		}()
+	}
+	wg.Wait()
+	return probes
+}
+
+// agentModels builds the agents list rows: per backend, the models it
+// lists, or is known to take, then the configured model and the models of
+// journal runs it doesn't list. Stats are of the runs in stats.
+func agentModels(probes []runner.Probe, chain []runner.Backend, configured map[runner.Backend]string, stats []journal.Stats) []agentModel {
+	byModel := make(map[[2]string]journal.Stats)
+	for _, s := range stats {
+		byModel[[2]string{s.Backend, s.Model}] = s
+	}
+	var rows []agentModel
+	for _, p := range probes {
+		backend := string(p.Backend)
+		seen := make(map[string]bool)
+		add := func(model, source string) {
+			if model == "" || seen[model] {
+				return
+			}
+			seen[model] = true
+			row := agentModel{Backend: backend, Model: model, Installed: p.Installed(), Version: p.Version, Source: source}
+			if m, ok := configured[p.Backend]; ok && m == model {
+				row.Config = agentFallback
+				if p.Backend == chain[0] {
+					row.Config = agentPrimary
+				}
+			}
+			if s, ok := byModel[[2]string{backend, model}]; ok {
+				row.Runs, row.Completed, row.Failed = s.Runs, s.Completed, s.Failed
+				row.SuccessRate = s.SuccessRate()
+				row.AvgDurationSec = s.AvgDuration().Seconds()
+			}
+			rows = append(rows, row)
+		}
+		for _, m := range p.Models {
+			add(m, p.ModelsFrom)
+		}
+		add(configured[p.Backend], modelsFromConfig)
+		for _, s := range stats {
+			if s.Backend == backend {
+				add(s.Model, modelsFromJournal)
+			}
+		}
+	}
+	return rows
+}
+
+// writeAgents renders the agents list rows
+func writeAgents(out io.Writer, format string, rows []agentModel) error {
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(out, "%s\n", data)
+		return err
+	case "table":
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "BACKEND\tMODEL\tINSTALLED\tCONFIG\tSOURCE\tRUNS\tSUCCESS\tAVG TIME")
+		for _, r := range rows {
+			installed := "no"
+			if r.Installed {
+				installed = "yes"
+				if r.Version != "" {
+					installed = r.Version
+				}
+			}
+			config, success, avg := "-", "-", "-"
+			if r.Config != "" {
+				config = r.Config
+			}
+			if r.Runs > 0 {
+				success = fmt.Sprintf("%.0f%%", r.SuccessRate*100)
+				avg = time.Duration(r.AvgDurationSec * float64(time.Second)).Round(time.Second).String()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n", r.Backend, r.Model, installed, config, r.Source, r.Runs, success, avg)
+		}
+		return w.Flush()
+	}
+	return fmt.Errorf("unknown format %q (use %s)", format, strings.Join(agentsFormats, " or "))
+}
+
+// listAgents prints what tasks can run with: each backend's models, whether
+// it is installed, which ones iterate-loop is configured to use and their
+// runs since since
+func listAgents(out io.Writer, format string, since time.Time) error {
+	if !slices.Contains(agentsFormats, format) {
+		return fmt.Errorf("unknown format %q (use %s)", format, strings.Join(agentsFormats, " or "))
+	}
+	chain, configured, err := loopAgents()
+	if err != nil {
+		return err
+	}
+	entries, err := journal.Read(journalPath())
+	if err != nil {
+		return fmt.Errorf("reading run journal: %w", err)
+	}
+	stats := journal.ComputeStats(entries, journal.StatsOptions{Since: since})
+	rows := agentModels(probeAgents(context.Background()), chain, configured, stats)
+	if err := writeAgents(out, format, rows); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		if r.Config != "" && !r.Installed {
+			fmt.Fprintf(os.Stderr, "[%s] ⚠️ iterate-loop is configured to use %s, which is not installed\n", ts(), r.Backend)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/atomicfile"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// archiveCompleted moves the completed tasks that pass filter out of
+// tasks.md and progress.md into a new file in outdir, returning its path.
+// The archive is written first, so a failure never loses a completion.
+func archiveCompleted(tasksMd, progressMd, tasksFile, progressFile, outdir string, filter tasks.ArchiveFilter) (string, error) {
+	archived, remainingProgress, updatedTasks, archiveFile, err := tasks.ArchiveCompletedTasksMatching(tasksMd, progressMd, outdir, filter)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(archiveFile), 0755); err != nil {
+		return "", fmt.Errorf("creating archive directory: %v", err)
+	}
+	if archiveFile, err = createArchive(archiveFile, []byte(archived)); err != nil {
+		return "", fmt.Errorf("writing archive: %v", err)
+	}
+	if err := atomicfile.WriteFile(tasksFile, []byte(updatedTasks), 0644); err != nil {
+		return archiveFile, fmt.Errorf("writing tasks: %v", err)
+	}
+	if err := writeProgress(progressFile, progressMd, remainingProgress); err != nil {
+		return archiveFile, fmt.Errorf("writing progress: %v", err)
+	}
+	return archiveFile, nil
+}
+
+// createArchive writes a new archive file at path, numbering the name when
+// an archive of the same second exists so it is never overwritten. It
+// returns the path written.
+func createArchive(path string, data []byte) (string, error) {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for n := 2; ; n++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_, err = f.Write(data)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			return path, err
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+		path = fmt.Sprintf("%s_%d%s", base, n, ext)
+	}
+}
+
+// queuedArchive is an archive-completed run left for the running
+// iterate-loop to do once none of its agents is running, so neither
+// rewrites progress.md under the other
+type queuedArchive struct {
+	TasksFile    string    `json:"tasks_file"`
+	ProgressFile string    `json:"progress_file"`
+	Outdir       string    `json:"outdir"`
+	Milestone    string    `json:"milestone,omitempty"`
+	Before       string    `json:"before,omitempty"` // as given to --before
+	Label        string    `json:"label,omitempty"`
+	QueuedAt     time.Time `json:"queued_at"`
+}
+
+func (q queuedArchive) filter() (tasks.ArchiveFilter, error) {
+	before, err := tasks.ParseArchiveBefore(q.Before)
+	return tasks.ArchiveFilter{Milestone: q.Milestone, Before: before, Label: q.Label, Defaults: configLabelDefaults()}, err
+}
+
+func archiveQueuePath() string {
+	return getControlFilePath("archive-queued.json")
+}
+
+// queueArchive leaves an archive for the running loop, refusing when one is
+// already waiting
+func queueArchive(q queuedArchive) error {
+	data, err := json.MarshalIndent(q, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(archiveQueuePath(), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if errors.Is(err, os.ErrExist) {
+		if waiting, ok := pendingArchive(); ok {
+			return fmt.Errorf("an archive queued at %s is still waiting for iterate-loop", waiting.QueuedAt.Local().Format("15:04:05"))
+		}
+		return fmt.Errorf("an archive is already queued in %s", archiveQueuePath())
+	}
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// pendingArchive returns the archive waiting for the loop, if any
+func pendingArchive() (queuedArchive, bool) {
+	data, err := os.ReadFile(archiveQueuePath())
+	if err != nil {
+		return queuedArchive{}, false
+	}
+	var q queuedArchive
+	if err := json.Unmarshal(data, &q); err != nil {
+		return queuedArchive{}, false
+	}
+	return q, true
+}
+
+// runQueuedArchive does the archive waiting for the loop, if any. The loop
+// calls it when none of its agents is running.
+func runQueuedArchive() {
+	q, ok := pendingArchive()
+	if !ok {
+		return
+	}
+	os.Remove(archiveQueuePath())
+	fmt.Printf("[%s] 🗄️ Archiving completed tasks, as queued at %s\n", ts(), q.QueuedAt.Local().Format("15:04:05"))
+	filter, err := q.filter()
+	if err == nil {
+		err = runArchive(q.TasksFile, q.ProgressFile, q.Outdir, filter)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: queued archive failed: %v\n", ts(), err)
+	}
+}
+
+// dropQueuedArchive forgets an archive queued for a loop that has stopped
+// without doing it, since archiving now does it instead
+func dropQueuedArchive() {
+	if q, ok := pendingArchive(); ok {
+		os.Remove(archiveQueuePath())
+		fmt.Printf("[%s] 🗄️ Dropped the archive queued at %s, which no loop ran\n", ts(), q.QueuedAt.Local().Format("15:04:05"))
+	}
+}
+
+// runArchive archives the completed tasks that pass filter and reports
+// what it did
+func runArchive(tasksFile, progressFile, outdir string, filter tasks.ArchiveFilter) error {
+	unlock, err := atomicfile.LockFiles(tasksFile, progressFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	taskContent, err := os.ReadFile(tasksFile)
+	if err != nil {
+		return fmt.Errorf("reading %s: %v", tasksFile, err)
+	}
+	progressContent, err := os.ReadFile(progressFile)
+	if err != nil {
+		return fmt.Errorf("reading %s: %v", progressFile, err)
+	}
+
+	if !filter.IsZero() && len(tasks.ArchivableTasks(string(taskContent), string(progressContent), filter)) == 0 {
+		fmt.Printf("No completed tasks match %s; nothing archived\n", filter)
+		return nil
+	}
+
+	archiveFile, err := archiveCompleted(string(taskContent), string(progressContent), tasksFile, progressFile, outdir, filter)
+	if err != nil {
+		return fmt.Errorf("archiving: %v", err)
+	}
+	if !filter.IsZero() {
+		fmt.Printf("✅ Archived completed tasks matching %s to %s\n", filter, archiveFile)
+		fmt.Printf("✅ Removed them from tasks.md and progress.md (kept in-progress tasks and other completions)\n")
+		return nil
+	}
+	fmt.Printf("✅ Archived completed tasks to %s\n", archiveFile)
+	fmt.Printf("✅ Removed completed tasks from tasks.md\n")
+	fmt.Printf("✅ Removed completed tasks from progress.md (kept in-progress tasks)\n")
+	return nil
+}
+
+// archivedTasks returns the completions of the given tasks in the archives
+// of archive-completed in dir, the latest when a task was archived twice
+func archivedTasks(dir string, titles []string) (map[string]tasks.ProgressEntry, error) {
+	completed := make(map[string]tasks.ProgressEntry)
+	archives, _ := filepath.Glob(filepath.Join(dir, "completed_*.md"))
+	sort.Strings(archives)
+	for _, path := range archives {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for title, e := range tasks.ParseArchive(string(data), titles) {
+			completed[title] = e
+		}
+	}
+	return completed, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// defaultMaxAttempts is the --max-attempts default
+const defaultMaxAttempts = 5
+
+// retryBudget is how many failed runs in a row a task gets before it is
+// moved to Failed instead of being retried again; 0 retries forever
+type retryBudget int
+
+// mustRetryBudget returns the budget of the --max-attempts flag
+func mustRetryBudget(maxAttempts int) retryBudget {
+	if maxAttempts < 0 {
+		fmt.Fprintf(os.Stderr, "invalid --max-attempts %d: want 0 (no limit) or more\n", maxAttempts)
+		os.Exit(1)
+	}
+	return retryBudget(maxAttempts)
+}
+
+// Attempts counts the failed runs of a task since it last completed or was
+// triaged, from the run journal
+func (b retryBudget) Attempts(title string) int {
+	entries, err := journal.Read(journalPath())
+	if err != nil {
+		return 0
+	}
+	decisions, _ := journal.ReadDecisions(triageLogPath())
+	return journal.Attempts(entries, decisions, title)
+}
+
+// Spent moves a task that has failed as many times as the budget allows to
+// Failed, reporting whether it did. The last run's classification is kept
+// as the reason.
+func (b retryBudget) Spent(progressFile string, entry journal.Entry) bool {
+	if b == 0 || entry.Task == "" {
+		return false
+	}
+	attempts := b.Attempts(entry.Task)
+	if attempts < int(b) {
+		return false
+	}
+	reason := fmt.Sprintf("gave up after %d failed attempts", attempts)
+	if entry.Classification != "" {
+		reason += ", the last one " + entry.Classification
+	}
+	if err := updateControlFile(progressFile, func(progress string) string {
+		return tasks.MarkTaskFailed(progress, entry.Task, reason)
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not move '%s' to Failed: %v\n", ts(), entry.Task, err)
+		return false
+	}
+	fmt.Printf("[%s] ❌ Out of attempts (%d/%d), moved to Failed: %s\n", ts(), attempts, b, entry.Task)
+	logTaskExhausted(entry.Task, attempts, reason)
+	fmt.Printf("[%s] 💡 Look at its runs and retry it with 'cursor-iter triage'\n", ts())
+	return true
+}
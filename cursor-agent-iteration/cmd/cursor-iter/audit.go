@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/audit"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/review"
+)
+
+// auditFormats are the values of audit-log export --format
+var auditFormats = []string{"jsonl", "markdown"}
+
+// auditCommit is a commit made during an agent run
+type auditCommit struct {
+	Hash    string
+	Author  string
+	Time    time.Time
+	Subject string
+	// Files are the changed paths, each after its git status letter and a
+	// tab, e.g. "M\tsrc/login.go"
+	Files []string
+}
+
+// runCommits lists the commits in from..to with the files they changed,
+// oldest first
+func runCommits(from, to string) ([]auditCommit, error) {
+	out, err := exec.Command("git", "log", "--reverse", "--no-renames", "--name-status",
+		"--format=%x1e%H%x1f%an <%ae>%x1f%cI%x1f%s", from+".."+to).Output()
+	if err != nil {
+		return nil, err
+	}
+	var commits []auditCommit
+	for _, record := range strings.Split(string(out), "\x1e") {
+		lines := strings.Split(strings.TrimSpace(record), "\n")
+		fields := strings.SplitN(lines[0], "\x1f", 4)
+		if len(fields) != 4 {
+			continue
+		}
+		at, _ := time.Parse(time.RFC3339, fields[2])
+		c := auditCommit{Hash: fields[0], Author: fields[1], Time: at, Subject: fields[3]}
+		for _, line := range lines[1:] {
+			if strings.Contains(line, "\t") {
+				c.Files = append(c.Files, line)
+			}
+		}
+		commits = append(commits, c)
+	}
+	return commits, nil
+}
+
+// fileVerbs describe the git status letters of changed files
+var fileVerbs = map[string]string{"A": "added", "M": "modified", "D": "deleted", "T": "changed the type of"}
+
+// auditRecords collects the autonomous actions between from and to, either
+// of which may be zero for no bound: agent dispatches and outcomes from the
+// journal, the commits and file changes of each run, and the code reviews,
+// sign-offs and triage decisions
+func auditRecords(from, to time.Time) ([]audit.Record, error) {
+	entries, err := journal.Read(journalPath())
+	if err != nil {
+		return nil, err
+	}
+	var records []audit.Record
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		actor := e.Backend + "/" + e.Model
+		started := e.Started
+		if started.IsZero() {
+			started = e.Time.Add(-e.Duration())
+		}
+		dispatch := "dispatched to " + actor
+		if e.PromptVariant != "" {
+			dispatch += " with the " + e.PromptVariant + " prompt"
+		}
+		records = append(records, audit.Record{Time: started, Kind: audit.KindDispatch, Actor: actor, Task: e.Task, RunID: e.RunID, Summary: dispatch})
+		outcome := "run " + e.Outcome
+		if e.Classification != "" {
+			outcome += " (" + e.Classification + ")"
+		}
+		records = append(records, audit.Record{Time: e.Time, Kind: audit.KindOutcome, Actor: actor, Task: e.Task, RunID: e.RunID, Summary: outcome, Outcome: e.Outcome})
+
+		if e.HeadBefore == "" || e.HeadAfter == "" || e.HeadBefore == e.HeadAfter {
+			continue
+		}
+		commits, err := runCommits(e.HeadBefore, e.HeadAfter)
+		if err != nil {
+			return nil, fmt.Errorf("reading the commits of %s's run %s: %v", e.Task, e.RunID, err)
+		}
+		for _, c := range commits {
+			// Runs of parallel tasks overlap; a commit belongs to the first
+			// run that saw it
+			if seen[c.Hash] {
+				continue
+			}
+			seen[c.Hash] = true
+			records = append(records, audit.Record{Time: c.Time, Kind: audit.KindCommit, Actor: c.Author, Task: e.Task, RunID: e.RunID, Summary: c.Subject, Commit: c.Hash})
+			for _, f := range c.Files {
+				status, path, _ := strings.Cut(f, "\t")
+				verb := fileVerbs[status[:1]]
+				if verb == "" {
+					verb = "changed"
+				}
+				records = append(records, audit.Record{Time: c.Time, Kind: audit.KindFileWrite, Actor: c.Author, Task: e.Task, RunID: e.RunID, Summary: verb + " " + path, Commit: c.Hash, Path: path})
+			}
+		}
+	}
+
+	decisions, err := journal.ReadDecisions(triageLogPath())
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range decisions {
+		summary := "triage: " + d.Action
+		if d.Detail != "" {
+			summary += " (" + d.Detail + ")"
+		}
+		by := d.By
+		if by == "" {
+			by = "unknown"
+		}
+		records = append(records, audit.Record{Time: d.Time, Kind: audit.KindApproval, Actor: by, Task: d.Task, Summary: summary, Outcome: d.Action})
+	}
+
+	reviews, _ := filepath.Glob(getControlFilePath(filepath.Join("reviews", "*.md")))
+	for _, path := range reviews {
+		md, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		title := reviewTitle(string(md))
+		for _, r := range review.ParseRounds(string(md), time.Local) {
+			summary := "review: " + string(r.Action)
+			if len(r.Findings) > 0 {
+				summary += fmt.Sprintf(" (%d findings, worst %s)", len(r.Findings), review.Worst(r.Findings))
+			}
+			records = append(records, audit.Record{Time: r.At, Kind: audit.KindApproval, Actor: r.Reviewer, Task: title, Summary: summary, Outcome: string(r.Action)})
+		}
+	}
+
+	var inRange []audit.Record
+	for _, r := range records {
+		if (from.IsZero() || !r.Time.Before(from)) && (to.IsZero() || r.Time.Before(to)) {
+			inRange = append(inRange, r)
+		}
+	}
+	return inRange, nil
+}
+
+// reviewTitle returns the task of a review file, from its "# Review:" line
+func reviewTitle(md string) string {
+	first, _, _ := strings.Cut(md, "\n")
+	return strings.TrimSpace(strings.TrimPrefix(first, "# Review:"))
+}
+
+// parseAuditBound parses --from or --to: a date (2006-01-02) in local time,
+// or an RFC 3339 time. A date given to --to includes that whole day.
+func parseAuditBound(name, spec string, endOfDay bool) (time.Time, error) {
+	if spec == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", spec, time.Local); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, spec); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --%s %q, want a date such as 2025-01-31 or a time such as 2025-01-31T09:00:00Z", name, spec)
+}
+
+// exportAuditLog writes the hash-chained actions between from and to in
+// format, returning the number of records
+func exportAuditLog(out io.Writer, format string, from, to time.Time) (int, error) {
+	if format != "jsonl" && format != "markdown" {
+		return 0, fmt.Errorf("unknown format %q (want %s)", format, strings.Join(auditFormats, ", "))
+	}
+	records, err := auditRecords(from, to)
+	if err != nil {
+		return 0, err
+	}
+	h := audit.Header{Format: audit.Format, GeneratedAt: time.Now().UTC(), Records: len(records), Head: audit.Chain(records)}
+	if !from.IsZero() {
+		f := from.UTC()
+		h.From = &f
+	}
+	if !to.IsZero() {
+		t := to.UTC()
+		h.To = &t
+	}
+	if format == "markdown" {
+		return len(records), audit.WriteMarkdown(out, h, records)
+	}
+	return len(records), audit.WriteJSONL(out, h, records)
+}
+
+// writeAuditExport exports the actions between the --from and --to specs to
+// output, or stdout when it is empty, returning the number of records
+func writeAuditExport(output, format, fromSpec, toSpec string) (int, error) {
+	from, err := parseAuditBound("from", fromSpec, false)
+	if err != nil {
+		return 0, err
+	}
+	to, err := parseAuditBound("to", toSpec, true)
+	if err != nil {
+		return 0, err
+	}
+	if output == "" {
+		return exportAuditLog(os.Stdout, format, from, to)
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return 0, err
+	}
+	n, err := exportAuditLog(f, format, from, to)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
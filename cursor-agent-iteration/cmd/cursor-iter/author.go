@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"net/mail"
+	"os"
+	"strings"
+)
+
+// agentAuthor is the git identity agent runs commit as, so their commits can
+// be told apart from human ones. A nil *agentAuthor leaves git's own
+// configuration in place.
+type agentAuthor struct {
+	Name  string
+	Email string
+	// TaskInCommitter adds the task to the committer name
+	TaskInCommitter bool
+}
+
+// parseAgentAuthor parses an identity such as "Cursor Autopilot <bot@example.com>";
+// "" means no identity
+func parseAgentAuthor(spec string, taskInCommitter bool) (*agentAuthor, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	addr, err := mail.ParseAddress(spec)
+	if err != nil || addr.Name == "" {
+		return nil, fmt.Errorf("agent author %q is not \"Name <email>\"", spec)
+	}
+	return &agentAuthor{Name: addr.Name, Email: addr.Address, TaskInCommitter: taskInCommitter}, nil
+}
+
+// mustAgentAuthor returns the identity for the --agent-author flag, exiting
+// on an invalid one
+func mustAgentAuthor(spec string, taskInCommitter bool) *agentAuthor {
+	author, err := parseAgentAuthor(spec, taskInCommitter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid --agent-author: %v\n", err)
+		os.Exit(1)
+	}
+	return author
+}
+
+// Env returns the git variables that make an agent working on a task commit
+// as the identity. title is "" for runs outside a task.
+func (a *agentAuthor) Env(title string) []string {
+	if a == nil {
+		return nil
+	}
+	committer := a.Name
+	if a.TaskInCommitter && title != "" {
+		committer = fmt.Sprintf("%s (task %s)", a.Name, taskSlug(title))
+	}
+	return []string{
+		"GIT_AUTHOR_NAME=" + a.Name,
+		"GIT_AUTHOR_EMAIL=" + a.Email,
+		"GIT_COMMITTER_NAME=" + committer,
+		"GIT_COMMITTER_EMAIL=" + a.Email,
+	}
+}
+
+// String renders the identity as git shows it
+func (a *agentAuthor) String() string {
+	return fmt.Sprintf("%s <%s>", a.Name, a.Email)
+}
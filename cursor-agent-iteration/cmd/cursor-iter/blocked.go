@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// blockerDetector finds runs that ended with the agent asking a human for
+// something. A nil *blockerDetector detects nothing.
+type blockerDetector struct {
+	patterns []*regexp.Regexp
+}
+
+// mustBlockerDetector returns the detector for the --detect-blocked flag, or
+// nil when detection is off. Extra patterns are read from patternsFile if it
+// exists.
+func mustBlockerDetector(enabled bool, patternsFile string) *blockerDetector {
+	if !enabled {
+		return nil
+	}
+	extra, err := journal.ReadBlockerPatterns(patternsFile)
+	if err == nil {
+		var patterns []*regexp.Regexp
+		if patterns, err = journal.CompileBlockerPatterns(extra); err == nil {
+			return &blockerDetector{patterns: patterns}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "invalid --blocked-patterns: %v\n", err)
+	os.Exit(1)
+	return nil
+}
+
+// Check looks for a blocker statement at the end of an incomplete run's
+// output and records it on the run, returning it
+func (d *blockerDetector) Check(run *TaskExecution) string {
+	if d == nil || run == nil || run.Output == nil {
+		return ""
+	}
+	run.Blocker = journal.FindBlocker(string(run.Output.Snapshot()), d.patterns)
+	return run.Blocker
+}
+
+// blockNeedsHuman moves a task the agent can't finish alone to Blocked
+// instead of retrying it
+func blockNeedsHuman(progressFile, title, reason string) {
+	if err := updateControlFile(progressFile, func(progress string) string {
+		return tasks.MarkTaskBlocked(progress, title, "needs human: "+reason)
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not block '%s': %v\n", ts(), title, err)
+		return
+	}
+	fmt.Printf("[%s] ⛔ Task needs a human, moved to Blocked: %s - %s\n", ts(), title, reason)
+	logTaskBlocked(title, "needs human: "+reason)
+	fmt.Printf("[%s] 💡 Provide what it asks for, then retry it with 'cursor-iter triage'\n", ts())
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// loopChurn counts the lines iterate-loop changed against --max-churn; nil,
+// counting nothing, unless it is set
+var loopChurn *churnLimit
+
+// churnLimit is the --max-churn of a loop: the lines added and removed
+// between the HEAD the loop started at and the current one, leaving out the
+// control files. It is only read and updated by the loop itself.
+type churnLimit struct {
+	step  int // the lines each confirmation allows
+	limit int
+	base  string
+
+	head    string
+	added   int
+	removed int
+	reached bool
+}
+
+// mustChurnLimit returns the limit of --max-churn, nil when it is 0, exiting
+// on invalid values
+func mustChurnLimit(maxChurn int) *churnLimit {
+	if maxChurn == 0 {
+		return nil
+	}
+	if maxChurn < 0 {
+		fmt.Fprintf(os.Stderr, "invalid --max-churn %d: want a positive number of lines\n", maxChurn)
+		os.Exit(1)
+	}
+	base := gitHead()
+	if base == "" {
+		fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: --max-churn needs a git repository with a commit; not limiting churn\n", ts())
+		return nil
+	}
+	return &churnLimit{step: maxChurn, limit: maxChurn, base: base, head: base}
+}
+
+// String describes the limit for the start of the loop
+func (c *churnLimit) String() string {
+	return fmt.Sprintf("Stopping for confirmation after %s changed lines", groupThousands(int64(c.limit)))
+}
+
+// changed is the lines added and removed since the loop started
+func (c *churnLimit) changed() int {
+	return c.added + c.removed
+}
+
+// update counts the changed lines again when HEAD moved, announcing when
+// they reach the limit
+func (c *churnLimit) update() {
+	if c == nil {
+		return
+	}
+	head := gitHead()
+	if head == "" || head == c.head {
+		return
+	}
+	added, removed, err := diffLines(c.base, head)
+	if err != nil {
+		return
+	}
+	c.head, c.added, c.removed = head, added, removed
+	if !c.reached && c.changed() >= c.limit {
+		c.reached = true
+		fmt.Printf("[%s] 🧯 Changed %s lines (+%d -%d) since the loop started, reaching --max-churn; no new tasks start, running agents finish\n",
+			ts(), groupThousands(int64(c.changed())), c.added, c.removed)
+	}
+}
+
+// Reached reports whether the loop changed as many lines as it may; never
+// for a nil limit
+func (c *churnLimit) Reached() bool {
+	return c != nil && c.reached
+}
+
+// confirm asks on the terminal whether the loop may change another
+// --max-churn lines, raising the limit if it may. It is false without a
+// terminal to ask on, or once ctx is done.
+func (c *churnLimit) confirm(ctx context.Context, interactive bool) bool {
+	if !interactive || !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return false
+	}
+	fmt.Printf("[%s] 🧯 The loop changed %s lines since %s; review them with 'git diff --stat %s'.\n",
+		ts(), groupThousands(int64(c.changed())), shortHash(c.base), shortHash(c.base))
+	fmt.Printf("Continue for another %s lines? [y/N] ", groupThousands(int64(c.step)))
+	answers := make(chan string, 1)
+	go func() {
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		answers <- answer
+	}()
+	select {
+	case answer := <-answers:
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer != "y" && answer != "yes" {
+			return false
+		}
+	case <-ctx.Done():
+		fmt.Println()
+		return false
+	}
+	c.limit = c.changed() + c.step
+	c.reached = false
+	return true
+}
+
+// diffLines counts the lines added and removed between two commits, leaving
+// out the control files agents update with every task
+func diffLines(from, to string) (added, removed int, err error) {
+	args := []string{"diff", "--numstat", "--no-renames", from, to, "--", ".", ":(exclude)" + CursorIterDir}
+	for _, path := range controlFilePaths() {
+		if filepath.IsLocal(path) && !strings.HasPrefix(filepath.ToSlash(path), CursorIterDir+"/") {
+			args = append(args, ":(exclude)"+filepath.ToSlash(path))
+		}
+	}
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return 0, 0, err
+	}
+	added, removed = parseNumstat(string(out))
+	return added, removed, nil
+}
+
+// parseNumstat sums the lines of 'git diff --numstat' output. Binary files,
+// listed with "-" for both, count as no lines.
+func parseNumstat(out string) (added, removed int) {
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) < 3 {
+			continue
+		}
+		a, errA := strconv.Atoi(fields[0])
+		r, errR := strconv.Atoi(fields[1])
+		if errA != nil || errR != nil {
+			continue
+		}
+		added += a
+		removed += r
+	}
+	return added, removed
+}
+
+// runLines counts the lines a run changed, for its journal entry
+func runLines(entry *journal.Entry) {
+	if entry.HeadBefore == "" || entry.HeadAfter == "" || entry.HeadBefore == entry.HeadAfter {
+		return
+	}
+	added, removed, err := diffLines(entry.HeadBefore, entry.HeadAfter)
+	if err != nil {
+		return
+	}
+	entry.LinesAdded, entry.LinesRemoved = added, removed
+}
+
+// writeTaskChurn adds the lines all runs of a completed task changed to its
+// completion entry in progress.md, as " | churn: +120 -30"
+func writeTaskChurn(progressFile, title string) {
+	entries, err := journal.Read(journalPath())
+	if err != nil {
+		return
+	}
+	var added, removed int
+	for _, e := range entries {
+		if e.Task == title {
+			added += e.LinesAdded
+			removed += e.LinesRemoved
+		}
+	}
+	if added == 0 && removed == 0 {
+		return
+	}
+	field := tasks.Field{Name: "churn", Value: fmt.Sprintf("+%s -%s", groupThousands(int64(added)), groupThousands(int64(removed)))}
+	if err := updateControlFile(progressFile, func(progress string) string {
+		return tasks.SetCompletionFields(progress, title, []tasks.Field{field})
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not record the churn of '%s': %v\n", ts(), title, err)
+	}
+}
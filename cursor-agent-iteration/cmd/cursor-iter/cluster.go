@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/atomicfile"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// printClusters lists the suggested clusters with why their tasks belong
+// together
+func printClusters(w io.Writer, clusters []tasks.Cluster) {
+	if len(clusters) == 0 {
+		fmt.Fprintln(w, "No small related pending tasks to combine.")
+		return
+	}
+	for i, c := range clusters {
+		fmt.Fprintf(w, "%d. %s\n", i+1, c.Title())
+		for _, reason := range c.Reasons {
+			fmt.Fprintf(w, "   - %s\n", reason)
+		}
+	}
+	fmt.Fprintln(w, "\nCombine them with 'cursor-iter cluster --apply'; the original tasks go to the trash, where 'cursor-iter trash restore' brings them back.")
+}
+
+// applyClusters combines the clusters suggested for tasks.md into one task
+// each and moves the tasks they combine to the trash. The clusters are
+// suggested again under the lock, so they match the files they change. It
+// returns the clusters applied.
+func applyClusters(tasksPath, progressPath string, opts tasks.ClusterOptions) ([]tasks.Cluster, error) {
+	unlock, err := atomicfile.LockFiles(tasksPath, progressPath, trashPath())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	current, err := os.ReadFile(tasksPath)
+	if err != nil {
+		return nil, err
+	}
+	progress, _ := os.ReadFile(progressPath)
+	trash, _ := os.ReadFile(trashPath())
+
+	clusters := tasks.SuggestClusters(string(current), string(progress), opts)
+	newTasks, newProgress, newTrash := string(current), string(progress), string(trash)
+	now := time.Now()
+	for _, c := range clusters {
+		if newTasks, err = tasks.ApplyCluster(newTasks, c); err != nil {
+			return nil, err
+		}
+		reason := fmt.Sprintf("combined into '%s' by cluster", c.Title())
+		for _, title := range c.Titles {
+			if newTasks, newProgress, newTrash, err = tasks.TrashTask(newTasks, newProgress, newTrash, title, reason, now); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(clusters) == 0 {
+		return nil, nil
+	}
+	// Write the trash first so a failure can't lose the tasks
+	if err := atomicfile.WriteFile(trashPath(), []byte(newTrash), 0644); err != nil {
+		return nil, err
+	}
+	if err := atomicfile.WriteFile(tasksPath, []byte(newTasks), 0644); err != nil {
+		return nil, err
+	}
+	if newProgress != string(progress) {
+		return clusters, writeProgress(progressPath, string(progress), newProgress)
+	}
+	return clusters, nil
+}
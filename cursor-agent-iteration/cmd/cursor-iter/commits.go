@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/commitmsg"
+)
+
+// Commit policy fix modes
+const (
+	commitFixAmend    = "amend"    // reword the offending commit in place when safe
+	commitFixInstruct = "instruct" // always ask the agent to reword it
+)
+
+// commitInfo is one commit made during a task run
+type commitInfo struct {
+	Hash    string
+	Message string
+	// Committer identity, kept when the commit is reworded
+	CommitterName  string
+	CommitterEmail string
+	// Merge is set for commits with more than one parent
+	Merge bool
+}
+
+// commitsBetween lists the commits in from..to, oldest first
+func commitsBetween(from, to string) ([]commitInfo, error) {
+	out, err := exec.Command("git", "log", "--reverse", "--format=%H%x1f%cn%x1f%ce%x1f%P%x1f%B%x1e", from+".."+to).Output()
+	if err != nil {
+		return nil, err
+	}
+	var commits []commitInfo
+	for _, record := range strings.Split(string(out), "\x1e") {
+		fields := strings.SplitN(strings.TrimLeft(record, "\n"), "\x1f", 5)
+		if len(fields) == 5 {
+			commits = append(commits, commitInfo{Hash: fields[0], CommitterName: fields[1], CommitterEmail: fields[2], Merge: len(strings.Fields(fields[3])) > 1, Message: strings.TrimSpace(fields[4])})
+		}
+	}
+	return commits, nil
+}
+
+// isPushed reports whether a commit is already on a remote-tracking branch
+func isPushed(hash string) bool {
+	out, err := exec.Command("git", "branch", "-r", "--contains", hash).Output()
+	return err == nil && strings.TrimSpace(string(out)) != ""
+}
+
+// commitChecker enforces the commit message policy on the commits each task
+// run makes. With parallel tasks a run's range can include commits of other
+// tasks, so every commit is only checked once, by the first run that sees it.
+type commitChecker struct {
+	policy commitmsg.Policy
+	mode   string
+	seen   map[string]bool
+}
+
+// mustCommitChecker parses the --commit-policy and --commit-fix flags,
+// exiting on invalid values
+func mustCommitChecker(spec string, mode string) *commitChecker {
+	policy, err := commitmsg.ParsePolicy(spec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid --commit-policy: %v\n", err)
+		os.Exit(1)
+	}
+	if mode != commitFixAmend && mode != commitFixInstruct {
+		fmt.Fprintf(os.Stderr, "invalid --commit-fix %q: must be %s or %s\n", mode, commitFixAmend, commitFixInstruct)
+		os.Exit(1)
+	}
+	return &commitChecker{policy: policy, mode: mode, seen: make(map[string]bool)}
+}
+
+// PromptNote tells agents the commit message rules up front
+func (c *commitChecker) PromptNote() string {
+	if c == nil || !c.policy.Enabled() {
+		return ""
+	}
+	return "Commit messages must follow the project's policy: " + c.policy.Describe() + "."
+}
+
+// Check checks the commits made since the run started. In amend mode a single
+// offending commit is reworded in place when it is the unpushed HEAD, its
+// message can be fixed mechanically and canAmend says no other agent is
+// working. Otherwise it returns an instruction for the agent to reword the
+// commits itself, or "" if there is nothing to fix.
+func (c *commitChecker) Check(run *TaskExecution, canAmend bool) string {
+	if c == nil || !c.policy.Enabled() || run == nil || run.HeadBefore == "" {
+		return ""
+	}
+	head := gitHead()
+	if head == "" || head == run.HeadBefore {
+		return ""
+	}
+	commits, err := commitsBetween(run.HeadBefore, head)
+	if err != nil {
+		fmt.Printf("[%s] ⚠️ Could not list commits for '%s': %v\n", ts(), run.TaskTitle, err)
+		return ""
+	}
+
+	var bad []commitInfo
+	var problems []string
+	for _, commit := range commits {
+		if c.seen[commit.Hash] {
+			continue
+		}
+		c.seen[commit.Hash] = true
+		if violations := c.policy.Check(commit.Message); len(violations) > 0 {
+			bad = append(bad, commit)
+			problems = append(problems, fmt.Sprintf("%s %q (%s)", shortHash(commit.Hash), subjectOf(commit.Message), strings.Join(violations, "; ")))
+		}
+	}
+	if len(bad) == 0 {
+		return ""
+	}
+
+	if c.mode == commitFixAmend && canAmend && len(bad) == 1 && bad[0].Hash == head && !isPushed(head) {
+		if fixed, ok := c.policy.Fix(bad[0].Message); ok {
+			cmd := exec.Command("git", "commit", "--amend", "--only", "--quiet", "-F", "-")
+			cmd.Stdin = strings.NewReader(fixed + "\n")
+			// Keep the committer, so bot commits stay attributed to the bot
+			cmd.Env = append(os.Environ(), "GIT_COMMITTER_NAME="+bad[0].CommitterName, "GIT_COMMITTER_EMAIL="+bad[0].CommitterEmail)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				fmt.Printf("[%s] ⚠️ Could not reword commit %s: %v %s\n", ts(), shortHash(head), err, strings.TrimSpace(string(out)))
+			} else {
+				c.seen[gitHead()] = true
+				fmt.Printf("[%s] ✏️  Reworded commit %s: %q → %q\n", ts(), shortHash(head), subjectOf(bad[0].Message), subjectOf(fixed))
+				return ""
+			}
+		}
+	}
+
+	for _, p := range problems {
+		fmt.Printf("[%s] 📝 Commit by '%s' breaks the commit policy: %s\n", ts(), run.TaskTitle, p)
+	}
+	return fmt.Sprintf("Commits from the previous run break the commit message policy (%s): %s. Reword them without changing their content, e.g. `git commit --amend` for the latest commit or a rebase for older ones, and don't push them before they are fixed.",
+		c.policy.Describe(), strings.Join(problems, ", "))
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
+func subjectOf(message string) string {
+	subject, _, _ := strings.Cut(message, "\n")
+	return subject
+}
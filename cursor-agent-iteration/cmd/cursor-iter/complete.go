@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/atomicfile"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// manualCompletion is the triage log action of a task someone finished by
+// hand
+const manualCompletion = "complete-manually"
+
+// completeTask records that a person finished a task themselves, so the
+// loop never dispatches it again. Its required criteria must be ticked in
+// tasks.md, or are ticked when tickAll is set. The completion is recorded
+// in progress.md with a "by" field and in the triage log, which also
+// settles the task's failed runs. It returns the criteria ticked.
+func completeTask(tasksPath, progressPath, title, notes, by string, policy tasks.CompletionPolicy, tickAll bool) ([]string, error) {
+	unlock, err := atomicfile.LockFiles(tasksPath, progressPath)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	current, err := os.ReadFile(tasksPath)
+	if err != nil {
+		return nil, err
+	}
+	progress, _ := os.ReadFile(progressPath)
+
+	title = strings.TrimSpace(title)
+	var task *tasks.Task
+	for _, t := range tasks.ParseTasks(string(current)) {
+		if t.Title == title {
+			task = &t
+			break
+		}
+	}
+	if task == nil {
+		return nil, fmt.Errorf("no task %q in %s", title, tasksPath)
+	}
+	if tasks.IsTaskCompleted(string(progress), title) {
+		return nil, fmt.Errorf("'%s' is already completed", title)
+	}
+
+	newTasks, ticked := string(current), []string(nil)
+	if tickAll {
+		newTasks, ticked = tasks.TickCriteria(newTasks, title, policy)
+	} else {
+		var open []string
+		for _, c := range task.Criteria {
+			if !c.Checked && !policy.IsDeferred(c.Category) {
+				open = append(open, c.Text)
+			}
+		}
+		if len(open) > 0 {
+			return nil, fmt.Errorf("'%s' has unchecked criteria: %s; tick them in %s, or pass --check-all if they are done", title, strings.Join(open, "; "), tasksPath)
+		}
+	}
+
+	if newTasks != string(current) {
+		if err := atomicfile.WriteFile(tasksPath, []byte(newTasks), 0644); err != nil {
+			return nil, err
+		}
+	}
+	if err := writeProgress(progressPath, string(progress), tasks.CompleteManually(string(progress), title, notes, by)); err != nil {
+		return ticked, err
+	}
+	return ticked, journal.AppendDecision(triageLogPath(), journal.Decision{
+		Time:   time.Now(),
+		Task:   title,
+		Action: manualCompletion,
+		Detail: notes,
+		By:     by,
+	})
+}
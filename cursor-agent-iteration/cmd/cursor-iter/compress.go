@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/compress"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/runner"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/seal"
+)
+
+// compressedDir holds the condensed copies of the control files
+func compressedDir() string {
+	return getControlFilePath("compressed")
+}
+
+// compressedFile is one control file handled by compress-context
+type compressedFile struct {
+	Name   string
+	Path   string
+	Before int // estimated tokens of the full file
+	After  int // estimated tokens of the condensed copy
+	Cached bool
+}
+
+// modelSummarizer condenses a control file with an agent, for compress-context --model
+func modelSummarizer(backend runner.Backend, model string, debug bool) func(name, content string) (string, error) {
+	return func(name, content string) (string, error) {
+		prompt := compress.Prompt(name, content)
+		logPrompt(prompt, debug, false)
+		var out bytes.Buffer
+		err := runner.RunPrompt(runner.Options{Debug: debug, Stdout: &out}, backend, model, prompt)
+		return out.String(), err
+	}
+}
+
+// compressContext writes condensed copies of the control files that changed
+// since they were last condensed, or of all of them with force. Files are
+// condensed with summarize when given and extractively otherwise.
+func compressContext(summarize func(name, content string) (string, error), force bool) ([]compressedFile, error) {
+	var results []compressedFile
+	for _, name := range compress.Files {
+		data, err := os.ReadFile(getControlFilePath(name))
+		if err != nil || seal.IsSealed(data) {
+			// Encrypted files go into prompts whole; a condensed copy would
+			// leave them in plain text on disk
+			continue
+		}
+		content := string(data)
+		condensed, cached := compress.Load(compressedDir(), name, content)
+		if force || !cached {
+			cached = false
+			condensed = compress.Extract(name, content)
+			if summarize != nil {
+				reply, err := summarize(name, content)
+				if err != nil {
+					return results, fmt.Errorf("condensing %s: %v", name, err)
+				}
+				condensed = strings.TrimSpace(reply)
+			}
+			if _, err := compress.Save(compressedDir(), name, content, condensed); err != nil {
+				return results, err
+			}
+		}
+		results = append(results, compressedFile{
+			Name:   name,
+			Path:   compress.Path(compressedDir(), name),
+			Before: compress.EstimateTokens(content),
+			After:  compress.EstimateTokens(condensed),
+			Cached: cached,
+		})
+	}
+	return results, nil
+}
+
+// contextBudgetNote tells the agent to read the condensed control files when
+// the full ones would take more than budget tokens. Copies that are missing
+// or out of date are condensed extractively first. Returns "" when there is
+// no budget or the full files fit.
+func contextBudgetNote(budget int) string {
+	if budget <= 0 {
+		return ""
+	}
+	total := 0
+	for _, name := range controlFileNames {
+		if data, err := os.ReadFile(getControlFilePath(name)); err == nil && !seal.IsSealed(data) {
+			total += compress.EstimateTokens(string(data))
+		}
+	}
+	if total <= budget {
+		return ""
+	}
+	files, err := compressContext(nil, false)
+	if err != nil || len(files) == 0 {
+		return ""
+	}
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	return fmt.Sprintf("The control files are too large to read in full (~%d tokens, budget %d): read the condensed copies %s instead of the full files, and only open a full file for details the condensed copy leaves out",
+		total, budget, strings.Join(paths, ", "))
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/config"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// configFileNames are the per-repository config files, in the order they
+// are looked for
+var configFileNames = []string{".cursor-iter.yaml", ".cursor-iter.yml"}
+
+// repoConfig holds the settings of the config file, loaded at startup
+var repoConfig config.Config
+
+// configPath returns the config file to load: CURSOR_ITER_CONFIG, or the
+// first of configFileNames that exists
+func configPath() string {
+	if v := os.Getenv("CURSOR_ITER_CONFIG"); v != "" {
+		return v
+	}
+	for _, name := range configFileNames {
+		if _, err := os.Stat(name); err == nil {
+			return name
+		}
+	}
+	return configFileNames[0]
+}
+
+// configFile is the layout of the config file for its schema: the settings
+// that aren't flags. Any other key is a flag name, at the top level for
+// every command or in the section named after a command.
+type configFile struct {
+	TasksFile         string            `json:"tasks-file,omitempty"`
+	ProgressFile      string            `json:"progress-file,omitempty"`
+	Gates             map[string]string `json:"gates,omitempty"`
+	LabelDefaults     map[string]any    `json:"label-defaults,omitempty"`
+	Allowlist         map[string]string `json:"allowlist,omitempty"`
+	AllowlistChecksum string            `json:"allowlist-checksum,omitempty"`
+}
+
+// labelDefaultsSection is the section of the config file listing the labels
+// other labels imply, e.g. "area:infra: [milestone:infra, exclusive:true]"
+const labelDefaultsSection = "label-defaults"
+
+// loadRepoConfig loads the config file for a command, exiting when it is
+// invalid. Settings that aren't flags are applied here: agent-retries sets
+// the retries of cursor-agent startup races, label-defaults the labels
+// tasks get from the labels they have, and tips whether a tip follows the
+// command.
+func loadRepoConfig(command string) {
+	path := configPath()
+	cfg, err := config.Load(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid config: %v\n", err)
+		os.Exit(1)
+	}
+	repoConfig = cfg
+	if v, ok := cfg.Lookup(command, "agent-retries"); ok {
+		if _, err := strconv.Atoi(v); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid agent-retries in %s: %q is not a number\n", path, v)
+			os.Exit(1)
+		}
+		os.Setenv("CURSOR_AGENT_MAX_RETRIES", v)
+	}
+	tipsOn = true
+	if v, ok := cfg.Lookup(command, "tips"); ok {
+		on, err := strconv.ParseBool(v)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid tips in %s: %q is not true or false\n", path, v)
+			os.Exit(1)
+		}
+		tipsOn = on
+	}
+	if _, err := labelDefaults(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid %s in %s: %v\n", labelDefaultsSection, path, err)
+		os.Exit(1)
+	}
+}
+
+// configLabelDefaults returns the label defaults of the config file;
+// loadRepoConfig has already rejected invalid ones
+func configLabelDefaults() tasks.LabelDefaults {
+	defaults, _ := labelDefaults(repoConfig)
+	return defaults
+}
+
+// labelDefaults reads the label-defaults section: a label, then the
+// comma-separated or listed labels it implies
+func labelDefaults(cfg config.Config) (tasks.LabelDefaults, error) {
+	if v, ok := cfg.Values[labelDefaultsSection]; ok {
+		return nil, fmt.Errorf("want \"label: implied labels\" settings such as \"area:infra: [milestone:infra]\", got %q", v)
+	}
+	section := cfg.Sections[labelDefaultsSection]
+	if len(section) == 0 {
+		return nil, nil
+	}
+	defaults := make(tasks.LabelDefaults, len(section))
+	for label, value := range section {
+		var implied []string
+		for _, l := range strings.Split(value, ",") {
+			l = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(l), "["), "]"))
+			if l == "" {
+				continue
+			}
+			if strings.ContainsAny(l, "[]") {
+				return nil, fmt.Errorf("%s implies %q, which is not a label", label, l)
+			}
+			if p, ok := tasks.LabelValue([]string{l}, "priority"); ok {
+				if _, ok := tasks.ParsePriority(p); !ok {
+					return nil, fmt.Errorf("%s implies %q; want priority P0, P1 or P2", label, l)
+				}
+			}
+			implied = append(implied, l)
+		}
+		if len(implied) == 0 {
+			return nil, fmt.Errorf("%s implies no labels", label)
+		}
+		defaults[label] = implied
+	}
+	return defaults, nil
+}
+
+// configPathSetting returns a top-level path setting of the config file,
+// such as tasks-file. Paths are shared by every command, so sections can't
+// set them.
+func configPathSetting(key string) (string, bool) {
+	v := repoConfig.Values[key]
+	return v, v != ""
+}
+
+// parseFlags parses the flags of a command and fills in the flags that
+// weren't given from the config file, so flags override config values and
+// config values override environment variables and built-in defaults. The
+// section of a command is named after its first word, e.g. "trash" for
+// "trash restore".
+func parseFlags(fs *flag.FlagSet, args []string) {
+	_ = fs.Parse(args)
+	command := strings.Fields(fs.Name())[0]
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	fs.VisitAll(func(f *flag.Flag) {
+		if given[f.Name] {
+			return
+		}
+		v, ok := repoConfig.Lookup(command, f.Name)
+		if !ok {
+			return
+		}
+		if err := fs.Set(f.Name, v); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid %s in %s: %v\n", f.Name, configPath(), err)
+			os.Exit(1)
+		}
+	})
+	for key := range repoConfig.Sections[command] {
+		if fs.Lookup(key) == nil && key != "agent-retries" && key != "tips" {
+			fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: %s sets %s for %s, which has no such flag\n", ts(), configPath(), key, command)
+		}
+	}
+}
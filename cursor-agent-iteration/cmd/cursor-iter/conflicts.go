@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/conflict"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/runner"
+)
+
+// Conflict resolutions, as recorded in the conflict log
+const (
+	resolvedGenerated = "generated-theirs"
+	resolvedAgent     = "agent"
+	resolvedEdit      = "edit"
+	resolvedRebase    = "rebase"
+)
+
+// conflictLogPath is the JSONL log of conflicted files
+func conflictLogPath() string {
+	return getControlFilePath("logs/conflicts.jsonl")
+}
+
+// conflictSession resolves the conflicts of an in-progress merge or rebase
+type conflictSession struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	root      string
+	generated []string
+	editor    string
+	// runAgent sends a prompt to the configured agent backend
+	runAgent func(prompt string) error
+
+	// resolutions maps each conflicted file to how it was resolved
+	resolutions map[string]string
+}
+
+// record notes how a file was resolved; files seen but left unresolved are
+// recorded with an empty resolution
+func (s *conflictSession) record(file, resolution string) {
+	if s.resolutions == nil {
+		s.resolutions = make(map[string]string)
+	}
+	if resolution != "" || s.resolutions[file] == "" {
+		s.resolutions[file] = resolution
+	}
+}
+
+// flush appends the session's conflicts to the conflict log
+func (s *conflictSession) flush(op conflict.Operation) {
+	var records []conflict.Record
+	for file, resolution := range s.resolutions {
+		records = append(records, conflict.Record{Time: time.Now(), File: file, Op: op, Resolution: resolution})
+	}
+	if len(records) == 0 {
+		return
+	}
+	if err := conflict.Append(conflictLogPath(), records...); err != nil {
+		fmt.Fprintf(s.out, "⚠️ Could not write conflict log: %v\n", err)
+	}
+}
+
+// resolveGenerated takes the incoming side of generated files
+func (s *conflictSession) resolveGenerated(st conflict.State) {
+	for _, file := range st.Files {
+		s.record(file, "")
+		if !conflict.IsGenerated(file, s.generated) {
+			continue
+		}
+		// During a rebase git's "ours" is the upstream being rebased onto
+		side := conflict.Theirs
+		if st.Op == conflict.OpRebase {
+			side = conflict.Ours
+		}
+		if err := conflict.Take(s.root, file, side); err != nil {
+			fmt.Fprintf(s.out, "⚠️ %v\n", err)
+			continue
+		}
+		s.record(file, resolvedGenerated)
+		fmt.Fprintf(s.out, "🤖 %s is generated, took the incoming version (regenerate it after the %s)\n", file, st.Op)
+	}
+}
+
+// useAgent asks the agent to resolve all remaining conflicts
+func (s *conflictSession) useAgent(st conflict.State) error {
+	fmt.Fprintf(s.out, "🚀 Asking the agent to resolve %d file(s)...\n", len(st.Files))
+	if err := s.runAgent(conflict.AgentPrompt(s.root, st)); err != nil {
+		return fmt.Errorf("agent failed: %v", err)
+	}
+	for _, file := range st.Files {
+		if err := conflict.MarkResolved(s.root, file); err != nil {
+			fmt.Fprintf(s.out, "⚠️ %v\n", err)
+			continue
+		}
+		s.record(file, resolvedAgent)
+	}
+	return nil
+}
+
+// prompt asks a question and returns the trimmed answer
+func (s *conflictSession) prompt(question string) (string, error) {
+	fmt.Fprint(s.out, question)
+	line, err := s.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.ToLower(strings.TrimSpace(line)), nil
+}
+
+// interactive walks through the remaining conflicted files
+func (s *conflictSession) interactive() error {
+	for {
+		st, err := conflict.Inspect(s.root)
+		if err != nil {
+			return err
+		}
+		if len(st.Files) == 0 {
+			return nil
+		}
+		// Label the sides from the user's point of view
+		local, incoming := conflict.Ours, conflict.Theirs
+		if st.Op == conflict.OpRebase {
+			local, incoming = conflict.Theirs, conflict.Ours
+		}
+
+		file := st.Files[0]
+		s.record(file, "")
+		fmt.Fprintf(s.out, "\n⚔️  %s (%d file(s) left)\n", file, len(st.Files))
+		choice, err := s.prompt("Resolve: keep [l]ocal, take [i]ncoming, [e]dit, [a]gent for all, [r]ebase instead, [q]uit > ")
+		if err != nil {
+			return nil
+		}
+		switch choice {
+		case "l", "i":
+			side, resolution := local, "local"
+			if choice == "i" {
+				side, resolution = incoming, "incoming"
+			}
+			if err := conflict.Take(s.root, file, side); err != nil {
+				fmt.Fprintf(s.out, "❌ %v\n", err)
+				continue
+			}
+			s.record(file, resolution)
+		case "e":
+			cmd := exec.Command("sh", "-c", s.editor+` "$1"`, "sh", file)
+			cmd.Dir = s.root
+			cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+			if err := cmd.Run(); err != nil {
+				fmt.Fprintf(s.out, "❌ editor failed: %v\n", err)
+				continue
+			}
+			if err := conflict.MarkResolved(s.root, file); err != nil {
+				fmt.Fprintf(s.out, "❌ %v\n", err)
+				continue
+			}
+			s.record(file, resolvedEdit)
+		case "a":
+			if err := s.useAgent(st); err != nil {
+				fmt.Fprintf(s.out, "❌ %v\n", err)
+			}
+			if st, _ := conflict.Inspect(s.root); len(st.Files) > 0 {
+				fmt.Fprintf(s.out, "⚠️ %d file(s) still conflicted\n", len(st.Files))
+			}
+		case "r":
+			if err := conflict.RetryAsRebase(s.root, st); err != nil {
+				fmt.Fprintf(s.out, "⚠️ %v\n", err)
+			}
+			for _, f := range st.Files {
+				s.record(f, resolvedRebase)
+			}
+			if next, _ := conflict.Inspect(s.root); next.Op == conflict.OpRebase {
+				s.resolveGenerated(next)
+			}
+		case "q":
+			return nil
+		default:
+			fmt.Fprintf(s.out, "Unknown choice %q\n", choice)
+		}
+	}
+}
+
+// printConflictStats lists the files that conflict most often
+func printConflictStats(out io.Writer) error {
+	counts, err := conflict.Frequency(conflictLogPath())
+	if err != nil {
+		return err
+	}
+	if len(counts) == 0 {
+		fmt.Fprintln(out, "No conflicts recorded yet")
+		return nil
+	}
+	fmt.Fprintln(out, "⚔️  Conflicts per file:")
+	for _, c := range counts {
+		fmt.Fprintf(out, "  %4d  %s (last %s)\n", c.Count, c.File, c.Last.Format("2006-01-02 15:04"))
+	}
+	return nil
+}
+
+// agentRunner returns a function that sends a prompt to the selected backend
+func agentRunner(backend runner.Backend, model string, debug bool) func(string) error {
+	return func(prompt string) error {
+		logPrompt(prompt, debug, false)
+		opts, flush := agentOptions(debug)
+		defer flush()
+		return runner.RunPrompt(opts, backend, model, prompt)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/coord"
+)
+
+// coordinatorHeartbeat is how often a loop tells the others it is alive
+// and checks for repositories it must stop agents for, well within
+// coord.WaitingFor so it sees every wait
+const coordinatorHeartbeat = 10 * time.Second
+
+// defaultCoordinatorDir is shared by every repository of the current user
+func defaultCoordinatorDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "cursor-iter-coordinator")
+	}
+	return filepath.Join(home, CursorIterDir, "coordinator")
+}
+
+// newCoordinator joins the global agent budget for the --global-max flag,
+// or returns nil when there is no global limit
+func newCoordinator(max int, dir string, priority int, weight float64) (*coord.Coordinator, error) {
+	if max <= 0 {
+		return nil, nil
+	}
+	repo, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	c, err := coord.New(dir, repo, max, priority, weight)
+	if err != nil {
+		return nil, err
+	}
+	c.Start(coordinatorHeartbeat)
+	states, _ := c.States()
+	fmt.Printf("[%s] 🌐 Joined agent coordinator %s: %s\n", ts(), dir, coord.Describe(states, max))
+	return c, nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// loopSpend counts the spend of iterate-loop against --budget; nil, counting
+// nothing, unless it is set
+var loopSpend *spendLimit
+
+// spendLimit is the --budget of a loop. Runs count with the cost their agent
+// reported, or their tokens at the model's --prices price; runs with neither
+// are free to it.
+type spendLimit struct {
+	limit  float64
+	prices map[string]float64
+
+	mu      sync.Mutex
+	spent   float64
+	reached bool
+}
+
+// mustSpendLimit returns the limit of --budget, nil when it is 0, exiting on
+// invalid values
+func mustSpendLimit(budget float64, prices map[string]float64) *spendLimit {
+	if budget == 0 {
+		return nil
+	}
+	if budget < 0 {
+		fmt.Fprintf(os.Stderr, "invalid --budget %v: want a positive amount of USD\n", budget)
+		os.Exit(1)
+	}
+	return &spendLimit{limit: budget, prices: prices}
+}
+
+// mustPrices parses --prices, exiting on invalid values
+func mustPrices(spec string) map[string]float64 {
+	prices, err := parsePrices(spec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid --prices: %v\n", err)
+		os.Exit(1)
+	}
+	return prices
+}
+
+// String describes the limit for the start of the loop
+func (s *spendLimit) String() string {
+	if len(s.prices) == 0 {
+		return fmt.Sprintf("Stopping at %s of reported agent costs; set --prices to count runs by their tokens too", formatUSD(s.limit))
+	}
+	return fmt.Sprintf("Stopping at %s of agent costs", formatUSD(s.limit))
+}
+
+// add counts the cost of a run, announcing when it reaches the limit
+func (s *spendLimit) add(entry journal.Entry) {
+	if s == nil {
+		return
+	}
+	usd, ok := entry.Cost(s.prices)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spent += usd
+	if s.spent >= s.limit && !s.reached {
+		s.reached = true
+		fmt.Printf("[%s] 💸 Spent %s of the %s budget; no new tasks start, running agents finish\n", ts(), formatUSD(s.spent), formatUSD(s.limit))
+	}
+}
+
+// Reached reports whether the loop spent its budget; never for a nil limit
+func (s *spendLimit) Reached() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reached
+}
+
+// Spent is what the loop has spent so far
+func (s *spendLimit) Spent() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.spent
+}
+
+// writeTaskCost adds the tokens and cost of all runs of a completed task to
+// its completion entry in progress.md, as " | tokens: ~12,345 | cost: $0.42"
+func writeTaskCost(progressFile, title string, prices map[string]float64) {
+	entries, err := journal.Read(journalPath())
+	if err != nil {
+		return
+	}
+	var cost journal.TaskCost
+	for _, c := range journal.TaskCosts(entries, time.Time{}, "", prices) {
+		if c.Task == title {
+			cost = c
+		}
+	}
+	fields := taskCostFields(cost)
+	if len(fields) == 0 {
+		return
+	}
+	if err := updateControlFile(progressFile, func(progress string) string {
+		return tasks.SetCompletionFields(progress, title, fields)
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not record the cost of '%s': %v\n", ts(), title, err)
+	}
+}
+
+// taskCostFields are the progress.md fields of a task's cost
+func taskCostFields(c journal.TaskCost) []tasks.Field {
+	var fields []tasks.Field
+	if c.Tokens > 0 {
+		fields = append(fields, tasks.Field{Name: "tokens", Value: formatTokens(c)})
+	}
+	if c.Runs > c.Unpriced {
+		fields = append(fields, tasks.Field{Name: "cost", Value: formatCost(c)})
+	}
+	return fields
+}
+
+// formatTokens groups the thousands of a task's tokens, marking estimates
+// with "~"
+func formatTokens(c journal.TaskCost) string {
+	s := groupThousands(c.Tokens)
+	if c.Estimated {
+		s = "~" + s
+	}
+	return s
+}
+
+// groupThousands formats n with commas between its thousands
+func groupThousands(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	for i := len(s) - 3; i > 0 && s[i-1] != '-'; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// formatCost is a task's cost, marked with "+" when some runs have no
+// known cost, or "-" when none has
+func formatCost(c journal.TaskCost) string {
+	switch {
+	case c.Runs == c.Unpriced:
+		return "-"
+	case c.Unpriced > 0:
+		return formatUSD(c.USD) + "+"
+	}
+	return formatUSD(c.USD)
+}
+
+func formatUSD(usd float64) string {
+	return fmt.Sprintf("$%.2f", usd)
+}
+
+// printCosts prints one row per task and their total
+func printCosts(out io.Writer, costs []journal.TaskCost) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TASK\tRUNS\tTOKENS\tCOST")
+	var total journal.TaskCost
+	for _, c := range costs {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", c.Task, c.Runs, formatTokens(c), formatCost(c))
+		total.Runs += c.Runs
+		total.Tokens += c.Tokens
+		total.USD += c.USD
+		total.Unpriced += c.Unpriced
+		total.Estimated = total.Estimated || c.Estimated
+	}
+	fmt.Fprintf(w, "TOTAL\t%d\t%s\t%s\n", total.Runs, formatTokens(total), formatCost(total))
+	w.Flush()
+	if total.Estimated {
+		fmt.Fprintln(out, "~ some tokens are estimated from the sizes of the prompt and output")
+	}
+	if total.Unpriced > 0 {
+		fmt.Fprintf(out, "+ %d run(s) reported no cost and have no --prices price for their model\n", total.Unpriced)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/progress"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/state"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/stream"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tui"
+)
+
+// dashboardInterval is how often the dashboard is redrawn
+const dashboardInterval = 250 * time.Millisecond
+
+// Why a run was stopped, in TaskExecution.StopReason: skipped or restarted
+// from the dashboard, or preempted for a higher-priority repository
+const (
+	stopSkip      = "skip"
+	stopRetry     = "retry"
+	stopPreempted = "preempted"
+)
+
+// paneLines is how much of each agent's output the dashboard keeps on hand
+const paneLines = 200
+
+// dashboard is the --tui view of iterate-loop: the tasks by status, an
+// output pane per running agent and the loop's own log, with keys to pause
+// the loop and to skip or retry the selected task. A nil *dashboard is never
+// paused.
+type dashboard struct {
+	runner    *TaskRunner
+	store     *state.Store
+	runID     string
+	maxActive int
+	// log holds the loop's output, which the dashboard shows instead of
+	// the terminal
+	log *stream.RingBuffer
+	// quit stops the loop as a Ctrl-C would
+	quit   func()
+	paused atomic.Bool
+
+	mu       sync.Mutex
+	selected string // title of the selected task
+	notice   string
+	width    int
+	height   int
+	sizedAt  time.Time
+}
+
+// Paused reports whether dispatching new tasks was paused from the dashboard
+func (d *dashboard) Paused() bool {
+	return d != nil && d.paused.Load()
+}
+
+// running returns the running tasks, oldest first
+func (d *dashboard) running() []*TaskExecution {
+	runs := d.runner.runningExecutions()
+	sort.Slice(runs, func(i, j int) bool { return runs[i].StartTime.Before(runs[j].StartTime) })
+	return runs
+}
+
+// view builds the current frame
+func (d *dashboard) view() tui.View {
+	snap, _ := d.store.Refresh()
+	s := tasks.SummarizeWith(snap.TasksMd, snap.ProgressMd, tasks.StatusOptions{Defaults: configLabelDefaults()})
+	runs := d.running()
+
+	v := tui.View{Paused: d.Paused(), Blocked: s.Totals.Blocked, Failed: s.Totals.Failed}
+	v.Header = fmt.Sprintf("run %s · %d/%d agents · %d/%d tasks completed", d.runID, len(runs), d.maxActive, s.Totals.Completed, s.Totals.Total)
+	for _, t := range s.Tasks {
+		switch t.Status {
+		case "completed":
+			v.Completed = append(v.Completed, t.Title)
+		case "in-progress":
+			v.InProgress = append(v.InProgress, fmt.Sprintf("%s (%d/%d)", t.Title, t.ACChecked, t.ACTotal))
+		case "blocked", "failed":
+		default:
+			v.Pending = append(v.Pending, t.Title)
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, run := range runs {
+		if run.TaskTitle == d.selected {
+			v.Selected = i
+		}
+		v.Panes = append(v.Panes, tui.Pane{
+			Title:   run.TaskTitle,
+			Backend: string(run.Backend),
+			Elapsed: time.Since(run.StartTime),
+			Lines:   tui.Lines(string(run.Output.Snapshot()), paneLines),
+		})
+	}
+	if len(runs) > 0 {
+		d.selected = runs[v.Selected].TaskTitle
+	}
+	v.Log = tui.Lines(string(d.log.Snapshot()), paneLines)
+	v.Notice = d.notice
+	return v
+}
+
+// size returns the terminal size, read at most once a second
+func (d *dashboard) size() (width, height int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if time.Since(d.sizedAt) >= time.Second {
+		d.width, d.height = tui.Size(os.Stdin)
+		d.sizedAt = time.Now()
+	}
+	return d.width, d.height
+}
+
+// handle acts on a key
+func (d *dashboard) handle(key string) {
+	runs := d.running()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	current := -1
+	for i, run := range runs {
+		if run.TaskTitle == d.selected {
+			current = i
+		}
+	}
+
+	switch key {
+	case tui.KeyUp, tui.KeyDown:
+		if len(runs) == 0 {
+			return
+		}
+		step := 1
+		if key == tui.KeyUp {
+			step = len(runs) - 1
+		}
+		d.selected = runs[(max(current, 0)+step)%len(runs)].TaskTitle
+	case tui.KeyPause:
+		if d.paused.CompareAndSwap(false, true) {
+			d.notice = "⏸ Paused: running agents finish, no new tasks start"
+		} else {
+			d.paused.Store(false)
+			d.notice = "▶ Resumed"
+		}
+	case tui.KeySkip, tui.KeyRetry:
+		if current < 0 {
+			d.notice = "No task selected"
+			return
+		}
+		reason, verb := stopSkip, "Skipping"
+		if key == tui.KeyRetry {
+			reason, verb = stopRetry, "Restarting"
+		}
+		if err := d.runner.StopTask(d.selected, reason); err != nil {
+			d.notice = err.Error()
+			return
+		}
+		d.notice = fmt.Sprintf("%s '%s'", verb, d.selected)
+	case tui.KeyQuit:
+		d.notice = "🛑 Stopping: no new tasks start, running agents are stopped"
+		d.quit()
+	}
+}
+
+// startDashboard shows the dashboard until the returned function is called,
+// routing stdout and stderr to its log pane. Without an interactive terminal
+// it warns and returns a nil dashboard, leaving the usual output.
+func startDashboard(enabled bool, tr *TaskRunner, store *state.Store, runID string, maxActive int, quit func()) (*dashboard, func()) {
+	if !enabled {
+		return nil, func() {}
+	}
+	if !progress.Enabled(isTerminal(os.Stdin) && isTerminal(os.Stdout), os.Getenv("TERM")) {
+		fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: --tui needs an interactive terminal, showing the usual output\n", ts())
+		return nil, func() {}
+	}
+	restore, err := tui.RawInput(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: --tui could not read keys from the terminal, showing the usual output: %v\n", ts(), err)
+		return nil, func() {}
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		restore()
+		return nil, func() {}
+	}
+
+	d := &dashboard{runner: tr, store: store, runID: runID, maxActive: maxActive, log: stream.NewRingBuffer(stream.DefaultCapacity), quit: quit}
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = w, w
+	copied := make(chan struct{})
+	go func() {
+		io.Copy(d.log, r)
+		close(copied)
+	}()
+
+	screen := tui.NewScreen(stdout, d.view, d.size)
+	screen.Start(dashboardInterval)
+	var stopped atomic.Bool
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil || stopped.Load() {
+				return
+			}
+			for _, key := range tui.Keys(buf[:n]) {
+				d.handle(key)
+			}
+			screen.Redraw()
+		}
+	}()
+
+	var once sync.Once
+	return d, func() {
+		once.Do(func() {
+			stopped.Store(true)
+			screen.Stop()
+			restore()
+			os.Stdout, os.Stderr = stdout, stderr
+			w.Close()
+			<-copied
+			r.Close()
+			// The loop's last words would otherwise vanish with the
+			// alternate screen
+			lines := tui.Lines(string(d.log.Snapshot()), 20)
+			if len(lines) > 0 {
+				fmt.Fprintln(stdout, strings.Join(lines, "\n"))
+			}
+		})
+	}
+}
+
+// handleStopped acts on a stopped run: a skipped task moves to Blocked,
+// while a restarted or preempted one stays in progress, so the loop starts
+// it again
+func handleStopped(progressFile string, run *TaskExecution) {
+	switch run.StopReason {
+	case stopRetry:
+		fmt.Printf("[%s] 🔁 Restarting from the dashboard: %s\n", ts(), run.TaskTitle)
+		logTaskRetry(run.TaskTitle, "restarted")
+		return
+	case stopPreempted:
+		fmt.Printf("[%s] ⏸️  Stopped to free a slot for a higher-priority repository, stays in progress: %s\n", ts(), run.TaskTitle)
+		logTaskRetry(run.TaskTitle, "preempted")
+		return
+	}
+	if err := updateControlFile(progressFile, func(progress string) string {
+		return tasks.MarkTaskBlocked(progress, run.TaskTitle, "skipped from the dashboard")
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not skip '%s': %v\n", ts(), run.TaskTitle, err)
+		return
+	}
+	fmt.Printf("[%s] ⏭️ Skipped from the dashboard, moved to Blocked: %s\n", ts(), run.TaskTitle)
+	logTaskBlocked(run.TaskTitle, "skipped from the dashboard")
+	fmt.Printf("[%s] 💡 Put it back with 'cursor-iter triage'\n", ts())
+}
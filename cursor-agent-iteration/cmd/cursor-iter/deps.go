@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/deps"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// dependencyMilestone groups staged dependency update tasks
+const dependencyMilestone = "dependencies"
+
+// genDependencyTasks adds a task per upgrade to tasks.md, or to the staging
+// file with stage. Major upgrades are skipped unless major is set, and
+// upgrades that already have a task are left alone. With dryRun nothing is
+// written.
+func genDependencyTasks(updates []deps.Update, major, stage, dryRun bool) (added []string, skipped []deps.Update, err error) {
+	existing, err := existingTaskTitles()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var blocks []string
+	for _, u := range updates {
+		title := deps.TaskTitle(u)
+		if existing[title] {
+			continue
+		}
+		if !major && !u.Safe() {
+			skipped = append(skipped, u)
+			continue
+		}
+		existing[title] = true
+		added = append(added, title)
+		blocks = append(blocks, deps.TaskBlock(u))
+	}
+	if dryRun {
+		return added, skipped, nil
+	}
+	return added, skipped, addGeneratedTasks(added, blocks, dependencyMilestone, stage)
+}
+
+// existingTaskTitles returns the titles of the tasks in tasks.md and the
+// staging file, so generators don't add a task twice
+func existingTaskTitles() (map[string]bool, error) {
+	current, err := os.ReadFile(resolveTasksFile())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	staged, _ := os.ReadFile(stagedTasksPath())
+
+	existing := make(map[string]bool)
+	for _, t := range tasks.ParseTasks(string(current)) {
+		existing[t.Title] = true
+	}
+	for _, m := range tasks.ParseStaged(string(staged)) {
+		for _, t := range m.Tasks {
+			existing[t.Title] = true
+		}
+	}
+	return existing, nil
+}
+
+// addGeneratedTasks appends generated task blocks to tasks.md, or stages
+// them under milestone for review
+func addGeneratedTasks(titles, blocks []string, milestone string, stage bool) error {
+	if len(blocks) == 0 {
+		return nil
+	}
+	if stage {
+		var newStaged []tasks.StagedTask
+		for i, block := range blocks {
+			newStaged = append(newStaged, tasks.StagedTask{Title: titles[i], Milestone: milestone, Block: block})
+		}
+		return updateControlFile(stagedTasksPath(), func(staged string) string {
+			return tasks.AddStaged(staged, newStaged)
+		})
+	}
+	return updateControlFile(resolveTasksFile(), func(current string) string {
+		return tasks.AppendTasks(current, blocks)
+	})
+}
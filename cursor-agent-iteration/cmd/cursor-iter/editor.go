@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// editorLabelPrefix starts the label of every task and launch configuration
+// editor-setup writes, so running it again replaces them and keeps the rest
+const editorLabelPrefix = "cursor-iter: "
+
+// serveReadyPattern matches the line serve prints once it listens; VS Code
+// waits for it before running the tasks that need the API
+const serveReadyPattern = "Serving the cursor-iter API"
+
+// editorTask is a task of .vscode/tasks.json
+type editorTask struct {
+	Label        string   `json:"label"`
+	Type         string   `json:"type"`
+	Command      string   `json:"command"`
+	Args         []string `json:"args,omitempty"`
+	IsBackground bool     `json:"isBackground,omitempty"`
+	DependsOn    string   `json:"dependsOn,omitempty"`
+	// ProblemMatcher is [] for tasks VS Code shouldn't scan, and tells it
+	// when a background task is ready
+	ProblemMatcher any            `json:"problemMatcher"`
+	Presentation   map[string]any `json:"presentation,omitempty"`
+}
+
+// editorLaunch is a configuration of .vscode/launch.json. The built-in
+// node-terminal type runs its command in a terminal of the Run and Debug
+// view; no debugger attaches to cursor-iter itself.
+type editorLaunch struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Request string `json:"request"`
+	Command string `json:"command"`
+}
+
+// editorTasks returns the tasks that drive cursor-iter from the editor. bin
+// runs cursor-iter and addr is where serve listens. The API tasks send
+// SERVE_TOKEN from the editor's environment, which a server without a
+// token ignores.
+func editorTasks(bin, addr string) []editorTask {
+	serve := editorLabelPrefix + "serve"
+	auth := "Authorization: Bearer ${env:SERVE_TOKEN}"
+	dedicated := map[string]any{"panel": "dedicated"}
+	return []editorTask{
+		{Label: editorLabelPrefix + "iterate selected task", Type: "shell", Command: bin, Args: []string{"iterate", "--task", "${selectedText}"}, ProblemMatcher: []any{}},
+		{Label: editorLabelPrefix + "iterate", Type: "shell", Command: bin, Args: []string{"iterate"}, ProblemMatcher: []any{}},
+		{Label: editorLabelPrefix + "iterate-loop", Type: "shell", Command: bin, Args: []string{"iterate-loop"}, ProblemMatcher: []any{}, Presentation: dedicated},
+		{Label: editorLabelPrefix + "task status", Type: "shell", Command: bin, Args: []string{"task-status"}, ProblemMatcher: []any{}},
+		{
+			Label: serve, Type: "shell", Command: bin, Args: []string{"serve", "--addr", addr}, IsBackground: true,
+			ProblemMatcher: map[string]any{
+				"owner":   "cursor-iter",
+				"pattern": map[string]any{"regexp": "^error: (.*)$", "message": 1},
+				"background": map[string]any{
+					"activeBegins":  true,
+					"beginsPattern": serveReadyPattern,
+					"endsPattern":   serveReadyPattern,
+				},
+			},
+			Presentation: dedicated,
+		},
+		{Label: editorLabelPrefix + "status panel", Type: "shell", Command: "curl", Args: []string{"-s", "-H", auth, "http://" + addr + "/api/status"}, DependsOn: serve, ProblemMatcher: []any{}, Presentation: dedicated},
+		{Label: editorLabelPrefix + "tail logs", Type: "shell", Command: "curl", Args: []string{"-sN", "-H", auth, "http://" + addr + "/api/logs?follow=true"}, DependsOn: serve, ProblemMatcher: []any{}, Presentation: dedicated},
+	}
+}
+
+// editorLaunches returns the launch configurations that run cursor-iter
+// from the Run and Debug view
+func editorLaunches(bin string) []editorLaunch {
+	launch := func(name, command string) editorLaunch {
+		return editorLaunch{Name: editorLabelPrefix + name, Type: "node-terminal", Request: "launch", Command: bin + " " + command}
+	}
+	return []editorLaunch{
+		launch("iterate selected task", `iterate --task "${selectedText}"`),
+		launch("iterate", "iterate"),
+		launch("iterate-loop", "iterate-loop"),
+	}
+}
+
+// writeEditorFile writes the generated entries to the list key of the JSON
+// file at path: tasks of tasks.json, configurations of launch.json. Entries
+// named with editorLabelPrefix are replaced and the others kept. A file VS
+// Code reads but encoding/json can't, e.g. one with comments, is only
+// overwritten with force.
+func writeEditorFile(path, key, version string, generated []any, force bool) error {
+	doc := map[string]any{}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil && json.Unmarshal(data, &doc) != nil {
+		if !force {
+			return fmt.Errorf("%s is not plain JSON (comments or trailing commas?); fix it or overwrite it with --force", path)
+		}
+		doc = map[string]any{}
+	}
+	if doc == nil {
+		doc = map[string]any{}
+	}
+	if _, ok := doc["version"]; !ok {
+		doc["version"] = version
+	}
+	existing, _ := doc[key].([]any)
+	var entries []any
+	for _, e := range existing {
+		if m, ok := e.(map[string]any); ok && isEditorEntry(m) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	doc[key] = append(entries, generated...)
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(out, '\n'), 0644)
+}
+
+// isEditorEntry reports whether editor-setup wrote a task or configuration
+func isEditorEntry(m map[string]any) bool {
+	for _, field := range []string{"label", "name"} {
+		if s, ok := m[field].(string); ok && strings.HasPrefix(s, editorLabelPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// editorSetup writes the tasks and launch configurations to dir, .vscode
+// for VS Code and Cursor, and returns the paths it wrote
+func editorSetup(dir, bin, addr string, force bool) ([]string, error) {
+	var taskEntries, launchEntries []any
+	for _, t := range editorTasks(bin, addr) {
+		taskEntries = append(taskEntries, t)
+	}
+	for _, l := range editorLaunches(bin) {
+		launchEntries = append(launchEntries, l)
+	}
+	tasksPath, launchPath := filepath.Join(dir, "tasks.json"), filepath.Join(dir, "launch.json")
+	if err := writeEditorFile(tasksPath, "tasks", "2.0.0", taskEntries, force); err != nil {
+		return nil, err
+	}
+	if err := writeEditorFile(launchPath, "configurations", "0.2.0", launchEntries, force); err != nil {
+		return []string{tasksPath}, err
+	}
+	return []string{tasksPath, launchPath}, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/atomicfile"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// editTask changes a task in tasks.md under the lock iterate-loop takes,
+// renaming its progress.md entries with it. A task in progress can't be
+// renamed: the run working on it would record its result under the old
+// title.
+func editTask(tasksPath, progressPath, title string, e tasks.TaskEdit) error {
+	unlock, err := atomicfile.LockFiles(tasksPath, progressPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	current, err := os.ReadFile(tasksPath)
+	if err != nil {
+		return err
+	}
+	progress, _ := os.ReadFile(progressPath)
+	if e.Title != "" && !strings.EqualFold(strings.TrimSpace(e.Title), title) && tasks.IsTaskInProgress(string(progress), title) {
+		return fmt.Errorf("%q is in progress; rename it once its run has finished", title)
+	}
+	newTasks, newProgress, err := tasks.EditTask(string(current), string(progress), title, e)
+	if err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(tasksPath, []byte(newTasks), 0644); err != nil {
+		return err
+	}
+	if newProgress != string(progress) {
+		return writeProgress(progressPath, string(progress), newProgress)
+	}
+	return nil
+}
+
+// dependentTasks returns the tasks of tasks.md that list title among their
+// dependencies
+func dependentTasks(tasksPath, title string) []string {
+	content, err := os.ReadFile(tasksPath)
+	if err != nil {
+		return nil
+	}
+	var dependents []string
+	for _, t := range tasks.ParseTasks(string(content)) {
+		for _, dep := range t.Dependencies {
+			if strings.EqualFold(dep, title) {
+				dependents = append(dependents, t.Title)
+				break
+			}
+		}
+	}
+	return dependents
+}
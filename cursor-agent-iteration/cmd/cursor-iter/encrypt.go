@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/atomicfile"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/seal"
+)
+
+// sealableFiles are the control files that may be encrypted at rest. Agents
+// only read them, so their content can go into prompts instead; tasks.md,
+// progress.md and CHANGELOG.md are edited by agents in place and stay
+// plain.
+var sealableFiles = []string{
+	"architecture.md",
+	"decisions.md",
+	"test_plan.md",
+	"qa_checklist.md",
+	"context.md",
+	"glossary.md",
+}
+
+// keychainService is the name the key is stored under in the OS keychain
+const keychainService = "cursor-iter"
+
+var (
+	keyOnce    sync.Once
+	sealKey    []byte
+	sealKeyErr error
+)
+
+// controlKey returns the key of sealed control files: CURSOR_ITER_KEY, or
+// the OS keychain entry for keychainService (macOS Keychain, or the Secret
+// Service through secret-tool on Linux)
+func controlKey() ([]byte, error) {
+	keyOnce.Do(func() {
+		encoded := os.Getenv("CURSOR_ITER_KEY")
+		if encoded == "" {
+			encoded = keychainKey()
+		}
+		if encoded == "" {
+			sealKeyErr = fmt.Errorf("no key: set CURSOR_ITER_KEY or store one in the OS keychain as %q (see 'cursor-iter encrypt --gen-key')", keychainService)
+			return
+		}
+		sealKey, sealKeyErr = seal.ParseKey(encoded)
+	})
+	return sealKey, sealKeyErr
+}
+
+// keychainKey reads the key from the OS keychain, or returns ""
+func keychainKey() string {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", keychainService, "-w")
+	case "linux":
+		cmd = exec.Command("secret-tool", "lookup", "service", keychainService)
+	default:
+		return ""
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// openControlData decrypts the content of a control file if it is sealed
+func openControlData(data []byte) ([]byte, error) {
+	if !seal.IsSealed(data) {
+		return data, nil
+	}
+	key, err := controlKey()
+	if err != nil {
+		return nil, err
+	}
+	return seal.Open(key, data)
+}
+
+// readControlFile reads a control file, decrypting it in memory if it is
+// sealed
+func readControlFile(name string) ([]byte, error) {
+	data, err := os.ReadFile(getControlFilePath(name))
+	if err != nil {
+		return nil, err
+	}
+	plain, err := openControlData(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return plain, nil
+}
+
+// sealedControlFiles returns the control files that are encrypted on disk
+func sealedControlFiles() []string {
+	var sealed []string
+	for _, name := range sealableFiles {
+		if data, err := os.ReadFile(getControlFilePath(name)); err == nil && seal.IsSealed(data) {
+			sealed = append(sealed, name)
+		}
+	}
+	return sealed
+}
+
+// sealControlFile encrypts a control file in place; sealed files are left
+// as they are
+func sealControlFile(name string) (bool, error) {
+	if !slices.Contains(sealableFiles, name) {
+		return false, fmt.Errorf("%s can't be encrypted: agents edit it in place (encryptable: %s)", name, strings.Join(sealableFiles, ", "))
+	}
+	path := getControlFilePath(name)
+	data, err := os.ReadFile(path)
+	if err != nil || seal.IsSealed(data) {
+		return false, err
+	}
+	key, err := controlKey()
+	if err != nil {
+		return false, err
+	}
+	sealed, err := seal.Seal(key, data)
+	if err != nil {
+		return false, err
+	}
+	return true, rewriteControlFile(path, sealed)
+}
+
+// unsealControlFile decrypts a control file in place
+func unsealControlFile(name string) (bool, error) {
+	path := getControlFilePath(name)
+	data, err := os.ReadFile(path)
+	if err != nil || !seal.IsSealed(data) {
+		return false, err
+	}
+	plain, err := openControlData(data)
+	if err != nil {
+		return false, fmt.Errorf("%s: %v", name, err)
+	}
+	return true, rewriteControlFile(path, plain)
+}
+
+// rewriteControlFile replaces a control file in one step, so a crash can't
+// leave it half encrypted, keeping its mode
+func rewriteControlFile(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, data, info.Mode().Perm())
+}
+
+// resealControlFiles encrypts again the files that were sealed before an
+// agent run and that the agent rewrote in plain text
+func resealControlFiles(names []string) {
+	for _, name := range names {
+		resealed, err := sealControlFile(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not encrypt %s again: %v\n", ts(), name, err)
+		} else if resealed {
+			fmt.Printf("[%s] 🔒 An agent rewrote %s in plain text; encrypted it again\n", ts(), name)
+		}
+	}
+}
+
+// sealedFilesNote gives the agent the decrypted content of the sealed
+// control files, which it can't read from disk. It is "" when no file is
+// sealed.
+func sealedFilesNote() string {
+	var b strings.Builder
+	for _, name := range sealedControlFiles() {
+		data, err := readControlFile(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: leaving encrypted %s out of the prompt: %v\n", ts(), name, err)
+			continue
+		}
+		fmt.Fprintf(&b, "\n\n### %s\n\n%s", getControlFilePath(name), strings.TrimSpace(string(data)))
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "## Encrypted Control Files\n\nThese control files are encrypted on disk, so their content is included here. Don't try to read or decrypt them. To change one, overwrite it with its complete new content in plain text; cursor-iter encrypts it again after your run. Never copy their content into other files." + b.String()
+}
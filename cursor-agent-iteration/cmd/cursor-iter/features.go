@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/atomicfile"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/features"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// featuresLogPath is the log of add-feature requests and their delivery
+func featuresLogPath() string {
+	return getControlFilePath("features.md")
+}
+
+// recordFeature labels the tasks add-feature added to tasks.md with a new
+// feature ID and logs the request in the features log. It returns the
+// feature, with no ID when the agent added no tasks.
+func recordFeature(tasksPath, previousTasks, description string, now time.Time) (features.Feature, error) {
+	unlock, err := atomicfile.LockFiles(tasksPath, featuresLogPath())
+	if err != nil {
+		return features.Feature{}, err
+	}
+	defer unlock()
+	current, err := os.ReadFile(tasksPath)
+	if err != nil {
+		return features.Feature{}, err
+	}
+	existing := make(map[string]bool)
+	for _, t := range tasks.ParseTasks(previousTasks) {
+		existing[t.Title] = true
+	}
+	f := features.Feature{ID: features.NewID(now), Title: features.Title(description), Requested: now.Truncate(time.Minute)}
+	md := string(current)
+	for _, t := range tasks.ParseTasks(md) {
+		if !existing[t.Title] {
+			f.Tasks = append(f.Tasks, t.Title)
+			md = tasks.SetTaskLabel(md, t.Title, features.LabelKey, f.ID)
+		}
+	}
+	if len(f.Tasks) == 0 {
+		return features.Feature{}, nil
+	}
+	log, _ := os.ReadFile(featuresLogPath())
+	// Log the request first so no task points at a feature that isn't there
+	if err := atomicfile.WriteFile(featuresLogPath(), []byte(features.Format(append(features.Parse(string(log)), f))), 0644); err != nil {
+		return features.Feature{}, err
+	}
+	return f, atomicfile.WriteFile(tasksPath, []byte(md), 0644)
+}
+
+// deliverFeature checks whether a task that just completed was the last
+// open task of its feature, and if so appends what delivering the feature
+// took to the features log: its runs, cost, time, commits and pull
+// requests. A task of the feature still in staged-tasks.md holds it open.
+func deliverFeature(tasksFile, progressFile, title string, prices map[string]float64) {
+	tasksMd, err := os.ReadFile(tasksFile)
+	if err != nil {
+		return
+	}
+	id, ok := tasks.LabelValue(tasks.ParseLabels(tasks.ExtractTaskDetails(string(tasksMd), title)), features.LabelKey)
+	if !ok {
+		return
+	}
+	progressMd, _ := os.ReadFile(progressFile)
+	var titles []string
+	for _, t := range tasks.ParseTasks(string(tasksMd)) {
+		if v, _ := tasks.LabelValue(t.Labels, features.LabelKey); v == id {
+			titles = append(titles, t.Title)
+		}
+	}
+	entries := tasks.ParseProgressFor(string(progressMd), titles)
+	for _, t := range titles {
+		if entries[t].Status != "completed" {
+			return
+		}
+	}
+	if staged, _ := os.ReadFile(stagedTasksPath()); strings.Contains(string(staged), "["+features.LabelKey+":"+id+"]") {
+		return
+	}
+
+	var summary []string
+	if err := updateControlFile(featuresLogPath(), func(log string) string {
+		all := features.Parse(log)
+		f, ok := features.Find(all, id)
+		if !ok || !f.Delivered.IsZero() {
+			return log
+		}
+		runs, _ := journal.Read(journalPath())
+		f.Delivered = time.Now().Truncate(time.Minute)
+		f.Summary = featureSummary(*f, titles, runs, string(progressMd), prices)
+		summary = f.Summary
+		return features.Format(all)
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not record the delivery of feature %s: %v\n", ts(), id, err)
+		return
+	}
+	if summary != nil {
+		fmt.Printf("[%s] 🚀 Feature %s delivered: %s (see %s)\n", ts(), id, strings.Join(summary, "; "), featuresLogPath())
+	}
+}
+
+// featureSummary sums up the runs of a feature's tasks, found by the
+// feature label they were dispatched with, the commits they made and the
+// pull requests progress.md records for its tasks
+func featureSummary(f features.Feature, titles []string, runs []journal.Entry, progressMd string, prices map[string]float64) []string {
+	var cost journal.TaskCost
+	var agentTime time.Duration
+	seen := make(map[string]bool)
+	var commits []string
+	for _, e := range runs {
+		if v, _ := tasks.LabelValue(e.Labels, features.LabelKey); v != f.ID {
+			continue
+		}
+		cost.Runs++
+		cost.Tokens += e.Tokens
+		cost.Estimated = cost.Estimated || e.TokensEstimated
+		if usd, ok := e.Cost(prices); ok {
+			cost.USD += usd
+		} else {
+			cost.Unpriced++
+		}
+		agentTime += e.Duration()
+		if e.HeadBefore == "" || e.HeadAfter == "" || e.HeadBefore == e.HeadAfter {
+			continue
+		}
+		between, err := commitsBetween(e.HeadBefore, e.HeadAfter)
+		if err != nil {
+			continue
+		}
+		for _, hash := range taskCommits(between, e.Task) {
+			if !seen[hash] {
+				seen[hash] = true
+				commits = append(commits, hash)
+			}
+		}
+	}
+
+	// Tasks renamed since the request are under their new title, and
+	// tasks archived since are only in the request
+	var all []string
+	done := make(map[string]bool)
+	for _, t := range append(append([]string{}, f.Tasks...), titles...) {
+		if !done[t] {
+			done[t] = true
+			all = append(all, t)
+		}
+	}
+	var prs []string
+	entries := tasks.ParseProgressFor(progressMd, all)
+	for _, t := range all {
+		if url, ok := entries[t].Field("pr"); ok && !seen[url] {
+			seen[url] = true
+			prs = append(prs, url)
+		}
+	}
+
+	summary := []string{
+		fmt.Sprintf("%d task(s), %d run(s) in %v of agent time, %v after the request", max(len(f.Tasks), len(titles)), cost.Runs, agentTime.Round(time.Second), f.Delivered.Sub(f.Requested).Round(time.Minute)),
+	}
+	if cost.Runs > 0 {
+		summary = append(summary, fmt.Sprintf("cost %s, %s tokens", formatCost(cost), formatTokens(cost)))
+	}
+	summary = append(summary, fmt.Sprintf("%d commit(s)", len(commits)))
+	if len(prs) > 0 {
+		summary = append(summary, "pull requests: "+strings.Join(prs, ", "))
+	}
+	return summary
+}
+
+// recordPR adds the pull request opened for a completed task to its
+// completion entry, where the features log finds it
+func recordPR(progressFile, title, url string) {
+	if err := updateControlFile(progressFile, func(progress string) string {
+		return tasks.SetCompletionFields(progress, title, []tasks.Field{{Name: "pr", Value: url}})
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not record the pull request of '%s': %v\n", ts(), title, err)
+	}
+}
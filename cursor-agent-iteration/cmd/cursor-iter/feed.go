@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/feed"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// feedFormat resolves --format of feed, defaulting by the extension of the
+// output file
+func feedFormat(path, format string) (string, error) {
+	switch format {
+	case "ics", "json":
+		return format, nil
+	case "":
+		if strings.EqualFold(filepath.Ext(path), ".json") {
+			return "json", nil
+		}
+		return "ics", nil
+	}
+	return "", fmt.Errorf("unknown feed format %q (want ics or json)", format)
+}
+
+// progressFeed builds the feed of the completions in progressFile and the
+// archives in archiveDir. A task's milestone comes from its labels in
+// tasksFile, or from the milestone field archive-completed leaves on the
+// entries of the tasks it removed.
+func progressFeed(title, tasksFile, progressFile, archiveDir string) (feed.Feed, error) {
+	tasksMd, err := os.ReadFile(tasksFile)
+	if err != nil && !os.IsNotExist(err) {
+		return feed.Feed{}, err
+	}
+	progressMd, err := os.ReadFile(progressFile)
+	if err != nil && !os.IsNotExist(err) {
+		return feed.Feed{}, err
+	}
+	all := tasks.ParseTasks(string(tasksMd))
+	titles := make([]string, 0, len(all))
+	milestones := make(map[string]string)
+	for _, t := range all {
+		titles = append(titles, t.Title)
+		if m := tasks.TaskMilestone(t); m != tasks.DefaultMilestone {
+			milestones[t.Title] = m
+		}
+	}
+
+	completed, err := archivedTasks(archiveDir, titles)
+	if err != nil {
+		return feed.Feed{}, err
+	}
+	for title, e := range tasks.ParseProgressFor(string(progressMd), titles) {
+		if e.Status == "completed" {
+			completed[title] = e
+		}
+	}
+
+	open := make(map[string]int)
+	for _, t := range all {
+		if _, done := completed[t.Title]; !done && milestones[t.Title] != "" {
+			open[milestones[t.Title]]++
+		}
+	}
+	var completions []feed.Completion
+	for title, e := range completed {
+		if e.CompletedAt.IsZero() {
+			continue
+		}
+		m := milestones[title]
+		if m == "" {
+			m, _ = e.Field("milestone")
+		}
+		completions = append(completions, feed.Completion{Task: title, Milestone: m, Notes: e.Notes, Time: wallClock(e.CompletedAt)})
+	}
+	sort.Slice(completions, func(i, j int) bool {
+		if !completions[i].Time.Equal(completions[j].Time) {
+			return completions[i].Time.Before(completions[j].Time)
+		}
+		return completions[i].Task < completions[j].Task
+	})
+	return feed.Build(title, completions, open), nil
+}
+
+// wallClock places a progress timestamp, written in local time without a
+// zone and parsed as UTC, in the local zone
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local)
+}
+
+// defaultFeedTitle names the feed after the repository
+func defaultFeedTitle() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "cursor-iter"
+	}
+	return "cursor-iter: " + filepath.Base(dir)
+}
+
+// writeFeed renders the feed to path, or stdout when path is empty
+func writeFeed(path, format string, f feed.Feed) error {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "ics":
+		err = f.ICS(&buf)
+	case "json":
+		err = f.JSON(&buf)
+	default:
+		err = fmt.Errorf("unknown feed format %q (want ics or json)", format)
+	}
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		_, err := io.Copy(os.Stdout, &buf)
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// namedTask returns the task iterate --task names, by title or ID, and its
+// status in progress.md. Completed, blocked and failed tasks are refused,
+// since iterate would only run them again.
+func namedTask(tasksMd, progressMd, ref string) (*tasks.Task, string, error) {
+	title, err := resolveTaskRef(tasksMd, ref)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, t := range tasks.ParseTasks(tasksMd) {
+		if t.Title != title {
+			continue
+		}
+		status := tasks.ParseProgressFor(progressMd, []string{t.Title})[t.Title].Status
+		switch status {
+		case "completed":
+			return nil, status, fmt.Errorf("task '%s' is already completed", t.Title)
+		case "blocked", "failed":
+			return nil, status, fmt.Errorf("task '%s' is %s; retry it with 'cursor-iter triage' first", t.Title, status)
+		}
+		return &t, status, nil
+	}
+	return nil, "", fmt.Errorf("no task %q in tasks.md", title)
+}
+
+// taskFlags collects repeated --task flags
+type taskFlags []string
+
+func (t *taskFlags) String() string {
+	return strings.Join(*t, ", ")
+}
+
+func (t *taskFlags) Set(v string) error {
+	if strings.TrimSpace(v) == "" {
+		return errors.New("the task title is empty")
+	}
+	*t = append(*t, strings.TrimSpace(v))
+	return nil
+}
+
+// loopFocus is the tasks iterate-loop was told to work on; empty, it works
+// on all of them
+type loopFocus []string
+
+// newLoopFocus resolves the tasks given to iterate-loop --task, by title or
+// ID, against tasks.md
+func newLoopFocus(tasksMd string, refs []string) (loopFocus, error) {
+	var focus loopFocus
+	for _, ref := range refs {
+		title, err := resolveTaskRef(tasksMd, ref)
+		if err != nil {
+			return nil, err
+		}
+		focus = append(focus, title)
+	}
+	return focus, nil
+}
+
+// narrow returns tasks.md with only the tasks of the focus, so the loop
+// dispatches them ahead of the tasks before them and finishes with them.
+// A task of the focus that was since removed or renamed is reported as
+// missing.
+func (f loopFocus) narrow(tasksMd string) (string, []string) {
+	if len(f) == 0 {
+		return tasksMd, nil
+	}
+	return tasks.OnlyTasks(tasksMd, f)
+}
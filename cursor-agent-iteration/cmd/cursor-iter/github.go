@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/issues"
+)
+
+// githubIssuesPath records the issues sync-github imported, keyed by
+// issues.Ref, until it closes them
+func githubIssuesPath() string {
+	return getControlFilePath("github-issues.json")
+}
+
+// syncedIssue is an issue sync-github imported and hasn't closed yet
+type syncedIssue struct {
+	Repo       string    `json:"repo"`
+	Number     int       `json:"number"`
+	Title      string    `json:"title"`
+	URL        string    `json:"url"`
+	ImportedAt time.Time `json:"imported_at"`
+}
+
+// githubSync is what sync-github does: import the open issues of Repo
+// labeled Label and close those whose tasks were archived to ArchiveDir
+type githubSync struct {
+	Repo       string
+	Label      string
+	ArchiveDir string
+	// Limit caps the issues fetched
+	Limit  int
+	DryRun bool
+}
+
+// run closes the issues whose tasks were archived, then imports the new
+// ones, and returns the refs of both. An issue is imported once: when its
+// task is removed without being completed, the issue is left open and not
+// imported again.
+func (g githubSync) run() (imported, closed []string, err error) {
+	if _, err := exec.LookPath("gh"); err != nil {
+		return nil, nil, fmt.Errorf("sync-github talks to GitHub with the GitHub CLI, which is not installed: %v", err)
+	}
+	synced, err := readSyncedIssues()
+	if err != nil {
+		return nil, nil, err
+	}
+	closed, err = g.closeArchived(synced)
+	if err == nil {
+		imported, err = g.importIssues(synced, closed)
+	}
+	// The issues closed before an error are forgotten all the same
+	if !g.DryRun {
+		if werr := writeSyncedIssues(synced); err == nil {
+			err = werr
+		}
+	}
+	return imported, closed, err
+}
+
+// closeArchived closes the issues of Repo whose tasks are in an archive
+// and no longer in tasks.md, with their completion notes as the comment,
+// and forgets them
+func (g githubSync) closeArchived(synced map[string]syncedIssue) ([]string, error) {
+	current, err := existingTaskTitles()
+	if err != nil {
+		return nil, err
+	}
+	var titles []string
+	for _, s := range synced {
+		if s.Repo == g.Repo && !current[s.Title] {
+			titles = append(titles, s.Title)
+		}
+	}
+	if len(titles) == 0 {
+		return nil, nil
+	}
+	completed, err := archivedTasks(g.ArchiveDir, titles)
+	if err != nil {
+		return nil, err
+	}
+
+	var closed []string
+	for _, ref := range sortedRefs(synced) {
+		s := synced[ref]
+		e, ok := completed[s.Title]
+		if s.Repo != g.Repo || current[s.Title] || !ok {
+			continue
+		}
+		if g.DryRun {
+			fmt.Printf("[%s] 📪 Would close %s: '%s' was archived\n", ts(), ref, s.Title)
+		} else {
+			comment := issues.CloseComment(s.Title, e.CompletedAt.Format("2006-01-02 15:04"), e.Notes)
+			if _, err := gh("issue", "close", strconv.Itoa(s.Number), "--repo", s.Repo, "--comment", comment); err != nil {
+				return closed, fmt.Errorf("could not close %s: %v", ref, err)
+			}
+			delete(synced, ref)
+			fmt.Printf("[%s] 📪 Closed %s: '%s' was archived\n", ts(), ref, s.Title)
+		}
+		closed = append(closed, ref)
+	}
+	return closed, nil
+}
+
+// importIssues adds a task to tasks.md for every open issue of Repo labeled
+// Label that wasn't imported before, skipping those whose title a task
+// already has and those just closed, which GitHub may still list as open
+func (g githubSync) importIssues(synced map[string]syncedIssue, closed []string) ([]string, error) {
+	out, err := gh("issue", "list", "--repo", g.Repo, "--label", g.Label, "--state", "open", "--limit", strconv.Itoa(g.Limit), "--json", "number,title,body,url")
+	if err != nil {
+		return nil, fmt.Errorf("could not list the issues of %s: %v", g.Repo, err)
+	}
+	var open []issues.Issue
+	if err := json.Unmarshal([]byte(out), &open); err != nil {
+		return nil, fmt.Errorf("could not read the issues of %s: %v", g.Repo, err)
+	}
+	existing, err := existingTaskTitles()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(open, func(i, j int) bool { return open[i].Number < open[j].Number })
+
+	var imported, titles, blocks []string
+	added := make(map[string]syncedIssue)
+	for _, issue := range open {
+		ref := issues.Ref(g.Repo, issue.Number)
+		title := strings.TrimSpace(issue.Title)
+		if _, ok := synced[ref]; ok || slices.Contains(closed, ref) {
+			continue
+		}
+		if existing[title] {
+			fmt.Printf("[%s] ⏭️ Skipped %s: a task is already named '%s'\n", ts(), ref, title)
+			continue
+		}
+		existing[title] = true
+		verb := "Imported"
+		if g.DryRun {
+			verb = "Would import"
+		}
+		fmt.Printf("[%s] 📥 %s %s as '%s'\n", ts(), verb, ref, title)
+		imported = append(imported, ref)
+		titles = append(titles, title)
+		blocks = append(blocks, issues.TaskBlock(g.Repo, issue))
+		added[ref] = syncedIssue{Repo: g.Repo, Number: issue.Number, Title: title, URL: issue.URL, ImportedAt: time.Now()}
+	}
+	if g.DryRun {
+		return imported, nil
+	}
+	if err := addGeneratedTasks(titles, blocks, "", false); err != nil {
+		return nil, err
+	}
+	for ref, s := range added {
+		synced[ref] = s
+	}
+	return imported, nil
+}
+
+// readSyncedIssues reads githubIssuesPath, empty when there is none yet
+func readSyncedIssues() (map[string]syncedIssue, error) {
+	synced := make(map[string]syncedIssue)
+	data, err := os.ReadFile(githubIssuesPath())
+	if os.IsNotExist(err) {
+		return synced, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &synced); err != nil {
+		return nil, fmt.Errorf("could not read %s: %v", githubIssuesPath(), err)
+	}
+	return synced, nil
+}
+
+func writeSyncedIssues(synced map[string]syncedIssue) error {
+	data, err := json.MarshalIndent(synced, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(githubIssuesPath(), append(data, '\n'), 0644)
+}
+
+func sortedRefs(synced map[string]syncedIssue) []string {
+	refs := make([]string, 0, len(synced))
+	for ref := range synced {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+// gh runs the GitHub CLI and returns its output, with its stderr in the
+// error when it fails
+func gh(args ...string) (string, error) {
+	cmd := exec.Command("gh", args...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%v: %s", err, msg)
+		}
+		return "", err
+	}
+	return string(out), nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/handoff"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+)
+
+// handoffPath is where the hand-off document is written
+func handoffPath() string {
+	return getControlFilePath("HANDOFF.md")
+}
+
+// gitLines runs git and returns the non-empty lines of its output
+func gitLines(args ...string) []string {
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil
+	}
+	var lines []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, strings.TrimRight(line, " "))
+		}
+	}
+	return lines
+}
+
+// gitRepoState reads the branches, worktrees and uncommitted changes of the
+// repository, leaving out the control files
+func gitRepoState() handoff.Repo {
+	var repo handoff.Repo
+	if current := gitLines("rev-parse", "--abbrev-ref", "HEAD"); len(current) > 0 {
+		repo.Branch = current[0]
+	}
+	for _, branch := range gitLines("for-each-ref", "--format=%(refname:short)", "refs/heads") {
+		if branch != repo.Branch {
+			repo.Branches = append(repo.Branches, branch)
+		}
+	}
+	if out, err := exec.Command("git", "worktree", "list", "--porcelain").Output(); err == nil {
+		repo.Worktrees = handoff.ParseWorktrees(string(out))
+	}
+	// Control files change with every run; only the code matters here
+	for _, line := range gitLines("status", "--short") {
+		if len(line) > 3 && !strings.HasPrefix(strings.Trim(line[3:], `"`), CursorIterDir+"/") {
+			repo.Dirty = append(repo.Dirty, line)
+		}
+	}
+	return repo
+}
+
+// writeHandoff writes the hand-off document for a session that stopped
+// before the backlog was done
+func writeHandoff(path, reason, runID string) error {
+	tasksMd, err := os.ReadFile(resolveTasksFile())
+	if err != nil {
+		return err
+	}
+	progressMd, _ := os.ReadFile(resolveProgressFile())
+	entries, _ := journal.Read(journalPath())
+	doc := handoff.Build(string(tasksMd), string(progressMd), entries, configLabelDefaults())
+	doc.Time = time.Now()
+	doc.Reason = reason
+	doc.RunID = runID
+	doc.Repo = gitRepoState()
+	return os.WriteFile(path, []byte(doc.Markdown()), 0644)
+}
+
+// handOff writes the hand-off document when iterate-loop stops mid-backlog
+func handOff(reason, runID string) {
+	if err := writeHandoff(handoffPath(), reason, runID); err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not write the hand-off document: %v\n", ts(), err)
+		return
+	}
+	fmt.Printf("[%s] 📋 Wrote %s for whoever picks up from here\n", ts(), handoffPath())
+}
+
+// clearHandoff removes the hand-off document once the backlog is done, so it
+// doesn't describe work that no longer exists
+func clearHandoff() {
+	os.Remove(handoffPath())
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+)
+
+// heatBarWidth is the width of the bar for the most changed area
+const heatBarWidth = 20
+
+// commitFiles returns the files changed between the commits of a run
+func commitFiles(e journal.Entry) []string {
+	out, err := exec.Command("git", "diff", "--name-only", e.HeadBefore+".."+e.HeadAfter).Output()
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, CursorIterDir+"/") {
+			files = append(files, line)
+		}
+	}
+	return files
+}
+
+// printHeatmap prints the areas the autopilot changes most, marking those
+// where tasks fail often
+func printHeatmap(out io.Writer, areas []journal.Area, top int) {
+	if top > 0 && len(areas) > top {
+		areas = areas[:top]
+	}
+	max := 0
+	for _, a := range areas {
+		if a.Changes > max {
+			max = a.Changes
+		}
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "AREA\tCHANGES\tTASKS\tRUNS\tFAILED\tRETRIES\tFAIL RATE\t")
+	fragile := 0
+	for _, a := range areas {
+		bar := strings.Repeat("█", (a.Changes*heatBarWidth+max-1)/max)
+		rate := fmt.Sprintf("%.0f%%", a.FailureRate()*100)
+		if a.Fragile() {
+			rate += " ⚠️"
+			fragile++
+		}
+		fmt.Fprintf(w, "%s\t%s %d\t%d\t%d\t%d\t%d\t%s\t\n", a.Path, bar, a.Changes, a.Tasks, a.Runs, a.Failed, a.Retries(), rate)
+	}
+	w.Flush()
+	if fragile > 0 {
+		fmt.Fprintf(out, "\n⚠️ %d fragile area(s): at least %.0f%% of the runs of tasks touching them failed. Consider human-only ownership or better tests there.\n",
+			fragile, journal.FragileFailureRate*100)
+	}
+}
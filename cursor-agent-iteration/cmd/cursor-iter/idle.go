@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/coord"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// idleReportEvery is how many idle iterations in a row pass between reports
+// of why nothing is dispatched, about a minute of waiting
+const idleReportEvery = 30
+
+// idleReasonsShown is how many blocking reasons a report lists
+const idleReasonsShown = 3
+
+// idleTracker counts the iterations of iterate-loop that had nothing running
+// and dispatched nothing, with the reasons tasks could not be started. Idle
+// iterations don't count against the iteration cap.
+type idleTracker struct {
+	total   int
+	streak  int
+	reasons map[string]int
+}
+
+// idleReason is a blocking reason with the number of idle iterations it
+// was seen in
+type idleReason struct {
+	Reason string
+	Count  int
+}
+
+// Record counts an idle iteration and its reasons, and reports whether the
+// reasons should be shown: on the first idle iteration of a streak and every
+// idleReportEvery after it
+func (t *idleTracker) Record(reasons []string) bool {
+	if t.reasons == nil {
+		t.reasons = make(map[string]int)
+	}
+	t.total++
+	t.streak++
+	seen := make(map[string]bool)
+	for _, r := range reasons {
+		if !seen[r] {
+			seen[r] = true
+			t.reasons[r]++
+		}
+	}
+	return t.streak%idleReportEvery == 1
+}
+
+// Active ends a streak of idle iterations
+func (t *idleTracker) Active() {
+	t.streak = 0
+}
+
+// Top returns the n reasons seen in the most idle iterations
+func (t *idleTracker) Top(n int) []idleReason {
+	top := make([]idleReason, 0, len(t.reasons))
+	for r, c := range t.reasons {
+		top = append(top, idleReason{Reason: r, Count: c})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Reason < top[j].Reason
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// Print writes the idle iteration count and the top blocking reasons; it
+// writes nothing when the loop was never idle
+func (t *idleTracker) Print(out io.Writer, header string) {
+	if t.total == 0 {
+		return
+	}
+	fmt.Fprintf(out, "[%s] 💤 %s (%d idle iteration(s) in total, not counted against the cap)\n", ts(), header, t.total)
+	top := t.Top(idleReasonsShown)
+	if len(top) == 0 {
+		fmt.Fprintln(out, "  - no pending or in-progress task could be found")
+		return
+	}
+	fmt.Fprintln(out, "  Top blocking reasons:")
+	for _, r := range top {
+		fmt.Fprintf(out, "  - %s (%d)\n", r.Reason, r.Count)
+	}
+}
+
+// dispatchReason describes why a task could not be started. Waiting for a
+// global slot is one reason for every task, so it is reported once.
+func dispatchReason(title string, err error) string {
+	if errors.Is(err, coord.ErrNoSlot) {
+		return "waiting for a global agent slot shared with other repositories"
+	}
+	return fmt.Sprintf("'%s' could not start: %v", title, err)
+}
+
+// stallReasons explains from the control files why there may be nothing
+// to dispatch: an empty tasks.md, blocked tasks with their reasons, or
+// pending tasks waiting on their dependencies
+func stallReasons(tasksMd, progressMd string) []string {
+	all := tasks.ParseTasks(tasksMd)
+	if len(all) == 0 {
+		return []string{"tasks.md has no tasks"}
+	}
+	titles := make([]string, len(all))
+	for i, t := range all {
+		titles[i] = t.Title
+	}
+	entries := tasks.ParseProgressFor(progressMd, titles)
+	var reasons []string
+	for _, title := range titles {
+		entry := entries[title]
+		if entry.Status != "blocked" && entry.Status != "failed" {
+			continue
+		}
+		reason := fmt.Sprintf("'%s' is %s", title, entry.Status)
+		if entry.Notes != "" {
+			reason += ": " + entry.Notes
+		}
+		reasons = append(reasons, reason)
+	}
+	graph := tasks.NewDependencyGraph(all)
+	for _, title := range titles {
+		if _, exists := entries[title]; exists {
+			continue
+		}
+		if waiting := graph.Waiting(title, entries); len(waiting) > 0 {
+			reasons = append(reasons, fmt.Sprintf("'%s' waits on %s", title, strings.Join(quoteTitles(waiting), ", ")))
+		}
+	}
+	return reasons
+}
+
+// quoteTitles puts each title in single quotes
+func quoteTitles(titles []string) []string {
+	quoted := make([]string, len(titles))
+	for i, title := range titles {
+		quoted[i] = "'" + title + "'"
+	}
+	return quoted
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/issues"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/jira"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// jiraTicketsPath records the tickets sync-jira imported, keyed by ticket
+// key, until it transitions them
+func jiraTicketsPath() string {
+	return getControlFilePath("jira-tickets.json")
+}
+
+// syncedTicket is a ticket sync-jira imported and hasn't transitioned yet
+type syncedTicket struct {
+	Title      string    `json:"title"`
+	URL        string    `json:"url"`
+	ImportedAt time.Time `json:"imported_at"`
+}
+
+// jiraSync is what sync-jira does: import the tickets JQL finds and move
+// those whose tasks are completed through the Done transition
+type jiraSync struct {
+	Client *jira.Client
+	JQL    string
+	Done   string
+	// ProgressFile and ArchiveDir are where completions are looked for
+	ProgressFile string
+	ArchiveDir   string
+	Limit        int
+	DryRun       bool
+}
+
+// jiraJQL is the search of sync-jira without --jql: the project's tickets
+// that aren't done yet
+func jiraJQL(project string) string {
+	return fmt.Sprintf("project = %q AND statusCategory != Done ORDER BY created ASC", project)
+}
+
+// run transitions the tickets whose tasks were completed, then imports the
+// new ones, and returns the keys of both. A ticket is imported once, like
+// the issues of sync-github.
+func (j jiraSync) run() (imported, done []string, err error) {
+	synced, err := readSyncedTickets()
+	if err != nil {
+		return nil, nil, err
+	}
+	done, err = j.transitionCompleted(synced)
+	if err == nil {
+		imported, err = j.importTickets(synced, done)
+	}
+	if !j.DryRun {
+		if werr := writeSyncedTickets(synced); err == nil {
+			err = werr
+		}
+	}
+	return imported, done, err
+}
+
+// transitionCompleted moves the tickets whose tasks progress.md marks
+// completed, or that were archived since, through the Done transition with
+// a comment giving the completion notes, and forgets them
+func (j jiraSync) transitionCompleted(synced map[string]syncedTicket) ([]string, error) {
+	if len(synced) == 0 {
+		return nil, nil
+	}
+	var titles []string
+	for _, s := range synced {
+		titles = append(titles, s.Title)
+	}
+	completed, err := archivedTasks(j.ArchiveDir, titles)
+	if err != nil {
+		return nil, err
+	}
+	progressMd, err := os.ReadFile(j.ProgressFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for title, e := range tasks.ParseProgressFor(string(progressMd), titles) {
+		if e.Status == "completed" {
+			completed[title] = e
+		}
+	}
+
+	keys := make([]string, 0, len(synced))
+	for key := range synced {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var done []string
+	for _, key := range keys {
+		s := synced[key]
+		e, ok := completed[s.Title]
+		if !ok {
+			continue
+		}
+		if j.DryRun {
+			fmt.Printf("[%s] 🎫 Would move %s to %s: '%s' is completed\n", ts(), key, j.Done, s.Title)
+			done = append(done, key)
+			continue
+		}
+		if err := j.Client.Transition(key, j.Done); err != nil {
+			return done, fmt.Errorf("could not move %s to %s: %v", key, j.Done, err)
+		}
+		delete(synced, key)
+		done = append(done, key)
+		fmt.Printf("[%s] 🎫 Moved %s to %s: '%s' is completed\n", ts(), key, j.Done, s.Title)
+		comment := issues.CloseComment(s.Title, e.CompletedAt.Format("2006-01-02 15:04"), e.Notes)
+		if err := j.Client.Comment(key, comment); err != nil {
+			fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not comment on %s: %v\n", ts(), key, err)
+		}
+	}
+	return done, nil
+}
+
+// importTickets adds a task to tasks.md for every ticket JQL finds that
+// wasn't imported before, skipping those whose title a task already has
+// and those just transitioned
+func (j jiraSync) importTickets(synced map[string]syncedTicket, done []string) ([]string, error) {
+	found, err := j.Client.Search(j.JQL, j.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not search Jira: %v", err)
+	}
+	existing, err := existingTaskTitles()
+	if err != nil {
+		return nil, err
+	}
+	skip := make(map[string]bool)
+	for _, key := range done {
+		skip[key] = true
+	}
+
+	var imported, titles, blocks []string
+	added := make(map[string]syncedTicket)
+	for _, t := range found {
+		title := jira.Title(t)
+		if _, ok := synced[t.Key]; ok || skip[t.Key] || title == "" {
+			continue
+		}
+		if existing[title] {
+			fmt.Printf("[%s] ⏭️ Skipped %s: a task is already named '%s'\n", ts(), t.Key, title)
+			continue
+		}
+		existing[title] = true
+		verb := "Imported"
+		if j.DryRun {
+			verb = "Would import"
+		}
+		fmt.Printf("[%s] 📥 %s %s as '%s'\n", ts(), verb, t.Key, title)
+		imported = append(imported, t.Key)
+		titles = append(titles, title)
+		blocks = append(blocks, jira.TaskBlock(j.Client.BaseURL, t))
+		added[t.Key] = syncedTicket{Title: title, URL: jira.BrowseURL(j.Client.BaseURL, t.Key), ImportedAt: time.Now()}
+	}
+	if j.DryRun {
+		return imported, nil
+	}
+	if err := addGeneratedTasks(titles, blocks, "", false); err != nil {
+		return nil, err
+	}
+	for key, s := range added {
+		synced[key] = s
+	}
+	return imported, nil
+}
+
+// readSyncedTickets reads jiraTicketsPath, empty when there is none yet
+func readSyncedTickets() (map[string]syncedTicket, error) {
+	synced := make(map[string]syncedTicket)
+	data, err := os.ReadFile(jiraTicketsPath())
+	if os.IsNotExist(err) {
+		return synced, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &synced); err != nil {
+		return nil, fmt.Errorf("could not read %s: %v", jiraTicketsPath(), err)
+	}
+	return synced, nil
+}
+
+func writeSyncedTickets(synced map[string]syncedTicket) error {
+	data, err := json.MarshalIndent(synced, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(jiraTicketsPath(), append(data, '\n'), 0644)
+}
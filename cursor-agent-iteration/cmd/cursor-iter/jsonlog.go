@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/jsonlog"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/runner"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// The log helpers below are where iterate-loop reports what happens; they
+// also update the Prometheus metrics of --metrics-addr and post to
+// --notify-webhook.
+
+// eventLog receives the structured events of iterate-loop under
+// --log-format json; nil, logging nothing, in text mode
+var eventLog *jsonlog.Logger
+
+// setLogFormat applies the --log-format flag, exiting on invalid values. In
+// json mode stdout carries nothing but events, so the usual output moves to
+// stderr.
+func setLogFormat(format string) {
+	if _, err := jsonlog.ParseFormat(format); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid --log-format: %v\n", err)
+		os.Exit(1)
+	}
+	if format == jsonlog.FormatJSON {
+		eventLog = jsonlog.New(os.Stdout)
+		os.Stdout = os.Stderr
+	}
+}
+
+// logLine is the layout of an event line for its schema: the time and
+// event that jsonlog writes first, then the fields the helpers below set,
+// each only on the events it applies to
+type logLine struct {
+	Time           time.Time `json:"time"`
+	Event          string    `json:"event"`
+	Task           string    `json:"task,omitempty"`
+	TaskID         string    `json:"task_id,omitempty"`
+	RunID          string    `json:"run_id,omitempty"`
+	Backend        string    `json:"backend,omitempty"`
+	Model          string    `json:"model,omitempty"`
+	Fallback       bool      `json:"fallback,omitempty"`
+	PromptVariant  string    `json:"prompt_variant,omitempty"`
+	Log            string    `json:"log,omitempty"`
+	Attempt        int       `json:"attempt,omitempty"`
+	Attempts       int       `json:"attempts,omitempty"`
+	Reason         string    `json:"reason,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	Classification string    `json:"classification,omitempty"`
+	DurationMs     int64     `json:"duration_ms,omitempty"`
+	Iteration      int       `json:"iteration,omitempty"`
+	Iterations     int       `json:"iterations,omitempty"`
+	Active         int       `json:"active,omitempty"`
+	MaxActive      int       `json:"max_active,omitempty"`
+	Total          int       `json:"total,omitempty"`
+	Completed      int       `json:"completed,omitempty"`
+	InProgress     int       `json:"in_progress,omitempty"`
+	Pending        int       `json:"pending,omitempty"`
+	Blocked        int       `json:"blocked,omitempty"`
+	Failed         int       `json:"failed,omitempty"`
+}
+
+// taskFields identify a task in events; the task ID is its slug, as in log
+// and note file names
+func taskFields(title string) jsonlog.Fields {
+	return jsonlog.Fields{"task": title, "task_id": taskSlug(title)}
+}
+
+// logTaskStarted records the dispatch of a task run
+func logTaskStarted(run *TaskExecution, fallback bool, active int) {
+	fields := taskFields(run.TaskTitle)
+	fields["backend"] = string(run.Backend)
+	fields["model"] = run.Model
+	fields["active"] = active
+	if fallback {
+		fields["fallback"] = true
+	}
+	if run.PromptVariant != promptFull {
+		fields["prompt_variant"] = run.PromptVariant
+	}
+	if run.LogPath != "" {
+		fields["log"] = run.LogPath
+	}
+	eventLog.Log(jsonlog.TaskStarted, fields)
+	loopNotifier.started(run)
+}
+
+// logRetries returns a runner.Options hook recording the agent retries of a
+// task run
+func logRetries(title string) func(runner.Backend, int, error) {
+	return func(backend runner.Backend, attempt int, err error) {
+		reason := "agent error"
+		if errors.Is(err, runner.ErrConfigRace) {
+			reason = "config race"
+		}
+		loopMetrics.retry(reason)
+		fields := taskFields(title)
+		fields["backend"] = string(backend)
+		fields["attempt"] = attempt
+		fields["reason"] = reason
+		fields["error"] = err.Error()
+		eventLog.Log(jsonlog.AgentRetry, fields)
+	}
+}
+
+// logTaskRetry records that the loop will dispatch a task again, and why
+func logTaskRetry(title, reason string) {
+	loopMetrics.retry(reason)
+	fields := taskFields(title)
+	fields["reason"] = reason
+	eventLog.Log(jsonlog.AgentRetry, fields)
+}
+
+// logRunOutcome records the journaled outcome of a task run
+func logRunOutcome(entry journal.Entry) {
+	loopMetrics.observeRun(entry)
+	event := jsonlog.TaskIncomplete
+	switch entry.Outcome {
+	case journal.OutcomeCompleted:
+		event = jsonlog.TaskCompleted
+	case journal.OutcomeFailed:
+		event = jsonlog.TaskFailed
+	}
+	fields := taskFields(entry.Task)
+	fields["run_id"] = entry.RunID
+	fields["backend"] = entry.Backend
+	fields["model"] = entry.Model
+	fields["duration_ms"] = entry.DurationMs
+	if entry.Classification != "" {
+		fields["classification"] = entry.Classification
+	}
+	if entry.Error != "" {
+		fields["error"] = entry.Error
+	}
+	if entry.LogPath != "" {
+		fields["log"] = entry.LogPath
+	}
+	eventLog.Log(event, fields)
+	loopNotifier.runOutcome(entry)
+	loopSpend.add(entry)
+}
+
+// logTaskBlocked records a task moved to Blocked
+func logTaskBlocked(title, reason string) {
+	fields := taskFields(title)
+	fields["reason"] = reason
+	eventLog.Log(jsonlog.TaskBlocked, fields)
+	loopNotifier.blocked(title, reason)
+}
+
+// logTaskExhausted records a task moved to Failed once it used up its
+// attempts
+func logTaskExhausted(title string, attempts int, reason string) {
+	fields := taskFields(title)
+	fields["attempts"] = attempts
+	fields["reason"] = reason
+	eventLog.Log(jsonlog.TaskExhausted, fields)
+	loopNotifier.exhausted(title, attempts, reason)
+}
+
+// logIterationTick records the state of the loop at the start of an
+// iteration
+func logIterationTick(iteration, active, maxActive int, tasksMd, progressMd string) {
+	if eventLog == nil && loopMetrics == nil {
+		return
+	}
+	totals := tasks.Summarize(tasksMd, progressMd).Totals
+	loopMetrics.tick(totals)
+	eventLog.Log(jsonlog.IterationTick, jsonlog.Fields{
+		"iteration":   iteration,
+		"active":      active,
+		"max_active":  maxActive,
+		"total":       totals.Total,
+		"completed":   totals.Completed,
+		"in_progress": totals.InProgress,
+		"pending":     totals.Pending,
+		"blocked":     totals.Blocked,
+		"failed":      totals.Failed,
+	})
+}
+
+// logLoopFinished records why iterate-loop stopped
+func logLoopFinished(runID, reason string, iterations int) {
+	eventLog.Log(jsonlog.LoopFinished, jsonlog.Fields{"run_id": runID, "reason": reason, "iterations": iterations})
+	loopNotifier.finished(runID, reason, iterations)
+}
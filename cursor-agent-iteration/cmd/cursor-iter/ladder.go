@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/project"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// Prompt variants, from the full prompt down to the simplest
+const (
+	promptFull      = "full"      // the usual prompt
+	promptLean      = "lean"      // the task alone, without instructions and notes
+	promptRephrased = "rephrased" // the criteria as a plain numbered list
+	promptSplit     = "split"     // only the first open criterion
+)
+
+// defaultPromptLadder is tried, in order, while a model keeps declining
+const defaultPromptLadder = promptLean + "," + promptRephrased + "," + promptSplit
+
+// leanPromptInstructions replace the instructions of the full prompt in the
+// simpler variants, after the lean instruction of the project type
+const leanPromptInstructions = ` Check off each acceptance criterion in .cursor-iter/tasks.md as you finish it, and when all of them are checked move the task to "## Completed Tasks" in .cursor-iter/progress.md.`
+
+// promptLadder is the sequence of prompt variants a task goes through while
+// the model declines it, starting with the full prompt. A nil ladder always
+// sends the full prompt.
+type promptLadder []string
+
+// parsePromptLadder parses a comma-separated list of the variants to fall
+// back to; "" and "none" turn degradation off
+func parsePromptLadder(spec string) (promptLadder, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "none" {
+		return nil, nil
+	}
+	ladder := promptLadder{promptFull}
+	for _, v := range strings.Split(spec, ",") {
+		v = strings.TrimSpace(v)
+		switch v {
+		case promptLean, promptRephrased, promptSplit:
+		default:
+			return nil, fmt.Errorf("unknown prompt variant %q (want %s, %s or %s)", v, promptLean, promptRephrased, promptSplit)
+		}
+		ladder = append(ladder, v)
+	}
+	return ladder, nil
+}
+
+// mustPromptLadder returns the ladder for the --prompt-ladder flag
+func mustPromptLadder(spec string) promptLadder {
+	ladder, err := parsePromptLadder(spec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid --prompt-ladder: %v\n", err)
+		os.Exit(1)
+	}
+	return ladder
+}
+
+// next picks the variant for the next run of a task from its runs in the
+// journal: one rung down after a refusal, back to the top after the last
+// rung, and the variant of the last run otherwise, so a variant that got
+// the model working sticks
+func (l promptLadder) next(entries []journal.Entry, taskTitle string) string {
+	if len(l) == 0 {
+		return promptFull
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if e.Task != taskTitle {
+			continue
+		}
+		at := l.index(e.PromptVariant)
+		if e.Classification != journal.ClassRefusal {
+			return l[at]
+		}
+		return l[(at+1)%len(l)]
+	}
+	return l[0]
+}
+
+// Variant returns the variant for the next run of a task
+func (l promptLadder) Variant(taskTitle string) string {
+	if len(l) == 0 {
+		return promptFull
+	}
+	entries, err := journal.Read(journalPath())
+	if err != nil {
+		return l[0]
+	}
+	return l.next(entries, taskTitle)
+}
+
+// Exhausted reports whether a run was declined on the last rung, so the
+// task should be given up on
+func (l promptLadder) Exhausted(run *TaskExecution, classification string) bool {
+	return len(l) > 1 && run != nil && classification == journal.ClassRefusal && run.PromptVariant == l[len(l)-1]
+}
+
+func (l promptLadder) index(variant string) int {
+	for i, v := range l {
+		if v == variant {
+			return i
+		}
+	}
+	return 0
+}
+
+// variantPrompt builds the prompt of a variant for a project type. details
+// is the task block from tasks.md and extra is appended to it in the full
+// prompt only (the glossary); notes only go with the full prompt.
+func variantPrompt(kind project.Type, variant, details, extra string, notes ...string) string {
+	if variant == promptFull {
+		return buildTaskPrompt(kind, details+extra, notes...)
+	}
+	instructions := kind.Instructions().Lean + leanPromptInstructions
+	var task tasks.Task
+	if parsed := tasks.ParseTasks("## Current Tasks\n\n" + details); len(parsed) > 0 {
+		task = parsed[0]
+	}
+	var open []string
+	for _, c := range task.Criteria {
+		if !c.Checked {
+			open = append(open, c.Text)
+		}
+	}
+	if variant == promptLean || len(open) == 0 {
+		return strings.TrimSpace(details) + "\n\n" + instructions
+	}
+
+	var b strings.Builder
+	if variant == promptSplit {
+		fmt.Fprintf(&b, "This is one step of the task %q in .cursor-iter/tasks.md:\n\n%s\n\n", task.Title, open[0])
+		b.WriteString("Do only this step and add a test for it if it changes code. Then check off that criterion in .cursor-iter/tasks.md. If it was the last unchecked criterion of the task, also move the task to \"## Completed Tasks\" in .cursor-iter/progress.md.")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "Task: %s\n\nRequirements:\n", task.Title)
+	for i, text := range open {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, text)
+	}
+	if len(task.Files) > 0 {
+		fmt.Fprintf(&b, "\nFiles: %s\n", strings.Join(task.Files, ", "))
+	}
+	b.WriteString("\n" + instructions)
+	return b.String()
+}
+
+// blockRefused gives up on a task the model declined with every prompt
+// variant, moving it to Blocked
+func blockRefused(progressFile, title string) {
+	if err := updateControlFile(progressFile, func(progress string) string {
+		return tasks.MarkTaskBlocked(progress, title, "the model declined every prompt variant")
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not block '%s': %v\n", ts(), title, err)
+		return
+	}
+	fmt.Printf("[%s] ⛔ The model declined every prompt variant, moved to Blocked: %s\n", ts(), title)
+	logTaskBlocked(title, "the model declined every prompt variant")
+	fmt.Printf("[%s] 💡 Reword the task or try another model, then retry it with 'cursor-iter triage'\n", ts())
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/promptlint"
+)
+
+// promptsDir holds the local copies of the prompt templates
+func promptsDir() string {
+	return getControlFilePath("prompts")
+}
+
+// lintPrompts lints the given template files, or every template in dir
+func lintPrompts(dir string, files []string) ([]promptlint.Issue, int, error) {
+	if len(files) == 0 {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, 0, fmt.Errorf("no prompt templates to lint: %w", err)
+		}
+		matches, err := filepath.Glob(filepath.Join(dir, "*.md"))
+		if err != nil {
+			return nil, 0, err
+		}
+		sort.Strings(matches)
+		files = matches
+	}
+	var issues []promptlint.Issue
+	for _, f := range files {
+		content, err := os.ReadFile(f)
+		if err != nil {
+			return nil, 0, err
+		}
+		issues = append(issues, promptlint.Lint(f, string(content), promptlint.Specs[filepath.Base(f)])...)
+	}
+	return issues, len(files), nil
+}
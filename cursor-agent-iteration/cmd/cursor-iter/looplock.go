@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// Holders of the control files lock
+const (
+	lockLoop    = "iterate-loop"
+	lockArchive = "archive-completed"
+)
+
+// archiveLockWait is how long iterate-loop and archive-completed wait for
+// an archive that holds the lock; archiving takes well under a second
+const archiveLockWait = 10 * time.Second
+
+// controlLock is the content of the control files lock: which command
+// rewrites tasks.md and progress.md, and its process
+type controlLock struct {
+	Command string    `json:"command"`
+	PID     int       `json:"pid"`
+	RunID   string    `json:"run_id,omitempty"`
+	Started time.Time `json:"started"`
+}
+
+func (l controlLock) String() string {
+	return fmt.Sprintf("%s (pid %d, since %s)", l.Command, l.PID, l.Started.Local().Format("15:04:05"))
+}
+
+// controlLockPath is held by the iterate-loop of the repository for as
+// long as it runs, and by archive-completed while it rewrites the files
+func controlLockPath() string {
+	return getControlFilePath("loop.lock")
+}
+
+// readControlLock returns the live holder of the control files lock, or
+// false when it isn't held or its process is gone
+func readControlLock() (controlLock, bool) {
+	data, err := os.ReadFile(controlLockPath())
+	if err != nil {
+		return controlLock{}, false
+	}
+	var l controlLock
+	if json.Unmarshal(data, &l) != nil || !processAlive(l.PID) {
+		return controlLock{}, false
+	}
+	return l, true
+}
+
+// activeLoop returns the iterate-loop of another process running in the
+// repository, if there is one
+func activeLoop() (controlLock, bool) {
+	l, ok := readControlLock()
+	return l, ok && l.Command == lockLoop && l.PID != os.Getpid()
+}
+
+// lockControlFiles takes the control files lock for command, waiting up to
+// archiveLockWait for an archive that holds it and breaking locks of
+// processes that are gone. When a loop holds it, holder is that loop and
+// the lock isn't taken.
+func lockControlFiles(command, runID string) (release func(), holder *controlLock, err error) {
+	path := controlLockPath()
+	if err := os.MkdirAll(CursorIterDir, 0755); err != nil {
+		return nil, nil, err
+	}
+	data, err := json.Marshal(controlLock{Command: command, PID: os.Getpid(), RunID: runID, Started: time.Now()})
+	if err != nil {
+		return nil, nil, err
+	}
+	deadline := time.Now().Add(archiveLockWait)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_, err = f.Write(data)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, nil, err
+			}
+			return func() { os.Remove(path) }, nil, nil
+		}
+		if !os.IsExist(err) {
+			return nil, nil, err
+		}
+		l, alive := readControlLock()
+		switch {
+		case !alive:
+			// Give a holder that has only just created the file time to
+			// write it before breaking the lock
+			if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) < time.Second {
+				break
+			}
+			os.Remove(path)
+			continue
+		case l.Command == lockLoop:
+			return nil, &l, nil
+		case time.Now().After(deadline):
+			return nil, nil, fmt.Errorf("%s holds %s", l, path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// processAlive reports whether a process of the pid exists
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		// FindProcess fails there for processes that are gone
+		return true
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
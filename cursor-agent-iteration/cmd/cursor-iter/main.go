@@ -2,28 +2,130 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"net/http"
+	"net/url"
 	"os"
+	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/atomicfile"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/audit"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/conflict"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/coord"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/deps"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/diff"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/features"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/glossary"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/jira"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/jsonlog"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/notes"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/notify"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/project"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/prompt"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/promptlint"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/reground"
 	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/runner"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/schema"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/scope"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/seal"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/snapshot"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/state"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/statusline"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/stream"
 	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/throttle"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/timeline"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/todos"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/toolenv"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/worktree"
 )
 
 // CursorIterDir is the directory where all cursor-iter files are stored
 const CursorIterDir = ".cursor-iter"
 
+// controlFileNames are the planning documents shared between cursor-iter and the agents
+var controlFileNames = []string{
+	"architecture.md",
+	"decisions.md",
+	"tasks.md",
+	"progress.md",
+	"test_plan.md",
+	"qa_checklist.md",
+	"CHANGELOG.md",
+	"context.md",
+	"glossary.md",
+}
+
 // TaskExecution represents a running task
 type TaskExecution struct {
 	TaskTitle string
 	StartTime time.Time
-	Done      chan error
+	// EndTime is when the agent exited; zero while it runs
+	EndTime time.Time
+	Done    chan error
+	// Output holds the most recent agent output for live viewers
+	Output *stream.RingBuffer
+	// Backend is the agent CLI working on the task
+	Backend runner.Backend
+	Model   string
+	// HeadBefore is the git HEAD when the run started, for rollback
+	HeadBefore string
+	// Labels are the task's labels, for per-label stats
+	Labels []string
+	// Blocker is what the agent said it needs from a human, if anything
+	Blocker string
+	// PromptVariant is the rung of the prompt ladder the run was sent
+	PromptVariant string
+	// PromptChars is the size of the prompt, for estimating the tokens of
+	// agents that don't report them
+	PromptChars int
+	// Env is the toolchain the agent runs under, taken at dispatch
+	Env *toolenv.Env
+	// Files is the task's declared file scope, for --strict-files
+	Files []string
+	// LogPath is the file the agent's output goes to instead of the
+	// terminal, or "" when it is shown on the terminal
+	LogPath string
+	// StopReason is why the run was stopped: skip or retry from the
+	// dashboard, or preempted by the coordinator; "" when it wasn't
+	StopReason string
+	// Worktree is the git worktree the agent works in under --worktree;
+	// zero when it works in the repository itself
+	Worktree worktree.Tree
+	// cancel stops the agent; nil when the runner has no context
+	cancel context.CancelFunc
+}
+
+// Duration is how long the agent ran, or has been running so far
+func (e *TaskExecution) Duration() time.Duration {
+	if e.EndTime.IsZero() {
+		return time.Since(e.StartTime)
+	}
+	return e.EndTime.Sub(e.StartTime)
+}
+
+// BackendStats counts task runs per agent backend
+type BackendStats struct {
+	Attempts  int
+	Successes int
+	Failures  int
 }
 
 // TaskRunner manages parallel task execution
@@ -31,139 +133,499 @@ type TaskRunner struct {
 	running   map[string]*TaskExecution
 	mutex     sync.Mutex
 	maxActive int
+
+	// fallbacks are tried in order once a task has failed fallbackAfter
+	// times on the current backend
+	fallbacks     []runner.Backend
+	fallbackAfter int
+	failures      map[string]int
+	lastBackend   map[string]runner.Backend
+	stats         map[runner.Backend]*BackendStats
+
+	// finished keeps the last completed execution of each task so its
+	// outcome can be journaled after it leaves the running set
+	finished map[string]*TaskExecution
+
+	// promptNotes are appended to every task prompt
+	promptNotes     []string
+	showFullPrompts bool
+	// followUps are one-off notes for the next dispatch of a task
+	followUps       map[string][]string
+	updateTaskPaths bool
+	// contextBudget is the token budget for the control files, above which
+	// agents are pointed at condensed copies
+	contextBudget int
+	// coordinator enforces the agent budget shared with loops in other
+	// repositories; nil when there is none
+	coordinator *coord.Coordinator
+	// author is the git identity agents commit as; nil keeps git's own
+	author *agentAuthor
+	// ladder simplifies the prompt of tasks the model keeps declining
+	ladder promptLadder
+	// kind selects the prompt instructions for the project type
+	kind project.Type
+	// taskTimeout kills agents that run longer; 0 means no limit
+	taskTimeout time.Duration
+	// ctx stops the running agents when it is done; nil when nothing does
+	ctx context.Context
+	// taskLogs sends each agent's output to its own log file, leaving the
+	// terminal to one status line per task
+	taskLogs bool
+
+	// exclusive is the running [exclusive:true] task; no other task may
+	// start until it finishes
+	exclusive string
+	// fileClaims keeps tasks whose Files to Modify overlap from running at
+	// the same time
+	fileClaims bool
+	// worktrees gives every task a git worktree of its own; nil runs agents
+	// in the repository itself
+	worktrees *worktrees
+	// throttle lowers maxActive and raises the stagger while cursor-agent
+	// startups race on cli-config.json; nil keeps them as configured
+	throttle *throttle.Throttle
 }
 
 // NewTaskRunner creates a new TaskRunner
 func NewTaskRunner(maxActive int) *TaskRunner {
 	return &TaskRunner{
-		running:   make(map[string]*TaskExecution),
-		maxActive: maxActive,
+		running:     make(map[string]*TaskExecution),
+		maxActive:   maxActive,
+		failures:    make(map[string]int),
+		lastBackend: make(map[string]runner.Backend),
+		stats:       make(map[runner.Backend]*BackendStats),
+		finished:    make(map[string]*TaskExecution),
+		followUps:   make(map[string][]string),
 	}
 }
 
-// ActiveCount returns the number of currently running tasks
-func (tr *TaskRunner) ActiveCount() int {
+// SetFallbackChain configures the backends to fall back to, in order, after a
+// task has failed `after` times on the backend before it
+func (tr *TaskRunner) SetFallbackChain(fallbacks []runner.Backend, after int) {
 	tr.mutex.Lock()
 	defer tr.mutex.Unlock()
-	return len(tr.running)
+	if after < 1 {
+		after = 1
+	}
+	tr.fallbacks = fallbacks
+	tr.fallbackAfter = after
 }
 
-// StartTask starts a new task execution in a goroutine
-func (tr *TaskRunner) StartTask(taskTitle string, taskDetails string, useCodex bool, model string, debug bool) error {
+// SetPromptNotes sets extra notes appended to every task prompt
+func (tr *TaskRunner) SetPromptNotes(notes ...string) {
 	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	tr.promptNotes = notes
+}
 
-	// Check if task is already running
-	if _, exists := tr.running[taskTitle]; exists {
-		tr.mutex.Unlock()
-		return fmt.Errorf("task '%s' is already running", taskTitle)
+// AddFollowUp queues a note for the next dispatch of a task only
+func (tr *TaskRunner) AddFollowUp(taskTitle string, note string) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	tr.followUps[taskTitle] = append(tr.followUps[taskTitle], note)
+}
+
+// SetShowFullPrompts controls whether debug logs print each task prompt in
+// full instead of its hash and saved path
+func (tr *TaskRunner) SetShowFullPrompts(show bool) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	tr.showFullPrompts = show
+}
+
+// SetUpdateTaskPaths controls whether renamed files are corrected in a task's
+// "Files to Modify" line in tasks.md before it is dispatched
+func (tr *TaskRunner) SetUpdateTaskPaths(update bool) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	tr.updateTaskPaths = update
+}
+
+// SetFileClaims controls whether a task waits while its files overlap those
+// of a running task
+func (tr *TaskRunner) SetFileClaims(on bool) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	tr.fileClaims = on
+}
+
+// Claims returns the files the running tasks claim, or nil when file claims
+// are off
+func (tr *TaskRunner) Claims() tasks.Claims {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	return tr.claims()
+}
+
+// claims must be called with the mutex held
+func (tr *TaskRunner) claims() tasks.Claims {
+	if !tr.fileClaims {
+		return nil
+	}
+	claims := make(tasks.Claims, len(tr.running))
+	for title, exec := range tr.running {
+		claims[title] = exec.Files
 	}
+	return claims
+}
 
-	// Check if we've hit the max concurrent tasks
-	if len(tr.running) >= tr.maxActive {
-		tr.mutex.Unlock()
-		return fmt.Errorf("max concurrent tasks (%d) reached", tr.maxActive)
+// SetWorktrees runs every task in a git worktree of its own
+func (tr *TaskRunner) SetWorktrees(w *worktrees) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	tr.worktrees = w
+}
+
+// SetTaskLogs controls whether agent output goes to a log file per task
+// instead of the terminal
+func (tr *TaskRunner) SetTaskLogs(on bool) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	tr.taskLogs = on
+}
+
+// SetContextBudget sets the token budget for the control files; agents read
+// condensed copies when the full files exceed it. 0 means no budget.
+func (tr *TaskRunner) SetContextBudget(tokens int) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	tr.contextBudget = tokens
+}
+
+// SetCoordinator makes every dispatch take a slot of the global agent
+// budget, and lets the coordinator stop agents to hand their slots to
+// higher-priority repositories
+func (tr *TaskRunner) SetCoordinator(c *coord.Coordinator) {
+	tr.mutex.Lock()
+	tr.coordinator = c
+	tr.mutex.Unlock()
+	c.SetPreempt(tr.preempt)
+}
+
+// preempt stops the n most recently started agents that can be stopped,
+// losing the least work, and returns how many it stopped. Their tasks stay
+// in progress and start again once the coordinator admits them.
+func (tr *TaskRunner) preempt(n int) int {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	runs := make([]*TaskExecution, 0, len(tr.running))
+	for _, run := range tr.running {
+		if run.cancel != nil && run.StopReason == "" {
+			runs = append(runs, run)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].StartTime.After(runs[j].StartTime) })
+	if len(runs) > n {
+		runs = runs[:n]
+	}
+	for _, run := range runs {
+		run.StopReason = stopPreempted
+		run.cancel()
 	}
+	return len(runs)
+}
 
-	// Create execution tracker
-	exec := &TaskExecution{
-		TaskTitle: taskTitle,
-		StartTime: time.Now(),
-		Done:      make(chan error, 1),
+// SetAgentAuthor makes agents commit as the given identity
+func (tr *TaskRunner) SetAgentAuthor(a *agentAuthor) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	tr.author = a
+}
+
+// SetPromptLadder sets the prompt variants to step through while the model
+// declines a task
+func (tr *TaskRunner) SetPromptLadder(l promptLadder) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	tr.ladder = l
+}
+
+// SetProjectType sets the project type whose instructions go in prompts
+func (tr *TaskRunner) SetProjectType(kind project.Type) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	tr.kind = kind
+}
+
+// SetTaskTimeout sets how long an agent may run before it is killed and its
+// task retried. 0 means no limit.
+func (tr *TaskRunner) SetTaskTimeout(d time.Duration) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	tr.taskTimeout = d
+}
+
+// SetContext makes the running agents stop when ctx is done
+func (tr *TaskRunner) SetContext(ctx context.Context) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	tr.ctx = ctx
+}
+
+// SetThrottle makes the runner start fewer tasks, further apart, while agent
+// startups race on cli-config.json; nil turns that off
+func (tr *TaskRunner) SetThrottle(t *throttle.Throttle) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	tr.throttle = t
+}
+
+// Capacity is how many tasks may run at once: maxActive, or fewer while the
+// throttle holds the runner back. Once the races have stopped for a while
+// it gives a task back.
+func (tr *TaskRunner) Capacity() int {
+	tr.mutex.Lock()
+	t := tr.throttle
+	tr.mutex.Unlock()
+	if t == nil {
+		return tr.maxActive
 	}
-	tr.running[taskTitle] = exec
+	if t.Recover(time.Now()) {
+		fmt.Printf("[%s] 🐇 No cli-config.json races for %v, back up to %d agents, %v apart\n", ts(), throttle.Cooldown, min(t.Limit(), tr.maxActive), t.Stagger())
+	}
+	return min(t.Limit(), tr.maxActive)
+}
+
+// Stagger is the delay between task starts: stagger, or longer while the
+// throttle holds the runner back
+func (tr *TaskRunner) Stagger(stagger time.Duration) time.Duration {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	if tr.throttle == nil {
+		return stagger
+	}
+	return tr.throttle.Stagger()
+}
+
+// retryHook returns the runner.Options hook of a task's agent retries,
+// which feeds cli-config.json races to the throttle
+func (tr *TaskRunner) retryHook(title string) func(runner.Backend, int, error) {
+	tr.mutex.Lock()
+	t := tr.throttle
 	tr.mutex.Unlock()
+	logRetry := logRetries(title)
+	return func(backend runner.Backend, attempt int, err error) {
+		logRetry(backend, attempt, err)
+		if t != nil && errors.Is(err, runner.ErrConfigRace) && t.Race(time.Now()) {
+			fmt.Printf("[%s] 🐢 Agent startups keep racing on cli-config.json (%d in %v), running at most %d agents, %v apart\n",
+				ts(), throttle.Threshold, throttle.Window, min(t.Limit(), tr.maxActive), t.Stagger())
+		}
+	}
+}
 
-	// Log task start
-	fmt.Printf("[%s] 🚀 Starting cursor-agent for task: '%s' (active: %d/%d)\n",
-		ts(), taskTitle, tr.ActiveCount(), tr.maxActive)
+// backendFor picks the backend for the next attempt of a task based on how
+// often it has failed so far. Must be called with the mutex held.
+func (tr *TaskRunner) backendFor(taskTitle string, primary runner.Backend) runner.Backend {
+	chain := chainFrom(primary, tr.fallbacks)
+	if len(chain) == 1 {
+		return primary
+	}
+	idx := tr.failures[taskTitle] / tr.fallbackAfter
+	if idx >= len(chain) {
+		idx = len(chain) - 1
+	}
+	return chain[idx]
+}
 
-	// Build prompt
-	msg := fmt.Sprintf(`You are working on a specific task from the engineering iteration system.
+// RecordOutcome records whether the last run of a task succeeded (the agent
+// exited cleanly and the task was verified complete). Failures move the task
+// along the fallback chain.
+func (tr *TaskRunner) RecordOutcome(taskTitle string, succeeded bool) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
 
-## Your Task
+	backend, ok := tr.lastBackend[taskTitle]
+	if !ok {
+		return
+	}
+	st := tr.statsFor(backend)
+	if succeeded {
+		st.Successes++
+		delete(tr.failures, taskTitle)
+		return
+	}
+	st.Failures++
+	tr.failures[taskTitle]++
+}
 
-%s
+// BackendStats returns a copy of the per-backend run counters
+func (tr *TaskRunner) BackendStats() map[runner.Backend]BackendStats {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	out := make(map[runner.Backend]BackendStats, len(tr.stats))
+	for b, st := range tr.stats {
+		out[b] = *st
+	}
+	return out
+}
 
-## Instructions
+func (tr *TaskRunner) statsFor(backend runner.Backend) *BackendStats {
+	st, ok := tr.stats[backend]
+	if !ok {
+		st = &BackendStats{}
+		tr.stats[backend] = st
+	}
+	return st
+}
 
-1. Review the control files for context (located in .cursor-iter/):
-   - .cursor-iter/architecture.md: System architecture and design
-   - .cursor-iter/decisions.md: Architectural Decision Records (ADRs)
-   - .cursor-iter/progress.md: Completed tasks and progress history
-   - .cursor-iter/test_plan.md: Testing strategy and coverage
-   - .cursor-iter/qa_checklist.md: Quality assurance requirements
-   - .cursor-iter/CHANGELOG.md: Change history
-   - .cursor-iter/context.md: Project context (if available)
-
-2. Implement the task following these steps:
-   - Plan your implementation approach
-   - Write the code with comprehensive logging and comments
-   - Create/update tests to verify functionality
-   - Run quality gates (linting, formatting, type checking, tests)
-   - Update documentation as needed
-   - Commit changes with conventional commit messages
-
-3. Track progress:
-   - Check off each acceptance criterion in .cursor-iter/tasks.md as you complete it
-   - When ALL criteria are checked, move the task from "## In Progress" to "## Completed Tasks" in .cursor-iter/progress.md
-   - Use format: "- ✅ [YYYY-MM-DD HH:MM] Task Title - completion notes"
-
-4. Quality Requirements:
-   - All tests must pass
-   - Code must pass linting and formatting checks
-   - Follow existing code patterns and conventions
-   - Add detailed code comments explaining complex logic
-   - Include logging for debugging and monitoring
+// ActiveCount returns the number of currently running tasks
+func (tr *TaskRunner) ActiveCount() int {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	return len(tr.running)
+}
 
-5. 🚨 CRITICAL: NEVER RUN LONG-RUNNING PROCESSES 🚨
-   STRICTLY FORBIDDEN COMMANDS - These will hang the agent:
-   - ❌ npm run dev / pnpm run dev / yarn dev - Dev servers
-   - ❌ npm start / pnpm start / yarn start - Application servers
-   - ❌ python manage.py runserver - Django dev server
-   - ❌ flask run / uvicorn / gunicorn - Python web servers
-   - ❌ go run (unless it completes immediately) - Go applications that don't exit
-   - ❌ cargo run (unless it completes immediately) - Rust applications that don't exit
-   - ❌ rails server / rails s - Rails dev server
-   - ❌ Any command that starts a server, daemon, or continuous process
+// runPrompt runs the agent of a task dispatch; stress swaps in simulated
+// agents
+var runPrompt = runner.RunPrompt
 
-   ALLOWED: Build commands that complete and exit
-   - ✅ npm run build / pnpm build / yarn build - Build commands that exit
-   - ✅ go build - Compilation that exits
-   - ✅ cargo build - Compilation that exits
-   - ✅ Any test command that runs and completes
+// StartTask starts a new task execution in a goroutine
+func (tr *TaskRunner) StartTask(taskTitle string, taskDetails string, primary runner.Backend, model string, debug bool) error {
+	head := gitHead()
+	exclusive := tasks.IsExclusive(configLabelDefaults().ParseLabels(taskDetails))
+	files := tasks.ParseFileScope(taskDetails)
+	tr.mutex.Lock()
+	err := tr.startable(taskTitle, exclusive, files)
+	coordinator := tr.coordinator
+	tr.mutex.Unlock()
+	if err != nil {
+		return err
+	}
 
-   If a dev server is needed for testing:
-   - Document it in the README with manual start instructions
-   - Never run it in the agent - the human developer will run it manually
-   - Use build commands and unit tests instead
+	// Take a slot of the agent budget shared with other repositories. The
+	// coordinator waits on the other loops, so this can't hold the mutex,
+	// and the checks are repeated once it is held again.
+	if err := coordinator.Acquire(); err != nil {
+		return err
+	}
+	tr.mutex.Lock()
+	if err := tr.startable(taskTitle, exclusive, files); err != nil {
+		tr.mutex.Unlock()
+		coordinator.Release()
+		return err
+	}
+	if exclusive {
+		tr.exclusive = taskTitle
+	}
 
-## Important Notes
+	// Pick the backend, moving down the fallback chain after repeated failures
+	backend := tr.backendFor(taskTitle, primary)
+	if backend != primary {
+		// The requested model belongs to the primary backend
+		model = "auto"
+	} else if m := taskModel(taskDetails); m != "" {
+		model = m
+	}
 
-- Focus ONLY on this specific task
-- .cursor-iter/tasks.md is a simple task list (no status emojis) - only check off acceptance criteria
-- .cursor-iter/progress.md tracks task status (in-progress and completed)
-- When all acceptance criteria are checked, move this task from "## In Progress" to "## Completed Tasks" in .cursor-iter/progress.md
-- Ensure all quality gates pass before marking complete
-- NEVER run dev servers or long-running processes - they will hang the agent
+	// Create execution tracker
+	exec := &TaskExecution{
+		TaskTitle:  taskTitle,
+		StartTime:  time.Now(),
+		Done:       make(chan error, 1),
+		Output:     stream.NewRingBuffer(stream.DefaultCapacity),
+		Backend:    backend,
+		Model:      model,
+		HeadBefore: head,
+		Labels:     configLabelDefaults().ParseLabels(taskDetails),
+		Files:      files,
+	}
+	tr.running[taskTitle] = exec
+	tr.lastBackend[taskTitle] = backend
+	tr.statsFor(backend).Attempts++
+	notes := append(append([]string{}, tr.promptNotes...), tr.followUps[taskTitle]...)
+	delete(tr.followUps, taskTitle)
+	showFull := tr.showFullPrompts
+	updatePaths := tr.updateTaskPaths
+	budget := tr.contextBudget
+	author := tr.author
+	ladder := tr.ladder
+	kind := tr.kind
+	timeout := tr.taskTimeout
+	ctx := tr.ctx
+	if ctx != nil {
+		ctx, exec.cancel = context.WithCancel(ctx)
+	}
+	taskLogs := tr.taskLogs
+	trees := tr.worktrees
+	tr.mutex.Unlock()
 
-Work on this task until all acceptance criteria are checked off and the task is moved to completed in .cursor-iter/progress.md.`, taskDetails)
+	// Log task start
+	if backend != primary {
+		fmt.Printf("[%s] 🔀 Falling back to %s for task: '%s'\n", ts(), backend, taskTitle)
+	}
+	fmt.Printf("[%s] 🚀 Starting %s for task: '%s' (active: %d/%d)\n",
+		ts(), backend, taskTitle, tr.ActiveCount(), tr.maxActive)
+
+	// Build prompt, pointing the agent at files that moved since the task was
+	// written, and simplified if the model declined earlier runs
+	notes = append(notes, regroundTask(taskTitle, taskDetails, updatePaths, debug), contextBudgetNote(budget), reviewFollowUp(taskTitle), checksFollowUp(taskTitle), sealedFilesNote(), trees.PromptNote(taskTitle, resolveTasksFile(), resolveProgressFile()))
+	sealed := sealedControlFiles()
+	exec.PromptVariant = ladder.Variant(taskTitle)
+	if exec.PromptVariant != promptFull {
+		fmt.Printf("[%s] 🪜 The model declined earlier runs, sending the %s prompt for task: '%s'\n", ts(), exec.PromptVariant, taskTitle)
+	}
+	msg := variantPrompt(kind, exec.PromptVariant, taskDetails, glossaryFor(taskDetails), notes...)
+	exec.PromptChars = len(msg)
+	logPrompt(msg, debug, showFull)
+
+	// With task logs the output goes to a file of its own, so parallel
+	// agents don't interleave on the terminal
+	var logFile *os.File
+	if taskLogs {
+		path := taskLogPath(taskTitle, exec.StartTime)
+		f, err := createLog(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: showing the output of '%s' here, could not create its log: %v\n", ts(), taskTitle, err)
+		} else {
+			logFile = f
+			exec.LogPath = path
+			fmt.Printf("[%s] 📄 Output of '%s' goes to %s\n", ts(), taskTitle, path)
+		}
+	}
+	logTaskStarted(exec, backend != primary, tr.ActiveCount())
 
-	// Start cursor-agent in goroutine
+	// Start cursor-agent in goroutine, teeing its output into the task's
+	// ring buffer so it can be watched live while the run is in flight
 	go func() {
+		exec.Env = captureEnv(taskTitle)
+		opts, flush := agentOptions(debug)
+		if logFile != nil {
+			opts.Stdout, opts.Stderr = logFile, logFile
+			defer logFile.Close()
+		}
+		opts.Stdout = io.MultiWriter(opts.Stdout, exec.Output)
+		opts.Stderr = io.MultiWriter(opts.Stderr, exec.Output)
+		opts.Env = author.Env(taskTitle)
+		opts.Timeout = timeout
+		opts.Context = ctx
+		opts.OnRetry = tr.retryHook(taskTitle)
 		var err error
-		if useCodex {
-			err = runner.CodexWithDebug(debug, model, msg)
-		} else {
-			err = runner.CursorAgentWithDebug(debug, "--print", "--force", msg)
+		if trees != nil {
+			exec.Worktree, err = trees.Open(taskTitle)
+			opts.Dir = exec.Worktree.Path
+		}
+		if err == nil {
+			err = runPrompt(opts, backend, model, msg)
+		}
+		if exec.cancel != nil {
+			exec.cancel()
+		}
+		flush()
+		resealControlFiles(sealed)
+		exec.Output.Close()
+		exec.EndTime = time.Now()
+
+		duration := exec.Duration()
+		logNote := ""
+		if exec.LogPath != "" {
+			logNote = ", log: " + exec.LogPath
 		}
-
-		duration := time.Since(exec.StartTime)
 		if err != nil {
-			fmt.Printf("[%s] ❌ cursor-agent failed for task '%s' (duration: %v): %v\n",
-				ts(), taskTitle, duration, err)
+			fmt.Printf("[%s] ❌ %s failed for task '%s' (duration: %v%s): %v\n",
+				ts(), backend, taskTitle, duration, logNote, err)
 		} else {
-			fmt.Printf("[%s] ✅ cursor-agent completed for task '%s' (duration: %v)\n",
-				ts(), taskTitle, duration)
+			fmt.Printf("[%s] ✅ %s completed for task '%s' (duration: %v%s)\n",
+				ts(), backend, taskTitle, duration, logNote)
 		}
 
 		exec.Done <- err
@@ -172,6 +634,39 @@ Work on this task until all acceptance criteria are checked off and the task is
 	return nil
 }
 
+// startable returns why a task can't start now, or nil when it can. Must be
+// called with the mutex held.
+func (tr *TaskRunner) startable(taskTitle string, exclusive bool, files []string) error {
+	// Check if task is already running
+	if _, exists := tr.running[taskTitle]; exists {
+		return fmt.Errorf("task '%s' is already running", taskTitle)
+	}
+
+	// Check if we've hit the max concurrent tasks
+	limit := tr.maxActive
+	if tr.throttle != nil {
+		limit = min(limit, tr.throttle.Limit())
+	}
+	if len(tr.running) >= limit {
+		return fmt.Errorf("max concurrent tasks (%d) reached", limit)
+	}
+
+	// Exclusive tasks run alone: they wait for running tasks to drain and
+	// block every other dispatch until they finish
+	if tr.exclusive != "" && tr.exclusive != taskTitle {
+		return fmt.Errorf("exclusive task '%s' must finish first", tr.exclusive)
+	}
+	if exclusive && len(tr.running) > 0 {
+		return fmt.Errorf("exclusive task waiting for %d running task(s) to drain", len(tr.running))
+	}
+
+	// Tasks changing the same files would conflict when merged
+	if other := tr.claims().Conflict(taskTitle, files); other != "" {
+		return fmt.Errorf("its files overlap those of running task '%s'", other)
+	}
+	return nil
+}
+
 // WaitForTask waits for a specific task to complete
 func (tr *TaskRunner) WaitForTask(taskTitle string) error {
 	tr.mutex.Lock()
@@ -187,52 +682,92 @@ func (tr *TaskRunner) WaitForTask(taskTitle string) error {
 
 	// Remove from running map
 	tr.mutex.Lock()
-	delete(tr.running, taskTitle)
+	tr.finish(taskTitle)
 	tr.mutex.Unlock()
 
 	return err
 }
 
-// WaitForAny waits for any task to complete and returns its title
+// WaitForAny waits for the first running task to complete and returns its
+// title
 func (tr *TaskRunner) WaitForAny() (string, error) {
-	// Create a select case for each running task
 	tr.mutex.Lock()
 	if len(tr.running) == 0 {
 		tr.mutex.Unlock()
 		return "", fmt.Errorf("no tasks running")
 	}
-
-	// Copy running tasks to avoid holding lock
-	runningCopy := make(map[string]*TaskExecution)
-	for k, v := range tr.running {
-		runningCopy[k] = v
+	titles := make([]string, 0, len(tr.running))
+	cases := make([]reflect.SelectCase, 0, len(tr.running))
+	for title, exec := range tr.running {
+		titles = append(titles, title)
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(exec.Done)})
 	}
 	tr.mutex.Unlock()
 
-	// Wait for first completion using reflection to handle dynamic cases
-	for title, exec := range runningCopy {
-		select {
-		case err := <-exec.Done:
-			// Remove from running map
-			tr.mutex.Lock()
-			delete(tr.running, title)
-			tr.mutex.Unlock()
-			return title, err
-		default:
-			// Continue checking other tasks
-		}
+	// The number of tasks changes at runtime, so select over all of their
+	// Done channels at once
+	chosen, value, _ := reflect.Select(cases)
+	err, _ := value.Interface().(error)
+	title := titles[chosen]
+	tr.mutex.Lock()
+	tr.finish(title)
+	tr.mutex.Unlock()
+	return title, err
+}
+
+// finish removes a completed task from the running set, lifting the dispatch
+// block if it was the exclusive task. Must be called with the mutex held.
+func (tr *TaskRunner) finish(taskTitle string) {
+	if exec, ok := tr.running[taskTitle]; ok {
+		tr.finished[taskTitle] = exec
+		tr.coordinator.Release()
+	}
+	delete(tr.running, taskTitle)
+	if tr.exclusive == taskTitle {
+		tr.exclusive = ""
 	}
+}
 
-	// If no task is done yet, wait for the first one
-	for title, exec := range runningCopy {
-		err := <-exec.Done
-		tr.mutex.Lock()
-		delete(tr.running, title)
-		tr.mutex.Unlock()
-		return title, err
+// LastRun returns the most recently finished execution of a task, or nil
+func (tr *TaskRunner) LastRun(taskTitle string) *TaskExecution {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	return tr.finished[taskTitle]
+}
+
+// RunningExclusive returns the exclusive task that is running, or ""
+func (tr *TaskRunner) RunningExclusive() string {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	return tr.exclusive
+}
+
+// Output returns the live output buffer of a running task, or nil if the
+// task is not running
+func (tr *TaskRunner) Output(taskTitle string) *stream.RingBuffer {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	if exec, ok := tr.running[taskTitle]; ok {
+		return exec.Output
 	}
+	return nil
+}
 
-	return "", fmt.Errorf("no tasks completed")
+// StopTask stops the agent of a running task, recording the reason on the
+// run for the loop to act on once the run has ended
+func (tr *TaskRunner) StopTask(taskTitle string, reason string) error {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	exec, ok := tr.running[taskTitle]
+	if !ok {
+		return fmt.Errorf("task '%s' is not running", taskTitle)
+	}
+	if exec.cancel == nil {
+		return fmt.Errorf("task '%s' can't be stopped", taskTitle)
+	}
+	exec.StopReason = reason
+	exec.cancel()
+	return nil
 }
 
 // GetRunningTasks returns a list of currently running task titles
@@ -247,45 +782,391 @@ func (tr *TaskRunner) GetRunningTasks() []string {
 	return titles
 }
 
-func usage() {
-	fmt.Println("cursor-iter - task utilities")
-	fmt.Println("")
-	fmt.Println("All control files are stored in the .cursor-iter/ directory")
-	fmt.Println("")
-	fmt.Println("Usage:")
-	fmt.Println("  cursor-iter task-status   [--file .cursor-iter/tasks.md] [--progress .cursor-iter/progress.md]")
-	fmt.Println("  cursor-iter archive-completed [--file .cursor-iter/tasks.md] [--progress .cursor-iter/progress.md]")
-	fmt.Println("  cursor-iter iterate-init   [--model auto] [--codex]  # uses .cursor-iter/prompts/initialize-iteration-universal.md")
-	fmt.Println("  cursor-iter iterate        [--max-in-progress 10]    # runs iteration using .cursor-iter/prompts/iterate.md")
-	fmt.Println("  cursor-iter iterate-loop   [--codex] [--max-in-progress 10]  # loops until completion")
-	fmt.Println("  cursor-iter add-feature                  # uses .cursor-iter/prompts/add-feature.md (DESIGN ONLY)")
-	fmt.Println("  cursor-iter add-feature --file <path>    # read feature description from file")
-	fmt.Println("  cursor-iter add-feature --prompt \"desc\"  # provide feature description as argument")
-	fmt.Println("  cursor-iter add-feature [--codex]        # use codex instead of cursor-agent")
-	fmt.Println("  cursor-iter run-agent --prompt \"request\" # send ad-hoc request to cursor-agent/codex")
-	fmt.Println("  cursor-iter run-agent [--codex]          # use codex instead of cursor-agent")
-	fmt.Println("  cursor-iter validate-tasks [--fix]       # validate/fix tasks.md structure")
-	fmt.Println("  cursor-iter reset                       # remove .cursor-iter/ directory and all control files")
-	fmt.Println("")
-	fmt.Println("Options:")
-	fmt.Println("  --codex              Use codex CLI with gpt-5-codex model instead of cursor-agent")
-	fmt.Println("  --model              Specify model for cursor-agent (auto, gpt-4o, etc.) or codex (gpt-5-codex)")
-	fmt.Println("  --max-in-progress N  Maximum number of in-progress tasks allowed (default: 10)")
-	fmt.Println("")
-	fmt.Println("Task Workflow:")
-	fmt.Println("  .cursor-iter/tasks.md     - Master task list (add-feature adds tasks here)")
-	fmt.Println("  .cursor-iter/progress.md  - Completion log (iterate-loop updates when tasks complete)")
-	fmt.Println("  NOTE: This separation prevents write conflicts when adding features during iterate-loop")
-	fmt.Println("")
-	fmt.Println("Task Continuation:")
-	fmt.Println("  iterate-loop now continues working on in-progress tasks until completion")
-	fmt.Println("  If a task doesn't complete in one iteration, it will retry on the next iteration")
-	fmt.Println("  Use --max-in-progress to limit concurrent task processing")
+// runningExecutions returns the executions of the running tasks
+func (tr *TaskRunner) runningExecutions() []*TaskExecution {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	runs := make([]*TaskExecution, 0, len(tr.running))
+	for _, run := range tr.running {
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+// buildTaskPrompt builds the prompt sent to the agent for a single task
+// from the task template, with the instructions of the project type. Extra
+// notes are appended to the "Important Notes" section. A local template that
+// fails to render gives way to the built-in one.
+func buildTaskPrompt(kind project.Type, taskDetails string, notes ...string) string {
+	in := kind.Instructions()
+	data := prompt.Task{Type: string(kind), Details: taskDetails, Implement: in.Implement, Quality: in.Quality, Gates: in.Gates, Notes: notes}
+	msg, err := loadPromptTemplates().Task(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: using the built-in task prompt: %v\n", ts(), err)
+		msg, _ = prompt.Builtin().Task(data)
+	}
+	return msg
+}
+
+// journalPath is the JSONL log of every task run, used by triage and stats
+func journalPath() string {
+	return getControlFilePath(filepath.Join("logs", "journal.jsonl"))
+}
+
+// triageLogPath is the JSONL log of triage decisions
+func triageLogPath() string {
+	return getControlFilePath(filepath.Join("logs", "triage.jsonl"))
+}
+
+// taskSlug turns a task title into a file name
+func taskSlug(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	slug := strings.TrimSuffix(b.String(), "-")
+	if len(slug) > 60 {
+		slug = strings.TrimSuffix(slug[:60], "-")
+	}
+	if slug == "" {
+		slug = "task"
+	}
+	return slug
+}
+
+// Task log modes for iterate-loop's --task-logs
+const (
+	taskLogsAuto = "auto" // log files when more than one task may run at once
+	taskLogsOn   = "on"
+	taskLogsOff  = "off"
+)
+
+// mustTaskLogs reports whether agent output goes to log files under the
+// --task-logs mode, exiting on invalid values
+func mustTaskLogs(mode string, maxInProgress int) bool {
+	switch mode {
+	case taskLogsAuto:
+		return maxInProgress > 1
+	case taskLogsOn:
+		return true
+	case taskLogsOff:
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "invalid --task-logs %q: must be %s, %s or %s\n", mode, taskLogsAuto, taskLogsOn, taskLogsOff)
+	os.Exit(1)
+	return false
+}
+
+// taskLogPath is the log file of a task run started at start
+func taskLogPath(title string, start time.Time) string {
+	return getControlFilePath(filepath.Join("logs", taskSlug(title)+"-"+start.Format("20060102-150405")+".log"))
+}
+
+// createLog creates a log file and its directory
+func createLog(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(path)
+}
+
+// gitHead returns the current commit, or "" outside a git repository
+func gitHead() string {
+	out, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// taskModel returns the model requested by a task's [model:<name>] label
+func taskModel(taskDetails string) string {
+	m, _ := tasks.LabelValue(configLabelDefaults().ParseLabels(taskDetails), "model")
+	return m
+}
+
+// recordRun appends a finished task run to the journal, saving the agent's
+// retained output next to it so failures can be triaged later, and returns
+// the entry
+func recordRun(runID string, run *TaskExecution, outcome string, runErr error) journal.Entry {
+	if run == nil {
+		return journal.Entry{}
+	}
+	entry := journal.Entry{
+		Time:          time.Now(),
+		Started:       run.StartTime,
+		RunID:         runID,
+		Task:          run.TaskTitle,
+		Backend:       string(run.Backend),
+		Model:         run.Model,
+		Labels:        run.Labels,
+		Outcome:       outcome,
+		DurationMs:    run.Duration().Milliseconds(),
+		HeadBefore:    run.HeadBefore,
+		HeadAfter:     gitHead(),
+		PromptVariant: run.PromptVariant,
+		Env:           run.Env,
+	}
+	if runErr != nil {
+		entry.Error = runErr.Error()
+	}
+	var output []byte
+	if run.Output != nil {
+		output = run.Output.Snapshot()
+	}
+	entry.Classification = journal.Classify(outcome, entry.Error, string(output))
+	if run.Blocker != "" {
+		entry.Classification = journal.ClassNeedsHuman
+		entry.Blocker = run.Blocker
+	}
+	entry.Tokens = journal.ParseTokens(string(output))
+	entry.CostUSD = journal.ParseCost(string(output))
+	runLines(&entry)
+	if entry.Tokens == 0 && run.Output != nil {
+		entry.Tokens = journal.EstimateTokens(int64(run.PromptChars), run.Output.Total())
+		entry.TokensEstimated = entry.Tokens > 0
+	}
+	if run.LogPath != "" {
+		// The task log has the complete output
+		entry.LogPath = run.LogPath
+	} else if len(output) > 0 {
+		logPath := filepath.Join(getControlFilePath(filepath.Join("logs", "runs", runID)), taskSlug(run.TaskTitle)+".log")
+		if err := os.MkdirAll(filepath.Dir(logPath), 0755); err == nil && os.WriteFile(logPath, output, 0644) == nil {
+			entry.LogPath = logPath
+		}
+	}
+	if err := journal.Append(journalPath(), entry); err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not write run journal: %v\n", ts(), err)
+	}
+	logRunOutcome(entry)
+	return entry
+}
+
+// waitingExclusiveTask returns the first in-progress [exclusive:true] task
+// that is not running yet, or nil
+func waitingExclusiveTask(taskContent string, inProgress []*tasks.Task, running []string) *tasks.Task {
+	for _, task := range inProgress {
+		isRunning := false
+		for _, title := range running {
+			if title == task.Title {
+				isRunning = true
+				break
+			}
+		}
+		if !isRunning && tasks.IsExclusive(configLabelDefaults().ParseLabels(tasks.ExtractTaskDetails(taskContent, task.Title))) {
+			return task
+		}
+	}
+	return nil
+}
+
+// glossaryFor returns the entries of .cursor-iter/glossary.md whose terms occur
+// in the task as a prompt section, or "" if there is no glossary or no match
+func glossaryFor(taskDetails string) string {
+	data, err := readControlFile("glossary.md")
+	if err != nil {
+		return ""
+	}
+	return glossary.Section(glossary.Match(glossary.Parse(string(data)), taskDetails))
+}
+
+// regroundTask checks that the files a task lists still exist before it is
+// dispatched, following renames through git history. Tasks whose files have
+// all been deleted are flagged for review. The returned prompt note tells the
+// agent where the files went, or is "" when the file list is current.
+func regroundTask(taskTitle, taskDetails string, updatePaths, debug bool) string {
+	files := tasks.ParseFiles(taskDetails)
+	if len(files) == 0 {
+		return ""
+	}
+	report := reground.Check(".", files, reground.GitHistory("."))
+	if debug {
+		fmt.Printf("[%s] 🧭 Checked %d listed file(s) for task '%s'\n", ts(), len(files), taskTitle)
+	}
+	if !report.Stale() {
+		return ""
+	}
+
+	if report.Obsolete() {
+		fmt.Printf("[%s] ⚠️ Task may be obsolete, needs review: '%s' (none of its listed files exist anymore)\n", ts(), taskTitle)
+	}
+	renames := report.Renames()
+	for oldPath, newPath := range renames {
+		fmt.Printf("[%s] 🧭 '%s' lists %s, which moved to %s\n", ts(), taskTitle, oldPath, newPath)
+	}
+
+	if updatePaths && len(renames) > 0 {
+		file := resolveTasksFile()
+		if _, err := os.Stat(file); err == nil {
+			err := updateControlFile(file, func(content string) string {
+				return tasks.ReplaceTaskFiles(content, taskTitle, renames)
+			})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not update task paths in %s: %v\n", ts(), file, err)
+			} else {
+				fmt.Printf("[%s] ✏️  Updated file paths for '%s' in %s\n", ts(), taskTitle, file)
+			}
+		}
+	}
+	return report.PromptNote()
+}
+
+func usage() {
+	fmt.Println("cursor-iter - task utilities")
+	fmt.Println("")
+	fmt.Println("All control files are stored in the .cursor-iter/ directory")
+	fmt.Println("")
+	fmt.Println("Usage:")
+	fmt.Println("  cursor-iter task-status   [--file .cursor-iter/tasks.md] [--progress .cursor-iter/progress.md] [--categories]")
+	fmt.Println("  cursor-iter task-status --format json|yaml|table  # status per task and totals for scripts and dashboards")
+	fmt.Println("  cursor-iter task-status [--summary] [--page N] [--page-size 50] [--group-by milestone|label|<label key>]  # counts only above 100 tasks")
+	fmt.Println("  cursor-iter task-status --label type:bugfix [--exclude-label type:feature]  # only tasks with or without these labels")
+	fmt.Println("  cursor-iter statusline [--width N]  # one condensed status line for tmux, prompts and editor status bars")
+	fmt.Println("  cursor-iter handoff [--reason R] [--output F]  # write HANDOFF.md: in-flight tasks, branches, worktrees and next steps")
+	fmt.Println("  cursor-iter archive-completed [--file .cursor-iter/tasks.md] [--progress .cursor-iter/progress.md] [--milestone M] [--before 2006-01-02] [--label L] [--queue=false]")
+	fmt.Println("  cursor-iter iterate-init   [--model auto] [--codex|--claude]  # uses .cursor-iter/prompts/initialize-iteration-universal.md")
+	fmt.Println("  cursor-iter iterate        [--max-in-progress 10] [--task T] [--label L] [--exclude-label L]  # runs iteration using .cursor-iter/prompts/iterate.md")
+	fmt.Println("  cursor-iter iterate-loop   [--codex|--claude] [--max-in-progress 10] [--task T ...] [--label L] [--exclude-label L]  # loops until completion")
+	fmt.Println("  cursor-iter serve [--addr 127.0.0.1:8787] [--token T] [--start] [-- iterate-loop flags]  # REST API to drive the loop remotely")
+	fmt.Println("  cursor-iter api-spec                     # print the OpenAPI document of the serve API")
+	fmt.Println("  cursor-iter editor-setup [--dir .vscode] # write VS Code/Cursor tasks and launch configs that drive cursor-iter")
+	fmt.Println("  cursor-iter add-feature                  # uses .cursor-iter/prompts/add-feature.md (DESIGN ONLY)")
+	fmt.Println("  cursor-iter add-feature --file <path>    # read feature description from file")
+	fmt.Println("  cursor-iter add-feature --prompt \"desc\"  # provide feature description as argument")
+	fmt.Println("  cursor-iter add-feature --url <spec URL> [--header 'Name: value']  # fetch the feature description from a spec")
+	fmt.Println("  cursor-iter add-feature [--codex|--claude]  # use codex or claude instead of cursor-agent")
+	fmt.Println("  cursor-iter add-feature --stage          # stage generated tasks by milestone for approval")
+	fmt.Println("  cursor-iter add-task --title T --context C --criteria 'a,b' [--files F] [--deps D] [--labels L] [--interactive]  # add a task without an agent")
+	fmt.Println("  cursor-iter add-task --from-url <spec URL> [--title T] [--header 'Name: value'] [--stage]  # add a task whose description is a remote spec")
+	fmt.Println("  cursor-iter cluster [--max-criteria 3] [--max-size 3] [--apply]  # suggest small related pending tasks to do in one run, or combine them")
+	fmt.Println("  cursor-iter sync-github --repo owner/name [--label cursor-iter] [--dry-run]  # import labeled GitHub issues as tasks and close them once their tasks are archived")
+	fmt.Println("  cursor-iter sync-jira --base-url URL --project KEY [--jql Q] [--done-transition Done] [--dry-run]  # import Jira tickets as tasks and transition them once their tasks are completed (JIRA_EMAIL, JIRA_API_TOKEN)")
+	fmt.Println("  cursor-iter sync-pr-reviews [--dry-run]  # add the changes reviewers request on tasks' pull requests as criteria and reopen the tasks")
+	fmt.Println("  cursor-iter accept-tasks [--milestone name|--all|--list]  # move staged tasks into tasks.md")
+	fmt.Println("  cursor-iter run-agent --prompt \"request\" # send ad-hoc request to cursor-agent/codex/claude")
+	fmt.Println("  cursor-iter run-agent [--codex|--claude]  # use codex or claude instead of cursor-agent")
+	fmt.Println("  cursor-iter run-agent [--json] [--output-file F] # script it: exit 2 = agent error, 3 = request failed")
+	fmt.Println("  cursor-iter validate-tasks [--fix] [--max-task-lines N] # validate/fix tasks.md structure")
+	fmt.Println("  cursor-iter validate-deps [--graph deps.dot|deps.mmd] [--fix]  # check that dependencies name tasks, find cycles and tasks that can never start")
+	fmt.Println("  cursor-iter task-note --task \"title\" [--codex|--claude]  # write the implementation note of a completed task")
+	fmt.Println("  cursor-iter show-task \"Title or ID\"    # show a task's details, status, runs and elapsed time")
+	fmt.Println("  cursor-iter remove-task --task \"title\" [--reason text]  # move a task to the trash")
+	fmt.Println("  cursor-iter complete-task --task \"title\" [--notes text] [--by name] [--check-all]  # record a task finished by hand so it isn't dispatched")
+	fmt.Println("  cursor-iter edit-task --task \"title\" [--title T] [--context C] [--criteria 'a,b'] [--labels L]  # change a task safely while iterate-loop runs")
+	fmt.Println("  cursor-iter trash list|restore --task \"title\"|purge [--all]  # manage deleted tasks")
+	fmt.Println("  cursor-iter prioritize --paths src/payments/... [--hotfix \"title\"] [--dry-run]  # run tasks touching a failing area first")
+	fmt.Println("  cursor-iter encrypt [--gen-key] [files...]  # encrypt control files at rest (key: CURSOR_ITER_KEY or OS keychain)")
+	fmt.Println("  cursor-iter decrypt [--print] [files...]  # decrypt encrypted control files, or print them")
+	fmt.Println("  cursor-iter snapshot [--id name]         # save a snapshot of the control files")
+	fmt.Println("  cursor-iter diff-control-files [--since latest|previous|<run-id>] [--list]  # diff control files vs a snapshot")
+	fmt.Println("  cursor-iter resolve-conflicts [--strategy interactive|agent|rebase] [--stats]  # resolve merge/rebase conflicts")
+	fmt.Println("  cursor-iter gen-dependency-tasks [--major] [--stage] [--dry-run]  # add a task per safe dependency upgrade")
+	fmt.Println("  cursor-iter scan-todos [--include 'TODO(autopilot):'] [--on-complete annotate|remove] [--stage]  # turn tagged TODO comments into tasks")
+	fmt.Println("  cursor-iter recurring [--list] [--dry-run]  # add due recurring tasks from tasks-recurring.md")
+	fmt.Println("  cursor-iter compress-context [--model m] [--force]  # write condensed copies of the control files")
+	fmt.Println("  cursor-iter stress [--tasks 50] [--concurrency 10] [--fail-rate 0.1] [--seed N]  # run mock agents under parallel load and check no task is lost")
+	fmt.Println("  cursor-iter prompt-preview [--task \"Title\"] [--variant lean]  # print the prompt iterate would send and its size, without running an agent")
+	fmt.Println("  cursor-iter prompt-templates [--write]  # list the prompt templates in use, or copy the built-in ones for customizing")
+	fmt.Println("  cursor-iter lint-prompts [--strict] [files...]  # check prompt templates for broken customizations")
+	fmt.Println("  cursor-iter schema <name> [--list] [--write dir]  # print JSON Schemas of cursor-iter files")
+	fmt.Println("  cursor-iter model-stats [--since 7d] [--period week] [--by-label]  # success rate, attempts, time and cost by model")
+	fmt.Println("  cursor-iter costs [--since 7d] [--run ID] [--prices LIST]  # tokens and cost by task, most expensive first")
+	fmt.Println("  cursor-iter agents list [--since 30d] [--format json]  # installed backends, their models, the configured ones and their stats")
+	fmt.Println("  cursor-iter heatmap [--since 30d] [--depth 2] [--top 25]  # files the autopilot changes most, with failure rates")
+	fmt.Println("  cursor-iter sign-off --task \"title\" [--by name]  # accept a task a code review held for sign-off")
+	fmt.Println("  cursor-iter timeline [--run latest|<id>] [--format mermaid|html] [--output F] [--list]  # Gantt chart of a run's tasks")
+	fmt.Println("  cursor-iter feed [--format ics|json] [--output F] [--milestone M] [--since 30d]  # completed tasks and milestones as a calendar or JSON Feed")
+	fmt.Println("  cursor-iter triage [--list] [--task title --action retry|retry-model|edit|block|rollback]  # review failed task runs")
+	fmt.Println("  cursor-iter pr-body --task \"title\" [--body-file F|-]  # PR description with the task's criteria checklist and run results")
+	fmt.Println("  cursor-iter audit-log export [--from 2025-01-01] [--to 2025-03-31] [--format jsonl|markdown] [--output F]  # hash-chained log of autonomous actions")
+	fmt.Println("  cursor-iter audit-log verify <export.jsonl>  # check an audit export hasn't been tampered with")
+	fmt.Println("  cursor-iter allowlist-checksum          # the config file's allowlist for --restricted and the checksum that signs it")
+	fmt.Println("  cursor-iter reset                       # remove .cursor-iter/ directory and all control files")
+	fmt.Println("")
+	fmt.Println("Options:")
+	fmt.Println("  --codex              Use codex CLI with gpt-5-codex model instead of cursor-agent")
+	fmt.Println("  --claude             Use the claude CLI (Claude Code) instead of cursor-agent; auto leaves the model to claude")
+	fmt.Println("  --model              Specify model for cursor-agent (auto, gpt-4o, etc.), codex (gpt-5-codex) or claude (sonnet, opus)")
+	fmt.Println("  --max-in-progress N  Maximum number of in-progress tasks allowed (default: 10)")
+	fmt.Println("  --fallback LIST      Backends to fall back to on failure, in order (e.g. codex; env AGENT_FALLBACK)")
+	fmt.Println("  --fallback-after N   Failures on a backend before falling back to the next one (default: 2)")
+	fmt.Println("  --task-logs MODE     Agent output to .cursor-iter/logs/<task>-<time>.log: auto (with --max-in-progress > 1), on or off (env TASK_LOGS)")
+	fmt.Println("  --log-format FORMAT  iterate-loop output: text, or json for one event per line on stdout, the usual lines on stderr (env LOG_FORMAT)")
+	fmt.Println("  --metrics-addr ADDR  Serve Prometheus metrics on /metrics, e.g. :9464 (env METRICS_ADDR)")
+	fmt.Println("  --fairness POLICY    Share slots between milestones: fifo, interleave or reserve=0.25 (env FAIRNESS)")
+	fmt.Println("  --defer-categories   Criteria categories that may stay unchecked, e.g. docs (env DEFER_CATEGORIES)")
+	fmt.Println("  --commit-policy      Commit message rules: conventional or types=..;scopes=..;max-subject=N (env COMMIT_POLICY)")
+	fmt.Println("  --commit-fix         amend (reword the last commit when safe) or instruct the agent (env COMMIT_FIX)")
+	fmt.Println("  --strict-files       Revert changes to files outside a task's Files to Modify list (env STRICT_FILES)")
+	fmt.Println("  --strict-files-allow Globs any task may change under --strict-files; tests and docs by default (env STRICT_FILES_ALLOW)")
+	fmt.Println("  --no-progress        Don't show live status lines for running tasks (env NO_PROGRESS)")
+	fmt.Println("  --embedded-prompts   iterate-init and add-feature use the prompts built into cursor-iter instead of fetching them (env EMBEDDED_PROMPTS)")
+	fmt.Println("  --offline            iterate-init, add-feature and add-task never use the network: built-in prompts, no spec URLs (env CURSOR_ITER_OFFLINE)")
+	fmt.Println("  --prompt-mirror URLS Fetch prompts from these URLs ({file} for the name) before GitHub and jsDelivr (env PROMPT_MIRROR)")
+	fmt.Println("  --tui                iterate-loop dashboard: tasks by status, output pane per agent; p pause, s skip, r retry, q quit (env TUI)")
+	fmt.Println("  --task-notes         Write a note per completed task to docs/autopilot/ (env TASK_NOTES, dir TASK_NOTES_DIR)")
+	fmt.Println("  --context-budget N   Point agents at condensed control files when the full ones exceed N tokens")
+	fmt.Println("  --completion-format  progress.md completion entry with custom fields, e.g. '... | ticket: {ticket}' (env COMPLETION_FORMAT)")
+	fmt.Println("  --detect-blocked     Block tasks whose agent says it needs a human (default true; extra patterns via --blocked-patterns)")
+	fmt.Println("  --agent-author ID    Commit as 'Name <email>' during agent runs; --author-task-id adds the task to the committer (env AGENT_AUTHOR)")
+	fmt.Println("  --global-max N       Share N concurrent agents between the iterate-loops of all repositories (env GLOBAL_MAX_AGENTS)")
+	fmt.Println("  --priority N         Priority of this repository for global agent slots; also --weight (env REPO_PRIORITY)")
+	fmt.Println("  --prompt-ladder LIST Simpler prompts to try while the model declines a task (default lean,rephrased,split; env PROMPT_LADDER)")
+	fmt.Println("  --project-type TYPE  code, docs or infra; switches prompt instructions and deferred criteria (default auto; env PROJECT_TYPE)")
+	fmt.Println("  --verify-fast CMD    Quick checks after each completed task, after the config file's gates; a failure reopens it (env VERIFY_FAST)")
+	fmt.Println("  --verify-full CMD    Full suite after every --verify-batch (5) completed tasks; reopens the task it blames (env VERIFY_FULL)")
+	fmt.Println("  --reviewer SPEC      Review completed tasks' diffs with a model, backend:model or command:<sh> (env REVIEWER)")
+	fmt.Println("  --review-policy P    fix=high;signoff=critical;rounds=2 by default; severities to send back or hold (env REVIEW_POLICY)")
+	fmt.Println("  --task-timeout D     Kill an agent (and its children) that runs longer than D, e.g. 45m, and retry the task (env TASK_TIMEOUT)")
+	fmt.Println("  --max-attempts N     Move a task to Failed after N failed runs in a row instead of retrying it (default 5, 0 = no limit; env MAX_ATTEMPTS)")
+	fmt.Println("  --notify-webhook URL Post task and loop events to a Slack, Discord or generic JSON webhook (env NOTIFY_WEBHOOK)")
+	fmt.Println("  --notify-on EVENTS   Events to post: started, completed, failed, blocked, finished or all (default all; env NOTIFY_ON)")
+	fmt.Println("  --notify-after-failures N  Post a task as failed after N failed runs in a row (default 3; env NOTIFY_AFTER_FAILURES)")
+	fmt.Println("  --budget USD         Stop starting tasks once iterate-loop's agents cost USD, exit when the running ones finish (env BUDGET)")
+	fmt.Println("  --prices LIST        USD per million tokens by model, e.g. gpt-5-codex=1.25, for costs and --budget (env MODEL_PRICES)")
+	fmt.Println("  --max-churn N        Stop starting tasks once iterate-loop changed N lines, ask to go on when the running ones finish (env MAX_CHURN)")
+	fmt.Println("  --restricted         Only run gate, reviewer and webhook entries of the config file's allowlist, signed by allowlist-checksum (env RESTRICTED=true)")
+	fmt.Println("  --shutdown-grace D   On Ctrl-C, let running agents finish for up to D before stopping them (env SHUTDOWN_GRACE)")
+	fmt.Println("  --file-claims        Don't run tasks whose Files to Modify overlap at the same time (default on; env FILE_CLAIMS=false)")
+	fmt.Println("  --worktree MODE      Run each task in its own git worktree and branch: off, merge or pr (opens a pull request with gh; env WORKTREE)")
+	fmt.Println("  --open-pr            Put each completed task's commits on feature/<task> and open a pull request for it with gh (env OPEN_PR=true)")
+	fmt.Println("  --pr-remote R        Remote branches are pushed to under --open-pr and --worktree pr (default origin; env PR_REMOTE)")
+	fmt.Println("  --pr-base B          Branch pull requests are opened against under --open-pr (default: the checked-out branch; env PR_BASE)")
+	fmt.Println("  --stagger D          Delay between starting tasks in iterate-loop (default 0)")
+	fmt.Println("  --race-throttle      Run fewer agents, further apart, while startups race on cli-config.json (default on; env RACE_THROTTLE=false)")
+	fmt.Println("")
+	fmt.Println("Configuration:")
+	fmt.Println("  .cursor-iter.yaml sets flag defaults for the repository (or CURSOR_ITER_CONFIG=path)")
+	fmt.Println("  Keys are flag names, e.g. 'model: gpt-5' or 'max-in-progress: 4'; a section named after a command applies to it only")
+	fmt.Println("  Also tasks-file, progress-file and agent-retries; flags override the file, which overrides environment variables")
+	fmt.Println("  A gates section of 'name: command' lines, e.g. 'lint: make lint', lists the quality gates a completed task must pass")
+	fmt.Println("  A label-defaults section, e.g. 'area:infra: [milestone:infra, exclusive:true]', adds labels to tasks that have a label")
+	fmt.Println("  'tips: false' turns off the tip printed after commands such as task-status and iterate")
+	fmt.Println("  An allowlist section of 'name: command or URL' lines lists what --restricted may run; ALLOWLIST_CHECKSUM or allowlist-checksum signs it")
+	fmt.Println("")
+	fmt.Println("Task Workflow:")
+	fmt.Println("  .cursor-iter/tasks.md     - Master task list (add-feature adds tasks here)")
+	fmt.Println("  .cursor-iter/progress.md  - Completion log (iterate-loop updates when tasks complete)")
+	fmt.Println("  .cursor-iter/features.md  - add-feature requests, their tasks and what delivering each took")
+	fmt.Println("  NOTE: This separation prevents write conflicts when adding features during iterate-loop")
+	fmt.Println("")
+	fmt.Println("Task Continuation:")
+	fmt.Println("  iterate-loop now continues working on in-progress tasks until completion")
+	fmt.Println("  If a task doesn't complete in one iteration, it will retry on the next iteration")
+	fmt.Println("  Use --max-in-progress to limit concurrent task processing")
 	fmt.Println("")
 	fmt.Println("Parallel Execution:")
-	fmt.Println("  Tasks start with 3-second stagger to prevent race conditions")
-	fmt.Println("  Each cursor-agent has additional 50-200ms startup delay")
-	fmt.Println("  This ensures safe parallel execution without file conflicts")
+	fmt.Println("  Tasks whose Files to Modify overlap don't run at the same time (--file-claims)")
+	fmt.Println("  --worktree merge gives each task a worktree and merges its branch when it completes")
+	fmt.Println("  Each cursor-agent has a 50-200ms startup delay; --stagger adds a delay between task starts")
+	fmt.Println("  This keeps parallel tasks from conflicting over the same files")
 }
 
 func main() {
@@ -294,170 +1175,1402 @@ func main() {
 		os.Exit(1)
 	}
 	cmd := os.Args[1]
+	loadRepoConfig(cmd)
 	debug := envOr("DEBUG", "") != "" // DEBUG=1 enables verbose mode
+	showFullPrompts := envOr("SHOW_FULL_PROMPTS", "") != ""
 	switch cmd {
 	case "task-status":
 		fs := flag.NewFlagSet("task-status", flag.ExitOnError)
 		file := fs.String("file", resolveTasksFile(), "tasks file")
 		progressFile := fs.String("progress", resolveProgressFile(), "progress file")
+		categories := fs.Bool("categories", false, "show acceptance criteria completion per category")
+		format := fs.String("format", "text", "output format: text, json, yaml or table")
+		deferCategories := fs.String("defer-categories", envOr("DEFER_CATEGORIES", ""), "criteria categories that may be deferred (e.g. docs,perf)")
+		projectType := fs.String("project-type", envOr("PROJECT_TYPE", project.Auto), "kind of repository: code, docs, infra or auto to detect it")
+		page := fs.Int("page", 0, "list only this page of tasks, from 1 (0 = every task)")
+		pageSize := fs.Int("page-size", envInt("TASK_STATUS_PAGE_SIZE", tasks.DefaultPageSize), "tasks per page")
+		groupBy := fs.String("group-by", "", "count tasks per milestone, label, or value of another label key such as type")
+		summary := fs.Bool("summary", false, fmt.Sprintf("show only the counts (the default for the text report above %d tasks)", summaryThreshold))
+		labelSpec := fs.String("label", "", "only show tasks with one of these comma-separated labels, e.g. type:bugfix")
+		excludeLabelSpec := fs.String("exclude-label", "", "leave out tasks with any of these comma-separated labels, e.g. type:feature")
 		dbg := fs.Bool("debug", debug, "enable verbose logging")
-		_ = fs.Parse(os.Args[2:])
+		parseFlags(fs, os.Args[2:])
 		if *dbg {
 			fmt.Printf("[%s] task-status reading %s and %s\n", ts(), *file, *progressFile)
 		}
-
-		// Read tasks.md
-		taskContent, err := os.ReadFile(*file)
+		if *page < 0 || *pageSize < 1 {
+			fmt.Fprintf(os.Stderr, "error: --page must be at least 0 and --page-size at least 1\n")
+			os.Exit(1)
+		}
+		summarySet := false
+		fs.Visit(func(f *flag.Flag) { summarySet = summarySet || f.Name == "summary" })
+
+		// Read tasks.md
+		taskContent, err := os.ReadFile(*file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading %s: %v\n", *file, err)
+			os.Exit(1)
+		}
+
+		// Read progress.md (create if doesn't exist)
+		progressContent, err := os.ReadFile(*progressFile)
+		if err != nil {
+			// If progress.md doesn't exist, create an empty one
+			progressContent = []byte("# Progress Log\n\n## Completed Tasks\n\n")
+		}
+		labels := tasks.ParseLabelFilter(*labelSpec, *excludeLabelSpec)
+		labels.Defaults = configLabelDefaults()
+		taskContent = []byte(labels.Keep(string(taskContent)))
+		if !labels.IsZero() && *format == "text" {
+			fmt.Printf("🏷️ Tasks %s\n", labels)
+		}
+
+		opts := tasks.StatusOptions{CountsOnly: *summary, Page: *page, PageSize: *pageSize, GroupBy: *groupBy, Defaults: configLabelDefaults()}
+		if *format != "text" {
+			if err := writeStatus(os.Stdout, *format, tasks.SummarizeWith(string(taskContent), string(progressContent), opts)); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+		// Listing a large backlog is slow and unreadable: above the
+		// threshold the text report shows only the counts unless asked
+		autoSummary := !summarySet && *page == 0
+		if autoSummary {
+			opts.CountsOnly = true
+		}
+		var s tasks.StatusSummary
+		if opts.CountsOnly || opts.Page > 0 || opts.GroupBy != "" {
+			s = tasks.SummarizeWith(string(taskContent), string(progressContent), opts)
+		}
+		if autoSummary && s.Totals.Total <= summaryThreshold {
+			opts.CountsOnly = false
+		}
+		if opts.CountsOnly || opts.Page > 0 {
+			if err := writeStatusSummary(os.Stdout, s, *groupBy); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			if autoSummary {
+				fmt.Printf("\n💡 %d tasks: showing counts only. Use --page 1 to list them, --group-by milestone to break them down, or --summary=false for the full report\n", s.Totals.Total)
+			}
+		} else {
+			report := tasks.StatusReportWithProgress(string(taskContent), string(progressContent))
+			fmt.Println(report)
+			if len(s.Groups) > 0 {
+				fmt.Printf("📁 By %s:\n", *groupBy)
+				writeGroups(os.Stdout, s.Groups)
+			}
+		}
+		if *categories {
+			policy := completionPolicy(*deferCategories, mustProjectType(*projectType, *dbg))
+			if catReport := tasks.CategoryReport(string(taskContent), policy); catReport != "" {
+				fmt.Println()
+				fmt.Println(catReport)
+			}
+		}
+	case "statusline":
+		fs := flag.NewFlagSet("statusline", flag.ExitOnError)
+		file := fs.String("file", resolveTasksFile(), "tasks file")
+		progressFile := fs.String("progress", resolveProgressFile(), "progress file")
+		width := fs.Int("width", envInt("STATUSLINE_WIDTH", 0), "shorten the line to this many characters (0 = no limit)")
+		parseFlags(fs, os.Args[2:])
+
+		// Status bars call this every few seconds: the counts come from the
+		// cache unless tasks.md or progress.md changed
+		c := statusline.Refresh(statusLinePath(), *file, *progressFile, configLabelDefaults())
+		fmt.Println(c.Line(time.Now(), *width))
+	case "handoff":
+		fs := flag.NewFlagSet("handoff", flag.ExitOnError)
+		output := fs.String("output", handoffPath(), "where to write the hand-off document")
+		reason := fs.String("reason", "", "why the session is paused, e.g. 'end of day'")
+		parseFlags(fs, os.Args[2:])
+		if err := writeHandoff(*output, *reason, ""); err != nil {
+			fmt.Fprintf(os.Stderr, "error writing hand-off: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("[%s] 📋 Wrote %s\n", ts(), *output)
+	case "pr-body":
+		fs := flag.NewFlagSet("pr-body", flag.ExitOnError)
+		title := fs.String("task", "", "task the pull request implements")
+		bodyFile := fs.String("body-file", "", "current PR description to update, - for stdin")
+		parseFlags(fs, os.Args[2:])
+		if *title == "" {
+			fmt.Fprintf(os.Stderr, "error: --task is required\n")
+			os.Exit(1)
+		}
+		body, err := readBodyFile(*bodyFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading %s: %v\n", *bodyFile, err)
+			os.Exit(1)
+		}
+		updated, err := syncPRBody(resolveTasksFile(), *title, body)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(updated)
+	case "validate-tasks":
+		fs := flag.NewFlagSet("validate-tasks", flag.ExitOnError)
+		file := fs.String("file", resolveTasksFile(), "tasks file")
+		fix := fs.Bool("fix", false, "attempt to fix structure issues")
+		maxTaskLines := fs.Int("max-task-lines", envInt("MAX_TASK_LINES", tasks.DefaultMaxTaskLines), "warn about tasks longer than this many lines (0 disables)")
+		dbg := fs.Bool("debug", debug, "enable verbose logging")
+		parseFlags(fs, os.Args[2:])
+		if *dbg {
+			fmt.Printf("[%s] validate-tasks reading %s\n", ts(), *file)
+		}
+		content, err := os.ReadFile(*file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading %s: %v\n", *file, err)
+			os.Exit(1)
+		}
+
+		if *fix {
+			fixedContent, result := tasks.ValidateAndFixTasksStructureWithLimit(string(content), *maxTaskLines)
+			if !result.Valid {
+				fmt.Fprintf(os.Stderr, "Structure validation failed:\n")
+				for _, err := range result.Errors {
+					fmt.Fprintf(os.Stderr, "  ERROR: %s\n", err)
+				}
+				os.Exit(1)
+			}
+			if len(result.Warnings) > 0 {
+				fmt.Printf("Warnings:\n")
+				for _, warning := range result.Warnings {
+					fmt.Printf("  WARNING: %s\n", warning)
+				}
+			}
+			if err := atomicfile.WriteFile(*file, []byte(fixedContent), 0644); err != nil {
+				fmt.Fprintf(os.Stderr, "error writing fixed content: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("✅ Fixed tasks.md structure\n")
+		} else {
+			result := tasks.ValidateTasksStructureWithLimit(string(content), *maxTaskLines)
+			if result.Valid {
+				fmt.Printf("✅ tasks.md structure is valid\n")
+			} else {
+				fmt.Fprintf(os.Stderr, "❌ Structure validation failed:\n")
+				for _, err := range result.Errors {
+					fmt.Fprintf(os.Stderr, "  ERROR: %s\n", err)
+				}
+				os.Exit(1)
+			}
+			if len(result.Warnings) > 0 {
+				fmt.Printf("Warnings:\n")
+				for _, warning := range result.Warnings {
+					fmt.Printf("  WARNING: %s\n", warning)
+				}
+			}
+		}
+	case "validate-deps":
+		fs := flag.NewFlagSet("validate-deps", flag.ExitOnError)
+		file := fs.String("file", resolveTasksFile(), "tasks file")
+		progressFile := fs.String("progress", resolveProgressFile(), "progress file")
+		outdir := fs.String("outdir", getControlFilePath("completed_tasks"), "archive directory whose tasks count as completed dependencies")
+		graph := fs.String("graph", "", "write the dependency graph to this file, e.g. deps.dot or deps.mmd")
+		format := fs.String("format", "", "graph format: dot or mermaid (default: from the --graph extension, .mmd for mermaid)")
+		fix := fs.Bool("fix", false, "rewrite dependencies on renamed tasks to their new titles")
+		parseFlags(fs, os.Args[2:])
+
+		ok, err := validateDeps(*file, *progressFile, *outdir, *graph, *format, *fix)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+	case "snapshot":
+		fs := flag.NewFlagSet("snapshot", flag.ExitOnError)
+		id := fs.String("id", snapshot.NewID(time.Now()), "snapshot id")
+		parseFlags(fs, os.Args[2:])
+
+		target, err := snapshot.Take(snapshotDir(), *id, controlFilePaths(), snapshot.DefaultKeep)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error taking snapshot: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Saved control file snapshot %s to %s\n", *id, target)
+	case "triage":
+		fs := flag.NewFlagSet("triage", flag.ExitOnError)
+		list := fs.Bool("list", false, "list failed tasks that need triage and exit")
+		taskTitle := fs.String("task", "", "triage only this task")
+		action := fs.String("action", "", "apply an action without prompting: retry, retry-model, edit, block, rollback")
+		model := fs.String("model", "", "model for --action retry-model")
+		reason := fs.String("reason", "", "reason for --action block")
+		yes := fs.Bool("yes", false, "don't ask before reverting commits")
+		parseFlags(fs, os.Args[2:])
+
+		session := &triageSession{
+			in:           bufio.NewReader(os.Stdin),
+			out:          os.Stdout,
+			tasksFile:    resolveTasksFile(),
+			progressFile: resolveProgressFile(),
+			editor:       envOr("EDITOR", "vi"),
+			assumeYes:    *yes,
+		}
+		failures, err := pendingFailures(session.progressFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading run journal: %v\n", err)
+			os.Exit(1)
+		}
+		if *taskTitle != "" {
+			var matched []journal.Entry
+			for _, f := range failures {
+				if f.Task == *taskTitle {
+					matched = append(matched, f)
+				}
+			}
+			if len(matched) == 0 {
+				fmt.Fprintf(os.Stderr, "error: no untriaged failure for task '%s'\n", *taskTitle)
+				os.Exit(1)
+			}
+			failures = matched
+		}
+		if *list || len(failures) == 0 {
+			printFailureList(os.Stdout, failures)
+			return
+		}
+
+		if *action != "" {
+			if *taskTitle == "" {
+				fmt.Fprintln(os.Stderr, "error: --action requires --task")
+				os.Exit(1)
+			}
+			detail := *reason
+			if *action == triageRetryModel {
+				detail = *model
+			}
+			if err := session.apply(failures[0], *action, detail); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+		_ = session.run(failures)
+	case "resolve-conflicts":
+		fs := flag.NewFlagSet("resolve-conflicts", flag.ExitOnError)
+		strategy := fs.String("strategy", "interactive", "how to resolve non-generated files: interactive, agent or rebase")
+		generated := fs.String("generated", envOr("GENERATED_FILES", strings.Join(conflict.DefaultGenerated, ",")), "comma-separated globs of generated files that take the incoming version")
+		stats := fs.Bool("stats", false, "show how often each file has conflicted and exit")
+		useCodex := fs.Bool("codex", false, "use codex CLI with gpt-5-codex model")
+		useClaude := fs.Bool("claude", false, "use the claude CLI (Claude Code)")
+		model := fs.String("model", envOr("MODEL", "auto"), "cursor-agent, codex (gpt-5-codex) or claude (sonnet, opus) model")
+		dbg := fs.Bool("debug", debug, "enable verbose logging")
+		parseFlags(fs, os.Args[2:])
+		agentBackend := primaryBackend(*useCodex, *useClaude)
+
+		if *stats {
+			if err := printConflictStats(os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "error reading conflict log: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		root, _ := os.Getwd()
+		st, err := conflict.Inspect(root)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(st.Files) == 0 {
+			fmt.Println("✅ No merge conflicts")
+			return
+		}
+		agentModel := runner.DefaultModel(agentBackend, *model)
+		session := &conflictSession{
+			in:        bufio.NewReader(os.Stdin),
+			out:       os.Stdout,
+			root:      root,
+			generated: strings.Split(*generated, ","),
+			editor:    envOr("EDITOR", "vi"),
+			runAgent:  agentRunner(agentBackend, agentModel, *dbg),
+		}
+		fmt.Printf("[%s] ⚔️  %s stopped on conflicts in %d file(s)\n", ts(), st.Op, len(st.Files))
+
+		session.resolveGenerated(st)
+		st, _ = conflict.Inspect(root)
+		if len(st.Files) > 0 {
+			switch *strategy {
+			case "agent":
+				err = session.useAgent(st)
+			case "rebase":
+				err = conflict.RetryAsRebase(root, st)
+				for _, f := range st.Files {
+					session.record(f, resolvedRebase)
+				}
+			case "interactive":
+				err = session.interactive()
+			default:
+				err = fmt.Errorf("unknown strategy %q", *strategy)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[%s] ⚠️ %v\n", ts(), err)
+			}
+		}
+		session.flush(st.Op)
+
+		final, _ := conflict.Inspect(root)
+		if len(final.Files) > 0 {
+			fmt.Printf("[%s] ⚠️ %d file(s) still conflicted: %s\n", ts(), len(final.Files), strings.Join(final.Files, ", "))
+			os.Exit(1)
+		}
+		switch final.Op {
+		case conflict.OpRebase:
+			fmt.Printf("[%s] ✅ Conflicts resolved. Run 'git rebase --continue' to go on\n", ts())
+		case conflict.OpMerge:
+			fmt.Printf("[%s] ✅ Conflicts resolved. Run 'git commit --no-edit' to finish the merge\n", ts())
+		default:
+			fmt.Printf("[%s] ✅ Conflicts resolved\n", ts())
+		}
+	case "gen-dependency-tasks":
+		fs := flag.NewFlagSet("gen-dependency-tasks", flag.ExitOnError)
+		dir := fs.String("dir", ".", "project directory containing go.mod, package.json or requirements.txt")
+		major := fs.Bool("major", false, "also create tasks for major version upgrades")
+		stage := fs.Bool("stage", false, "stage the tasks for review under the 'dependencies' milestone")
+		dryRun := fs.Bool("dry-run", false, "list the upgrades without creating tasks")
+		parseFlags(fs, os.Args[2:])
+
+		if err := ensureCursorIterDir(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create %s directory: %v\n", CursorIterDir, err)
+			os.Exit(1)
+		}
+		fmt.Printf("[%s] 🔍 Checking dependencies in %s...\n", ts(), *dir)
+		updates, errs := deps.Scan(*dir, deps.Exec)
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: %v\n", ts(), err)
+		}
+		added, skipped, err := genDependencyTasks(updates, *major, *stage, *dryRun)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, u := range skipped {
+			fmt.Printf("[%s] ⏭️  Skipped major upgrade of %s %s → %s (use --major)\n", ts(), u.Name, u.Current, u.Latest)
+		}
+		for _, title := range added {
+			fmt.Printf("[%s] 📦 %s\n", ts(), title)
+		}
+		switch {
+		case len(added) == 0:
+			fmt.Printf("[%s] ✅ No new dependency upgrades\n", ts())
+		case *dryRun:
+			fmt.Printf("[%s] 💡 %d upgrade(s) found; run without --dry-run to create the tasks\n", ts(), len(added))
+		case *stage:
+			fmt.Printf("[%s] ✅ Staged %d dependency task(s); accept them with 'cursor-iter accept-tasks --milestone %s'\n", ts(), len(added), dependencyMilestone)
+		default:
+			fmt.Printf("[%s] ✅ Added %d dependency task(s) to %s\n", ts(), len(added), resolveTasksFile())
+		}
+	case "scan-todos":
+		fs := flag.NewFlagSet("scan-todos", flag.ExitOnError)
+		marker := fs.String("include", envOr("TODO_MARKER", todos.DefaultMarker), "tag of the TODO comments to turn into tasks")
+		dir := fs.String("dir", ".", "directory to scan")
+		onComplete := fs.String("on-complete", todos.Annotate, "what to do with a comment once its task completes: annotate (TODO becomes DONE) or remove")
+		stage := fs.Bool("stage", false, "stage the tasks for review under the 'todos' milestone")
+		dryRun := fs.Bool("dry-run", false, "list the TODOs without creating tasks")
+		parseFlags(fs, os.Args[2:])
+
+		if *onComplete != todos.Annotate && *onComplete != todos.Remove {
+			fmt.Fprintf(os.Stderr, "invalid --on-complete %q (use %s or %s)\n", *onComplete, todos.Annotate, todos.Remove)
+			os.Exit(1)
+		}
+		if err := ensureCursorIterDir(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create %s directory: %v\n", CursorIterDir, err)
+			os.Exit(1)
+		}
+		fmt.Printf("[%s] 🔍 Scanning %s for %q comments...\n", ts(), *dir, *marker)
+		found, err := todos.Scan(*dir, *marker)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error scanning %s: %v\n", *dir, err)
+			os.Exit(1)
+		}
+		// Tasks point at files relative to the working directory
+		for i := range found {
+			found[i].File = filepath.ToSlash(filepath.Join(*dir, found[i].File))
+		}
+		added, err := genTodoTasks(found, *onComplete, *stage, *dryRun)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, title := range added {
+			fmt.Printf("[%s] 📌 %s\n", ts(), title)
+		}
+		switch {
+		case len(added) == 0:
+			fmt.Printf("[%s] ✅ No new TODOs (%d already have tasks)\n", ts(), len(found))
+		case *dryRun:
+			fmt.Printf("[%s] 💡 %d TODO(s) found; run without --dry-run to create the tasks\n", ts(), len(added))
+		case *stage:
+			fmt.Printf("[%s] ✅ Staged %d TODO task(s); accept them with 'cursor-iter accept-tasks --milestone %s'\n", ts(), len(added), todoMilestone)
+		default:
+			fmt.Printf("[%s] ✅ Added %d TODO task(s) to %s\n", ts(), len(added), resolveTasksFile())
+		}
+	case "recurring":
+		fs := flag.NewFlagSet("recurring", flag.ExitOnError)
+		list := fs.Bool("list", false, "list the recurring tasks and when they are next due")
+		dryRun := fs.Bool("dry-run", false, "show the tasks that are due without adding them")
+		parseFlags(fs, os.Args[2:])
+
+		if *list {
+			if err := printRecurring(os.Stdout, time.Now()); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+		added, err := materializeRecurring(time.Now(), *dryRun)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, title := range added {
+			fmt.Printf("[%s] 🔁 %s\n", ts(), title)
+		}
+		switch {
+		case len(added) == 0:
+			fmt.Printf("[%s] ✅ No recurring tasks due\n", ts())
+		case *dryRun:
+			fmt.Printf("[%s] 💡 %d recurring task(s) due; run without --dry-run to add them\n", ts(), len(added))
+		default:
+			fmt.Printf("[%s] ✅ Added %d recurring task(s) to %s\n", ts(), len(added), resolveTasksFile())
+		}
+	case "compress-context":
+		fs := flag.NewFlagSet("compress-context", flag.ExitOnError)
+		useCodex := fs.Bool("codex", false, "condense with codex instead of cursor-agent (with --model)")
+		useClaude := fs.Bool("claude", false, "condense with claude instead of cursor-agent (with --model)")
+		model := fs.String("model", envOr("COMPRESS_MODEL", ""), "condense with this (cheap) model instead of extractive heuristics")
+		force := fs.Bool("force", false, "rebuild condensed copies even if the control files did not change")
+		dbg := fs.Bool("debug", debug, "enable verbose logging")
+		parseFlags(fs, os.Args[2:])
+		agentBackend := primaryBackend(*useCodex, *useClaude)
+
+		var summarize func(name, content string) (string, error)
+		if *model != "" {
+			summarize = modelSummarizer(agentBackend, *model, *dbg)
+		}
+		files, err := compressContext(summarize, *force)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(files) == 0 {
+			fmt.Printf("[%s] ℹ️  No control files to condense\n", ts())
+		}
+		for _, f := range files {
+			state := "condensed"
+			if f.Cached {
+				state = "unchanged"
+			}
+			fmt.Printf("[%s] 🗜️  %s: ~%d → ~%d tokens (%s) %s\n", ts(), f.Name, f.Before, f.After, state, f.Path)
+		}
+	case "stress":
+		fs := flag.NewFlagSet("stress", flag.ExitOnError)
+		var o stressOptions
+		fs.IntVar(&o.Tasks, "tasks", 50, "simulated tasks to complete")
+		fs.IntVar(&o.Concurrency, "concurrency", 10, "agents running at once")
+		mock := fs.Bool("mock", true, "simulate the agents instead of running them (the only mode)")
+		fs.IntVar(&o.Criteria, "criteria", 3, "acceptance criteria per task")
+		fs.DurationVar(&o.Delay, "delay", 20*time.Millisecond, "average time a mock agent takes")
+		fs.Float64Var(&o.FailRate, "fail-rate", 0.1, "share of mock runs that fail and are retried, 0 to 0.9")
+		fs.IntVar(&o.ArchiveEvery, "archive-every", 10, "archive completed tasks after this many completions while agents run (0 = only at the end)")
+		fs.Int64Var(&o.Seed, "seed", time.Now().UnixNano(), "seed of the simulated delays and failures, to repeat a run")
+		keep := fs.Bool("keep", false, "keep the scratch workspace afterwards")
+		verbose := fs.Bool("verbose", false, "show the loop's and agents' output instead of writing it to the workspace")
+		parseFlags(fs, os.Args[2:])
+		switch {
+		case !*mock:
+			fmt.Fprintf(os.Stderr, "error: stress only runs mock agents; real ones would spend tokens on %d throwaway tasks\n", o.Tasks)
+			os.Exit(1)
+		case o.Tasks < 1 || o.Concurrency < 1 || o.Criteria < 1:
+			fmt.Fprintf(os.Stderr, "error: --tasks, --concurrency and --criteria must be at least 1\n")
+			os.Exit(1)
+		case o.FailRate < 0 || o.FailRate > 0.9:
+			fmt.Fprintf(os.Stderr, "error: --fail-rate must be between 0 and 0.9\n")
+			os.Exit(1)
+		}
+
+		// The test runs in a scratch workspace, never in the project
+		workspace, err := os.MkdirTemp("", "cursor-iter-stress-")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		originalDir, _ := os.Getwd()
+		if err := os.Chdir(workspace); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("[%s] 🏋️ Stress test: %d tasks, %d agents at a time, %.0f%% simulated failures, seed %d\n", ts(), o.Tasks, o.Concurrency, o.FailRate*100, o.Seed)
+		stdout, stderr := os.Stdout, os.Stderr
+		logPath := filepath.Join(workspace, "stress.log")
+		if !*verbose {
+			if f, err := os.Create(logPath); err == nil {
+				os.Stdout, os.Stderr = f, f
+				defer f.Close()
+			}
+		}
+		rep, err := runStress(o)
+		os.Stdout, os.Stderr = stdout, stderr
+		os.Chdir(originalDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v (workspace kept at %s)\n", err, workspace)
+			os.Exit(1)
+		}
+
+		fmt.Printf("[%s] 📊 %d runs (%d failed and retried), %d archives, up to %d agents at once, %v (%.1f tasks/s)\n",
+			ts(), rep.Runs, rep.Failures, rep.Archives, rep.MaxActive, rep.Duration.Round(time.Millisecond), float64(o.Tasks)/rep.Duration.Seconds())
+		if raceEnabled {
+			fmt.Printf("[%s] 🔬 Race detector on: a data race would have stopped the test with a report\n", ts())
+		} else {
+			fmt.Printf("[%s] 💡 Race detector off; build with 'go build -race ./cmd/cursor-iter' to catch data races too\n", ts())
+		}
+		if len(rep.Problems) > 0 {
+			for _, p := range rep.Problems {
+				fmt.Fprintf(os.Stderr, "[%s] ❌ %s\n", ts(), p)
+			}
+			fmt.Fprintf(os.Stderr, "[%s] 🔍 Workspace kept at %s (log: %s); repeat with --seed %d\n", ts(), workspace, logPath, o.Seed)
+			os.Exit(1)
+		}
+		fmt.Printf("[%s] ✅ No lost, duplicated or stuck tasks\n", ts())
+		if *keep {
+			fmt.Printf("[%s] 📁 Workspace kept at %s\n", ts(), workspace)
+		} else {
+			os.RemoveAll(workspace)
+		}
+	case "prompt-preview":
+		fs := flag.NewFlagSet("prompt-preview", flag.ExitOnError)
+		file := fs.String("file", resolveTasksFile(), "tasks file")
+		progressFile := fs.String("progress", resolveProgressFile(), "progress file")
+		title := fs.String("task", "", "task to preview (default: the task iterate would work on next)")
+		variant := fs.String("variant", "", "prompt variant: full, lean, rephrased or split (default: the one the task's next run gets)")
+		deferCategories := fs.String("defer-categories", envOr("DEFER_CATEGORIES", ""), "criteria categories that may be deferred (e.g. docs,perf)")
+		projectType := fs.String("project-type", envOr("PROJECT_TYPE", project.Auto), "kind of repository: code, docs, infra or auto to detect it")
+		commitPolicy := fs.String("commit-policy", envOr("COMMIT_POLICY", ""), "commit message rules, e.g. conventional or types=feat,fix;scopes=api;max-subject=72")
+		commitFix := fs.String("commit-fix", envOr("COMMIT_FIX", commitFixAmend), "how to fix commit policy violations: amend or instruct")
+		strictFiles := fs.Bool("strict-files", envOr("STRICT_FILES", "") != "", "revert changes to files outside a task's Files to Modify list")
+		strictAllow := fs.String("strict-files-allow", envOr("STRICT_FILES_ALLOW", strings.Join(scope.DefaultAllowed, ",")), "comma-separated globs any task may change under --strict-files")
+		completionFormat := fs.String("completion-format", envOr("COMPLETION_FORMAT", ""), "completion entry template for progress.md, e.g. '"+tasks.DefaultCompletionFormat+" | ticket: {ticket}'")
+		contextBudget := fs.Int("context-budget", 0, "token budget for the control files; above it agents read condensed copies (0 = no budget)")
+		ladderSpec := fs.String("prompt-ladder", envOr("PROMPT_LADDER", defaultPromptLadder), "prompt variants to try, in order, while the model declines a task (lean, rephrased, split; none to turn off)")
+		parseFlags(fs, os.Args[2:])
+		kind := mustProjectType(*projectType, false)
+		commits := mustCommitChecker(*commitPolicy, *commitFix)
+		strict := newFileScopeChecker(*strictFiles, *strictAllow)
+		entryFormat := mustCompletionFormat(*completionFormat)
+		ladder := mustPromptLadder(*ladderSpec)
+		switch *variant {
+		case "", promptFull, promptLean, promptRephrased, promptSplit:
+		default:
+			fmt.Fprintf(os.Stderr, "invalid --variant %q: must be %s, %s, %s or %s\n", *variant, promptFull, promptLean, promptRephrased, promptSplit)
+			os.Exit(1)
+		}
+
+		tasksMd, err := os.ReadFile(*file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading tasks file: %v\n", err)
+			os.Exit(1)
+		}
+		progressMd, _ := os.ReadFile(*progressFile)
+		taskTitle, err := previewTaskTitle(string(tasksMd), string(progressMd), *title)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		if *variant == "" {
+			*variant = ladder.Variant(taskTitle)
+		}
+		notes := []string{tasks.DeferredCriteriaNote(completionPolicy(*deferCategories, kind)), commits.PromptNote(), strict.PromptNote(), entryFormat.PromptNote()}
+
+		// The checks behind the notes log to stdout, which is for the prompt
+		stdout := os.Stdout
+		os.Stdout = os.Stderr
+		mustPromptTemplates()
+		preview := previewPrompt(kind, *variant, taskTitle, tasks.ExtractTaskDetails(string(tasksMd), taskTitle), *contextBudget, notes...)
+		os.Stdout = stdout
+		fmt.Println(preview.Prompt)
+		writePromptSize(os.Stderr, preview)
+	case "prompt-templates":
+		fs := flag.NewFlagSet("prompt-templates", flag.ExitOnError)
+		write := fs.Bool("write", false, "copy the built-in templates into "+promptTemplatesDir()+" for customizing")
+		parseFlags(fs, os.Args[2:])
+		if *write {
+			written, err := writePromptTemplates(promptTemplatesDir())
+			for _, path := range written {
+				fmt.Printf("[%s] ✏️  Wrote %s\n", ts(), path)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		t, err := prompt.Load(promptTemplatesDir())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid prompt template: %v\n", err)
+			os.Exit(1)
+		}
+		for _, name := range prompt.Names {
+			source := "built-in"
+			if slices.Contains(t.Overridden, name) {
+				source = filepath.Join(promptTemplatesDir(), name)
+			}
+			fmt.Printf("%-10s %s\n", name, source)
+		}
+	case "lint-prompts":
+		fs := flag.NewFlagSet("lint-prompts", flag.ExitOnError)
+		dir := fs.String("dir", promptsDir(), "directory of prompt templates to lint")
+		strict := fs.Bool("strict", false, "fail on warnings too")
+		parseFlags(fs, os.Args[2:])
+
+		issues, checked, err := lintPrompts(*dir, fs.Args())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, issue := range issues {
+			fmt.Println(issue)
+		}
+		if promptlint.HasErrors(issues, *strict) {
+			fmt.Fprintf(os.Stderr, "❌ %d problem(s) in %d prompt template(s)\n", len(issues), checked)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ %d prompt template(s) checked, %d warning(s)\n", checked, len(issues))
+	case "schema":
+		fs := flag.NewFlagSet("schema", flag.ExitOnError)
+		list := fs.Bool("list", false, "list available schemas")
+		write := fs.String("write", "", "write every schema into this directory")
+		parseFlags(fs, os.Args[2:])
+
+		switch {
+		case *list:
+			for _, name := range schema.Names() {
+				spec, _ := schema.Lookup(name)
+				fmt.Printf("  %-18s %s\n", name, spec.Description)
+			}
+		case *write != "":
+			if err := writeSchemas(*write); err != nil {
+				fmt.Fprintf(os.Stderr, "error writing schemas: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("[%s] 📐 Wrote %d schema(s) to %s\n", ts(), len(schema.Names()), *write)
+		case fs.NArg() == 1:
+			if err := printSchema(os.Stdout, fs.Arg(0)); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+		default:
+			fmt.Fprintf(os.Stderr, "usage: cursor-iter schema <name> | --list | --write <dir>\n")
+			os.Exit(1)
+		}
+	case "model-stats":
+		fs := flag.NewFlagSet("model-stats", flag.ExitOnError)
+		since := fs.String("since", "", "only count runs since a duration ago (7d, 12h) or a date (2025-01-31)")
+		period := fs.String("period", "", "split results by day, week or month")
+		byLabel := fs.Bool("by-label", false, "split results by task label")
+		priceSpec := fs.String("prices", envOr("MODEL_PRICES", ""), "USD per million tokens by model, e.g. gpt-5-codex=1.25,sonnet=3")
+		parseFlags(fs, os.Args[2:])
+
+		sinceTime, err := parseSince(*since, time.Now())
+		if err == nil {
+			err = journal.ValidPeriod(*period)
+		}
+		prices, priceErr := parsePrices(*priceSpec)
+		if err == nil {
+			err = priceErr
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+
+		entries, err := journal.Read(journalPath())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading run journal: %v\n", err)
+			os.Exit(1)
+		}
+		opts := journal.StatsOptions{Since: sinceTime, Period: *period, ByLabel: *byLabel}
+		stats := journal.ComputeStats(entries, opts)
+		if len(stats) == 0 {
+			fmt.Println("No task runs recorded yet. Runs are recorded by 'iterate' and 'iterate-loop'.")
+			return
+		}
+		printModelStats(os.Stdout, stats, opts, prices)
+	case "costs":
+		fs := flag.NewFlagSet("costs", flag.ExitOnError)
+		since := fs.String("since", "", "only count runs since a duration ago (7d, 12h) or a date (2025-01-31)")
+		run := fs.String("run", "", "only count the runs of one iterate-loop, by run ID")
+		priceSpec := fs.String("prices", envOr("MODEL_PRICES", ""), "USD per million tokens by model, for runs whose agent reported no cost, e.g. gpt-5-codex=1.25,sonnet=3")
+		parseFlags(fs, os.Args[2:])
+
+		sinceTime, err := parseSince(*since, time.Now())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		prices := mustPrices(*priceSpec)
+		entries, err := journal.Read(journalPath())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading run journal: %v\n", err)
+			os.Exit(1)
+		}
+		costs := journal.TaskCosts(entries, sinceTime, *run, prices)
+		if len(costs) == 0 {
+			fmt.Println("No task runs recorded yet. Runs are recorded by 'iterate' and 'iterate-loop'.")
+			return
+		}
+		printCosts(os.Stdout, costs)
+	case "heatmap":
+		fs := flag.NewFlagSet("heatmap", flag.ExitOnError)
+		since := fs.String("since", "", "only count runs since a duration ago (30d, 12h) or a date (2025-01-31)")
+		depth := fs.Int("depth", 2, "directory levels to group files by (0 = individual files)")
+		top := fs.Int("top", 25, "number of areas to show (0 = all)")
+		parseFlags(fs, os.Args[2:])
+
+		sinceTime, err := parseSince(*since, time.Now())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		entries, err := journal.Read(journalPath())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading run journal: %v\n", err)
+			os.Exit(1)
+		}
+		areas := journal.Heatmap(entries, sinceTime, *depth, commitFiles)
+		if len(areas) == 0 {
+			fmt.Println("No commits recorded for task runs yet. Runs are recorded by 'iterate' and 'iterate-loop'.")
+			return
+		}
+		printHeatmap(os.Stdout, areas, *top)
+	case "encrypt":
+		fs := flag.NewFlagSet("encrypt", flag.ExitOnError)
+		genKey := fs.Bool("gen-key", false, "print a new key and exit")
+		parseFlags(fs, os.Args[2:])
+		if *genKey {
+			key, err := seal.GenerateKey()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(key)
+			fmt.Fprintf(os.Stderr, "Export it as CURSOR_ITER_KEY, or store it in the keychain as %q:\n", keychainService)
+			fmt.Fprintf(os.Stderr, "  macOS: security add-generic-password -a \"$USER\" -s %s -w <key>\n", keychainService)
+			fmt.Fprintf(os.Stderr, "  Linux: secret-tool store --label=%s service %s\n", keychainService, keychainService)
+			return
+		}
+		names := fs.Args()
+		if len(names) == 0 {
+			names = sealableFiles
+		}
+		encrypted := 0
+		for _, name := range names {
+			sealed, err := sealControlFile(name)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			if sealed {
+				fmt.Printf("[%s] 🔒 Encrypted %s\n", ts(), getControlFilePath(name))
+				encrypted++
+			}
+		}
+		if encrypted == 0 {
+			fmt.Println("Nothing to encrypt: the files are missing or already encrypted.")
+		}
+	case "decrypt":
+		fs := flag.NewFlagSet("decrypt", flag.ExitOnError)
+		printOnly := fs.Bool("print", false, "print the decrypted files instead of writing them")
+		parseFlags(fs, os.Args[2:])
+		names := fs.Args()
+		if len(names) == 0 {
+			names = sealedControlFiles()
+		}
+		for _, name := range names {
+			if *printOnly {
+				data, err := readControlFile(name)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "error: %v\n", err)
+					os.Exit(1)
+				}
+				os.Stdout.Write(data)
+				continue
+			}
+			unsealed, err := unsealControlFile(name)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			if unsealed {
+				fmt.Printf("[%s] 🔓 Decrypted %s\n", ts(), getControlFilePath(name))
+			}
+		}
+	case "timeline":
+		fs := flag.NewFlagSet("timeline", flag.ExitOnError)
+		runSpec := fs.String("run", "latest", "run to show: latest, a run id or a unique prefix of one")
+		format := fs.String("format", "mermaid", "output format: mermaid or html")
+		output := fs.String("output", "", "write the timeline to a file instead of stdout")
+		list := fs.Bool("list", false, "list the recorded runs")
+		parseFlags(fs, os.Args[2:])
+
+		entries, err := journal.Read(journalPath())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading run journal: %v\n", err)
+			os.Exit(1)
+		}
+		runs := timeline.Runs(entries)
+		if *list {
+			if len(runs) == 0 {
+				fmt.Println("No runs recorded yet. Runs are recorded by 'iterate' and 'iterate-loop'.")
+			}
+			for _, id := range runs {
+				tl := timeline.Build(entries, id)
+				fmt.Printf("%s  %d runs of %d tasks, %s\n", id, len(tl.Bars), tl.Tasks(), tl.Duration().Round(time.Second))
+			}
+			return
+		}
+		id, err := timeline.Resolve(runs, *runSpec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := writeTimeline(*output, *format, timeline.Build(entries, id)); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		if *output != "" {
+			fmt.Printf("[%s] 🗓️ Wrote the timeline of run %s to %s\n", ts(), id, *output)
+		}
+	case "feed":
+		fs := flag.NewFlagSet("feed", flag.ExitOnError)
+		file := fs.String("file", resolveTasksFile(), "tasks file")
+		progressFile := fs.String("progress", resolveProgressFile(), "progress file")
+		outdir := fs.String("outdir", getControlFilePath("completed_tasks"), "archive directory whose completions are included")
+		format := fs.String("format", "", "output format: ics or json (default: json for a .json --output, else ics)")
+		output := fs.String("output", "", "write the feed to a file instead of stdout")
+		milestone := fs.String("milestone", "", "only include the tasks of this milestone")
+		since := fs.String("since", "", "only include completions since a duration ago (30d, 12h) or a date (2025-01-31)")
+		title := fs.String("title", defaultFeedTitle(), "name of the calendar or feed")
+		parseFlags(fs, os.Args[2:])
+
+		sinceTime, err := parseSince(*since, time.Now())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		f, err := feedFormat(*output, *format)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		progress, err := progressFeed(*title, *file, *progressFile, *outdir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		progress = progress.Filter(sinceTime, *milestone)
+		if err := writeFeed(*output, f, progress); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		if *output != "" {
+			fmt.Printf("[%s] 🗓️ Wrote %d completions and milestones to %s\n", ts(), len(progress.Items), *output)
+		}
+	case "allowlist-checksum":
+		fs := flag.NewFlagSet("allowlist-checksum", flag.ExitOnError)
+		parseFlags(fs, os.Args[2:])
+
+		if err := printAllowlist(repoConfig, envOr("ALLOWLIST_CHECKSUM", repoConfig.Values[allowlistChecksumKey])); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid %s in %s: %v\n", allowlistSection, configPath(), err)
+			os.Exit(1)
+		}
+	case "diff-control-files":
+		fs := flag.NewFlagSet("diff-control-files", flag.ExitOnError)
+		since := fs.String("since", "latest", "snapshot or run id to diff against (latest, previous, or an id prefix)")
+		list := fs.Bool("list", false, "list available snapshots")
+		contextLines := fs.Int("context", 3, "lines of context around changes")
+		parseFlags(fs, os.Args[2:])
+
+		if *list {
+			ids, err := snapshot.List(snapshotDir())
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error listing snapshots: %v\n", err)
+				os.Exit(1)
+			}
+			if len(ids) == 0 {
+				fmt.Println("No snapshots yet. Snapshots are taken at the start of each iterate/iterate-loop run or with 'cursor-iter snapshot'.")
+				return
+			}
+			for _, id := range ids {
+				fmt.Println(id)
+			}
+			return
+		}
+
+		id, err := snapshot.Resolve(snapshotDir(), *since)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		before, err := snapshot.Load(snapshotDir(), id)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+
+		changed := 0
+		paths := controlFilePaths()
+		for _, name := range controlFileNames {
+			oldContent, hadOld := before[name]
+			newData, readErr := os.ReadFile(paths[name])
+			// Compare encrypted files by their content when the key is at hand
+			if plain, err := openControlData([]byte(oldContent)); err == nil {
+				oldContent = string(plain)
+			}
+			if plain, err := openControlData(newData); err == nil {
+				newData = plain
+			}
+			if !hadOld && readErr != nil {
+				continue
+			}
+			oldName := fmt.Sprintf("a/%s/%s", id, name)
+			newName := "b/" + name
+			if !hadOld {
+				oldName = "/dev/null"
+			}
+			if readErr != nil {
+				newName = "/dev/null"
+			}
+			if d := diff.Unified(oldName, newName, oldContent, string(newData), *contextLines); d != "" {
+				fmt.Print(d)
+				changed++
+			}
+		}
+		if changed == 0 {
+			fmt.Printf("No control file changes since snapshot %s\n", id)
+		}
+	case "archive-completed":
+		fs := flag.NewFlagSet("archive-completed", flag.ExitOnError)
+		file := fs.String("file", resolveTasksFile(), "tasks file")
+		progressFile := fs.String("progress", resolveProgressFile(), "progress file")
+		outdir := fs.String("outdir", getControlFilePath("completed_tasks"), "archive directory")
+		milestone := fs.String("milestone", "", "only archive tasks of this milestone")
+		before := fs.String("before", "", "only archive tasks completed before this date (2006-01-02)")
+		label := fs.String("label", "", "only archive tasks with this label, e.g. type:feature")
+		queue := fs.Bool("queue", true, "while iterate-loop runs, leave the archive for it to do once its agents finish instead of refusing")
+		dbg := fs.Bool("debug", debug, "enable verbose logging")
+		parseFlags(fs, os.Args[2:])
+		if *dbg {
+			fmt.Printf("[%s] archiving completed from %s and %s to %s\n", ts(), *file, *progressFile, *outdir)
+		}
+		beforeTime, err := tasks.ParseArchiveBefore(*before)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		filter := tasks.ArchiveFilter{Milestone: *milestone, Before: beforeTime, Label: *label, Defaults: configLabelDefaults()}
+
+		// A running loop rewrites progress.md, and so do its agents; the
+		// archive waits until none of them is running
+		release, loop, err := lockControlFiles(lockArchive, "")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		if loop != nil {
+			if !*queue {
+				fmt.Fprintf(os.Stderr, "error: %s is running and rewrites progress.md; archiving now could lose its changes. Run archive-completed with --queue to archive once its agents finish, or stop the loop first\n", loop)
+				os.Exit(1)
+			}
+			q := queuedArchive{TasksFile: *file, ProgressFile: *progressFile, Outdir: *outdir, Milestone: *milestone, Before: *before, Label: *label, QueuedAt: time.Now()}
+			if err := queueArchive(q); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("[%s] 🗄️ %s is running; queued the archive. The loop starts no new tasks until it has archived, once its running agents finish\n", ts(), loop)
+			return
+		}
+		dropQueuedArchive()
+		err = runArchive(*file, *progressFile, *outdir, filter)
+		release()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	case "remove-task":
+		fs := flag.NewFlagSet("remove-task", flag.ExitOnError)
+		file := fs.String("file", resolveTasksFile(), "tasks file")
+		progressFile := fs.String("progress", resolveProgressFile(), "progress file")
+		title := fs.String("task", "", "title of the task to remove")
+		reason := fs.String("reason", "", "why the task was removed")
+		parseFlags(fs, os.Args[2:])
+		if *title == "" {
+			fmt.Fprintf(os.Stderr, "error: --task is required\n")
+			os.Exit(1)
+		}
+		if err := trashTask(*file, *progressFile, *title, *reason); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("[%s] 🗑️ Moved '%s' to the trash. Restore it with 'cursor-iter trash restore --task \"%s\"'\n", ts(), *title, *title)
+		for _, dependent := range dependentTasks(*file, *title) {
+			fmt.Printf("[%s] ⚠️ Warning: '%s' depends on '%s', which no longer holds it up\n", ts(), dependent, *title)
+		}
+		if cutoff, err := trashCutoff(envOr("TRASH_RETENTION", defaultTrashRetention), time.Now()); err == nil {
+			if purged, _ := purgeTrash("", cutoff); len(purged) > 0 {
+				fmt.Printf("[%s] 🔥 Purged %d task(s) past the trash retention\n", ts(), len(purged))
+			}
+		}
+	case "edit-task":
+		fs := flag.NewFlagSet("edit-task", flag.ExitOnError)
+		file := fs.String("file", resolveTasksFile(), "tasks file")
+		progressFile := fs.String("progress", resolveProgressFile(), "progress file")
+		title := fs.String("task", "", "title of the task to edit")
+		newTitle := fs.String("title", "", "new title; progress.md entries and dependencies on the task follow it")
+		taskContext := fs.String("context", "", "new context line")
+		criteria := fs.String("criteria", "", "comma-separated acceptance criteria replacing the task's; criteria kept stay ticked")
+		labels := fs.String("labels", "", "comma-separated labels replacing the task's; empty removes them")
+		parseFlags(fs, os.Args[2:])
+		if *title == "" {
+			fmt.Fprintf(os.Stderr, "error: --task is required\n")
+			os.Exit(1)
+		}
+		edit := tasks.TaskEdit{Title: *newTitle, Context: *taskContext}
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "criteria":
+				edit.Criteria = append([]string{}, splitItems(*criteria)...)
+			case "labels":
+				edit.Labels = append([]string{}, splitItems(*labels)...)
+			}
+		})
+		if edit.Title == "" && edit.Context == "" && edit.Criteria == nil && edit.Labels == nil {
+			fmt.Fprintf(os.Stderr, "error: nothing to change; give --title, --context, --criteria or --labels\n")
+			os.Exit(1)
+		}
+		if err := editTask(*file, *progressFile, *title, edit); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		edited := *title
+		if strings.TrimSpace(edit.Title) != "" {
+			edited = strings.TrimSpace(edit.Title)
+		}
+		fmt.Printf("[%s] ✏️ Updated '%s' in %s\n", ts(), edited, *file)
+	case "prioritize":
+		fs := flag.NewFlagSet("prioritize", flag.ExitOnError)
+		file := fs.String("file", resolveTasksFile(), "tasks file")
+		progressFile := fs.String("progress", resolveProgressFile(), "progress file")
+		pathList := fs.String("paths", "", "comma-separated paths, directories (src/payments/...) or globs of the failing area")
+		hotfix := fs.String("hotfix", "", "also add a hotfix task with this title at the top of tasks.md")
+		incident := fs.String("incident", "", "incident summary for the hotfix task, e.g. 'INC-42: refunds ran twice'")
+		dryRun := fs.Bool("dry-run", false, "show the new order without changing tasks.md")
+		parseFlags(fs, os.Args[2:])
+		var paths []string
+		for _, p := range strings.Split(*pathList, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				paths = append(paths, p)
+			}
+		}
+		if len(paths) == 0 {
+			fmt.Fprintf(os.Stderr, "error: --paths is required\n")
+			os.Exit(1)
+		}
+		bumped, err := prioritizePaths(*file, *progressFile, paths, *hotfix, *incident, *dryRun)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		verb := "Moved"
+		if *dryRun {
+			verb = "Would move"
+		}
+		if *hotfix != "" {
+			if *dryRun {
+				fmt.Printf("[%s] 🚑 Would add hotfix task '%s' at the top of %s\n", ts(), *hotfix, *file)
+			} else {
+				fmt.Printf("[%s] 🚑 Added hotfix task '%s' at the top of %s\n", ts(), *hotfix, *file)
+			}
+		}
+		if len(bumped) == 0 {
+			fmt.Printf("[%s] ℹ️  No pending tasks touch %s\n", ts(), strings.Join(paths, ", "))
+			return
+		}
+		fmt.Printf("[%s] ⏫ %s %d pending task(s) to the front of %s:\n", ts(), verb, len(bumped), *file)
+		printBumped(bumped)
+	case "serve":
+		fs := flag.NewFlagSet("serve", flag.ExitOnError)
+		addr := fs.String("addr", envOr("SERVE_ADDR", "127.0.0.1:8787"), "address to serve the API on")
+		token := fs.String("token", envOr("SERVE_TOKEN", ""), "require this bearer token on every request (required off localhost)")
+		start := fs.Bool("start", false, "start the iterate loop right away")
+		shutdownGrace := fs.Duration("shutdown-grace", envDuration("SHUTDOWN_GRACE", 10*time.Minute), "how long a paused loop lets running agents finish before stopping them")
+		parseFlags(fs, os.Args[2:])
+		// Flags after -- go to iterate-loop, and may override the grace period
+		loopArgs := append([]string{"--shutdown-grace=" + shutdownGrace.String()}, fs.Args()...)
+		if err := runServe(*addr, *token, loopArgs, *start); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	case "api-spec":
+		fs := flag.NewFlagSet("api-spec", flag.ExitOnError)
+		parseFlags(fs, os.Args[2:])
+		doc, err := serveAPI.OpenAPI()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(doc)
+	case "editor-setup":
+		fs := flag.NewFlagSet("editor-setup", flag.ExitOnError)
+		dir := fs.String("dir", ".vscode", "directory to write tasks.json and launch.json to")
+		bin := fs.String("binary", "cursor-iter", "command the editor runs cursor-iter with")
+		addr := fs.String("addr", envOr("SERVE_ADDR", "127.0.0.1:8787"), "address the serve task listens on and the API tasks call")
+		force := fs.Bool("force", false, "overwrite tasks.json and launch.json when they aren't plain JSON")
+		parseFlags(fs, os.Args[2:])
+		paths, err := editorSetup(*dir, *bin, *addr, *force)
+		for _, p := range paths {
+			fmt.Printf("[%s] 🧩 Wrote %s\n", ts(), p)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("[%s] 💡 Run them with 'Tasks: Run Task'; for 'iterate selected task', select a task title in tasks.md first\n", ts())
+	case "agents":
+		if len(os.Args) < 3 {
+			fmt.Fprintf(os.Stderr, "usage: cursor-iter agents list [flags]\n")
+			os.Exit(1)
+		}
+		sub := os.Args[2]
+		fs := flag.NewFlagSet("agents "+sub, flag.ExitOnError)
+		since := fs.String("since", "30d", "count runs since a duration ago (30d, 12h) or a date (2025-01-31)")
+		format := fs.String("format", "table", "output format: table or json")
+		parseFlags(fs, os.Args[3:])
+
+		if sub != "list" {
+			fmt.Fprintf(os.Stderr, "error: unknown agents command %q, want list\n", sub)
+			os.Exit(1)
+		}
+		sinceTime, err := parseSince(*since, time.Now())
+		if err == nil {
+			err = listAgents(os.Stdout, *format, sinceTime)
+		}
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "error reading %s: %v\n", *file, err)
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
 			os.Exit(1)
 		}
+	case "trash":
+		if len(os.Args) < 3 {
+			fmt.Fprintf(os.Stderr, "usage: cursor-iter trash list|restore|purge [flags]\n")
+			os.Exit(1)
+		}
+		sub := os.Args[2]
+		fs := flag.NewFlagSet("trash "+sub, flag.ExitOnError)
+		file := fs.String("file", resolveTasksFile(), "tasks file")
+		progressFile := fs.String("progress", resolveProgressFile(), "progress file")
+		title := fs.String("task", "", "title of the trashed task")
+		retention := fs.String("retention", envOr("TRASH_RETENTION", defaultTrashRetention), "how long trashed tasks are kept (e.g. 30d)")
+		all := fs.Bool("all", false, "purge every trashed task")
+		parseFlags(fs, os.Args[3:])
 
-		// Read progress.md (create if doesn't exist)
-		progressContent, err := os.ReadFile(*progressFile)
+		now := time.Now()
+		cutoff, err := trashCutoff(*retention, now)
 		if err != nil {
-			// If progress.md doesn't exist, create an empty one
-			progressContent = []byte("# Progress Log\n\n## Completed Tasks\n\n")
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
 		}
-
-		report := tasks.StatusReportWithProgress(string(taskContent), string(progressContent))
-		fmt.Println(report)
-	case "validate-tasks":
-		fs := flag.NewFlagSet("validate-tasks", flag.ExitOnError)
-		file := fs.String("file", resolveTasksFile(), "tasks file")
-		fix := fs.Bool("fix", false, "attempt to fix structure issues")
-		dbg := fs.Bool("debug", debug, "enable verbose logging")
-		_ = fs.Parse(os.Args[2:])
-		if *dbg {
-			fmt.Printf("[%s] validate-tasks reading %s\n", ts(), *file)
+		switch sub {
+		case "list":
+			printTrash(os.Stdout, now.Sub(cutoff))
+		case "restore":
+			if *title == "" {
+				fmt.Fprintf(os.Stderr, "error: --task is required\n")
+				os.Exit(1)
+			}
+			if err := restoreTask(*file, *progressFile, *title); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("[%s] ♻️ Restored '%s' to %s\n", ts(), *title, *file)
+		case "purge":
+			if *all {
+				cutoff = now.Add(time.Minute)
+			}
+			purged, err := purgeTrash(*title, cutoff)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			if *title != "" && len(purged) == 0 {
+				fmt.Fprintf(os.Stderr, "error: no trashed task %q\n", *title)
+				os.Exit(1)
+			}
+			fmt.Printf("[%s] 🔥 Purged %d task(s) from the trash\n", ts(), len(purged))
+		default:
+			fmt.Fprintf(os.Stderr, "unknown trash command %q, want list, restore or purge\n", sub)
+			os.Exit(1)
 		}
-		content, err := os.ReadFile(*file)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "error reading %s: %v\n", *file, err)
+	case "audit-log":
+		if len(os.Args) < 3 {
+			fmt.Fprintf(os.Stderr, "usage: cursor-iter audit-log export|verify [flags]\n")
 			os.Exit(1)
 		}
-
-		if *fix {
-			fixedContent, result := tasks.ValidateAndFixTasksStructure(string(content))
-			if !result.Valid {
-				fmt.Fprintf(os.Stderr, "Structure validation failed:\n")
-				for _, err := range result.Errors {
-					fmt.Fprintf(os.Stderr, "  ERROR: %s\n", err)
-				}
+		sub := os.Args[2]
+		fs := flag.NewFlagSet("audit-log "+sub, flag.ExitOnError)
+		fromSpec := fs.String("from", "", "only export actions from this date (2006-01-02) or RFC 3339 time")
+		toSpec := fs.String("to", "", "only export actions up to this date, inclusive, or before this RFC 3339 time")
+		format := fs.String("format", "jsonl", "export format: jsonl (verifiable) or markdown")
+		output := fs.String("output", "", "write the export to this file instead of stdout")
+		parseFlags(fs, os.Args[3:])
+
+		switch sub {
+		case "export":
+			n, err := writeAuditExport(*output, *format, *fromSpec, *toSpec)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
 				os.Exit(1)
 			}
-			if len(result.Warnings) > 0 {
-				fmt.Printf("Warnings:\n")
-				for _, warning := range result.Warnings {
-					fmt.Printf("  WARNING: %s\n", warning)
-				}
+			if *output != "" {
+				fmt.Printf("[%s] 🧾 Exported %d action(s) to %s\n", ts(), n, *output)
 			}
-			if err := os.WriteFile(*file, []byte(fixedContent), 0644); err != nil {
-				fmt.Fprintf(os.Stderr, "error writing fixed content: %v\n", err)
+		case "verify":
+			if fs.NArg() != 1 {
+				fmt.Fprintf(os.Stderr, "usage: cursor-iter audit-log verify <export.jsonl>\n")
 				os.Exit(1)
 			}
-			fmt.Printf("✅ Fixed tasks.md structure\n")
-		} else {
-			result := tasks.ValidateTasksStructure(string(content))
-			if result.Valid {
-				fmt.Printf("✅ tasks.md structure is valid\n")
-			} else {
-				fmt.Fprintf(os.Stderr, "❌ Structure validation failed:\n")
-				for _, err := range result.Errors {
-					fmt.Fprintf(os.Stderr, "  ERROR: %s\n", err)
-				}
+			f, err := os.Open(fs.Arg(0))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
 				os.Exit(1)
 			}
-			if len(result.Warnings) > 0 {
-				fmt.Printf("Warnings:\n")
-				for _, warning := range result.Warnings {
-					fmt.Printf("  WARNING: %s\n", warning)
-				}
+			defer f.Close()
+			h, err := audit.Verify(f)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "❌ %s failed verification: %v\n", fs.Arg(0), err)
+				os.Exit(1)
 			}
+			fmt.Printf("✅ %s is intact: %d record(s), head %s\n", fs.Arg(0), h.Records, h.Head)
+		default:
+			fmt.Fprintf(os.Stderr, "unknown audit-log command %q, want export or verify\n", sub)
+			os.Exit(1)
 		}
-	case "archive-completed":
-		fs := flag.NewFlagSet("archive-completed", flag.ExitOnError)
-		file := fs.String("file", resolveTasksFile(), "tasks file")
-		progressFile := fs.String("progress", resolveProgressFile(), "progress file")
-		outdir := fs.String("outdir", getControlFilePath("completed_tasks"), "archive directory")
+	case "task-note":
+		fs := flag.NewFlagSet("task-note", flag.ExitOnError)
+		title := fs.String("task", "", "title of the completed task")
+		useCodex := fs.Bool("codex", false, "use codex CLI with gpt-5-codex model")
+		useClaude := fs.Bool("claude", false, "use the claude CLI (Claude Code)")
+		model := fs.String("model", envOr("MODEL", "auto"), "cursor-agent, codex (gpt-5-codex) or claude (sonnet, opus) model")
+		notesDir := fs.String("notes-dir", envOr("TASK_NOTES_DIR", notes.DefaultDir), "directory for task notes")
 		dbg := fs.Bool("debug", debug, "enable verbose logging")
-		_ = fs.Parse(os.Args[2:])
-		if *dbg {
-			fmt.Printf("[%s] archiving completed from %s and %s to %s\n", ts(), *file, *progressFile, *outdir)
+		parseFlags(fs, os.Args[2:])
+		agentBackend := primaryBackend(*useCodex, *useClaude)
+		if *title == "" {
+			fmt.Fprintf(os.Stderr, "error: --task is required\n")
+			os.Exit(1)
 		}
-
-		// Read tasks.md
-		taskContent, err := os.ReadFile(*file)
+		current, _ := os.ReadFile(resolveTasksFile())
+		taskNotes := newTaskNotes(true, *notesDir, agentBackend, *model, *dbg)
+		path, err := taskNotes.WriteFromJournal(*title, tasks.ExtractTaskDetails(string(current), *title))
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "error reading %s: %v\n", *file, err)
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
 			os.Exit(1)
 		}
-
-		// Read progress.md
-		progressContent, readErr := os.ReadFile(*progressFile)
-		if readErr != nil {
-			fmt.Fprintf(os.Stderr, "error reading %s: %v\n", *progressFile, readErr)
+		fmt.Printf("[%s] 📝 Wrote task note %s\n", ts(), path)
+	case "sign-off":
+		fs := flag.NewFlagSet("sign-off", flag.ExitOnError)
+		title := fs.String("task", "", "title of the task held for sign-off")
+		progressFile := fs.String("progress", resolveProgressFile(), "progress file")
+		by := fs.String("by", envOr("USER", "unknown"), "who signs the task off, recorded in its review file")
+		parseFlags(fs, os.Args[2:])
+		if *title == "" {
+			fmt.Fprintf(os.Stderr, "error: --task is required\n")
 			os.Exit(1)
 		}
-
-		// Archive completed tasks
-		// 1. Move completed tasks from progress.md to archive file
-		// 2. Remove completed tasks from tasks.md
-		archived, remainingProgress, updatedTasks, archiveFile, err := tasks.ArchiveCompletedTasks(
-			string(taskContent),
-			string(progressContent),
-			*outdir,
-		)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "error archiving: %v\n", err)
+		if err := signOff(*progressFile, *title, *by); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
 			os.Exit(1)
 		}
-
-		// Update tasks.md (remove completed tasks)
-		if err := os.WriteFile(*file, []byte(updatedTasks), 0644); err != nil {
-			fmt.Fprintf(os.Stderr, "error writing tasks: %v\n", err)
+		fmt.Printf("[%s] ✅ Signed off '%s'; the review is recorded in %s\n", ts(), *title, reviewPath(*title))
+	case "show-task":
+		fs := flag.NewFlagSet("show-task", flag.ExitOnError)
+		file := fs.String("file", resolveTasksFile(), "tasks file")
+		progressFile := fs.String("progress", resolveProgressFile(), "progress file")
+		parseFlags(fs, os.Args[2:])
+		ref := strings.Join(fs.Args(), " ")
+		if strings.TrimSpace(ref) == "" {
+			fmt.Fprintf(os.Stderr, "usage: cursor-iter show-task [--file F] \"Title or ID\"\n")
 			os.Exit(1)
 		}
-
-		// Update progress.md (remove completed tasks, keep in-progress)
-		if err := os.WriteFile(*progressFile, []byte(remainingProgress), 0644); err != nil {
-			fmt.Fprintf(os.Stderr, "error writing progress: %v\n", err)
+		content, err := os.ReadFile(*file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
 			os.Exit(1)
 		}
-
-		// Create archive directory if it doesn't exist
-		archiveDir := filepath.Dir(archiveFile)
-		if err := os.MkdirAll(archiveDir, 0755); err != nil {
-			fmt.Fprintf(os.Stderr, "error creating archive directory: %v\n", err)
+		title, err := resolveTaskRef(string(content), ref)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
 			os.Exit(1)
 		}
-
-		// Write archive file
-		if err := os.WriteFile(archiveFile, []byte(archived), 0644); err != nil {
-			fmt.Fprintf(os.Stderr, "error writing archive: %v\n", err)
+		progress, _ := os.ReadFile(*progressFile)
+		entries, _ := journal.Read(journalPath())
+		decisions, _ := journal.ReadDecisions(triageLogPath())
+		printTask(os.Stdout, string(content), string(progress), title, entries, decisions, time.Now())
+	case "complete-task":
+		fs := flag.NewFlagSet("complete-task", flag.ExitOnError)
+		file := fs.String("file", resolveTasksFile(), "tasks file")
+		progressFile := fs.String("progress", resolveProgressFile(), "progress file")
+		title := fs.String("task", "", "title of the task finished by hand")
+		notes := fs.String("notes", "completed manually", "notes for the completion entry")
+		by := fs.String("by", envOr("USER", "unknown"), "who finished the task, recorded with the completion")
+		checkAll := fs.Bool("check-all", false, "tick the task's unchecked criteria instead of refusing to complete it")
+		deferCategories := fs.String("defer-categories", envOr("DEFER_CATEGORIES", ""), "criteria categories that may stay unchecked (e.g. docs,perf)")
+		priceSpec := fs.String("prices", envOr("MODEL_PRICES", ""), "USD per million tokens by model, for the summary of a feature this task completes")
+		parseFlags(fs, os.Args[2:])
+		if *title == "" {
+			fmt.Fprintf(os.Stderr, "error: --task is required\n")
 			os.Exit(1)
 		}
-
-		fmt.Printf("✅ Archived completed tasks to %s\n", archiveFile)
-		fmt.Printf("✅ Removed completed tasks from tasks.md\n")
-		fmt.Printf("✅ Removed completed tasks from progress.md (kept in-progress tasks)\n")
+		prices := mustPrices(*priceSpec)
+		progress, _ := os.ReadFile(*progressFile)
+		running := tasks.IsTaskInProgress(string(progress), strings.TrimSpace(*title))
+		ticked, err := completeTask(*file, *progressFile, *title, *notes, *by, tasks.ParseCompletionPolicy(*deferCategories), *checkAll)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(ticked) > 0 {
+			fmt.Printf("[%s] ☑️ Ticked %d criteria: %s\n", ts(), len(ticked), strings.Join(ticked, "; "))
+		}
+		fmt.Printf("[%s] ✅ Completed '%s' by hand (%s); it won't be dispatched again\n", ts(), strings.TrimSpace(*title), *by)
+		if running {
+			fmt.Printf("[%s] ⚠️ Warning: '%s' was in progress; stop its agent if one is still running\n", ts(), strings.TrimSpace(*title))
+		}
+		deliverFeature(*file, *progressFile, strings.TrimSpace(*title), prices)
 	case "iterate-init":
 		fs := flag.NewFlagSet("iterate-init", flag.ExitOnError)
-		model := fs.String("model", envOr("MODEL", "auto"), "cursor-agent model or codex model (gpt-5-codex)")
+		model := fs.String("model", envOr("MODEL", "auto"), "cursor-agent, codex (gpt-5-codex) or claude (sonnet, opus) model")
 		useCodex := fs.Bool("codex", false, "use codex CLI with gpt-5-codex model")
+		useClaude := fs.Bool("claude", false, "use the claude CLI (Claude Code)")
 		dbg := fs.Bool("debug", debug, "enable verbose logging")
-		_ = fs.Parse(os.Args[2:])
-		
+		showFull := fs.Bool("show-full-prompts", showFullPrompts, "print full prompts in debug logs instead of their hash and path")
+		embeddedPrompts := fs.Bool("embedded-prompts", envOr("EMBEDDED_PROMPTS", "") != "", "use the prompts built into cursor-iter instead of fetching them from GitHub")
+		promptMirror := fs.String("prompt-mirror", envOr("PROMPT_MIRROR", ""), "comma-separated URLs to fetch prompts from before GitHub and jsDelivr, e.g. https://mirror.example.com/prompts/{file}")
+		offline := fs.Bool("offline", envOr("CURSOR_ITER_OFFLINE", "") != "", "never use the network: built-in prompts only, no spec URLs")
+		parseFlags(fs, os.Args[2:])
+		agentBackend := primaryBackend(*useCodex, *useClaude)
+
 		// Ensure .cursor-iter directory exists
 		if err := ensureCursorIterDir(); err != nil {
 			fmt.Fprintf(os.Stderr, "failed to create %s directory: %v\n", CursorIterDir, err)
 			os.Exit(1)
 		}
-		
+
 		promptFile := getControlFilePath("prompts/initialize-iteration-universal.md")
 
-		// Try to fetch from GitHub if not present locally
-		if err := fetchPromptFromGitHub(promptFile); err != nil {
+		// Fetch the prompt if it isn't present locally
+		if err := fetchPrompt(promptFile, newPromptSource(*embeddedPrompts, *offline, *promptMirror)); err != nil {
 			fmt.Fprintf(os.Stderr, "failed to fetch prompt: %v\n", err)
 			os.Exit(1)
 		}
@@ -468,40 +2581,98 @@ func main() {
 			os.Exit(1)
 		}
 
-		// Set default model for codex if not specified
-		agentModel := *model
-		if *useCodex && *model == "auto" {
-			agentModel = "gpt-5-codex"
-		}
+		agentModel := runner.DefaultModel(agentBackend, *model)
 
 		if *dbg {
-			if *useCodex {
-				fmt.Printf("[%s] iterate-init using codex model=%s, prompt=%s\n", ts(), agentModel, promptFile)
-			} else {
-				fmt.Printf("[%s] iterate-init using cursor-agent model=%s, prompt=%s\n", ts(), agentModel, promptFile)
-			}
+			fmt.Printf("[%s] iterate-init using %s model=%s, prompt=%s\n", ts(), agentBackend, agentModel, promptFile)
 		}
 
-		if *useCodex {
-			if err := runner.CodexWithDebug(*dbg, agentModel, string(data)); err != nil {
-				os.Exit(1)
-			}
-		} else {
-			if err := runner.CursorAgentWithDebug(*dbg, "--print", "--force", "--model", agentModel, string(data)); err != nil {
-				os.Exit(1)
-			}
+		logPrompt(string(data), *dbg, *showFull)
+		opts, flush := agentOptions(*dbg)
+		var initErr error
+		switch agentBackend {
+		case runner.BackendCodex:
+			initErr = runner.CodexWithOptions(opts, agentModel, string(data))
+		case runner.BackendClaude:
+			initErr = runner.ClaudeWithOptions(opts, agentModel, string(data))
+		default:
+			initErr = runner.CursorAgentWithOptions(opts, "--print", "--force", "--model", agentModel, string(data))
+		}
+		flush()
+		if initErr != nil {
+			os.Exit(1)
 		}
 	case "iterate":
 		fs := flag.NewFlagSet("iterate", flag.ExitOnError)
 		useCodex := fs.Bool("codex", false, "use codex CLI with gpt-5-codex model")
-		model := fs.String("model", envOr("MODEL", "auto"), "cursor-agent model or codex model (gpt-5-codex)")
+		useClaude := fs.Bool("claude", false, "use the claude CLI (Claude Code)")
+		model := fs.String("model", envOr("MODEL", "auto"), "cursor-agent, codex (gpt-5-codex) or claude (sonnet, opus) model")
 		maxInProgress := fs.Int("max-in-progress", 10, "maximum number of in-progress tasks allowed")
+		taskTitle := fs.String("task", "", "work on this task, by title or ID, instead of the first one in progress or the next pending one")
+		fallback := fs.String("fallback", envOr("AGENT_FALLBACK", ""), "comma-separated backends to fall back to on failure (e.g. codex)")
+		fallbackAfter := fs.Int("fallback-after", 2, "failures on a backend before falling back to the next one")
+		deferCategories := fs.String("defer-categories", envOr("DEFER_CATEGORIES", ""), "criteria categories that may be deferred (e.g. docs,perf)")
+		projectType := fs.String("project-type", envOr("PROJECT_TYPE", project.Auto), "kind of repository: code, docs, infra or auto to detect it")
+		updateTaskPaths := fs.Bool("update-task-paths", envOr("UPDATE_TASK_PATHS", "") != "", "rewrite renamed paths in a task's Files to Modify line before dispatch")
+		commitPolicy := fs.String("commit-policy", envOr("COMMIT_POLICY", ""), "commit message rules, e.g. conventional or types=feat,fix;scopes=api;max-subject=72")
+		commitFix := fs.String("commit-fix", envOr("COMMIT_FIX", commitFixAmend), "how to fix commit policy violations: amend or instruct")
+		strictFiles := fs.Bool("strict-files", envOr("STRICT_FILES", "") != "", "revert changes to files outside a task's Files to Modify list")
+		strictAllow := fs.String("strict-files-allow", envOr("STRICT_FILES_ALLOW", strings.Join(scope.DefaultAllowed, ",")), "comma-separated globs any task may change under --strict-files")
+		taskNotesOn := fs.Bool("task-notes", envOr("TASK_NOTES", "") != "", "write an implementation note for each completed task")
+		notesDir := fs.String("notes-dir", envOr("TASK_NOTES_DIR", notes.DefaultDir), "directory for task notes")
 		dbg := fs.Bool("debug", debug, "enable verbose logging")
-		_ = fs.Parse(os.Args[2:])
+		showFull := fs.Bool("show-full-prompts", showFullPrompts, "print full prompts in debug logs instead of their hash and path")
+		noProgress := fs.Bool("no-progress", envOr("NO_PROGRESS", "") != "", "don't show live progress lines for running tasks")
+		contextBudget := fs.Int("context-budget", 0, "token budget for the control files; above it agents read condensed copies (0 = no budget)")
+		completionFormat := fs.String("completion-format", envOr("COMPLETION_FORMAT", ""), "completion entry template for progress.md, e.g. '"+tasks.DefaultCompletionFormat+" | ticket: {ticket}'")
+		detectBlocked := fs.Bool("detect-blocked", envOr("DETECT_BLOCKED", "true") != "false", "move tasks to Blocked when the agent says it needs a human")
+		blockedPatterns := fs.String("blocked-patterns", envOr("BLOCKED_PATTERNS", getControlFilePath("blocked-patterns.txt")), "file of extra regular expressions, one per line, that mark a run as blocked")
+		agentAuthorSpec := fs.String("agent-author", envOr("AGENT_AUTHOR", ""), "git author and committer for agent commits, e.g. 'Cursor Autopilot <bot@example.com>'")
+		authorTaskID := fs.Bool("author-task-id", envOr("AGENT_AUTHOR_TASK_ID", "") == "true", "include the task in the committer name of agent commits")
+		ladderSpec := fs.String("prompt-ladder", envOr("PROMPT_LADDER", defaultPromptLadder), "prompt variants to try, in order, while the model declines a task (lean, rephrased, split; none to turn off)")
+		reviewerSpec := fs.String("reviewer", envOr("REVIEWER", ""), "review the diff of completed tasks with this model, backend:model or command:<shell command>")
+		reviewPolicy := fs.String("review-policy", envOr("REVIEW_POLICY", ""), "severities sent back for fixes or held for sign-off, e.g. fix=high;signoff=critical;rounds=2")
+		taskTimeout := fs.Duration("task-timeout", envDuration("TASK_TIMEOUT", 0), "kill an agent that runs longer than this and retry its task (0 = no limit)")
+		verifyFast := fs.String("verify-fast", envOr("VERIFY_FAST", ""), "quick checks run after the task completes, after the gates of the config file; a failure reopens the task")
+		verifyTimeout := fs.Duration("verify-timeout", envDuration("VERIFY_TIMEOUT", 30*time.Minute), "kill a check that runs longer than this (0 = no limit)")
+		maxAttempts := fs.Int("max-attempts", envInt("MAX_ATTEMPTS", defaultMaxAttempts), "failed runs in a row after which a task is moved to Failed instead of retried (0 = no limit)")
+		openPR := fs.Bool("open-pr", envOr("OPEN_PR", "") == "true", "put the commits of the completed task on a feature/<task> branch and open a pull request for it with gh")
+		prRemote := fs.String("pr-remote", envOr("PR_REMOTE", "origin"), "remote feature branches are pushed to under --open-pr")
+		prBase := fs.String("pr-base", envOr("PR_BASE", ""), "branch pull requests are opened against under --open-pr (default: the checked-out branch)")
+		priceSpec := fs.String("prices", envOr("MODEL_PRICES", ""), "USD per million tokens by model, for the cost recorded in progress.md, e.g. gpt-5-codex=1.25,sonnet=3")
+		restricted := fs.Bool("restricted", envOr("RESTRICTED", "") == "true", "only run the gate and reviewer commands of the config file's checksummed allowlist")
+		labelSpec := fs.String("label", "", "only pick tasks with one of these comma-separated labels, e.g. type:bugfix")
+		excludeLabelSpec := fs.String("exclude-label", "", "don't pick tasks with any of these comma-separated labels, e.g. type:feature")
+		parseFlags(fs, os.Args[2:])
+		agentBackend := primaryBackend(*useCodex, *useClaude)
+		fallbacks := mustParseChain(*fallback)
+		kind := mustProjectType(*projectType, *dbg)
+		mustPromptTemplates()
+		policy := completionPolicy(*deferCategories, kind)
+		commits := mustCommitChecker(*commitPolicy, *commitFix)
+		strict := newFileScopeChecker(*strictFiles, *strictAllow)
+		taskNotes := newTaskNotes(*taskNotesOn, *notesDir, agentBackend, *model, *dbg)
+		blockers := mustBlockerDetector(*detectBlocked, *blockedPatterns)
+		entryFormat := mustCompletionFormat(*completionFormat)
+		author := mustAgentAuthor(*agentAuthorSpec, *authorTaskID)
+		ladder := mustPromptLadder(*ladderSpec)
+		budget := mustRetryBudget(*maxAttempts)
+		reviewer := mustCodeReviewer(*reviewerSpec, *reviewPolicy, agentBackend, *dbg)
+		gates := qualityGates(*verifyFast)
+		perms := mustPermissions(*restricted)
+		perms.mustAllow(gates, "", *reviewerSpec, "")
+		checks := newVerifier(gates, "", 1, *verifyTimeout)
+		prs := mustPROpener(*openPR, *prRemote, *prBase)
+		prices := mustPrices(*priceSpec)
+		promptNotes := []string{tasks.DeferredCriteriaNote(policy), commits.PromptNote(), strict.PromptNote(), entryFormat.PromptNote(), checks.PromptNote()}
 
 		// Run the main iteration based on prompts/iterate.md
 		file := resolveTasksFile()
 		progressFile := resolveProgressFile()
+		runID := startRun(*dbg)
+		if *dbg {
+			fmt.Printf("[%s] 🆔 Run ID: %s\n", ts(), runID)
+		}
 
 		// Read tasks.md and progress.md
 		if *dbg {
@@ -524,7 +2695,7 @@ func main() {
 		if err != nil {
 			// If progress.md doesn't exist, create an empty one
 			progressContent = []byte("# Progress Log\n\n## Completed Tasks\n\n")
-			os.WriteFile(progressFile, progressContent, 0644)
+			atomicfile.WriteFile(progressFile, progressContent, 0644)
 			if *dbg {
 				fmt.Printf("[%s] 📝 Created new progress.md file\n", ts())
 			}
@@ -545,11 +2716,40 @@ func main() {
 			fmt.Printf("[%s] 📊 Found %d in-progress tasks (max allowed: %d)\n", ts(), inProgressCount, *maxInProgress)
 		}
 
+		// --label and --exclude-label narrow the tasks picked below; all
+		// in-progress tasks still count against --max-in-progress
+		labels := tasks.ParseLabelFilter(*labelSpec, *excludeLabelSpec)
+		labels.Defaults = configLabelDefaults()
+		pickContent, held := labels.KeepRunnable(taskContent, progressStr)
+		if !labels.IsZero() {
+			inProgressTasks = tasks.GetAllInProgressTasks(pickContent, progressStr)
+		}
+
 		var currentTask *tasks.Task
 		var taskToWork string
 
-		// First, check if there's an existing in-progress task
-		if len(inProgressTasks) > 0 {
+		if *taskTitle != "" {
+			// The task asked for, whatever else is in progress
+			named, status, err := namedTask(taskContent, progressStr, *taskTitle)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			currentTask = named
+			taskToWork = named.Title
+			if status == "in-progress" {
+				fmt.Printf("[%s] 🔄 Continuing in-progress task: '%s' (%d/%d criteria)\n", ts(), named.Title, named.ACChecked, named.ACTotal)
+			} else {
+				if err := updateControlFile(progressFile, func(content string) string {
+					progressStr = tasks.MarkTaskInProgress(content, named.Title)
+					return progressStr
+				}); err != nil {
+					fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not update progress: %v\n", ts(), err)
+					os.Exit(1)
+				}
+				fmt.Printf("[%s] 📝 Started new task: '%s'\n", ts(), named.Title)
+			}
+		} else if len(inProgressTasks) > 0 {
 			// Continue working on the first in-progress task
 			currentTask = inProgressTasks[0]
 			taskToWork = currentTask.Title
@@ -563,17 +2763,18 @@ func main() {
 			if *dbg {
 				fmt.Printf("[%s] 🔍 Looking for next pending task...\n", ts())
 			}
-			nextTask := tasks.GetNextPendingTaskWithProgress(taskContent, progressStr)
+			nextTask := configLabelDefaults().NextPendingTask(pickContent, progressStr)
 			if nextTask != nil {
 				if *dbg {
 					fmt.Printf("[%s] 🎯 Found next pending task: '%s'\n", ts(), nextTask.Title)
 					fmt.Printf("[%s] 📝 Marking task as in-progress in progress.md...\n", ts())
 				}
 				// Mark task as in-progress in progress.md (not tasks.md)
-				updatedProgress := tasks.MarkTaskInProgress(progressStr, nextTask.Title)
-
-				// Write the updated progress.md
-				if err := os.WriteFile(progressFile, []byte(updatedProgress), 0644); err != nil {
+				var updatedProgress string
+				if err := updateControlFile(progressFile, func(content string) string {
+					updatedProgress = tasks.MarkTaskInProgress(content, nextTask.Title)
+					return updatedProgress
+				}); err != nil {
 					fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not update progress: %v\n", ts(), err)
 					os.Exit(1)
 				} else {
@@ -595,6 +2796,13 @@ func main() {
 		}
 
 		if currentTask == nil {
+			if !labels.IsZero() {
+				fmt.Fprintf(os.Stderr, "[%s] ⚠️ No tasks %s available to work on\n", ts(), labels)
+				for _, title := range held {
+					fmt.Fprintf(os.Stderr, "[%s] ⏭️ '%s' waits on a task the labels leave out\n", ts(), title)
+				}
+				os.Exit(1)
+			}
 			fmt.Fprintf(os.Stderr, "[%s] ⚠️ No tasks available to work on\n", ts())
 			os.Exit(1)
 		}
@@ -612,105 +2820,93 @@ func main() {
 		if *dbg {
 			fmt.Printf("[%s] 📝 Building prompt for cursor-agent...\n", ts())
 		}
-		msg := fmt.Sprintf(`You are working on a specific task from the engineering iteration system.
-
-## Your Task
-
-%s
-
-## Instructions
-
-1. Review the control files for context (located in .cursor-iter/):
-   - .cursor-iter/architecture.md: System architecture and design
-   - .cursor-iter/decisions.md: Architectural Decision Records (ADRs)
-   - .cursor-iter/progress.md: Completed tasks and progress history
-   - .cursor-iter/test_plan.md: Testing strategy and coverage
-   - .cursor-iter/qa_checklist.md: Quality assurance requirements
-   - .cursor-iter/CHANGELOG.md: Change history
-   - .cursor-iter/context.md: Project context (if available)
-
-2. Implement the task following these steps:
-   - Plan your implementation approach
-   - Write the code with comprehensive logging and comments
-   - Create/update tests to verify functionality
-   - Run quality gates (linting, formatting, type checking, tests)
-   - Update documentation as needed
-   - Commit changes with conventional commit messages
-
-3. Track progress:
-   - Check off each acceptance criterion in .cursor-iter/tasks.md as you complete it
-   - When ALL criteria are checked, move the task from "## In Progress" to "## Completed Tasks" in .cursor-iter/progress.md
-   - Use format: "- ✅ [YYYY-MM-DD HH:MM] Task Title - completion notes"
-
-4. Quality Requirements:
-   - All tests must pass
-   - Code must pass linting and formatting checks
-   - Follow existing code patterns and conventions
-   - Add detailed code comments explaining complex logic
-   - Include logging for debugging and monitoring
-
-5. 🚨 CRITICAL: NEVER RUN LONG-RUNNING PROCESSES 🚨
-   STRICTLY FORBIDDEN COMMANDS - These will hang the agent:
-   - ❌ npm run dev / pnpm run dev / yarn dev - Dev servers
-   - ❌ npm start / pnpm start / yarn start - Application servers
-   - ❌ python manage.py runserver - Django dev server
-   - ❌ flask run / uvicorn / gunicorn - Python web servers
-   - ❌ go run (unless it completes immediately) - Go applications that don't exit
-   - ❌ cargo run (unless it completes immediately) - Rust applications that don't exit
-   - ❌ rails server / rails s - Rails dev server
-   - ❌ Any command that starts a server, daemon, or continuous process
-
-   ALLOWED: Build commands that complete and exit
-   - ✅ npm run build / pnpm build / yarn build - Build commands that exit
-   - ✅ go build - Compilation that exits
-   - ✅ cargo build - Compilation that exits
-   - ✅ Any test command that runs and completes
-
-   If a dev server is needed for testing:
-   - Document it in the README with manual start instructions
-   - Never run it in the agent - the human developer will run it manually
-   - Use build commands and unit tests instead
-
-## Important Notes
-
-- Focus ONLY on this specific task
-- .cursor-iter/tasks.md is a simple task list (no status emojis) - only check off acceptance criteria
-- .cursor-iter/progress.md tracks task status (in-progress and completed)
-- When all acceptance criteria are checked, move this task from "## In Progress" to "## Completed Tasks" in .cursor-iter/progress.md
-- Ensure all quality gates pass before marking complete
-- NEVER run dev servers or long-running processes - they will hang the agent
-
-Work on this task until all acceptance criteria are checked off and the task is moved to completed in .cursor-iter/progress.md.`, taskDetails)
-
-		// Set default model for codex if not specified
-		agentModel := *model
-		if *useCodex && *model == "auto" {
-			agentModel = "gpt-5-codex"
+		glossarySection := glossaryFor(taskDetails)
+		if *dbg && glossarySection != "" {
+			fmt.Printf("[%s] 📖 Injecting matching glossary entries into prompt\n", ts())
 		}
+		promptNotes = append(promptNotes, regroundTask(taskToWork, taskDetails, *updateTaskPaths, *dbg), contextBudgetNote(*contextBudget), reviewFollowUp(taskToWork), checksFollowUp(taskToWork), sealedFilesNote())
+		variant := ladder.Variant(taskToWork)
+		if variant != promptFull {
+			fmt.Printf("[%s] 🪜 The model declined earlier runs, sending the %s prompt\n", ts(), variant)
+		}
+		msg := variantPrompt(kind, variant, taskDetails, glossarySection, promptNotes...)
+
+		agentModel := runner.DefaultModel(agentBackend, *model)
 
 		// Log which task is about to be sent to cursor-agent
 		fmt.Printf("[%s] 🚀 Sending task to cursor-agent: '%s'\n", ts(), taskToWork)
 		if *dbg {
-			if *useCodex {
-				fmt.Printf("[%s] 🤖 Using codex (model: %s)\n", ts(), agentModel)
-			} else {
-				fmt.Printf("[%s] 🤖 Using cursor-agent (model: %s)\n", ts(), agentModel)
-			}
+			fmt.Printf("[%s] 🤖 Using %s (model: %s)\n", ts(), agentBackend, agentModel)
 			fmt.Printf("[%s] 📊 Task progress: %d/%d acceptance criteria completed\n", ts(), currentTask.ACChecked, currentTask.ACTotal)
 		}
 
-		// Run cursor-agent
-		var agentErr error
-		if *useCodex {
-			agentErr = runner.CodexWithDebug(*dbg, agentModel, msg)
-		} else {
-			agentErr = runner.CursorAgentWithDebug(*dbg, "--print", "--force", msg)
+		// Run the agent, falling back along the chain when it keeps erroring
+		chain := chainFrom(agentBackend, fallbacks)
+		attempts := 1
+		if len(chain) > 1 {
+			attempts = *fallbackAfter
+		}
+		if m := taskModel(taskDetails); m != "" {
+			agentModel = m
+		}
+		logPrompt(msg, *dbg, *showFull)
+		run := &TaskExecution{
+			TaskTitle:     taskToWork,
+			StartTime:     time.Now(),
+			Output:        stream.NewRingBuffer(stream.DefaultCapacity),
+			Backend:       chain[0],
+			Model:         agentModel,
+			HeadBefore:    gitHead(),
+			Labels:        configLabelDefaults().ParseLabels(taskDetails),
+			PromptVariant: variant,
+			PromptChars:   len(msg),
+			Env:           captureEnv(taskToWork),
+			Files:         tasks.ParseFileScope(taskDetails),
+		}
+		// The display takes over stdout, so create the agent's writers after it
+		stopProgress := startProgress(!*dbg && !*noProgress, func() []*TaskExecution { return []*TaskExecution{run} })
+		opts, flush := agentOptions(*dbg)
+		opts.Stdout = io.MultiWriter(opts.Stdout, run.Output)
+		opts.Stderr = io.MultiWriter(opts.Stderr, run.Output)
+		opts.Env = author.Env(taskToWork)
+		opts.Timeout = *taskTimeout
+		// Ctrl-C stops the agent with the processes it started
+		ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		opts.Context = ctx
+		sealed := sealedControlFiles()
+		usedBackend, agentErr := runner.RunChain(opts, chain, attempts, agentModel, msg)
+		stopSignals()
+		flush()
+		resealControlFiles(sealed)
+		stopProgress()
+		run.Output.Close()
+		run.EndTime = time.Now()
+
+		if errors.Is(agentErr, runner.ErrInterrupted) {
+			recordRun(runID, run, journal.OutcomeFailed, agentErr)
+			releaseInterrupted(progressFile, taskToWork)
+			os.Exit(exitInterrupted)
 		}
-
 		if agentErr != nil {
+			spent := false
+			if entry := recordRun(runID, run, journal.OutcomeFailed, agentErr); ladder.Exhausted(run, entry.Classification) {
+				blockRefused(progressFile, taskToWork)
+			} else {
+				spent = budget.Spent(progressFile, entry)
+			}
 			fmt.Fprintf(os.Stderr, "[%s] ⚠️ Iteration failed: %v\n", ts(), agentErr)
+			if errors.Is(agentErr, runner.ErrTimeout) && !spent {
+				fmt.Fprintf(os.Stderr, "[%s] ⏱️ '%s' stays in progress; the next iteration retries it\n", ts(), taskToWork)
+			}
 			os.Exit(1)
 		}
+		if usedBackend != chain[0] {
+			run.Backend = usedBackend
+			run.Model = "auto"
+		}
+		if usedBackend != chain[0] {
+			fmt.Printf("[%s] 🔀 Task run succeeded on fallback backend: %s\n", ts(), usedBackend)
+		}
 
 		// Check if the task is now complete
 		if *dbg {
@@ -734,9 +2930,44 @@ Work on this task until all acceptance criteria are checked off and the task is
 				fmt.Printf("[%s] 🔍 Checking if task '%s' is now marked as completed...\n", ts(), taskToWork)
 			}
 			taskCompleted := tasks.IsTaskCompletedAfterRun(newTaskContent, newProgressStr, taskToWork)
+			outcome := journal.OutcomeIncomplete
+			blocker := ""
+			if taskCompleted {
+				outcome = journal.OutcomeCompleted
+			} else {
+				blocker = blockers.Check(run)
+			}
+			entry := recordRun(runID, run, outcome, nil)
+			if note := commits.Check(run, true); note != "" {
+				fmt.Printf("[%s] 💡 Reword the commits above before pushing\n", ts())
+			}
+			strict.Check(run, nil)
 
 			if taskCompleted {
 				fmt.Printf("[%s] ✅ Task completed: %s\n", ts(), taskToWork)
+				if variant != promptFull {
+					fmt.Printf("[%s] 🪜 Completed with the %s prompt\n", ts(), variant)
+				}
+				if !checks.Fast(run, progressFile) {
+					fmt.Printf("[%s] 💡 Run 'iterate' again to fix the failing gate\n", ts())
+				} else if reviewer.Review(run, taskDetails, progressFile) {
+					taskNotes.WriteRun(run, taskDetails)
+					writeTaskCost(progressFile, taskToWork, prices)
+					writeTaskChurn(progressFile, taskToWork)
+					resolveTodo(taskDetails)
+					prs.openPR(run, file, progressFile)
+					deliverFeature(file, progressFile, taskToWork, prices)
+				} else {
+					fmt.Printf("[%s] 💡 Run 'iterate' again to address the review\n", ts())
+				}
+			} else if blocker != "" {
+				blockNeedsHuman(progressFile, taskToWork, blocker)
+			} else if ladder.Exhausted(run, entry.Classification) {
+				blockRefused(progressFile, taskToWork)
+			} else if budget.Spent(progressFile, entry) {
+				// Out of attempts, moved to Failed
+			} else if entry.Classification == journal.ClassRefusal && len(ladder) > 0 {
+				fmt.Printf("[%s] 🪜 The model declined the task; the next run sends the %s prompt\n", ts(), ladder.next([]journal.Entry{entry}, taskToWork))
 			} else {
 				fmt.Printf("[%s] ⚠️ Task not yet complete: %s - run 'iterate' again to continue\n", ts(), taskToWork)
 				if *dbg {
@@ -753,52 +2984,345 @@ Work on this task until all acceptance criteria are checked off and the task is
 	case "iterate-loop":
 		fs := flag.NewFlagSet("iterate-loop", flag.ExitOnError)
 		useCodex := fs.Bool("codex", false, "use codex CLI with gpt-5-codex model")
-		model := fs.String("model", envOr("MODEL", "auto"), "cursor-agent model or codex model (gpt-5-codex)")
+		useClaude := fs.Bool("claude", false, "use the claude CLI (Claude Code)")
+		model := fs.String("model", envOr("MODEL", "auto"), "cursor-agent, codex (gpt-5-codex) or claude (sonnet, opus) model")
 		maxInProgress := fs.Int("max-in-progress", 10, "maximum number of in-progress tasks allowed")
+		fallback := fs.String("fallback", envOr("AGENT_FALLBACK", ""), "comma-separated backends to fall back to on failure (e.g. codex)")
+		fallbackAfter := fs.Int("fallback-after", 2, "failed runs of a task on a backend before falling back to the next one")
+		taskLogsMode := fs.String("task-logs", envOr("TASK_LOGS", taskLogsAuto), "send each agent's output to its own log file: auto (when more than one task may run), on or off")
+		fairnessSpec := fs.String("fairness", envOr("FAIRNESS", tasks.FairnessFIFO), "how free slots are shared between milestones: fifo, interleave or reserve=<fraction>")
+		deferCategories := fs.String("defer-categories", envOr("DEFER_CATEGORIES", ""), "criteria categories that may be deferred (e.g. docs,perf)")
+		projectType := fs.String("project-type", envOr("PROJECT_TYPE", project.Auto), "kind of repository: code, docs, infra or auto to detect it")
+		updateTaskPaths := fs.Bool("update-task-paths", envOr("UPDATE_TASK_PATHS", "") != "", "rewrite renamed paths in a task's Files to Modify line before dispatch")
+		commitPolicy := fs.String("commit-policy", envOr("COMMIT_POLICY", ""), "commit message rules, e.g. conventional or types=feat,fix;scopes=api;max-subject=72")
+		commitFix := fs.String("commit-fix", envOr("COMMIT_FIX", commitFixAmend), "how to fix commit policy violations: amend or instruct")
+		strictFiles := fs.Bool("strict-files", envOr("STRICT_FILES", "") != "", "revert changes to files outside a task's Files to Modify list")
+		strictAllow := fs.String("strict-files-allow", envOr("STRICT_FILES_ALLOW", strings.Join(scope.DefaultAllowed, ",")), "comma-separated globs any task may change under --strict-files")
+		taskNotesOn := fs.Bool("task-notes", envOr("TASK_NOTES", "") != "", "write an implementation note for each completed task")
+		notesDir := fs.String("notes-dir", envOr("TASK_NOTES_DIR", notes.DefaultDir), "directory for task notes")
 		dbg := fs.Bool("debug", debug, "enable verbose logging")
-		_ = fs.Parse(os.Args[2:])
+		showFull := fs.Bool("show-full-prompts", showFullPrompts, "print full prompts in debug logs instead of their hash and path")
+		noProgress := fs.Bool("no-progress", envOr("NO_PROGRESS", "") != "", "don't show live progress lines for running tasks")
+		contextBudget := fs.Int("context-budget", 0, "token budget for the control files; above it agents read condensed copies (0 = no budget)")
+		completionFormat := fs.String("completion-format", envOr("COMPLETION_FORMAT", ""), "completion entry template for progress.md, e.g. '"+tasks.DefaultCompletionFormat+" | ticket: {ticket}'")
+		detectBlocked := fs.Bool("detect-blocked", envOr("DETECT_BLOCKED", "true") != "false", "move tasks to Blocked when the agent says it needs a human")
+		blockedPatterns := fs.String("blocked-patterns", envOr("BLOCKED_PATTERNS", getControlFilePath("blocked-patterns.txt")), "file of extra regular expressions, one per line, that mark a run as blocked")
+		agentAuthorSpec := fs.String("agent-author", envOr("AGENT_AUTHOR", ""), "git author and committer for agent commits, e.g. 'Cursor Autopilot <bot@example.com>'")
+		authorTaskID := fs.Bool("author-task-id", envOr("AGENT_AUTHOR_TASK_ID", "") == "true", "include the task in the committer name of agent commits")
+		ladderSpec := fs.String("prompt-ladder", envOr("PROMPT_LADDER", defaultPromptLadder), "prompt variants to try, in order, while the model declines a task (lean, rephrased, split; none to turn off)")
+		reviewerSpec := fs.String("reviewer", envOr("REVIEWER", ""), "review the diff of completed tasks with this model, backend:model or command:<shell command>")
+		reviewPolicy := fs.String("review-policy", envOr("REVIEW_POLICY", ""), "severities sent back for fixes or held for sign-off, e.g. fix=high;signoff=critical;rounds=2")
+		taskTimeout := fs.Duration("task-timeout", envDuration("TASK_TIMEOUT", 0), "kill an agent that runs longer than this and retry its task (0 = no limit)")
+		globalMax := fs.Int("global-max", envInt("GLOBAL_MAX_AGENTS", 0), "maximum concurrent agents across all repositories sharing the coordinator directory (0 = no limit)")
+		coordDir := fs.String("coordinator-dir", envOr("COORDINATOR_DIR", defaultCoordinatorDir()), "directory shared by the loops of all repositories")
+		priority := fs.Int("priority", envInt("REPO_PRIORITY", 0), "priority of this repository for global slots; higher goes first")
+		weight := fs.Float64("weight", 1, "share of the global slots relative to repositories of the same priority")
+		stagger := fs.Duration("stagger", 0, "delay between starting tasks")
+		raceThrottle := fs.Bool("race-throttle", envOr("RACE_THROTTLE", "true") != "false", "start fewer agents, further apart, while their startups keep racing on cli-config.json, and ramp back up once they stop")
+		fileClaims := fs.Bool("file-claims", envOr("FILE_CLAIMS", "true") != "false", "don't run tasks whose Files to Modify overlap at the same time")
+		worktreeMode := fs.String("worktree", envOr("WORKTREE", worktreeOff), "run each task in a git worktree and branch of its own: off, merge (merge the branch once the task completes) or pr (push it and open a pull request with gh)")
+		openPR := fs.Bool("open-pr", envOr("OPEN_PR", "") == "true", "put the commits of each completed task on a feature/<task> branch and open a pull request for it with gh")
+		prRemote := fs.String("pr-remote", envOr("PR_REMOTE", "origin"), "remote branches are pushed to under --open-pr and --worktree pr")
+		prBase := fs.String("pr-base", envOr("PR_BASE", ""), "branch pull requests are opened against under --open-pr (default: the checked-out branch)")
+		shutdownGrace := fs.Duration("shutdown-grace", envDuration("SHUTDOWN_GRACE", 0), "on SIGINT or SIGTERM, wait this long for running agents to finish before stopping them")
+		logFormat := fs.String("log-format", envOr("LOG_FORMAT", jsonlog.FormatText), "output format: text, or json for one event per line on stdout with the usual output on stderr")
+		metricsAddr := fs.String("metrics-addr", envOr("METRICS_ADDR", ""), "serve Prometheus metrics on /metrics at this address or port, e.g. :9464 (empty = off)")
+		useTUI := fs.Bool("tui", envOr("TUI", "") != "", "show a live dashboard of the tasks and agent output, with keys to pause the loop and skip or retry tasks")
+		verifyFast := fs.String("verify-fast", envOr("VERIFY_FAST", ""), "quick checks run after every completed task, after the gates of the config file, e.g. 'go build ./... && go vet ./...'; a failure reopens the task")
+		verifyFull := fs.String("verify-full", envOr("VERIFY_FULL", ""), "full test suite run after every --verify-batch completed tasks, e.g. 'go test ./...'; a failure reopens the task it is blamed on")
+		verifyBatch := fs.Int("verify-batch", envInt("VERIFY_BATCH", 5), "completed tasks per run of the --verify-full suite")
+		verifyTimeout := fs.Duration("verify-timeout", envDuration("VERIFY_TIMEOUT", 30*time.Minute), "kill a check that runs longer than this (0 = no limit)")
+		maxAttempts := fs.Int("max-attempts", envInt("MAX_ATTEMPTS", defaultMaxAttempts), "failed runs in a row after which a task is moved to Failed instead of retried (0 = no limit)")
+		notifyWebhook := fs.String("notify-webhook", envOr("NOTIFY_WEBHOOK", ""), "post task and loop events to this Slack, Discord or generic JSON webhook (empty = off)")
+		notifyOn := fs.String("notify-on", envOr("NOTIFY_ON", "all"), "comma-separated events to post: "+strings.Join(notify.AllEvents, ", ")+" or all")
+		notifyAfter := fs.Int("notify-after-failures", envInt("NOTIFY_AFTER_FAILURES", defaultNotifyAfterFailures), "failed runs in a row of a task before it is posted as failed")
+		priceSpec := fs.String("prices", envOr("MODEL_PRICES", ""), "USD per million tokens by model, for task costs and --budget, e.g. gpt-5-codex=1.25,sonnet=3")
+		spendBudget := fs.Float64("budget", envFloat("BUDGET", 0), "stop starting tasks once the loop's agents cost this many USD, and exit when the running ones finish (0 = no limit)")
+		maxChurn := fs.Int("max-churn", envInt("MAX_CHURN", 0), "stop starting tasks once the loop changed this many lines, and ask to go on when the running ones finish (0 = no limit)")
+		restricted := fs.Bool("restricted", envOr("RESTRICTED", "") == "true", "only run the gate and reviewer commands and post to the webhooks of the config file's checksummed allowlist")
+		var focusRefs taskFlags
+		fs.Var(&focusRefs, "task", "work only on this task, by title or ID, whatever its place in tasks.md; repeatable")
+		labelSpec := fs.String("label", "", "only work on tasks with one of these comma-separated labels, e.g. type:bugfix")
+		excludeLabelSpec := fs.String("exclude-label", "", "leave tasks with any of these comma-separated labels for later, e.g. type:feature")
+		parseFlags(fs, os.Args[2:])
+		setLogFormat(*logFormat)
+		if *useTUI && *logFormat == jsonlog.FormatJSON {
+			fmt.Fprintf(os.Stderr, "--tui can't be combined with --log-format %s\n", jsonlog.FormatJSON)
+			os.Exit(1)
+		}
+		agentBackend := primaryBackend(*useCodex, *useClaude)
+		fallbacks := mustParseChain(*fallback)
+		kind := mustProjectType(*projectType, *dbg)
+		mustPromptTemplates()
+		policy := completionPolicy(*deferCategories, kind)
+		commits := mustCommitChecker(*commitPolicy, *commitFix)
+		strict := newFileScopeChecker(*strictFiles, *strictAllow)
+		coordinator, err := newCoordinator(*globalMax, *coordDir, *priority, *weight)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to join the agent coordinator: %v\n", err)
+			os.Exit(1)
+		}
+		defer coordinator.Close()
+		fairness, err := tasks.ParseFairness(*fairnessSpec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid --fairness: %v\n", err)
+			os.Exit(1)
+		}
+		fairness.Defaults = configLabelDefaults()
+		taskNotes := newTaskNotes(*taskNotesOn, *notesDir, agentBackend, *model, *dbg)
+		blockers := mustBlockerDetector(*detectBlocked, *blockedPatterns)
+		entryFormat := mustCompletionFormat(*completionFormat)
+		author := mustAgentAuthor(*agentAuthorSpec, *authorTaskID)
+		ladder := mustPromptLadder(*ladderSpec)
+		budget := mustRetryBudget(*maxAttempts)
+		reviewer := mustCodeReviewer(*reviewerSpec, *reviewPolicy, agentBackend, *dbg)
+		gates := qualityGates(*verifyFast)
+		perms := mustPermissions(*restricted)
+		perms.mustAllow(gates, *verifyFull, *reviewerSpec, *notifyWebhook)
+		checks := newVerifier(gates, *verifyFull, *verifyBatch, *verifyTimeout)
+		trees := mustWorktrees(*worktreeMode, *prRemote)
+		if *openPR && trees != nil && trees.mode == worktreePR {
+			fmt.Fprintf(os.Stderr, "--open-pr and --worktree %s both open a pull request per task; use one of them\n", worktreePR)
+			os.Exit(1)
+		}
+		prs := mustPROpener(*openPR, *prRemote, *prBase)
 
 		// Parallel iteration loop - can run up to maxInProgress tasks concurrently
 		file := resolveTasksFile()
 		progressFile := resolveProgressFile()
 
-		// Set default model for codex if not specified
-		agentModel := *model
-		if *useCodex && *model == "auto" {
-			agentModel = "gpt-5-codex"
+		agentModel := runner.DefaultModel(agentBackend, *model)
+		loopNotifier = mustNotifier(*notifyWebhook, *notifyOn, *notifyAfter, budget, file, progressFile)
+		prices := mustPrices(*priceSpec)
+		loopSpend = mustSpendLimit(*spendBudget, prices)
+		loopChurn = mustChurnLimit(*maxChurn)
+
+		runID := startRun(*dbg)
+		fmt.Printf("[%s] 🚀 Starting iterate-loop with parallel execution (max concurrent: %d, run: %s)\n", ts(), *maxInProgress, runID)
+		eventLog.Log(jsonlog.LoopStarted, jsonlog.Fields{"run_id": runID, "max_active": *maxInProgress, "backend": string(agentBackend), "model": agentModel})
+		if author != nil {
+			fmt.Printf("[%s] 🤖 Agent commits are authored by %s\n", ts(), author)
+		}
+		if perms != nil {
+			fmt.Printf("[%s] 🔒 %s\n", ts(), perms)
+		}
+		if gates := checks.GateNames(); gates != "" {
+			fmt.Printf("[%s] 🚦 Quality gates: %s\n", ts(), gates)
+		}
+		if trees != nil {
+			fmt.Printf("[%s] 🌿 Each task runs in a worktree under %s; %s\n", ts(), worktreesDir(), trees)
+		}
+		if prs != nil {
+			fmt.Printf("[%s] 📬 %s\n", ts(), prs)
+		}
+		if loopNotifier != nil {
+			fmt.Printf("[%s] 🔔 %s\n", ts(), loopNotifier)
+		}
+		if loopSpend != nil {
+			fmt.Printf("[%s] 💰 %s\n", ts(), loopSpend)
+		}
+		if loopChurn != nil {
+			fmt.Printf("[%s] 🧯 %s\n", ts(), loopChurn)
 		}
-
-		fmt.Printf("[%s] 🚀 Starting iterate-loop with parallel execution (max concurrent: %d)\n", ts(), *maxInProgress)
 
 		// Create task runner for managing parallel executions
 		taskRunner := NewTaskRunner(*maxInProgress)
+		taskRunner.SetFallbackChain(fallbacks, *fallbackAfter)
+		taskRunner.SetFileClaims(*fileClaims)
+		taskRunner.SetWorktrees(trees)
+		taskRunner.SetPromptNotes(tasks.DeferredCriteriaNote(policy), commits.PromptNote(), strict.PromptNote(), entryFormat.PromptNote(), checks.PromptNote())
+		taskRunner.SetShowFullPrompts(*showFull)
+		taskRunner.SetUpdateTaskPaths(*updateTaskPaths)
+		taskRunner.SetContextBudget(*contextBudget)
+		// The dashboard shows each agent's output in a pane of its own
+		taskRunner.SetTaskLogs(*useTUI || mustTaskLogs(*taskLogsMode, *maxInProgress))
+		taskRunner.SetCoordinator(coordinator)
+		taskRunner.SetAgentAuthor(author)
+		taskRunner.SetPromptLadder(ladder)
+		taskRunner.SetProjectType(kind)
+		taskRunner.SetTaskTimeout(*taskTimeout)
+		if *raceThrottle {
+			taskRunner.SetThrottle(throttle.New(*maxInProgress, *stagger))
+		}
+
+		if *metricsAddr != "" {
+			loopMetrics = newIterMetrics(taskRunner)
+			ln, err := serveMetrics(*metricsAddr, loopMetrics)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to serve metrics: %v\n", err)
+				os.Exit(1)
+			}
+			defer ln.Close()
+			fmt.Printf("[%s] 📈 Serving Prometheus metrics on http://%s/metrics\n", ts(), ln.Addr())
+		}
+
+		// Ctrl-C stops the running agents and leaves progress.md consistent
+		// instead of orphaning them
+		stop := watchSignals(*shutdownGrace)
+		defer stop.Stop()
+		taskRunner.SetContext(stop.ctx)
+		checks.SetContext(stop.ctx)
+
+		// Let status bars show how many agents are running
+		stopStatus := publishRunning(taskRunner)
+		defer stopStatus()
+
+		// Keep the parsed control files in memory; they are only re-read
+		// when they change on disk
+		if _, err := os.Stat(file); err != nil {
+			fmt.Fprintf(os.Stderr, "error reading tasks file: %v\n", err)
+			os.Exit(1)
+		}
+		if _, err := os.Stat(progressFile); err != nil {
+			// If progress.md doesn't exist, create an empty one
+			atomicfile.WriteFile(progressFile, []byte("# Progress Log\n\n## In Progress\n\n## Completed Tasks\n\n"), 0644)
+		}
+		store := state.NewStore(file, progressFile)
+		commitFollowUps := make(map[string]bool)
+
+		// archive-completed queues its archive for the loop holding the
+		// lock instead of rewriting progress.md under it
+		releaseLock, otherLoop, err := lockControlFiles(lockLoop, runID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		if otherLoop != nil {
+			fmt.Printf("[%s] ⚠️ %s is already running in this repository; archives are queued for it\n", ts(), otherLoop)
+			releaseLock = func() {}
+		}
+		// os.Exit skips deferred calls, so the exits below release the lock
+		// themselves
+		defer releaseLock()
+
+		// Tasks in a dependency cycle are never dispatched
+		if snap, _ := store.Refresh(); snap.TasksMd != "" {
+			for _, cycle := range tasks.NewDependencyGraph(tasks.ParseTasks(snap.TasksMd)).Cycles() {
+				fmt.Printf("[%s] ⚠️ Dependency cycle: %s; these tasks won't start (see 'cursor-iter validate-tasks')\n", ts(), tasks.FormatCycle(cycle))
+			}
+		}
+
+		// --task limits the loop to the tasks named, in the order of tasks.md
+		var focus loopFocus
+		if len(focusRefs) > 0 {
+			snap, _ := store.Refresh()
+			if focus, err = newLoopFocus(snap.TasksMd, focusRefs); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				releaseLock()
+				os.Exit(1)
+			}
+			fmt.Printf("[%s] 🎯 Working only on: %s\n", ts(), strings.Join(focus, ", "))
+		}
+		focusLost := make(map[string]bool)
+		labels := tasks.ParseLabelFilter(*labelSpec, *excludeLabelSpec)
+		labels.Defaults = configLabelDefaults()
+		if !labels.IsZero() {
+			fmt.Printf("[%s] 🏷️ Working only on tasks %s\n", ts(), labels)
+		}
+		heldNoted := make(map[string]bool)
+
+		dash, stopDashboard := startDashboard(*useTUI, taskRunner, store, runID, *maxInProgress, stop.Request)
+		defer stopDashboard()
+		stopProgress := startProgress(!*dbg && !*noProgress && eventLog == nil && dash == nil, taskRunner.runningExecutions)
+		defer stopProgress()
+
+		// Main loop
+		iterationCount := 0
+		maxIterations := 100 // safety cap
+		var idle idleTracker
+
+		var lastRecurringCheck time.Time
+		lastTick := 0
+		holdingForArchive := false
+
+		for iterationCount < maxIterations {
+			iterationCount++
+
+			// Once a signal stopped the loop and its agents, exit
+			if stop.Requested() && taskRunner.ActiveCount() == 0 {
+				tidyProgress(progressFile)
+				printBackendStats(taskRunner)
+				fmt.Printf("[%s] 🛑 Stopped; progress.md is up to date. Run iterate-loop again to resume\n", ts())
+				logLoopFinished(runID, "interrupted", iterationCount)
+				handOff("interrupted", runID)
+				stop.Stop()
+				stopStatus()
+				stopProgress()
+				stopDashboard()
+				coordinator.Close()
+				releaseLock()
+				os.Exit(exitInterrupted)
+			}
+
+			// Once the budget is spent and the last agents finished, exit
+			if loopSpend.Reached() && taskRunner.ActiveCount() == 0 {
+				tidyProgress(progressFile)
+				fmt.Printf("[%s] 💸 Stopped after spending %s of the %s budget. Raise --budget to continue\n", ts(), formatUSD(loopSpend.Spent()), formatUSD(loopSpend.limit))
+				logLoopFinished(runID, "budget reached", iterationCount)
+				handOff("reached the spending budget", runID)
+				printBackendStats(taskRunner)
+				return
+			}
+
+			// Once the loop changed too many lines and the last agents
+			// finished, go on only if a human says so
+			loopChurn.update()
+			if loopChurn.Reached() && taskRunner.ActiveCount() == 0 && !loopChurn.confirm(stop.ctx, dash == nil && eventLog == nil) {
+				tidyProgress(progressFile)
+				fmt.Printf("[%s] 🧯 Stopped after changing %s lines. Review them, then run iterate-loop again or raise --max-churn to continue\n", ts(), groupThousands(int64(loopChurn.changed())))
+				logLoopFinished(runID, "churn limit reached", iterationCount)
+				handOff("reached the churn limit", runID)
+				printBackendStats(taskRunner)
+				return
+			}
 
-		// Main loop
-		iterationCount := 0
-		maxIterations := 100 // safety cap
+			// Add recurring tasks that came due
+			if time.Since(lastRecurringCheck) >= recurringCheckInterval {
+				lastRecurringCheck = time.Now()
+				checkRecurring()
+			}
 
-		for iterationCount < maxIterations {
-			iterationCount++
+			// A queued archive runs once no agent is running; until then
+			// no task starts
+			_, archiveQueued := pendingArchive()
+			if archiveQueued && taskRunner.ActiveCount() == 0 {
+				runQueuedArchive()
+				archiveQueued = false
+			}
+			if archiveQueued && !holdingForArchive {
+				fmt.Printf("[%s] 🗄️ Archive queued; holding new tasks until the %d running agent(s) finish\n", ts(), taskRunner.ActiveCount())
+			}
+			holdingForArchive = archiveQueued
 
 			// Read current state
+			snap, changed := store.Refresh()
 			if *dbg {
-				fmt.Printf("[%s] 📖 Reading tasks from: %s\n", ts(), file)
+				if changed {
+					fmt.Printf("[%s] 📖 Reloaded %s and %s (version %d)\n", ts(), file, progressFile, snap.Version)
+				} else {
+					fmt.Printf("[%s] 📖 Control files unchanged (version %d)\n", ts(), snap.Version)
+				}
 			}
-			b, err := os.ReadFile(file)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "error reading tasks file: %v\n", err)
-				os.Exit(1)
+			taskContent, lost := focus.narrow(snap.TasksMd)
+			for _, title := range lost {
+				if !focusLost[title] {
+					focusLost[title] = true
+					fmt.Printf("[%s] ⚠️ Task '%s' given with --task is no longer in %s\n", ts(), title, file)
+				}
 			}
-			taskContent := string(b)
-
-			// Read progress.md (create if doesn't exist)
-			progressContent, err := os.ReadFile(progressFile)
-			if err != nil {
-				// If progress.md doesn't exist, create an empty one
-				progressContent = []byte("# Progress Log\n\n## In Progress\n\n## Completed Tasks\n\n")
-				os.WriteFile(progressFile, progressContent, 0644)
+			progressStr := snap.ProgressMd
+			taskContent, held := labels.KeepRunnable(taskContent, progressStr)
+			for _, title := range held {
+				if !heldNoted[title] {
+					heldNoted[title] = true
+					fmt.Printf("[%s] ⏭️ Skipping '%s': it waits on a task the labels leave out\n", ts(), title)
+				}
+			}
+			// Idle iterations repeat their number; tick once per number
+			if iterationCount > lastTick {
+				lastTick = iterationCount
+				logIterationTick(iterationCount, taskRunner.ActiveCount(), *maxInProgress, taskContent, progressStr)
 			}
-			progressStr := string(progressContent)
 
 			// Check if all tasks are complete
 			if tasks.CompleteAllChecked(taskContent, progressStr) {
@@ -811,13 +3335,29 @@ Work on this task until all acceptance criteria are checked off and the task is
 							ts(), completedTitle, taskRunner.ActiveCount(), *maxInProgress)
 					}
 				}
-				fmt.Printf("[%s] ✅ All tasks completed successfully!\n", ts())
+				// Tasks the full suite hasn't checked yet are checked now
+				if checks.CheckBatch(progressFile, true) {
+					continue
+				}
+				switch {
+				case len(focus) > 0:
+					fmt.Printf("[%s] ✅ The tasks given with --task are completed\n", ts())
+				case !labels.IsZero():
+					fmt.Printf("[%s] ✅ All tasks %s are completed\n", ts(), labels)
+				default:
+					fmt.Printf("[%s] ✅ All tasks completed successfully!\n", ts())
+				}
+				runQueuedArchive()
+				logLoopFinished(runID, "all tasks completed", iterationCount)
+				clearHandoff()
+				printBackendStats(taskRunner)
+				idle.Print(os.Stdout, "The loop waited with nothing to dispatch")
 				return
 			}
 
 			// Show current progress
 			progress := tasks.GetTaskProgressWithProgress(taskContent, progressStr)
-			if *dbg || taskRunner.ActiveCount() == 0 {
+			if *dbg || (taskRunner.ActiveCount() == 0 && idle.streak == 0) {
 				fmt.Printf("[%s] Iteration #%d - %s\n", ts(), iterationCount, progress)
 				if taskRunner.ActiveCount() > 0 {
 					fmt.Printf("[%s] 🔄 Currently running %d tasks: %v\n",
@@ -829,8 +3369,38 @@ Work on this task until all acceptance criteria are checked off and the task is
 			inProgressTasks := tasks.GetAllInProgressTasks(taskContent, progressStr)
 			runningTitles := taskRunner.GetRunningTasks()
 
-			// Start new tasks if we have capacity
-			if taskRunner.ActiveCount() < *maxInProgress {
+			// An exclusive task that is waiting to run drains the runner:
+			// it is the only task that may be started
+			blocker := taskRunner.RunningExclusive()
+			if blocker == "" {
+				if waiting := waitingExclusiveTask(taskContent, inProgressTasks, runningTitles); waiting != nil {
+					blocker = waiting.Title
+					inProgressTasks = []*tasks.Task{waiting}
+				}
+			}
+			if blocker != "" && *dbg {
+				fmt.Printf("[%s] 🔒 Holding new tasks for exclusive task: '%s'\n", ts(), blocker)
+			}
+
+			// Why tasks could not be started, reported when nothing runs
+			var dispatchReasons []string
+			if blocker != "" {
+				dispatchReasons = append(dispatchReasons, fmt.Sprintf("new tasks held for exclusive task '%s'", blocker))
+			}
+
+			if archiveQueued {
+				inProgressTasks = nil
+				dispatchReasons = append(dispatchReasons, "new tasks held for a queued archive-completed")
+			}
+
+			if dash.Paused() {
+				dispatchReasons = append(dispatchReasons, "paused from the dashboard")
+			}
+
+			// Start new tasks if we have capacity, unless the loop is stopping,
+			// paused or out of budget
+			capacity := taskRunner.Capacity()
+			if !stop.Requested() && !dash.Paused() && !loopSpend.Reached() && !loopChurn.Reached() && taskRunner.ActiveCount() < capacity {
 				tasksStarted := 0
 
 				// First, try to start any in-progress tasks that aren't currently running
@@ -844,32 +3414,36 @@ Work on this task until all acceptance criteria are checked off and the task is
 						}
 					}
 
-					if !isRunning && taskRunner.ActiveCount() < *maxInProgress {
+					if !isRunning && taskRunner.ActiveCount() < capacity {
 						// Extract task details and start it
 						taskDetails := tasks.ExtractTaskDetails(taskContent, task.Title)
 						if *dbg {
 							fmt.Printf("[%s] 🔄 Resuming in-progress task: '%s' (%d/%d criteria)\n",
 								ts(), task.Title, task.ACChecked, task.ACTotal)
 						}
-						err := taskRunner.StartTask(task.Title, taskDetails, *useCodex, agentModel, *dbg)
-						if err != nil && *dbg {
-							fmt.Printf("[%s] ⚠️ Could not start task '%s': %v\n", ts(), task.Title, err)
+						err := taskRunner.StartTask(task.Title, taskDetails, agentBackend, agentModel, *dbg)
+						if err != nil {
+							dispatchReasons = append(dispatchReasons, dispatchReason(task.Title, err))
+							if *dbg {
+								fmt.Printf("[%s] ⚠️ Could not start task '%s': %v\n", ts(), task.Title, err)
+							}
 						} else {
 							tasksStarted++
-							// Stagger task starts by 3 seconds to prevent race conditions
-							if taskRunner.ActiveCount() < *maxInProgress {
+							// Stagger task starts when asked to
+							if delay := taskRunner.Stagger(*stagger); delay > 0 && taskRunner.ActiveCount() < capacity {
 								if *dbg {
-									fmt.Printf("[%s] ⏱️ Staggering next task start by 3 seconds...\n", ts())
+									fmt.Printf("[%s] ⏱️ Staggering next task start by %v...\n", ts(), delay)
 								}
-								time.Sleep(3 * time.Second)
+								time.Sleep(delay)
 							}
 						}
 					}
 				}
 
-				// Then, try to start new pending tasks
-				for taskRunner.ActiveCount() < *maxInProgress {
-					nextTask := tasks.GetNextPendingTaskWithProgress(taskContent, progressStr)
+				// Then, try to start new pending tasks unless an exclusive
+				// task is waiting or running
+				for blocker == "" && !archiveQueued && taskRunner.RunningExclusive() == "" && taskRunner.ActiveCount() < capacity {
+					nextTask := fairness.NextPendingTask(taskContent, progressStr, taskRunner.GetRunningTasks(), capacity, taskRunner.Claims())
 					if nextTask == nil {
 						break // No more pending tasks
 					}
@@ -878,9 +3452,15 @@ Work on this task until all acceptance criteria are checked off and the task is
 					if *dbg {
 						fmt.Printf("[%s] 📝 Marking new task as in-progress: '%s'\n", ts(), nextTask.Title)
 					}
-					updatedProgress := tasks.MarkTaskInProgress(progressStr, nextTask.Title)
-					if err := os.WriteFile(progressFile, []byte(updatedProgress), 0644); err != nil {
+					// Marked in the file as it is now, since agents write
+					// it while the loop runs
+					var updatedProgress string
+					if err := updateControlFile(progressFile, func(content string) string {
+						updatedProgress = tasks.MarkTaskInProgress(content, nextTask.Title)
+						return updatedProgress
+					}); err != nil {
 						fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not update progress: %v\n", ts(), err)
+						dispatchReasons = append(dispatchReasons, fmt.Sprintf("could not update %s: %v", progressFile, err))
 						break
 					}
 					progressStr = updatedProgress // Update local copy
@@ -888,19 +3468,25 @@ Work on this task until all acceptance criteria are checked off and the task is
 					// Extract task details and start it
 					taskDetails := tasks.ExtractTaskDetails(taskContent, nextTask.Title)
 					fmt.Printf("[%s] 📝 Starting new task: '%s'\n", ts(), nextTask.Title)
-					err := taskRunner.StartTask(nextTask.Title, taskDetails, *useCodex, agentModel, *dbg)
+					err := taskRunner.StartTask(nextTask.Title, taskDetails, agentBackend, agentModel, *dbg)
 					if err != nil {
+						dispatchReasons = append(dispatchReasons, dispatchReason(nextTask.Title, err))
+					}
+					if errors.Is(err, coord.ErrNoSlot) {
+						fmt.Printf("[%s] ⏸️  Waiting to start '%s': %v\n", ts(), nextTask.Title, err)
+						break
+					} else if err != nil {
 						fmt.Printf("[%s] ⚠️ Could not start task '%s': %v\n", ts(), nextTask.Title, err)
 						break
 					}
 					tasksStarted++
-					// Stagger task starts by 3 seconds to prevent race conditions
+					// Stagger task starts when asked to
 					// Skip delay if we've reached max capacity
-					if taskRunner.ActiveCount() < *maxInProgress {
+					if delay := taskRunner.Stagger(*stagger); delay > 0 && taskRunner.ActiveCount() < capacity {
 						if *dbg {
-							fmt.Printf("[%s] ⏱️ Staggering next task start by 3 seconds...\n", ts())
+							fmt.Printf("[%s] ⏱️ Staggering next task start by %v...\n", ts(), delay)
 						}
-						time.Sleep(3 * time.Second)
+						time.Sleep(delay)
 					}
 				}
 
@@ -912,34 +3498,162 @@ Work on this task until all acceptance criteria are checked off and the task is
 
 			// If we have running tasks, wait for at least one to complete
 			if taskRunner.ActiveCount() > 0 {
+				idle.Active()
 				completedTitle, err := taskRunner.WaitForAny()
 				if err != nil {
+					// Skips, restarts and preemptions aren't failures
+					// of the backend
+					if run := taskRunner.LastRun(completedTitle); completedTitle != "" && run.StopReason != "" {
+						recordRun(runID, run, journal.OutcomeFailed, err)
+						handleStopped(progressFile, run)
+						continue
+					}
+					if completedTitle != "" {
+						taskRunner.RecordOutcome(completedTitle, false)
+						spent := false
+						if entry := recordRun(runID, taskRunner.LastRun(completedTitle), journal.OutcomeFailed, err); ladder.Exhausted(taskRunner.LastRun(completedTitle), entry.Classification) {
+							blockRefused(progressFile, completedTitle)
+						} else if !errors.Is(err, runner.ErrInterrupted) {
+							spent = budget.Spent(progressFile, entry)
+						}
+						if note := commits.Check(taskRunner.LastRun(completedTitle), taskRunner.ActiveCount() == 0); note != "" {
+							taskRunner.AddFollowUp(completedTitle, note)
+						}
+						if note := strict.Check(taskRunner.LastRun(completedTitle), taskRunner.runningExecutions()); note != "" {
+							taskRunner.AddFollowUp(completedTitle, note)
+						}
+						if spent {
+							continue
+						}
+						if errors.Is(err, runner.ErrTimeout) {
+							fmt.Printf("[%s] ⏱️ Task timed out: %s - will retry\n", ts(), completedTitle)
+							logTaskRetry(completedTitle, "timeout")
+							continue
+						}
+						if errors.Is(err, runner.ErrInterrupted) {
+							releaseInterrupted(progressFile, completedTitle)
+							continue
+						}
+						logTaskRetry(completedTitle, "failed")
+					}
 					fmt.Fprintf(os.Stderr, "[%s] ⚠️ Error waiting for task: %v\n", ts(), err)
 					time.Sleep(2 * time.Second)
 					continue
 				}
 
 				// Re-read files to check completion status
-				b2, err := os.ReadFile(file)
-				if err == nil {
-					progressContent2, _ := os.ReadFile(progressFile)
-					newTaskContent := string(b2)
-					newProgressStr := string(progressContent2)
+				if snap, _ := store.Refresh(); snap.TasksMd != "" {
+					newTaskContent := snap.TasksMd
+					newProgressStr := snap.ProgressMd
 
 					taskCompleted := tasks.IsTaskCompletedAfterRun(newTaskContent, newProgressStr, completedTitle)
+					taskRunner.RecordOutcome(completedTitle, taskCompleted)
+					outcome := journal.OutcomeIncomplete
+					blocker := ""
+					if taskCompleted {
+						outcome = journal.OutcomeCompleted
+					} else {
+						blocker = blockers.Check(taskRunner.LastRun(completedTitle))
+					}
+					entry := recordRun(runID, taskRunner.LastRun(completedTitle), outcome, nil)
 					if taskCompleted {
 						fmt.Printf("[%s] ✅ Task marked as completed: %s\n", ts(), completedTitle)
+						if entry.PromptVariant != "" && entry.PromptVariant != promptFull {
+							fmt.Printf("[%s] 🪜 Completed with the %s prompt\n", ts(), entry.PromptVariant)
+						}
+						// A commit message follow-up completes the task again
+						if !commitFollowUps[completedTitle] {
+							completedDetails := tasks.ExtractTaskDetails(newTaskContent, completedTitle)
+							// The task's branch is merged first, so the
+							// quality gates and the review see its work;
+							// the quality gates gate completion before the
+							// slower review
+							if note, landed := trees.Land(taskRunner.LastRun(completedTitle), file, progressFile); !landed {
+								taskCompleted = false
+								if note != "" {
+									taskRunner.AddFollowUp(completedTitle, note)
+								}
+							} else if !checks.Fast(taskRunner.LastRun(completedTitle), progressFile) {
+								taskCompleted = false
+							} else if reviewer.Review(taskRunner.LastRun(completedTitle), completedDetails, progressFile) {
+								taskNotes.WriteRun(taskRunner.LastRun(completedTitle), completedDetails)
+								writeTaskCost(progressFile, completedTitle, prices)
+								writeTaskChurn(progressFile, completedTitle)
+								resolveTodo(completedDetails)
+								checks.Queue(taskRunner.LastRun(completedTitle))
+								prs.openPR(taskRunner.LastRun(completedTitle), file, progressFile)
+								deliverFeature(file, progressFile, completedTitle, prices)
+							} else {
+								taskCompleted = false
+							}
+						}
+					} else if blocker != "" {
+						blockNeedsHuman(progressFile, completedTitle, blocker)
+					} else if ladder.Exhausted(taskRunner.LastRun(completedTitle), entry.Classification) {
+						blockRefused(progressFile, completedTitle)
+					} else if budget.Spent(progressFile, entry) {
+						// Out of attempts, moved to Failed
+					} else if entry.Classification == journal.ClassRefusal && len(ladder) > 0 {
+						fmt.Printf("[%s] 🪜 The model declined the task: %s - will retry with the %s prompt\n", ts(), completedTitle, ladder.next([]journal.Entry{entry}, completedTitle))
+						logTaskRetry(completedTitle, "declined")
 					} else {
 						fmt.Printf("[%s] ⚠️ Task not yet complete: %s - will retry\n", ts(), completedTitle)
+						logTaskRetry(completedTitle, "incomplete")
 					}
 
+					// Commit policy violations the checker couldn't reword go
+					// back to the agent: with the next attempt of an open
+					// task, or in one follow-up run of a completed one
+					if note := commits.Check(taskRunner.LastRun(completedTitle), taskRunner.ActiveCount() == 0); note != "" {
+						if !taskCompleted {
+							taskRunner.AddFollowUp(completedTitle, note)
+						} else if !commitFollowUps[completedTitle] && !stop.Requested() {
+							commitFollowUps[completedTitle] = true
+							taskRunner.AddFollowUp(completedTitle, "This task is already complete; only fix its commit messages. "+note)
+							details := tasks.ExtractTaskDetails(newTaskContent, completedTitle)
+							if err := taskRunner.StartTask(completedTitle, details, agentBackend, agentModel, *dbg); err != nil {
+								fmt.Printf("[%s] ⚠️ Could not start commit message follow-up for '%s': %v\n", ts(), completedTitle, err)
+							}
+						}
+					}
+
+					// Out-of-scope changes are reverted, after the commit
+					// check so it sees the agent's own commits. An open task
+					// is told with its next attempt.
+					if note := strict.Check(taskRunner.LastRun(completedTitle), taskRunner.runningExecutions()); note != "" && !taskCompleted {
+						taskRunner.AddFollowUp(completedTitle, note)
+					}
+
+					// The full suite runs once per batch of completed tasks
+					checks.CheckBatch(progressFile, false)
+
 					// Show updated progress
 					newProgress := tasks.GetTaskProgressWithProgress(newTaskContent, newProgressStr)
 					fmt.Printf("[%s] 📊 Progress: %s (active: %d/%d)\n",
 						ts(), newProgress, taskRunner.ActiveCount(), *maxInProgress)
 				}
 			} else {
-				// No tasks running and no tasks to start - wait a bit and retry
+				// Nothing left to run but blocked tasks: hand over to triage
+				// instead of idling until the iteration cap
+				if tasks.OnlyBlockedRemain(taskContent, snap.ProgressMd) {
+					if checks.CheckBatch(progressFile, true) {
+						continue
+					}
+					fmt.Printf("[%s] ⛔ Remaining tasks are blocked: %v\n", ts(), tasks.GetBlockedTasks(snap.ProgressMd))
+					fmt.Printf("[%s] 💡 Run 'cursor-iter triage' to review them\n", ts())
+					logLoopFinished(runID, "only blocked tasks remain", iterationCount)
+					handOff("only blocked tasks remain", runID)
+					printBackendStats(taskRunner)
+					idle.Print(os.Stdout, "The loop waited with nothing to dispatch")
+					return
+				}
+				// No tasks running and no tasks to start - wait a bit and
+				// retry. Idle iterations don't count against the cap, but
+				// what holds the loop up is reported.
+				iterationCount--
+				if idle.Record(append(dispatchReasons, stallReasons(taskContent, snap.ProgressMd)...)) {
+					idle.Print(os.Stdout, fmt.Sprintf("Nothing to dispatch for %d iteration(s) in a row", idle.streak))
+				}
 				if *dbg {
 					fmt.Printf("[%s] ⏳ No tasks to run, waiting...\n", ts())
 				}
@@ -948,14 +3662,32 @@ Work on this task until all acceptance criteria are checked off and the task is
 		}
 
 		fmt.Printf("[%s] ⚠️ Reached max iterations (%d) without completion\n", ts(), maxIterations)
+		logLoopFinished(runID, "max iterations", iterationCount)
+		handOff(fmt.Sprintf("reached the limit of %d iterations", maxIterations), runID)
+		printBackendStats(taskRunner)
+		idle.Print(os.Stdout, "The loop waited with nothing to dispatch")
 	case "add-feature":
 		fs := flag.NewFlagSet("add-feature", flag.ExitOnError)
 		file := fs.String("file", "", "read feature description from file")
 		prompt := fs.String("prompt", "", "provide feature description as command line argument")
+		specURL := fs.String("url", "", "fetch the feature description from a spec URL, e.g. a Notion export or wiki page")
+		var headers headerFlags
+		fs.Var(&headers, "header", "header to send with --url, \"Name: value\" with $VARS expanded; repeatable (env SPEC_TOKEN sends a bearer token)")
 		useCodex := fs.Bool("codex", false, "use codex CLI with gpt-5-codex model")
-		model := fs.String("model", envOr("MODEL", "auto"), "cursor-agent model or codex model (gpt-5-codex)")
+		useClaude := fs.Bool("claude", false, "use the claude CLI (Claude Code)")
+		model := fs.String("model", envOr("MODEL", "auto"), "cursor-agent, codex (gpt-5-codex) or claude (sonnet, opus) model")
+		stage := fs.Bool("stage", envOr("ADD_FEATURE_STAGE", "") != "", "put generated tasks in staged-tasks.md for approval instead of tasks.md")
+		stageOver := fs.Int("stage-over", 0, "stage generated tasks only when there are more than N of them")
+		milestone := fs.String("milestone", tasks.DefaultMilestone, "milestone for staged tasks without a [milestone:...] label")
+		projectType := fs.String("project-type", envOr("PROJECT_TYPE", project.Auto), "kind of repository: code, docs, infra or auto to detect it")
 		dbg := fs.Bool("debug", debug, "enable verbose logging")
-		_ = fs.Parse(os.Args[2:])
+		showFull := fs.Bool("show-full-prompts", showFullPrompts, "print full prompts in debug logs instead of their hash and path")
+		embeddedPrompts := fs.Bool("embedded-prompts", envOr("EMBEDDED_PROMPTS", "") != "", "use the prompts built into cursor-iter instead of fetching them from GitHub")
+		promptMirror := fs.String("prompt-mirror", envOr("PROMPT_MIRROR", ""), "comma-separated URLs to fetch prompts from before GitHub and jsDelivr, e.g. https://mirror.example.com/prompts/{file}")
+		offline := fs.Bool("offline", envOr("CURSOR_ITER_OFFLINE", "") != "", "never use the network: built-in prompts only, no spec URLs")
+		parseFlags(fs, os.Args[2:])
+		agentBackend := primaryBackend(*useCodex, *useClaude)
+		staging := *stage || *stageOver > 0
 
 		// Ensure .cursor-iter directory exists
 		if err := ensureCursorIterDir(); err != nil {
@@ -965,8 +3697,8 @@ Work on this task until all acceptance criteria are checked off and the task is
 
 		promptFile := getControlFilePath("prompts/add-feature.md")
 
-		// Try to fetch from GitHub if not present locally
-		if fetchErr := fetchPromptFromGitHub(promptFile); fetchErr != nil {
+		// Fetch the prompt if it isn't present locally
+		if fetchErr := fetchPrompt(promptFile, newPromptSource(*embeddedPrompts, *offline, *promptMirror)); fetchErr != nil {
 			fmt.Fprintf(os.Stderr, "failed to fetch prompt: %v\n", fetchErr)
 			os.Exit(1)
 		}
@@ -992,11 +3724,19 @@ Work on this task until all acceptance criteria are checked off and the task is
 			}
 			featureDesc = string(fileData)
 			fmt.Printf("✅ Loaded feature description from %s (%d characters)\n", *file, len(featureDesc))
+		} else if *specURL != "" {
+			text, err := fetchSpec(*specURL, specSource{Headers: headers, Offline: *offline})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			featureDesc = fmt.Sprintf("Spec: %s\n\n%s", *specURL, text)
+			fmt.Printf("✅ Fetched feature description from %s (%d characters)\n", *specURL, len(text))
 		} else {
 			// Interactive input
 			fmt.Print("Enter feature description (press Enter twice when done):\n")
 			fmt.Print("Tip: For long descriptions, you can paste multi-line text. Press Enter twice to finish.\n")
-			fmt.Print("Alternative: Use --file <path> to read from a file or --prompt \"description\"\n")
+			fmt.Print("Alternative: Use --file <path> to read from a file, --url <spec URL> to fetch it, or --prompt \"description\"\n")
 
 			scanner := bufio.NewScanner(os.Stdin)
 			var lines []string
@@ -1042,40 +3782,40 @@ Work on this task until all acceptance criteria are checked off and the task is
 
 		// Replace placeholder with user input
 		promptContent := strings.ReplaceAll(string(data), "{{FEATURE_DESCRIPTION}}", featureDesc)
-
-		// Set default model for codex if not specified
-		agentModel := *model
-		if *useCodex && *model == "auto" {
-			agentModel = "gpt-5-codex"
+		promptContent += mustProjectType(*projectType, *dbg).PromptNote()
+		if note := sealedFilesNote(); note != "" {
+			promptContent += "\n\n" + note
 		}
+		if staging {
+			promptContent += stagingPromptNote
+		}
+
+		// Remember the current tasks so generated ones can be labeled with
+		// the feature and staged
+		previousTasks, _ := os.ReadFile(getControlFilePath("tasks.md"))
+
+		agentModel := runner.DefaultModel(agentBackend, *model)
 
 		fmt.Printf("[%s] Analyzing feature and creating architecture/tasks...\n", ts())
 		if *dbg {
-			if *useCodex {
-				fmt.Printf("[%s] add-feature using codex model=%s, prompt=%s with feature: %s\n", ts(), agentModel, promptFile, featureDesc)
-			} else {
-				fmt.Printf("[%s] add-feature using cursor-agent model=%s, prompt=%s with feature: %s\n", ts(), agentModel, promptFile, featureDesc)
-			}
+			fmt.Printf("[%s] add-feature using %s model=%s, prompt=%s with feature: %s\n", ts(), agentBackend, agentModel, promptFile, featureDesc)
 		}
 
 		// Log that we're about to send to cursor-agent
 		fmt.Printf("[%s] 🚀 Sending feature design request to cursor-agent...\n", ts())
 		if *dbg {
-			if *useCodex {
-				fmt.Printf("[%s] 🤖 Using codex (model: %s)\n", ts(), agentModel)
-			} else {
-				fmt.Printf("[%s] 🤖 Using cursor-agent (model: %s)\n", ts(), agentModel)
-			}
+			fmt.Printf("[%s] 🤖 Using %s (model: %s)\n", ts(), agentBackend, agentModel)
 		}
 
 		// Run cursor-agent to directly edit files
 		var runErr error
 
-		if *useCodex {
-			runErr = runner.CodexWithDebug(*dbg, agentModel, promptContent)
-		} else {
-			runErr = runner.CursorAgentWithDebug(*dbg, "--print", "--force", promptContent)
-		}
+		logPrompt(promptContent, *dbg, *showFull)
+		opts, flush := agentOptions(*dbg)
+		sealed := sealedControlFiles()
+		runErr = runner.RunPrompt(opts, agentBackend, agentModel, promptContent)
+		flush()
+		resealControlFiles(sealed)
 
 		if runErr != nil {
 			fmt.Fprintf(os.Stderr, "[%s] ❌ Feature analysis failed: %v\n", ts(), runErr)
@@ -1086,6 +3826,27 @@ Work on this task until all acceptance criteria are checked off and the task is
 		fmt.Printf("[%s] ✅ Feature design complete!\n", ts())
 		fmt.Printf("[%s] 📝 Control files have been updated by cursor-agent\n", ts())
 
+		// Label the new tasks first, so staged ones keep the label
+		feature, err := recordFeature(getControlFilePath("tasks.md"), string(previousTasks), featureDesc, time.Now())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not record the feature in %s: %v\n", ts(), featuresLogPath(), err)
+		} else if feature.ID != "" {
+			fmt.Printf("[%s] 🏷️ Labeled %d new task(s) with [%s:%s]; its delivery is summed up in %s\n", ts(), len(feature.Tasks), features.LabelKey, feature.ID, featuresLogPath())
+		}
+
+		if staging {
+			staged, err := stageNewTasks(string(previousTasks), *milestone, *stage, *stageOver)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[%s] ❌ Failed to stage generated tasks: %v\n", ts(), err)
+				os.Exit(1)
+			}
+			if len(staged) > 0 {
+				fmt.Printf("[%s] 📥 Staged %d generated tasks in %s\n", ts(), len(staged), stagedTasksPath())
+				printStagedMilestones(stagedTasksPath())
+				return
+			}
+		}
+
 		// Verify that files were actually updated
 		controlFiles := []string{"architecture.md", "tasks.md", "test_plan.md", "decisions.md"}
 		updatedFiles := []string{}
@@ -1117,14 +3878,272 @@ Work on this task until all acceptance criteria are checked off and the task is
 			fmt.Printf("[%s] ⚠️ Warning: No control files found. The agent may not have created them yet.\n", ts())
 			fmt.Printf("[%s] 💡 Check if cursor-agent made the expected changes.\n", ts())
 		}
+	case "add-task":
+		fs := flag.NewFlagSet("add-task", flag.ExitOnError)
+		fromURL := fs.String("from-url", "", "spec URL whose content becomes the task, e.g. a Notion export or wiki page")
+		title := fs.String("title", "", "task title (default: the spec's first heading)")
+		taskContext := fs.String("context", "", "why the task exists and what the agent should know")
+		criteria := fs.String("criteria", "", "comma-separated acceptance criteria, e.g. 'Login is rate limited,Tests cover it'")
+		files := fs.String("files", "", "comma-separated files to modify")
+		deps := fs.String("deps", "", "comma-separated titles of the tasks this one depends on")
+		labels := fs.String("labels", "", "comma-separated labels, e.g. type:feature,milestone:auth")
+		interactive := fs.Bool("interactive", false, "ask for the title, context, criteria, files, dependencies and labels not given as flags")
+		var headers headerFlags
+		fs.Var(&headers, "header", "header to send with the request, \"Name: value\" with $VARS expanded; repeatable (env SPEC_TOKEN sends a bearer token)")
+		stage := fs.Bool("stage", false, "stage the task for review instead of adding it to tasks.md")
+		milestone := fs.String("milestone", specMilestone, "milestone to stage the task under")
+		offline := fs.Bool("offline", envOr("CURSOR_ITER_OFFLINE", "") != "", "never use the network: built-in prompts only, no spec URLs")
+		parseFlags(fs, os.Args[2:])
+
+		draft := tasks.Draft{
+			Title:        *title,
+			Context:      *taskContext,
+			Criteria:     splitItems(*criteria),
+			Files:        splitItems(*files),
+			Dependencies: splitItems(*deps),
+			Labels:       splitItems(*labels),
+		}
+		if *fromURL == "" && *title == "" && !*interactive {
+			fmt.Fprintln(os.Stderr, "add-task needs --title, --context and --criteria, or --interactive or --from-url; use add-feature to plan tasks from a description")
+			os.Exit(1)
+		}
+		if *fromURL != "" && (*interactive || draft.Context != "" || len(draft.Criteria) > 0 || len(draft.Files) > 0 || len(draft.Dependencies) > 0 || len(draft.Labels) > 0) {
+			fmt.Fprintln(os.Stderr, "--from-url takes the task from the spec; only --title may be given with it")
+			os.Exit(1)
+		}
+		if err := ensureCursorIterDir(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create %s directory: %v\n", CursorIterDir, err)
+			os.Exit(1)
+		}
+		if *fromURL == "" {
+			if *interactive {
+				var ok bool
+				var err error
+				draft, ok, err = draftPrompter{in: bufio.NewReader(os.Stdin), out: os.Stdout}.ask(draft)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "error: %v\n", err)
+					os.Exit(1)
+				}
+				if !ok {
+					fmt.Println("Not added.")
+					return
+				}
+			}
+			unknown, err := addManualTask(draft, *milestone, *stage)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			for _, dep := range unknown {
+				fmt.Printf("[%s] ⚠️ Warning: dependency %q names no task, so it won't hold this one up\n", ts(), dep)
+			}
+			added := strings.TrimSpace(draft.Title)
+			if *stage {
+				fmt.Printf("[%s] 📥 Staged %q; accept it with 'cursor-iter accept-tasks --milestone %s'\n", ts(), added, *milestone)
+			} else {
+				fmt.Printf("[%s] ✅ Added %q to %s\n", ts(), added, resolveTasksFile())
+			}
+			break
+		}
+		added, ok, err := addSpecTask(*fromURL, specSource{Headers: headers, Offline: *offline}, strings.TrimSpace(*title), *milestone, *stage)
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		case !ok:
+			fmt.Printf("[%s] ✅ %q already has a task\n", ts(), added)
+		case *stage:
+			fmt.Printf("[%s] 📥 Staged %q; accept it with 'cursor-iter accept-tasks --milestone %s'\n", ts(), added, *milestone)
+		default:
+			fmt.Printf("[%s] ✅ Added %q to %s\n", ts(), added, resolveTasksFile())
+		}
+	case "cluster":
+		fs := flag.NewFlagSet("cluster", flag.ExitOnError)
+		file := fs.String("file", resolveTasksFile(), "tasks file")
+		progressFile := fs.String("progress", resolveProgressFile(), "progress file")
+		maxCriteria := fs.Int("max-criteria", envInt("CLUSTER_MAX_CRITERIA", 3), "most acceptance criteria a task may have to be combined")
+		maxSize := fs.Int("max-size", envInt("CLUSTER_MAX_SIZE", 3), "most tasks to combine into one")
+		apply := fs.Bool("apply", false, "combine each cluster into one task and move the tasks it combines to the trash")
+		parseFlags(fs, os.Args[2:])
+
+		if *maxCriteria < 1 || *maxSize < 2 {
+			fmt.Fprintf(os.Stderr, "error: --max-criteria must be at least 1 and --max-size at least 2\n")
+			os.Exit(1)
+		}
+		opts := tasks.ClusterOptions{MaxCriteria: *maxCriteria, MaxSize: *maxSize, Defaults: configLabelDefaults()}
+		if !*apply {
+			content, err := os.ReadFile(*file)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			progress, _ := os.ReadFile(*progressFile)
+			printClusters(os.Stdout, tasks.SuggestClusters(string(content), string(progress), opts))
+			break
+		}
+		applied, err := applyClusters(*file, *progressFile, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, c := range applied {
+			fmt.Printf("[%s] 🧩 Combined %d tasks into '%s'\n", ts(), len(c.Titles), c.Title())
+		}
+		if len(applied) == 0 {
+			fmt.Printf("[%s] 🧩 No small related pending tasks to combine\n", ts())
+		}
+	case "sync-github":
+		fs := flag.NewFlagSet("sync-github", flag.ExitOnError)
+		repo := fs.String("repo", envOr("GITHUB_REPO", ""), "GitHub repository to sync with, owner/name")
+		label := fs.String("label", envOr("GITHUB_LABEL", "cursor-iter"), "import the open issues with this label")
+		outdir := fs.String("outdir", getControlFilePath("completed_tasks"), "archive directory whose tasks close their issues")
+		limit := fs.Int("limit", 100, "most issues to fetch")
+		dryRun := fs.Bool("dry-run", false, "print what would be imported and closed without changing anything")
+		parseFlags(fs, os.Args[2:])
+
+		if owner, name, ok := strings.Cut(*repo, "/"); !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+			fmt.Fprintf(os.Stderr, "sync-github needs --repo owner/name, got %q\n", *repo)
+			os.Exit(1)
+		}
+		if err := ensureCursorIterDir(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create %s directory: %v\n", CursorIterDir, err)
+			os.Exit(1)
+		}
+		gsync := githubSync{Repo: *repo, Label: *label, ArchiveDir: *outdir, Limit: *limit, DryRun: *dryRun}
+		imported, closed, err := gsync.run()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(imported) == 0 && len(closed) == 0 {
+			fmt.Printf("[%s] ✅ Nothing to sync with %s\n", ts(), *repo)
+		}
+	case "sync-jira":
+		fs := flag.NewFlagSet("sync-jira", flag.ExitOnError)
+		baseURL := fs.String("base-url", envOr("JIRA_BASE_URL", ""), "Jira site, e.g. https://acme.atlassian.net")
+		project := fs.String("project", envOr("JIRA_PROJECT", ""), "project key whose open tickets are imported")
+		jql := fs.String("jql", "", "JQL selecting the tickets to import (default: the project's tickets that aren't done)")
+		doneTransition := fs.String("done-transition", "Done", "transition, or status it leads to, for tickets whose tasks are completed")
+		progressFile := fs.String("progress", resolveProgressFile(), "progress file")
+		outdir := fs.String("outdir", getControlFilePath("completed_tasks"), "archive directory")
+		limit := fs.Int("limit", 50, "most tickets to fetch")
+		dryRun := fs.Bool("dry-run", false, "print what would be imported and transitioned without changing anything")
+		parseFlags(fs, os.Args[2:])
+
+		if u, err := url.Parse(*baseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fmt.Fprintf(os.Stderr, "sync-jira needs --base-url, the Jira site, e.g. https://acme.atlassian.net; got %q\n", *baseURL)
+			os.Exit(1)
+		}
+		if *jql == "" && *project == "" {
+			fmt.Fprintln(os.Stderr, "sync-jira needs --project or --jql to know which tickets to import")
+			os.Exit(1)
+		}
+		token := os.Getenv("JIRA_API_TOKEN")
+		if token == "" {
+			fmt.Fprintln(os.Stderr, "sync-jira needs JIRA_API_TOKEN: an API token with JIRA_EMAIL on Jira Cloud, or a personal access token on Jira Data Center")
+			os.Exit(1)
+		}
+		if *jql == "" {
+			*jql = jiraJQL(*project)
+		}
+		if err := ensureCursorIterDir(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create %s directory: %v\n", CursorIterDir, err)
+			os.Exit(1)
+		}
+		jsync := jiraSync{
+			Client:       jira.NewClient(*baseURL, os.Getenv("JIRA_EMAIL"), token),
+			JQL:          *jql,
+			Done:         *doneTransition,
+			ProgressFile: *progressFile,
+			ArchiveDir:   *outdir,
+			Limit:        *limit,
+			DryRun:       *dryRun,
+		}
+		imported, done, err := jsync.run()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(imported) == 0 && len(done) == 0 {
+			fmt.Printf("[%s] ✅ Nothing to sync with %s\n", ts(), *baseURL)
+		}
+	case "sync-pr-reviews":
+		fs := flag.NewFlagSet("sync-pr-reviews", flag.ExitOnError)
+		file := fs.String("file", resolveTasksFile(), "tasks file")
+		progressFile := fs.String("progress", resolveProgressFile(), "progress file")
+		limit := fs.Int("limit", 100, "most open pull requests to fetch")
+		dryRun := fs.Bool("dry-run", false, "print the criteria that would be added without changing anything")
+		parseFlags(fs, os.Args[2:])
+
+		if err := ensureCursorIterDir(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create %s directory: %v\n", CursorIterDir, err)
+			os.Exit(1)
+		}
+		reopened, err := prReviewSync{TasksFile: *file, ProgressFile: *progressFile, Limit: *limit, DryRun: *dryRun}.run()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(reopened) == 0 {
+			fmt.Printf("[%s] ✅ No new reviews requesting changes\n", ts())
+		} else if !*dryRun {
+			fmt.Printf("[%s] 💡 iterate-loop resumes reopened tasks; a running loop picks them up on its next iteration\n", ts())
+		}
+	case "accept-tasks":
+		fs := flag.NewFlagSet("accept-tasks", flag.ExitOnError)
+		milestone := fs.String("milestone", "", "staged milestone to move into tasks.md")
+		all := fs.Bool("all", false, "accept every staged milestone")
+		list := fs.Bool("list", false, "list staged milestones and exit")
+		parseFlags(fs, os.Args[2:])
+
+		data, err := os.ReadFile(stagedTasksPath())
+		milestones := tasks.ParseStaged(string(data))
+		if err != nil || len(milestones) == 0 {
+			fmt.Println("No staged tasks. Use 'cursor-iter add-feature --stage' to stage generated tasks.")
+			return
+		}
+		switch {
+		case *list:
+			printStagedMilestones(stagedTasksPath())
+		case *all:
+			for _, m := range milestones {
+				if err := acceptStagedMilestone(m.Name); err != nil {
+					fmt.Fprintf(os.Stderr, "error: %v\n", err)
+					os.Exit(1)
+				}
+			}
+		case *milestone != "":
+			if err := acceptStagedMilestone(*milestone); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+		default:
+			if err := reviewStagedMilestones(os.Stdin); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+		}
 	case "run-agent":
 		// Send ad-hoc request to cursor-agent/codex with control file references
 		fs := flag.NewFlagSet("run-agent", flag.ExitOnError)
 		prompt := fs.String("prompt", "", "ad-hoc request to send to cursor-agent/codex")
 		useCodex := fs.Bool("codex", false, "use codex CLI with gpt-5-codex model")
-		model := fs.String("model", envOr("MODEL", "auto"), "cursor-agent model or codex model (gpt-5-codex)")
+		useClaude := fs.Bool("claude", false, "use the claude CLI (Claude Code)")
+		model := fs.String("model", envOr("MODEL", "auto"), "cursor-agent, codex (gpt-5-codex) or claude (sonnet, opus) model")
 		dbg := fs.Bool("debug", debug, "enable verbose logging")
-		_ = fs.Parse(os.Args[2:])
+		showFull := fs.Bool("show-full-prompts", showFullPrompts, "print full prompts in debug logs instead of their hash and path")
+		outputFile := fs.String("output-file", "", "write the agent's final message to this file")
+		jsonOut := fs.Bool("json", false, "print the result as JSON on stdout; logs and agent output go to stderr")
+		agentAuthorSpec := fs.String("agent-author", envOr("AGENT_AUTHOR", ""), "git author and committer for agent commits, e.g. 'Cursor Autopilot <bot@example.com>'")
+		projectType := fs.String("project-type", envOr("PROJECT_TYPE", project.Auto), "kind of repository: code, docs, infra or auto to detect it")
+		parseFlags(fs, os.Args[2:])
+		agentBackend := primaryBackend(*useCodex, *useClaude)
+
+		// Keep stdout for the JSON result
+		resultOut := os.Stdout
+		if *jsonOut {
+			os.Stdout = os.Stderr
+		}
 
 		// Validate prompt is provided
 		if *prompt == "" {
@@ -1134,11 +4153,7 @@ Work on this task until all acceptance criteria are checked off and the task is
 			os.Exit(1)
 		}
 
-		// Set default model for codex if not specified
-		agentModel := *model
-		if *useCodex && *model == "auto" {
-			agentModel = "gpt-5-codex"
-		}
+		agentModel := runner.DefaultModel(agentBackend, *model)
 
 		// Build a comprehensive prompt with control file references
 		controlFilesList := []string{
@@ -1231,14 +4246,17 @@ The following control files are available for reference and may need to be updat
 
 Complete the user's request and ensure all control files are updated appropriately.
 REMEMBER: NEVER run dev servers or long-running processes - they will hang the agent.`, *prompt, strings.Join(existingControlFiles, "\n"))
+		enhancedPrompt += mustProjectType(*projectType, *dbg).PromptNote()
+		if note := sealedFilesNote(); note != "" {
+			enhancedPrompt += "\n\n" + note
+		}
+		if *jsonOut || *outputFile != "" {
+			enhancedPrompt += "\n\n" + agentResultNote
+		}
 
 		if *dbg {
 			fmt.Printf("[%s] 🚀 Running ad-hoc request with cursor-agent...\n", ts())
-			if *useCodex {
-				fmt.Printf("[%s] 🤖 Using codex (model: %s)\n", ts(), agentModel)
-			} else {
-				fmt.Printf("[%s] 🤖 Using cursor-agent (model: %s)\n", ts(), agentModel)
-			}
+			fmt.Printf("[%s] 🤖 Using %s (model: %s)\n", ts(), agentBackend, agentModel)
 			fmt.Printf("[%s] 📝 User request: %s\n", ts(), *prompt)
 			fmt.Printf("[%s] 📋 Control files available: %d\n", ts(), len(existingControlFiles))
 		}
@@ -1249,17 +4267,29 @@ REMEMBER: NEVER run dev servers or long-running processes - they will hang the a
 			fmt.Printf("[%s] 📊 Enhanced prompt size: %d bytes\n", ts(), len(enhancedPrompt))
 		}
 
-		// Run cursor-agent or codex
-		var runErr error
-		if *useCodex {
-			runErr = runner.CodexWithDebug(*dbg, agentModel, enhancedPrompt)
-		} else {
-			runErr = runner.CursorAgentWithDebug(*dbg, "--print", "--force", enhancedPrompt)
+		// Run the agent, capturing its output for the result
+		var captured bytes.Buffer
+		logPrompt(enhancedPrompt, *dbg, *showFull)
+		opts, flush := agentOptions(*dbg)
+		opts.Stdout = io.MultiWriter(opts.Stdout, &captured)
+		opts.Env = mustAgentAuthor(*agentAuthorSpec, false).Env("")
+		started := time.Now()
+		sealed := sealedControlFiles()
+		runErr := runner.RunPrompt(opts, agentBackend, agentModel, enhancedPrompt)
+		flush()
+		resealControlFiles(sealed)
+
+		result := newAgentResult(string(agentBackend), agentModel, captured.String(), time.Since(started), runErr)
+		if err := writeAgentResult(resultOut, result, *outputFile, *jsonOut); err != nil {
+			fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not write result: %v\n", ts(), err)
 		}
-
-		if runErr != nil {
+		switch result.Status {
+		case agentStatusError:
 			fmt.Fprintf(os.Stderr, "[%s] ❌ Ad-hoc request failed: %v\n", ts(), runErr)
-			os.Exit(1)
+			os.Exit(result.ExitCode)
+		case agentStatusFailed:
+			fmt.Fprintf(os.Stderr, "[%s] ❌ Agent reported the request failed: %v\n", ts(), result.Result["summary"])
+			os.Exit(result.ExitCode)
 		}
 
 		fmt.Printf("[%s] ✅ Ad-hoc request completed successfully!\n", ts())
@@ -1269,12 +4299,12 @@ REMEMBER: NEVER run dev servers or long-running processes - they will hang the a
 	case "reset":
 		// Remove the .cursor-iter directory and legacy files
 		fmt.Printf("Removing cursor-iter control files...\n")
-		
+
 		// Remove new location
 		if err := os.RemoveAll(CursorIterDir); err == nil {
 			fmt.Printf("Removed: %s/\n", CursorIterDir)
 		}
-		
+
 		// Also clean up any legacy files in the root (for backward compatibility)
 		legacyFiles := []string{
 			"architecture.md",
@@ -1298,7 +4328,7 @@ REMEMBER: NEVER run dev servers or long-running processes - they will hang the a
 				}
 			}
 		}
-		
+
 		if removed > 0 {
 			fmt.Printf("Reset complete. Removed %s/ directory and %d legacy files.\n", CursorIterDir, removed)
 		} else {
@@ -1313,6 +4343,7 @@ REMEMBER: NEVER run dev servers or long-running processes - they will hang the a
 		usage()
 		os.Exit(1)
 	}
+	showTip(os.Stderr, cmd)
 }
 
 // ensureCursorIterDir ensures the .cursor-iter directory exists
@@ -1325,7 +4356,98 @@ func getControlFilePath(filename string) string {
 	return filepath.Join(CursorIterDir, filename)
 }
 
+// controlFilePaths maps each control file name to the path it is read from,
+// honoring TASKS_FILE/PROGRESS_FILE and legacy locations
+func controlFilePaths() map[string]string {
+	paths := make(map[string]string, len(controlFileNames))
+	for _, name := range controlFileNames {
+		paths[name] = getControlFilePath(name)
+	}
+	paths["tasks.md"] = resolveTasksFile()
+	paths["progress.md"] = resolveProgressFile()
+	return paths
+}
+
+// snapshotDir is where control file snapshots are kept
+func snapshotDir() string {
+	return getControlFilePath("snapshots")
+}
+
+// startRun assigns a run ID and snapshots the control files at the run
+// boundary so diff-control-files can show what the run changed
+func startRun(debug bool) string {
+	runID := snapshot.NewID(time.Now())
+	if _, err := os.Stat(CursorIterDir); err != nil {
+		return runID
+	}
+	target, err := snapshot.Take(snapshotDir(), runID, controlFilePaths(), snapshot.DefaultKeep)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not snapshot control files: %v\n", ts(), err)
+	} else if debug {
+		fmt.Printf("[%s] 📸 Snapshotted control files to %s\n", ts(), target)
+	}
+	return runID
+}
+
+// promptLogDir holds every prompt dispatched in debug mode, named by content hash
+func promptLogDir() string {
+	return getControlFilePath(filepath.Join("logs", "prompts"))
+}
+
+// savePrompt stores prompt under promptLogDir and returns its short content
+// hash and path. Identical prompts share one file.
+func savePrompt(prompt string) (string, string, error) {
+	sum := sha256.Sum256([]byte(prompt))
+	hash := hex.EncodeToString(sum[:])[:12]
+	path := filepath.Join(promptLogDir(), hash+".md")
+	if _, err := os.Stat(path); err == nil {
+		return hash, path, nil
+	}
+	if err := os.MkdirAll(promptLogDir(), 0755); err != nil {
+		return hash, "", err
+	}
+	if err := os.WriteFile(path, []byte(prompt), 0644); err != nil {
+		return hash, "", err
+	}
+	return hash, path, nil
+}
+
+// logPrompt notes a dispatched prompt in debug mode. The prompt boilerplate is
+// the same for every dispatch, so by default only its hash and saved path are
+// logged; showFull prints the whole prompt instead.
+func logPrompt(prompt string, debug, showFull bool) {
+	if !debug {
+		return
+	}
+	if showFull {
+		fmt.Printf("[%s] 📝 Prompt (%d bytes):\n%s\n", ts(), len(prompt), prompt)
+		return
+	}
+	hash, path, err := savePrompt(prompt)
+	if err != nil {
+		fmt.Printf("[%s] 📝 Prompt sha256:%s (%d bytes, not saved: %v)\n", ts(), hash, len(prompt), err)
+		return
+	}
+	fmt.Printf("[%s] 📝 Prompt sha256:%s (%d bytes) saved to %s\n", ts(), hash, len(prompt), path)
+}
+
+// agentOptions returns runner options that collapse identical consecutive
+// lines of agent output. The returned func flushes any pending output and
+// must be called once the agent exits.
+func agentOptions(debug bool) (runner.Options, func()) {
+	stdout := stream.NewDedupWriter(os.Stdout)
+	stderr := stream.NewDedupWriter(os.Stderr)
+	opts := runner.Options{Debug: debug, Stdout: stdout, Stderr: stderr}
+	return opts, func() {
+		stdout.Flush()
+		stderr.Flush()
+	}
+}
+
 func resolveTasksFile() string {
+	if v, ok := configPathSetting("tasks-file"); ok {
+		return v
+	}
 	if v := os.Getenv("TASKS_FILE"); v != "" {
 		return v
 	}
@@ -1345,6 +4467,9 @@ func resolveTasksFile() string {
 }
 
 func resolveProgressFile() string {
+	if v, ok := configPathSetting("progress-file"); ok {
+		return v
+	}
 	if v := os.Getenv("PROGRESS_FILE"); v != "" {
 		return v
 	}
@@ -1363,64 +4488,127 @@ func resolveProgressFile() string {
 	return newPath // Return new location as default
 }
 
-func envOr(k, def string) string {
-	if v := os.Getenv(k); v != "" {
-		return v
-	}
-	return def
+// updateControlFile rewrites a control file such as tasks.md or progress.md
+// with what edit makes of its current content. The file is read and
+// replaced under its lock, in one step, so the loop, other commands and
+// the serve API writing it at once don't lose each other's updates.
+func updateControlFile(path string, edit func(content string) string) error {
+	return atomicfile.Update(path, func(content []byte) ([]byte, error) {
+		return []byte(edit(string(content))), nil
+	})
 }
 
-func ts() string { return time.Now().Format("15:04:05") }
-
-// fetchPromptFromGitHub fetches a prompt file from GitHub if it doesn't exist locally
-func fetchPromptFromGitHub(promptFile string) error {
-	// Check if file already exists locally
-	if _, err := os.Stat(promptFile); err == nil {
-		return nil // File exists, no need to fetch
+// writeProgress replaces progress.md with ours, what cursor-iter made of
+// base, the content it read earlier. When the file changed since, usually
+// because an agent updated it, the two are merged entry by entry so neither
+// side's entries are lost. Callers hold the file's lock.
+func writeProgress(path, base, ours string) error {
+	theirs, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
 	}
+	if err == nil {
+		ours = tasks.MergeProgress(base, ours, string(theirs))
+	}
+	return atomicfile.WriteFile(path, []byte(ours), 0644)
+}
 
-	// Extract the filename from the path
-	filename := filepath.Base(promptFile)
-
-	// GitHub repository details
-	owner := "cheddarwhizzy"
-	repo := "cursor-autopilot"
-	branch := "main"
-
-	// Construct GitHub raw URL
-	url := fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s/cursor-agent-iteration/prompts/%s",
-		owner, repo, branch, filename)
+// mustParseChain parses a --fallback value, exiting on unknown backends
+func mustParseChain(spec string) []runner.Backend {
+	chain, err := runner.ParseChain(spec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid --fallback: %v\n", err)
+		os.Exit(1)
+	}
+	return chain
+}
 
-	fmt.Printf("[%s] Fetching %s from GitHub...\n", ts(), filename)
+// primaryBackend returns the backend selected by the --codex or --claude
+// flag, exiting if both are set
+func primaryBackend(useCodex, useClaude bool) runner.Backend {
+	switch {
+	case useCodex && useClaude:
+		fmt.Fprintln(os.Stderr, "error: --codex and --claude can't be combined")
+		os.Exit(1)
+	case useCodex:
+		return runner.BackendCodex
+	case useClaude:
+		return runner.BackendClaude
+	}
+	return runner.BackendCursorAgent
+}
 
-	// Make HTTP request
-	resp, err := http.Get(url)
-	if err != nil {
-		return fmt.Errorf("failed to fetch %s from GitHub: %v", filename, err)
+// chainFrom returns primary followed by the fallbacks, without duplicates
+func chainFrom(primary runner.Backend, fallbacks []runner.Backend) []runner.Backend {
+	chain := []runner.Backend{primary}
+	for _, b := range fallbacks {
+		if b != primary {
+			chain = append(chain, b)
+		}
 	}
-	defer resp.Body.Close()
+	return chain
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to fetch %s: HTTP %d", filename, resp.StatusCode)
+// printBackendStats logs how each backend fared during an iterate-loop run
+func printBackendStats(tr *TaskRunner) {
+	stats := tr.BackendStats()
+	if len(stats) == 0 {
+		return
+	}
+	backends := make([]string, 0, len(stats))
+	for b := range stats {
+		backends = append(backends, string(b))
 	}
+	sort.Strings(backends)
+	fmt.Printf("[%s] 📊 Backend results:\n", ts())
+	for _, name := range backends {
+		st := stats[runner.Backend(name)]
+		fmt.Printf("  - %s: %d runs, %d succeeded, %d failed\n", name, st.Attempts, st.Successes, st.Failures)
+	}
+}
 
-	// Create directory if it doesn't exist
-	dir := filepath.Dir(promptFile)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory %s: %v", dir, err)
+func envOr(k, def string) string {
+	if v := os.Getenv(k); v != "" {
+		return v
 	}
+	return def
+}
 
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
+// mustCompletionFormat parses the --completion-format flag or exits
+func mustCompletionFormat(template string) tasks.CompletionFormat {
+	format, err := tasks.ParseCompletionFormat(template)
 	if err != nil {
-		return fmt.Errorf("failed to read response body: %v", err)
+		fmt.Fprintf(os.Stderr, "invalid --completion-format: %v\n", err)
+		os.Exit(1)
 	}
+	return format
+}
 
-	// Write file
-	if err := os.WriteFile(promptFile, body, 0644); err != nil {
-		return fmt.Errorf("failed to write %s: %v", promptFile, err)
+// envInt returns the integer value of an environment variable, or def if it
+// is unset or not a number
+func envInt(k string, def int) int {
+	if n, err := strconv.Atoi(os.Getenv(k)); err == nil {
+		return n
 	}
+	return def
+}
 
-	fmt.Printf("[%s] ✅ Successfully fetched %s\n", ts(), filename)
-	return nil
+// envDuration returns the duration value of an environment variable, or def
+// if it is unset or not a duration
+func envDuration(k string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(k)); err == nil {
+		return d
+	}
+	return def
+}
+
+// envFloat returns the float value of an environment variable, or def if it
+// is unset or not a number
+func envFloat(k string, def float64) float64 {
+	if f, err := strconv.ParseFloat(os.Getenv(k), 64); err == nil {
+		return f
+	}
+	return def
 }
+
+func ts() string { return time.Now().Format("15:04:05") }
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/metrics"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// agentDurationBuckets are the upper bounds, in seconds, of the agent run
+// duration histogram; runs take from seconds to most of an hour
+var agentDurationBuckets = []float64{10, 30, 60, 120, 300, 600, 1200, 1800, 3600}
+
+// loopMetrics are the Prometheus metrics of iterate-loop; nil, recording
+// nothing, unless --metrics-addr is set
+var loopMetrics *iterMetrics
+
+// iterMetrics are the metrics iterate-loop updates as it runs
+type iterMetrics struct {
+	registry   *metrics.Registry
+	tasks      *metrics.Gauge
+	runs       *metrics.Counter
+	durations  *metrics.Histogram
+	retries    *metrics.Counter
+	iterations *metrics.Counter
+	lastRun    *metrics.Gauge
+}
+
+// newIterMetrics registers the metrics of a loop running tr
+func newIterMetrics(tr *TaskRunner) *iterMetrics {
+	r := metrics.NewRegistry()
+	r.GaugeFunc("cursor_iter_active_tasks", "Tasks with a running agent.", func() float64 { return float64(tr.ActiveCount()) })
+	r.GaugeFunc("cursor_iter_max_active_tasks", "Maximum number of tasks running at once (--max-in-progress).", func() float64 { return float64(tr.maxActive) })
+	return &iterMetrics{
+		registry:   r,
+		tasks:      r.Gauge("cursor_iter_tasks", "Tasks in tasks.md by status.", "status"),
+		runs:       r.Counter("cursor_iter_agent_runs_total", "Finished agent runs by backend and outcome (completed, incomplete or failed).", "backend", "outcome"),
+		durations:  r.Histogram("cursor_iter_agent_duration_seconds", "Duration of agent runs.", agentDurationBuckets, "backend"),
+		retries:    r.Counter("cursor_iter_agent_retries_total", "Agent retries by reason.", "reason"),
+		iterations: r.Counter("cursor_iter_iterations_total", "Iterations of the loop."),
+		lastRun:    r.Gauge("cursor_iter_last_run_timestamp_seconds", "Unix time the last agent run finished."),
+	}
+}
+
+// serveMetrics serves /metrics on addr, e.g. ":9464", in the background.
+// A port without a colon listens on all interfaces.
+func serveMetrics(addr string, m *iterMetrics) (net.Listener, error) {
+	if _, err := strconv.Atoi(addr); err == nil {
+		addr = ":" + addr
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.registry.Handler())
+	go http.Serve(ln, mux)
+	return ln, nil
+}
+
+// observeRun records a finished agent run
+func (m *iterMetrics) observeRun(entry journal.Entry) {
+	if m == nil {
+		return
+	}
+	m.runs.Inc(entry.Backend, entry.Outcome)
+	m.durations.Observe(float64(entry.DurationMs)/1000, entry.Backend)
+	m.lastRun.Set(float64(entry.Time.Unix()))
+}
+
+// retry records an agent retry
+func (m *iterMetrics) retry(reason string) {
+	if m == nil {
+		return
+	}
+	m.retries.Inc(reason)
+}
+
+// tick records the start of an iteration and the task totals it saw
+func (m *iterMetrics) tick(totals tasks.StatusTotals) {
+	if m == nil {
+		return
+	}
+	m.iterations.Inc()
+	m.tasks.Set(float64(totals.Completed), "completed")
+	m.tasks.Set(float64(totals.InProgress), "in_progress")
+	m.tasks.Set(float64(totals.Pending), "pending")
+	m.tasks.Set(float64(totals.Blocked), "blocked")
+	m.tasks.Set(float64(totals.Failed), "failed")
+}
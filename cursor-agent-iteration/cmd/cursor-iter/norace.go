@@ -0,0 +1,6 @@
+//go:build !race
+
+package main
+
+// raceEnabled reports whether cursor-iter was built with the race detector
+const raceEnabled = false
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/notes"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/runner"
+)
+
+// taskNotes writes an implementation note for every completed task. A nil
+// *taskNotes writes nothing.
+type taskNotes struct {
+	dir string
+	// summarize sends a prompt to the agent and returns its reply
+	summarize func(prompt string) (string, error)
+}
+
+// newTaskNotes returns a note writer for the --task-notes flag, or nil when
+// notes are disabled
+func newTaskNotes(enabled bool, dir string, backend runner.Backend, model string, debug bool) *taskNotes {
+	if !enabled {
+		return nil
+	}
+	return &taskNotes{
+		dir: dir,
+		summarize: func(prompt string) (string, error) {
+			logPrompt(prompt, debug, false)
+			var out bytes.Buffer
+			err := runner.RunPrompt(runner.Options{Debug: debug, Stdout: &out}, backend, model, prompt)
+			return out.String(), err
+		},
+	}
+}
+
+// taskDiff returns the changes made between two commits, or between a commit
+// and the working tree when after is empty or the same commit
+func taskDiff(before, after string) string {
+	if before == "" {
+		return ""
+	}
+	args := []string{"diff", "--stat", "--patch", before}
+	if after != "" && after != before {
+		args = []string{"diff", "--stat", "--patch", before + ".." + after}
+	}
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
+
+// Write summarizes a completed task and saves the note, returning its path
+func (n *taskNotes) Write(title, details, headBefore, headAfter, transcript string) (string, error) {
+	if n == nil {
+		return "", nil
+	}
+	reply, err := n.summarize(notes.Prompt(notes.Input{
+		Title:      title,
+		Details:    details,
+		Diff:       taskDiff(headBefore, headAfter),
+		Transcript: transcript,
+	}))
+	if err != nil {
+		return "", fmt.Errorf("summarizing %s: %v", title, err)
+	}
+	return notes.Write(n.dir, taskSlug(title), notes.Clean(title, reply))
+}
+
+// WriteRun writes the note for a task run that just completed
+func (n *taskNotes) WriteRun(run *TaskExecution, details string) {
+	if n == nil || run == nil {
+		return
+	}
+	var transcript string
+	if run.Output != nil {
+		transcript = string(run.Output.Snapshot())
+	}
+	path, err := n.Write(run.TaskTitle, details, run.HeadBefore, gitHead(), transcript)
+	if err != nil {
+		fmt.Printf("[%s] ⚠️ Could not write task note: %v\n", ts(), err)
+		return
+	}
+	fmt.Printf("[%s] 📝 Wrote task note %s\n", ts(), path)
+}
+
+// WriteFromJournal writes the note for a task from its last completed run
+// in the journal, for tasks that finished without --task-notes
+func (n *taskNotes) WriteFromJournal(title, details string) (string, error) {
+	entries, err := journal.Read(journalPath())
+	if err != nil {
+		return "", err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if e.Task != title || e.Outcome != journal.OutcomeCompleted {
+			continue
+		}
+		var transcript []byte
+		if e.LogPath != "" {
+			transcript, _ = os.ReadFile(e.LogPath)
+		}
+		return n.Write(title, details, e.HeadBefore, e.HeadAfter, string(transcript))
+	}
+	return "", fmt.Errorf("no completed run of %q in the run journal", title)
+}
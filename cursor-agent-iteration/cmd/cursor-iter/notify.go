@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/notify"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// defaultNotifyAfterFailures is the --notify-after-failures default
+const defaultNotifyAfterFailures = 3
+
+// loopNotifier posts the events of iterate-loop to --notify-webhook; nil,
+// posting nothing, unless it is set
+var loopNotifier *taskNotifier
+
+// taskNotifier turns what iterate-loop reports into webhook messages
+type taskNotifier struct {
+	*notify.Notifier
+	// repo names the repository in messages, for webhooks several loops
+	// post to
+	repo string
+	// failAfter is how many failed runs in a row of a task are reported
+	failAfter int
+	// budget is the --max-attempts budget; a task running out of it is
+	// reported as exhausted instead
+	budget       retryBudget
+	tasksFile    string
+	progressFile string
+}
+
+// mustNotifier returns the notifier of the --notify-* flags, nil when no
+// webhook is given, exiting on invalid values
+func mustNotifier(webhook, events string, failAfter int, budget retryBudget, tasksFile, progressFile string) *taskNotifier {
+	if webhook == "" {
+		return nil
+	}
+	if u, err := url.Parse(webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		fmt.Fprintf(os.Stderr, "invalid --notify-webhook %q: want an http or https URL\n", webhook)
+		os.Exit(1)
+	}
+	wanted, err := notify.ParseEvents(events)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid --notify-on: %v\n", err)
+		os.Exit(1)
+	}
+	if failAfter < 1 {
+		fmt.Fprintf(os.Stderr, "invalid --notify-after-failures %d: want 1 or more\n", failAfter)
+		os.Exit(1)
+	}
+	n := notify.New(webhook, wanted)
+	n.OnError = func(m notify.Message, err error) {
+		fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not notify the webhook of %s: %v\n", ts(), m.Event, err)
+	}
+	repo := "repository"
+	if cwd, err := os.Getwd(); err == nil {
+		repo = filepath.Base(cwd)
+	}
+	return &taskNotifier{Notifier: n, repo: repo, failAfter: failAfter, budget: budget, tasksFile: tasksFile, progressFile: progressFile}
+}
+
+// String describes the notifier for the start of the loop
+func (n *taskNotifier) String() string {
+	return fmt.Sprintf("Notifying the %s webhook of %s", n.Kind, notify.Describe(n.Events))
+}
+
+// wants reports whether event is posted; a nil notifier posts nothing
+func (n *taskNotifier) wants(event string) bool {
+	return n != nil && n.Wants(event)
+}
+
+// webhookPayload is the body posted to generic webhooks, documented for
+// its schema; send builds it from the fields of each event. Slack and
+// Discord get the text alone.
+type webhookPayload struct {
+	Event string `json:"event"`
+	Text  string `json:"text"`
+	Repo  string `json:"repo"`
+	Task  string `json:"task,omitempty"`
+	// Backend and Model are set for started and completed
+	Backend    string `json:"backend,omitempty"`
+	Model      string `json:"model,omitempty"`
+	DurationMs int64  `json:"duration_ms,omitempty"`
+	// Attempts, Classification, Error and Reason are set for failed and
+	// blocked
+	Attempts       int    `json:"attempts,omitempty"`
+	Classification string `json:"classification,omitempty"`
+	Error          string `json:"error,omitempty"`
+	Reason         string `json:"reason,omitempty"`
+	// RunID, Iterations and Totals are set for finished, with Reason
+	RunID      string              `json:"run_id,omitempty"`
+	Iterations int                 `json:"iterations,omitempty"`
+	Totals     *tasks.StatusTotals `json:"totals,omitempty"`
+}
+
+// send posts text with the task's fields for generic webhooks
+func (n *taskNotifier) send(event, title, text string, fields map[string]any) {
+	if fields == nil {
+		fields = map[string]any{}
+	}
+	fields["repo"] = n.repo
+	if title != "" {
+		fields["task"] = title
+	}
+	n.Send(notify.Message{Event: event, Text: fmt.Sprintf("[%s] %s", n.repo, text), Fields: fields})
+}
+
+// started reports the dispatch of a task run
+func (n *taskNotifier) started(run *TaskExecution) {
+	if !n.wants(notify.Started) {
+		return
+	}
+	n.send(notify.Started, run.TaskTitle, fmt.Sprintf("🚀 Started '%s' on %s/%s", run.TaskTitle, run.Backend, run.Model),
+		map[string]any{"backend": string(run.Backend), "model": run.Model})
+}
+
+// runOutcome reports a completed task, and a task whose runs failed
+// failAfter times in a row. Interrupted runs don't count, as in the retry
+// budget.
+func (n *taskNotifier) runOutcome(entry journal.Entry) {
+	if n == nil {
+		return
+	}
+	if !entry.Failed() {
+		if n.wants(notify.Completed) {
+			n.send(notify.Completed, entry.Task, fmt.Sprintf("✅ Completed '%s' in %s", entry.Task, entry.Duration().Round(time.Second)),
+				map[string]any{"backend": entry.Backend, "model": entry.Model, "duration_ms": entry.DurationMs})
+		}
+		return
+	}
+	if !n.wants(notify.Failed) || entry.Classification == journal.ClassInterrupted {
+		return
+	}
+	attempts := n.budget.Attempts(entry.Task)
+	if attempts != n.failAfter || (n.budget != 0 && attempts >= int(n.budget)) {
+		return
+	}
+	reason := entry.Classification
+	if entry.Error != "" {
+		reason += ": " + entry.Error
+	}
+	n.send(notify.Failed, entry.Task, fmt.Sprintf("❌ '%s' failed %d runs in a row (%s)", entry.Task, attempts, reason),
+		map[string]any{"attempts": attempts, "classification": entry.Classification, "error": entry.Error})
+}
+
+// exhausted reports a task moved to Failed once it used up its attempts
+func (n *taskNotifier) exhausted(title string, attempts int, reason string) {
+	if !n.wants(notify.Failed) {
+		return
+	}
+	n.send(notify.Failed, title, fmt.Sprintf("❌ Moved '%s' to Failed: %s", title, reason),
+		map[string]any{"attempts": attempts, "reason": reason})
+}
+
+// blocked reports a task moved to Blocked
+func (n *taskNotifier) blocked(title, reason string) {
+	if !n.wants(notify.Blocked) {
+		return
+	}
+	n.send(notify.Blocked, title, fmt.Sprintf("⛔ '%s' is blocked: %s", title, reason), map[string]any{"reason": reason})
+}
+
+// finished reports why the loop stopped, with the task totals, and waits
+// for the posts still in flight
+func (n *taskNotifier) finished(runID, reason string, iterations int) {
+	if n == nil {
+		return
+	}
+	defer n.Wait()
+	if !n.wants(notify.Finished) {
+		return
+	}
+	tasksMd, _ := os.ReadFile(n.tasksFile)
+	progressMd, _ := os.ReadFile(n.progressFile)
+	t := tasks.Summarize(string(tasksMd), string(progressMd)).Totals
+	n.send(notify.Finished, "", fmt.Sprintf("🏁 iterate-loop stopped (%s): %d/%d tasks completed, %d pending, %d blocked, %d failed",
+		reason, t.Completed, t.Total, t.Pending+t.InProgress, t.Blocked, t.Failed),
+		map[string]any{"run_id": runID, "reason": reason, "iterations": iterations, "totals": t})
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/worktree"
+)
+
+// featureBranchPrefix starts the branches --open-pr puts a task's commits on
+const featureBranchPrefix = "feature/"
+
+// prOpener puts the commits of every completed task on a feature branch of
+// its own and opens a pull request for it, so each task can be reviewed on
+// its own. A nil *prOpener opens none.
+type prOpener struct {
+	// repo is the absolute path of the checkout
+	repo   string
+	remote string
+	// base is the branch the pull requests are opened against
+	base string
+}
+
+// mustPROpener returns the opener of the --open-pr flag, or nil when it is
+// off. base "" is the branch checked out now. It exits outside a git
+// repository with a branch checked out and without the gh CLI.
+func mustPROpener(open bool, remote, base string) *prOpener {
+	if !open {
+		return nil
+	}
+	repo, err := filepath.Abs(".")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	if base == "" {
+		if base, err = worktree.CurrentBranch(repo); err != nil {
+			fmt.Fprintf(os.Stderr, "--open-pr needs a git repository with a branch checked out, or --pr-base: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if _, err := exec.LookPath("gh"); err != nil {
+		fmt.Fprintf(os.Stderr, "--open-pr opens pull requests with the GitHub CLI, which is not installed: %v\n", err)
+		os.Exit(1)
+	}
+	return &prOpener{repo: repo, remote: remote, base: base}
+}
+
+// String says where the pull requests go
+func (o *prOpener) String() string {
+	return fmt.Sprintf("completed tasks get a %s<task> branch pushed to %s and a pull request for %s", featureBranchPrefix, o.remote, o.base)
+}
+
+// Branch is the feature branch of a task
+func (o *prOpener) Branch(title string) string {
+	return featureBranchPrefix + taskSlug(title)
+}
+
+// Open puts the commits the run made on the task's feature branch, started
+// from where the run started, and opens a pull request for it; a second
+// run of the task adds its commits to the branch and its pull request. It
+// returns the pull request's URL, "" when the run made no commits. The
+// checkout itself is left as it is.
+func (o *prOpener) Open(run *TaskExecution, tasksFile, progressFile string) (string, error) {
+	if o == nil || run == nil || run.HeadBefore == "" {
+		return "", nil
+	}
+	head := gitHead()
+	if head == "" || head == run.HeadBefore {
+		return "", nil
+	}
+	commits, err := commitsBetween(run.HeadBefore, head)
+	if err != nil {
+		return "", fmt.Errorf("could not list the commits of the run: %v", err)
+	}
+	hashes := taskCommits(commits, run.TaskTitle)
+	if len(hashes) == 0 {
+		return "", nil
+	}
+	branch := o.Branch(run.TaskTitle)
+	if err := worktree.CherryPick(o.repo, branch, run.HeadBefore, hashes); err != nil {
+		return "", err
+	}
+	body, err := describePR(tasksFile, progressFile, run.TaskTitle)
+	if err != nil {
+		body = run.TaskTitle
+	}
+	return worktree.PullRequest(o.repo, o.remote, branch, o.base, run.TaskTitle, body)
+}
+
+// taskCommits returns the hashes of the commits that belong to a task,
+// leaving out merges. When the agents commit with the task in the committer
+// name (--author-task-id), commits of other tasks that ran at the same time
+// are left out too.
+func taskCommits(commits []commitInfo, title string) []string {
+	marker := fmt.Sprintf("(task %s)", taskSlug(title))
+	tagged := false
+	for _, c := range commits {
+		if strings.Contains(c.CommitterName, "(task ") {
+			tagged = true
+			break
+		}
+	}
+	var hashes []string
+	for _, c := range commits {
+		if c.Merge || (tagged && !strings.HasSuffix(c.CommitterName, marker)) {
+			continue
+		}
+		hashes = append(hashes, c.Hash)
+	}
+	return hashes
+}
+
+// openPR opens the pull request of a completed task, warning when it can't;
+// the task stays completed either way
+func (o *prOpener) openPR(run *TaskExecution, tasksFile, progressFile string) {
+	if o == nil || run == nil {
+		return
+	}
+	url, err := o.Open(run, tasksFile, progressFile)
+	if url != "" {
+		recordPR(progressFile, run.TaskTitle, url)
+	}
+	switch {
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not open a pull request for '%s': %v\n", ts(), run.TaskTitle, err)
+	case url == "":
+		fmt.Printf("[%s] 📬 No commits to open a pull request with: %s\n", ts(), run.TaskTitle)
+	default:
+		fmt.Printf("[%s] 📬 Opened %s for '%s' from %s\n", ts(), url, run.TaskTitle, o.Branch(run.TaskTitle))
+	}
+}
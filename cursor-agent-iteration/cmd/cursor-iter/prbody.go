@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/prbody"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// describePR returns the description of a new pull request for a task: its
+// context and acceptance criteria from tasks.md, its runs in the journal and
+// the notes of its progress.md entry
+func describePR(tasksFile, progressFile, title string) (string, error) {
+	task, err := findTask(tasksFile, title)
+	if err != nil {
+		return "", err
+	}
+	entries, err := journal.Read(journalPath())
+	if err != nil {
+		return "", err
+	}
+	progress, _ := os.ReadFile(progressFile)
+	notes := tasks.ParseProgressFor(string(progress), []string{task.Title})[task.Title].Notes
+	return prbody.Describe(task.Context, notes, task.Criteria, prbody.Results(entries, task.Title)), nil
+}
+
+// syncPRBody returns body with its criteria section updated from the task's
+// acceptance criteria in tasks.md and its runs in the journal
+func syncPRBody(tasksFile, title, body string) (string, error) {
+	task, err := findTask(tasksFile, title)
+	if err != nil {
+		return "", err
+	}
+	entries, err := journal.Read(journalPath())
+	if err != nil {
+		return "", err
+	}
+	return prbody.Sync(body, task.Criteria, prbody.Results(entries, task.Title)), nil
+}
+
+// findTask returns the task of tasks.md with the title
+func findTask(tasksFile, title string) (tasks.Task, error) {
+	data, err := os.ReadFile(tasksFile)
+	if err != nil {
+		return tasks.Task{}, err
+	}
+	for _, t := range tasks.ParseTasks(string(data)) {
+		if strings.EqualFold(t.Title, title) {
+			return t, nil
+		}
+	}
+	return tasks.Task{}, fmt.Errorf("no task '%s' in %s", title, tasksFile)
+}
+
+// readBodyFile reads a PR description from a file, or from stdin for "-"
+func readBodyFile(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	if path == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		return string(data), err
+	}
+	data, err := os.ReadFile(path)
+	return string(data), err
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/compress"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/project"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// previewFile is a control file the agent can read on top of its prompt
+type previewFile struct {
+	Name   string
+	Tokens int // estimated
+}
+
+// promptPreview is the prompt iterate would send for a task, built without
+// starting an agent
+type promptPreview struct {
+	Task    string
+	Variant string
+	Prompt  string
+	Files   []previewFile
+}
+
+// previewTaskTitle returns the task to preview: title if tasks.md has it,
+// or with "" the task iterate would work on, the first one in progress or
+// else the next pending one
+func previewTaskTitle(tasksMd, progressMd, title string) (string, error) {
+	if title == "" {
+		if running := tasks.GetAllInProgressTasks(tasksMd, progressMd); len(running) > 0 {
+			return running[0].Title, nil
+		}
+		if next := configLabelDefaults().NextPendingTask(tasksMd, progressMd); next != nil {
+			return next.Title, nil
+		}
+		return "", fmt.Errorf("no task is in progress or pending; name one with --task")
+	}
+	for _, t := range tasks.ParseTasks(tasksMd) {
+		if t.Title == title {
+			return title, nil
+		}
+	}
+	return "", fmt.Errorf("no task %q in tasks.md", title)
+}
+
+// previewPrompt builds the prompt of a task as iterate does, with the notes
+// iterate adds at dispatch. Task paths are never rewritten.
+func previewPrompt(kind project.Type, variant, title, details string, budget int, notes ...string) promptPreview {
+	notes = append(notes, regroundTask(title, details, false, false), contextBudgetNote(budget), reviewFollowUp(title), checksFollowUp(title), sealedFilesNote())
+	p := promptPreview{Task: title, Variant: variant, Prompt: variantPrompt(kind, variant, details, glossaryFor(details), notes...)}
+	for _, name := range controlFileNames {
+		data, err := readControlFile(name)
+		if err != nil {
+			continue
+		}
+		p.Files = append(p.Files, previewFile{Name: name, Tokens: compress.EstimateTokens(string(data))})
+	}
+	return p
+}
+
+// writePromptSize writes the size of a previewed prompt and of the control
+// files the agent can read on top of it
+func writePromptSize(w io.Writer, p promptPreview) {
+	tokens := compress.EstimateTokens(p.Prompt)
+	fmt.Fprintf(w, "📏 %s prompt for '%s': %d bytes, ~%d tokens\n", p.Variant, p.Task, len(p.Prompt), tokens)
+	if len(p.Files) == 0 {
+		return
+	}
+	files := 0
+	for _, f := range p.Files {
+		files += f.Tokens
+	}
+	fmt.Fprintf(w, "📚 Control files the agent can read: ~%d tokens\n", files)
+	for _, f := range p.Files {
+		fmt.Fprintf(w, "   %-16s ~%d\n", f.Name, f.Tokens)
+	}
+	fmt.Fprintf(w, "🧮 Up to ~%d tokens of context before the agent opens any source file\n", tokens+files)
+}
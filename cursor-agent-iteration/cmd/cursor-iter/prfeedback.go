@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/prfeedback"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/worktree"
+)
+
+// prReviewsPath records the reviews sync-pr-reviews turned into criteria,
+// by pull request URL, so each review is only taken once
+func prReviewsPath() string {
+	return getControlFilePath("pr-reviews.json")
+}
+
+// taskPR is an open pull request of a task, from --open-pr or --worktree pr
+type taskPR struct {
+	Number int    `json:"number"`
+	URL    string `json:"url"`
+	Branch string `json:"headRefName"`
+}
+
+// prReviewSync is what sync-pr-reviews does: add what reviewers of the
+// tasks' open pull requests asked for to the tasks as criteria, and reopen
+// them, so the loop sends them back to their agents
+type prReviewSync struct {
+	TasksFile    string
+	ProgressFile string
+	// Limit caps the pull requests fetched
+	Limit  int
+	DryRun bool
+}
+
+// run returns the titles of the tasks it reopened
+func (s prReviewSync) run() ([]string, error) {
+	if _, err := exec.LookPath("gh"); err != nil {
+		return nil, fmt.Errorf("sync-pr-reviews reads reviews with the GitHub CLI, which is not installed: %v", err)
+	}
+	seen, err := readPRReviews()
+	if err != nil {
+		return nil, err
+	}
+	out, err := gh("pr", "list", "--state", "open", "--json", "number,url,headRefName", "--limit", fmt.Sprint(s.Limit))
+	if err != nil {
+		return nil, fmt.Errorf("could not list pull requests: %v", err)
+	}
+	var prs []taskPR
+	if err := json.Unmarshal([]byte(out), &prs); err != nil {
+		return nil, fmt.Errorf("could not read the pull requests: %v", err)
+	}
+	tasksMd, err := os.ReadFile(s.TasksFile)
+	if err != nil {
+		return nil, err
+	}
+	bySlug := make(map[string]string)
+	for _, t := range tasks.ParseTasks(string(tasksMd)) {
+		bySlug[taskSlug(t.Title)] = t.Title
+	}
+
+	var reopened []string
+	for _, pr := range prs {
+		title := bySlug[prTaskSlug(pr.Branch)]
+		if title == "" {
+			continue
+		}
+		added, err := s.apply(pr, title, seen)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not read the reviews of %s: %v\n", ts(), pr.URL, err)
+			continue
+		}
+		if added {
+			reopened = append(reopened, title)
+		}
+	}
+	if s.DryRun {
+		return reopened, nil
+	}
+	return reopened, writePRReviews(seen)
+}
+
+// apply adds the criteria of the new reviews of pr requesting changes to
+// the task, reopens it and records the reviews in seen. It reports whether
+// the task got new criteria.
+func (s prReviewSync) apply(pr taskPR, title string, seen map[string][]int64) (bool, error) {
+	var reviews []prfeedback.Review
+	var comments []prfeedback.Comment
+	if err := ghPages(&reviews, "api", "--paginate", fmt.Sprintf("repos/{owner}/{repo}/pulls/%d/reviews", pr.Number)); err != nil {
+		return false, err
+	}
+	if err := ghPages(&comments, "api", "--paginate", fmt.Sprintf("repos/{owner}/{repo}/pulls/%d/comments", pr.Number)); err != nil {
+		return false, err
+	}
+	done := make(map[int64]bool)
+	for _, id := range seen[pr.URL] {
+		done[id] = true
+	}
+	criteria, ids := prfeedback.Criteria(reviews, comments, done)
+	if len(ids) == 0 {
+		return false, nil
+	}
+
+	if s.DryRun {
+		tasksMd, err := os.ReadFile(s.TasksFile)
+		if err != nil {
+			return false, err
+		}
+		_, added := tasks.AddCriteria(string(tasksMd), title, criteria)
+		for _, c := range added {
+			fmt.Printf("[%s] 📝 Would add to '%s': %s\n", ts(), title, c)
+		}
+		return len(added) > 0, nil
+	}
+	// The files are read again for every pull request, since the last one
+	// changed them
+	var added []string
+	if err := updateControlFile(s.TasksFile, func(tasksMd string) string {
+		var updated string
+		updated, added = tasks.AddCriteria(tasksMd, title, criteria)
+		return updated
+	}); err != nil {
+		return false, err
+	}
+	seen[pr.URL] = append(seen[pr.URL], ids...)
+	if len(added) == 0 {
+		return false, nil
+	}
+	if err := updateControlFile(s.ProgressFile, func(progress string) string {
+		return tasks.ReopenTask(progress, title)
+	}); err != nil {
+		return false, err
+	}
+	for _, c := range added {
+		fmt.Printf("  - %s\n", c)
+	}
+	fmt.Printf("[%s] 🔁 Reopened '%s' with %d criteria from the review of %s\n", ts(), title, len(added), pr.URL)
+	return true, nil
+}
+
+// prTaskSlug is the slug of the task a pull request's branch belongs to, or
+// "" for branches cursor-iter didn't create
+func prTaskSlug(branch string) string {
+	for _, prefix := range []string{featureBranchPrefix, worktree.BranchPrefix} {
+		if slug, ok := strings.CutPrefix(branch, prefix); ok {
+			return slug
+		}
+	}
+	return ""
+}
+
+// ghPages runs gh and decodes the JSON arrays it prints, one per page with
+// --paginate, into out
+func ghPages[T any](out *[]T, args ...string) error {
+	data, err := gh(args...)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(strings.NewReader(data))
+	for {
+		var page []T
+		if err := dec.Decode(&page); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		*out = append(*out, page...)
+	}
+}
+
+// readPRReviews reads prReviewsPath, empty when there is none yet
+func readPRReviews() (map[string][]int64, error) {
+	seen := make(map[string][]int64)
+	data, err := os.ReadFile(prReviewsPath())
+	if os.IsNotExist(err) {
+		return seen, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &seen); err != nil {
+		return nil, fmt.Errorf("could not read %s: %v", prReviewsPath(), err)
+	}
+	return seen, nil
+}
+
+func writePRReviews(seen map[string][]int64) error {
+	data, err := json.MarshalIndent(seen, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(prReviewsPath(), append(data, '\n'), 0644)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/atomicfile"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/scope"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// bumpedTask is a pending task moved to the front by prioritize
+type bumpedTask struct {
+	Title string
+	// Match is the file list entry that overlaps the incident paths, or ""
+	// for a prerequisite of another bumped task
+	Match string
+	// For is the bumped task a prerequisite was moved for
+	For string
+}
+
+// incidentTasks returns the pending tasks whose file list overlaps paths, in
+// tasks.md order, each preceded by its pending prerequisites so it can start
+// as soon as they are done
+func incidentTasks(tasksMd, progressMd string, paths []string) []bumpedTask {
+	status := make(map[string]string)
+	for _, t := range tasks.Summarize(tasksMd, progressMd).Tasks {
+		status[t.Title] = t.Status
+	}
+	all := tasks.ParseTasks(tasksMd)
+	graph := tasks.NewDependencyGraph(all)
+
+	var bumped []bumpedTask
+	seen := make(map[string]bool)
+	var addPrereqs func(title, root string)
+	addPrereqs = func(title, root string) {
+		for _, p := range graph.Prerequisites(title) {
+			if seen[p] || status[p] != "pending" {
+				continue
+			}
+			seen[p] = true
+			addPrereqs(p, root)
+			bumped = append(bumped, bumpedTask{Title: p, For: root})
+		}
+	}
+	for _, t := range all {
+		if seen[t.Title] || status[t.Title] != "pending" {
+			continue
+		}
+		if match := overlappingEntry(tasks.ParseFileScope(tasks.ExtractTaskDetails(tasksMd, t.Title)), paths); match != "" {
+			seen[t.Title] = true
+			addPrereqs(t.Title, t.Title)
+			bumped = append(bumped, bumpedTask{Title: t.Title, Match: match})
+		}
+	}
+	return bumped
+}
+
+// overlappingEntry returns the first entry of a task's file list that
+// overlaps one of paths, or ""
+func overlappingEntry(files, paths []string) string {
+	for _, f := range files {
+		for _, p := range paths {
+			if scope.Overlaps(f, p) {
+				return f
+			}
+		}
+	}
+	return ""
+}
+
+// prioritizePaths moves the pending tasks touching paths to the front of
+// tasks.md, after a new hotfix task when hotfix names one, and returns the
+// moved tasks
+func prioritizePaths(tasksPath, progressPath string, paths []string, hotfix, incident string, dryRun bool) ([]bumpedTask, error) {
+	unlock, err := atomicfile.LockFiles(tasksPath)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	current, err := os.ReadFile(tasksPath)
+	if err != nil {
+		return nil, err
+	}
+	progress, _ := os.ReadFile(progressPath)
+	tasksMd := string(current)
+
+	if hotfix != "" {
+		for _, t := range tasks.ParseTasks(tasksMd) {
+			if strings.EqualFold(t.Title, hotfix) {
+				return nil, fmt.Errorf("a task named %q already exists", hotfix)
+			}
+		}
+	}
+	bumped := incidentTasks(tasksMd, string(progress), paths)
+	titles := make([]string, len(bumped))
+	for i, b := range bumped {
+		titles[i] = b.Title
+	}
+	// Reordering only wins among equal priorities: the moved tasks go to
+	// P0 too, so no task already at P0 runs first
+	updated := tasks.MoveToFront(tasksMd, titles)
+	for _, title := range titles {
+		updated = tasks.SetPriority(updated, title, tasks.PriorityP0)
+	}
+	if hotfix != "" {
+		updated = tasks.PrependTasks(updated, []string{tasks.HotfixTask(hotfix, paths, incident)})
+	}
+	if dryRun || updated == tasksMd {
+		return bumped, nil
+	}
+	return bumped, atomicfile.WriteFile(tasksPath, []byte(updated), 0644)
+}
+
+// printBumped lists the tasks prioritize moved, and why
+func printBumped(bumped []bumpedTask) {
+	for i, b := range bumped {
+		if b.For != "" {
+			fmt.Printf("  %d. %s (prerequisite of '%s')\n", i+1, b.Title, b.For)
+		} else {
+			fmt.Printf("  %d. %s (%s)\n", i+1, b.Title, b.Match)
+		}
+	}
+}
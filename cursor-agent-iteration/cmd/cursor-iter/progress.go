@@ -0,0 +1,90 @@
+package main
+
+import (
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/progress"
+)
+
+// progressInterval is how often the status lines are redrawn
+const progressInterval = 250 * time.Millisecond
+
+// isTerminal reports whether f is an interactive terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// terminalWidth returns the width from $COLUMNS, or a conservative default
+func terminalWidth() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 20 {
+		return n
+	}
+	return 100
+}
+
+// progressSource lists the running tasks for the display, estimating how long
+// each will take from similar runs in the journal
+func progressSource(running func() []*TaskExecution) func() []progress.Task {
+	entries, _ := journal.Read(journalPath())
+	var mu sync.Mutex
+	estimates := make(map[*TaskExecution]time.Duration)
+	return func() []progress.Task {
+		runs := running()
+		sort.Slice(runs, func(i, j int) bool { return runs[i].StartTime.Before(runs[j].StartTime) })
+		mu.Lock()
+		defer mu.Unlock()
+		result := make([]progress.Task, 0, len(runs))
+		for _, run := range runs {
+			estimate, ok := estimates[run]
+			if !ok {
+				estimate = journal.EstimateDuration(entries, run.TaskTitle, run.Labels, string(run.Backend))
+				estimates[run] = estimate
+			}
+			result = append(result, progress.Task{Title: run.TaskTitle, Backend: string(run.Backend), Start: run.StartTime, Estimate: estimate})
+		}
+		return result
+	}
+}
+
+// startProgress shows a live status line per running task at the bottom of
+// the terminal until the returned function is called. Stdout and stderr are
+// routed through the display while it runs so the status block stays below
+// all other output. Nothing is shown when disabled or when the output isn't
+// an interactive terminal.
+func startProgress(enabled bool, running func() []*TaskExecution) (stop func()) {
+	if !enabled || !progress.Enabled(isTerminal(os.Stdout) && isTerminal(os.Stderr), os.Getenv("TERM")) {
+		return func() {}
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return func() {}
+	}
+	stdout, stderr := os.Stdout, os.Stderr
+	display := progress.New(stdout, progressSource(running), terminalWidth())
+	os.Stdout, os.Stderr = w, w
+
+	copied := make(chan struct{})
+	go func() {
+		io.Copy(display, r)
+		close(copied)
+	}()
+	display.Start(progressInterval)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			os.Stdout, os.Stderr = stdout, stderr
+			w.Close()
+			<-copied
+			r.Close()
+			display.Stop()
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/project"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// mustProjectType returns the project type for the --project-type flag,
+// detecting it from the current directory for "auto"
+func mustProjectType(spec string, debug bool) project.Type {
+	kind, err := project.Resolve(spec, ".")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid --project-type: %v\n", err)
+		os.Exit(1)
+	}
+	if debug {
+		fmt.Printf("[%s] 🗂️ Project type: %s\n", ts(), kind)
+	}
+	return kind
+}
+
+// completionPolicy builds the completion policy from the --defer-categories
+// flag, falling back to the categories the project type defers
+func completionPolicy(deferCategories string, kind project.Type) tasks.CompletionPolicy {
+	if deferCategories == "" {
+		deferCategories = kind.DeferredCategories()
+	}
+	return tasks.ParseCompletionPolicy(deferCategories)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/prompts"
+)
+
+// promptMirrors are where missing prompt files are fetched from, in order:
+// GitHub raw, then jsDelivr's copy of the same branch. {file} is replaced by
+// the file name.
+var promptMirrors = []string{
+	"https://raw.githubusercontent.com/cheddarwhizzy/cursor-autopilot/main/cursor-agent-iteration/prompts/{file}",
+	"https://cdn.jsdelivr.net/gh/cheddarwhizzy/cursor-autopilot@main/cursor-agent-iteration/prompts/{file}",
+}
+
+// promptAttempts is how many times each mirror is tried
+const promptAttempts = 3
+
+// promptRetryBackoff is the wait before the second attempt on a mirror; it
+// doubles after every attempt
+var promptRetryBackoff = time.Second
+
+// maxPromptWait caps how long a Retry-After header may hold a fetch up
+const maxPromptWait = 30 * time.Second
+
+// promptClient fetches prompts, giving up on a mirror that hangs
+var promptClient = &http.Client{Timeout: 20 * time.Second}
+
+// promptSource says where missing prompt files come from
+type promptSource struct {
+	// Embedded uses the prompts built into cursor-iter instead of fetching
+	Embedded bool
+	// Offline forbids any network fetch, leaving only the built-in prompts
+	Offline bool
+	// Mirrors are URLs tried before GitHub and jsDelivr: a template with
+	// {file}, or a base URL the file name is appended to
+	Mirrors []string
+}
+
+// newPromptSource returns the source for the --embedded-prompts, --offline
+// and --prompt-mirror flags
+func newPromptSource(embedded, offline bool, mirrors string) promptSource {
+	src := promptSource{Embedded: embedded, Offline: offline}
+	for _, m := range strings.Split(mirrors, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			src.Mirrors = append(src.Mirrors, m)
+		}
+	}
+	return src
+}
+
+// urls returns the URLs to fetch a prompt file from, in order
+func (s promptSource) urls(filename string) []string {
+	var urls []string
+	for _, m := range append(append([]string{}, s.Mirrors...), promptMirrors...) {
+		if !strings.Contains(m, "{file}") {
+			m = strings.TrimSuffix(m, "/") + "/{file}"
+		}
+		urls = append(urls, strings.ReplaceAll(m, "{file}", filename))
+	}
+	return urls
+}
+
+// fetchPrompt puts a prompt file in place if it doesn't exist yet: from the
+// copies built into cursor-iter with Embedded or Offline, or else from the
+// first mirror that serves it. Each mirror is retried with backoff while it
+// is down or rate-limited, and the built-in copy is used when none serves
+// it.
+func fetchPrompt(promptFile string, src promptSource) error {
+	if _, err := os.Stat(promptFile); err == nil {
+		return nil
+	}
+	filename := filepath.Base(promptFile)
+	if err := os.MkdirAll(filepath.Dir(promptFile), 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %v", filepath.Dir(promptFile), err)
+	}
+
+	builtin, builtinErr := prompts.FS.ReadFile(filename)
+	if src.Embedded || src.Offline {
+		if builtinErr != nil {
+			return fmt.Errorf("no prompt %s is built into cursor-iter; put the file at %s yourself", filename, promptFile)
+		}
+		if err := os.WriteFile(promptFile, builtin, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %v", promptFile, err)
+		}
+		fmt.Printf("[%s] 📦 Using the built-in %s\n", ts(), filename)
+		return nil
+	}
+
+	var failures []string
+	missing := true
+	for _, url := range src.urls(filename) {
+		host := url
+		if i := strings.Index(url, "://"); i >= 0 {
+			host, _, _ = strings.Cut(url[i+3:], "/")
+		}
+		fmt.Printf("[%s] Fetching %s from %s...\n", ts(), filename, host)
+		body, _, err := fetchURL(url, nil)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", host, err))
+			missing = missing && errors.Is(err, errPromptNotFound)
+			continue
+		}
+		if err := os.WriteFile(promptFile, body, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %v", promptFile, err)
+		}
+		fmt.Printf("[%s] ✅ Successfully fetched %s\n", ts(), filename)
+		return nil
+	}
+	if builtinErr == nil {
+		if err := os.WriteFile(promptFile, builtin, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %v", promptFile, err)
+		}
+		fmt.Printf("[%s] ⚠️ Could not fetch %s (%s); using the copy built into cursor-iter\n", ts(), filename, strings.Join(failures, "; "))
+		return nil
+	}
+	if missing {
+		return fmt.Errorf("no mirror has %s (%s) and none is built into cursor-iter; put the file at %s yourself", filename, strings.Join(failures, "; "), promptFile)
+	}
+	return fmt.Errorf("could not fetch %s (%s), which isn't built into cursor-iter. GitHub looks unreachable or rate-limited: point --prompt-mirror at a copy, or put the file at %s yourself",
+		filename, strings.Join(failures, "; "), promptFile)
+}
+
+// httpStatusError is a response other than 200 OK
+type httpStatusError int
+
+func (e httpStatusError) Error() string {
+	return fmt.Sprintf("HTTP %d", int(e))
+}
+
+// errPromptNotFound is a mirror's 404, which no retry will fix
+var errPromptNotFound error = httpStatusError(http.StatusNotFound)
+
+// fetchURL gets a prompt or spec, sending header with the request, and
+// retries with backoff on network errors, rate limits and server errors
+func fetchURL(url string, header http.Header) (body []byte, contentType string, err error) {
+	backoff := promptRetryBackoff
+	for attempt := 1; attempt <= promptAttempts; attempt++ {
+		var wait time.Duration
+		body, contentType, wait, err = getURL(url, header)
+		if err == nil {
+			return body, contentType, nil
+		}
+		if !retryableFetch(err) || attempt == promptAttempts {
+			break
+		}
+		wait = max(wait, backoff)
+		fmt.Printf("[%s] 🔄 %v, retry %d/%d after %v\n", ts(), err, attempt, promptAttempts-1, wait)
+		time.Sleep(wait)
+		backoff *= 2
+	}
+	return nil, "", err
+}
+
+// getURL makes one request, returning how long the server asked to wait
+// before the next one, if it did
+func getURL(url string, header http.Header) ([]byte, string, time.Duration, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := promptClient.Do(req)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", retryAfter(resp.Header.Get("Retry-After")), httpStatusError(resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("failed to read response body: %v", err)
+	}
+	return body, resp.Header.Get("Content-Type"), 0, nil
+}
+
+// retryAfter parses a Retry-After header in seconds, capped at maxPromptWait
+func retryAfter(header string) time.Duration {
+	seconds, err := strconv.Atoi(strings.TrimSpace(header))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return min(time.Duration(seconds)*time.Second, maxPromptWait)
+}
+
+// retryableFetch reports whether a failed fetch may succeed later: not when
+// the host doesn't resolve, which means no network or a mistyped mirror,
+// and not on client errors such as 404 or 401 other than a timeout or rate
+// limit
+func retryableFetch(err error) bool {
+	var status httpStatusError
+	if errors.As(err, &status) {
+		return status >= 500 || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests
+	}
+	var dnsErr *net.DNSError
+	return !errors.As(err, &dnsErr) || !dnsErr.IsNotFound
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/atomicfile"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/recurring"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// recurringCheckInterval is how often iterate-loop looks for due recurring
+// tasks
+const recurringCheckInterval = time.Minute
+
+// recurringPath is the file of recurring task definitions
+func recurringPath() string {
+	return getControlFilePath("tasks-recurring.md")
+}
+
+// recurringStatePath records when each recurring task was last created
+func recurringStatePath() string {
+	return getControlFilePath("recurring-state.json")
+}
+
+// materializeRecurring adds an instance of every recurring task that is due
+// to tasks.md, unless its previous instance is still open, and returns the
+// new titles. Without a definitions file it does nothing. With dryRun
+// nothing is written.
+func materializeRecurring(now time.Time, dryRun bool) ([]string, error) {
+	md, err := os.ReadFile(recurringPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defs, err := recurring.Parse(string(md))
+	if err != nil {
+		return nil, err
+	}
+	state, err := recurring.LoadState(recurringStatePath())
+	if err != nil {
+		return nil, err
+	}
+
+	tasksPath := resolveTasksFile()
+	unlock, err := atomicfile.LockFiles(tasksPath)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	current, err := os.ReadFile(tasksPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	progress, _ := os.ReadFile(resolveProgressFile())
+	var titles []string
+	for _, t := range tasks.ParseTasks(string(current)) {
+		titles = append(titles, t.Title)
+	}
+	open := func(title string) bool {
+		return !tasks.IsTaskCompleted(string(progress), title)
+	}
+
+	due, blocks := recurring.Materialize(defs, state, now, titles, open)
+	added := make([]string, len(due))
+	for i, d := range due {
+		added[i] = d.InstanceTitle(now)
+	}
+	if dryRun || len(blocks) == 0 {
+		return added, nil
+	}
+	if err := atomicfile.WriteFile(tasksPath, []byte(tasks.AppendTasks(string(current), blocks)), 0644); err != nil {
+		return nil, err
+	}
+	return added, state.Save(recurringStatePath())
+}
+
+// checkRecurring materializes due recurring tasks from the loop, logging
+// instead of failing
+func checkRecurring() {
+	added, err := materializeRecurring(time.Now(), false)
+	if err != nil {
+		fmt.Printf("[%s] ⚠️ Could not check recurring tasks: %v\n", ts(), err)
+		return
+	}
+	for _, title := range added {
+		fmt.Printf("[%s] 🔁 Added recurring task: %s\n", ts(), title)
+	}
+}
+
+// printRecurring lists the recurring tasks with their last and next
+// instance
+func printRecurring(out io.Writer, now time.Time) error {
+	md, err := os.ReadFile(recurringPath())
+	if os.IsNotExist(err) {
+		fmt.Fprintf(out, "No recurring tasks (define them in %s)\n", recurringPath())
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defs, err := recurring.Parse(string(md))
+	if err != nil {
+		return err
+	}
+	state, err := recurring.LoadState(recurringStatePath())
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TASK\tSCHEDULE\tLAST\tNEXT\t")
+	for _, d := range defs {
+		last, next := "never", "now"
+		if t, ok := state[d.Title]; ok {
+			last = t.Format("2006-01-02 15:04")
+			if !state.Due(d, now) {
+				next = d.Schedule.Next(t).Format("2006-01-02 15:04")
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", d.Title, d.Spec, last, next)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/config"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/verify"
+)
+
+// allowlistSection is the section of the config file listing what
+// iterate and iterate-loop may run under --restricted, by name, e.g.
+// "test: go test ./..." or "slack: https://hooks.slack.com/services/..."
+const allowlistSection = "allowlist"
+
+// allowlistChecksumKey is the top-level config setting, or with
+// ALLOWLIST_CHECKSUM the environment variable, holding the checksum the
+// allowlist section must have
+const allowlistChecksumKey = "allowlist-checksum"
+
+// permissions is what --restricted lets iterate and iterate-loop run on
+// their own: the quality gate and reviewer commands and the notify webhooks
+// of the allowlist. A nil *permissions allows everything.
+type permissions struct {
+	allowed map[string]bool
+}
+
+// allowlistChecksum is the checksum of an allowlist: the SHA-256 of its
+// "name: value" lines, sorted, so reordering the section keeps it
+func allowlistChecksum(allowlist map[string]string) string {
+	names := make([]string, 0, len(allowlist))
+	for name := range allowlist {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s: %s\n", name, strings.TrimSpace(allowlist[name]))
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// readAllowlist returns the allowlist section of the config file
+func readAllowlist(cfg config.Config) (map[string]string, error) {
+	if v, ok := cfg.Values[allowlistSection]; ok {
+		return nil, fmt.Errorf("want \"name: command or URL\" settings such as \"test: go test ./...\", got %q", v)
+	}
+	return cfg.Sections[allowlistSection], nil
+}
+
+// restrictedPermissions returns the permissions of the allowlist in cfg,
+// which must have the checksum want. Without an allowlist nothing is
+// allowed; an allowlist without a checksum, or with another one, is an
+// error, so it can't be changed without updating the checksum too.
+func restrictedPermissions(cfg config.Config, want string) (*permissions, error) {
+	allowlist, err := readAllowlist(cfg)
+	if err != nil {
+		return nil, err
+	}
+	p := &permissions{allowed: make(map[string]bool, len(allowlist))}
+	if len(allowlist) == 0 {
+		return p, nil
+	}
+	got := allowlistChecksum(allowlist)
+	switch want = strings.TrimSpace(want); {
+	case want == "":
+		return nil, fmt.Errorf("the %s section has no checksum; set %s: %s", allowlistSection, allowlistChecksumKey, got)
+	case !strings.EqualFold(want, got):
+		return nil, fmt.Errorf("the %s section has checksum %s, not %s; it changed since it was signed", allowlistSection, got, want)
+	}
+	for _, value := range allowlist {
+		p.allowed[strings.TrimSpace(value)] = true
+	}
+	return p, nil
+}
+
+// mustPermissions returns the permissions of --restricted, nil when it is
+// off, exiting when the allowlist is invalid. RESTRICTED=true turns it on
+// even if the config file turns it off, and ALLOWLIST_CHECKSUM takes
+// precedence over the checksum in the config file, so a CI runner can pin
+// both outside the repository.
+func mustPermissions(restricted bool) *permissions {
+	if !restricted && os.Getenv("RESTRICTED") != "true" {
+		return nil
+	}
+	want := envOr("ALLOWLIST_CHECKSUM", repoConfig.Values[allowlistChecksumKey])
+	p, err := restrictedPermissions(repoConfig, want)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid %s in %s: %v\n", allowlistSection, configPath(), err)
+		os.Exit(1)
+	}
+	return p
+}
+
+// String describes the permissions for the start of a run
+func (p *permissions) String() string {
+	return fmt.Sprintf("Restricted: only the %d command(s) and webhook(s) of the %s run", len(p.allowed), allowlistSection)
+}
+
+// check returns an error naming the first of the gates, the --verify-full
+// command, a command:<shell> reviewer and the webhook that isn't allowed.
+// A nil *permissions allows them all.
+func (p *permissions) check(gates []verify.Gate, full, reviewer, webhook string) error {
+	if p == nil {
+		return nil
+	}
+	var wanted [][2]string
+	for _, g := range gates {
+		what := "gate " + g.Name
+		if g.Name == verify.Fast {
+			what = "--verify-fast"
+		}
+		wanted = append(wanted, [2]string{what, g.Command})
+	}
+	wanted = append(wanted, [2]string{"--verify-full", full})
+	if command, ok := strings.CutPrefix(strings.TrimSpace(reviewer), "command:"); ok {
+		wanted = append(wanted, [2]string{"--reviewer", command})
+	}
+	wanted = append(wanted, [2]string{"--notify-webhook", webhook})
+	for _, w := range wanted {
+		value := strings.TrimSpace(w[1])
+		if value != "" && !p.allowed[value] {
+			return fmt.Errorf("%s runs %q, which the %s of %s doesn't list", w[0], value, allowlistSection, configPath())
+		}
+	}
+	return nil
+}
+
+// mustAllow exits when --restricted doesn't allow the gates, the
+// --verify-full command, the reviewer or the webhook
+func (p *permissions) mustAllow(gates []verify.Gate, full, reviewer, webhook string) {
+	if err := p.check(gates, full, reviewer, webhook); err != nil {
+		fmt.Fprintf(os.Stderr, "--restricted: %v; add it and update %s (cursor-iter allowlist-checksum prints it)\n", err, allowlistChecksumKey)
+		os.Exit(1)
+	}
+}
+
+// printAllowlist prints the allowlist of cfg and the checksum that signs it,
+// reporting whether want matches it
+func printAllowlist(cfg config.Config, want string) error {
+	allowlist, err := readAllowlist(cfg)
+	if err != nil {
+		return err
+	}
+	if len(allowlist) == 0 {
+		fmt.Printf("No %s section in %s; under --restricted nothing runs.\n", allowlistSection, configPath())
+		return nil
+	}
+	names := make([]string, 0, len(allowlist))
+	for name := range allowlist {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("  %-12s %s\n", name, allowlist[name])
+	}
+	got := allowlistChecksum(allowlist)
+	fmt.Printf("%s: %s\n", allowlistChecksumKey, got)
+	switch want = strings.TrimSpace(want); {
+	case want == "":
+		fmt.Printf("Not signed yet; add the line above to %s, or set ALLOWLIST_CHECKSUM on the CI runner.\n", configPath())
+	case strings.EqualFold(want, got):
+		fmt.Println("✅ The checksum matches.")
+	default:
+		fmt.Printf("❌ The checksum doesn't match %s; --restricted refuses to run.\n", want)
+	}
+	return nil
+}
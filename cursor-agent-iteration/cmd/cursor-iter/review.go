@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/review"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/runner"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// codeReviewer has a reviewer look at the diff of every completed task. A
+// nil *codeReviewer reviews nothing.
+type codeReviewer struct {
+	name   string
+	policy review.Policy
+	// ask sends the review prompt to the reviewer and returns its reply
+	ask func(prompt string) (string, error)
+}
+
+// mustCodeReviewer returns the reviewer for the --reviewer flag, or nil when
+// spec is empty. spec is a model for the primary backend, a backend and
+// model such as codex:gpt-5-codex, or command:<shell command>, which gets
+// the prompt on stdin and prints the findings.
+func mustCodeReviewer(spec, policySpec string, primary runner.Backend, debug bool) *codeReviewer {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil
+	}
+	policy, err := review.ParsePolicy(policySpec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid --review-policy: %v\n", err)
+		os.Exit(1)
+	}
+	r := &codeReviewer{name: spec, policy: policy}
+	if command, ok := strings.CutPrefix(spec, "command:"); ok {
+		r.ask = func(prompt string) (string, error) {
+			cmd := exec.Command("sh", "-c", command)
+			cmd.Stdin = strings.NewReader(prompt)
+			cmd.Stderr = os.Stderr
+			out, err := cmd.Output()
+			return string(out), err
+		}
+		return r
+	}
+	backend, model := primary, spec
+	if name, m, ok := strings.Cut(spec, ":"); ok {
+		if b, err := runner.ParseBackend(name); err == nil {
+			backend, model = b, m
+		}
+	}
+	r.ask = func(prompt string) (string, error) {
+		logPrompt(prompt, debug, false)
+		var out bytes.Buffer
+		err := runner.RunPrompt(runner.Options{Debug: debug, Stdout: &out}, backend, model, prompt)
+		return out.String(), err
+	}
+	return r
+}
+
+// reviewPath is the review file of a task
+func reviewPath(title string) string {
+	return getControlFilePath(filepath.Join("reviews", taskSlug(title)+".md"))
+}
+
+// Review reviews the diff of a task that just completed and applies the
+// policy: the task stays completed, goes back to its agent with the
+// findings, or moves to Blocked until someone signs it off. It reports
+// whether the task is still completed; a failed review doesn't hold the
+// task up.
+func (r *codeReviewer) Review(run *TaskExecution, details, progressFile string) bool {
+	if r == nil || run == nil {
+		return true
+	}
+	title := run.TaskTitle
+	diff := taskDiff(run.HeadBefore, gitHead())
+	if strings.TrimSpace(diff) == "" {
+		fmt.Printf("[%s] 🔎 No changes to review for task: %s\n", ts(), title)
+		return true
+	}
+	fmt.Printf("[%s] 🔎 Reviewing the changes of '%s' with %s...\n", ts(), title, r.name)
+	reply, err := r.ask(review.Prompt(review.Input{Title: title, Details: details, Diff: diff}))
+	if err != nil {
+		fmt.Printf("[%s] ⚠️ Review of '%s' failed, keeping it completed: %v\n", ts(), title, err)
+		return true
+	}
+
+	path := reviewPath(title)
+	previous, _ := os.ReadFile(path)
+	findings := review.Parse(reply)
+	action := r.policy.Decide(findings, review.FixRounds(string(previous)))
+	record := review.Record(string(previous), title, review.Round{At: time.Now(), Reviewer: r.name, Action: action, Findings: findings})
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err == nil {
+		err = os.WriteFile(path, []byte(record), 0644)
+	}
+	if err != nil {
+		fmt.Printf("[%s] ⚠️ Could not write %s: %v\n", ts(), path, err)
+	}
+	for _, f := range findings {
+		fmt.Printf("  - %s\n", f)
+	}
+
+	switch action {
+	case review.ActionFix:
+		if err := updateControlFile(progressFile, func(progress string) string {
+			return tasks.ReopenTask(progress, title)
+		}); err != nil {
+			fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not reopen '%s': %v\n", ts(), title, err)
+			return true
+		}
+		fmt.Printf("[%s] 🔁 Review found %s issues, sending '%s' back to the agent\n", ts(), review.Worst(findings), title)
+		return false
+	case review.ActionSignOff:
+		reason := fmt.Sprintf("needs sign-off: review found %s issues, see %s", review.Worst(findings), path)
+		if err := updateControlFile(progressFile, func(progress string) string {
+			return tasks.MarkTaskBlocked(tasks.ReopenTask(progress, title), title, reason)
+		}); err != nil {
+			fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not block '%s': %v\n", ts(), title, err)
+			return true
+		}
+		fmt.Printf("[%s] ⛔ Review found %s issues, moved to Blocked until signed off: %s\n", ts(), review.Worst(findings), title)
+		fmt.Printf("[%s] 💡 Read %s, then run 'cursor-iter sign-off --task \"%s\"' or retry it with 'cursor-iter triage'\n", ts(), path, title)
+		return false
+	}
+	if len(findings) == 0 {
+		fmt.Printf("[%s] ✅ Review found no issues: %s\n", ts(), title)
+	} else {
+		fmt.Printf("[%s] ✅ Review found only %s issues, keeping '%s' completed (see %s)\n", ts(), review.Worst(findings), title, path)
+	}
+	return true
+}
+
+// reviewFollowUp points the agent at the findings of a task whose last
+// review sent it back, and is empty otherwise
+func reviewFollowUp(title string) string {
+	path := reviewPath(title)
+	md, err := os.ReadFile(path)
+	if err != nil || review.LastAction(string(md)) != review.ActionFix {
+		return ""
+	}
+	return fmt.Sprintf("A code review of this task found issues; fix the findings of the last round in %s, then mark the task completed again", path)
+}
+
+// signOff accepts a task held for review sign-off: it is moved from Blocked
+// to Completed and the sign-off is recorded in its review file
+func signOff(progressFile, title, by string) error {
+	progress, err := os.ReadFile(progressFile)
+	if err != nil {
+		return err
+	}
+	if !tasks.IsTaskBlocked(string(progress), title) {
+		return fmt.Errorf("'%s' is not blocked", title)
+	}
+	path := reviewPath(title)
+	md, _ := os.ReadFile(path)
+	if review.LastAction(string(md)) != review.ActionSignOff {
+		return fmt.Errorf("'%s' is not waiting for a review sign-off", title)
+	}
+	if err := updateControlFile(progressFile, func(progress string) string {
+		return tasks.LogTaskCompletion(tasks.UnblockTask(progress, title), title, "signed off after review")
+	}); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(review.Record(string(md), title, review.Round{At: time.Now(), Reviewer: by, Action: review.ActionSigned})), 0644)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Exit codes of run-agent, so scripts can tell a broken agent from a request
+// the agent could not carry out. Usage errors exit with 1.
+const (
+	exitAgentError = 2 // the agent could not be started or exited with an error
+	exitTaskFailed = 3 // the agent ran but reported that the request failed
+)
+
+// Statuses of a run-agent result
+const (
+	agentStatusSuccess = "success"
+	agentStatusFailed  = "failed"
+	agentStatusError   = "error"
+)
+
+// agentResultMarker starts the line the agent reports its outcome on
+const agentResultMarker = "RESULT:"
+
+// agentResultNote asks the agent for a machine-readable outcome when
+// run-agent is scripted
+const agentResultNote = `End your final message with a single line "RESULT: {...}" holding a JSON object with "status" set to "success" or "failed" and a one-sentence "summary", so automation can read the outcome.`
+
+// agentResult is the outcome of a run-agent request, printed by --json
+type agentResult struct {
+	Status     string         `json:"status"`
+	ExitCode   int            `json:"exit_code"`
+	Backend    string         `json:"backend"`
+	Model      string         `json:"model"`
+	DurationMs int64          `json:"duration_ms"`
+	Message    string         `json:"message"`          // the agent's final message
+	Result     map[string]any `json:"result,omitempty"` // the RESULT object the agent reported, if any
+	Error      string         `json:"error,omitempty"`
+	OutputFile string         `json:"output_file,omitempty"`
+}
+
+// parseAgentResult returns the object of the last RESULT line in the
+// agent's output, or nil if there is none
+func parseAgentResult(output string) map[string]any {
+	lines := strings.Split(output, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.Trim(strings.TrimSpace(lines[i]), "`")
+		_, rest, ok := strings.Cut(line, agentResultMarker)
+		if !ok {
+			continue
+		}
+		var result map[string]any
+		if err := json.Unmarshal([]byte(strings.TrimSpace(rest)), &result); err == nil {
+			return result
+		}
+	}
+	return nil
+}
+
+// newAgentResult classifies a finished run-agent request from the agent's
+// output and the error running it
+func newAgentResult(backend, model string, output string, duration time.Duration, runErr error) agentResult {
+	r := agentResult{
+		Status:     agentStatusSuccess,
+		Backend:    backend,
+		Model:      model,
+		DurationMs: duration.Milliseconds(),
+		Message:    strings.TrimSpace(output),
+		Result:     parseAgentResult(output),
+	}
+	switch {
+	case runErr != nil:
+		r.Status, r.ExitCode, r.Error = agentStatusError, exitAgentError, runErr.Error()
+	case r.Result != nil && !strings.EqualFold(fmt.Sprint(r.Result["status"]), agentStatusSuccess):
+		r.Status, r.ExitCode = agentStatusFailed, exitTaskFailed
+	}
+	return r
+}
+
+// writeAgentResult saves the final message to outputFile, if set, and prints
+// the result as JSON to out when asJSON is set
+func writeAgentResult(out io.Writer, r agentResult, outputFile string, asJSON bool) error {
+	if outputFile != "" {
+		if err := os.WriteFile(outputFile, []byte(r.Message+"\n"), 0644); err != nil {
+			return fmt.Errorf("writing %s: %w", outputFile, err)
+		}
+		r.OutputFile = outputFile
+	}
+	if !asJSON {
+		return nil
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
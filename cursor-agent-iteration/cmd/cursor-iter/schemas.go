@@ -0,0 +1,209 @@
+package main
+
+//go:generate go run . schema --write ../../docs/schemas
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/audit"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/conflict"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/coord"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/events"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/feed"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/jsonlog"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/notify"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/recurring"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/schema"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/statusline"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+func init() {
+	schema.Register(schema.Spec{
+		Name:        "journal-entry",
+		Description: "One line of .cursor-iter/logs/journal.jsonl: a single agent run for a single task",
+		Type:        journal.Entry{},
+		Enums: map[string][]string{
+			"outcome": {journal.OutcomeCompleted, journal.OutcomeIncomplete, journal.OutcomeFailed},
+			"classification": {journal.ClassInterrupted, journal.ClassAgentMissing, journal.ClassAuth, journal.ClassRateLimit, journal.ClassTimeout,
+				journal.ClassBuild, journal.ClassTests, journal.ClassLint, journal.ClassMerge, journal.ClassNeedsHuman, journal.ClassRefusal, journal.ClassIncomplete, journal.ClassUnknown},
+			"prompt_variant": {promptFull, promptLean, promptRephrased, promptSplit},
+		},
+	})
+	schema.Register(schema.Spec{
+		Name:        "triage-decision",
+		Description: "One line of .cursor-iter/logs/triage.jsonl: a triage decision about a failed task",
+		Type:        journal.Decision{},
+		Enums: map[string][]string{
+			"action": {triageRetry, triageRetryModel, triageEdit, triageBlock, triageRollback},
+		},
+	})
+	schema.Register(schema.Spec{
+		Name:        "conflict-record",
+		Description: "One line of .cursor-iter/logs/conflicts.jsonl: a conflicted file and how it was resolved",
+		Type:        conflict.Record{},
+		Enums: map[string][]string{
+			"op": {string(conflict.OpMerge), string(conflict.OpRebase)},
+		},
+	})
+	schema.Register(schema.Spec{
+		Name:        "run-agent-result",
+		Description: "Output of cursor-iter run-agent --json: the outcome of an ad-hoc request",
+		Type:        agentResult{},
+		Enums: map[string][]string{
+			"status": {agentStatusSuccess, agentStatusFailed, agentStatusError},
+		},
+	})
+	schema.Register(schema.Spec{
+		Name:        "agents-list",
+		Description: "Output of cursor-iter agents list --format json: the models of every backend, how they are configured and their recent runs",
+		Type:        []agentModel{},
+	})
+	schema.Register(schema.Spec{
+		Name:        "task-status",
+		Description: "Output of cursor-iter task-status --format json: the status of every task and the totals",
+		Type:        tasks.StatusSummary{},
+	})
+	schema.Register(schema.Spec{
+		Name:        "coordinator-state",
+		Description: "A repository's state file in the --coordinator-dir shared by loops under --global-max: its running agents, priority and weight",
+		Type:        coord.State{},
+	})
+	schema.Register(schema.Spec{
+		Name:        "recurring-state",
+		Description: "The .cursor-iter/recurring-state.json file: when each recurring task last had an instance created, by title",
+		Type:        recurring.State{},
+	})
+	schema.Register(schema.Spec{
+		Name:        "config",
+		Description: "The .cursor-iter.yaml config file, for editors validating YAML against JSON Schemas",
+		Type:        configFile{},
+	})
+	schema.Register(schema.Spec{
+		Name:        "statusline-cache",
+		Description: "The .cursor-iter/statusline.json cache of cursor-iter statusline: the task counts and the control files they were read from",
+		Type:        statusline.Cache{},
+	})
+	schema.Register(schema.Spec{
+		Name:        "event",
+		Description: "A message of the GET /ws WebSocket of cursor-iter serve: a task, loop or log event",
+		Type:        events.Event{},
+		Enums: map[string][]string{
+			"type": {events.TaskStarted, events.TaskCompleted, events.TaskBlocked, events.TaskFailed, events.TaskReopened, events.TaskAdded, events.TaskRemoved,
+				events.CriteriaUpdated, events.Log, events.LoopStarted, events.LoopPaused, events.LoopStopped},
+		},
+	})
+	schema.Register(schema.Spec{
+		Name:        "event-filter",
+		Description: "A message a GET /ws client of cursor-iter serve sends to change the events it receives",
+		Type:        events.Filter{},
+	})
+	schema.Register(schema.Spec{
+		Name:        "log-event",
+		Description: "One line of iterate-loop --log-format json: an event of the loop with its fields",
+		Type:        logLine{},
+		Enums: map[string][]string{
+			"event": {jsonlog.LoopStarted, jsonlog.LoopFinished, jsonlog.IterationTick, jsonlog.TaskStarted, jsonlog.TaskCompleted, jsonlog.TaskIncomplete,
+				jsonlog.TaskFailed, jsonlog.TaskBlocked, jsonlog.TaskExhausted, jsonlog.AgentRetry},
+		},
+	})
+	schema.Register(schema.Spec{
+		Name:        "audit-header",
+		Description: "The first line of a cursor-iter audit-log export: the period, record count and hash of the last record",
+		Type:        audit.Header{},
+		Enums: map[string][]string{
+			"format": {audit.Format},
+		},
+	})
+	schema.Register(schema.Spec{
+		Name:        "audit-record",
+		Description: "Every further line of a cursor-iter audit-log export: one autonomous action, chained to the previous one by its hash",
+		Type:        audit.Record{},
+		Enums: map[string][]string{
+			"kind": {audit.KindDispatch, audit.KindOutcome, audit.KindCommit, audit.KindFileWrite, audit.KindApproval},
+		},
+	})
+	schema.Register(schema.Spec{
+		Name:        "archive-queue",
+		Description: "The .cursor-iter/archive-queued.json file: an archive-completed run left for the running iterate-loop",
+		Type:        queuedArchive{},
+	})
+	schema.Register(schema.Spec{
+		Name:        "loop-lock",
+		Description: "The .cursor-iter/loop.lock file: the iterate-loop or archive-completed process rewriting the control files",
+		Type:        controlLock{},
+		Enums: map[string][]string{
+			"command": {lockLoop, lockArchive},
+		},
+	})
+	schema.Register(schema.Spec{
+		Name:        "tips-state",
+		Description: "The .cursor-iter/tips.json file: when each tip was last shown, by rule ID",
+		Type:        map[string]time.Time{},
+	})
+	schema.Register(schema.Spec{
+		Name:        "github-issues",
+		Description: "The .cursor-iter/github-issues.json file: the issues sync-github imported and hasn't closed yet, by owner/repo#number",
+		Type:        map[string]syncedIssue{},
+	})
+	schema.Register(schema.Spec{
+		Name:        "jira-tickets",
+		Description: "The .cursor-iter/jira-tickets.json file: the tickets sync-jira imported and hasn't moved to Done yet, by issue key",
+		Type:        map[string]syncedTicket{},
+	})
+	schema.Register(schema.Spec{
+		Name:        "webhook-payload",
+		Description: "The JSON body iterate-loop posts to a generic --notify-webhook: the event, its text and the fields of the event",
+		Type:        webhookPayload{},
+		Enums: map[string][]string{
+			"event": notify.AllEvents,
+		},
+	})
+	schema.Register(schema.Spec{
+		Name:        "pr-reviews",
+		Description: "The .cursor-iter/pr-reviews.json file: the IDs of the reviews and review comments sync-pr-reviews already turned into criteria, by pull request URL",
+		Type:        map[string][]int64{},
+	})
+	schema.Register(schema.Spec{
+		Name:        "feed",
+		Description: "Output of cursor-iter feed --format json: a JSON Feed 1.1 of the completed tasks and milestones, with a _cursor_iter extension on each item",
+		Type:        feed.JSONFeed{},
+	})
+}
+
+// printSchema writes one registered schema to out
+func printSchema(out io.Writer, name string) error {
+	spec, ok := schema.Lookup(name)
+	if !ok {
+		return fmt.Errorf("unknown schema %q (available: %v)", name, schema.Names())
+	}
+	data, err := spec.JSON()
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(data)
+	return err
+}
+
+// writeSchemas publishes every registered schema into dir
+func writeSchemas(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, name := range schema.Names() {
+		spec, _ := schema.Lookup(name)
+		data, err := spec.JSON()
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, schema.FileName(name)), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,458 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/daemon"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/events"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/schema"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/state"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/stream"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// serveLogCapacity is how much of the loop's output serve keeps for
+// /api/logs
+const serveLogCapacity = 256 * 1024
+
+// maxQueuedFeatures bounds the features waiting for add-feature
+const maxQueuedFeatures = 32
+
+// apiError is the body of every error response of the serve API
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// serveStatus is the body of GET /api/status
+type serveStatus struct {
+	Loop     daemon.ProcessStatus `json:"loop"`
+	Current  string               `json:"current,omitempty"` // the first task in progress
+	Next     string               `json:"next,omitempty"`    // the next pending task, when none is in progress
+	Totals   tasks.StatusTotals   `json:"totals"`
+	Features []daemon.Job         `json:"features"` // features enqueued since serve started
+}
+
+// taskDetail is the body of GET /api/tasks/{title}
+type taskDetail struct {
+	Task     tasks.StatusTask `json:"task"`
+	Markdown string           `json:"markdown"` // the task's block in tasks.md
+}
+
+// featureRequest is the body of POST /api/features
+type featureRequest struct {
+	Description string `json:"description"`
+}
+
+// serveAPI describes the REST API of cursor-iter serve. Its operations are
+// also the server's routes, so the document can't drift from the handlers.
+var serveAPI = schema.API{
+	Title:   "cursor-iter serve",
+	Version: "1",
+	Description: "Drives the iterate loop of a repository. GET /ws streams task, loop and log events over WebSocket. " +
+		"With --token, requests need an 'Authorization: Bearer <token>' header or a token query parameter.",
+	Error: apiError{},
+	Operations: []schema.Operation{
+		{ID: "getStatus", Method: "GET", Path: "/api/status", Summary: "Loop state, task totals and enqueued features", Response: serveStatus{}},
+		{ID: "listTasks", Method: "GET", Path: "/api/tasks", Summary: "Status of every task", Response: tasks.StatusSummary{}},
+		{ID: "getTask", Method: "GET", Path: "/api/tasks/{title}", Summary: "One task and its block in tasks.md", Response: taskDetail{}},
+		{ID: "startLoop", Method: "POST", Path: "/api/loop/start", Summary: "Start the iterate loop", Response: daemon.ProcessStatus{}},
+		{ID: "pauseLoop", Method: "POST", Path: "/api/loop/pause", Summary: "Stop the loop once its running tasks finish; pausing again stops them now", Response: daemon.ProcessStatus{}},
+		{ID: "listFeatures", Method: "GET", Path: "/api/features", Summary: "Features enqueued since serve started", Response: []daemon.Job{}},
+		{ID: "addFeature", Method: "POST", Path: "/api/features", Summary: "Enqueue a feature for add-feature to turn into tasks", Request: featureRequest{}, Response: daemon.Job{}},
+		{ID: "getLogs", Method: "GET", Path: "/api/logs", Summary: "Recent output of the loop and of add-feature; ?follow=true keeps streaming", Response: "", ResponseType: "text/plain"},
+	},
+}
+
+// server holds the state behind the serve API
+type server struct {
+	store    *state.Store
+	loop     *daemon.Process
+	features *daemon.Queue
+	logs     *stream.RingBuffer
+	// out writes to logs and to the serve log file
+	out   io.Writer
+	bus   *events.Bus
+	token string
+}
+
+// newServer supervises an iterate loop started with loopArgs and an
+// add-feature queue, both writing their output to logs and, unless it is
+// nil, logFile. add-feature runs with the same backend flags as the loop.
+func newServer(store *state.Store, loopArgs []string, logs *stream.RingBuffer, logFile io.Writer, bus *events.Bus, token string) *server {
+	out := io.Writer(logs)
+	if logFile != nil {
+		out = io.MultiWriter(logs, logFile)
+	}
+	s := &server{store: store, logs: logs, out: out, bus: bus, token: token}
+	s.loop = daemon.NewProcess(func() *exec.Cmd {
+		return selfCommand(append([]string{"iterate-loop"}, loopArgs...)...)
+	}, out)
+	s.loop.OnExit(func(err error) {
+		status := "exit status 0"
+		if err != nil {
+			status = err.Error()
+		}
+		fmt.Fprintf(out, "[%s] ⏹️ iterate-loop stopped (%s)\n", ts(), status)
+		bus.Publish(events.Event{Type: events.LoopStopped, Time: time.Now(), Text: status})
+	})
+	backend := backendArgs(loopArgs)
+	s.features = daemon.NewQueue(maxQueuedFeatures, func(job daemon.Job) error {
+		fmt.Fprintf(out, "[%s] ➕ add-feature #%d: %s\n", ts(), job.ID, firstLine(job.Description))
+		cmd := selfCommand(append([]string{"add-feature", "--prompt", job.Description}, backend...)...)
+		cmd.Stdout, cmd.Stderr = out, out
+		return cmd.Run()
+	})
+	return s
+}
+
+// selfCommand runs this cursor-iter binary with args
+func selfCommand(args ...string) *exec.Cmd {
+	exe, err := os.Executable()
+	if err != nil {
+		exe = os.Args[0]
+	}
+	return exec.Command(exe, args...)
+}
+
+// backendArgs picks the flags of iterate-loop arguments that add-feature
+// shares: the backend and model
+func backendArgs(loopArgs []string) []string {
+	var out []string
+	for i := 0; i < len(loopArgs); i++ {
+		name, _, hasValue := strings.Cut(strings.TrimLeft(loopArgs[i], "-"), "=")
+		switch name {
+		case "codex", "claude":
+			out = append(out, loopArgs[i])
+		case "model":
+			out = append(out, loopArgs[i])
+			if !hasValue && i+1 < len(loopArgs) {
+				i++
+				out = append(out, loopArgs[i])
+			}
+		}
+	}
+	return out
+}
+
+// firstLine returns the first line of s, for log lines about it
+func firstLine(s string) string {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), "\n")
+	return s
+}
+
+// handler routes the operations of serveAPI, /ws and /openapi.json
+func (s *server) handler() http.Handler {
+	handlers := map[string]http.HandlerFunc{
+		"getStatus":    s.getStatus,
+		"listTasks":    s.listTasks,
+		"getTask":      s.getTask,
+		"startLoop":    s.startLoop,
+		"pauseLoop":    s.pauseLoop,
+		"listFeatures": s.listFeatures,
+		"addFeature":   s.addFeature,
+		"getLogs":      s.getLogs,
+	}
+	mux := http.NewServeMux()
+	for _, op := range serveAPI.Operations {
+		mux.Handle(op.Method+" "+op.Path, handlers[op.ID])
+	}
+	mux.Handle("GET /ws", events.Handler(s.bus))
+	mux.HandleFunc("GET /openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		doc, err := serveAPI.OpenAPI()
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(doc)
+	})
+	return s.authorize(mux)
+}
+
+// authorize rejects requests without the server's token, when it has one.
+// Browsers can't set headers on WebSocket requests, so the token may also
+// come as a query parameter. Without a token the server only listens on
+// loopback, and anything running here may use it, but browsers send the
+// requests of every page the user visits too: requests from pages of other
+// sites, or naming another host, as a DNS rebinding attack does, are
+// refused.
+func (s *server) authorize(next http.Handler) http.Handler {
+	if s.token == "" {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !events.IsLoopbackHost(r.Host) || !events.AllowedOrigin(r) {
+				writeAPIError(w, http.StatusForbidden, errors.New("requests from other hosts or sites need serve's --token"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given := r.URL.Query().Get("token")
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			given = bearer
+		}
+		if subtle.ConstantTimeCompare([]byte(given), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeAPIError(w, http.StatusUnauthorized, errors.New("missing or wrong token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// summary returns the status of every task, from the files as they are now
+func (s *server) summary() (tasks.StatusSummary, *state.Snapshot) {
+	snap, _ := s.store.Refresh()
+	return tasks.SummarizeWith(snap.TasksMd, snap.ProgressMd, tasks.StatusOptions{Defaults: configLabelDefaults()}), snap
+}
+
+func (s *server) getStatus(w http.ResponseWriter, r *http.Request) {
+	sum, _ := s.summary()
+	writeJSON(w, serveStatus{
+		Loop:     s.loop.Status(),
+		Current:  sum.Current,
+		Next:     sum.Next,
+		Totals:   sum.Totals,
+		Features: s.features.Jobs(),
+	})
+}
+
+func (s *server) listTasks(w http.ResponseWriter, r *http.Request) {
+	sum, _ := s.summary()
+	writeJSON(w, sum)
+}
+
+func (s *server) getTask(w http.ResponseWriter, r *http.Request) {
+	title := r.PathValue("title")
+	sum, snap := s.summary()
+	for _, t := range sum.Tasks {
+		if strings.EqualFold(t.Title, title) {
+			writeJSON(w, taskDetail{Task: t, Markdown: tasks.ExtractTaskDetails(snap.TasksMd, t.Title)})
+			return
+		}
+	}
+	writeAPIError(w, http.StatusNotFound, fmt.Errorf("no task named %q", title))
+}
+
+func (s *server) startLoop(w http.ResponseWriter, r *http.Request) {
+	if err := s.loop.Start(); err != nil {
+		writeAPIError(w, loopErrorStatus(err), fmt.Errorf("iterate-loop: %v", err))
+		return
+	}
+	status := s.loop.Status()
+	fmt.Fprintf(s.out, "[%s] ▶️ iterate-loop started (pid %d)\n", ts(), status.PID)
+	s.bus.Publish(events.Event{Type: events.LoopStarted, Time: time.Now()})
+	writeJSON(w, status)
+}
+
+func (s *server) pauseLoop(w http.ResponseWriter, r *http.Request) {
+	if err := s.loop.Pause(); err != nil {
+		writeAPIError(w, loopErrorStatus(err), fmt.Errorf("iterate-loop: %v", err))
+		return
+	}
+	fmt.Fprintf(s.out, "[%s] ⏸️ Pausing iterate-loop\n", ts())
+	s.bus.Publish(events.Event{Type: events.LoopPaused, Time: time.Now()})
+	writeJSON(w, s.loop.Status())
+}
+
+// loopErrorStatus maps a Start or Pause error to a response status
+func loopErrorStatus(err error) int {
+	if errors.Is(err, daemon.ErrRunning) || errors.Is(err, daemon.ErrNotRunning) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func (s *server) listFeatures(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.features.Jobs())
+}
+
+func (s *server) addFeature(w http.ResponseWriter, r *http.Request) {
+	// Pages of other sites may post forms and text/plain without asking,
+	// but not JSON
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		writeAPIError(w, http.StatusUnsupportedMediaType, errors.New("the request body must be application/json"))
+		return
+	}
+	var req featureRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+	if strings.TrimSpace(req.Description) == "" {
+		writeAPIError(w, http.StatusBadRequest, errors.New("description is required"))
+		return
+	}
+	job, ok := s.features.Enqueue("feature", req.Description)
+	if !ok {
+		writeAPIError(w, http.StatusServiceUnavailable, fmt.Errorf("%d features are already waiting", maxQueuedFeatures))
+		return
+	}
+	writeJSON(w, job)
+}
+
+// getLogs writes the retained output and, with ?follow=true, streams new
+// output until the client disconnects
+func (s *server) getLogs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if r.URL.Query().Get("follow") != "true" {
+		w.Write(s.logs.Snapshot())
+		return
+	}
+	chunks, cancel := s.logs.Subscribe(256)
+	defer cancel()
+	w.Write(s.logs.Snapshot())
+	flusher, _ := w.(http.Flusher)
+	for {
+		if flusher != nil {
+			flusher.Flush()
+		}
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				return
+			}
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(apiError{Error: err.Error()})
+}
+
+// publishChanges publishes an event for every change to the control files
+// and every piece of output until ctx is done
+func (s *server) publishChanges(ctx context.Context) {
+	go s.store.Watch(ctx, 2*time.Second, 500*time.Millisecond)
+	snapshots, cancel := s.store.Subscribe()
+	defer cancel()
+	chunks, cancelLogs := s.logs.Subscribe(256)
+	defer cancelLogs()
+
+	snap := s.store.Snapshot()
+	before := tasks.Summarize(snap.TasksMd, snap.ProgressMd)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case snap := <-snapshots:
+			after := tasks.Summarize(snap.TasksMd, snap.ProgressMd)
+			for _, e := range events.Diff(before, after, time.Now()) {
+				s.bus.Publish(e)
+			}
+			before = after
+		case chunk, ok := <-chunks:
+			if !ok {
+				return
+			}
+			s.bus.Publish(events.LogExcerpt("", string(chunk), time.Now()))
+		}
+	}
+}
+
+// serveLoopLog is where serve keeps the output of the loop and of
+// add-feature beyond what /api/logs retains
+func serveLoopLog() string {
+	return getControlFilePath(filepath.Join("logs", "serve.log"))
+}
+
+// runServe serves the API on addr until SIGINT or SIGTERM. The first signal
+// pauses the loop and exits once it has stopped; a second stops its agents.
+func runServe(addr, token string, loopArgs []string, start bool) error {
+	if token == "" && !isLoopback(addr) {
+		return fmt.Errorf("refusing to serve on %s without --token; anyone who can reach it could run agents", addr)
+	}
+	file := resolveTasksFile()
+	if _, err := os.Stat(file); err != nil {
+		return fmt.Errorf("error reading tasks file: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(serveLoopLog()), 0755); err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(serveLoopLog(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	logs := stream.NewRingBuffer(serveLogCapacity)
+	bus := events.NewBus()
+	s := newServer(state.NewStore(file, resolveProgressFile()), loopArgs, logs, logFile, bus, token)
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: s.handler()}
+	go srv.Serve(ln)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.publishChanges(ctx)
+	fmt.Printf("[%s] 🌐 Serving the cursor-iter API on http://%s (OpenAPI document at /openapi.json)\n", ts(), ln.Addr())
+
+	if start {
+		if err := s.loop.Start(); err != nil {
+			return fmt.Errorf("failed to start iterate-loop: %v", err)
+		}
+		fmt.Printf("[%s] ▶️ Started iterate-loop (pid %d)\n", ts(), s.loop.Status().PID)
+	}
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	sig := <-signals
+	if s.loop.Status().State != daemon.StateStopped {
+		fmt.Printf("[%s] 🛑 Received %v: pausing iterate-loop (again to stop its agents now)\n", ts(), sig)
+		s.loop.Pause()
+		stopped := make(chan struct{})
+		go func() {
+			s.loop.Wait()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-signals:
+			s.loop.Pause()
+			<-stopped
+		}
+	}
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelShutdown()
+	srv.Shutdown(shutdownCtx)
+	logs.Close()
+	return nil
+}
+
+// isLoopback reports whether addr only accepts connections from this host
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	return err == nil && events.IsLoopbackHost(host)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// resolveTaskRef returns the title of the task of tasksMd that ref names:
+// by its title, ignoring case, or by its ID, the slug its log and note
+// files are named after
+func resolveTaskRef(tasksMd, ref string) (string, error) {
+	ref = strings.TrimSpace(ref)
+	var byID []string
+	for _, t := range tasks.ParseTasks(tasksMd) {
+		if strings.EqualFold(t.Title, ref) {
+			return t.Title, nil
+		}
+		if taskSlug(t.Title) == ref {
+			byID = append(byID, t.Title)
+		}
+	}
+	switch len(byID) {
+	case 0:
+		return "", fmt.Errorf("no task titled %q or with that ID; 'cursor-iter task-status' lists them", ref)
+	case 1:
+		return byID[0], nil
+	}
+	return "", fmt.Errorf("the ID %q is shared by %s; give the title instead", ref, strings.Join(byID, ", "))
+}
+
+// printTask prints everything known about one task: its progress.md status,
+// how long it has taken, its runs from the journal, the decisions made
+// about it, and its block from tasks.md
+func printTask(w io.Writer, tasksMd, progressMd, title string, entries []journal.Entry, decisions []journal.Decision, now time.Time) {
+	var runs []journal.Entry
+	for _, e := range entries {
+		if e.Task == title {
+			runs = append(runs, e)
+		}
+	}
+	entry, started := tasks.ParseProgressFor(progressMd, []string{title})[title]
+
+	fmt.Fprintf(w, "📋 %s (ID %s)\n", title, taskSlug(title))
+	switch {
+	case !started:
+		fmt.Fprintln(w, "Status:    ⏳ pending")
+	case entry.Status == "completed":
+		fmt.Fprintf(w, "Status:    ✅ completed %s%s\n", entry.CompletedAt.Format("2006-01-02 15:04"), entryNotes(entry.Notes))
+	case entry.Status == "in-progress":
+		fmt.Fprintf(w, "Status:    🔄 in progress since %s%s\n", entry.StartedAt.Format("2006-01-02 15:04"), entryNotes(entry.Notes))
+	case entry.Status == "blocked":
+		fmt.Fprintf(w, "Status:    ⛔ blocked since %s%s\n", entry.StartedAt.Format("2006-01-02 15:04"), entryNotes(entry.Notes))
+	default:
+		fmt.Fprintf(w, "Status:    ❌ %s since %s%s\n", entry.Status, entry.StartedAt.Format("2006-01-02 15:04"), entryNotes(entry.Notes))
+	}
+	for _, f := range entry.Fields {
+		fmt.Fprintf(w, "           %s: %s\n", f.Name, f.Value)
+	}
+
+	// Elapsed time runs from the first dispatch, or the in-progress entry
+	// when no run was journaled, to the completion or now
+	var first time.Time
+	var spent time.Duration
+	for _, r := range runs {
+		start := r.Started
+		if start.IsZero() {
+			start = r.Time.Add(-r.Duration())
+		}
+		if first.IsZero() || start.Before(first) {
+			first = start
+		}
+		spent += r.Duration()
+	}
+	if first.IsZero() && entry.Status == "in-progress" {
+		first = wallClock(entry.StartedAt)
+	}
+	if !first.IsZero() {
+		end := now
+		if entry.Status == "completed" && !entry.CompletedAt.IsZero() {
+			end = wallClock(entry.CompletedAt)
+		}
+		fmt.Fprintf(w, "Elapsed:   %v since it was first started, %v of it in %d run(s)\n", end.Sub(first).Round(time.Minute), spent.Round(time.Second), len(runs))
+	}
+	if attempts := journal.Attempts(entries, decisions, title); attempts > 0 {
+		fmt.Fprintf(w, "Attempts:  %d failed run(s) since it last completed or was triaged\n", attempts)
+	}
+
+	if len(runs) > 0 {
+		fmt.Fprintln(w, "\nRuns:")
+		for i, r := range runs {
+			outcome := r.Outcome
+			if r.Classification != "" && r.Failed() {
+				outcome += " (" + r.Classification + ")"
+			}
+			fmt.Fprintf(w, "  %d. %s  %s/%s  %s, took %v", i+1, r.Time.Format("2006-01-02 15:04"), r.Backend, r.Model, outcome, r.Duration().Round(time.Second))
+			if r.Error != "" {
+				fmt.Fprintf(w, " - %s", r.Error)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	var decided []journal.Decision
+	for _, d := range decisions {
+		if d.Task == title {
+			decided = append(decided, d)
+		}
+	}
+	if len(decided) > 0 {
+		fmt.Fprintln(w, "\nDecisions:")
+		for _, d := range decided {
+			by := d.By
+			if by == "" {
+				by = "unknown"
+			}
+			fmt.Fprintf(w, "  - %s  %s by %s%s\n", d.Time.Format("2006-01-02 15:04"), d.Action, by, entryNotes(d.Detail))
+		}
+	}
+
+	fmt.Fprintf(w, "\n%s\n", strings.TrimSpace(tasks.ExtractTaskDetails(tasksMd, title)))
+}
+
+// entryNotes renders notes after a status, or nothing without notes
+func entryNotes(notes string) string {
+	if notes == "" {
+		return ""
+	}
+	return " - " + notes
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// exitInterrupted is the exit status after a SIGINT or SIGTERM, as a shell
+// reports for Ctrl-C
+const exitInterrupted = 130
+
+// shutdown turns SIGINT and SIGTERM into an orderly stop of iterate-loop.
+// The first signal stops dispatching new tasks and gives the running agents
+// the grace period to finish; a second signal, or the end of the grace
+// period, stops them.
+type shutdown struct {
+	// ctx is done once the running agents must stop
+	ctx       context.Context
+	cancel    context.CancelFunc
+	requested atomic.Bool
+	signals   chan os.Signal
+}
+
+// watchSignals starts handling SIGINT and SIGTERM
+func watchSignals(grace time.Duration) *shutdown {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &shutdown{ctx: ctx, cancel: cancel, signals: make(chan os.Signal, 2)}
+	signal.Notify(s.signals, os.Interrupt, syscall.SIGTERM)
+	go s.watch(grace)
+	return s
+}
+
+func (s *shutdown) watch(grace time.Duration) {
+	var sig os.Signal
+	select {
+	case sig = <-s.signals:
+	case <-s.ctx.Done():
+		return
+	}
+	s.requested.Store(true)
+	if grace > 0 {
+		fmt.Printf("[%s] 🛑 Received %v: starting no new tasks and waiting up to %v for running agents (again to stop them now)\n", ts(), sig, grace)
+		select {
+		case <-s.signals:
+		case <-time.After(grace):
+		case <-s.ctx.Done():
+			return
+		}
+	}
+	fmt.Printf("[%s] 🛑 Stopping running agents...\n", ts())
+	s.cancel()
+}
+
+// Request stops the loop as a SIGINT would, e.g. from the dashboard
+func (s *shutdown) Request() {
+	select {
+	case s.signals <- os.Interrupt:
+	default:
+	}
+}
+
+// Requested reports whether a signal asked the loop to stop
+func (s *shutdown) Requested() bool {
+	return s.requested.Load()
+}
+
+// Stop restores the default signal handling
+func (s *shutdown) Stop() {
+	signal.Stop(s.signals)
+	s.cancel()
+}
+
+// releaseInterrupted puts a task whose agent was stopped midway back to
+// pending, so progress.md doesn't claim work that nothing is doing
+func releaseInterrupted(progressFile, title string) {
+	if _, err := os.Stat(progressFile); err != nil {
+		return
+	}
+	if err := updateControlFile(progressFile, func(progress string) string {
+		return tasks.ReleaseTask(progress, title)
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not update progress: %v\n", ts(), err)
+		return
+	}
+	fmt.Printf("[%s] ↩️ Stopped midway, back to pending: %s\n", ts(), title)
+}
+
+// tidyProgress drops the in-progress entries that agents left next to the
+// completion entry of a task, before the loop exits
+func tidyProgress(progressFile string) {
+	if _, err := os.Stat(progressFile); err != nil {
+		return
+	}
+	err := updateControlFile(progressFile, func(progress string) string {
+		for _, title := range tasks.GetCompletedTasks(progress) {
+			progress = tasks.ReleaseTask(progress, title)
+		}
+		return progress
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not update progress: %v\n", ts(), err)
+	}
+}
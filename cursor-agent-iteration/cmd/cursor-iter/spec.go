@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/spec"
+)
+
+// specMilestone groups staged tasks from add-task --from-url
+const specMilestone = "specs"
+
+// maxSpecSize rejects responses too large to be a spec
+const maxSpecSize = 1 << 20
+
+// headerFlags collects repeated --header "Name: value" flags
+type headerFlags []string
+
+func (h *headerFlags) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerFlags) Set(v string) error {
+	if name, _, ok := strings.Cut(v, ":"); !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("want \"Name: value\", got %q", v)
+	}
+	*h = append(*h, v)
+	return nil
+}
+
+// specSource says how specs are fetched
+type specSource struct {
+	// Headers are "Name: value" pairs sent with the request
+	Headers []string
+	// Offline refuses to fetch at all
+	Offline bool
+}
+
+// specHeader builds the headers of a spec request from "Name: value" pairs.
+// $VARS in values are expanded, so a token can stay out of the shell
+// history, and SPEC_TOKEN is sent as a bearer token unless an Authorization
+// header is given.
+func specHeader(pairs []string) http.Header {
+	header := http.Header{}
+	for _, pair := range pairs {
+		name, value, _ := strings.Cut(pair, ":")
+		header.Add(strings.TrimSpace(name), os.ExpandEnv(strings.TrimSpace(value)))
+	}
+	if token := os.Getenv("SPEC_TOKEN"); token != "" && header.Get("Authorization") == "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	return header
+}
+
+// fetchSpec gets the spec at rawURL as text, turning an HTML page such as a
+// Notion export or wiki page into Markdown-ish text
+func fetchSpec(rawURL string, src specSource) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid spec URL %q: want an http or https URL", rawURL)
+	}
+	if src.Offline {
+		return "", fmt.Errorf("--offline forbids fetching %s", rawURL)
+	}
+	fmt.Printf("[%s] 🌐 Fetching spec from %s...\n", ts(), u.Host)
+	body, contentType, err := fetchURL(rawURL, specHeader(src.Headers))
+	var status httpStatusError
+	switch {
+	case errors.As(err, &status) && (status == http.StatusUnauthorized || status == http.StatusForbidden):
+		return "", fmt.Errorf("could not fetch %s (%v): pass credentials with --header 'Authorization: Bearer $TOKEN' or set SPEC_TOKEN", rawURL, err)
+	case err != nil:
+		return "", fmt.Errorf("could not fetch %s: %v", rawURL, err)
+	case len(body) > maxSpecSize:
+		return "", fmt.Errorf("%s is %d bytes, more than the %d a spec may be", rawURL, len(body), maxSpecSize)
+	}
+	text := string(body)
+	if spec.IsHTML(contentType, body) {
+		text = spec.Text(text)
+	}
+	if strings.TrimSpace(text) == "" {
+		return "", fmt.Errorf("%s has no text", rawURL)
+	}
+	return text, nil
+}
+
+// addSpecTask fetches the spec at rawURL, saves it under .cursor-iter/specs
+// and adds a task pointing at it to tasks.md, or to the staging file with
+// stage. The title defaults to the spec's first heading. It reports false
+// when a task of that title already exists.
+func addSpecTask(rawURL string, src specSource, title, milestone string, stage bool) (string, bool, error) {
+	text, err := fetchSpec(rawURL, src)
+	if err != nil {
+		return "", false, err
+	}
+	if title == "" {
+		title = spec.Title(text)
+	}
+	existing, err := existingTaskTitles()
+	if err != nil {
+		return title, false, err
+	}
+	if existing[title] {
+		return title, false, nil
+	}
+	path := getControlFilePath(filepath.Join("specs", taskSlug(title)+".md"))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return title, false, err
+	}
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		return title, false, err
+	}
+	block := spec.TaskBlock(title, rawURL, filepath.ToSlash(path), text)
+	return title, true, addGeneratedTasks([]string{title}, []string{block}, milestone, stage)
+}
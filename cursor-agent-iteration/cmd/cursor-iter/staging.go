@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/atomicfile"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// stagingPromptNote asks add-feature agents to group the tasks they create
+const stagingPromptNote = `
+
+## Milestones
+
+Group the new tasks into milestones that can be accepted and shipped independently. Add a ` + "`[milestone:<short-name>]`" + ` label to the **Labels:** line of every new task (add the line if the task has none). New tasks are staged for review before they are worked on.
+`
+
+// stagedTasksPath is where generated tasks wait for approval
+func stagedTasksPath() string {
+	return getControlFilePath("staged-tasks.md")
+}
+
+// stageNewTasks moves the tasks add-feature added to tasks.md into the
+// staging file. With always unset they are only staged when there are more
+// than over of them.
+func stageNewTasks(previousTasks string, milestone string, always bool, over int) ([]tasks.StagedTask, error) {
+	tasksPath := getControlFilePath("tasks.md")
+	unlock, err := atomicfile.LockFiles(tasksPath, stagedTasksPath())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	current, err := os.ReadFile(tasksPath)
+	if err != nil {
+		return nil, err
+	}
+	remaining, taken := tasks.TakeNewTasks(previousTasks, string(current), milestone)
+	if len(taken) == 0 {
+		return nil, nil
+	}
+	if !always && len(taken) <= over {
+		fmt.Printf("[%s] 📋 %d new tasks (not more than %d), adding them to tasks.md directly\n", ts(), len(taken), over)
+		return nil, nil
+	}
+
+	// Write the staging file first so a failure can't lose tasks
+	staged, _ := os.ReadFile(stagedTasksPath())
+	if err := atomicfile.WriteFile(stagedTasksPath(), []byte(tasks.AddStaged(string(staged), taken)), 0644); err != nil {
+		return nil, err
+	}
+	if err := atomicfile.WriteFile(tasksPath, []byte(remaining), 0644); err != nil {
+		return nil, err
+	}
+	return taken, nil
+}
+
+// printStagedMilestones lists the staged milestones and how to accept them
+func printStagedMilestones(path string) {
+	data, _ := os.ReadFile(path)
+	milestones := tasks.ParseStaged(string(data))
+	if len(milestones) == 0 {
+		fmt.Println("No staged tasks")
+		return
+	}
+	for _, m := range milestones {
+		fmt.Printf("  📦 %s (%d tasks)\n", m.Name, len(m.Tasks))
+		for _, t := range m.Tasks {
+			fmt.Printf("     - %s\n", t.Title)
+		}
+	}
+	fmt.Printf("💡 Run 'cursor-iter accept-tasks --milestone <name>' to queue a milestone\n")
+}
+
+// acceptStagedMilestone moves one staged milestone into tasks.md
+func acceptStagedMilestone(milestone string) error {
+	tasksPath := resolveTasksFile()
+	unlock, err := atomicfile.LockFiles(tasksPath, stagedTasksPath())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	staged, err := os.ReadFile(stagedTasksPath())
+	if err != nil {
+		return fmt.Errorf("no staged tasks: %v", err)
+	}
+	current, err := os.ReadFile(tasksPath)
+	if err != nil {
+		return err
+	}
+	newStaged, newTasks, accepted, skipped, err := tasks.AcceptMilestone(string(staged), string(current), milestone)
+	if err != nil {
+		return err
+	}
+	// Queue the tasks before removing them from staging so a failure can't lose them
+	if err := atomicfile.WriteFile(tasksPath, []byte(newTasks), 0644); err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(stagedTasksPath(), []byte(newStaged), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Accepted %d tasks from milestone '%s' into %s\n", len(accepted), milestone, tasksPath)
+	for _, title := range skipped {
+		fmt.Printf("⚠️ Skipped '%s': already in %s\n", title, tasksPath)
+	}
+	return nil
+}
+
+// reviewStagedMilestones asks about each staged milestone in turn
+func reviewStagedMilestones(in io.Reader) error {
+	data, err := os.ReadFile(stagedTasksPath())
+	if err != nil {
+		return fmt.Errorf("no staged tasks: %v", err)
+	}
+	reader := bufio.NewReader(in)
+	for _, m := range tasks.ParseStaged(string(data)) {
+		fmt.Printf("\n📦 %s (%d tasks)\n", m.Name, len(m.Tasks))
+		for _, t := range m.Tasks {
+			fmt.Printf("   - %s\n", t.Title)
+		}
+		fmt.Print("Accept this milestone? [y/N/q] ")
+		answer, err := reader.ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer == "y" || answer == "yes" {
+			if err := acceptStagedMilestone(m.Name); err != nil {
+				return err
+			}
+		}
+		if answer == "q" || err != nil {
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+)
+
+// parsePrices parses "model=usd,model=usd" prices per million tokens
+func parsePrices(spec string) (map[string]float64, error) {
+	prices := make(map[string]float64)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		model, price, found := strings.Cut(item, "=")
+		usd, err := strconv.ParseFloat(strings.TrimSpace(price), 64)
+		if !found || err != nil || usd < 0 {
+			return nil, fmt.Errorf("invalid price %q, want model=usd-per-million-tokens", item)
+		}
+		prices[strings.TrimSpace(model)] = usd
+	}
+	return prices, nil
+}
+
+// parseSince accepts a duration such as 24h or 7d, or a date (2006-01-02)
+func parseSince(spec string, now time.Time) (time.Time, error) {
+	if spec == "" {
+		return time.Time{}, nil
+	}
+	if days, ok := strings.CutSuffix(spec, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(spec); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", spec, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q, want e.g. 7d, 12h or 2025-01-31", spec)
+}
+
+// printModelStats prints one table row per stats group. Cost is shown for
+// models with a known price.
+func printModelStats(out io.Writer, stats []journal.Stats, opts journal.StatsOptions, prices map[string]float64) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	var header []string
+	if opts.Period != journal.PeriodAll {
+		header = append(header, strings.ToUpper(opts.Period))
+	}
+	header = append(header, "BACKEND", "MODEL")
+	if opts.ByLabel {
+		header = append(header, "LABEL")
+	}
+	header = append(header, "RUNS", "SUCCESS", "FAILED", "AVG ATTEMPTS", "AVG TIME", "TOKENS", "COST")
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+
+	for _, s := range stats {
+		var row []string
+		if opts.Period != journal.PeriodAll {
+			row = append(row, s.Period)
+		}
+		row = append(row, s.Backend, s.Model)
+		if opts.ByLabel {
+			row = append(row, s.Label)
+		}
+		attempts := "-"
+		if s.AvgAttempts() > 0 {
+			attempts = fmt.Sprintf("%.1f", s.AvgAttempts())
+		}
+		tokens, cost := "-", "-"
+		if s.Tokens > 0 {
+			tokens = strconv.FormatInt(s.Tokens, 10)
+			if price, ok := prices[s.Model]; ok {
+				cost = fmt.Sprintf("$%.2f", s.Cost(price))
+			}
+		}
+		row = append(row,
+			strconv.Itoa(s.Runs),
+			fmt.Sprintf("%.0f%%", s.SuccessRate()*100),
+			strconv.Itoa(s.Failed),
+			attempts,
+			s.AvgDuration().Round(time.Second).String(),
+			tokens,
+			cost,
+		)
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// statusFormats are the values of task-status --format; text is the emoji
+// report
+var statusFormats = []string{"text", "json", "yaml", "table"}
+
+// summaryThreshold is the number of tasks above which the text report shows
+// only counts unless --summary=false or --page asks for tasks
+const summaryThreshold = 100
+
+// writeStatus renders a status summary in a machine-friendly format
+func writeStatus(out io.Writer, format string, s tasks.StatusSummary) error {
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(out, "%s\n", data)
+		return err
+	case "yaml":
+		_, err := io.WriteString(out, statusYAML(s))
+		return err
+	case "table":
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TITLE\tSTATUS\tCRITERIA\tSTARTED\tCOMPLETED")
+		for _, t := range s.Tasks {
+			fmt.Fprintf(w, "%s\t%s\t%d/%d\t%s\t%s\n", t.Title, t.Status, t.ACChecked, t.ACTotal, tableTime(t.StartedAt), tableTime(t.CompletedAt))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if s.Page != nil {
+			fmt.Fprintf(out, "\n%s\n", pageText(*s.Page, len(s.Tasks)))
+		}
+		if _, err := fmt.Fprintf(out, "\nTotal: %d  Completed: %d  In progress: %d  Pending: %d  Blocked: %d\n",
+			s.Totals.Total, s.Totals.Completed, s.Totals.InProgress, s.Totals.Pending, s.Totals.Blocked); err != nil {
+			return err
+		}
+		if len(s.Groups) > 0 {
+			fmt.Fprintln(out)
+			return writeGroups(out, s.Groups)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown format %q (want %s)", format, strings.Join(statusFormats, ", "))
+}
+
+// writeGroups writes the counts per group as an aligned table
+func writeGroups(out io.Writer, groups []tasks.StatusGroup) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "GROUP\tTOTAL\tCOMPLETED\tIN PROGRESS\tPENDING\tBLOCKED")
+	for _, g := range groups {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\n", g.Name, g.Totals.Total, g.Totals.Completed, g.Totals.InProgress, g.Totals.Pending, g.Totals.Blocked)
+	}
+	return w.Flush()
+}
+
+// pageText describes a page of n tasks, e.g. "Page 2 of 20 (tasks 51-100)"
+func pageText(p tasks.StatusPage, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("Page %d of %d (no tasks)", p.Number, p.Pages)
+	}
+	return fmt.Sprintf("Page %d of %d (tasks %d-%d)", p.Number, p.Pages, p.First, p.First+n-1)
+}
+
+// statusEmoji marks a task's status in the text summary
+var statusEmoji = map[string]string{"completed": "✅", "in-progress": "🔄", "blocked": "⛔", "failed": "❌", "pending": "⏳"}
+
+// writeStatusSummary writes the compact text report of a trimmed summary:
+// the totals, then the groups and the page of tasks when there are any.
+// groupBy names the groups.
+func writeStatusSummary(out io.Writer, s tasks.StatusSummary, groupBy string) error {
+	var b strings.Builder
+	b.WriteString("📊 Task Status Summary\n")
+	b.WriteString("======================\n")
+	switch {
+	case s.Current != "":
+		fmt.Fprintf(&b, "🎯 CURRENT TASK: %s\n\n", s.Current)
+	case s.Next != "":
+		fmt.Fprintf(&b, "🎯 NEXT TASK: %s\n\n", s.Next)
+	case s.Totals.Total > 0 && s.Totals.Completed == s.Totals.Total:
+		b.WriteString("🎯 ALL TASKS COMPLETED! 🎉\n\n")
+	}
+	fmt.Fprintf(&b, "Total Tasks: %d\n", s.Totals.Total)
+	fmt.Fprintf(&b, "✅ Completed: %d\n", s.Totals.Completed)
+	fmt.Fprintf(&b, "🔄 In Progress: %d\n", s.Totals.InProgress)
+	fmt.Fprintf(&b, "⏳ Pending: %d\n", s.Totals.Pending)
+	if s.Totals.Blocked > 0 {
+		fmt.Fprintf(&b, "⛔ Blocked: %d\n", s.Totals.Blocked)
+	}
+	if s.Totals.Failed > 0 {
+		fmt.Fprintf(&b, "❌ Failed: %d\n", s.Totals.Failed)
+	}
+	if _, err := io.WriteString(out, b.String()); err != nil {
+		return err
+	}
+
+	if len(s.Groups) > 0 {
+		fmt.Fprintf(out, "\n📁 By %s:\n", groupBy)
+		if err := writeGroups(out, s.Groups); err != nil {
+			return err
+		}
+	}
+	if s.Page != nil {
+		fmt.Fprintf(out, "\n📄 %s:\n", pageText(*s.Page, len(s.Tasks)))
+		for _, t := range s.Tasks {
+			fmt.Fprintf(out, "  - %s %s (%d/%d criteria completed)\n", statusEmoji[t.Status], t.Title, t.ACChecked, t.ACTotal)
+		}
+	}
+	return nil
+}
+
+// tableTime formats an optional timestamp for the table
+func tableTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format("2006-01-02 15:04")
+}
+
+// statusYAML renders a status summary as YAML with the keys of its JSON
+// form. Strings are written as JSON strings, which YAML reads as
+// double-quoted scalars.
+func statusYAML(s tasks.StatusSummary) string {
+	var b strings.Builder
+	if s.Current != "" {
+		fmt.Fprintf(&b, "current: %s\n", yamlString(s.Current))
+	}
+	if s.Next != "" {
+		fmt.Fprintf(&b, "next: %s\n", yamlString(s.Next))
+	}
+	if len(s.Tasks) == 0 {
+		b.WriteString("tasks: []\n")
+	} else {
+		b.WriteString("tasks:\n")
+	}
+	for _, t := range s.Tasks {
+		fmt.Fprintf(&b, "  - title: %s\n", yamlString(t.Title))
+		fmt.Fprintf(&b, "    status: %s\n", t.Status)
+		fmt.Fprintf(&b, "    ac_checked: %d\n", t.ACChecked)
+		fmt.Fprintf(&b, "    ac_total: %d\n", t.ACTotal)
+		if t.StartedAt != nil {
+			fmt.Fprintf(&b, "    started_at: %s\n", t.StartedAt.Format(time.RFC3339))
+		}
+		if t.CompletedAt != nil {
+			fmt.Fprintf(&b, "    completed_at: %s\n", t.CompletedAt.Format(time.RFC3339))
+		}
+		if t.Notes != "" {
+			fmt.Fprintf(&b, "    notes: %s\n", yamlString(t.Notes))
+		}
+	}
+	b.WriteString("totals:\n")
+	writeYAMLTotals(&b, "  ", s.Totals)
+	if s.Page != nil {
+		b.WriteString("page:\n")
+		fmt.Fprintf(&b, "  number: %d\n", s.Page.Number)
+		fmt.Fprintf(&b, "  size: %d\n", s.Page.Size)
+		fmt.Fprintf(&b, "  pages: %d\n", s.Page.Pages)
+		fmt.Fprintf(&b, "  first: %d\n", s.Page.First)
+	}
+	if len(s.Groups) > 0 {
+		b.WriteString("groups:\n")
+	}
+	for _, g := range s.Groups {
+		fmt.Fprintf(&b, "  - name: %s\n", yamlString(g.Name))
+		b.WriteString("    totals:\n")
+		writeYAMLTotals(&b, "      ", g.Totals)
+	}
+	return b.String()
+}
+
+// writeYAMLTotals writes the keys of totals at the given indent
+func writeYAMLTotals(b *strings.Builder, indent string, totals tasks.StatusTotals) {
+	fmt.Fprintf(b, "%stotal: %d\n", indent, totals.Total)
+	fmt.Fprintf(b, "%scompleted: %d\n", indent, totals.Completed)
+	fmt.Fprintf(b, "%sin_progress: %d\n", indent, totals.InProgress)
+	fmt.Fprintf(b, "%spending: %d\n", indent, totals.Pending)
+	fmt.Fprintf(b, "%sblocked: %d\n", indent, totals.Blocked)
+}
+
+// yamlString quotes a string for YAML
+func yamlString(v string) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/statusline"
+)
+
+// statusLineInterval is how often iterate-loop records its running agents
+// for the statusline command
+const statusLineInterval = 5 * time.Second
+
+// statusLinePath is the cache file of the statusline command
+func statusLinePath() string {
+	return getControlFilePath("statusline.json")
+}
+
+// publishRunning keeps the running count of the statusline cache up to date
+// until the returned function is called, which clears it
+func publishRunning(tr *TaskRunner) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(statusLineInterval)
+		defer ticker.Stop()
+		for {
+			statusline.SetRunning(statusLinePath(), tr.ActiveCount(), time.Now())
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-finished
+			statusline.SetRunning(statusLinePath(), 0, time.Now())
+		})
+	}
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/atomicfile"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/coord"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/runner"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// stressMaxRuns bounds the runs of a stress test, per task, so a task that
+// never completes can't keep it going
+const stressMaxRuns = 20
+
+// stressOptions configure a stress test
+type stressOptions struct {
+	Tasks        int
+	Concurrency  int
+	Criteria     int           // acceptance criteria per task
+	Delay        time.Duration // average time a mock agent takes
+	FailRate     float64       // share of mock runs that fail, to be retried
+	ArchiveEvery int           // archive after this many completions (0 = only at the end)
+	Seed         int64
+}
+
+// stressReport is the outcome of a stress test
+type stressReport struct {
+	Runs      int
+	Failures  int
+	Archives  int
+	MaxActive int // most agents running at once
+	Duration  time.Duration
+	// Problems are the broken invariants: lost, duplicated or stuck tasks,
+	// leaked agent slots and journal entries that don't add up
+	Problems []string
+}
+
+// stress runs simulated agents through the TaskRunner, the agent
+// coordinator, progress.md and the archive in the current directory
+type stress struct {
+	opts         stressOptions
+	tasksFile    string
+	progressFile string
+	archiveDir   string
+	// files serializes tasks.md and progress.md between the loop and the
+	// mock agents. Real agents are processes of their own that don't take
+	// it; here a lost update points at cursor-iter, not at the simulation.
+	files sync.Mutex
+	rngMu sync.Mutex
+	rng   *rand.Rand
+}
+
+// stressTitle is the title of the i-th simulated task
+func stressTitle(i int) string {
+	return fmt.Sprintf("Stress task %04d", i)
+}
+
+// reStressTask finds the task a mock agent was sent
+var reStressTask = regexp.MustCompile(`(?m)^### Task: (.+)$`)
+
+// stressTasks returns a tasks.md of n simulated tasks
+func stressTasks(n, criteria int) string {
+	var b strings.Builder
+	b.WriteString("# Tasks\n\n## Current Tasks\n\n")
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "### Task: %s\n\n**Context:** Simulated load for cursor-iter stress\n\n**Acceptance Criteria:**\n\n", stressTitle(i))
+		for c := 1; c <= criteria; c++ {
+			fmt.Fprintf(&b, "* [ ] Step %d\n", c)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// checkOffTask checks every acceptance criterion of a task in tasks.md
+func checkOffTask(md, title string) string {
+	lines := strings.Split(md, "\n")
+	in := false
+	for i, line := range lines {
+		if strings.HasPrefix(line, "### Task:") {
+			in = strings.TrimSpace(strings.TrimPrefix(line, "### Task:")) == title
+			continue
+		}
+		if in {
+			lines[i] = strings.Replace(line, "[ ]", "[x]", 1)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (s *stress) random() float64 {
+	s.rngMu.Lock()
+	defer s.rngMu.Unlock()
+	return s.rng.Float64()
+}
+
+// agent is a mock agent: it writes a few lines over about the delay, then
+// either fails or checks off its task and moves it to completed as a real
+// agent would
+func (s *stress) agent(opts runner.Options, _ runner.Backend, _ string, prompt string) error {
+	m := reStressTask.FindStringSubmatch(prompt)
+	if m == nil {
+		return errors.New("mock agent: no task in the prompt")
+	}
+	title := m[1]
+	var done <-chan struct{}
+	if opts.Context != nil {
+		done = opts.Context.Done()
+	}
+	const steps = 4
+	for i := 1; i <= steps; i++ {
+		select {
+		case <-done:
+			return runner.ErrInterrupted
+		case <-time.After(time.Duration(s.random() * 2 * float64(s.opts.Delay) / steps)):
+		}
+		fmt.Fprintf(opts.Stdout, "mock agent: %s, step %d/%d\n", title, i, steps)
+	}
+	if s.random() < s.opts.FailRate {
+		fmt.Fprintf(opts.Stderr, "mock agent: simulated failure on %s\n", title)
+		return errors.New("simulated agent failure")
+	}
+
+	s.files.Lock()
+	defer s.files.Unlock()
+	tasksMd, err := os.ReadFile(s.tasksFile)
+	if err != nil {
+		return err
+	}
+	progressMd, err := os.ReadFile(s.progressFile)
+	if err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(s.tasksFile, []byte(checkOffTask(string(tasksMd), title)), 0644); err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(s.progressFile, []byte(tasks.MoveTaskToCompleted(string(progressMd), title, "stress")), 0644)
+}
+
+// next returns the task to dispatch: one a failed run left in progress, or
+// else the next pending one, which is marked in progress. The title is ""
+// when there is none.
+func (s *stress) next(tr *TaskRunner) (title, details string, err error) {
+	s.files.Lock()
+	defer s.files.Unlock()
+	tasksMd, err := os.ReadFile(s.tasksFile)
+	if err != nil {
+		return "", "", err
+	}
+	progressMd, err := os.ReadFile(s.progressFile)
+	if err != nil {
+		return "", "", err
+	}
+	running := make(map[string]bool)
+	for _, t := range tr.GetRunningTasks() {
+		running[t] = true
+	}
+	for _, t := range tasks.GetAllInProgressTasks(string(tasksMd), string(progressMd)) {
+		if !running[t.Title] {
+			return t.Title, tasks.ExtractTaskDetails(string(tasksMd), t.Title), nil
+		}
+	}
+	t := tasks.GetNextPendingTaskWithProgress(string(tasksMd), string(progressMd))
+	if t == nil {
+		return "", "", nil
+	}
+	if err := atomicfile.WriteFile(s.progressFile, []byte(tasks.MarkTaskInProgress(string(progressMd), t.Title)), 0644); err != nil {
+		return "", "", err
+	}
+	return t.Title, tasks.ExtractTaskDetails(string(tasksMd), t.Title), nil
+}
+
+// completed reports whether progress.md shows a task completed, or it was
+// archived since its agent finished
+func (s *stress) completed(title string) bool {
+	s.files.Lock()
+	defer s.files.Unlock()
+	progressMd, _ := os.ReadFile(s.progressFile)
+	if tasks.IsTaskCompleted(string(progressMd), title) {
+		return true
+	}
+	tasksMd, _ := os.ReadFile(s.tasksFile)
+	for _, t := range tasks.ParseTasks(string(tasksMd)) {
+		if t.Title == title {
+			return false
+		}
+	}
+	return true
+}
+
+// archive archives the completed tasks while agents keep running
+func (s *stress) archive() error {
+	s.files.Lock()
+	defer s.files.Unlock()
+	tasksMd, err := os.ReadFile(s.tasksFile)
+	if err != nil {
+		return err
+	}
+	progressMd, err := os.ReadFile(s.progressFile)
+	if err != nil {
+		return err
+	}
+	_, err = archiveCompleted(string(tasksMd), string(progressMd), s.tasksFile, s.progressFile, s.archiveDir, tasks.ArchiveFilter{})
+	return err
+}
+
+// watch reads what the progress line, the dashboard and task-status read,
+// for as long as the test runs
+func (s *stress) watch(tr *TaskRunner, stop <-chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(2 * time.Millisecond):
+		}
+		for _, run := range tr.runningExecutions() {
+			run.Output.Snapshot()
+		}
+		tasksMd, _ := os.ReadFile(s.tasksFile)
+		progressMd, _ := os.ReadFile(s.progressFile)
+		tasks.Summarize(string(tasksMd), string(progressMd))
+	}
+}
+
+// runStress runs a stress test in the current directory, which it fills
+// with a .cursor-iter of simulated tasks
+func runStress(o stressOptions) (stressReport, error) {
+	var rep stressReport
+	if err := os.MkdirAll(CursorIterDir, 0755); err != nil {
+		return rep, err
+	}
+	s := &stress{
+		opts:         o,
+		tasksFile:    getControlFilePath("tasks.md"),
+		progressFile: getControlFilePath("progress.md"),
+		archiveDir:   getControlFilePath("completed_tasks"),
+		rng:          rand.New(rand.NewSource(o.Seed)),
+	}
+	if err := os.WriteFile(s.tasksFile, []byte(stressTasks(o.Tasks, o.Criteria)), 0644); err != nil {
+		return rep, err
+	}
+	if err := os.WriteFile(s.progressFile, []byte("# Progress Log\n\n## In Progress\n\n## Completed Tasks\n\n"), 0644); err != nil {
+		return rep, err
+	}
+	c, err := coord.New(getControlFilePath("coord"), ".", o.Concurrency, 0, 1)
+	if err != nil {
+		return rep, err
+	}
+	c.Start(10 * time.Millisecond)
+	defer c.Close()
+	tr := NewTaskRunner(o.Concurrency)
+	tr.SetCoordinator(c)
+	runPrompt = s.agent
+	defer func() { runPrompt = runner.RunPrompt }()
+
+	runID := startRun(false)
+	start := time.Now()
+	stop := make(chan struct{})
+	var watchers sync.WaitGroup
+	watchers.Add(1)
+	go s.watch(tr, stop, &watchers)
+
+	completed := 0
+	for completed < o.Tasks && rep.Runs < o.Tasks*stressMaxRuns {
+		for tr.ActiveCount() < o.Concurrency {
+			title, details, err := s.next(tr)
+			if err != nil {
+				return rep, err
+			}
+			if title == "" {
+				break
+			}
+			if err := tr.StartTask(title, details, runner.BackendCursorAgent, "auto", false); err != nil {
+				rep.Problems = append(rep.Problems, fmt.Sprintf("could not dispatch '%s': %v", title, err))
+				break
+			}
+			rep.Runs++
+			rep.MaxActive = max(rep.MaxActive, tr.ActiveCount())
+		}
+		if tr.ActiveCount() == 0 {
+			rep.Problems = append(rep.Problems, fmt.Sprintf("nothing to dispatch with %d of %d tasks completed", completed, o.Tasks))
+			break
+		}
+
+		title, runErr := tr.WaitForAny()
+		outcome := journal.OutcomeCompleted
+		switch {
+		case runErr != nil:
+			outcome = journal.OutcomeFailed
+			rep.Failures++
+		case !s.completed(title):
+			outcome = journal.OutcomeIncomplete
+			rep.Problems = append(rep.Problems, fmt.Sprintf("'%s' finished cleanly but progress.md doesn't show it completed", title))
+		default:
+			completed++
+		}
+		recordRun(runID, tr.LastRun(title), outcome, runErr)
+		if outcome == journal.OutcomeCompleted && o.ArchiveEvery > 0 && completed%o.ArchiveEvery == 0 {
+			if err := s.archive(); err != nil {
+				return rep, err
+			}
+			rep.Archives++
+		}
+	}
+	for tr.ActiveCount() > 0 {
+		tr.WaitForAny()
+	}
+	close(stop)
+	watchers.Wait()
+
+	// What's left is archived too, so every task should end up in exactly
+	// one archive
+	if err := s.archive(); err != nil {
+		return rep, err
+	}
+	rep.Archives++
+	rep.Duration = time.Since(start)
+	rep.Problems = append(rep.Problems, s.check(runID, tr, c, rep)...)
+	return rep, nil
+}
+
+// check returns the invariants a finished stress test broke
+func (s *stress) check(runID string, tr *TaskRunner, c *coord.Coordinator, rep stressReport) []string {
+	var problems []string
+	if n := tr.ActiveCount(); n > 0 {
+		problems = append(problems, fmt.Sprintf("the task runner still counts %d running agent(s)", n))
+	}
+	if states, err := c.States(); err != nil {
+		problems = append(problems, fmt.Sprintf("reading the coordinator: %v", err))
+	} else {
+		for _, st := range states {
+			if st.Running > 0 {
+				problems = append(problems, fmt.Sprintf("the coordinator still holds %d agent slot(s)", st.Running))
+			}
+		}
+	}
+
+	tasksMd, _ := os.ReadFile(s.tasksFile)
+	progressMd, _ := os.ReadFile(s.progressFile)
+	if left := tasks.ParseTasks(string(tasksMd)); len(left) > 0 {
+		problems = append(problems, fmt.Sprintf("%d task(s) left in tasks.md after the final archive", len(left)))
+	}
+	if n := len(tasks.GetInProgressTasks(string(progressMd))) + len(tasks.GetCompletedTasks(string(progressMd))); n > 0 {
+		problems = append(problems, fmt.Sprintf("%d entr(ies) left in progress.md after the final archive", n))
+	}
+
+	var archives strings.Builder
+	files, _ := filepath.Glob(filepath.Join(s.archiveDir, "*.md"))
+	for _, f := range files {
+		data, _ := os.ReadFile(f)
+		archives.Write(data)
+	}
+	var lost, duplicated []string
+	for i := 1; i <= s.opts.Tasks; i++ {
+		switch n := strings.Count(archives.String(), "] "+stressTitle(i)+" - "); {
+		case n == 0:
+			lost = append(lost, stressTitle(i))
+		case n > 1:
+			duplicated = append(duplicated, stressTitle(i))
+		}
+	}
+	if len(lost) > 0 {
+		problems = append(problems, fmt.Sprintf("%d task(s) missing from the archives, e.g. '%s'", len(lost), lost[0]))
+	}
+	if len(duplicated) > 0 {
+		problems = append(problems, fmt.Sprintf("%d task(s) archived more than once, e.g. '%s'", len(duplicated), duplicated[0]))
+	}
+
+	entries, err := journal.Read(journalPath())
+	if err != nil {
+		return append(problems, fmt.Sprintf("reading the journal: %v", err))
+	}
+	runs, failures, completions := 0, 0, 0
+	for _, e := range entries {
+		if e.RunID != runID {
+			continue
+		}
+		runs++
+		switch e.Outcome {
+		case journal.OutcomeFailed:
+			failures++
+		case journal.OutcomeCompleted:
+			completions++
+		}
+	}
+	if runs != rep.Runs || failures != rep.Failures || completions != s.opts.Tasks {
+		problems = append(problems, fmt.Sprintf("the journal has %d run(s), %d failed and %d completed; expected %d, %d and %d",
+			runs, failures, completions, rep.Runs, rep.Failures, s.opts.Tasks))
+	}
+	return problems
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/scope"
+)
+
+// fileScopeChecker enforces --strict-files: changes a task run commits to
+// files outside its "**Files to Modify:**" list and the allowed globs are
+// reverted, and the agent is told to stay in scope. With parallel tasks a
+// run's range can include commits of other tasks, so every commit is only
+// checked once, by the first run that claims it.
+type fileScopeChecker struct {
+	allowed []string
+	seen    map[string]bool
+}
+
+// newFileScopeChecker returns the checker for --strict-files, or nil when
+// the mode is off. allow is a comma-separated list of globs every task may
+// change.
+func newFileScopeChecker(enabled bool, allow string) *fileScopeChecker {
+	if !enabled {
+		return nil
+	}
+	var allowed []string
+	for _, g := range strings.Split(allow, ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			allowed = append(allowed, g)
+		}
+	}
+	return &fileScopeChecker{allowed: allowed, seen: make(map[string]bool)}
+}
+
+// PromptNote tells agents up front that the file list is enforced
+func (c *fileScopeChecker) PromptNote() string {
+	if c == nil {
+		return ""
+	}
+	also := ""
+	if len(c.allowed) > 0 {
+		also = ", plus files matching " + strings.Join(c.allowed, ", ")
+	}
+	return "Only change the files listed under Files to Modify" + also + ". Changes to any other file are reverted after your run; if the task needs another file, explain why in your progress notes instead of changing it."
+}
+
+// Check looks at the files changed by the commits the run made since it
+// started. Commits already checked for a task that finished earlier are
+// skipped, and so are commits only touching the scope of other running
+// tasks, which are left for them. Out-of-scope changes are reverted in a
+// new commit when no other task is running. It returns a note telling the
+// agent what happened, or "" if the run stayed in scope.
+func (c *fileScopeChecker) Check(run *TaskExecution, others []*TaskExecution) string {
+	if c == nil || run == nil || run.HeadBefore == "" {
+		return ""
+	}
+	head := gitHead()
+	if head == "" || head == run.HeadBefore {
+		return ""
+	}
+	if len(run.Files) == 0 {
+		fmt.Printf("[%s] ⚠️ '%s' lists no Files to Modify, so --strict-files can't check its changes\n", ts(), run.TaskTitle)
+		return ""
+	}
+	between, err := commitsBetween(run.HeadBefore, head)
+	if err != nil {
+		fmt.Printf("[%s] ⚠️ Could not list the commits of '%s': %v\n", ts(), run.TaskTitle, err)
+		return ""
+	}
+	var unseen []commitInfo
+	for _, commit := range between {
+		if !c.seen[commit.Hash] {
+			unseen = append(unseen, commit)
+		}
+	}
+
+	var own, changed []string
+	listed := make(map[string]bool)
+	for _, hash := range taskCommits(unseen, run.TaskTitle) {
+		files, err := scope.ChangedFiles(".", hash+"^", hash)
+		if err != nil {
+			fmt.Printf("[%s] ⚠️ Could not list the files changed by '%s': %v\n", ts(), run.TaskTitle, err)
+			return ""
+		}
+		if othersOnly(files, run, others) {
+			continue
+		}
+		c.seen[hash] = true
+		own = append(own, hash)
+		for _, f := range files {
+			if !listed[f] {
+				listed[f] = true
+				changed = append(changed, f)
+			}
+		}
+	}
+
+	var outside []string
+	// The control files are updated by every run
+	allowed := append([]string{CursorIterDir + "/"}, c.allowed...)
+	for _, f := range (scope.Scope{Declared: run.Files, Allowed: allowed}).OutOfScope(changed) {
+		if !inScopeOfAny(f, others) {
+			outside = append(outside, f)
+		}
+	}
+	if len(outside) == 0 {
+		return ""
+	}
+	fmt.Printf("[%s] 🚧 '%s' changed files outside its scope: %s\n", ts(), run.TaskTitle, strings.Join(outside, ", "))
+
+	if len(others) == 0 {
+		message := fmt.Sprintf("revert: out-of-scope changes from '%s'", run.TaskTitle)
+		if err := scope.Revert(".", own, outside, message); err != nil {
+			fmt.Printf("[%s] ⚠️ Could not revert the out-of-scope changes: %v\n", ts(), err)
+		} else {
+			c.seen[gitHead()] = true
+			fmt.Printf("[%s] ↩️  Reverted the changes to %d out-of-scope file(s) in %s\n", ts(), len(outside), shortHash(gitHead()))
+			return fmt.Sprintf("The previous run changed files outside this task's Files to Modify list (%s), and those changes were reverted. Only change the listed files.", strings.Join(outside, ", "))
+		}
+	}
+	short := make([]string, len(own))
+	for i, hash := range own {
+		short[i] = shortHash(hash)
+	}
+	return fmt.Sprintf("The previous run changed files outside this task's Files to Modify list: %s. Revert those changes in a new commit, e.g. with `git show <commit> -- <file> | git apply -R` for each of its commits (%s), and only change the listed files.",
+		strings.Join(outside, ", "), strings.Join(short, ", "))
+}
+
+// othersOnly reports whether a commit changing files is the work of another
+// running task: it only touches files in their scope and none in the run's
+func othersOnly(files []string, run *TaskExecution, others []*TaskExecution) bool {
+	if len(others) == 0 {
+		return false
+	}
+	for _, f := range files {
+		if (scope.Scope{Declared: run.Files}).Contains(f) || !inScopeOfAny(f, others) {
+			return false
+		}
+	}
+	return true
+}
+
+func inScopeOfAny(file string, runs []*TaskExecution) bool {
+	for _, r := range runs {
+		if (scope.Scope{Declared: r.Files}).Contains(file) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/prompt"
+)
+
+// promptTemplatesDir holds local templates that replace the built-in prompt
+// templates of the same name
+func promptTemplatesDir() string {
+	return filepath.Join(promptsDir(), "templates")
+}
+
+// loadPromptTemplates reads the prompt templates, falling back to the
+// built-in ones when a local template is broken
+func loadPromptTemplates() *prompt.Templates {
+	t, err := prompt.Load(promptTemplatesDir())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: using the built-in prompt templates: %v\n", ts(), err)
+		return prompt.Builtin()
+	}
+	return t
+}
+
+// mustPromptTemplates checks the local prompt templates before a run, so a
+// broken one stops it rather than being replaced at every dispatch
+func mustPromptTemplates() {
+	t, err := prompt.Load(promptTemplatesDir())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid prompt template: %v\n", err)
+		os.Exit(1)
+	}
+	for _, name := range t.Overridden {
+		fmt.Printf("[%s] 📝 Using the local prompt template %s\n", ts(), filepath.Join(promptTemplatesDir(), name))
+	}
+}
+
+// writePromptTemplates copies the built-in prompt templates into dir for
+// customizing, leaving the ones already there alone. It returns the paths
+// written.
+func writePromptTemplates(dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	var written []string
+	for _, name := range prompt.Names {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		text, err := prompt.Default(name)
+		if err != nil {
+			return written, err
+		}
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			return written, err
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/timeline"
+)
+
+// writeTimeline renders the timeline of a run to path, or stdout when path
+// is empty
+func writeTimeline(path, format string, tl timeline.Timeline) error {
+	var buf bytes.Buffer
+	switch format {
+	case "mermaid":
+		buf.WriteString(tl.Mermaid())
+	case "html":
+		if err := tl.HTML(&buf); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown format %q (want mermaid or html)", format)
+	}
+	if path == "" {
+		_, err := io.Copy(os.Stdout, &buf)
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
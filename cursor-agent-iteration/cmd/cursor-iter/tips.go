@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/compress"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tips"
+)
+
+// tipsOn is the tips setting of the config file, on unless it says false
+var tipsOn = true
+
+// tipCommands are the commands a tip may follow: those run by hand, whose
+// output is read by people rather than scripts
+var tipCommands = map[string]bool{
+	"task-status": true, "iterate": true, "iterate-loop": true, "iterate-init": true,
+	"add-feature": true, "add-task": true, "accept-tasks": true, "archive-completed": true,
+	"triage": true, "validate-tasks": true, "validate-deps": true, "handoff": true, "agents": true,
+	"model-stats": true, "costs": true, "timeline": true, "feed": true, "editor-setup": true, "sync-github": true, "sync-jira": true,
+	"sync-pr-reviews": true, "allowlist-checksum": true, "cluster": true, "edit-task": true, "complete-task": true, "show-task": true,
+}
+
+// tipsPath records when each tip was last shown
+func tipsPath() string {
+	return getControlFilePath("tips.json")
+}
+
+// tipState builds the state the tips rules look at after command
+func tipState(command string, now time.Time) tips.State {
+	tasksMd, _ := os.ReadFile(resolveTasksFile())
+	progressMd, _ := os.ReadFile(resolveProgressFile())
+	entries, _ := journal.Read(journalPath())
+	s := tips.Build(string(tasksMd), string(progressMd), entries, now)
+	s.Command = command
+	if _, err := os.Stat(configPath()); err == nil {
+		s.HasConfig = true
+	}
+	for _, name := range controlFileNames {
+		if data, err := readControlFile(name); err == nil {
+			s.ControlTokens += compress.EstimateTokens(string(data))
+		}
+	}
+	return s
+}
+
+// showTip prints a tip about the repository's state to w after command, at
+// most one, when the command is one of tipCommands, w is a terminal, the
+// repository has been initialized and the config file doesn't turn tips
+// off. The tips shown are recorded so they take turns.
+func showTip(w *os.File, command string) {
+	if !tipsOn || !tipCommands[command] || !isTerminal(w) {
+		return
+	}
+	if info, err := os.Stat(CursorIterDir); err != nil || !info.IsDir() {
+		return
+	}
+	writeTip(w, command, time.Now())
+}
+
+// writeTip picks the tip for the state at now and records it as shown
+func writeTip(w io.Writer, command string, now time.Time) {
+	shown := make(map[string]time.Time)
+	if data, err := os.ReadFile(tipsPath()); err == nil {
+		_ = json.Unmarshal(data, &shown)
+	}
+	rule, tip, ok := tips.Pick(tips.Rules, tipState(command, now), shown)
+	if !ok {
+		return
+	}
+	fmt.Fprintf(w, "[%s] 💡 Tip: %s\n", ts(), tip)
+	shown[rule.ID] = now
+	if data, err := json.MarshalIndent(shown, "", "  "); err == nil {
+		_ = os.WriteFile(tipsPath(), append(data, '\n'), 0644)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/atomicfile"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/todos"
+)
+
+// todoMilestone groups staged tasks from TODO comments
+const todoMilestone = "todos"
+
+// genTodoTasks adds a task per tagged TODO comment to tasks.md, or to the
+// staging file with stage. TODOs that already have a task are left alone.
+// With dryRun nothing is written.
+func genTodoTasks(found []todos.Todo, onComplete string, stage, dryRun bool) ([]string, error) {
+	existing, err := existingTaskTitles()
+	if err != nil {
+		return nil, err
+	}
+	var added, blocks []string
+	for _, t := range found {
+		title := todos.TaskTitle(t)
+		if existing[title] {
+			continue
+		}
+		existing[title] = true
+		added = append(added, title)
+		blocks = append(blocks, todos.TaskBlock(t, onComplete))
+	}
+	if dryRun {
+		return added, nil
+	}
+	return added, addGeneratedTasks(added, blocks, todoMilestone, stage)
+}
+
+// resolveTodo removes or annotates the TODO comment a completed task came
+// from, if the agent left it in place. Tasks not created by scan-todos are
+// ignored.
+func resolveTodo(details string) {
+	source, ok := todos.ParseSource(details)
+	if !ok {
+		return
+	}
+	path := filepath.FromSlash(source.File)
+	info, err := os.Stat(path)
+	if err != nil {
+		fmt.Printf("[%s] ⚠️ Could not resolve TODO in %s: %v\n", ts(), source.File, err)
+		return
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf("[%s] ⚠️ Could not resolve TODO in %s: %v\n", ts(), source.File, err)
+		return
+	}
+	resolved, found := todos.Resolve(string(content), source)
+	if !found {
+		return
+	}
+	// Written whole or not at all, keeping the mode of scripts and the like
+	if err := atomicfile.WriteFile(path, []byte(resolved), info.Mode().Perm()); err != nil {
+		fmt.Printf("[%s] ⚠️ Could not resolve TODO in %s: %v\n", ts(), source.File, err)
+		return
+	}
+	verb := "Annotated"
+	if source.OnComplete == todos.Remove {
+		verb = "Removed"
+	}
+	fmt.Printf("[%s] 🧹 %s the TODO at %s:%d; commit it with your next change\n", ts(), verb, source.File, source.Line)
+}
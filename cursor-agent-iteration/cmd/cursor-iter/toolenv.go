@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/toolenv"
+)
+
+// envVars are the environment variables recorded with every run: the
+// defaults and the comma-separated names in ENV_SNAPSHOT_VARS
+func envVars() []string {
+	vars := append([]string{}, toolenv.DefaultVars...)
+	for _, name := range strings.Split(os.Getenv("ENV_SNAPSHOT_VARS"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			vars = append(vars, name)
+		}
+	}
+	return vars
+}
+
+// captureEnv takes the toolchain snapshot of a run of the task, warning when
+// it differs from the one the task was first run under
+func captureEnv(title string) *toolenv.Env {
+	env := toolenv.Capture(toolenv.DefaultTools, envVars(), os.Getenv)
+	entries, _ := journal.Read(journalPath())
+	if changes := envChanges(entries, title, env); len(changes) > 0 {
+		fmt.Printf("[%s] ⚠️ Retrying '%s' under a different environment than its first run: %s\n", ts(), title, strings.Join(changes, "; "))
+	}
+	return &env
+}
+
+// envChanges compares env with the environment of the first journaled run
+// of the task
+func envChanges(entries []journal.Entry, title string, env toolenv.Env) []string {
+	for _, e := range entries {
+		if e.Task == title && e.Env != nil {
+			return e.Env.Diff(env)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/atomicfile"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// defaultTrashRetention is how long deleted tasks are kept
+const defaultTrashRetention = "30d"
+
+// trashPath is where deleted tasks are kept until they are purged
+func trashPath() string {
+	return getControlFilePath("trash.md")
+}
+
+// trashCutoff turns a retention period into the time before which trashed
+// tasks are purged
+func trashCutoff(retention string, now time.Time) (time.Time, error) {
+	cutoff, err := parseSince(retention, now)
+	if err != nil || retention == "" {
+		return time.Time{}, fmt.Errorf("invalid retention %q, want e.g. 30d or 720h", retention)
+	}
+	return cutoff, nil
+}
+
+// trashTask moves a task from tasks.md to the trash along with its
+// progress.md entries
+func trashTask(tasksPath, progressPath, title, reason string) error {
+	unlock, err := atomicfile.LockFiles(tasksPath, progressPath, trashPath())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	current, err := os.ReadFile(tasksPath)
+	if err != nil {
+		return err
+	}
+	progress, _ := os.ReadFile(progressPath)
+	trash, _ := os.ReadFile(trashPath())
+	newTasks, newProgress, newTrash, err := tasks.TrashTask(string(current), string(progress), string(trash), title, reason, time.Now())
+	if err == nil {
+		err = tasks.CheckStructureKept(string(current), newTasks)
+	}
+	if err != nil {
+		return err
+	}
+	// Write the trash first so a failure can't lose the task
+	if err := atomicfile.WriteFile(trashPath(), []byte(newTrash), 0644); err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(tasksPath, []byte(newTasks), 0644); err != nil {
+		return err
+	}
+	if newProgress != string(progress) {
+		return writeProgress(progressPath, string(progress), newProgress)
+	}
+	return nil
+}
+
+// restoreTask moves a trashed task back into tasks.md
+func restoreTask(tasksPath, progressPath, title string) error {
+	unlock, err := atomicfile.LockFiles(tasksPath, progressPath, trashPath())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	trash, err := os.ReadFile(trashPath())
+	if err != nil {
+		return fmt.Errorf("trash is empty: %v", err)
+	}
+	current, err := os.ReadFile(tasksPath)
+	if err != nil {
+		return err
+	}
+	progress, _ := os.ReadFile(progressPath)
+	newTasks, newProgress, newTrash, err := tasks.RestoreTask(string(current), string(progress), string(trash), title)
+	if err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(tasksPath, []byte(newTasks), 0644); err != nil {
+		return err
+	}
+	if newProgress != string(progress) {
+		if err := writeProgress(progressPath, string(progress), newProgress); err != nil {
+			return err
+		}
+	}
+	return atomicfile.WriteFile(trashPath(), []byte(newTrash), 0644)
+}
+
+// purgeTrash permanently deletes one trashed task, or every task trashed
+// before cutoff
+func purgeTrash(title string, cutoff time.Time) ([]string, error) {
+	trash, err := os.ReadFile(trashPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	newTrash, purged := tasks.PurgeTrash(string(trash), title, cutoff)
+	if len(purged) == 0 {
+		return nil, nil
+	}
+	return purged, atomicfile.WriteFile(trashPath(), []byte(newTrash), 0644)
+}
+
+// printTrash lists the trashed tasks and when they expire
+func printTrash(out io.Writer, retention time.Duration) {
+	data, _ := os.ReadFile(trashPath())
+	items := tasks.ParseTrash(string(data))
+	if len(items) == 0 {
+		fmt.Fprintln(out, "Trash is empty")
+		return
+	}
+	for _, t := range items {
+		fmt.Fprintf(out, "  🗑️  %s (deleted %s, purged after %s)", t.Title, t.TrashedAt.Format("2006-01-02 15:04"), t.TrashedAt.Add(retention).Format("2006-01-02"))
+		if t.Reason != "" {
+			fmt.Fprintf(out, " - %s", t.Reason)
+		}
+		fmt.Fprintln(out)
+	}
+	fmt.Fprintf(out, "💡 Run 'cursor-iter trash restore --task \"<title>\"' to bring a task back\n")
+}
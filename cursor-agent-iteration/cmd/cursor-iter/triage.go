@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// triageOutputLines is how much of a failed run's output triage shows
+const triageOutputLines = 15
+
+// Triage actions, as recorded in the triage log
+const (
+	triageRetry      = "retry"
+	triageRetryModel = "retry-model"
+	triageEdit       = "edit"
+	triageBlock      = "block"
+	triageRollback   = "rollback"
+)
+
+// triageSession walks through failed task runs and applies the chosen actions
+type triageSession struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	tasksFile    string
+	progressFile string
+	editor       string
+	assumeYes    bool
+}
+
+// pendingFailures returns failed runs that have not been triaged and whose
+// task hasn't been completed since
+func pendingFailures(progressFile string) ([]journal.Entry, error) {
+	entries, err := journal.Read(journalPath())
+	if err != nil {
+		return nil, err
+	}
+	decisions, err := journal.ReadDecisions(triageLogPath())
+	if err != nil {
+		return nil, err
+	}
+	progress, _ := os.ReadFile(progressFile)
+
+	var failures []journal.Entry
+	for _, e := range journal.Unresolved(entries, decisions) {
+		if !tasks.IsTaskCompleted(string(progress), e.Task) {
+			failures = append(failures, e)
+		}
+	}
+	return failures, nil
+}
+
+// printFailureList prints a one-line summary per failure
+func printFailureList(out io.Writer, failures []journal.Entry) {
+	if len(failures) == 0 {
+		fmt.Fprintln(out, "✅ No failed tasks need triage")
+		return
+	}
+	fmt.Fprintf(out, "🩺 %d failed task(s) need triage:\n", len(failures))
+	for _, f := range failures {
+		fmt.Fprintf(out, "  - %s [%s, %s] %s/%s run %s\n",
+			f.Task, f.Outcome, f.Classification, f.Backend, f.Model, f.RunID)
+	}
+}
+
+// showFailure prints the details of one failed run
+func (s *triageSession) showFailure(f journal.Entry, n, total int) {
+	fmt.Fprintf(s.out, "\n━━━ [%d/%d] %s\n", n, total, f.Task)
+	fmt.Fprintf(s.out, "  Outcome:  %s (classification: %s)\n", f.Outcome, f.Classification)
+	fmt.Fprintf(s.out, "  Agent:    %s (model: %s)\n", f.Backend, f.Model)
+	fmt.Fprintf(s.out, "  Run:      %s at %s, took %v\n", f.RunID, f.Time.Format("2006-01-02 15:04"), f.Duration().Round(1e9))
+	if f.Env != nil {
+		fmt.Fprintf(s.out, "  Env:      %s\n", f.Env)
+	}
+	if f.Error != "" {
+		fmt.Fprintf(s.out, "  Error:    %s\n", f.Error)
+	}
+	if f.Blocker != "" {
+		fmt.Fprintf(s.out, "  Needs:    %s\n", f.Blocker)
+	}
+	if f.LogPath == "" {
+		return
+	}
+	data, err := os.ReadFile(f.LogPath)
+	if err != nil {
+		fmt.Fprintf(s.out, "  Output:   %s (unreadable: %v)\n", f.LogPath, err)
+		return
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) > triageOutputLines {
+		lines = lines[len(lines)-triageOutputLines:]
+	}
+	fmt.Fprintf(s.out, "  Output (last %d lines of %s):\n", len(lines), f.LogPath)
+	for _, line := range lines {
+		fmt.Fprintf(s.out, "    │ %s\n", line)
+	}
+}
+
+// prompt asks a question and returns the trimmed answer; io.EOF ends the session
+func (s *triageSession) prompt(question string) (string, error) {
+	fmt.Fprint(s.out, question)
+	line, err := s.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// run triages each failure interactively
+func (s *triageSession) run(failures []journal.Entry) error {
+	for i, f := range failures {
+		s.showFailure(f, i+1, len(failures))
+		for {
+			choice, err := s.prompt("Action: [r]etry, retry with [m]odel, [e]dit task, [b]lock, roll[x]back, [s]kip, [q]uit > ")
+			if err != nil {
+				return nil
+			}
+			action := map[string]string{
+				"r": triageRetry, "m": triageRetryModel, "e": triageEdit,
+				"b": triageBlock, "x": triageRollback,
+			}[strings.ToLower(choice)]
+			switch strings.ToLower(choice) {
+			case "s", "":
+			case "q":
+				return nil
+			default:
+				if action == "" {
+					fmt.Fprintf(s.out, "Unknown action %q\n", choice)
+					continue
+				}
+				if err := s.apply(f, action, ""); err != nil {
+					fmt.Fprintf(s.out, "❌ %v\n", err)
+					continue
+				}
+			}
+			break
+		}
+	}
+	fmt.Fprintln(s.out, "\n✅ Triage finished")
+	return nil
+}
+
+// apply performs an action for a failed run and records the decision.
+// detail is the model for retry-model and the reason for block; it is asked
+// for interactively when empty.
+func (s *triageSession) apply(f journal.Entry, action string, detail string) error {
+	var err error
+	switch action {
+	case triageRetry:
+		err = s.retry(f.Task)
+	case triageRetryModel:
+		if detail == "" {
+			if detail, err = s.prompt(fmt.Sprintf("Model to retry with (was %s): ", f.Model)); err != nil || detail == "" {
+				return fmt.Errorf("a model is required")
+			}
+		}
+		err = s.setModel(f.Task, detail)
+		if err == nil {
+			err = s.retry(f.Task)
+		}
+	case triageEdit:
+		err = s.edit()
+	case triageBlock:
+		if detail == "" {
+			detail, _ = s.prompt("Reason (optional): ")
+		}
+		err = s.block(f.Task, detail)
+	case triageRollback:
+		detail, err = s.rollback(f)
+	default:
+		return fmt.Errorf("unknown triage action %q", action)
+	}
+	if err != nil {
+		return err
+	}
+	return journal.AppendDecision(triageLogPath(), journal.Decision{
+		Time:   time.Now(),
+		Task:   f.Task,
+		Action: action,
+		Detail: detail,
+		By:     envOr("USER", "unknown"),
+	})
+}
+
+// retry unblocks the task and makes sure it is in progress so the next
+// iterate run resumes it
+func (s *triageSession) retry(task string) error {
+	err := updateControlFile(s.progressFile, func(progress string) string {
+		updated := tasks.UnblockTask(progress, task)
+		if !tasks.IsTaskInProgress(updated, task) {
+			updated = tasks.MarkTaskInProgress(updated, task)
+		}
+		return updated
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %v", s.progressFile, err)
+	}
+	fmt.Fprintf(s.out, "🔁 '%s' will be retried on the next iterate run\n", task)
+	return nil
+}
+
+// setModel pins the task to a model with a [model:<name>] label
+func (s *triageSession) setModel(task, model string) error {
+	if _, err := os.Stat(s.tasksFile); err != nil {
+		return fmt.Errorf("failed to read %s: %v", s.tasksFile, err)
+	}
+	found := false
+	err := updateControlFile(s.tasksFile, func(content string) string {
+		updated := tasks.SetTaskLabel(content, task, "model", model)
+		found = updated != content
+		return updated
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %v", s.tasksFile, err)
+	}
+	if !found {
+		return fmt.Errorf("task '%s' not found in %s", task, s.tasksFile)
+	}
+	fmt.Fprintf(s.out, "🏷️  '%s' now runs with model %s\n", task, model)
+	return nil
+}
+
+// edit opens tasks.md in the user's editor
+func (s *triageSession) edit() error {
+	cmd := exec.Command("sh", "-c", s.editor+` "$1"`, "sh", s.tasksFile)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor failed: %v", err)
+	}
+	return nil
+}
+
+// block moves the task to the Blocked section of progress.md
+func (s *triageSession) block(task, reason string) error {
+	if err := updateControlFile(s.progressFile, func(progress string) string {
+		return tasks.MarkTaskBlocked(progress, task, reason)
+	}); err != nil {
+		return fmt.Errorf("failed to update %s: %v", s.progressFile, err)
+	}
+	fmt.Fprintf(s.out, "⛔ '%s' is blocked until it is retried\n", task)
+	return nil
+}
+
+// rollback reverts the commits made while the failed run was active. The
+// range can include commits from tasks that ran in parallel, so it is shown
+// and confirmed first.
+func (s *triageSession) rollback(f journal.Entry) (string, error) {
+	if f.HeadBefore == "" || f.HeadAfter == "" || f.HeadBefore == f.HeadAfter {
+		return "", fmt.Errorf("no commits were recorded for this run")
+	}
+	commitRange := f.HeadBefore + ".." + f.HeadAfter
+	log, err := exec.Command("git", "log", "--oneline", commitRange).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to list commits in %s: %v", commitRange, err)
+	}
+	fmt.Fprintf(s.out, "Commits made during this run (may include parallel tasks):\n%s", log)
+	if !s.assumeYes {
+		answer, _ := s.prompt("Revert these commits? [y/N] ")
+		if !strings.EqualFold(answer, "y") && !strings.EqualFold(answer, "yes") {
+			return "", fmt.Errorf("rollback cancelled")
+		}
+	}
+	cmd := exec.Command("git", "revert", "--no-edit", commitRange)
+	cmd.Stdout, cmd.Stderr = s.out, s.out
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git revert failed: %v (resolve and run 'git revert --continue' or '--abort')", err)
+	}
+	fmt.Fprintf(s.out, "⏪ Reverted %s\n", commitRange)
+	return commitRange, nil
+}
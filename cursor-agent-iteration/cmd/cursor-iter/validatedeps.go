@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/atomicfile"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// graphFormat is the format of a dependency graph file: format when given,
+// otherwise mermaid for .mmd and .mermaid files and dot for the rest
+func graphFormat(path, format string) (string, error) {
+	switch format {
+	case "dot", "mermaid":
+		return format, nil
+	case "":
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".mmd", ".mermaid":
+			return "mermaid", nil
+		}
+		return "dot", nil
+	}
+	return "", fmt.Errorf("unknown graph format %q (want dot or mermaid)", format)
+}
+
+// validateDeps checks the dependencies of tasksFile, resolving them against
+// the archives in archiveDir too, and prints what it finds. With fix the
+// stale names of renamed tasks are rewritten first. The graph is written to
+// graphPath unless it is empty. It reports whether every dependency
+// resolves and every task can start.
+func validateDeps(tasksFile, progressFile, archiveDir, graphPath, format string, fix bool) (bool, error) {
+	if graphPath != "" {
+		var err error
+		if format, err = graphFormat(graphPath, format); err != nil {
+			return false, err
+		}
+	}
+	tasksMd, err := os.ReadFile(tasksFile)
+	if err != nil {
+		return false, err
+	}
+	progressMd, err := os.ReadFile(progressFile)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	completed, err := archivedTasks(archiveDir, nil)
+	if err != nil {
+		return false, err
+	}
+	archived := make([]string, 0, len(completed))
+	for title := range completed {
+		archived = append(archived, title)
+	}
+	sort.Strings(archived)
+
+	r := tasks.CheckDependencies(string(tasksMd), string(progressMd), archived)
+	if fix {
+		fixedMd, fixed := tasks.FixDependencies(string(tasksMd), r)
+		if len(fixed) > 0 {
+			if err := atomicfile.WriteFile(tasksFile, []byte(fixedMd), 0644); err != nil {
+				return false, err
+			}
+			for _, ref := range fixed {
+				fmt.Printf("[%s] 🔧 '%s' now depends on '%s' instead of '%s'\n", ts(), ref.Task, ref.Suggestion, ref.Dependency)
+			}
+			r = tasks.CheckDependencies(fixedMd, string(progressMd), archived)
+		}
+	}
+
+	for _, ref := range r.Refs {
+		switch {
+		case ref.Archived:
+			fmt.Printf("[%s] 🗄️ '%s' depends on '%s', which is archived\n", ts(), ref.Task, ref.Resolved)
+		case ref.Resolved == "" && ref.Suggestion != "":
+			fmt.Printf("[%s] ❌ '%s' depends on '%s', which is no task; was it renamed to '%s'? --fix rewrites it\n", ts(), ref.Task, ref.Dependency, ref.Suggestion)
+		case ref.Resolved == "":
+			fmt.Printf("[%s] ❌ '%s' depends on '%s', which is no task or archived task; put task names in backticks to keep other references out\n", ts(), ref.Task, ref.Dependency)
+		}
+	}
+	for _, cycle := range r.Cycles {
+		fmt.Printf("[%s] ❌ Dependency cycle: %s; these tasks can never start\n", ts(), tasks.FormatCycle(cycle))
+	}
+	for _, title := range r.Unreachable {
+		fmt.Printf("[%s] ❌ '%s' can never start: a prerequisite, or one of its own, is blocked, failed or in a cycle\n", ts(), title)
+	}
+	if r.OK() {
+		fmt.Printf("[%s] ✅ All %d dependencies of %d tasks resolve, with no cycles\n", ts(), len(r.Refs), len(r.Tasks))
+	}
+
+	if graphPath != "" {
+		graph := r.DOT()
+		if format == "mermaid" {
+			graph = r.Mermaid()
+		}
+		if err := os.WriteFile(graphPath, []byte(graph), 0644); err != nil {
+			return false, err
+		}
+		fmt.Printf("[%s] 🕸️ Wrote the dependency graph to %s\n", ts(), graphPath)
+	}
+	return r.OK(), nil
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/scope"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/verify"
+)
+
+// gatesSection is the section of the config file naming the quality gates,
+// e.g. "lint: golangci-lint run"
+const gatesSection = "gates"
+
+// maxVerifyFailures is how many failed checks a task gets before it is
+// blocked for a human instead of going back to its agent again
+const maxVerifyFailures = 3
+
+// verifyTailLines is how much of a failed check's output agents are shown;
+// the rest is in its log
+const verifyTailLines = 30
+
+// verifier runs the quality gates after every completed task, reopening it
+// when one fails, and the full suite after every batch of completed tasks,
+// reopening the task its failure is blamed on. A nil *verifier verifies
+// nothing.
+type verifier struct {
+	ctx     context.Context
+	gates   []verify.Gate
+	full    string
+	batch   int
+	timeout time.Duration
+	// pending are the tasks completed since the full suite last ran
+	pending []verify.Change
+	// failures counts failed checks per task
+	failures map[string]int
+}
+
+// qualityGates returns the gates of the config file's gates section, then
+// the --verify-fast command as a gate named "fast". It exits when the
+// section isn't made of "name: command" settings.
+func qualityGates(fast string) []verify.Gate {
+	if v, ok := repoConfig.Values[gatesSection]; ok {
+		fmt.Fprintf(os.Stderr, "invalid %s in %s: want \"name: command\" settings such as \"lint: make lint\", got %q\n", gatesSection, configPath(), v)
+		os.Exit(1)
+	}
+	gates := verify.Gates(repoConfig.Sections[gatesSection])
+	if fast = strings.TrimSpace(fast); fast != "" {
+		gates = append(gates, verify.Gate{Name: verify.Fast, Command: fast})
+	}
+	return gates
+}
+
+// newVerifier returns the verifier for the quality gates and the
+// --verify-full command, or nil when there are neither. The full suite runs
+// once batch tasks have completed.
+func newVerifier(gates []verify.Gate, full string, batch int, timeout time.Duration) *verifier {
+	full = strings.TrimSpace(full)
+	if len(gates) == 0 && full == "" {
+		return nil
+	}
+	return &verifier{ctx: context.Background(), gates: gates, full: full, batch: max(batch, 1), timeout: timeout, failures: make(map[string]int)}
+}
+
+// SetContext makes running checks stop when ctx is done
+func (v *verifier) SetContext(ctx context.Context) {
+	if v != nil {
+		v.ctx = ctx
+	}
+}
+
+// GateNames lists the quality gates in the order they run
+func (v *verifier) GateNames() string {
+	if v == nil {
+		return ""
+	}
+	names := make([]string, len(v.gates))
+	for i, g := range v.gates {
+		names[i] = g.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// PromptNote tells agents which checks their work must pass
+func (v *verifier) PromptNote() string {
+	if v == nil {
+		return ""
+	}
+	var checks []string
+	if len(v.gates) > 0 {
+		gates := make([]string, len(v.gates))
+		for i, g := range v.gates {
+			gates[i] = fmt.Sprintf("%s `%s`", g.Name, g.Command)
+		}
+		checks = append(checks, "the quality gates "+strings.Join(gates, ", ")+" run as soon as you mark the task completed, and it stays in progress until they all pass")
+	}
+	if v.full != "" {
+		checks = append(checks, fmt.Sprintf("`%s` runs after a few tasks have completed, and a failure it is blamed for reopens the task", v.full))
+	}
+	return "Checks: " + strings.Join(checks, "; ") + ". Run them yourself before marking the task completed"
+}
+
+// Fast runs the quality gates for a task that just completed and reports
+// whether they all passed. When one didn't, the task is reopened with the
+// failure saved for its next attempt or, once it has failed too often,
+// blocked.
+func (v *verifier) Fast(run *TaskExecution, progressFile string) bool {
+	if v == nil || len(v.gates) == 0 || run == nil {
+		return true
+	}
+	fmt.Printf("[%s] 🚦 Running quality gates for '%s': %s\n", ts(), run.TaskTitle, v.GateNames())
+	start := time.Now()
+	r, passed := verify.RunGates(v.ctx, v.gates, v.timeout)
+	if passed {
+		fmt.Printf("[%s] ✅ Quality gates passed in %v: %s\n", ts(), time.Since(start).Round(time.Millisecond), run.TaskTitle)
+		os.Remove(checksFollowUpPath(run.TaskTitle))
+		return true
+	}
+	v.fail(r, run.TaskTitle, progressFile, "after this task was marked completed")
+	return false
+}
+
+// Queue adds a task that passed its quality gates to the next full suite
+// run, with the files its run changed for blaming failures
+func (v *verifier) Queue(run *TaskExecution) {
+	if v == nil || v.full == "" || run == nil {
+		return
+	}
+	change := verify.Change{Task: run.TaskTitle}
+	if head := gitHead(); run.HeadBefore != "" && head != "" && head != run.HeadBefore {
+		change.Files, _ = scope.ChangedFiles(".", run.HeadBefore, head)
+	}
+	v.pending = append(v.pending, change)
+}
+
+// Pending is how many completed tasks the full suite hasn't checked yet
+func (v *verifier) Pending() int {
+	if v == nil {
+		return 0
+	}
+	return len(v.pending)
+}
+
+// Due reports whether a batch of tasks is waiting for the full suite
+func (v *verifier) Due() bool {
+	return v != nil && len(v.pending) >= v.batch
+}
+
+// Full runs the full suite over the tasks completed since it last ran. When
+// it fails, the task most likely to blame is reopened, or blocked, and
+// returned; the others stay completed and are checked again with the next
+// run.
+func (v *verifier) Full(progressFile string) string {
+	if v.Pending() == 0 {
+		return ""
+	}
+	batch := v.pending
+	v.pending = nil
+	titles := make([]string, len(batch))
+	for i, c := range batch {
+		titles[i] = c.Task
+	}
+	fmt.Printf("[%s] 🧪 Running the full suite for %d completed task(s): %s\n", ts(), len(batch), v.full)
+	r := verify.Run(v.ctx, verify.Full, v.full, v.timeout)
+	if r.Passed {
+		fmt.Printf("[%s] ✅ Full suite passed in %v: %s\n", ts(), r.Duration.Round(time.Second), strings.Join(titles, ", "))
+		for _, title := range titles {
+			os.Remove(checksFollowUpPath(title))
+		}
+		return ""
+	}
+	title := verify.Blame(r.Output, batch)
+	if len(batch) > 1 {
+		fmt.Printf("[%s] 🔍 Blaming '%s' for the failure, of: %s\n", ts(), title, strings.Join(titles, ", "))
+	}
+	for _, c := range batch {
+		if c.Task != title {
+			v.pending = append(v.pending, c)
+		}
+	}
+	v.fail(r, title, progressFile, fmt.Sprintf("after %d task(s) completed, and the changes of this task are the most likely cause", len(batch)))
+	return title
+}
+
+// CheckBatch runs the full suite when a batch is due, or with force when any
+// task is waiting for it. It reports whether a task was reopened or blocked.
+func (v *verifier) CheckBatch(progressFile string, force bool) bool {
+	if !v.Due() && (!force || v.Pending() == 0) {
+		return false
+	}
+	return v.Full(progressFile) != ""
+}
+
+// fail records a failed check, saves its output and reopens the task with
+// the failure saved for its next attempt, or blocks it once it has failed
+// too often
+func (v *verifier) fail(r verify.Result, title, progressFile, when string) {
+	v.failures[title]++
+	check, name := "The full suite", r.Tier
+	if r.Gate != "" {
+		check, name = fmt.Sprintf("The %s gate", r.Gate), r.Gate
+	}
+	path := verifyLogPath(name, time.Now())
+	if f, err := createLog(path); err == nil {
+		fmt.Fprintf(f, "$ %s\n%s", r.Command, r.Output)
+		f.Close()
+	} else {
+		fmt.Printf("[%s] ⚠️ Could not write %s: %v\n", ts(), path, err)
+	}
+	outcome := "failed"
+	if r.TimedOut {
+		outcome = fmt.Sprintf("timed out after %v", v.timeout)
+	}
+	fmt.Printf("[%s] ❌ %s %s (output in %s): %s\n", ts(), check, outcome, path, title)
+
+	if v.failures[title] >= maxVerifyFailures {
+		reason := fmt.Sprintf("checks failed %d times, see %s", v.failures[title], path)
+		if err := updateControlFile(progressFile, func(progress string) string {
+			return tasks.MarkTaskBlocked(tasks.ReopenTask(progress, title), title, reason)
+		}); err != nil {
+			fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not block '%s': %v\n", ts(), title, err)
+			return
+		}
+		fmt.Printf("[%s] ⛔ Checks keep failing, moved to Blocked: %s\n", ts(), title)
+		logTaskBlocked(title, reason)
+		fmt.Printf("[%s] 💡 Fix the checks, then retry it with 'cursor-iter triage'\n", ts())
+		return
+	}
+	if err := updateControlFile(progressFile, func(progress string) string {
+		return tasks.ReopenTask(progress, title)
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not reopen '%s': %v\n", ts(), title, err)
+		return
+	}
+	note := fmt.Sprintf("%s (`%s`) %s %s. Fix it, run the command again and then mark the task completed again. The end of its output (all of it is in %s):\n\n%s",
+		check, r.Command, outcome, when, path, verify.Tail(r.Output, verifyTailLines))
+	if err := writeChecksFollowUp(title, note); err != nil {
+		fmt.Printf("[%s] ⚠️ Could not save the failure for the next attempt: %v\n", ts(), err)
+	}
+	fmt.Printf("[%s] 🔁 Sending '%s' back to the agent\n", ts(), title)
+	logTaskRetry(title, "checks")
+}
+
+// verifyLogPath is the log of a failed check, a gate or the full suite
+func verifyLogPath(check string, at time.Time) string {
+	return getControlFilePath(filepath.Join("logs", "verify-"+taskSlug(check)+"-"+at.Format("20060102-150405")+".log"))
+}
+
+// checksFollowUpPath holds the failure a reopened task's next attempt must
+// fix
+func checksFollowUpPath(title string) string {
+	return getControlFilePath(filepath.Join("checks", taskSlug(title)+".md"))
+}
+
+func writeChecksFollowUp(title, note string) error {
+	path := checksFollowUpPath(title)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(note), 0644)
+}
+
+// checksFollowUp is the failed check a task was reopened for, until its
+// checks pass, and is empty otherwise
+func checksFollowUp(title string) string {
+	note, err := os.ReadFile(checksFollowUpPath(title))
+	if err != nil {
+		return ""
+	}
+	return string(note)
+}
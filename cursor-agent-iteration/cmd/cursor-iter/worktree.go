@@ -0,0 +1,227 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/worktree"
+)
+
+// Modes of --worktree
+const (
+	worktreeOff   = "off"   // agents share the working tree
+	worktreeMerge = "merge" // merge a task's branch once it completes
+	worktreePR    = "pr"    // push it and open a pull request instead
+)
+
+// maxMergeConflicts is how many times a task's branch may conflict before
+// the task is blocked instead of going back to its agent again
+const maxMergeConflicts = 3
+
+// worktrees runs every task in a git worktree of its own, on a branch of
+// its own, and once the task completes merges the branch into the checked
+// out one or opens a pull request for it. A nil *worktrees runs agents in
+// the repository itself.
+type worktrees struct {
+	mode string
+	// repo is the absolute path of the main checkout
+	repo string
+	// base is the branch task branches start from and are merged into
+	base   string
+	remote string
+	// conflicts counts the conflicting merges per task
+	conflicts map[string]int
+	// mu serializes the git commands that change worktrees and branches
+	mu sync.Mutex
+}
+
+// mustWorktrees returns the worktrees of the --worktree mode, or nil when it
+// is off. It exits on invalid modes, outside a git repository with a branch
+// checked out, and for pull requests without the gh CLI.
+func mustWorktrees(mode, remote string) *worktrees {
+	switch mode {
+	case worktreeOff, "":
+		return nil
+	case worktreeMerge, worktreePR:
+	default:
+		fmt.Fprintf(os.Stderr, "invalid --worktree %q: must be %s, %s or %s\n", mode, worktreeOff, worktreeMerge, worktreePR)
+		os.Exit(1)
+	}
+	repo, err := filepath.Abs(".")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	base, err := worktree.CurrentBranch(repo)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "--worktree needs a git repository with a branch checked out: %v\n", err)
+		os.Exit(1)
+	}
+	if mode == worktreePR {
+		if _, err := exec.LookPath("gh"); err != nil {
+			fmt.Fprintf(os.Stderr, "--worktree %s opens pull requests with the GitHub CLI, which is not installed: %v\n", worktreePR, err)
+			os.Exit(1)
+		}
+	}
+	return &worktrees{mode: mode, repo: repo, base: base, remote: remote, conflicts: make(map[string]int)}
+}
+
+// String says where the work of completed tasks goes
+func (w *worktrees) String() string {
+	if w.mode == worktreePR {
+		return fmt.Sprintf("completed branches are pushed to %s and proposed for %s in pull requests", w.remote, w.base)
+	}
+	return fmt.Sprintf("completed branches are merged into %s", w.base)
+}
+
+// Tree is where a task's agent works: its worktree under .cursor-iter and
+// its branch. Both only depend on the title, so every run of a task gets
+// the same ones.
+func (w *worktrees) Tree(title string) worktree.Tree {
+	if w == nil {
+		return worktree.Tree{}
+	}
+	return worktree.Tree{
+		Path:   filepath.Join(w.repo, worktreesDir(), taskSlug(title)),
+		Branch: worktree.BranchPrefix + taskSlug(title),
+	}
+}
+
+// worktreesDir holds the worktrees of the tasks
+func worktreesDir() string {
+	return getControlFilePath("worktrees")
+}
+
+// Open creates a task's worktree, or returns the one its earlier runs left
+func (w *worktrees) Open(title string) (worktree.Tree, error) {
+	t := w.Tree(title)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// Keep the checkouts out of the repository's own git status
+	ignore := filepath.Join(w.repo, worktreesDir(), ".gitignore")
+	if _, err := os.Stat(ignore); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(ignore), 0755); err != nil {
+			return t, err
+		}
+		if err := os.WriteFile(ignore, []byte("*\n"), 0644); err != nil {
+			return t, err
+		}
+	}
+	return worktree.Open(w.repo, t.Path, t.Branch)
+}
+
+// PromptNote tells a task's agent where to work and where the control files
+// it updates are, since they aren't part of its worktree
+func (w *worktrees) PromptNote(title, tasksFile, progressFile string) string {
+	if w == nil {
+		return ""
+	}
+	t := w.Tree(title)
+	abs := func(path string) string {
+		if filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(w.repo, path)
+	}
+	return fmt.Sprintf("Worktree: you work in %s, a git worktree on branch %s, apart from the agents of other tasks. Make and commit all your changes there; %s once their task is completed. "+
+		"The control files are in the main checkout, not in this worktree: update %s and %s, and read paths under %s/ relative to %s.",
+		t.Path, t.Branch, w, abs(tasksFile), abs(progressFile), CursorIterDir, w.repo)
+}
+
+// Land brings the work of a completed task back: it commits what the agent
+// left uncommitted, merges the branch or opens a pull request for it, and
+// removes the worktree. When the merge conflicts the task is reopened, with
+// its worktree kept, and the note for its next attempt returned; once it
+// has conflicted too often, or when the work can't be brought back at all,
+// the task is blocked. ok reports whether the work was brought back.
+func (w *worktrees) Land(run *TaskExecution, tasksFile, progressFile string) (note string, ok bool) {
+	if w == nil || run == nil || run.Worktree.Path == "" {
+		return "", true
+	}
+	t := run.Worktree
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := worktree.Commit(t, run.TaskTitle); err != nil {
+		w.block(progressFile, run.TaskTitle, fmt.Sprintf("could not commit the changes left in %s: %v", t.Path, err))
+		return "", false
+	}
+	ahead, err := worktree.Ahead(w.repo, t)
+	if err != nil {
+		w.block(progressFile, run.TaskTitle, fmt.Sprintf("could not compare %s with %s: %v", t.Branch, w.base, err))
+		return "", false
+	}
+	if ahead == 0 {
+		fmt.Printf("[%s] 🌿 No changes on %s to bring back: %s\n", ts(), t.Branch, run.TaskTitle)
+		w.remove(t, true)
+		return "", true
+	}
+
+	if w.mode == worktreePR {
+		body, err := describePR(tasksFile, progressFile, run.TaskTitle)
+		if err != nil {
+			body = run.TaskTitle
+		}
+		url, err := worktree.PullRequest(t.Path, w.remote, t.Branch, w.base, run.TaskTitle, body)
+		if err != nil {
+			w.block(progressFile, run.TaskTitle, fmt.Sprintf("%v; the work is on %s", err, t.Branch))
+			return "", false
+		}
+		fmt.Printf("[%s] 📬 Opened %s for '%s'\n", ts(), url, run.TaskTitle)
+		recordPR(progressFile, run.TaskTitle, url)
+		w.remove(t, false)
+		return "", true
+	}
+
+	err = worktree.Merge(w.repo, t, "Merge task: "+run.TaskTitle)
+	var conflictErr *worktree.ConflictError
+	if errors.As(err, &conflictErr) {
+		w.conflicts[run.TaskTitle]++
+		if w.conflicts[run.TaskTitle] >= maxMergeConflicts {
+			w.block(progressFile, run.TaskTitle, fmt.Sprintf("%v %d times; the work is on %s", err, w.conflicts[run.TaskTitle], t.Branch))
+			return "", false
+		}
+		fmt.Printf("[%s] 🔀 %v; sending '%s' back to its agent\n", ts(), err, run.TaskTitle)
+		logTaskRetry(run.TaskTitle, "merge conflict")
+		if err := updateControlFile(progressFile, func(progress string) string {
+			return tasks.ReopenTask(progress, run.TaskTitle)
+		}); err != nil {
+			fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not reopen '%s': %v\n", ts(), run.TaskTitle, err)
+		}
+		return fmt.Sprintf("Merging branch %s into %s conflicted in %s, so the task was reopened. Your earlier work is still in this worktree: merge %s into %s, resolve the conflicts, commit and mark the task completed again.",
+			t.Branch, w.base, strings.Join(conflictErr.Files, ", "), w.base, t.Branch), false
+	}
+	if err != nil {
+		w.block(progressFile, run.TaskTitle, fmt.Sprintf("%v; the work is on %s", err, t.Branch))
+		return "", false
+	}
+	fmt.Printf("[%s] 🔀 Merged %s (%d commit(s)) into %s: %s\n", ts(), t.Branch, ahead, w.base, run.TaskTitle)
+	delete(w.conflicts, run.TaskTitle)
+	w.remove(t, true)
+	return "", true
+}
+
+// block moves a completed task whose work couldn't be brought back to
+// Blocked, for a human to bring it back
+func (w *worktrees) block(progressFile, title, reason string) {
+	if err := updateControlFile(progressFile, func(progress string) string {
+		return tasks.MarkTaskBlocked(progress, title, reason)
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] ⚠️ Warning: could not block '%s': %v\n", ts(), title, err)
+		return
+	}
+	fmt.Printf("[%s] ⛔ Could not bring the work back, moved to Blocked: %s - %s\n", ts(), title, reason)
+	logTaskBlocked(title, reason)
+	fmt.Printf("[%s] 💡 Merge or push the branch yourself, then complete or retry it with 'cursor-iter triage'\n", ts())
+}
+
+func (w *worktrees) remove(t worktree.Tree, deleteBranch bool) {
+	if err := worktree.Remove(w.repo, t, deleteBranch); err != nil {
+		fmt.Printf("[%s] ⚠️ %v\n", ts(), err)
+	}
+}
@@ -0,0 +1,177 @@
+// Package atomicfile writes the control files so concurrent writers don't
+// lose each other's updates and readers never see half a file. A write
+// replaces the file in one step, by writing a temporary file next to it and
+// renaming it over the original, and Update holds a lock from reading the
+// file to replacing it. The lock is an flock on a ".lock" file beside the
+// file, so it serializes the goroutines of one process as well as separate
+// processes, such as iterate-loop and add-feature, while the file itself
+// can be replaced.
+package atomicfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// LockSuffix names the lock file of a file, e.g. "progress.md.lock"
+const LockSuffix = ".lock"
+
+// locks serializes the goroutines of this process per file, also where
+// flock is not available
+var locks sync.Map // path -> *sync.Mutex
+
+// LockFile takes the exclusive lock of path, waiting for other holders, and
+// returns the function releasing it. path need not exist.
+func LockFile(path string) (unlock func(), err error) {
+	path = resolve(path)
+	mu, _ := locks.LoadOrStore(path, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	f, err := os.OpenFile(path+LockSuffix, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		mu.(*sync.Mutex).Unlock()
+		return nil, fmt.Errorf("could not lock %s: %v", path, err)
+	}
+	if err := flock(f); err != nil {
+		f.Close()
+		mu.(*sync.Mutex).Unlock()
+		return nil, fmt.Errorf("could not lock %s: %v", path, err)
+	}
+	return func() {
+		funlock(f)
+		f.Close()
+		mu.(*sync.Mutex).Unlock()
+	}, nil
+}
+
+// LockFiles takes the locks of several files, always in the same order so
+// two callers locking the same files can't deadlock, and returns the
+// function releasing them all
+func LockFiles(paths ...string) (unlock func(), err error) {
+	resolved := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if p = resolve(p); !slices.Contains(resolved, p) {
+			resolved = append(resolved, p)
+		}
+	}
+	sort.Strings(resolved)
+	var unlocks []func()
+	unlock = func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+	for _, p := range resolved {
+		u, err := LockFile(p)
+		if err != nil {
+			unlock()
+			return nil, err
+		}
+		unlocks = append(unlocks, u)
+	}
+	return unlock, nil
+}
+
+// WriteFile replaces path with data in one step. The file keeps its mode
+// when it exists, and gets perm otherwise.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	path = resolve(path)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// updateAttempts bounds how often Update applies its function while the
+// file keeps changing under it
+const updateAttempts = 3
+
+// ErrConflict is returned by Update when the file still changed under the
+// last of its attempts
+var ErrConflict = errors.New("file kept changing during the update")
+
+// Update replaces the content of path with what fn returns for its current
+// content, holding its lock throughout. A missing file reads as empty. When
+// fn returns an error, or its content unchanged, the file isn't written.
+//
+// Writers that don't take the lock, such as agents, can still replace the
+// file while fn runs. Update reads it again before writing, and applies fn
+// to the new content when it changed rather than overwriting it. When the
+// file changed during every attempt, it is left as the other writer made it
+// and Update returns an error wrapping ErrConflict.
+func Update(path string, fn func(content []byte) ([]byte, error)) error {
+	unlock, err := LockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	content, err := readFile(path)
+	if err != nil {
+		return err
+	}
+	for attempt := 1; ; attempt++ {
+		updated, err := fn(content)
+		if err != nil {
+			return err
+		}
+		current, err := readFile(path)
+		if err != nil {
+			return err
+		}
+		if string(current) != string(content) {
+			if attempt == updateAttempts {
+				return fmt.Errorf("%w: %s", ErrConflict, path)
+			}
+			content = current
+			continue
+		}
+		if string(updated) == string(current) {
+			return nil
+		}
+		return WriteFile(path, updated, 0644)
+	}
+}
+
+// readFile reads path, as empty when it is missing
+func readFile(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return content, nil
+}
+
+// resolve follows symlinks, so a linked control file is replaced and not
+// the link, and both names share one lock
+func resolve(path string) string {
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		return real
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
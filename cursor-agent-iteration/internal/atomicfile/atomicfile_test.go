@@ -0,0 +1,159 @@
+package atomicfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUpdateLosesNoWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "progress.md")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := Update(path, func(content []byte) ([]byte, error) {
+				return append(content, fmt.Sprintf("- entry %d\n", i)...), nil
+			})
+			if err != nil {
+				t.Errorf("Update() = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	content, _ := os.ReadFile(path)
+	if n := strings.Count(string(content), "- entry"); n != 20 {
+		t.Errorf("Expected 20 entries, got %d:\n%s", n, content)
+	}
+	if tmps, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "*.tmp")); len(tmps) > 0 {
+		t.Errorf("Expected no temporary files left, got %v", tmps)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tasks.md")
+	os.WriteFile(path, []byte("old"), 0600)
+	before, _ := os.Stat(path)
+
+	// Unchanged content and errors leave the file alone
+	Update(path, func(c []byte) ([]byte, error) { return c, nil })
+	boom := errors.New("boom")
+	if err := Update(path, func(c []byte) ([]byte, error) { return []byte("new"), boom }); err != boom {
+		t.Errorf("Expected fn's error, got %v", err)
+	}
+	if after, _ := os.Stat(path); !os.SameFile(before, after) {
+		t.Error("Expected the file not to be replaced")
+	}
+
+	if err := Update(path, func(c []byte) ([]byte, error) { return append(c, " new"...), nil }); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := os.Stat(path)
+	if content, _ := os.ReadFile(path); string(content) != "old new" || after.Mode().Perm() != 0600 {
+		t.Errorf("Expected 'old new' with mode 0600, got %q with %v", content, after.Mode().Perm())
+	}
+
+	// A linked file is replaced, not the link
+	link := filepath.Join(dir, "link.md")
+	os.Symlink(path, link)
+	if err := WriteFile(link, []byte("via link"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Lstat(link); info.Mode()&os.ModeSymlink == 0 {
+		t.Error("Expected the symlink to stay")
+	}
+	if content, _ := os.ReadFile(path); string(content) != "via link" {
+		t.Errorf("Expected the target written, got %q", content)
+	}
+}
+
+// TestUpdateUnlockedWriter tests that a write by a writer without the lock
+// while the update runs isn't overwritten
+func TestUpdateUnlockedWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "progress.md")
+	os.WriteFile(path, []byte("a\n"), 0644)
+	calls := 0
+	err := Update(path, func(content []byte) ([]byte, error) {
+		calls++
+		if calls == 1 {
+			os.WriteFile(path, []byte("a\nagent\n"), 0644)
+		}
+		return append(content, "loop\n"...), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "a\nagent\nloop\n" || calls != 2 {
+		t.Errorf("Expected both writes after 2 calls, got %q after %d", got, calls)
+	}
+
+	// A file that keeps changing is left to the other writer
+	calls = 0
+	err = Update(path, func(content []byte) ([]byte, error) {
+		calls++
+		os.WriteFile(path, []byte(fmt.Sprintf("agent %d\n", calls)), 0644)
+		return []byte("loop\n"), nil
+	})
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict, got %v", err)
+	}
+	want := fmt.Sprintf("agent %d\n", updateAttempts)
+	if got, _ := os.ReadFile(path); string(got) != want || calls != updateAttempts {
+		t.Errorf("Expected %q kept after %d calls, got %q after %d", want, updateAttempts, got, calls)
+	}
+}
+
+func TestLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "progress.md")
+	unlock, err := LockFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	locked := make(chan struct{})
+	go func() {
+		unlock, err := LockFile(path)
+		if err == nil {
+			unlock()
+		}
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("Expected the second LockFile to wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the lock to be taken once released")
+	}
+
+	// Files locked together in any order don't deadlock
+	other := filepath.Join(filepath.Dir(path), "tasks.md")
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			paths := []string{path, other, path}
+			if i%2 == 1 {
+				paths = []string{other, path}
+			}
+			unlock, err := LockFiles(paths...)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			unlock()
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package atomicfile
+
+import "os"
+
+// flock is a no-op where flock isn't available; only the goroutines of one
+// process are serialized there
+func flock(f *os.File) error { return nil }
+
+// funlock is a no-op where flock isn't available
+func funlock(f *os.File) {}
@@ -0,0 +1,179 @@
+// Package audit builds tamper-evident exports of the actions the autopilot
+// took on a repository. Every record carries the hash of the record before
+// it, so changing, dropping or reordering one breaks every hash after it.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Format identifies the export format in its header
+const Format = "cursor-iter-audit/1"
+
+// Genesis is the previous hash of the first record
+var Genesis = strings.Repeat("0", 64)
+
+// Record kinds
+const (
+	KindDispatch  = "dispatch"   // an agent was started on a task
+	KindOutcome   = "outcome"    // an agent run ended
+	KindCommit    = "commit"     // a commit made during an agent run
+	KindFileWrite = "file-write" // a file changed by such a commit
+	KindApproval  = "approval"   // a code review, sign-off or triage decision
+)
+
+// Record is one action in an export
+type Record struct {
+	Seq     int       `json:"seq"`
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"`
+	Actor   string    `json:"actor"` // the backend and model of an agent, or who decided
+	Task    string    `json:"task,omitempty"`
+	RunID   string    `json:"run_id,omitempty"`
+	Summary string    `json:"summary"`
+	Commit  string    `json:"commit,omitempty"`
+	Path    string    `json:"path,omitempty"`
+	Outcome string    `json:"outcome,omitempty"` // of a run, or the action of an approval
+	Prev    string    `json:"prev"`              // hash of the previous record
+	Hash    string    `json:"hash"`
+}
+
+// Header is the first line of an export
+type Header struct {
+	Format      string     `json:"format"`
+	From        *time.Time `json:"from,omitempty"`
+	To          *time.Time `json:"to,omitempty"`
+	GeneratedAt time.Time  `json:"generated_at"`
+	Records     int        `json:"records"`
+	// Head is the hash of the last record. Keeping it somewhere the export
+	// can't reach, e.g. the review ticket, also detects a rewritten chain.
+	Head string `json:"head"`
+}
+
+// Hash returns the hash of a record, over its JSON form without the hash
+func Hash(r Record) string {
+	r.Hash = ""
+	data, _ := json.Marshal(r)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Chain orders records by time, numbers them and links their hashes,
+// returning the hash of the last one. Records of the same time keep their
+// order.
+func Chain(records []Record) string {
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+	prev := Genesis
+	for i := range records {
+		r := &records[i]
+		r.Seq = i + 1
+		r.Time = r.Time.UTC()
+		r.Prev = prev
+		r.Hash = Hash(*r)
+		prev = r.Hash
+	}
+	return prev
+}
+
+// WriteJSONL writes the header and chained records, one JSON object per
+// line
+func WriteJSONL(w io.Writer, h Header, records []Record) error {
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(h); err != nil {
+		return err
+	}
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteMarkdown writes the header and chained records as a table for
+// reviewers. Only the JSONL form can be verified.
+func WriteMarkdown(w io.Writer, h Header, records []Record) error {
+	var b strings.Builder
+	b.WriteString("# Autopilot audit log\n\n")
+	fmt.Fprintf(&b, "- Period: %s to %s\n", boundText(h.From, "the beginning"), boundText(h.To, "now"))
+	fmt.Fprintf(&b, "- Generated: %s\n", h.GeneratedAt.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "- Records: %d\n", h.Records)
+	fmt.Fprintf(&b, "- Head hash: `%s`\n\n", h.Head)
+	b.WriteString("| # | Time (UTC) | Kind | Actor | Task | Summary | Hash |\n")
+	b.WriteString("|---|---|---|---|---|---|---|\n")
+	for _, r := range records {
+		fmt.Fprintf(&b, "| %d | %s | %s | %s | %s | %s | `%s` |\n", r.Seq, r.Time.UTC().Format("2006-01-02 15:04:05"),
+			r.Kind, cell(r.Actor), cell(r.Task), cell(r.Summary), r.Hash[:12])
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func boundText(t *time.Time, open string) string {
+	if t == nil {
+		return open
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// cell escapes text for a Markdown table cell
+func cell(s string) string {
+	return strings.ReplaceAll(strings.Join(strings.Fields(s), " "), "|", `\|`)
+}
+
+// Verify reads a JSONL export and checks that every record's hash and link
+// are intact and that the chain ends at the header's head. It returns the
+// header.
+func Verify(r io.Reader) (Header, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	var h Header
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return h, err
+		}
+		return h, fmt.Errorf("empty export")
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &h); err != nil || h.Format != Format {
+		return h, fmt.Errorf("line 1 is not a %s header", Format)
+	}
+	prev, n, line := Genesis, 0, 1
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var rec Record
+		dec := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&rec); err != nil {
+			return h, fmt.Errorf("line %d: %v", line, err)
+		}
+		n++
+		switch {
+		case rec.Seq != n:
+			return h, fmt.Errorf("line %d: record %d where %d was expected", line, rec.Seq, n)
+		case rec.Prev != prev:
+			return h, fmt.Errorf("line %d: record %d doesn't link to the record before it", line, rec.Seq)
+		case Hash(rec) != rec.Hash:
+			return h, fmt.Errorf("line %d: record %d was modified", line, rec.Seq)
+		}
+		prev = rec.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		return h, err
+	}
+	if n != h.Records || prev != h.Head {
+		return h, fmt.Errorf("the header promises %d records ending at %.12s, found %d ending at %.12s", h.Records, h.Head, n, prev)
+	}
+	return h, nil
+}
@@ -0,0 +1,71 @@
+package audit
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sampleExport(t *testing.T) []byte {
+	t.Helper()
+	base := time.Date(2025, 3, 1, 9, 0, 0, 0, time.FixedZone("CET", 3600))
+	records := []Record{
+		{Time: base.Add(time.Minute), Kind: KindOutcome, Actor: "codex/gpt-5-codex", Task: "Login", Summary: "run completed", Outcome: "completed"},
+		{Time: base, Kind: KindDispatch, Actor: "codex/gpt-5-codex", Task: "Login", Summary: "dispatched"},
+		{Time: base.Add(30 * time.Second), Kind: KindCommit, Actor: "bot <bot@example.com>", Task: "Login", Summary: "feat: login | form", Commit: "abc123"},
+	}
+	head := Chain(records)
+	if records[0].Kind != KindDispatch || records[2].Kind != KindOutcome || records[0].Seq != 1 || records[0].Prev != Genesis {
+		t.Fatalf("Chain() order = %+v", records)
+	}
+	if records[1].Prev != records[0].Hash || head != records[2].Hash || records[0].Time.Location() != time.UTC {
+		t.Fatalf("Chain() links = %+v", records)
+	}
+	var buf bytes.Buffer
+	if err := WriteJSONL(&buf, Header{Format: Format, GeneratedAt: base, Records: len(records), Head: head}, records); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestVerify(t *testing.T) {
+	export := sampleExport(t)
+	h, err := Verify(bytes.NewReader(export))
+	if err != nil || h.Records != 3 {
+		t.Fatalf("Verify() = %+v, %v", h, err)
+	}
+
+	lines := strings.SplitAfter(string(export), "\n")
+	tests := []struct {
+		name    string
+		export  string
+		wantErr string
+	}{
+		{"modified record", strings.Replace(string(export), `"run completed"`, `"run failed"`, 1), "record 3 was modified"},
+		{"dropped record", lines[0] + lines[1] + lines[3], "record 3 where 2 was expected"},
+		{"truncated", strings.Join(lines[:3], ""), "promises 3 records"},
+		{"reordered", lines[0] + lines[1] + strings.Replace(lines[3], `"seq":3`, `"seq":2`, 1), "doesn't link"},
+		{"added field", strings.Replace(string(export), `"seq":1,`, `"seq":1,"extra":true,`, 1), "unknown field"},
+		{"no header", strings.Join(lines[1:], ""), "not a " + Format + " header"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Verify(strings.NewReader(tt.export)); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Verify() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	records := []Record{{Time: time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC), Kind: KindCommit, Actor: "bot", Task: "Login", Summary: "feat: a | b"}}
+	head := Chain(records)
+	var buf bytes.Buffer
+	WriteMarkdown(&buf, Header{Format: Format, Records: 1, Head: head}, records)
+	got := buf.String()
+	if !strings.Contains(got, "- Period: the beginning to now\n") ||
+		!strings.Contains(got, "| 1 | 2025-03-01 08:00:00 | commit | bot | Login | feat: a \\| b | `"+head[:12]+"` |\n") {
+		t.Errorf("WriteMarkdown() =\n%s", got)
+	}
+}
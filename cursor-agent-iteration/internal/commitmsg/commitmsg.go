@@ -0,0 +1,173 @@
+// Package commitmsg checks commit messages against a conventional-commit policy
+package commitmsg
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultTypes are the conventional-commit types allowed unless configured
+var DefaultTypes = []string{"feat", "fix", "docs", "style", "refactor", "perf", "test", "build", "ci", "chore", "revert"}
+
+// DefaultMaxSubject is the subject length limit unless configured
+const DefaultMaxSubject = 72
+
+var reHeader = regexp.MustCompile(`^([A-Za-z]+)(?:\(([^()]*)\))?(!)?\s*:\s*(.*)$`)
+
+// typeAliases are common misspellings that Fix maps to a conventional type
+var typeAliases = map[string]string{
+	"feature":     "feat",
+	"features":    "feat",
+	"bugfix":      "fix",
+	"bug":         "fix",
+	"hotfix":      "fix",
+	"doc":         "docs",
+	"tests":       "test",
+	"refactoring": "refactor",
+}
+
+// Policy is a set of commit message rules. The zero value checks nothing.
+type Policy struct {
+	Types        []string
+	Scopes       []string // empty allows any scope
+	RequireScope bool
+	MaxSubject   int
+}
+
+// ParsePolicy builds a policy from a spec such as
+// "conventional", "types=feat,fix;scopes=api,cli;max-subject=60" or
+// "require-scope". Any spec other than "" and "off" starts from the defaults.
+func ParsePolicy(spec string) (Policy, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "off" {
+		return Policy{}, nil
+	}
+	p := Policy{Types: DefaultTypes, MaxSubject: DefaultMaxSubject}
+	for _, part := range strings.Split(spec, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch strings.TrimSpace(key) {
+		case "", "conventional":
+		case "types":
+			p.Types = splitList(value)
+		case "scopes":
+			p.Scopes = splitList(value)
+		case "require-scope":
+			p.RequireScope = value == "" || value == "true"
+		case "max-subject":
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || n < 0 {
+				return Policy{}, fmt.Errorf("invalid max-subject %q", value)
+			}
+			p.MaxSubject = n
+		default:
+			return Policy{}, fmt.Errorf("unknown commit policy setting %q", key)
+		}
+	}
+	if len(p.Types) == 0 {
+		return Policy{}, fmt.Errorf("commit policy needs at least one type")
+	}
+	return p, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// Enabled reports whether the policy checks anything
+func (p Policy) Enabled() bool {
+	return len(p.Types) > 0
+}
+
+// Describe summarizes the policy for agent instructions
+func (p Policy) Describe() string {
+	var b strings.Builder
+	b.WriteString("subject must be 'type(scope): description'")
+	fmt.Fprintf(&b, "; types: %s", strings.Join(p.Types, ", "))
+	if len(p.Scopes) > 0 {
+		fmt.Fprintf(&b, "; scopes: %s", strings.Join(p.Scopes, ", "))
+	}
+	if p.RequireScope {
+		b.WriteString("; a scope is required")
+	}
+	if p.MaxSubject > 0 {
+		fmt.Fprintf(&b, "; subject at most %d characters", p.MaxSubject)
+	}
+	return b.String()
+}
+
+// Check returns the policy violations of a commit message, or nil
+func (p Policy) Check(message string) []string {
+	if !p.Enabled() {
+		return nil
+	}
+	lines := strings.Split(strings.TrimRight(message, "\n"), "\n")
+	subject := lines[0]
+
+	var violations []string
+	if p.MaxSubject > 0 && utf8.RuneCountInString(subject) > p.MaxSubject {
+		violations = append(violations, fmt.Sprintf("subject is %d characters, limit is %d", utf8.RuneCountInString(subject), p.MaxSubject))
+	}
+	if len(lines) > 1 && strings.TrimSpace(lines[1]) != "" {
+		violations = append(violations, "subject and body must be separated by a blank line")
+	}
+
+	m := reHeader.FindStringSubmatch(subject)
+	if m == nil || !strings.Contains(subject, ": ") {
+		return append(violations, "subject is not in 'type(scope): description' form")
+	}
+	typ, scope, desc := m[1], m[2], m[4]
+	if !contains(p.Types, typ) {
+		violations = append(violations, fmt.Sprintf("type %q is not one of %s", typ, strings.Join(p.Types, ", ")))
+	}
+	if scope == "" && p.RequireScope {
+		violations = append(violations, "scope is required")
+	}
+	if scope != "" && len(p.Scopes) > 0 && !contains(p.Scopes, scope) {
+		violations = append(violations, fmt.Sprintf("scope %q is not one of %s", scope, strings.Join(p.Scopes, ", ")))
+	}
+	if strings.TrimSpace(desc) == "" {
+		violations = append(violations, "description is empty")
+	}
+	return violations
+}
+
+// Fix makes mechanical corrections to a message: lower-case and aliased
+// types, spacing around the colon, a trailing period and a missing blank
+// line before the body. It reports whether the result satisfies the policy.
+func (p Policy) Fix(message string) (string, bool) {
+	lines := strings.Split(strings.TrimRight(message, "\n"), "\n")
+	if m := reHeader.FindStringSubmatch(lines[0]); m != nil {
+		typ := strings.ToLower(m[1])
+		if alias, ok := typeAliases[typ]; ok && contains(p.Types, alias) {
+			typ = alias
+		}
+		header := typ
+		if m[2] != "" {
+			header += "(" + strings.ToLower(strings.TrimSpace(m[2])) + ")"
+		}
+		lines[0] = header + m[3] + ": " + strings.TrimSuffix(strings.TrimSpace(m[4]), ".")
+	}
+	if len(lines) > 1 && strings.TrimSpace(lines[1]) != "" {
+		lines = append([]string{lines[0], ""}, lines[1:]...)
+	}
+	fixed := strings.Join(lines, "\n")
+	return fixed, len(p.Check(fixed)) == 0
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package commitmsg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePolicy(t *testing.T) {
+	p, err := ParsePolicy("")
+	if err != nil || p.Enabled() {
+		t.Errorf("ParsePolicy(\"\") = %+v, %v; want disabled", p, err)
+	}
+
+	p, err = ParsePolicy("conventional")
+	if err != nil || !reflect.DeepEqual(p.Types, DefaultTypes) || p.MaxSubject != DefaultMaxSubject {
+		t.Errorf("ParsePolicy(conventional) = %+v, %v", p, err)
+	}
+
+	p, err = ParsePolicy("types=feat, fix;scopes=api,cli;max-subject=50;require-scope")
+	want := Policy{Types: []string{"feat", "fix"}, Scopes: []string{"api", "cli"}, RequireScope: true, MaxSubject: 50}
+	if err != nil || !reflect.DeepEqual(p, want) {
+		t.Errorf("ParsePolicy() = %+v, %v; want %+v", p, err, want)
+	}
+
+	for _, spec := range []string{"max-subject=abc", "colour=blue", "types="} {
+		if _, err := ParsePolicy(spec); err == nil {
+			t.Errorf("ParsePolicy(%q) should fail", spec)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	p := Policy{Types: []string{"feat", "fix"}, Scopes: []string{"api"}, MaxSubject: 30}
+	tests := []struct {
+		message    string
+		violations int
+	}{
+		{"feat: add login", 0},
+		{"fix(api)!: drop v1 routes\n\nBREAKING CHANGE: v1 is gone", 0},
+		{"Add login", 1},
+		{"chore: bump deps", 1},
+		{"feat(ui): add button", 1},
+		{"feat: this subject is much too long to be accepted", 1},
+		{"feat: add login\nwith a body", 1},
+		{"feat:", 1},
+	}
+	for _, tt := range tests {
+		if got := p.Check(tt.message); len(got) != tt.violations {
+			t.Errorf("Check(%q) = %v, want %d violation(s)", tt.message, got, tt.violations)
+		}
+	}
+
+	if got := (Policy{}).Check("anything"); got != nil {
+		t.Errorf("Disabled policy reported %v", got)
+	}
+	if got := (Policy{Types: []string{"feat"}, RequireScope: true}).Check("feat: x"); len(got) != 1 {
+		t.Errorf("Expected missing scope violation, got %v", got)
+	}
+}
+
+func TestFix(t *testing.T) {
+	p := Policy{Types: DefaultTypes, MaxSubject: 40}
+	tests := []struct {
+		message string
+		fixed   string
+		ok      bool
+	}{
+		{"Feat(API) : add login.", "feat(api): add login", true},
+		{"bugfix: handle nil map", "fix: handle nil map", true},
+		{"docs: update readme\nmore detail", "docs: update readme\n\nmore detail", true},
+		{"Add login", "Add login", false},
+		{"feat: a subject that stays far too long after fixing", "feat: a subject that stays far too long after fixing", false},
+	}
+	for _, tt := range tests {
+		fixed, ok := p.Fix(tt.message)
+		if fixed != tt.fixed || ok != tt.ok {
+			t.Errorf("Fix(%q) = %q, %v; want %q, %v", tt.message, fixed, ok, tt.fixed, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// Package compress condenses the control files agents read for context, so
+// prompts stay within a token budget as architecture.md, decisions.md and the
+// progress history grow. Condensed copies are cached by the hash of the file
+// they were made from and are only rebuilt when that file changes.
+package compress
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Files are the control files that get condensed copies
+var Files = []string{"architecture.md", "decisions.md", "progress.md"}
+
+// Extractive limits
+const (
+	// maxSectionItems is how many list items are kept per section
+	maxSectionItems = 5
+	// maxCompleted is how many of the most recent completed tasks are kept
+	// from the progress history
+	maxCompleted = 10
+	// maxLine is the length long lines are cut to
+	maxLine = 200
+)
+
+var (
+	reHeader   = regexp.MustCompile(`^<!-- compressed from (\S+) sha256:([0-9a-f]+) -->\n`)
+	reListItem = regexp.MustCompile(`^\s*([-*+]|\d+[.)])\s+`)
+	reSentence = regexp.MustCompile(`^(.+?[.!?])(\s|$)`)
+)
+
+// EstimateTokens roughly estimates the tokens a text takes in a prompt
+func EstimateTokens(content string) int {
+	return (len(content) + 3) / 4
+}
+
+// Hash identifies the content a condensed copy was made from
+func Hash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// Extract condenses a control file without a model. It keeps every heading,
+// the first sentence of each paragraph and the first few list items of each
+// section. For progress.md the in-progress tasks are kept and the completed
+// history is cut to the most recent entries.
+func Extract(name string, content string) string {
+	if name == "progress.md" {
+		return extractProgress(content)
+	}
+	var out []string
+	items, paragraph := 0, false
+	skipped := 0
+	flush := func() {
+		if skipped > 0 {
+			out = append(out, fmt.Sprintf("- … %d more", skipped))
+			skipped = 0
+		}
+	}
+	inFence := false
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+			continue
+		}
+		switch {
+		case inFence:
+			// Code samples are left to the full file
+		case strings.HasPrefix(trimmed, "#"):
+			flush()
+			out = append(out, "", trimmed)
+			items, paragraph = 0, false
+		case trimmed == "":
+			paragraph = false
+		case reListItem.MatchString(line):
+			paragraph = false
+			if items < maxSectionItems {
+				out = append(out, cut(strings.TrimRight(line, " \t")))
+			} else {
+				skipped++
+			}
+			items++
+		case !paragraph:
+			flush()
+			paragraph = true
+			if m := reSentence.FindStringSubmatch(trimmed); m != nil {
+				trimmed = m[1]
+			}
+			out = append(out, cut(trimmed))
+		}
+	}
+	flush()
+	return strings.TrimSpace(strings.Join(collapse(out), "\n")) + "\n"
+}
+
+// extractProgress keeps everything but older completed tasks
+func extractProgress(content string) string {
+	var out, completed []string
+	inCompleted := false
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "## ") {
+			if inCompleted {
+				out = append(out, recent(completed)...)
+				completed = nil
+			}
+			inCompleted = trimmed == "## Completed Tasks"
+			out = append(out, "", trimmed)
+			continue
+		}
+		if trimmed == "" {
+			continue
+		}
+		if inCompleted {
+			completed = append(completed, cut(trimmed))
+		} else {
+			out = append(out, cut(trimmed))
+		}
+	}
+	if inCompleted {
+		out = append(out, recent(completed)...)
+	}
+	return strings.TrimSpace(strings.Join(collapse(out), "\n")) + "\n"
+}
+
+// recent keeps the last completed entries, which are the newest
+func recent(completed []string) []string {
+	if len(completed) <= maxCompleted {
+		return completed
+	}
+	older := len(completed) - maxCompleted
+	return append([]string{fmt.Sprintf("- … %d earlier completed task(s), see the full progress.md", older)}, completed[older:]...)
+}
+
+// cut shortens a line to maxLine characters
+func cut(line string) string {
+	runes := []rune(line)
+	if len(runes) <= maxLine {
+		return line
+	}
+	return string(runes[:maxLine-1]) + "…"
+}
+
+// collapse drops repeated blank lines
+func collapse(lines []string) []string {
+	var out []string
+	for _, line := range lines {
+		if line == "" && (len(out) == 0 || out[len(out)-1] == "") {
+			continue
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+// Prompt asks a model to condense a control file
+func Prompt(name string, content string) string {
+	return fmt.Sprintf(`Condense the control file %s below for use as context by coding agents.
+
+Reply with Markdown only and do not modify any files. Keep the headings, every
+decision, constraint, interface and file path, and the status of in-progress
+work; drop examples, repetition and prose. For progress history keep only the
+most recent completed tasks. Aim for at most a quarter of the original length.
+
+---
+
+%s
+`, name, strings.TrimSpace(content))
+}
+
+// Path is where the condensed copy of a control file is cached
+func Path(dir string, name string) string {
+	return filepath.Join(dir, name)
+}
+
+// Load returns the cached condensed copy of a control file if it was made
+// from the given content
+func Load(dir string, name string, content string) (string, bool) {
+	data, err := os.ReadFile(Path(dir, name))
+	if err != nil {
+		return "", false
+	}
+	m := reHeader.FindStringSubmatch(string(data))
+	if m == nil || m[1] != name || m[2] != Hash(content) {
+		return "", false
+	}
+	return string(data[len(m[0]):]), true
+}
+
+// Save caches the condensed copy of a control file, recording the hash of the
+// content it was made from, and returns its path
+func Save(dir string, name string, content string, condensed string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := Path(dir, name)
+	data := fmt.Sprintf("<!-- compressed from %s sha256:%s -->\n%s", name, Hash(content), strings.TrimSpace(condensed)+"\n")
+	return path, os.WriteFile(path, []byte(data), 0644)
+}
@@ -0,0 +1,67 @@
+package compress
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		expected string
+	}{
+		{
+			"headings and first sentences",
+			"architecture.md",
+			"# Architecture\n\nThe system has two parts. The first one is\nwrapped over lines.\n\n## Storage\n\nFiles on disk. Nothing else.\n\n```go\nfunc main() {}\n```\n",
+			"# Architecture\nThe system has two parts.\n\n## Storage\nFiles on disk.\n",
+		},
+		{
+			"long lists",
+			"decisions.md",
+			"## ADR-001\n- a\n- b\n- c\n- d\n- e\n- f\n- g\n## ADR-002\n- h\n",
+			"## ADR-001\n- a\n- b\n- c\n- d\n- e\n- … 2 more\n\n## ADR-002\n- h\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Extract(tt.file, tt.content); got != tt.expected {
+				t.Errorf("Extract() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExtractProgress(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("# Progress Log\n\n## In Progress\n\n- 🔄 [2025-01-08 10:00] Current\n\n## Completed Tasks\n\n")
+	for i := 1; i <= 12; i++ {
+		fmt.Fprintf(&b, "- ✅ [2025-01-%02d 10:00] Task %d - done\n", i, i)
+	}
+	got := Extract("progress.md", b.String())
+	if !strings.Contains(got, "Current") || !strings.Contains(got, "2 earlier completed task(s)") {
+		t.Errorf("Expected in-progress tasks kept and older ones counted, got %q", got)
+	}
+	if strings.Contains(got, "Task 2 -") || !strings.Contains(got, "Task 3 -") || !strings.Contains(got, "Task 12 -") {
+		t.Errorf("Expected the 10 most recent completed tasks, got %q", got)
+	}
+}
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	if _, ok := Load(dir, "architecture.md", "v1"); ok {
+		t.Fatalf("Expected no cached copy")
+	}
+	if _, err := Save(dir, "architecture.md", "v1", "short"); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if got, ok := Load(dir, "architecture.md", "v1"); !ok || got != "short\n" {
+		t.Errorf("Load() = %q, %v", got, ok)
+	}
+	if _, ok := Load(dir, "architecture.md", "v2"); ok {
+		t.Errorf("Expected a stale copy to be ignored after the file changed")
+	}
+}
@@ -0,0 +1,250 @@
+// Package config reads the per-repository .cursor-iter.yaml file, which sets
+// defaults for command-line flags. It understands the small subset of YAML
+// such a file needs: "key: value" pairs, lists, comments, and one level of
+// sections that hold the settings of a single command, the commands of the
+// quality gates, or the labels other labels imply:
+//
+//	model: gpt-5
+//	max-in-progress: 4
+//	fallback: [codex]
+//	iterate-loop:
+//	  stagger: 5s
+//	gates:
+//	  lint: golangci-lint run
+//	  test: go test ./...
+//	label-defaults:
+//	  area:infra: [milestone:infra, exclusive:true]
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Config holds settings by key. Keys are flag names; snake_case is accepted
+// and stored in kebab-case.
+type Config struct {
+	// Values are the top-level settings, shared by every command
+	Values map[string]string
+	// Sections are the settings of a single command, by command name
+	Sections map[string]map[string]string
+}
+
+// Load reads a config file; a missing file is an empty config
+func Load(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Config{}, nil
+	}
+	if err != nil {
+		return Config{}, err
+	}
+	c, err := Parse(string(data))
+	if err != nil {
+		return Config{}, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+// Lookup returns the value of key for a command: from the command's section
+// if it sets it, otherwise the top-level value
+func (c Config) Lookup(command string, key string) (string, bool) {
+	key = normalizeKey(key)
+	if v, ok := c.Sections[command][key]; ok {
+		return v, true
+	}
+	v, ok := c.Values[key]
+	return v, ok
+}
+
+// line is a meaningful line of a config file
+type line struct {
+	num    int
+	indent int
+	text   string
+}
+
+// Parse parses the contents of a config file
+func Parse(data string) (Config, error) {
+	c := Config{Values: map[string]string{}, Sections: map[string]map[string]string{}}
+	var lines []line
+	for i, raw := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		text := strings.TrimRight(stripComment(raw), " \t")
+		if strings.TrimSpace(text) == "" || text == "---" {
+			continue
+		}
+		trimmed := strings.TrimLeft(text, " \t")
+		if strings.Contains(text[:len(text)-len(trimmed)], "\t") {
+			return Config{}, fmt.Errorf("line %d: indent with spaces, not tabs", i+1)
+		}
+		lines = append(lines, line{num: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		if l.indent > 0 {
+			return Config{}, fmt.Errorf("line %d: unexpected indentation", l.num)
+		}
+		key, value, err := splitPair(l)
+		if err != nil {
+			return Config{}, err
+		}
+		if value != "" {
+			if c.Values[key], err = scalar(l, value); err != nil {
+				return Config{}, err
+			}
+			continue
+		}
+		// A key without a value opens a block list or a section
+		var block []line
+		for i+1 < len(lines) && lines[i+1].indent > 0 {
+			block = append(block, lines[i+1])
+			i++
+		}
+		switch {
+		case len(block) == 0:
+			c.Values[key] = ""
+		case strings.HasPrefix(block[0].text, "- ") || block[0].text == "-":
+			if c.Values[key], err = blockList(block); err != nil {
+				return Config{}, err
+			}
+		default:
+			if c.Sections[key], err = section(block); err != nil {
+				return Config{}, err
+			}
+		}
+	}
+	return c, nil
+}
+
+// section parses the indented settings of a command
+func section(block []line) (map[string]string, error) {
+	values := map[string]string{}
+	indent := block[0].indent
+	for i := 0; i < len(block); i++ {
+		l := block[i]
+		if l.indent != indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", l.num)
+		}
+		key, value, err := splitPair(l)
+		if err != nil {
+			return nil, err
+		}
+		if value != "" {
+			if values[key], err = scalar(l, value); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var items []line
+		for i+1 < len(block) && block[i+1].indent > indent {
+			items = append(items, block[i+1])
+			i++
+		}
+		if values[key], err = blockList(items); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// blockList joins the items of a "- item" list with commas, the way list
+// flags such as --fallback take them
+func blockList(items []line) (string, error) {
+	var values []string
+	for _, l := range items {
+		item, ok := strings.CutPrefix(l.text, "-")
+		if !ok || (item != "" && item[0] != ' ') {
+			return "", fmt.Errorf("line %d: expected a list item or a \"key: value\" setting", l.num)
+		}
+		v, err := scalar(l, strings.TrimSpace(item))
+		if err != nil {
+			return "", err
+		}
+		values = append(values, v)
+	}
+	return strings.Join(values, ","), nil
+}
+
+// splitPair splits a "key: value" line. As in YAML, the key ends at the
+// first colon followed by a space or the end of the line, so keys such as
+// the label "area:infra" may contain colons.
+func splitPair(l line) (key string, value string, err error) {
+	key, value, ok := strings.Cut(l.text, ": ")
+	if !ok {
+		key, ok = strings.CutSuffix(l.text, ":")
+	}
+	if !ok && strings.Contains(l.text, ":") {
+		return "", "", fmt.Errorf("line %d: expected a space after the colon", l.num)
+	}
+	if !ok || strings.TrimSpace(key) == "" {
+		return "", "", fmt.Errorf("line %d: expected \"key: value\"", l.num)
+	}
+	return normalizeKey(key), strings.TrimSpace(value), nil
+}
+
+// scalar unquotes a value; a flow list such as [a, b] becomes "a,b"
+func scalar(l line, value string) (string, error) {
+	if strings.HasPrefix(value, "[") {
+		inner, ok := strings.CutSuffix(value[1:], "]")
+		if !ok {
+			return "", fmt.Errorf("line %d: unterminated list", l.num)
+		}
+		var values []string
+		for _, item := range strings.Split(inner, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			v, err := scalar(l, item)
+			if err != nil {
+				return "", err
+			}
+			values = append(values, v)
+		}
+		return strings.Join(values, ","), nil
+	}
+	for _, q := range []string{`"`, `'`} {
+		if !strings.HasPrefix(value, q) {
+			continue
+		}
+		if len(value) < 2 || !strings.HasSuffix(value, q) {
+			return "", fmt.Errorf("line %d: unterminated quoted value", l.num)
+		}
+		inner := value[1 : len(value)-1]
+		if q == `'` {
+			return strings.ReplaceAll(inner, "''", "'"), nil
+		}
+		return strings.NewReplacer(`\"`, `"`, `\\`, `\`, `\n`, "\n", `\t`, "\t").Replace(inner), nil
+	}
+	return value, nil
+}
+
+// stripComment removes a "#" comment that isn't inside a quoted value. Like
+// YAML, a "#" only starts a comment at the start of a line or after a space,
+// and only quotes that start a value quote it.
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++ // an escaped character
+			} else if c == '\'' && quote == '\'' && i+1 < len(s) && s[i+1] == '\'' {
+				i++ // '' is a quote inside single quotes
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || s[i-1] == ' ' || s[i-1] == '['):
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// normalizeKey makes config keys match flag names
+func normalizeKey(key string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(key)), "_", "-")
+}
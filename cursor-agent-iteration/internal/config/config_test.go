@@ -0,0 +1,129 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const sampleConfig = `# cursor-iter settings
+---
+model: gpt-5
+codex: false
+max_in_progress: 4   # snake_case works too
+fallback: [codex, "cursor-agent"]
+commit-policy: "types=feat,fix;max-subject=72"
+completion-format: '{title} - {notes} | it''s #1'
+blocked-patterns: patterns#1.txt
+prompt-ladder:
+  - lean
+  - split
+
+iterate-loop:
+  max-in-progress: 8
+  stagger: 5s
+  fallback:
+    - codex
+label-defaults:
+  area:infra: [priority:high, "milestone:infra"]
+  type:docs:
+    - model:haiku
+`
+
+func TestParse(t *testing.T) {
+	c, err := Parse(sampleConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"model":             "gpt-5",
+		"codex":             "false",
+		"max-in-progress":   "4",
+		"fallback":          "codex,cursor-agent",
+		"commit-policy":     "types=feat,fix;max-subject=72",
+		"completion-format": "{title} - {notes} | it's #1",
+		"blocked-patterns":  "patterns#1.txt",
+		"prompt-ladder":     "lean,split",
+	}
+	if !reflect.DeepEqual(c.Values, expected) {
+		t.Errorf("Values = %v, want %v", c.Values, expected)
+	}
+	section := map[string]string{"max-in-progress": "8", "stagger": "5s", "fallback": "codex"}
+	if !reflect.DeepEqual(c.Sections["iterate-loop"], section) {
+		t.Errorf("Sections[iterate-loop] = %v, want %v", c.Sections["iterate-loop"], section)
+	}
+	labels := map[string]string{"area:infra": "priority:high,milestone:infra", "type:docs": "model:haiku"}
+	if !reflect.DeepEqual(c.Sections["label-defaults"], labels) {
+		t.Errorf("Sections[label-defaults] = %v, want %v", c.Sections["label-defaults"], labels)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	c, err := Parse(sampleConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		command  string
+		key      string
+		expected string
+		found    bool
+	}{
+		{"iterate-loop", "max-in-progress", "8", true},
+		{"iterate", "max-in-progress", "4", true},
+		{"iterate", "max_in_progress", "4", true},
+		{"iterate-loop", "model", "gpt-5", true},
+		{"iterate", "stagger", "", false},
+	}
+	for _, tt := range tests {
+		got, found := c.Lookup(tt.command, tt.key)
+		if got != tt.expected || found != tt.found {
+			t.Errorf("Lookup(%q, %q) = %q, %v; want %q, %v", tt.command, tt.key, got, found, tt.expected, tt.found)
+		}
+	}
+	if _, found := (Config{}).Lookup("iterate", "model"); found {
+		t.Error("Expected an empty config to have no values")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{"no colon", "model gpt-5\n", "line 1: expected \"key: value\""},
+		{"no space after colon", "model:gpt-5\n", "line 1: expected a space"},
+		{"empty key", ": gpt-5\n", "line 1: expected \"key: value\""},
+		{"tab indent", "iterate:\n\tmodel: x\n", "line 2: indent with spaces"},
+		{"indented first line", "  model: x\n", "line 1: unexpected indentation"},
+		{"nested section", "iterate:\n  model: x\n    deeper: y\n", "line 3: unexpected indentation"},
+		{"unterminated quote", "model: \"gpt-5\n", "line 1: unterminated quoted value"},
+		{"unterminated list", "fallback: [codex\n", "line 1: unterminated list"},
+		{"mixed list", "fallback:\n  - codex\n  other: x\n", "line 3: expected a list item"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.config)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Parse() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	if c, err := Load(filepath.Join(dir, "missing.yaml")); err != nil || len(c.Values) != 0 {
+		t.Errorf("Load(missing) = %v, %v", c, err)
+	}
+	path := filepath.Join(dir, ".cursor-iter.yaml")
+	if err := os.WriteFile(path, []byte("model gpt-5\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), path+": line 1") {
+		t.Errorf("Load() error = %v, want the path and line", err)
+	}
+}
@@ -0,0 +1,274 @@
+// Package conflict inspects and resolves git merge and rebase conflicts left
+// behind by agent runs, and keeps per-file conflict counts
+package conflict
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultGenerated are files that are regenerated rather than merged by
+// hand, so their conflicts are resolved by taking the incoming side
+var DefaultGenerated = []string{"go.sum", "package-lock.json", "pnpm-lock.yaml", "yarn.lock", "Cargo.lock", "*.pb.go", "*_gen.go", "*.gen.go"}
+
+// Operation is the git operation that stopped on conflicts
+type Operation string
+
+const (
+	OpNone   Operation = ""
+	OpMerge  Operation = "merge"
+	OpRebase Operation = "rebase"
+)
+
+// Sides of a conflict. During a rebase git swaps them: "ours" is the branch
+// being rebased onto and "theirs" the commit being replayed.
+const (
+	Ours   = "ours"
+	Theirs = "theirs"
+)
+
+// State describes the conflicts in a repository
+type State struct {
+	Op       Operation
+	Incoming string // MERGE_HEAD or the commit being replayed
+	Files    []string
+}
+
+// Inspect reports the in-progress operation and the unmerged files at root
+func Inspect(root string) (State, error) {
+	var st State
+	gitDir, err := git(root, "rev-parse", "--git-dir")
+	if err != nil {
+		return st, fmt.Errorf("not a git repository: %v", err)
+	}
+	dir := strings.TrimSpace(gitDir)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	switch {
+	case exists(filepath.Join(dir, "rebase-merge")) || exists(filepath.Join(dir, "rebase-apply")):
+		st.Op = OpRebase
+		if head, err := os.ReadFile(filepath.Join(dir, "REBASE_HEAD")); err == nil {
+			st.Incoming = strings.TrimSpace(string(head))
+		}
+	case exists(filepath.Join(dir, "MERGE_HEAD")):
+		st.Op = OpMerge
+		head, _ := os.ReadFile(filepath.Join(dir, "MERGE_HEAD"))
+		st.Incoming = strings.TrimSpace(string(head))
+	}
+
+	out, err := git(root, "diff", "--name-only", "--diff-filter=U")
+	if err != nil {
+		return st, err
+	}
+	for _, f := range strings.Split(out, "\n") {
+		if f = strings.TrimSpace(f); f != "" {
+			st.Files = append(st.Files, f)
+		}
+	}
+	return st, nil
+}
+
+// IsGenerated reports whether file matches one of the glob patterns, by full
+// path or by base name
+func IsGenerated(file string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, file); ok {
+			return true
+		}
+		if ok, _ := path.Match(p, path.Base(file)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Take resolves a file by taking one side of the conflict and staging it
+func Take(root, file, side string) error {
+	if side != Ours && side != Theirs {
+		return fmt.Errorf("unknown side %q", side)
+	}
+	if _, err := git(root, "checkout", "--"+side, "--", file); err != nil {
+		if !strings.Contains(err.Error(), "does not have") {
+			return fmt.Errorf("could not take %s side of %s: %v", side, file, err)
+		}
+		// The file was deleted on that side
+		_, err = git(root, "rm", "-q", "--", file)
+		return err
+	}
+	_, err := git(root, "add", "--", file)
+	return err
+}
+
+// HasMarkers reports whether a file still contains conflict markers
+func HasMarkers(root, file string) bool {
+	f, err := os.Open(filepath.Join(root, file))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "<<<<<<< ") || strings.HasPrefix(line, ">>>>>>> ") {
+			return true
+		}
+	}
+	return false
+}
+
+// MarkResolved stages a file that was fixed by hand or by an agent, refusing
+// if conflict markers remain
+func MarkResolved(root, file string) error {
+	if HasMarkers(root, file) {
+		return fmt.Errorf("%s still has conflict markers", file)
+	}
+	_, err := git(root, "add", "--", file)
+	return err
+}
+
+// RetryAsRebase aborts a conflicted merge and replays the local commits on
+// top of the incoming commit instead, the way `git pull --rebase` would.
+// Rebasing resolves conflicts commit by commit, which often makes them
+// smaller; it can stop on conflicts of its own.
+func RetryAsRebase(root string, st State) error {
+	if st.Op != OpMerge || st.Incoming == "" {
+		return fmt.Errorf("rebase is only offered for a conflicted merge")
+	}
+	if _, err := git(root, "merge", "--abort"); err != nil {
+		return fmt.Errorf("git merge --abort failed: %v", err)
+	}
+	if out, err := git(root, "rebase", st.Incoming); err != nil {
+		return fmt.Errorf("rebase stopped: %v %s", err, strings.TrimSpace(out))
+	}
+	return nil
+}
+
+// AgentPrompt asks an agent to resolve the remaining conflicts
+func AgentPrompt(root string, st State) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "The repository has unresolved %s conflicts in these files:\n\n", opName(st.Op))
+	for _, f := range st.Files {
+		fmt.Fprintf(&b, "- %s\n", f)
+	}
+	if st.Incoming != "" {
+		if log, err := git(root, "log", "-1", "--format=%h %s", st.Incoming); err == nil {
+			fmt.Fprintf(&b, "\nThe incoming change is %s", log)
+		}
+	}
+	b.WriteString(`
+Resolve every conflict so that the intent of both sides is kept:
+- Read each file, remove all conflict markers and combine the changes
+- Build and run the relevant tests to check the result
+- Stage the resolved files with git add, but do not commit and do not abort the ` + opName(st.Op) + `
+`)
+	return b.String()
+}
+
+func opName(op Operation) string {
+	if op == OpNone {
+		return "merge"
+	}
+	return string(op)
+}
+
+// Record is one conflicted file and how it was resolved
+type Record struct {
+	Time       time.Time `json:"time"`
+	File       string    `json:"file"`
+	Op         Operation `json:"op"`
+	Resolution string    `json:"resolution,omitempty"`
+}
+
+// Append adds records to the JSONL conflict log at logPath
+func Append(logPath string, records ...Record) error {
+	if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FileCount is how often a file conflicted
+type FileCount struct {
+	File  string
+	Count int
+	Last  time.Time
+}
+
+// Frequency reads the conflict log and counts conflicts per file, most
+// frequent first
+func Frequency(logPath string) ([]FileCount, error) {
+	data, err := os.ReadFile(logPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]*FileCount)
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		var r Record
+		if len(bytes.TrimSpace(line)) == 0 || json.Unmarshal(line, &r) != nil {
+			continue
+		}
+		c, ok := counts[r.File]
+		if !ok {
+			c = &FileCount{File: r.File}
+			counts[r.File] = c
+		}
+		c.Count++
+		if r.Time.After(c.Last) {
+			c.Last = r.Time
+		}
+	}
+	result := make([]FileCount, 0, len(counts))
+	for _, c := range counts {
+		result = append(result, *c)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].File < result[j].File
+	})
+	return result, nil
+}
+
+func exists(p string) bool {
+	_, err := os.Stat(p)
+	return err == nil
+}
+
+func git(root string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = root
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil && stderr.Len() > 0 {
+		err = fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), err
+}
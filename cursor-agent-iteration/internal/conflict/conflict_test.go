@@ -0,0 +1,126 @@
+package conflict
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// conflictedRepo creates a repository with a merge that conflicts in app.go
+// and go.sum
+func conflictedRepo(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found in PATH, skipping test")
+	}
+	root := t.TempDir()
+	run := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = root
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		os.WriteFile(filepath.Join(root, name), []byte(content), 0644)
+	}
+	run("init", "-q", "-b", "main")
+	write("app.go", "package app\n\nconst Name = \"base\"\n")
+	write("go.sum", "base\n")
+	run("add", ".")
+	run("commit", "-qm", "base")
+	run("checkout", "-qb", "task")
+	write("app.go", "package app\n\nconst Name = \"task\"\n")
+	write("go.sum", "task\n")
+	run("commit", "-qam", "task change")
+	run("checkout", "-q", "main")
+	write("app.go", "package app\n\nconst Name = \"main\"\n")
+	write("go.sum", "main\n")
+	run("commit", "-qam", "main change")
+
+	cmd := exec.Command("git", "-c", "user.name=test", "-c", "user.email=test@example.com", "merge", "task")
+	cmd.Dir = root
+	if err := cmd.Run(); err == nil {
+		t.Fatalf("Expected merge to conflict")
+	}
+	return root
+}
+
+func TestInspectAndResolve(t *testing.T) {
+	root := conflictedRepo(t)
+
+	st, err := Inspect(root)
+	if err != nil {
+		t.Fatalf("Inspect() = %v", err)
+	}
+	if st.Op != OpMerge || st.Incoming == "" || strings.Join(st.Files, ",") != "app.go,go.sum" {
+		t.Fatalf("Inspect() = %+v", st)
+	}
+
+	if !IsGenerated("go.sum", DefaultGenerated) || !IsGenerated("api/v1/api.pb.go", DefaultGenerated) || IsGenerated("app.go", DefaultGenerated) {
+		t.Errorf("IsGenerated() mismatch")
+	}
+
+	if err := Take(root, "go.sum", Theirs); err != nil {
+		t.Fatalf("Take() = %v", err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(root, "go.sum")); string(content) != "task\n" {
+		t.Errorf("Expected incoming go.sum, got %q", content)
+	}
+
+	if err := MarkResolved(root, "app.go"); err == nil {
+		t.Errorf("Expected MarkResolved to refuse a file with conflict markers")
+	}
+	if prompt := AgentPrompt(root, st); !strings.Contains(prompt, "- app.go") || !strings.Contains(prompt, "task change") {
+		t.Errorf("Unexpected agent prompt:\n%s", prompt)
+	}
+	os.WriteFile(filepath.Join(root, "app.go"), []byte("package app\n\nconst Name = \"main+task\"\n"), 0644)
+	if err := MarkResolved(root, "app.go"); err != nil {
+		t.Fatalf("MarkResolved() = %v", err)
+	}
+
+	if st, _ := Inspect(root); len(st.Files) != 0 || st.Op != OpMerge {
+		t.Errorf("Expected no conflicts left in the merge, got %+v", st)
+	}
+}
+
+func TestRetryAsRebase(t *testing.T) {
+	root := conflictedRepo(t)
+	st, _ := Inspect(root)
+
+	// Rebasing main's commit onto the task branch conflicts too
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	if err := RetryAsRebase(root, st); err == nil {
+		t.Fatalf("Expected the rebase to stop on conflicts")
+	}
+	st, _ = Inspect(root)
+	if st.Op != OpRebase || len(st.Files) != 2 {
+		t.Errorf("Expected a conflicted rebase, got %+v", st)
+	}
+	if err := RetryAsRebase(root, st); err == nil {
+		t.Errorf("Expected RetryAsRebase to refuse during a rebase")
+	}
+}
+
+func TestFrequency(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "logs", "conflicts.jsonl")
+	if counts, err := Frequency(logPath); err != nil || counts != nil {
+		t.Errorf("Frequency() of missing log = %v, %v", counts, err)
+	}
+	now := time.Now()
+	Append(logPath, Record{Time: now, File: "a.go"}, Record{Time: now, File: "go.sum"})
+	Append(logPath, Record{Time: now.Add(time.Hour), File: "go.sum", Resolution: Theirs})
+
+	counts, err := Frequency(logPath)
+	if err != nil || len(counts) != 2 {
+		t.Fatalf("Frequency() = %v, %v", counts, err)
+	}
+	if counts[0].File != "go.sum" || counts[0].Count != 2 || !counts[0].Last.Equal(now.Add(time.Hour)) {
+		t.Errorf("Unexpected top entry %+v", counts[0])
+	}
+}
@@ -0,0 +1,407 @@
+// Package coord shares a global budget of concurrent agents between
+// iterate-loops running in different repositories, since they draw on the
+// same API quota. Each loop keeps a state file in a shared directory; a slot
+// is only taken while holding the directory lock, so the limit holds across
+// processes.
+//
+// Repositories with a higher priority go first: while one of them is waiting
+// for a slot, lower-priority repositories start no new agents and the next
+// slot that frees up goes to it. When no slot is free, the lowest-priority
+// repository stops one of its agents to free one. Repositories of the same
+// priority share the budget by weight.
+package coord
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/atomicfile"
+)
+
+// Defaults
+const (
+	// StaleAfter is how long a state file lives without a heartbeat, so
+	// crashed loops don't hold slots forever
+	StaleAfter = 2 * time.Minute
+	// WaitingFor is how long a refused request counts as waiting; loops
+	// retry far more often than this while they have work
+	WaitingFor = 30 * time.Second
+
+	// lockName is locked with atomicfile.LockFile to read and write the
+	// state files, as "states.lock"
+	lockName = "states"
+)
+
+// ErrNoSlot is returned by Acquire when the repository must wait
+var ErrNoSlot = errors.New("no global agent slot")
+
+// State is one repository's entry in the coordinator directory
+type State struct {
+	Repo      string    `json:"repo"`
+	PID       int       `json:"pid"`
+	Priority  int       `json:"priority"`
+	Weight    float64   `json:"weight"`
+	Running   int       `json:"running"`
+	WaitingAt time.Time `json:"waiting_at,omitempty"`
+	Updated   time.Time `json:"updated"`
+}
+
+// Waiting reports whether the repository asked for a slot recently and was refused
+func (s State) Waiting(now time.Time) bool {
+	return !s.WaitingAt.IsZero() && now.Sub(s.WaitingAt) < WaitingFor
+}
+
+func (s State) weight() float64 {
+	if s.Weight <= 0 {
+		return 1
+	}
+	return s.Weight
+}
+
+// Admit decides whether me may start one more agent, given the live states
+// of the other repositories. The reason explains a refusal.
+func Admit(others []State, me State, max int, now time.Time) (bool, string) {
+	total := me.Running
+	for _, s := range others {
+		total += s.Running
+	}
+	if total >= max {
+		return false, fmt.Sprintf("all %d global agent slots are in use", max)
+	}
+
+	// Yield to higher-priority repositories that have work
+	for _, s := range others {
+		if s.Priority > me.Priority && s.Waiting(now) {
+			return false, fmt.Sprintf("yielding to higher-priority %s", s.Repo)
+		}
+	}
+
+	// Share the slots left by other priorities by weight among the
+	// repositories of this priority that have work
+	available := max
+	weights := me.weight()
+	var peers []State
+	for _, s := range others {
+		if s.Priority != me.Priority {
+			available -= s.Running
+			continue
+		}
+		if s.Running > 0 || s.Waiting(now) {
+			weights += s.weight()
+			peers = append(peers, s)
+		}
+	}
+	share := func(s State) int {
+		return int(math.Ceil(float64(available) * s.weight() / weights))
+	}
+	if me.Running < share(me) {
+		return true, ""
+	}
+	for _, s := range peers {
+		if s.Waiting(now) && s.Running < share(s) {
+			return false, fmt.Sprintf("at its weighted share of %d slot(s) while %s waits", share(me), s.Repo)
+		}
+	}
+	return true, ""
+}
+
+// Preempt returns how many of me's running agents to stop so that the
+// higher-priority repositories waiting for a slot get one. Each waiting
+// repository is owed one slot at a time, taken from the repository of the
+// lowest priority, then the most agents, then the first name, so the loops
+// of all repositories agree on whose agents stop.
+func Preempt(others []State, me State, max int, now time.Time) int {
+	all := append([]State{me}, others...)
+	free := max
+	for _, s := range all {
+		free -= s.Running
+	}
+	var waiting []State
+	for _, s := range all {
+		if s.Waiting(now) {
+			waiting = append(waiting, s)
+		}
+	}
+	sort.SliceStable(waiting, func(i, j int) bool { return waiting[i].Priority > waiting[j].Priority })
+
+	stop := 0
+	for _, w := range waiting {
+		if free > 0 {
+			free--
+			continue
+		}
+		victim := -1
+		for i, s := range all {
+			if s.Priority >= w.Priority || s.Running == 0 {
+				continue
+			}
+			if victim < 0 || stopsBefore(s, all[victim]) {
+				victim = i
+			}
+		}
+		if victim < 0 {
+			continue
+		}
+		all[victim].Running--
+		if all[victim].Repo == me.Repo {
+			stop++
+		}
+	}
+	return stop
+}
+
+// stopsBefore reports whether a stops an agent before b does
+func stopsBefore(a, b State) bool {
+	if a.Priority != b.Priority {
+		return a.Priority < b.Priority
+	}
+	if a.Running != b.Running {
+		return a.Running > b.Running
+	}
+	return a.Repo < b.Repo
+}
+
+// Coordinator holds this repository's place in the shared directory. A nil
+// *Coordinator imposes no global limit.
+type Coordinator struct {
+	dir  string
+	max  int
+	mu   sync.Mutex
+	self State
+	stop chan struct{}
+	done chan struct{}
+	// preempt stops running agents of this repository; yielding counts
+	// those it stopped that still hold their slots
+	preempt  func(n int) int
+	yielding int
+}
+
+// New registers repo in the coordinator directory with a global limit of max
+// concurrent agents
+func New(dir string, repo string, max int, priority int, weight float64) (*Coordinator, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	c := &Coordinator{
+		dir:  dir,
+		max:  max,
+		self: State{Repo: repo, PID: os.Getpid(), Priority: priority, Weight: weight},
+	}
+	return c, c.locked(func(time.Time, []State) error { return nil })
+}
+
+// path is the state file of a repository
+func (c *Coordinator) path() string {
+	sum := sha256.Sum256([]byte(c.self.Repo))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:6])+".json")
+}
+
+// Acquire takes a global slot for one agent, or returns an error wrapping
+// ErrNoSlot that says why the repository has to wait
+func (c *Coordinator) Acquire() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var reason string
+	err := c.locked(func(now time.Time, others []State) error {
+		ok, why := Admit(others, c.self, c.max, now)
+		if !ok {
+			reason = why
+			c.self.WaitingAt = now
+			return nil
+		}
+		c.self.Running++
+		c.self.WaitingAt = time.Time{}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		return fmt.Errorf("%w: %s", ErrNoSlot, reason)
+	}
+	return nil
+}
+
+// Release gives back a slot taken by Acquire
+func (c *Coordinator) Release() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.self.Running > 0 {
+		c.self.Running--
+	}
+	if c.yielding > 0 {
+		c.yielding--
+	}
+	c.locked(func(time.Time, []State) error { return nil })
+}
+
+// SetPreempt sets the function stopping n running agents of this
+// repository, which returns how many it stopped. The heartbeat calls it
+// while higher-priority repositories wait for the slots they hold; without
+// it, running agents are left to finish.
+func (c *Coordinator) SetPreempt(fn func(n int) int) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.preempt = fn
+}
+
+// heartbeat refreshes the state file and stops the agents owed to
+// higher-priority repositories
+func (c *Coordinator) heartbeat() {
+	c.mu.Lock()
+	n := 0
+	c.locked(func(now time.Time, others []State) error {
+		if c.preempt != nil {
+			n = Preempt(others, c.self, c.max, now) - c.yielding
+		}
+		return nil
+	})
+	if n <= 0 {
+		c.mu.Unlock()
+		return
+	}
+	c.yielding += n
+	preempt := c.preempt
+	c.mu.Unlock()
+
+	// Stopping agents takes the runner's lock, which is held while
+	// releasing slots, so it can't run under c.mu
+	if stopped := preempt(n); stopped < n {
+		c.mu.Lock()
+		c.yielding -= n - stopped
+		c.mu.Unlock()
+	}
+}
+
+// Start refreshes the state file every interval until Close, so other loops
+// know this one is alive, and preempts agents when higher-priority
+// repositories wait
+func (c *Coordinator) Start(interval time.Duration) {
+	if c == nil {
+		return
+	}
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+	go func() {
+		defer close(c.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+				c.heartbeat()
+			}
+		}
+	}()
+}
+
+// Close stops the heartbeat and removes this repository from the directory
+func (c *Coordinator) Close() {
+	if c == nil {
+		return
+	}
+	if c.stop != nil {
+		close(c.stop)
+		<-c.done
+		c.stop = nil
+	}
+	os.Remove(c.path())
+}
+
+// States returns the live states of all repositories, this one included
+func (c *Coordinator) States() ([]State, error) {
+	if c == nil {
+		return nil, nil
+	}
+	return readStates(c.dir, time.Now())
+}
+
+// locked runs fn with the directory lock held and the live states of the
+// other repositories, then writes this repository's state. Must be called
+// with c.mu held.
+func (c *Coordinator) locked(fn func(now time.Time, others []State) error) error {
+	unlock, err := atomicfile.LockFile(filepath.Join(c.dir, lockName))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	now := time.Now()
+	states, err := readStates(c.dir, now)
+	if err != nil {
+		return err
+	}
+	var others []State
+	for _, s := range states {
+		if s.Repo != c.self.Repo {
+			others = append(others, s)
+		}
+	}
+	if err := fn(now, others); err != nil {
+		return err
+	}
+	c.self.Updated = now
+	data, err := json.MarshalIndent(c.self, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path(), data, 0644)
+}
+
+// readStates reads the state files that had a heartbeat recently, deleting
+// the stale ones
+func readStates(dir string, now time.Time) ([]State, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var states []State
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		var s State
+		if json.Unmarshal(data, &s) != nil {
+			continue
+		}
+		if now.Sub(s.Updated) > StaleAfter {
+			os.Remove(f)
+			continue
+		}
+		states = append(states, s)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Repo < states[j].Repo })
+	return states, nil
+}
+
+// Describe summarizes the states for logging
+func Describe(states []State, max int) string {
+	total := 0
+	var parts []string
+	for _, s := range states {
+		total += s.Running
+		parts = append(parts, fmt.Sprintf("%s: %d running (priority %d, weight %g)", filepath.Base(s.Repo), s.Running, s.Priority, s.weight()))
+	}
+	return fmt.Sprintf("%d/%d global agent slots in use; %s", total, max, strings.Join(parts, ", "))
+}
@@ -0,0 +1,141 @@
+package coord
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAdmit(t *testing.T) {
+	now := time.Date(2025, 1, 8, 12, 0, 0, 0, time.UTC)
+	waiting := now.Add(-5 * time.Second)
+	tests := []struct {
+		name     string
+		others   []State
+		me       State
+		max      int
+		expected bool
+	}{
+		{"alone", nil, State{Repo: "a"}, 2, true},
+		{"limit reached", []State{{Repo: "b", Running: 2}}, State{Repo: "a", Running: 1}, 3, false},
+		{"yield to higher priority", []State{{Repo: "b", Priority: 1, WaitingAt: waiting}}, State{Repo: "a"}, 4, false},
+		{"higher priority no longer waiting", []State{{Repo: "b", Priority: 1, WaitingAt: now.Add(-time.Minute)}}, State{Repo: "a"}, 4, true},
+		{"lower priority waiting", []State{{Repo: "b", Priority: -1, WaitingAt: waiting}}, State{Repo: "a", Running: 3}, 4, true},
+		{"at share while peer waits", []State{{Repo: "b", WaitingAt: waiting}}, State{Repo: "a", Running: 2}, 4, false},
+		{"below share", []State{{Repo: "b", Running: 1, WaitingAt: waiting}}, State{Repo: "a", Running: 1}, 4, true},
+		{"over share while peer idle", []State{{Repo: "b"}}, State{Repo: "a", Running: 3}, 4, true},
+		{"weighted share", []State{{Repo: "b", Weight: 1, WaitingAt: waiting}}, State{Repo: "a", Weight: 3, Running: 2}, 4, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := Admit(tt.others, tt.me, tt.max, now)
+			if got != tt.expected {
+				t.Errorf("Admit() = %v (%s), want %v", got, reason, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPreempt(t *testing.T) {
+	now := time.Date(2025, 1, 8, 12, 0, 0, 0, time.UTC)
+	waiting := now.Add(-5 * time.Second)
+	tests := []struct {
+		name     string
+		others   []State
+		me       State
+		max      int
+		expected int
+	}{
+		{"nobody waiting", []State{{Repo: "b", Running: 1}}, State{Repo: "a", Running: 3}, 4, 0},
+		{"free slot", []State{{Repo: "b", Priority: 1, WaitingAt: waiting}}, State{Repo: "a", Running: 3}, 4, 0},
+		{"higher priority waiting", []State{{Repo: "b", Priority: 1, WaitingAt: waiting}}, State{Repo: "a", Running: 4}, 4, 1},
+		{"same priority waiting", []State{{Repo: "b", WaitingAt: waiting}}, State{Repo: "a", Running: 4}, 4, 0},
+		{"lower priority stops first", []State{{Repo: "b", Priority: 2, WaitingAt: waiting}, {Repo: "c", Priority: -1, Running: 1}}, State{Repo: "a", Running: 3}, 4, 0},
+		{"most agents stop first", []State{{Repo: "b", Priority: 1, WaitingAt: waiting}, {Repo: "c", Running: 1}}, State{Repo: "a", Running: 3}, 4, 1},
+		{"a slot per waiting repository", []State{{Repo: "b", Priority: 1, WaitingAt: waiting}, {Repo: "c", Priority: 1, WaitingAt: waiting}, {Repo: "d", Running: 1}}, State{Repo: "a", Running: 3}, 4, 2},
+		{"waiting expired", []State{{Repo: "b", Priority: 1, WaitingAt: now.Add(-time.Minute)}}, State{Repo: "a", Running: 4}, 4, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Preempt(tt.others, tt.me, tt.max, now); got != tt.expected {
+				t.Errorf("Preempt() = %d, want %d", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCoordinator(t *testing.T) {
+	dir := t.TempDir()
+	low, err := New(dir, "/repos/low", 1, 0, 1)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	high, _ := New(dir, "/repos/high", 1, 1, 1)
+
+	if err := low.Acquire(); err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if err := high.Acquire(); !errors.Is(err, ErrNoSlot) {
+		t.Fatalf("Expected the global limit to hold across coordinators, got %v", err)
+	}
+	low.Release()
+	if err := low.Acquire(); !errors.Is(err, ErrNoSlot) {
+		t.Errorf("Expected the low-priority repo to yield to the waiting one, got %v", err)
+	}
+	if err := high.Acquire(); err != nil {
+		t.Errorf("Expected the freed slot to go to the high-priority repo, got %v", err)
+	}
+
+	high.Close()
+	states, _ := low.States()
+	if len(states) != 1 || states[0].Repo != "/repos/low" {
+		t.Errorf("Expected Close to remove the state file, got %+v", states)
+	}
+
+	var none *Coordinator
+	if err := none.Acquire(); err != nil {
+		t.Errorf("Expected a nil coordinator to impose no limit, got %v", err)
+	}
+	none.Release()
+	none.Close()
+}
+
+func TestCoordinatorPreempts(t *testing.T) {
+	dir := t.TempDir()
+	low, err := New(dir, "/repos/low", 2, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	high, _ := New(dir, "/repos/high", 2, 1, 1)
+	var asked []int
+	low.SetPreempt(func(n int) int {
+		asked = append(asked, n)
+		return n
+	})
+	low.Acquire()
+	low.Acquire()
+
+	low.heartbeat()
+	if len(asked) != 0 {
+		t.Fatalf("Expected no preemption while nobody waits, got %v", asked)
+	}
+	if err := high.Acquire(); !errors.Is(err, ErrNoSlot) {
+		t.Fatalf("Expected the global limit to hold, got %v", err)
+	}
+	low.heartbeat()
+	if len(asked) != 1 || asked[0] != 1 {
+		t.Fatalf("Expected the low-priority repo to stop one agent, got %v", asked)
+	}
+	// The stopped agent still holds its slot until it has ended
+	low.heartbeat()
+	if len(asked) != 1 {
+		t.Errorf("Expected no second agent stopped for the same wait, got %v", asked)
+	}
+	low.Release()
+	if err := low.Acquire(); !errors.Is(err, ErrNoSlot) {
+		t.Errorf("Expected the low-priority repo to leave the slot to the waiting one, got %v", err)
+	}
+	if err := high.Acquire(); err != nil {
+		t.Errorf("Expected the freed slot to go to the high-priority repo, got %v", err)
+	}
+}
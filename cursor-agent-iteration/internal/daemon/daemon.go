@@ -0,0 +1,141 @@
+// Package daemon supervises the child processes of cursor-iter serve: the
+// iterate loop, which is started and paused on request, and a queue of jobs
+// such as feature requests that run one at a time
+package daemon
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Process states
+const (
+	StateStopped = "stopped"
+	StateRunning = "running"
+	// StatePausing is a process asked to stop that hasn't exited yet, e.g.
+	// a loop waiting for its running agents
+	StatePausing = "pausing"
+)
+
+var (
+	ErrRunning    = errors.New("already running")
+	ErrNotRunning = errors.New("not running")
+)
+
+// ProcessStatus describes a supervised process
+type ProcessStatus struct {
+	State     string     `json:"state"` // stopped, running or pausing
+	PID       int        `json:"pid,omitempty"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	// ExitedAt and LastExit describe the last run, once it has exited
+	ExitedAt *time.Time `json:"exited_at,omitempty"`
+	LastExit string     `json:"last_exit,omitempty"` // e.g. "exit status 130"
+	Runs     int        `json:"runs"`                // how often the process was started
+}
+
+// Process runs a command on request, one instance at a time
+type Process struct {
+	mu      sync.Mutex
+	command func() *exec.Cmd
+	output  io.Writer
+	cmd     *exec.Cmd
+	status  ProcessStatus
+	exited  chan struct{}
+	// onExit is called after each run ends, with the error from Wait
+	onExit func(error)
+}
+
+// NewProcess returns a stopped Process that runs the commands made by
+// command, writing their stdout and stderr to output
+func NewProcess(command func() *exec.Cmd, output io.Writer) *Process {
+	return &Process{command: command, output: output, status: ProcessStatus{State: StateStopped}}
+}
+
+// OnExit sets a function called after each run ends
+func (p *Process) OnExit(f func(error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onExit = f
+}
+
+// Start starts the command, or returns ErrRunning while it runs
+func (p *Process) Start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd != nil {
+		return ErrRunning
+	}
+	cmd := p.command()
+	cmd.Stdout, cmd.Stderr = p.output, p.output
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	now := time.Now()
+	p.cmd = cmd
+	p.exited = make(chan struct{})
+	p.status = ProcessStatus{State: StateRunning, PID: cmd.Process.Pid, StartedAt: &now, Runs: p.status.Runs + 1}
+	go p.wait(cmd, p.exited)
+	return nil
+}
+
+func (p *Process) wait(cmd *exec.Cmd, exited chan struct{}) {
+	err := cmd.Wait()
+	now := time.Now()
+	p.mu.Lock()
+	p.cmd = nil
+	p.status.State = StateStopped
+	p.status.PID = 0
+	p.status.ExitedAt = &now
+	p.status.LastExit = "exit status 0"
+	if err != nil {
+		p.status.LastExit = err.Error()
+	}
+	onExit := p.onExit
+	p.mu.Unlock()
+	close(exited)
+	if onExit != nil {
+		onExit(err)
+	}
+}
+
+// Pause interrupts the command, as Ctrl-C would, so it can wind down: the
+// iterate loop starts no new tasks and lets running agents finish. Pausing
+// again interrupts it again, which stops the loop's agents right away.
+func (p *Process) Pause() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd == nil {
+		return ErrNotRunning
+	}
+	if err := p.cmd.Process.Signal(os.Interrupt); err != nil {
+		// Platforms without interrupts can only kill
+		if err := p.cmd.Process.Kill(); err != nil {
+			return err
+		}
+	}
+	p.status.State = StatePausing
+	return nil
+}
+
+// Wait blocks until the running command, if any, has exited
+func (p *Process) Wait() {
+	p.mu.Lock()
+	exited := p.exited
+	running := p.cmd != nil
+	p.mu.Unlock()
+	if running {
+		<-exited
+	}
+}
+
+// Status returns the state of the process
+func (p *Process) Status() ProcessStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status
+}
@@ -0,0 +1,125 @@
+package daemon
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe for the process's output goroutines
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestProcessStartPause(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh and interrupts")
+	}
+	var out syncBuffer
+	p := NewProcess(func() *exec.Cmd {
+		return exec.Command("sh", "-c", `trap 'echo paused; exit 130' INT; echo started; while :; do sleep 0.05; done`)
+	}, &out)
+	exits := make(chan error, 1)
+	p.OnExit(func(err error) { exits <- err })
+
+	if err := p.Pause(); !errors.Is(err, ErrNotRunning) {
+		t.Fatalf("Pause() before Start = %v, want ErrNotRunning", err)
+	}
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Start(); !errors.Is(err, ErrRunning) {
+		t.Fatalf("second Start() = %v, want ErrRunning", err)
+	}
+	if s := p.Status(); s.State != StateRunning || s.PID == 0 || s.Runs != 1 {
+		t.Fatalf("status = %+v", s)
+	}
+	// Give the shell time to install its trap
+	for i := 0; i < 100 && out.String() == ""; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := p.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-exits:
+		if err == nil {
+			t.Error("expected a non-zero exit")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("process did not exit after Pause")
+	}
+	p.Wait()
+	if s := p.Status(); s.State != StateStopped || s.PID != 0 || s.LastExit != "exit status 130" || s.ExitedAt == nil {
+		t.Errorf("status after exit = %+v", s)
+	}
+	if got := out.String(); got != "started\npaused\n" {
+		t.Errorf("output = %q", got)
+	}
+
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if s := p.Status(); s.Runs != 2 || s.LastExit != "" {
+		t.Errorf("status after restart = %+v", s)
+	}
+	p.Pause()
+	p.Wait()
+}
+
+func TestQueue(t *testing.T) {
+	release := make(chan struct{})
+	var ran []string
+	q := NewQueue(2, func(j Job) error {
+		<-release
+		ran = append(ran, j.Description)
+		if j.Description == "bad" {
+			return errors.New("boom")
+		}
+		return nil
+	})
+
+	first, ok := q.Enqueue("feature", "good")
+	if !ok || first.ID != 1 || first.State != JobQueued {
+		t.Fatalf("Enqueue() = %+v, %v", first, ok)
+	}
+	// Wait for the worker to take the first job, leaving room for two more
+	for q.Jobs()[0].State != JobRunning {
+		time.Sleep(time.Millisecond)
+	}
+	q.Enqueue("feature", "bad")
+	q.Enqueue("feature", "third")
+	if _, ok := q.Enqueue("feature", "overflow"); ok {
+		t.Error("expected a full queue to refuse jobs")
+	}
+	close(release)
+	q.Close()
+
+	jobs := q.Jobs()
+	if len(jobs) != 3 || len(ran) != 3 || ran[1] != "bad" {
+		t.Fatalf("jobs = %+v, ran = %v", jobs, ran)
+	}
+	if jobs[0].State != JobDone || jobs[0].StartedAt == nil || jobs[0].FinishedAt == nil {
+		t.Errorf("first job = %+v", jobs[0])
+	}
+	if jobs[1].State != JobFailed || jobs[1].Error != "boom" {
+		t.Errorf("failed job = %+v", jobs[1])
+	}
+}
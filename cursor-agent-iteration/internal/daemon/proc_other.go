@@ -0,0 +1,8 @@
+//go:build !unix
+
+package daemon
+
+import "os/exec"
+
+// detach is a no-op where process groups aren't available
+func detach(cmd *exec.Cmd) {}
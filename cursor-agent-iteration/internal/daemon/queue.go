@@ -0,0 +1,98 @@
+package daemon
+
+import (
+	"sync"
+	"time"
+)
+
+// Job states
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// Job is a queued request, such as a feature to add to the backlog
+type Job struct {
+	ID          int        `json:"id"`
+	Kind        string     `json:"kind"` // e.g. "feature"
+	Description string     `json:"description"`
+	State       string     `json:"state"` // queued, running, done or failed
+	QueuedAt    time.Time  `json:"queued_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// Queue runs jobs one at a time, in the order they were enqueued
+type Queue struct {
+	mu      sync.Mutex
+	jobs    []*Job
+	pending chan *Job
+	run     func(Job) error
+	stopped chan struct{}
+}
+
+// NewQueue starts a worker that runs each enqueued job with run. At most
+// capacity jobs may wait.
+func NewQueue(capacity int, run func(Job) error) *Queue {
+	q := &Queue{pending: make(chan *Job, capacity), run: run, stopped: make(chan struct{})}
+	go q.work()
+	return q
+}
+
+func (q *Queue) work() {
+	defer close(q.stopped)
+	for job := range q.pending {
+		q.mu.Lock()
+		now := time.Now()
+		job.State = JobRunning
+		job.StartedAt = &now
+		snapshot := *job
+		q.mu.Unlock()
+
+		err := q.run(snapshot)
+
+		q.mu.Lock()
+		now = time.Now()
+		job.FinishedAt = &now
+		job.State = JobDone
+		if err != nil {
+			job.State = JobFailed
+			job.Error = err.Error()
+		}
+		q.mu.Unlock()
+	}
+}
+
+// Enqueue adds a job, returning it, or false when the queue is full
+func (q *Queue) Enqueue(kind, description string) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job := &Job{ID: len(q.jobs) + 1, Kind: kind, Description: description, State: JobQueued, QueuedAt: time.Now()}
+	select {
+	case q.pending <- job:
+	default:
+		return Job{}, false
+	}
+	q.jobs = append(q.jobs, job)
+	return *job, true
+}
+
+// Jobs returns every job enqueued so far, oldest first
+func (q *Queue) Jobs() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs := make([]Job, len(q.jobs))
+	for i, j := range q.jobs {
+		jobs[i] = *j
+	}
+	return jobs
+}
+
+// Close stops taking jobs and waits for the queued ones to finish
+func (q *Queue) Close() {
+	close(q.pending)
+	<-q.stopped
+}
@@ -0,0 +1,286 @@
+// Package deps finds outdated dependencies in go.mod, package.json and
+// requirements.txt and turns the safe upgrades into tasks, so routine
+// maintenance goes through the same pipeline as feature work
+package deps
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// Ecosystems
+const (
+	Go     = "go"
+	Npm    = "npm"
+	Python = "python"
+)
+
+// Update is an available upgrade of one dependency
+type Update struct {
+	Ecosystem string
+	Name      string
+	Current   string
+	Latest    string   // the version to upgrade to
+	Files     []string // manifest and lock files the upgrade touches
+}
+
+// Safe reports whether the upgrade stays within the current major version
+// (or minor version for 0.x releases) and is not a pre-release
+func (u Update) Safe() bool {
+	return IsSafe(u.Current, u.Latest)
+}
+
+// Runner runs a command in dir and returns its standard output
+type Runner func(dir string, name string, args ...string) ([]byte, error)
+
+// Exec is a Runner backed by os/exec. Output is returned even when the
+// command exits non-zero, as `npm outdated` does when it finds updates.
+func Exec(dir string, name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(out) > 0 {
+		return out, nil
+	}
+	return out, err
+}
+
+// Scan checks every manifest found in dir for outdated dependencies.
+// Ecosystems whose tooling fails are reported in errs and skipped.
+func Scan(dir string, run Runner) (updates []Update, errs []error) {
+	if exists(dir, "go.mod") {
+		out, err := run(dir, "go", "list", "-m", "-u", "-json", "all")
+		if err == nil {
+			var found []Update
+			found, err = ParseGoList(out)
+			updates = append(updates, withFiles(found, dir, "go.mod", "go.sum")...)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("go.mod: %v", err))
+		}
+	}
+	if exists(dir, "package.json") {
+		out, err := run(dir, "npm", "outdated", "--json")
+		if err == nil {
+			var found []Update
+			found, err = ParseNpmOutdated(out)
+			updates = append(updates, withFiles(found, dir, "package.json", "package-lock.json", "yarn.lock", "pnpm-lock.yaml")...)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("package.json: %v", err))
+		}
+	}
+	if exists(dir, "requirements.txt") {
+		requirements, err := os.ReadFile(filepath.Join(dir, "requirements.txt"))
+		var out []byte
+		if err == nil {
+			out, err = run(dir, "pip", "list", "--outdated", "--format=json")
+		}
+		if err == nil {
+			var found []Update
+			found, err = ParsePipOutdated(out, string(requirements))
+			updates = append(updates, withFiles(found, dir, "requirements.txt")...)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("requirements.txt: %v", err))
+		}
+	}
+	return updates, errs
+}
+
+func exists(dir, name string) bool {
+	_, err := os.Stat(filepath.Join(dir, name))
+	return err == nil
+}
+
+// withFiles sets the files of each update to those of names present in dir
+func withFiles(updates []Update, dir string, names ...string) []Update {
+	var files []string
+	for _, name := range names {
+		if exists(dir, name) {
+			files = append(files, name)
+		}
+	}
+	for i := range updates {
+		updates[i].Files = files
+	}
+	return updates
+}
+
+// ParseGoList reads the output of `go list -m -u -json all`, returning the
+// direct dependencies that have an update
+func ParseGoList(data []byte) ([]Update, error) {
+	var updates []Update
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var m struct {
+			Path     string
+			Version  string
+			Main     bool
+			Indirect bool
+			Update   *struct{ Version string }
+		}
+		if err := dec.Decode(&m); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if m.Main || m.Indirect || m.Update == nil {
+			continue
+		}
+		updates = append(updates, Update{Ecosystem: Go, Name: m.Path, Current: m.Version, Latest: m.Update.Version})
+	}
+	return updates, nil
+}
+
+// ParseNpmOutdated reads the output of `npm outdated --json`. The latest
+// release is used when it is a safe upgrade, otherwise the newest version
+// the package.json range allows.
+func ParseNpmOutdated(data []byte) ([]Update, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	var outdated map[string]struct {
+		Current string `json:"current"`
+		Wanted  string `json:"wanted"`
+		Latest  string `json:"latest"`
+	}
+	if err := json.Unmarshal(data, &outdated); err != nil {
+		return nil, err
+	}
+	var updates []Update
+	for name, o := range outdated {
+		if o.Current == "" {
+			// Not installed; nothing to upgrade from
+			continue
+		}
+		target := o.Latest
+		if !IsSafe(o.Current, target) && o.Wanted != o.Current {
+			target = o.Wanted
+		}
+		if target == o.Current {
+			continue
+		}
+		updates = append(updates, Update{Ecosystem: Npm, Name: name, Current: o.Current, Latest: target})
+	}
+	sort.Slice(updates, func(i, j int) bool { return updates[i].Name < updates[j].Name })
+	return updates, nil
+}
+
+// reRequirement matches the package name at the start of a requirements line
+var reRequirement = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)`)
+
+// ParsePipOutdated reads the output of `pip list --outdated --format=json`,
+// keeping the packages listed in requirements.txt
+func ParsePipOutdated(data []byte, requirements string) ([]Update, error) {
+	required := make(map[string]bool)
+	scanner := bufio.NewScanner(strings.NewReader(requirements))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "-") {
+			continue
+		}
+		if m := reRequirement.FindString(line); m != "" {
+			required[normalizePython(m)] = true
+		}
+	}
+	var outdated []struct {
+		Name          string `json:"name"`
+		Version       string `json:"version"`
+		LatestVersion string `json:"latest_version"`
+	}
+	if err := json.Unmarshal(data, &outdated); err != nil {
+		return nil, err
+	}
+	var updates []Update
+	for _, o := range outdated {
+		if required[normalizePython(o.Name)] {
+			updates = append(updates, Update{Ecosystem: Python, Name: o.Name, Current: o.Version, Latest: o.LatestVersion})
+		}
+	}
+	return updates, nil
+}
+
+// normalizePython normalizes a package name the way pip compares them
+func normalizePython(name string) string {
+	return strings.ToLower(strings.NewReplacer("_", "-", ".", "-").Replace(name))
+}
+
+// IsSafe reports whether upgrading from current to target keeps the major
+// version (and the minor version below 1.0) and avoids pre-releases
+func IsSafe(current string, target string) bool {
+	cur, ok1 := parseVersion(current)
+	tgt, ok2 := parseVersion(target)
+	if !ok1 || !ok2 || tgt.pre {
+		return false
+	}
+	if cur.major != tgt.major {
+		return false
+	}
+	return cur.major != 0 || cur.minor == tgt.minor
+}
+
+type version struct {
+	major, minor int
+	pre          bool
+}
+
+// parseVersion reads the major and minor numbers of a version such as
+// v1.2.3, 1.2.3-rc.1 or 2.31.0
+func parseVersion(v string) (version, bool) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	v, _, _ = strings.Cut(v, "+")
+	core, pre, hasPre := strings.Cut(v, "-")
+	parts := strings.Split(core, ".")
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return version{}, false
+	}
+	minor := 0
+	if len(parts) > 1 {
+		if minor, err = strconv.Atoi(parts[1]); err != nil {
+			return version{}, false
+		}
+	}
+	return version{major: major, minor: minor, pre: hasPre && pre != ""}, true
+}
+
+// TaskTitle is the title of the task that applies an upgrade
+func TaskTitle(u Update) string {
+	return fmt.Sprintf("Update %s to %s", u.Name, u.Latest)
+}
+
+// TaskBlock is the tasks.md block for an upgrade
+func TaskBlock(u Update) string {
+	where := "None"
+	if len(u.Files) > 0 {
+		where = strings.Join(u.Files, ", ")
+	}
+	return tasks.Draft{
+		Title:   TaskTitle(u),
+		Context: fmt.Sprintf("Routine dependency update of %s (%s) from %s to %s. The upgrade stays within the current major version, so no breaking changes are expected.", u.Name, u.Ecosystem, u.Current, u.Latest),
+		Criteria: []string{
+			fmt.Sprintf("%s is at %s in %s", u.Name, u.Latest, where),
+			"Build passes",
+			"Tests pass",
+			fmt.Sprintf("Changelog reviewed for changes between %s and %s, and any deprecations addressed", u.Current, u.Latest),
+		},
+		Files:  u.Files,
+		Tests:  "existing test suite",
+		Labels: []string{"type:maintenance", "deps:" + u.Ecosystem},
+	}.Block()
+}
@@ -0,0 +1,89 @@
+package deps
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIsSafe(t *testing.T) {
+	tests := []struct {
+		current, target string
+		expected        bool
+	}{
+		{"v1.2.3", "v1.4.0", true},
+		{"v1.2.3", "v2.0.0", false},
+		{"0.3.1", "0.3.9", true},
+		{"0.3.1", "0.4.0", false},
+		{"2.31.0", "2.32.3", true},
+		{"1.0.0", "1.1.0-rc.1", false},
+		{"v0.0.0-20240101000000-abcdef123456", "v0.0.1", true},
+		{"latest", "1.0.0", false},
+	}
+	for _, tt := range tests {
+		if got := IsSafe(tt.current, tt.target); got != tt.expected {
+			t.Errorf("IsSafe(%q, %q) = %v, want %v", tt.current, tt.target, got, tt.expected)
+		}
+	}
+}
+
+func TestParsers(t *testing.T) {
+	goList := `{"Path": "example.com/app", "Main": true}
+{"Path": "github.com/a/b", "Version": "v1.2.0", "Update": {"Version": "v1.3.0"}}
+{"Path": "github.com/c/d", "Version": "v0.1.0", "Indirect": true, "Update": {"Version": "v0.2.0"}}
+{"Path": "github.com/e/f", "Version": "v1.0.0"}
+`
+	got, err := ParseGoList([]byte(goList))
+	expected := []Update{{Ecosystem: Go, Name: "github.com/a/b", Current: "v1.2.0", Latest: "v1.3.0"}}
+	if err != nil || !reflect.DeepEqual(got, expected) {
+		t.Errorf("ParseGoList() = %+v, %v", got, err)
+	}
+
+	npm := `{
+  "react": {"current": "18.2.0", "wanted": "18.3.1", "latest": "19.0.0"},
+  "lodash": {"current": "4.17.20", "wanted": "4.17.21", "latest": "4.17.21"},
+  "left-pad": {"wanted": "1.3.0", "latest": "1.3.0"}
+}`
+	got, err = ParseNpmOutdated([]byte(npm))
+	expected = []Update{
+		{Ecosystem: Npm, Name: "lodash", Current: "4.17.20", Latest: "4.17.21"},
+		{Ecosystem: Npm, Name: "react", Current: "18.2.0", Latest: "18.3.1"},
+	}
+	if err != nil || !reflect.DeepEqual(got, expected) {
+		t.Errorf("ParseNpmOutdated() = %+v, %v", got, err)
+	}
+
+	pip := `[{"name": "Requests", "version": "2.28.0", "latest_version": "2.32.3"}, {"name": "pip", "version": "23.0", "latest_version": "24.0"}]`
+	got, err = ParsePipOutdated([]byte(pip), "# web\nrequests>=2.0\n-r dev.txt\n")
+	expected = []Update{{Ecosystem: Python, Name: "Requests", Current: "2.28.0", Latest: "2.32.3"}}
+	if err != nil || !reflect.DeepEqual(got, expected) {
+		t.Errorf("ParsePipOutdated() = %+v, %v", got, err)
+	}
+}
+
+func TestScan(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module x\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "go.sum"), nil, 0644)
+	var commands []string
+	run := func(dir string, name string, args ...string) ([]byte, error) {
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		return []byte(`{"Path": "github.com/a/b", "Version": "v1.2.0", "Update": {"Version": "v1.3.0"}}`), nil
+	}
+	updates, errs := Scan(dir, run)
+	if len(errs) != 0 || len(updates) != 1 || !reflect.DeepEqual(updates[0].Files, []string{"go.mod", "go.sum"}) {
+		t.Fatalf("Scan() = %+v, %v", updates, errs)
+	}
+	if !reflect.DeepEqual(commands, []string{"go list -m -u -json all"}) {
+		t.Errorf("Expected only the go tooling to run, got %v", commands)
+	}
+
+	block := TaskBlock(updates[0])
+	for _, want := range []string{"### Task: Update github.com/a/b to v1.3.0", "* [ ] Build passes", "* [ ] Tests pass", "**Files to Modify:** `go.mod`, `go.sum`", "[deps:go]"} {
+		if !strings.Contains(block, want) {
+			t.Errorf("TaskBlock() missing %q:\n%s", want, block)
+		}
+	}
+}
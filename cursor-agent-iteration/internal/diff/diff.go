@@ -0,0 +1,204 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OpKind is the kind of a line-level edit
+type OpKind int
+
+const (
+	Equal OpKind = iota
+	Delete
+	Insert
+)
+
+// Op is one line of an edit script
+type Op struct {
+	Kind OpKind
+	Line string
+}
+
+// Lines computes a minimal line edit script turning a into b using Myers'
+// O(ND) algorithm
+func Lines(a, b []string) []Op {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+	offset := max
+	v := make([]int, 2*max+1)
+	var trace [][]int
+
+	for d := 0; d <= max; d++ {
+		snapshot := make([]int, len(v))
+		copy(snapshot, v)
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b, offset)
+			}
+		}
+	}
+	return backtrack(trace, a, b, offset)
+}
+
+// backtrack walks the recorded V arrays backwards to build the edit script
+func backtrack(trace [][]int, a, b []string, offset int) []Op {
+	x, y := len(a), len(b)
+	var ops []Op
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, Op{Kind: Equal, Line: a[x]})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				ops = append(ops, Op{Kind: Insert, Line: b[y]})
+			} else {
+				x--
+				ops = append(ops, Op{Kind: Delete, Line: a[x]})
+			}
+		}
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// Unified renders a unified diff between two texts with the given number of
+// context lines. It returns an empty string when the texts are identical.
+func Unified(aName, bName, a, b string, context int) string {
+	if a == b {
+		return ""
+	}
+	ops := Lines(splitLines(a), splitLines(b))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+
+	// Walk the script, emitting hunks around each run of changes
+	aLine, bLine := 0, 0
+	i := 0
+	for i < len(ops) {
+		if ops[i].Kind == Equal {
+			i++
+			aLine++
+			bLine++
+			continue
+		}
+
+		// Start a hunk with up to `context` leading equal lines
+		start := i
+		lead := 0
+		for start > 0 && ops[start-1].Kind == Equal && lead < context {
+			start--
+			lead++
+		}
+		hunkA, hunkB := aLine-lead, bLine-lead
+
+		// Extend the hunk until a run of more than 2*context equal lines
+		end := i
+		for end < len(ops) {
+			if ops[end].Kind != Equal {
+				end++
+				continue
+			}
+			run := 0
+			for end+run < len(ops) && ops[end+run].Kind == Equal {
+				run++
+			}
+			if end+run >= len(ops) || run > 2*context {
+				if run > context {
+					run = context
+				}
+				end += run
+				break
+			}
+			end += run
+		}
+
+		var body strings.Builder
+		countA, countB := 0, 0
+		for _, op := range ops[start:end] {
+			switch op.Kind {
+			case Equal:
+				body.WriteString(" " + op.Line + "\n")
+				countA++
+				countB++
+			case Delete:
+				body.WriteString("-" + op.Line + "\n")
+				countA++
+			case Insert:
+				body.WriteString("+" + op.Line + "\n")
+				countB++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(hunkA, countA), hunkRange(hunkB, countB))
+		out.WriteString(body.String())
+
+		// Advance line counters past the hunk
+		for _, op := range ops[i:end] {
+			if op.Kind != Insert {
+				aLine++
+			}
+			if op.Kind != Delete {
+				bLine++
+			}
+		}
+		i = end
+	}
+	return out.String()
+}
+
+// hunkRange formats a hunk range header in unified diff notation
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// splitLines splits text into lines, dropping the empty element after a
+// trailing newline
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.Split(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,99 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+// apply replays an edit script to verify it transforms a into b
+func apply(ops []Op) (a, b []string) {
+	for _, op := range ops {
+		if op.Kind != Insert {
+			a = append(a, op.Line)
+		}
+		if op.Kind != Delete {
+			b = append(b, op.Line)
+		}
+	}
+	return a, b
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name      string
+		a, b      []string
+		wantEdits int
+	}{
+		{"identical", []string{"a", "b"}, []string{"a", "b"}, 0},
+		{"both empty", nil, nil, 0},
+		{"insert only", nil, []string{"x", "y"}, 2},
+		{"delete only", []string{"x", "y"}, nil, 2},
+		{"replace middle", []string{"a", "b", "c"}, []string{"a", "x", "c"}, 2},
+		{"classic", strings.Split("ABCABBA", ""), strings.Split("CBABAC", ""), 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops := Lines(tt.a, tt.b)
+			gotA, gotB := apply(ops)
+			if strings.Join(gotA, "\n") != strings.Join(tt.a, "\n") || strings.Join(gotB, "\n") != strings.Join(tt.b, "\n") {
+				t.Fatalf("edit script does not reproduce inputs: %+v", ops)
+			}
+			edits := 0
+			for _, op := range ops {
+				if op.Kind != Equal {
+					edits++
+				}
+			}
+			if edits != tt.wantEdits {
+				t.Errorf("edits = %d, want %d", edits, tt.wantEdits)
+			}
+		})
+	}
+}
+
+func TestUnified(t *testing.T) {
+	a := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	b := "one\nTWO\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven\n"
+
+	got := Unified("a/tasks.md", "b/tasks.md", a, b, 2)
+	want := `--- a/tasks.md
++++ b/tasks.md
+@@ -1,4 +1,4 @@
+ one
+-two
++TWO
+ three
+ four
+@@ -9,2 +9,3 @@
+ nine
+ ten
++eleven
+`
+	if got != want {
+		t.Errorf("Unified() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestUnifiedMergesCloseHunks(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n"
+	b := "1\nX\n3\nY\n5\n"
+	got := Unified("a", "b", a, b, 1)
+	if strings.Count(got, "@@ -") != 1 {
+		t.Errorf("Expected a single merged hunk, got:\n%s", got)
+	}
+}
+
+func TestUnifiedNewFile(t *testing.T) {
+	got := Unified("/dev/null", "b/new.md", "", "hello\n", 3)
+	want := "--- /dev/null\n+++ b/new.md\n@@ -0,0 +1 @@\n+hello\n"
+	if got != want {
+		t.Errorf("Unified() = %q, want %q", got, want)
+	}
+}
+
+func TestUnifiedIdentical(t *testing.T) {
+	if got := Unified("a", "b", "same\n", "same\n", 3); got != "" {
+		t.Errorf("Expected empty diff for identical input, got %q", got)
+	}
+}
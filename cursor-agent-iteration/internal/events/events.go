@@ -0,0 +1,226 @@
+// Package events publishes structured events about a running backlog, such
+// as task starts and completions, acceptance criteria updates and agent log
+// excerpts, to subscribers such as the WebSocket endpoint of the serve API
+package events
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// Event types. Subscribers filter on them, by full type or by the part
+// before the dot, e.g. "task" for every task event.
+const (
+	TaskStarted     = "task.started"   // a task went in progress
+	TaskCompleted   = "task.completed" // a task was marked completed
+	TaskBlocked     = "task.blocked"   // a task was moved to Blocked
+	TaskFailed      = "task.failed"    // a task was moved to Failed
+	TaskReopened    = "task.reopened"  // a task went back to pending
+	TaskAdded       = "task.added"     // a task appeared in tasks.md
+	TaskRemoved     = "task.removed"   // a task left tasks.md
+	CriteriaUpdated = "criteria.updated"
+	Log             = "log"          // an excerpt of an agent's output
+	LoopStarted     = "loop.started" // serve started the iterate loop
+	LoopPaused      = "loop.paused"  // serve asked the iterate loop to stop
+	LoopStopped     = "loop.stopped" // the iterate loop exited; Text is its exit status
+)
+
+// MaxExcerpt is the most log text an event carries; longer output keeps its
+// end
+const MaxExcerpt = 4096
+
+// Event is one structured event
+type Event struct {
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	Task      string    `json:"task,omitempty"`
+	Status    string    `json:"status,omitempty"`     // the task's status after the event
+	ACChecked int       `json:"ac_checked,omitempty"` // checked acceptance criteria, for criteria.updated
+	ACTotal   int       `json:"ac_total,omitempty"`
+	Text      string    `json:"text,omitempty"` // the log excerpt or the task's notes
+}
+
+// LogExcerpt returns a log event for a piece of an agent's output
+func LogExcerpt(task string, text string, now time.Time) Event {
+	if len(text) > MaxExcerpt {
+		text = text[len(text)-MaxExcerpt:]
+	}
+	return Event{Type: Log, Time: now, Task: task, Text: text}
+}
+
+// Diff returns the events that turn the before status into after: status
+// changes, tasks added and removed, and acceptance criteria updates
+func Diff(before, after tasks.StatusSummary, now time.Time) []Event {
+	old := make(map[string]tasks.StatusTask, len(before.Tasks))
+	for _, t := range before.Tasks {
+		old[t.Title] = t
+	}
+	var events []Event
+	seen := make(map[string]bool, len(after.Tasks))
+	for _, t := range after.Tasks {
+		seen[t.Title] = true
+		prev, existed := old[t.Title]
+		if !existed {
+			events = append(events, Event{Type: TaskAdded, Time: now, Task: t.Title, Status: t.Status})
+		} else if prev.Status != t.Status {
+			if typ := statusEvent(t.Status); typ != "" {
+				events = append(events, Event{Type: typ, Time: now, Task: t.Title, Status: t.Status, Text: t.Notes})
+			}
+		}
+		if existed && (prev.ACChecked != t.ACChecked || prev.ACTotal != t.ACTotal) {
+			events = append(events, Event{Type: CriteriaUpdated, Time: now, Task: t.Title, Status: t.Status, ACChecked: t.ACChecked, ACTotal: t.ACTotal})
+		}
+	}
+	for _, t := range before.Tasks {
+		if !seen[t.Title] {
+			events = append(events, Event{Type: TaskRemoved, Time: now, Task: t.Title})
+		}
+	}
+	return events
+}
+
+func statusEvent(status string) string {
+	switch status {
+	case "in-progress":
+		return TaskStarted
+	case "completed":
+		return TaskCompleted
+	case "blocked":
+		return TaskBlocked
+	case "failed":
+		return TaskFailed
+	case "pending":
+		return TaskReopened
+	}
+	return ""
+}
+
+// Filter selects the events a subscriber receives. Empty lists match
+// everything.
+type Filter struct {
+	Types []string `json:"types,omitempty"` // full types or their prefix before the dot
+	Tasks []string `json:"tasks,omitempty"` // task titles, matched case-insensitively
+}
+
+// ParseFilter builds a filter from comma-separated lists, e.g. the "types"
+// and "tasks" query parameters of the WebSocket endpoint
+func ParseFilter(types, taskTitles string) Filter {
+	return Filter{Types: splitList(types), Tasks: splitList(taskTitles)}
+}
+
+// Matches reports whether e passes the filter. Events without a task only
+// pass filters without tasks.
+func (f Filter) Matches(e Event) bool {
+	if len(f.Types) > 0 {
+		ok := false
+		for _, t := range f.Types {
+			if e.Type == t || strings.HasPrefix(e.Type, t+".") {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(f.Tasks) > 0 {
+		for _, title := range f.Tasks {
+			if strings.EqualFold(e.Task, title) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// Bus fans events out to subscribers. A subscriber that falls behind loses
+// events rather than holding up the publisher; the number it lost is
+// reported by its Subscription.
+type Bus struct {
+	mu   sync.Mutex
+	subs map[*Subscription]bool
+}
+
+// NewBus returns a bus without subscribers
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]bool)}
+}
+
+// Subscription receives the events that pass its filter on C
+type Subscription struct {
+	C <-chan Event
+
+	bus     *Bus
+	c       chan Event
+	mu      sync.Mutex
+	filter  Filter
+	dropped int
+}
+
+// Subscribe starts a subscription that buffers up to buffer events
+func (b *Bus) Subscribe(filter Filter, buffer int) *Subscription {
+	c := make(chan Event, buffer)
+	s := &Subscription{C: c, bus: b, c: c, filter: filter}
+	b.mu.Lock()
+	b.subs[s] = true
+	b.mu.Unlock()
+	return s
+}
+
+// Publish sends e to every subscriber whose filter it passes
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subs {
+		s.mu.Lock()
+		if s.filter.Matches(e) {
+			select {
+			case s.c <- e:
+			default:
+				s.dropped++
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// SetFilter replaces the subscription's filter
+func (s *Subscription) SetFilter(f Filter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.filter = f
+}
+
+// Dropped returns how many events were lost because C was full
+func (s *Subscription) Dropped() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// Close ends the subscription and closes C
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if s.bus.subs[s] {
+		delete(s.bus.subs, s)
+		close(s.c)
+	}
+}
@@ -0,0 +1,87 @@
+package events
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+func TestDiff(t *testing.T) {
+	now := time.Date(2025, 1, 8, 19, 0, 0, 0, time.UTC)
+	before := tasks.StatusSummary{Tasks: []tasks.StatusTask{
+		{Title: "Login form", Status: "pending", ACTotal: 3},
+		{Title: "Signup", Status: "in-progress", ACChecked: 1, ACTotal: 2},
+		{Title: "Old task", Status: "completed"},
+	}}
+	after := tasks.StatusSummary{Tasks: []tasks.StatusTask{
+		{Title: "Login form", Status: "in-progress", ACChecked: 1, ACTotal: 3},
+		{Title: "Signup", Status: "blocked", ACChecked: 1, ACTotal: 2, Notes: "needs API key"},
+		{Title: "Search", Status: "pending"},
+	}}
+	var got []string
+	for _, e := range Diff(before, after, now) {
+		got = append(got, e.Type+" "+e.Task)
+	}
+	want := []string{
+		"task.started Login form",
+		"criteria.updated Login form",
+		"task.blocked Signup",
+		"task.added Search",
+		"task.removed Old task",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() = %v, want %v", got, want)
+	}
+	if events := Diff(after, after, now); events != nil {
+		t.Errorf("Diff(same) = %v", events)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	started := Event{Type: TaskStarted, Task: "Login form"}
+	log := Event{Type: Log, Task: "Signup"}
+	tests := []struct {
+		filter Filter
+		want   []bool
+	}{
+		{Filter{}, []bool{true, true}},
+		{ParseFilter("task", ""), []bool{true, false}},
+		{ParseFilter("task.completed, log", ""), []bool{false, true}},
+		{ParseFilter("", "login FORM"), []bool{true, false}},
+		{ParseFilter("log", "Login form"), []bool{false, false}},
+	}
+	for _, tt := range tests {
+		if got := []bool{tt.filter.Matches(started), tt.filter.Matches(log)}; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v matches %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+	all := bus.Subscribe(Filter{}, 1)
+	logs := bus.Subscribe(ParseFilter("log", ""), 4)
+
+	bus.Publish(Event{Type: TaskStarted, Task: "A"})
+	bus.Publish(LogExcerpt("A", strings.Repeat("x", MaxExcerpt+10), time.Now()))
+
+	if e := <-all.C; e.Type != TaskStarted {
+		t.Errorf("first event = %+v", e)
+	}
+	if all.Dropped() != 1 {
+		t.Errorf("Dropped() = %d; want the event that didn't fit the buffer", all.Dropped())
+	}
+	if e := <-logs.C; e.Type != Log || len(e.Text) != MaxExcerpt {
+		t.Errorf("log event = %s with %d bytes", e.Type, len(e.Text))
+	}
+
+	logs.Close()
+	if _, ok := <-logs.C; ok {
+		t.Error("Expected C closed after Close")
+	}
+	bus.Publish(Event{Type: Log}) // must not panic on the closed subscription
+	logs.Close()
+}
@@ -0,0 +1,261 @@
+package events
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// websocketGUID is appended to the client's key in the RFC 6455 handshake
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes
+const (
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xA
+)
+
+// maxMessage bounds the frames a client may send; filters are small
+const maxMessage = 64 << 10
+
+// subscriberBuffer is how many events a slow WebSocket client may fall
+// behind before it loses events
+const subscriberBuffer = 256
+
+// Handler serves the events of bus over WebSocket, one JSON text message per
+// event. The "types" and "tasks" query parameters set the initial filter,
+// e.g. /ws?types=task,criteria&tasks=Login%20form, and a client replaces it
+// by sending a Filter as a JSON text message.
+func Handler(bus *Bus) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !headerHas(r.Header, "Connection", "upgrade") || !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			http.Error(w, "expected a WebSocket upgrade", http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("Sec-WebSocket-Version") != "13" {
+			w.Header().Set("Sec-WebSocket-Version", "13")
+			http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+			return
+		}
+		if !AllowedOrigin(r) {
+			http.Error(w, "cross-origin WebSocket connections are not allowed", http.StatusForbidden)
+			return
+		}
+		key := r.Header.Get("Sec-WebSocket-Key")
+		if key == "" {
+			http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+			return
+		}
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			http.Error(w, "WebSocket not supported by this server", http.StatusInternalServerError)
+			return
+		}
+		conn, rw, err := hj.Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		// Subscribe before the handshake completes, so no event published
+		// after the client sees it is missed
+		q := r.URL.Query()
+		sub := bus.Subscribe(ParseFilter(q.Get("types"), q.Get("tasks")), subscriberBuffer)
+		defer sub.Close()
+
+		fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", acceptKey(key))
+		if err := rw.Flush(); err != nil {
+			return
+		}
+
+		var mu sync.Mutex // serializes writes from both goroutines
+		send := func(opcode byte, payload []byte) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if err := writeFrame(rw.Writer, opcode, payload, nil); err != nil {
+				return err
+			}
+			return rw.Flush()
+		}
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for {
+				opcode, payload, masked, err := readFrame(rw.Reader)
+				if err != nil || !masked {
+					return
+				}
+				switch opcode {
+				case opText:
+					var f Filter
+					if json.Unmarshal(payload, &f) == nil {
+						sub.SetFilter(f)
+					}
+				case opPing:
+					send(opPong, payload)
+				case opClose:
+					send(opClose, payload)
+					return
+				}
+			}
+		}()
+
+		for {
+			select {
+			case e, ok := <-sub.C:
+				if !ok {
+					return
+				}
+				data, err := json.Marshal(e)
+				if err != nil {
+					continue
+				}
+				if send(opText, data) != nil {
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	})
+}
+
+// acceptKey is the Sec-WebSocket-Accept value for a client's key
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// AllowedOrigin reports whether a browser request comes from a page of the
+// server itself or of this host. Browsers send any page's WebSocket and
+// cross-site requests with its Origin, so without this check every website
+// the user visits could talk to a local server. Requests without an Origin
+// don't come from a page.
+func AllowedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host) || IsLoopbackHost(u.Host)
+}
+
+// IsLoopbackHost reports whether host, with or without a port, names this
+// host: localhost or a loopback address
+func IsLoopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// headerHas reports whether a comma-separated header contains token
+func headerHas(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// readFrame reads one unfragmented frame, unmasking its payload
+func readFrame(r io.Reader) (opcode byte, payload []byte, masked bool, err error) {
+	var h [2]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		return 0, nil, false, err
+	}
+	if h[0]&0x80 == 0 || h[0]&0x0f == 0 {
+		return 0, nil, false, errors.New("fragmented WebSocket messages are not supported")
+	}
+	opcode = h[0] & 0x0f
+	masked = h[1]&0x80 != 0
+	n := uint64(h[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, false, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, false, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > maxMessage {
+		return 0, nil, false, fmt.Errorf("WebSocket frame of %d bytes is too large", n)
+	}
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return 0, nil, false, err
+		}
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, false, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return opcode, payload, masked, nil
+}
+
+// writeFrame writes one final frame. Servers pass a nil mask; clients must
+// mask their frames.
+func writeFrame(w *bufio.Writer, opcode byte, payload []byte, mask []byte) error {
+	w.WriteByte(0x80 | opcode)
+	maskBit := byte(0)
+	if mask != nil {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		w.WriteByte(maskBit | byte(n))
+	case n <= 0xffff:
+		w.WriteByte(maskBit | 126)
+		binary.Write(w, binary.BigEndian, uint16(n))
+	default:
+		w.WriteByte(maskBit | 127)
+		binary.Write(w, binary.BigEndian, uint64(n))
+	}
+	if mask == nil {
+		_, err := w.Write(payload)
+		return err
+	}
+	w.Write(mask)
+	masked := make([]byte, len(payload))
+	for i, b := range payload {
+		masked[i] = b ^ mask[i%4]
+	}
+	_, err := w.Write(masked)
+	return err
+}
@@ -0,0 +1,146 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dialEvents connects a WebSocket client to the server at path
+func dialEvents(t *testing.T, srv *httptest.Server, path string) (net.Conn, *bufio.ReadWriter) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	rw.WriteString("GET " + path + " HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: keep-alive, Upgrade\r\nSec-WebSocket-Key: " + key + "\r\nSec-WebSocket-Version: 13\r\n\r\n")
+	rw.Flush()
+	resp, err := http.ReadResponse(rw.Reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The accept value of the sample key in RFC 6455
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake = %d %v", resp.StatusCode, resp.Header)
+	}
+	return conn, rw
+}
+
+func readEvent(t *testing.T, conn net.Conn, rw *bufio.ReadWriter) Event {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	opcode, payload, masked, err := readFrame(rw.Reader)
+	if err != nil || opcode != opText || masked {
+		t.Fatalf("readFrame() = %d %q masked=%v %v", opcode, payload, masked, err)
+	}
+	var e Event
+	if err := json.Unmarshal(payload, &e); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestHandler(t *testing.T) {
+	bus := NewBus()
+	srv := httptest.NewServer(Handler(bus))
+	defer srv.Close()
+
+	conn, rw := dialEvents(t, srv, "/ws?types=task")
+	bus.Publish(Event{Type: Log, Task: "A", Text: "compiling"})
+	bus.Publish(Event{Type: TaskCompleted, Task: "A"})
+	if e := readEvent(t, conn, rw); e.Type != TaskCompleted || e.Task != "A" {
+		t.Errorf("event = %+v; want the log event filtered out", e)
+	}
+
+	// A JSON message replaces the filter
+	writeFrame(rw.Writer, opText, []byte(`{"types":["log"],"tasks":["b"]}`), []byte{1, 2, 3, 4})
+	rw.Flush()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		bus.Publish(Event{Type: Log, Task: "B", Text: "ping"})
+		conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+		if _, payload, _, err := readFrame(rw.Reader); err == nil {
+			if !strings.Contains(string(payload), `"task":"B"`) {
+				t.Fatalf("event = %s; want a log event of B", payload)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no event after changing the filter")
+		}
+	}
+
+	// Pings are answered and a close is echoed
+	writeFrame(rw.Writer, opPing, []byte("hi"), []byte{5, 6, 7, 8})
+	writeFrame(rw.Writer, opClose, nil, []byte{5, 6, 7, 8})
+	rw.Flush()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for _, want := range []byte{opPong, opClose} {
+		opcode, _, _, err := readFrame(rw.Reader)
+		for err == nil && opcode == opText {
+			opcode, _, _, err = readFrame(rw.Reader)
+		}
+		if err != nil || opcode != want {
+			t.Fatalf("frame = %d %v, want opcode %d", opcode, err, want)
+		}
+	}
+}
+
+func TestHandlerRejectsPlainRequests(t *testing.T) {
+	srv := httptest.NewServer(Handler(NewBus()))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestHandlerRejectsForeignOrigins(t *testing.T) {
+	srv := httptest.NewServer(Handler(NewBus()))
+	defer srv.Close()
+	for origin, want := range map[string]int{
+		"https://evil.example":  http.StatusForbidden,
+		"null":                  http.StatusForbidden,
+		"http://localhost:3000": http.StatusSwitchingProtocols,
+		"http://[::1]:8787":     http.StatusSwitchingProtocols,
+		srv.URL:                 http.StatusSwitchingProtocols,
+	} {
+		req, _ := http.NewRequest("GET", srv.URL+"/ws", nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("Origin", origin)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("Origin %s: status = %d, want %d", origin, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestIsLoopbackHost(t *testing.T) {
+	for host, want := range map[string]bool{
+		"localhost": true, "127.0.0.1:8787": true, "[::1]:8787": true, "::1": true,
+		"example.com": false, "10.0.0.2:8787": false, "": false,
+	} {
+		if got := IsLoopbackHost(host); got != want {
+			t.Errorf("IsLoopbackHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
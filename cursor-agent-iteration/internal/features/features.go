@@ -0,0 +1,128 @@
+// Package features keeps the features log: every add-feature request with
+// the tasks it created and, once they have all completed, what delivering
+// the feature took, closing the loop from design request to shipped code
+package features
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// LabelKey labels the tasks an add-feature request created with its ID,
+// "[feature:feat-20250108-103000]"
+const LabelKey = "feature"
+
+// timeFormat is how times are written in the log
+const timeFormat = "2006-01-02 15:04"
+
+// logHeader starts a new features log
+const logHeader = "# Features\n\nEvery `cursor-iter add-feature` request, the tasks it created and, once they are all completed, what delivering it took.\n"
+
+var (
+	reFeature   = regexp.MustCompile(`^## (feat-[0-9-]+): (.*)$`)
+	reRequested = regexp.MustCompile(`^\*\*Requested:\*\*\s*(.+)$`)
+	reTasks     = regexp.MustCompile(`^\*\*Tasks:\*\*\s*(.*)$`)
+	reDelivered = regexp.MustCompile(`^\*\*Delivered:\*\*\s*(.+)$`)
+	reTaskName  = regexp.MustCompile("`([^`]+)`")
+)
+
+// Feature is one add-feature request in the log
+type Feature struct {
+	ID    string
+	Title string // the first line of the feature description
+	// Requested is when add-feature ran, to the minute
+	Requested time.Time
+	// Tasks are the titles of the tasks the request created
+	Tasks []string
+	// Delivered is when the last of its tasks completed, zero until then
+	Delivered time.Time
+	// Summary is what delivering the feature took, one item per line
+	Summary []string
+}
+
+// NewID names the request made at t
+func NewID(t time.Time) string {
+	return "feat-" + t.Format("20060102-150405")
+}
+
+// Parse reads a features log. Times are read in the local zone they were
+// written in.
+func Parse(logMd string) []Feature {
+	var features []Feature
+	var cur *Feature
+	for _, line := range strings.Split(logMd, "\n") {
+		line = strings.TrimSpace(line)
+		if m := reFeature.FindStringSubmatch(line); m != nil {
+			features = append(features, Feature{ID: m[1], Title: strings.TrimSpace(m[2])})
+			cur = &features[len(features)-1]
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		switch {
+		case reRequested.MatchString(line):
+			cur.Requested, _ = time.ParseInLocation(timeFormat, reRequested.FindStringSubmatch(line)[1], time.Local)
+		case reTasks.MatchString(line):
+			for _, m := range reTaskName.FindAllStringSubmatch(line, -1) {
+				cur.Tasks = append(cur.Tasks, m[1])
+			}
+		case reDelivered.MatchString(line):
+			cur.Delivered, _ = time.ParseInLocation(timeFormat, reDelivered.FindStringSubmatch(line)[1], time.Local)
+		case !cur.Delivered.IsZero() && strings.HasPrefix(line, "- "):
+			cur.Summary = append(cur.Summary, strings.TrimPrefix(line, "- "))
+		}
+	}
+	return features
+}
+
+// Format renders features as a features log
+func Format(features []Feature) string {
+	var b strings.Builder
+	b.WriteString(logHeader)
+	for _, f := range features {
+		fmt.Fprintf(&b, "\n## %s: %s\n\n", f.ID, f.Title)
+		fmt.Fprintf(&b, "**Requested:** %s\n", f.Requested.Format(timeFormat))
+		names := make([]string, len(f.Tasks))
+		for i, t := range f.Tasks {
+			names[i] = "`" + t + "`"
+		}
+		fmt.Fprintf(&b, "**Tasks:** %s\n", strings.Join(names, ", "))
+		if f.Delivered.IsZero() {
+			continue
+		}
+		fmt.Fprintf(&b, "**Delivered:** %s\n\n", f.Delivered.Format(timeFormat))
+		for _, item := range f.Summary {
+			fmt.Fprintf(&b, "- %s\n", item)
+		}
+	}
+	return b.String()
+}
+
+// Find returns the feature with the given ID
+func Find(features []Feature, id string) (*Feature, bool) {
+	for i := range features {
+		if features[i].ID == id {
+			return &features[i], true
+		}
+	}
+	return nil, false
+}
+
+// Title is the first line of a feature description, cut to a heading's
+// length
+func Title(description string) string {
+	for _, line := range strings.Split(description, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#"))
+		if line == "" {
+			continue
+		}
+		if r := []rune(line); len(r) > 80 {
+			line = strings.TrimSpace(string(r[:79])) + "…"
+		}
+		return line
+	}
+	return "(no description)"
+}
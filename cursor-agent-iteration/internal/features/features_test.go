@@ -0,0 +1,40 @@
+package features
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatParse(t *testing.T) {
+	requested := time.Date(2025, 1, 8, 10, 30, 0, 0, time.Local)
+	features := []Feature{
+		{ID: NewID(requested), Title: "Rate limiting", Requested: requested, Tasks: []string{"Rate limit login", "Lock accounts"}},
+		{ID: "feat-20250109-090000", Title: "Search", Requested: requested.Add(time.Hour), Tasks: []string{"Search index"},
+			Delivered: requested.Add(26 * time.Hour), Summary: []string{"1 task, 2 runs", "PRs: https://example.com/pr/1"}},
+	}
+	md := Format(features)
+	if !strings.Contains(md, "## feat-20250108-103000: Rate limiting\n\n**Requested:** 2025-01-08 10:30\n**Tasks:** `Rate limit login`, `Lock accounts`\n") {
+		t.Errorf("Unexpected log:\n%s", md)
+	}
+	if got := Parse(md); !reflect.DeepEqual(got, features) {
+		t.Errorf("Parse(Format()) = %+v, want %+v", got, features)
+	}
+	if f, ok := Find(Parse(md), "feat-20250109-090000"); !ok || f.Title != "Search" {
+		t.Errorf("Find() = %+v, %v", f, ok)
+	}
+}
+
+func TestTitle(t *testing.T) {
+	for desc, want := range map[string]string{
+		"\n# Rate limiting\n\nLimit logins.": "Rate limiting",
+		"Spec: https://example.com\n\nbody":  "Spec: https://example.com",
+		"   ":                                "(no description)",
+		strings.Repeat("a", 100):             strings.Repeat("a", 79) + "…",
+	} {
+		if got := Title(desc); got != want {
+			t.Errorf("Title(%q) = %q, want %q", desc, got, want)
+		}
+	}
+}
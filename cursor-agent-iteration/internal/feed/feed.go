@@ -0,0 +1,181 @@
+// Package feed publishes what the autopilot shipped and when: the task
+// completions of the progress history and the milestones they finished, as
+// an iCalendar file for team calendars or a JSON Feed for timeline tools.
+package feed
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of items
+const (
+	KindTask      = "task"
+	KindMilestone = "milestone"
+)
+
+// Completion is a completed task of the progress history
+type Completion struct {
+	Task      string
+	Milestone string // empty for a task without a milestone
+	Notes     string
+	Time      time.Time
+}
+
+// Item is one event of the feed: a completed task, or a milestone whose
+// tasks all completed
+type Item struct {
+	Kind      string
+	Title     string // the task, or the milestone
+	Milestone string
+	Notes     string
+	// Start is when the first task of a milestone completed; for a task it
+	// is Time
+	Start time.Time
+	Time  time.Time
+	Tasks int // the tasks a milestone finished
+}
+
+// ID identifies the item across regenerations of the feed
+func (it Item) ID() string {
+	sum := sha1.Sum([]byte(it.Kind + "\x00" + it.Title + "\x00" + it.Time.UTC().Format(time.RFC3339)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Summary is the one-line description of the item
+func (it Item) Summary() string {
+	if it.Kind == KindMilestone {
+		return fmt.Sprintf("🏁 Milestone %s shipped (%d tasks)", it.Title, it.Tasks)
+	}
+	return "✅ " + it.Title
+}
+
+// Feed is the items to publish, latest first
+type Feed struct {
+	Title string
+	Items []Item
+}
+
+// Build makes the feed of the completions. Each milestone with completed
+// tasks and none left open gets an item of its own, at its last completion;
+// open counts the tasks of each milestone that haven't completed yet.
+func Build(title string, completions []Completion, open map[string]int) Feed {
+	f := Feed{Title: title}
+	milestones := make(map[string]*Item)
+	var names []string
+	for _, c := range completions {
+		f.Items = append(f.Items, Item{Kind: KindTask, Title: c.Task, Milestone: c.Milestone, Notes: c.Notes, Start: c.Time, Time: c.Time})
+		if c.Milestone == "" || open[c.Milestone] > 0 {
+			continue
+		}
+		m := milestones[c.Milestone]
+		if m == nil {
+			m = &Item{Kind: KindMilestone, Title: c.Milestone, Milestone: c.Milestone, Start: c.Time, Time: c.Time}
+			milestones[c.Milestone] = m
+			names = append(names, c.Milestone)
+		}
+		m.Tasks++
+		if c.Time.Before(m.Start) {
+			m.Start = c.Time
+		}
+		if c.Time.After(m.Time) {
+			m.Time = c.Time
+		}
+	}
+	for _, name := range names {
+		f.Items = append(f.Items, *milestones[name])
+	}
+	// A milestone comes before the task that finished it
+	sort.SliceStable(f.Items, func(i, j int) bool {
+		if !f.Items[i].Time.Equal(f.Items[j].Time) {
+			return f.Items[i].Time.After(f.Items[j].Time)
+		}
+		return f.Items[i].Kind == KindMilestone && f.Items[j].Kind != KindMilestone
+	})
+	return f
+}
+
+// Filter keeps the items since since, and of one milestone unless milestone
+// is empty
+func (f Feed) Filter(since time.Time, milestone string) Feed {
+	out := Feed{Title: f.Title}
+	for _, it := range f.Items {
+		if it.Time.Before(since) || (milestone != "" && !strings.EqualFold(it.Milestone, milestone)) {
+			continue
+		}
+		out.Items = append(out.Items, it)
+	}
+	return out
+}
+
+// JSONFeed is the JSON Feed 1.1 (https://jsonfeed.org) JSON writes
+type JSONFeed struct {
+	Version string     `json:"version"`
+	Title   string     `json:"title"`
+	Items   []JSONItem `json:"items"`
+}
+
+// JSONItem is an item of a JSONFeed, tagged with its milestone
+type JSONItem struct {
+	ID            string        `json:"id"`
+	Title         string        `json:"title"`
+	ContentText   string        `json:"content_text"`
+	DatePublished time.Time     `json:"date_published"`
+	Tags          []string      `json:"tags,omitempty"`
+	Extension     JSONExtension `json:"_cursor_iter"`
+}
+
+// JSONExtension is the "_cursor_iter" extension of a JSONItem: its kind and
+// milestone, and for a milestone when it started and its task count
+type JSONExtension struct {
+	Kind      string     `json:"kind"`
+	Milestone string     `json:"milestone,omitempty"`
+	Started   *time.Time `json:"started,omitempty"`
+	Tasks     int        `json:"tasks,omitempty"`
+}
+
+// JSON writes the feed as a JSON Feed 1.1 (https://jsonfeed.org). Items are
+// tagged with their milestone, and carry their kind, task or milestone in a
+// "_cursor_iter" extension.
+func (f Feed) JSON(w io.Writer) error {
+	doc := JSONFeed{Version: "https://jsonfeed.org/version/1.1", Title: f.Title, Items: []JSONItem{}}
+	for _, it := range f.Items {
+		ext := JSONExtension{Kind: it.Kind, Milestone: it.Milestone}
+		if it.Kind == KindMilestone {
+			start := it.Start
+			ext.Started, ext.Tasks = &start, it.Tasks
+		}
+		var tags []string
+		if it.Milestone != "" {
+			tags = []string{it.Milestone}
+		}
+		doc.Items = append(doc.Items, JSONItem{
+			ID:            it.ID(),
+			Title:         it.Summary(),
+			ContentText:   it.description(),
+			DatePublished: it.Time,
+			Tags:          tags,
+			Extension:     ext,
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// description is the longer text of the item
+func (it Item) description() string {
+	if it.Kind == KindMilestone {
+		return fmt.Sprintf("All %d tasks of milestone %s completed, from %s to %s.", it.Tasks, it.Title, it.Start.Format("2006-01-02 15:04"), it.Time.Format("2006-01-02 15:04"))
+	}
+	if it.Notes != "" {
+		return it.Notes
+	}
+	return "Completed " + it.Title
+}
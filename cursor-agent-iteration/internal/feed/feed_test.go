@@ -0,0 +1,118 @@
+package feed
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2025, 1, 8, 19, 0, 0, 0, time.UTC)
+
+func sampleFeed() Feed {
+	completions := []Completion{
+		{Task: "Login form", Milestone: "auth", Notes: "done", Time: t0},
+		{Task: "Fix typo", Time: t0.Add(time.Hour)},
+		{Task: "Session cookies", Milestone: "auth", Time: t0.Add(2 * time.Hour)},
+		{Task: "Search index", Milestone: "search", Time: t0.Add(3 * time.Hour)},
+	}
+	return Build("cursor-iter: app", completions, map[string]int{"search": 1})
+}
+
+func TestBuild(t *testing.T) {
+	f := sampleFeed()
+	var got []string
+	for _, it := range f.Items {
+		got = append(got, it.Summary())
+	}
+	want := []string{"✅ Search index", "🏁 Milestone auth shipped (2 tasks)", "✅ Session cookies", "✅ Fix typo", "✅ Login form"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("Items = %q, want %q", got, want)
+	}
+	auth := f.Items[1]
+	if !auth.Start.Equal(t0) || !auth.Time.Equal(t0.Add(2*time.Hour)) {
+		t.Errorf("Milestone auth spans %v to %v", auth.Start, auth.Time)
+	}
+	if auth.ID() == f.Items[2].ID() || auth.ID() != sampleFeed().Items[1].ID() {
+		t.Errorf("Expected IDs unique to an item and stable across builds")
+	}
+
+	if got := f.Filter(time.Time{}, "AUTH").Items; len(got) != 3 {
+		t.Errorf("Expected the milestone and its 2 tasks, got %+v", got)
+	}
+	if got := f.Filter(t0.Add(90*time.Minute), "").Items; len(got) != 3 {
+		t.Errorf("Expected 3 items since the filter, got %+v", got)
+	}
+}
+
+func TestICS(t *testing.T) {
+	f := sampleFeed()
+	f.Items[0].Notes = "Indexes titles, bodies; and tags\nof every post " + strings.Repeat("ü", 40)
+	var b strings.Builder
+	if err := f.Filter(time.Time{}, "").ICS(&b); err != nil {
+		t.Fatal(err)
+	}
+	ics := b.String()
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\n",
+		"X-WR-CALNAME:cursor-iter: app\r\n",
+		"SUMMARY:🏁 Milestone auth shipped (2 tasks)\r\nDESCRIPTION:All 2 tasks of milestone auth completed",
+		"DTSTART:20250108T190000Z\r\nDTEND:20250108T210000Z\r\n",
+		"DESCRIPTION:Indexes titles\\, bodies\\; and tags\\nof every post",
+		"CATEGORIES:search\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(ics, want) {
+			t.Errorf("Expected %q in:\n%s", want, ics)
+		}
+	}
+	if strings.Count(ics, "BEGIN:VEVENT") != 5 {
+		t.Errorf("Expected 5 events:\n%s", ics)
+	}
+	for _, line := range strings.Split(ics, "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("Line longer than 75 octets: %q", line)
+		}
+	}
+	if !strings.Contains(strings.ReplaceAll(ics, "\r\n ", ""), strings.Repeat("ü", 40)) {
+		t.Errorf("Expected folded lines to unfold to the whole text:\n%s", ics)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var b strings.Builder
+	if err := sampleFeed().JSON(&b); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Version string
+		Title   string
+		Items   []struct {
+			ID            string
+			Title         string
+			DatePublished time.Time `json:"date_published"`
+			Tags          []string
+			Extension     struct {
+				Kind    string
+				Started *time.Time
+				Tasks   int
+			} `json:"_cursor_iter"`
+		}
+	}
+	if err := json.Unmarshal([]byte(b.String()), &doc); err != nil {
+		t.Fatalf("Invalid JSON: %v\n%s", err, b.String())
+	}
+	if doc.Version != "https://jsonfeed.org/version/1.1" || doc.Title != "cursor-iter: app" || len(doc.Items) != 5 {
+		t.Fatalf("Unexpected feed:\n%s", b.String())
+	}
+	auth := doc.Items[1]
+	if auth.Extension.Kind != KindMilestone || auth.Extension.Tasks != 2 || auth.Extension.Started == nil || !auth.Extension.Started.Equal(t0) {
+		t.Errorf("Unexpected milestone item %+v", auth)
+	}
+	if len(auth.Tags) != 1 || auth.Tags[0] != "auth" || !auth.DatePublished.Equal(t0.Add(2*time.Hour)) {
+		t.Errorf("Unexpected milestone item %+v", auth)
+	}
+	if typo := doc.Items[3]; typo.Tags != nil || typo.Extension.Kind != KindTask || typo.Extension.Started != nil {
+		t.Errorf("Unexpected task item %+v", typo)
+	}
+}
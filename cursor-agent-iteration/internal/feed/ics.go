@@ -0,0 +1,71 @@
+package feed
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// icsTime is the UTC DATE-TIME format of iCalendar
+const icsTime = "20060102T150405Z"
+
+// ICS writes the feed as an iCalendar file (RFC 5545). A task is an event at
+// its completion, and a milestone one from its first completion to its
+// last, each in the category of its milestone.
+func (f Feed) ICS(w io.Writer) error {
+	var b strings.Builder
+	line := func(name, value string) {
+		b.WriteString(foldLine(name + ":" + value))
+		b.WriteString("\r\n")
+	}
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//cursor-iter//progress feed//EN")
+	line("CALSCALE", "GREGORIAN")
+	if f.Title != "" {
+		line("X-WR-CALNAME", icsText(f.Title))
+	}
+	for _, it := range f.Items {
+		line("BEGIN", "VEVENT")
+		line("UID", it.ID()+"@cursor-iter")
+		line("DTSTAMP", it.Time.UTC().Format(icsTime))
+		line("DTSTART", it.Start.UTC().Format(icsTime))
+		if it.Time.After(it.Start) {
+			line("DTEND", it.Time.UTC().Format(icsTime))
+		}
+		line("SUMMARY", icsText(it.Summary()))
+		line("DESCRIPTION", icsText(it.description()))
+		if it.Milestone != "" {
+			line("CATEGORIES", icsText(it.Milestone))
+		}
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// icsText escapes a TEXT value
+func icsText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// foldLine folds a content line longer than 75 octets onto continuation
+// lines starting with a space, without splitting a character
+func foldLine(s string) string {
+	var b strings.Builder
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		// The leading space counts toward the continuation line
+		limit = 74
+	}
+	b.WriteString(s)
+	return b.String()
+}
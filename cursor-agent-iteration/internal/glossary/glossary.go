@@ -0,0 +1,145 @@
+package glossary
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Entry is a single glossary term with its definition
+type Entry struct {
+	Term       string
+	Aliases    []string
+	Definition string
+}
+
+// reBoldEntry matches "- **Term** (Alias, Other): definition"; the separator
+// may be a colon or a dash
+var reBoldEntry = regexp.MustCompile(`^[-*]\s+\*\*(.+?)\*\*\s*(?:\(([^)]*)\))?\s*(?::|—|–|-)\s*(.+)$`)
+
+// rePlainEntry matches "- Term (Alias): definition"
+var rePlainEntry = regexp.MustCompile(`^[-*]\s+([^:(]+?)\s*(?:\(([^)]*)\))?\s*:\s+(.+)$`)
+
+// reTableSeparator matches the "|---|---|" row of a markdown table
+var reTableSeparator = regexp.MustCompile(`^\|[\s:|-]+\|$`)
+
+// Parse reads glossary entries from markdown. Entries are list items such as
+// "- **SLA** (service level agreement): ..." or rows of a two-column
+// "| Term | Definition |" table. Everything else is ignored.
+func Parse(md string) []Entry {
+	var entries []Entry
+	for _, raw := range strings.Split(md, "\n") {
+		line := strings.TrimSpace(raw)
+		if e, ok := parseListEntry(line); ok {
+			entries = append(entries, e)
+			continue
+		}
+		if e, ok := parseTableRow(line); ok {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+func parseListEntry(line string) (Entry, bool) {
+	m := reBoldEntry.FindStringSubmatch(line)
+	if m == nil {
+		m = rePlainEntry.FindStringSubmatch(line)
+	}
+	if m == nil {
+		return Entry{}, false
+	}
+	return newEntry(m[1], m[2], m[3])
+}
+
+func parseTableRow(line string) (Entry, bool) {
+	if !strings.HasPrefix(line, "|") || reTableSeparator.MatchString(line) {
+		return Entry{}, false
+	}
+	cells := strings.Split(strings.Trim(line, "|"), "|")
+	if len(cells) < 2 {
+		return Entry{}, false
+	}
+	term := strings.Trim(strings.TrimSpace(cells[0]), "*`")
+	if strings.EqualFold(term, "term") {
+		return Entry{}, false // header row
+	}
+	return newEntry(term, "", cells[len(cells)-1])
+}
+
+func newEntry(term, aliases, definition string) (Entry, bool) {
+	e := Entry{
+		Term:       strings.TrimSpace(term),
+		Definition: strings.TrimSpace(definition),
+	}
+	if e.Term == "" || e.Definition == "" {
+		return Entry{}, false
+	}
+	for _, a := range strings.Split(aliases, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			e.Aliases = append(e.Aliases, a)
+		}
+	}
+	return e, true
+}
+
+// Match returns the entries whose term or an alias occurs in text as a whole
+// word, ignoring case, in glossary order
+func Match(entries []Entry, text string) []Entry {
+	lower := strings.ToLower(text)
+	var matched []Entry
+	for _, e := range entries {
+		for _, name := range append([]string{e.Term}, e.Aliases...) {
+			if containsWord(lower, strings.ToLower(name)) {
+				matched = append(matched, e)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// containsWord reports whether word occurs in text without a letter or digit
+// directly before or after it
+func containsWord(text, word string) bool {
+	if word == "" {
+		return false
+	}
+	for offset := 0; offset < len(text); {
+		i := strings.Index(text[offset:], word)
+		if i < 0 {
+			return false
+		}
+		start := offset + i
+		end := start + len(word)
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if (start == 0 || !isWordRune(before)) && (end == len(text) || !isWordRune(after)) {
+			return true
+		}
+		offset = start + 1
+	}
+	return false
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// Section renders matched entries as a prompt section, or "" when there are none
+func Section(entries []Entry) string {
+	if len(entries) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\n## Glossary\n\nDomain terms used in this task (from .cursor-iter/glossary.md):\n\n")
+	for _, e := range entries {
+		b.WriteString("- **" + e.Term + "**")
+		if len(e.Aliases) > 0 {
+			b.WriteString(" (" + strings.Join(e.Aliases, ", ") + ")")
+		}
+		b.WriteString(": " + e.Definition + "\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
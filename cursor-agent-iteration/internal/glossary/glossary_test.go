@@ -0,0 +1,95 @@
+package glossary
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const sampleGlossary = `# Glossary
+
+Terms used across the codebase.
+
+- **SLA** (service level agreement): Contractual uptime target per tenant
+- **Tenant**: A customer organization with isolated data
+* Ledger: Append-only record of balance changes
+- **C++ bridge** - Native module wrapping the pricing engine
+
+| Term | Definition |
+|------|------------|
+| ETL | Nightly export pipeline into the warehouse |
+`
+
+func TestParse(t *testing.T) {
+	entries := Parse(sampleGlossary)
+	expected := []Entry{
+		{Term: "SLA", Aliases: []string{"service level agreement"}, Definition: "Contractual uptime target per tenant"},
+		{Term: "Tenant", Definition: "A customer organization with isolated data"},
+		{Term: "Ledger", Definition: "Append-only record of balance changes"},
+		{Term: "C++ bridge", Definition: "Native module wrapping the pricing engine"},
+		{Term: "ETL", Definition: "Nightly export pipeline into the warehouse"},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Parse() =\n%#v\nwant\n%#v", entries, expected)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	entries := Parse(sampleGlossary)
+
+	tests := []struct {
+		name     string
+		text     string
+		expected []string
+	}{
+		{
+			name:     "term and alias, case insensitive",
+			text:     "Raise the Service Level Agreement alerting for each tenant",
+			expected: []string{"SLA", "Tenant"},
+		},
+		{
+			name:     "no partial word matches",
+			text:     "Update the ledgers and the SLAs in the tenants list",
+			expected: nil,
+		},
+		{
+			name:     "terms with punctuation",
+			text:     "Port the C++ bridge to the new ETL job.",
+			expected: []string{"C++ bridge", "ETL"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, e := range Match(entries, tt.text) {
+				got = append(got, e.Term)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Match() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSection(t *testing.T) {
+	if Section(nil) != "" {
+		t.Errorf("Expected empty section without entries")
+	}
+	section := Section([]Entry{
+		{Term: "SLA", Aliases: []string{"service level agreement"}, Definition: "Uptime target"},
+		{Term: "ETL", Definition: "Export pipeline"},
+	})
+	for _, want := range []string{
+		"## Glossary",
+		"- **SLA** (service level agreement): Uptime target",
+		"- **ETL**: Export pipeline",
+	} {
+		if !strings.Contains(section, want) {
+			t.Errorf("Section() missing %q:\n%s", want, section)
+		}
+	}
+	if strings.HasSuffix(section, "\n") {
+		t.Errorf("Expected section without trailing newline")
+	}
+}
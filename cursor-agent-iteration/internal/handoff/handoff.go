@@ -0,0 +1,254 @@
+// Package handoff writes HANDOFF.md, a summary of a session that stopped
+// mid-backlog: the tasks in flight and what is left of them, blocked tasks,
+// the branches, worktrees and uncommitted changes in the repository, and
+// what to do next. A human or the next session picks up from it.
+package handoff
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// Task is a task that needs attention in the hand-off
+type Task struct {
+	Title     string
+	ACChecked int
+	ACTotal   int
+	// Open are the unchecked acceptance criteria
+	Open []string
+	// Runs counts the agent runs of the task in the journal
+	Runs int
+	// Last is the latest run of the task, if any
+	Last *journal.Entry
+	// Reason is why a blocked task is blocked, or a failed one failed
+	Reason string
+}
+
+// Worktree is a git worktree of the repository
+type Worktree struct {
+	Path   string
+	Branch string
+}
+
+// Repo is the state of the git repository
+type Repo struct {
+	Branch string
+	// Branches are the other local branches
+	Branches  []string
+	Worktrees []Worktree
+	// Dirty are the uncommitted changes, as git status --short lines
+	Dirty []string
+}
+
+// Doc is the content of a hand-off document
+type Doc struct {
+	Time   time.Time
+	Reason string // why the session stopped, e.g. "interrupted"
+	RunID  string
+
+	Totals   tasks.StatusTotals
+	InFlight []Task
+	Blocked  []Task // blocked and failed tasks
+	Next     string // the next pending task
+
+	Repo Repo
+}
+
+// Build collects the tasks of a hand-off from the control files and the run
+// journal. The label defaults are merged into every task.
+func Build(tasksMd, progressMd string, entries []journal.Entry, defaults tasks.LabelDefaults) Doc {
+	summary := tasks.SummarizeWith(tasksMd, progressMd, tasks.StatusOptions{Defaults: defaults})
+	parsed := make(map[string]tasks.Task)
+	for _, t := range tasks.ParseTasks(tasksMd) {
+		parsed[t.Title] = t
+	}
+
+	d := Doc{Totals: summary.Totals}
+	if next := defaults.NextPendingTask(tasksMd, progressMd); next != nil {
+		d.Next = next.Title
+	}
+	for _, st := range summary.Tasks {
+		if st.Status != "in-progress" && st.Status != "blocked" && st.Status != "failed" {
+			continue
+		}
+		t := Task{Title: st.Title, ACChecked: st.ACChecked, ACTotal: st.ACTotal}
+		for _, c := range parsed[st.Title].Criteria {
+			if !c.Checked {
+				t.Open = append(t.Open, c.Text)
+			}
+		}
+		for i := range entries {
+			if entries[i].Task == st.Title {
+				t.Runs++
+				t.Last = &entries[i]
+			}
+		}
+		if st.Status == "blocked" || st.Status == "failed" {
+			t.Reason = st.Notes
+			d.Blocked = append(d.Blocked, t)
+		} else {
+			d.InFlight = append(d.InFlight, t)
+		}
+	}
+	return d
+}
+
+// NextSteps recommends what to do to pick up the work
+func (d Doc) NextSteps() []string {
+	var steps []string
+	if n := len(d.Repo.Dirty); n > 0 {
+		steps = append(steps, fmt.Sprintf("Review the %d uncommitted change(s) with `git diff`: they may be partial work of a stopped agent. Commit what is good and discard the rest.", n))
+	}
+	for _, w := range d.Repo.Worktrees {
+		steps = append(steps, fmt.Sprintf("Merge or remove the worktree `%s` (branch `%s`).", w.Path, w.Branch))
+	}
+	for _, t := range d.InFlight {
+		if t.Last != nil && t.Last.Outcome == journal.OutcomeFailed && t.Last.Classification != journal.ClassInterrupted {
+			steps = append(steps, fmt.Sprintf("Look into why the last run of '%s' failed (%s) before retrying it: `cursor-iter triage --list`.", t.Title, t.Last.Classification))
+		}
+	}
+	if len(d.Blocked) > 0 {
+		steps = append(steps, fmt.Sprintf("Unblock the %d blocked task(s) with `cursor-iter triage`.", len(d.Blocked)))
+	}
+	switch {
+	case len(d.InFlight) > 0:
+		steps = append(steps, "Resume with `cursor-iter iterate-loop`: it picks up the tasks in progress first.")
+	case d.Next != "":
+		steps = append(steps, fmt.Sprintf("Resume with `cursor-iter iterate-loop`: the next task is '%s'.", d.Next))
+	case len(d.Blocked) == 0:
+		steps = append(steps, "Every task is complete; add more with `cursor-iter add-feature`.")
+	}
+	return steps
+}
+
+// Markdown renders the hand-off document
+func (d Doc) Markdown() string {
+	var b strings.Builder
+	b.WriteString("# Hand-off\n\n")
+	fmt.Fprintf(&b, "Written %s", d.Time.Format("2006-01-02 15:04"))
+	if d.RunID != "" {
+		fmt.Fprintf(&b, " at the end of run %s", d.RunID)
+	}
+	b.WriteString(".")
+	if d.Reason != "" {
+		fmt.Fprintf(&b, " Stopped: %s.", d.Reason)
+	}
+	t := d.Totals
+	fmt.Fprintf(&b, "\n\n%d of %d tasks completed, %d in progress, %d pending, %d blocked.\n", t.Completed, t.Total, t.InProgress, t.Pending, t.Blocked)
+
+	b.WriteString("\n## In flight\n\n")
+	if len(d.InFlight) == 0 {
+		b.WriteString("No task is in progress.\n")
+	}
+	for i, task := range d.InFlight {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		writeTask(&b, task)
+	}
+
+	if len(d.Blocked) > 0 {
+		b.WriteString("\n## Blocked\n\n")
+		for i, task := range d.Blocked {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			writeTask(&b, task)
+		}
+	}
+
+	b.WriteString("\n## Repository\n\n")
+	if d.Repo.Branch != "" {
+		fmt.Fprintf(&b, "- Current branch: `%s`\n", d.Repo.Branch)
+	}
+	if len(d.Repo.Branches) > 0 {
+		fmt.Fprintf(&b, "- Other branches: %s\n", codeList(d.Repo.Branches))
+	}
+	for _, w := range d.Repo.Worktrees {
+		fmt.Fprintf(&b, "- Worktree `%s` on `%s`\n", w.Path, w.Branch)
+	}
+	if len(d.Repo.Dirty) == 0 {
+		b.WriteString("- No uncommitted changes\n")
+	} else {
+		fmt.Fprintf(&b, "- %d uncommitted change(s):\n", len(d.Repo.Dirty))
+		for _, line := range d.Repo.Dirty {
+			fmt.Fprintf(&b, "  - `%s`\n", line)
+		}
+	}
+
+	b.WriteString("\n## Next steps\n\n")
+	for i, step := range d.NextSteps() {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, step)
+	}
+	return b.String()
+}
+
+// writeTask writes a task with its open criteria and latest run
+func writeTask(b *strings.Builder, t Task) {
+	fmt.Fprintf(b, "### %s\n\n", t.Title)
+	if t.Reason != "" {
+		fmt.Fprintf(b, "Blocked: %s\n\n", t.Reason)
+	}
+	var summary []string
+	if t.ACTotal > 0 {
+		summary = append(summary, fmt.Sprintf("%d/%d acceptance criteria checked.", t.ACChecked, t.ACTotal))
+	}
+	if t.Last != nil {
+		outcome := t.Last.Outcome
+		if t.Last.Classification != "" {
+			outcome += " (" + t.Last.Classification + ")"
+		}
+		summary = append(summary, fmt.Sprintf("%d run(s); the last one, on %s with %s, %s.", t.Runs, t.Last.Time.Format("2006-01-02 15:04"), t.Last.Backend, outcome))
+		if t.Last.Env != nil {
+			summary = append(summary, fmt.Sprintf("Environment: %s.", t.Last.Env))
+		}
+	}
+	if len(summary) > 0 {
+		b.WriteString(strings.Join(summary, " ") + "\n")
+	}
+	if len(t.Open) > 0 {
+		b.WriteString("\nStill open:\n\n")
+		for _, c := range t.Open {
+			fmt.Fprintf(b, "- [ ] %s\n", c)
+		}
+	}
+}
+
+// codeList formats names as a comma-separated list of code spans
+func codeList(names []string) string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = "`" + n + "`"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// ParseWorktrees reads the output of git worktree list --porcelain, leaving
+// out the main worktree
+func ParseWorktrees(porcelain string) []Worktree {
+	var worktrees []Worktree
+	for i, block := range strings.Split(strings.TrimSpace(porcelain), "\n\n") {
+		if i == 0 || strings.TrimSpace(block) == "" {
+			continue
+		}
+		var w Worktree
+		for _, line := range strings.Split(block, "\n") {
+			switch {
+			case strings.HasPrefix(line, "worktree "):
+				w.Path = strings.TrimPrefix(line, "worktree ")
+			case strings.HasPrefix(line, "branch "):
+				w.Branch = strings.TrimPrefix(strings.TrimPrefix(line, "branch "), "refs/heads/")
+			case line == "detached":
+				w.Branch = "detached HEAD"
+			}
+		}
+		if w.Path != "" {
+			worktrees = append(worktrees, w)
+		}
+	}
+	return worktrees
+}
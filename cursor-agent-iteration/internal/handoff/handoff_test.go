@@ -0,0 +1,141 @@
+package handoff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/toolenv"
+)
+
+const tasksMd = `## Current Tasks
+
+### Task: Signup
+
+**Acceptance Criteria:**
+* [x] Form validates the email
+* [ ] [tests] Add an integration test
+
+### Task: Billing
+
+**Acceptance Criteria:**
+* [ ] Charge the card
+
+### Task: Search
+
+**Acceptance Criteria:**
+* [ ] Index products
+
+### Task: Docs
+
+**Acceptance Criteria:**
+* [x] Write the README
+`
+
+const progressMd = `# Progress Log
+
+## In Progress
+
+- 🔄 [2025-01-08 19:00] Signup
+
+## Completed Tasks
+
+- ✅ [2025-01-08 18:00] Docs
+
+## Blocked
+
+- ⛔ [2025-01-08 19:30] Billing - needs the Stripe API key
+`
+
+func TestBuild(t *testing.T) {
+	at := time.Date(2025, 1, 8, 19, 40, 0, 0, time.UTC)
+	entries := []journal.Entry{
+		{Time: at.Add(-time.Hour), Task: "Signup", Backend: "cursor-agent", Outcome: journal.OutcomeIncomplete, Classification: journal.ClassIncomplete},
+		{Time: at, Task: "Signup", Backend: "codex", Outcome: journal.OutcomeFailed, Classification: journal.ClassTests,
+			Env: &toolenv.Env{OS: "linux/amd64", Tools: map[string]string{"go": "go1.22.1"}}},
+	}
+	d := Build(tasksMd, progressMd, entries, nil)
+	d.Time = at
+	d.Reason = "interrupted"
+	d.RunID = "20250108-183000"
+	d.Repo = Repo{
+		Branch:    "main",
+		Branches:  []string{"feature/search"},
+		Worktrees: []Worktree{{Path: "/tmp/wt-search", Branch: "feature/search"}},
+		Dirty:     []string{"M internal/signup/form.go"},
+	}
+
+	if len(d.InFlight) != 1 || d.InFlight[0].Title != "Signup" || d.InFlight[0].Runs != 2 || d.InFlight[0].Last.Backend != "codex" {
+		t.Fatalf("InFlight = %+v", d.InFlight)
+	}
+	if !reflect.DeepEqual(d.InFlight[0].Open, []string{"Add an integration test"}) {
+		t.Errorf("Open = %q", d.InFlight[0].Open)
+	}
+	if len(d.Blocked) != 1 || d.Blocked[0].Reason != "needs the Stripe API key" {
+		t.Errorf("Blocked = %+v", d.Blocked)
+	}
+	if d.Next != "Search" {
+		t.Errorf("Next = %q, want Search", d.Next)
+	}
+
+	md := d.Markdown()
+	for _, want := range []string{
+		"Written 2025-01-08 19:40 at the end of run 20250108-183000. Stopped: interrupted.",
+		"1 of 4 tasks completed, 1 in progress, 1 pending, 1 blocked.",
+		"### Signup\n\n1/2 acceptance criteria checked. 2 run(s); the last one, on 2025-01-08 19:40 with codex, failed (tests). Environment: linux/amd64, go go1.22.1.\n\nStill open:\n\n- [ ] Add an integration test\n\n## Blocked",
+		"### Billing\n\nBlocked: needs the Stripe API key\n",
+		"- Current branch: `main`\n- Other branches: `feature/search`\n- Worktree `/tmp/wt-search` on `feature/search`\n- 1 uncommitted change(s):\n  - `M internal/signup/form.go`\n",
+		"1. Review the 1 uncommitted change(s)",
+		"2. Merge or remove the worktree `/tmp/wt-search`",
+		"3. Look into why the last run of 'Signup' failed (tests)",
+		"4. Unblock the 1 blocked task(s)",
+		"5. Resume with `cursor-iter iterate-loop`: it picks up the tasks in progress first.",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Expected %q in:\n%s", want, md)
+		}
+	}
+}
+
+func TestNextSteps(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  Doc
+		want string
+	}{
+		{"pending work", Doc{Next: "Search"}, "Resume with `cursor-iter iterate-loop`: the next task is 'Search'."},
+		{"all done", Doc{}, "Every task is complete; add more with `cursor-iter add-feature`."},
+		{"interrupted run", Doc{InFlight: []Task{{Title: "Signup", Last: &journal.Entry{Outcome: journal.OutcomeFailed, Classification: journal.ClassInterrupted}}}}, "Resume with `cursor-iter iterate-loop`: it picks up the tasks in progress first."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.doc.NextSteps(); len(got) != 1 || got[0] != tt.want {
+				t.Errorf("NextSteps() = %q, want [%q]", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWorktrees(t *testing.T) {
+	porcelain := `worktree /src/app
+HEAD 1111111111111111111111111111111111111111
+branch refs/heads/main
+
+worktree /src/app-search
+HEAD 2222222222222222222222222222222222222222
+branch refs/heads/feature/search
+
+worktree /src/app-bisect
+HEAD 3333333333333333333333333333333333333333
+detached
+`
+	want := []Worktree{{Path: "/src/app-search", Branch: "feature/search"}, {Path: "/src/app-bisect", Branch: "detached HEAD"}}
+	if got := ParseWorktrees(porcelain); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseWorktrees() = %+v, want %+v", got, want)
+	}
+	if got := ParseWorktrees("worktree /src/app\nHEAD 1111\nbranch refs/heads/main\n"); len(got) != 0 {
+		t.Errorf("Expected no worktree besides the main one, got %+v", got)
+	}
+}
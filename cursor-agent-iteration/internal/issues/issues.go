@@ -0,0 +1,88 @@
+// Package issues turns GitHub issues into tasks for tasks.md: the title
+// becomes the task, the body its context and the body's checklist its
+// acceptance criteria. Talking to GitHub is left to the caller.
+package issues
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/textutil"
+)
+
+// maxContextLen caps the part of an issue body quoted in its task's Context
+const maxContextLen = 1000
+
+var (
+	reChecklist = regexp.MustCompile(`^[-*+]\s+\[([ xX])\]\s+(.+)$`)
+	reComments  = regexp.MustCompile(`(?s)<!--.*?-->`)
+)
+
+// Issue is an open issue as gh issue list --json reports it
+type Issue struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	URL    string `json:"url"`
+}
+
+// Ref names an issue of repo, e.g. "owner/name#12"
+func Ref(repo string, number int) string {
+	return fmt.Sprintf("%s#%d", repo, number)
+}
+
+// Criterion is an item of an issue's checklist
+type Criterion struct {
+	Text string
+	Done bool
+}
+
+// Split returns the body of an issue as one line of context and the items
+// of its checklist. Headings and HTML comments, as issue templates leave
+// them, are dropped.
+func Split(body string) (context string, criteria []Criterion) {
+	var words []string
+	for _, line := range strings.Split(reComments.ReplaceAllString(body, ""), "\n") {
+		line = strings.TrimSpace(line)
+		if m := reChecklist.FindStringSubmatch(line); m != nil {
+			criteria = append(criteria, Criterion{Text: strings.TrimSpace(m[2]), Done: m[1] != " "})
+			continue
+		}
+		if !strings.HasPrefix(line, "#") {
+			words = append(words, strings.Fields(line)...)
+		}
+	}
+	return textutil.Shorten(strings.Join(words, " "), maxContextLen), criteria
+}
+
+// TaskBlock is the tasks.md entry for an issue of repo. An issue without a
+// checklist gets a criterion asking for what it describes.
+func TaskBlock(repo string, issue Issue) string {
+	context, criteria := Split(issue.Body)
+	d := tasks.Draft{
+		Title:   issue.Title,
+		Context: strings.TrimSpace(fmt.Sprintf("From GitHub issue %s. %s", Ref(repo, issue.Number), context)),
+		Source:  issue.URL,
+		Labels:  []string{"source:github"},
+	}
+	for _, c := range criteria {
+		d.Criteria = append(d.Criteria, c.Text)
+		d.Checked = append(d.Checked, c.Done)
+	}
+	if len(d.Criteria) == 0 {
+		d.Criteria = []string{"The behaviour described in the issue is implemented"}
+	}
+	return d.Block()
+}
+
+// CloseComment is the comment an issue is closed with once its task was
+// completed at completedAt, with the notes of its progress entry
+func CloseComment(title, completedAt, notes string) string {
+	comment := fmt.Sprintf("Completed by cursor-iter on %s as task '%s'.", completedAt, title)
+	if notes = strings.TrimSpace(notes); notes != "" {
+		comment += "\n\n" + notes
+	}
+	return comment
+}
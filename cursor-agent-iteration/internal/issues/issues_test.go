@@ -0,0 +1,63 @@
+package issues
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+const sampleBody = `<!-- Describe the feature -->
+## Summary
+
+Users can export their saved searches
+as CSV.
+
+- [ ] The export button is on the searches page
+- [x] The CSV has a header row
+`
+
+func TestSplit(t *testing.T) {
+	context, criteria := Split(sampleBody)
+	if context != "Users can export their saved searches as CSV." {
+		t.Errorf("context = %q", context)
+	}
+	want := []Criterion{{Text: "The export button is on the searches page"}, {Text: "The CSV has a header row", Done: true}}
+	if !reflect.DeepEqual(criteria, want) {
+		t.Errorf("criteria = %+v, want %+v", criteria, want)
+	}
+}
+
+func TestTaskBlock(t *testing.T) {
+	issue := Issue{Number: 12, Title: "Export saved searches ", Body: sampleBody, URL: "https://github.com/acme/app/issues/12"}
+	block := TaskBlock("acme/app", issue)
+	parsed := tasks.ParseTasks("## Current Tasks\n\n" + block + "\n")
+	if len(parsed) != 1 {
+		t.Fatalf("ParseTasks() found %d tasks in:\n%s", len(parsed), block)
+	}
+	task := parsed[0]
+	if task.Title != "Export saved searches" || !strings.Contains(task.Context, "acme/app#12") || !strings.Contains(task.Context, "as CSV.") {
+		t.Errorf("Title = %q, Context = %q", task.Title, task.Context)
+	}
+	if !strings.Contains(block, "* [ ] The export button is on the searches page\n* [x] The CSV has a header row") {
+		t.Errorf("The checklist is missing from:\n%s", block)
+	}
+	if !strings.Contains(block, "**Source:** https://github.com/acme/app/issues/12") {
+		t.Errorf("The issue URL is missing from:\n%s", block)
+	}
+
+	bare := TaskBlock("acme/app", Issue{Number: 3, Title: "Fix typo"})
+	if !strings.Contains(bare, "**Context:** From GitHub issue acme/app#3.\n") || !strings.Contains(bare, "* [ ] The behaviour described in the issue is implemented") {
+		t.Errorf("TaskBlock() of an issue without a body =\n%s", bare)
+	}
+}
+
+func TestCloseComment(t *testing.T) {
+	if got := CloseComment("Fix typo", "2025-01-08 19:00", ""); got != "Completed by cursor-iter on 2025-01-08 19:00 as task 'Fix typo'." {
+		t.Errorf("CloseComment() = %q", got)
+	}
+	if got := CloseComment("Fix typo", "2025-01-08 19:00", "fixed in the README "); !strings.HasSuffix(got, ".\n\nfixed in the README") {
+		t.Errorf("CloseComment() with notes = %q", got)
+	}
+}
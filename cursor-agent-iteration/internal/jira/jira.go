@@ -0,0 +1,207 @@
+// Package jira imports Jira tickets as tasks for tasks.md and moves them
+// along their workflow once the tasks are done, through Jira's REST API
+// (version 2, which Jira Cloud and Jira Data Center both serve).
+package jira
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/textutil"
+)
+
+// maxContextLen caps the part of a description quoted in its task's Context
+const maxContextLen = 1000
+
+var (
+	// reHeading matches wiki markup headings, "h3. Acceptance Criteria", and
+	// Markdown ones
+	reHeading  = regexp.MustCompile(`^(?:h[1-6]\.|#{1,6})\s+(.*)$`)
+	reListItem = regexp.MustCompile(`^(?:[-*#]+|\d+[.)])\s+(?:\[[ xX]\]\s+)?(.+)$`)
+	reCriteria = regexp.MustCompile(`(?i)^(acceptance criteria|requirements|definition of done)\b`)
+)
+
+// Ticket is a Jira issue as a search returns it
+type Ticket struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Summary     string `json:"summary"`
+		Description string `json:"description"`
+	} `json:"fields"`
+}
+
+// Client talks to the Jira at BaseURL, e.g. https://acme.atlassian.net. With
+// Email set it signs in with basic auth and Token as the API token, as Jira
+// Cloud wants; without, Token is sent as a bearer personal access token, as
+// Jira Data Center wants.
+type Client struct {
+	BaseURL string
+	Email   string
+	Token   string
+	HTTP    *http.Client
+}
+
+// NewClient returns a client for the Jira at baseURL
+func NewClient(baseURL, email, token string) *Client {
+	return &Client{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Email:   email,
+		Token:   token,
+		HTTP:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Search returns the tickets jql finds, at most max of them
+func (c *Client) Search(jql string, max int) ([]Ticket, error) {
+	q := url.Values{"jql": {jql}, "fields": {"summary,description"}, "maxResults": {fmt.Sprint(max)}}
+	var res struct {
+		Issues []Ticket `json:"issues"`
+	}
+	if err := c.do(http.MethodGet, "/rest/api/2/search?"+q.Encode(), nil, &res); err != nil {
+		return nil, err
+	}
+	return res.Issues, nil
+}
+
+// Transition moves a ticket with the transition named name, or the one
+// leading to the status named name, e.g. "Done"
+func (c *Client) Transition(key, name string) error {
+	var res struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+			To   struct {
+				Name string `json:"name"`
+			} `json:"to"`
+		} `json:"transitions"`
+	}
+	path := "/rest/api/2/issue/" + url.PathEscape(key) + "/transitions"
+	if err := c.do(http.MethodGet, path, nil, &res); err != nil {
+		return err
+	}
+	var names []string
+	for _, t := range res.Transitions {
+		if strings.EqualFold(t.Name, name) || strings.EqualFold(t.To.Name, name) {
+			return c.do(http.MethodPost, path, map[string]any{"transition": map[string]string{"id": t.ID}}, nil)
+		}
+		names = append(names, t.Name)
+	}
+	return fmt.Errorf("%s has no transition %q; it can go through %s", key, name, strings.Join(names, ", "))
+}
+
+// Comment adds a comment to a ticket
+func (c *Client) Comment(key, body string) error {
+	return c.do(http.MethodPost, "/rest/api/2/issue/"+url.PathEscape(key)+"/comment", map[string]string{"body": body}, nil)
+}
+
+// do sends a request with the JSON of in, if any, and decodes the response
+// into out, if any
+func (c *Client) do(method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.BaseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Email != "" {
+		req.SetBasicAuth(c.Email, c.Token)
+	} else if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: %s %s", method, strings.SplitN(path, "?", 2)[0], resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Split returns a ticket's description as one line of context, its first
+// paragraph of text, and the list items under its "Acceptance Criteria",
+// "Requirements" or "Definition of Done" heading. Headings may be wiki
+// markup, Markdown, bold or end with a colon.
+func Split(description string) (context string, criteria []string) {
+	in := false
+	for _, para := range strings.Split(strings.ReplaceAll(description, "\r\n", "\n"), "\n\n") {
+		for _, line := range strings.Split(para, "\n") {
+			line = strings.TrimSpace(line)
+			heading := ""
+			if m := reHeading.FindStringSubmatch(line); m != nil {
+				heading = m[1]
+			} else if t := strings.TrimSuffix(line, ":"); len(t) > 2 && strings.HasPrefix(t, "*") && strings.HasSuffix(t, "*") {
+				heading = strings.Trim(t, "*: ")
+			} else if reCriteria.MatchString(line) && strings.HasSuffix(line, ":") {
+				heading = line
+			}
+			if heading != "" {
+				in = reCriteria.MatchString(heading)
+				continue
+			}
+			if m := reListItem.FindStringSubmatch(line); in && m != nil {
+				criteria = append(criteria, strings.TrimRight(strings.TrimSpace(m[1]), "."))
+			}
+		}
+		if context != "" {
+			continue
+		}
+		var words []string
+		for _, line := range strings.Split(para, "\n") {
+			if line = strings.TrimSpace(line); line != "" && !reHeading.MatchString(line) && !reListItem.MatchString(line) {
+				words = append(words, strings.Fields(line)...)
+			}
+		}
+		context = textutil.Shorten(strings.Join(words, " "), maxContextLen)
+	}
+	return context, criteria
+}
+
+// TaskBlock is the tasks.md entry for a ticket of the Jira at baseURL. A
+// ticket without criteria gets one asking for what it describes.
+func TaskBlock(baseURL string, t Ticket) string {
+	context, criteria := Split(t.Fields.Description)
+	if len(criteria) == 0 {
+		criteria = []string{"The behaviour described in the ticket is implemented"}
+	}
+	return tasks.Draft{
+		Title:    Title(t),
+		Context:  strings.TrimSpace(fmt.Sprintf("From Jira ticket %s. %s", t.Key, context)),
+		Criteria: criteria,
+		Source:   BrowseURL(baseURL, t.Key),
+		Labels:   []string{"source:jira"},
+	}.Block()
+}
+
+// Title is the task title of a ticket: its summary
+func Title(t Ticket) string {
+	return strings.Join(strings.Fields(t.Fields.Summary), " ")
+}
+
+// BrowseURL is the page of a ticket
+func BrowseURL(baseURL, key string) string {
+	return strings.TrimRight(baseURL, "/") + "/browse/" + key
+}
@@ -0,0 +1,95 @@
+package jira
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+const sampleDescription = "h2. Summary\nUsers can export their saved\nsearches as CSV.\n\nh3. Acceptance Criteria\n* The export button is on the searches page.\n* The CSV has a header row\n\nh3. Notes\n* Not a criterion\n"
+
+func TestSplit(t *testing.T) {
+	context, criteria := Split(sampleDescription)
+	if context != "Users can export their saved searches as CSV." {
+		t.Errorf("context = %q", context)
+	}
+	want := []string{"The export button is on the searches page", "The CSV has a header row"}
+	if !reflect.DeepEqual(criteria, want) {
+		t.Errorf("criteria = %q, want %q", criteria, want)
+	}
+	if _, criteria := Split("Do it.\n\nAcceptance criteria:\n- works\n"); !reflect.DeepEqual(criteria, []string{"works"}) {
+		t.Errorf("criteria under a colon heading = %q", criteria)
+	}
+}
+
+func TestTaskBlock(t *testing.T) {
+	var ticket Ticket
+	ticket.Key = "APP-12"
+	ticket.Fields.Summary = "Export  saved searches"
+	ticket.Fields.Description = sampleDescription
+	block := TaskBlock("https://acme.atlassian.net/", ticket)
+	parsed := tasks.ParseTasks("## Current Tasks\n\n" + block + "\n")
+	if len(parsed) != 1 || parsed[0].Title != "Export saved searches" || !strings.Contains(parsed[0].Context, "APP-12") {
+		t.Fatalf("ParseTasks() = %+v from:\n%s", parsed, block)
+	}
+	for _, want := range []string{"* [ ] The CSV has a header row", "**Source:** https://acme.atlassian.net/browse/APP-12", "[source:jira]"} {
+		if !strings.Contains(block, want) {
+			t.Errorf("TaskBlock() should contain %q:\n%s", want, block)
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	var posted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "me@acme.com" || pass != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/rest/api/2/search":
+			if r.URL.Query().Get("jql") != "project = APP" {
+				t.Errorf("jql = %q", r.URL.Query().Get("jql"))
+			}
+			w.Write([]byte(`{"issues":[{"key":"APP-1","fields":{"summary":"Login","description":"Add it"}}]}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/rest/api/2/issue/APP-1/transitions":
+			w.Write([]byte(`{"transitions":[{"id":"11","name":"Start","to":{"name":"In Progress"}},{"id":"31","name":"Resolve","to":{"name":"Done"}}]}`))
+		case r.Method == http.MethodPost:
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			data, _ := json.Marshal(body)
+			posted = append(posted, r.URL.Path+" "+string(data))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "me@acme.com", "secret")
+	tickets, err := c.Search("project = APP", 10)
+	if err != nil || len(tickets) != 1 || tickets[0].Key != "APP-1" || tickets[0].Fields.Summary != "Login" {
+		t.Fatalf("Search() = %+v, %v", tickets, err)
+	}
+	if err := c.Transition("APP-1", "done"); err != nil {
+		t.Fatalf("Transition() error = %v", err)
+	}
+	if err := c.Transition("APP-1", "Closed"); err == nil || !strings.Contains(err.Error(), "Start, Resolve") {
+		t.Errorf("Transition() to a missing status = %v", err)
+	}
+	if err := c.Comment("APP-1", "Completed"); err != nil {
+		t.Fatalf("Comment() error = %v", err)
+	}
+	want := []string{`/rest/api/2/issue/APP-1/transitions {"transition":{"id":"31"}}`, `/rest/api/2/issue/APP-1/comment {"body":"Completed"}`}
+	if !reflect.DeepEqual(posted, want) {
+		t.Errorf("posted %q, want %q", posted, want)
+	}
+	if _, err := NewClient(srv.URL, "me@acme.com", "wrong").Search("project = APP", 10); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Search() with a wrong token = %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package journal
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// blockerTail is how much of the end of the output is searched; agents
+// state what they need in their final summary
+const blockerTail = 4000
+
+// DefaultBlockerPatterns match agents saying, in prose, that they cannot go
+// on without a human
+var DefaultBlockerPatterns = []string{
+	`(?i)\bI need (?:[\w'-]+\s+){0,8}from (?:a |the )?(?:human|user|you|maintainers?|developers?)\b`,
+	`(?i)\b(?:requires?|needs?) (?:human|manual) (?:input|intervention|action|approval|review)\b`,
+	`(?i)\b(?:cannot|can't|unable to) (?:proceed|continue) (?:without|until)\b`,
+	`(?i)\bwaiting (?:for|on) (?:a |the )?(?:human|user|you|your)\b`,
+	`(?i)\bblocked (?:on|by|until)\b`,
+	`(?i)\bplease provide\b`,
+}
+
+// CompileBlockerPatterns compiles the default patterns plus extra ones
+func CompileBlockerPatterns(extra []string) ([]*regexp.Regexp, error) {
+	var patterns []*regexp.Regexp
+	for _, p := range append(append([]string{}, DefaultBlockerPatterns...), extra...) {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid blocker pattern %q: %v", p, err)
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns, nil
+}
+
+// ReadBlockerPatterns reads extra patterns from a file, one regular
+// expression per line; blank lines and # comments are skipped. A missing
+// file has no patterns.
+func ReadBlockerPatterns(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
+		}
+	}
+	return patterns, scanner.Err()
+}
+
+var defaultBlockerPatterns, _ = CompileBlockerPatterns(nil)
+
+// FindBlocker returns the sentence near the end of an agent's output in which
+// it says it is blocked on a human, or "" if it says no such thing. The last
+// statement wins.
+func FindBlocker(output string, patterns []*regexp.Regexp) string {
+	if len(output) > blockerTail {
+		output = output[len(output)-blockerTail:]
+	}
+	best, at := "", -1
+	for _, re := range patterns {
+		for _, loc := range re.FindAllStringIndex(output, -1) {
+			if loc[0] > at {
+				best, at = sentenceAround(output, loc[0], loc[1]), loc[0]
+			}
+		}
+	}
+	return best
+}
+
+// sentenceAround expands a match to the sentence or line containing it
+func sentenceAround(text string, start, end int) string {
+	from := strings.LastIndexAny(text[:start], ".!?\n")
+	from++
+	to := strings.IndexAny(text[end:], ".!?\n")
+	if to < 0 {
+		to = len(text)
+	} else {
+		to += end
+		if text[to] != '\n' {
+			to++
+		}
+	}
+	sentence := strings.Join(strings.Fields(text[from:to]), " ")
+	sentence = strings.TrimLeft(sentence, "-*> ")
+	if len(sentence) > 300 {
+		sentence = sentence[:297] + "..."
+	}
+	return sentence
+}
@@ -0,0 +1,94 @@
+package journal
+
+import "strings"
+
+// Failure classifications, roughly in the order they are checked
+const (
+	ClassInterrupted  = "interrupted"   // the loop was stopped while the agent ran
+	ClassAgentMissing = "agent-missing" // the agent CLI isn't installed
+	ClassAuth         = "auth"          // not logged in or invalid credentials
+	ClassRateLimit    = "rate-limit"    // provider throttling or quota
+	ClassTimeout      = "timeout"       // the run was cut off
+	ClassBuild        = "build"         // compilation or type checking failed
+	ClassTests        = "tests"         // tests failed
+	ClassLint         = "lint"          // linters or formatters failed
+	ClassMerge        = "merge"         // git conflicts
+	ClassNeedsHuman   = "needs-human"   // agent stopped to wait for a human
+	ClassRefusal      = "refusal"       // the model declined the task or said nothing
+	ClassIncomplete   = "incomplete"    // agent finished but left the task open
+	ClassUnknown      = "unknown"
+)
+
+// refusalTail is how much of the end of the output is searched for a refusal
+const refusalTail = 2000
+
+// refusalFragments (lower case) are how models decline a request
+var refusalFragments = []string{
+	"i can't help with", "i cannot help with", "i can't assist", "i cannot assist",
+	"i'm unable to help", "i am unable to help", "i'm not able to help", "i won't be able to help",
+	"i can't comply", "i cannot comply", "i must decline", "i'm sorry, but i can't", "i'm sorry, but i cannot",
+}
+
+// classRules map output fragments (lower case) to a classification. Earlier
+// rules win so infrastructure problems are reported before code problems.
+var classRules = []struct {
+	class     string
+	fragments []string
+}{
+	{ClassInterrupted, []string{"agent interrupted"}},
+	{ClassAgentMissing, []string{"cursor-agent not found", "codex cli not found", "claude cli not found", "executable file not found"}},
+	{ClassAuth, []string{"unauthorized", "not logged in", "authentication", "invalid api key", "401"}},
+	{ClassRateLimit, []string{"rate limit", "rate-limit", "too many requests", "quota", "429", "overloaded"}},
+	{ClassTimeout, []string{"timed out", "timeout", "deadline exceeded", "signal: killed"}},
+	{ClassMerge, []string{"merge conflict", "conflict (content)", "automatic merge failed"}},
+	{ClassBuild, []string{"build failed", "compilation failed", "cannot find package", "undefined:", "syntax error", "type error", "error ts"}},
+	{ClassTests, []string{"--- fail", "tests failed", "test failed", "failing tests", "assertionerror", "fail:"}},
+	{ClassLint, []string{"lint", "golangci", "eslint", "gofmt", "prettier"}},
+}
+
+// Classify guesses why a run failed from its error message and the tail of
+// its output
+func Classify(outcome, errMsg, output string) string {
+	if outcome == OutcomeCompleted {
+		return ""
+	}
+	if outcome == OutcomeIncomplete && FindBlocker(output, defaultBlockerPatterns) != "" {
+		return ClassNeedsHuman
+	}
+	if IsRefusal(outcome, output) {
+		return ClassRefusal
+	}
+	text := strings.ToLower(errMsg + "\n" + output)
+	for _, rule := range classRules {
+		for _, fragment := range rule.fragments {
+			if strings.Contains(text, fragment) {
+				return rule.class
+			}
+		}
+	}
+	if outcome == OutcomeIncomplete {
+		return ClassIncomplete
+	}
+	return ClassUnknown
+}
+
+// IsRefusal reports whether the model declined a run that didn't complete
+// its task: it exited cleanly without any output, or ended by refusing
+func IsRefusal(outcome, output string) bool {
+	if outcome == OutcomeCompleted {
+		return false
+	}
+	if outcome == OutcomeIncomplete && strings.TrimSpace(output) == "" {
+		return true
+	}
+	if len(output) > refusalTail {
+		output = output[len(output)-refusalTail:]
+	}
+	text := strings.ToLower(strings.ReplaceAll(output, "’", "'"))
+	for _, fragment := range refusalFragments {
+		if strings.Contains(text, fragment) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package journal
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reCost matches the spend an agent reports, as text ("Total cost: $0.42")
+// or as JSON ("total_cost_usd": 0.42). Without a dollar sign or "usd" a
+// number after "cost" is left alone, since it is likely something else.
+var reCost = regexp.MustCompile(`(?i)(?:total[ _])?cost(?:[ _]usd"?\s*[:=]\s*|"?\s*[:=]\s*\$\s*)(\d[\d,]*(?:\.\d+)?|\.\d+)`)
+
+// ParseCost returns the spend in USD an agent reported at the end of its
+// output, or 0 if it reported none
+func ParseCost(output string) float64 {
+	matches := reCost.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 {
+		return 0
+	}
+	usd, _ := strconv.ParseFloat(strings.ReplaceAll(matches[len(matches)-1][1], ",", ""), 64)
+	return usd
+}
+
+// EstimateTokens estimates the tokens of a run that reported none from the
+// characters of its prompt and output, at about four characters a token
+func EstimateTokens(promptChars, outputChars int64) int64 {
+	if n := promptChars + outputChars; n > 0 {
+		return (n + 3) / 4
+	}
+	return 0
+}
+
+// Cost is the spend of the run: what the agent reported, or else its tokens
+// at the price per million tokens of its model. ok is false when neither is
+// known.
+func (e Entry) Cost(prices map[string]float64) (usd float64, ok bool) {
+	if e.CostUSD > 0 {
+		return e.CostUSD, true
+	}
+	price, priced := prices[e.Model]
+	if !priced || e.Tokens == 0 {
+		return 0, false
+	}
+	return float64(e.Tokens) / 1e6 * price, true
+}
+
+// TaskCost sums the runs of one task
+type TaskCost struct {
+	Task   string
+	Runs   int
+	Tokens int64
+	USD    float64
+	// Estimated reports whether some of the tokens were estimated
+	Estimated bool
+	// Unpriced counts the runs whose cost is unknown, left out of USD
+	Unpriced int
+}
+
+// TaskCosts sums the runs recorded since since per task, most expensive
+// first. runID limits it to the runs of one loop unless it is "".
+func TaskCosts(entries []Entry, since time.Time, runID string, prices map[string]float64) []TaskCost {
+	byTask := make(map[string]*TaskCost)
+	var order []string
+	for _, e := range entries {
+		if e.Time.Before(since) || (runID != "" && e.RunID != runID) {
+			continue
+		}
+		c := byTask[e.Task]
+		if c == nil {
+			c = &TaskCost{Task: e.Task}
+			byTask[e.Task] = c
+			order = append(order, e.Task)
+		}
+		c.Runs++
+		c.Tokens += e.Tokens
+		c.Estimated = c.Estimated || e.TokensEstimated
+		if usd, ok := e.Cost(prices); ok {
+			c.USD += usd
+		} else {
+			c.Unpriced++
+		}
+	}
+	costs := make([]TaskCost, 0, len(order))
+	for _, task := range order {
+		costs = append(costs, *byTask[task])
+	}
+	sort.SliceStable(costs, func(i, j int) bool {
+		if costs[i].USD != costs[j].USD {
+			return costs[i].USD > costs[j].USD
+		}
+		return costs[i].Tokens > costs[j].Tokens
+	})
+	return costs
+}
+
+// Spent is the known spend of the runs of a loop
+func Spent(entries []Entry, runID string, prices map[string]float64) float64 {
+	var usd float64
+	for _, e := range entries {
+		if e.RunID != runID {
+			continue
+		}
+		if cost, ok := e.Cost(prices); ok {
+			usd += cost
+		}
+	}
+	return usd
+}
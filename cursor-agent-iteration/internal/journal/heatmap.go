@@ -0,0 +1,125 @@
+package journal
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Thresholds for flagging an area as fragile
+const (
+	FragileFailureRate = 0.5
+	FragileMinRuns     = 3
+)
+
+// Area aggregates the tasks whose commits changed files under one path
+type Area struct {
+	Path    string
+	Changes int // runs whose commits changed files here
+	Tasks   int // distinct tasks that changed files here
+	Runs    int // all runs of those tasks
+	Failed  int // runs of those tasks that did not complete them
+}
+
+// Retries is the number of runs beyond the first per task
+func (a Area) Retries() int {
+	return a.Runs - a.Tasks
+}
+
+// FailureRate is the share of runs of the tasks touching the area that failed
+func (a Area) FailureRate() float64 {
+	if a.Runs == 0 {
+		return 0
+	}
+	return float64(a.Failed) / float64(a.Runs)
+}
+
+// Fragile reports whether tasks touching the area fail often enough to
+// warrant human ownership or better tests
+func (a Area) Fragile() bool {
+	return a.Runs >= FragileMinRuns && a.FailureRate() >= FragileFailureRate
+}
+
+// AreaOf groups a file into its directory, keeping depth path components.
+// Depth 0 keeps the file itself.
+func AreaOf(file string, depth int) string {
+	if depth <= 0 {
+		return file
+	}
+	dir := path.Dir(file)
+	if dir == "." {
+		return "."
+	}
+	parts := strings.Split(dir, "/")
+	if len(parts) > depth {
+		parts = parts[:depth]
+	}
+	return strings.Join(parts, "/") + "/"
+}
+
+// Heatmap groups the runs since a time by the areas their commits changed.
+// changed returns the files the commits of a run modified. The failures and
+// retries of a task count against every area it changed, so areas where
+// tasks keep failing stand out even when only the final attempt committed.
+func Heatmap(entries []Entry, since time.Time, depth int, changed func(Entry) []string) []Area {
+	type taskStats struct {
+		runs, failed int
+		changes      map[string]int
+	}
+	byTask := make(map[string]*taskStats)
+	var order []string
+	for _, e := range entries {
+		if e.Time.Before(since) {
+			continue
+		}
+		ts, ok := byTask[e.Task]
+		if !ok {
+			ts = &taskStats{changes: make(map[string]int)}
+			byTask[e.Task] = ts
+			order = append(order, e.Task)
+		}
+		ts.runs++
+		if e.Failed() {
+			ts.failed++
+		}
+		if e.HeadBefore == "" || e.HeadAfter == "" || e.HeadBefore == e.HeadAfter {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, f := range changed(e) {
+			if area := AreaOf(f, depth); !seen[area] {
+				seen[area] = true
+				ts.changes[area]++
+			}
+		}
+	}
+
+	areas := make(map[string]*Area)
+	for _, task := range order {
+		ts := byTask[task]
+		for name, changes := range ts.changes {
+			a, ok := areas[name]
+			if !ok {
+				a = &Area{Path: name}
+				areas[name] = a
+			}
+			a.Changes += changes
+			a.Tasks++
+			a.Runs += ts.runs
+			a.Failed += ts.failed
+		}
+	}
+
+	result := make([]Area, 0, len(areas))
+	for _, a := range areas {
+		result = append(result, *a)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Changes != result[j].Changes {
+			return result[i].Changes > result[j].Changes
+		}
+		return result[i].Path < result[j].Path
+	})
+	return result
+}
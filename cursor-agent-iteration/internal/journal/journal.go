@@ -0,0 +1,209 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/toolenv"
+)
+
+// Task run outcomes
+const (
+	OutcomeCompleted  = "completed"  // the task is marked completed in progress.md
+	OutcomeIncomplete = "incomplete" // the agent exited cleanly but the task isn't done
+	OutcomeFailed     = "failed"     // the agent exited with an error
+)
+
+// Entry records one agent run for one task. Time is when the run was
+// recorded, after the task's completion was checked.
+type Entry struct {
+	Time           time.Time `json:"time"`
+	Started        time.Time `json:"started,omitempty"` // when the agent started; missing in older journals
+	RunID          string    `json:"run_id"`
+	Task           string    `json:"task"`
+	Backend        string    `json:"backend"`
+	Model          string    `json:"model"`
+	Labels         []string  `json:"labels,omitempty"`
+	Outcome        string    `json:"outcome"`
+	Error          string    `json:"error,omitempty"`
+	Classification string    `json:"classification,omitempty"`
+	Blocker        string    `json:"blocker,omitempty"`        // what the agent said it needs from a human
+	PromptVariant  string    `json:"prompt_variant,omitempty"` // the prompt ladder rung the run was sent
+	DurationMs     int64     `json:"duration_ms"`
+	Tokens         int64     `json:"tokens,omitempty"`
+	// TokensEstimated is set when the agent reported no token count and
+	// Tokens was estimated from the sizes of the prompt and output
+	TokensEstimated bool    `json:"tokens_estimated,omitempty"`
+	CostUSD         float64 `json:"cost_usd,omitempty"` // the spend the agent reported
+	LogPath         string  `json:"log_path,omitempty"`
+	HeadBefore      string  `json:"head_before,omitempty"`
+	HeadAfter       string  `json:"head_after,omitempty"`
+	// LinesAdded and LinesRemoved are the lines changed between HeadBefore
+	// and HeadAfter, leaving out the control files
+	LinesAdded   int `json:"lines_added,omitempty"`
+	LinesRemoved int `json:"lines_removed,omitempty"`
+	// Env is the toolchain the agent ran under; missing in older journals
+	Env *toolenv.Env `json:"env,omitempty"`
+}
+
+// Failed reports whether the run did not complete its task
+func (e Entry) Failed() bool {
+	return e.Outcome != OutcomeCompleted
+}
+
+// Duration returns the run's duration
+func (e Entry) Duration() time.Duration {
+	return time.Duration(e.DurationMs) * time.Millisecond
+}
+
+// Decision records a human triage decision about a failed task
+type Decision struct {
+	Time   time.Time `json:"time"`
+	Task   string    `json:"task"`
+	Action string    `json:"action"`
+	Detail string    `json:"detail,omitempty"`
+	By     string    `json:"by,omitempty"` // who decided; missing in older logs
+}
+
+// appendMu serializes appends from concurrent task goroutines
+var appendMu sync.Mutex
+
+// Append adds an entry to the JSONL journal at path
+func Append(path string, e Entry) error {
+	return appendJSON(path, e)
+}
+
+// AppendDecision adds a triage decision to the JSONL file at path
+func AppendDecision(path string, d Decision) error {
+	return appendJSON(path, d)
+}
+
+func appendJSON(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	appendMu.Lock()
+	defer appendMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// Read returns all entries in the journal at path, oldest first. A missing
+// journal is empty; malformed lines are skipped.
+func Read(path string) ([]Entry, error) {
+	var entries []Entry
+	err := readJSONL(path, func(line []byte) {
+		var e Entry
+		if json.Unmarshal(line, &e) == nil && e.Task != "" {
+			entries = append(entries, e)
+		}
+	})
+	return entries, err
+}
+
+// ReadDecisions returns all triage decisions at path, oldest first
+func ReadDecisions(path string) ([]Decision, error) {
+	var decisions []Decision
+	err := readJSONL(path, func(line []byte) {
+		var d Decision
+		if json.Unmarshal(line, &d) == nil && d.Task != "" {
+			decisions = append(decisions, d)
+		}
+	})
+	return decisions, err
+}
+
+func readJSONL(path string, fn func(line []byte)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) > 0 {
+			fn(scanner.Bytes())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return nil
+}
+
+// Unresolved returns the latest failed run of each task that has not been
+// followed by a successful run or a triage decision, oldest first
+func Unresolved(entries []Entry, decisions []Decision) []Entry {
+	latest := make(map[string]Entry)
+	for _, e := range entries {
+		latest[e.Task] = e
+	}
+	decided := make(map[string]time.Time)
+	for _, d := range decisions {
+		if d.Time.After(decided[d.Task]) {
+			decided[d.Task] = d.Time
+		}
+	}
+
+	var failed []Entry
+	for task, e := range latest {
+		if !e.Failed() {
+			continue
+		}
+		if t, ok := decided[task]; ok && !t.Before(e.Time) {
+			continue
+		}
+		failed = append(failed, e)
+	}
+	sort.Slice(failed, func(i, j int) bool {
+		if !failed[i].Time.Equal(failed[j].Time) {
+			return failed[i].Time.Before(failed[j].Time)
+		}
+		return failed[i].Task < failed[j].Task
+	})
+	return failed
+}
+
+// Attempts counts the failed runs of a task since it last completed or a
+// triage decision was made about it: how much of its retry budget it has
+// used. Runs stopped by an interrupt don't count.
+func Attempts(entries []Entry, decisions []Decision, task string) int {
+	var since time.Time
+	for _, d := range decisions {
+		if d.Task == task && d.Time.After(since) {
+			since = d.Time
+		}
+	}
+	attempts := 0
+	for _, e := range entries {
+		switch {
+		case e.Task != task || (!since.IsZero() && !e.Time.After(since)):
+		case !e.Failed():
+			attempts = 0
+		case e.Classification != ClassInterrupted:
+			attempts++
+		}
+	}
+	return attempts
+}
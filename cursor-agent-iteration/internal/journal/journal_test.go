@@ -0,0 +1,165 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAppendAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "journal.jsonl")
+
+	if entries, err := Read(path); err != nil || len(entries) != 0 {
+		t.Fatalf("Read() of missing journal = %v, %v; want empty", entries, err)
+	}
+
+	now := time.Now().Truncate(time.Millisecond)
+	want := Entry{Time: now, RunID: "r1", Task: "A", Backend: "codex", Model: "gpt-5-codex", Outcome: OutcomeFailed, Error: "exit status 1", DurationMs: 1500}
+	if err := Append(path, want); err != nil {
+		t.Fatalf("Append() = %v", err)
+	}
+	Append(path, Entry{Time: now, Task: "B", Outcome: OutcomeCompleted})
+
+	// Malformed lines are skipped
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString("{not json\n")
+	f.Close()
+
+	entries, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read() = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	got := entries[0]
+	if got.Task != want.Task || got.Outcome != want.Outcome || !got.Time.Equal(want.Time) || got.Duration() != 1500*time.Millisecond {
+		t.Errorf("entries[0] = %+v, want %+v", got, want)
+	}
+	if !got.Failed() || entries[1].Failed() {
+		t.Errorf("Failed() mismatch: %v, %v", got.Failed(), entries[1].Failed())
+	}
+}
+
+func TestAppendConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Append(path, Entry{Time: time.Now(), Task: "T", Outcome: OutcomeCompleted})
+		}()
+	}
+	wg.Wait()
+	entries, _ := Read(path)
+	if len(entries) != 20 {
+		t.Errorf("Expected 20 entries, got %d", len(entries))
+	}
+}
+
+func TestUnresolved(t *testing.T) {
+	base := time.Now()
+	at := func(minutes int) time.Time { return base.Add(time.Duration(minutes) * time.Minute) }
+
+	entries := []Entry{
+		{Time: at(0), Task: "fixed later", Outcome: OutcomeFailed},
+		{Time: at(1), Task: "fixed later", Outcome: OutcomeCompleted},
+		{Time: at(2), Task: "still failing", Outcome: OutcomeIncomplete},
+		{Time: at(3), Task: "triaged", Outcome: OutcomeFailed},
+		{Time: at(4), Task: "failed after triage", Outcome: OutcomeFailed},
+	}
+	decisions := []Decision{
+		{Time: at(3), Task: "triaged", Action: "block"},
+		{Time: at(1), Task: "failed after triage", Action: "retry"},
+	}
+
+	got := Unresolved(entries, decisions)
+	if len(got) != 2 || got[0].Task != "still failing" || got[1].Task != "failed after triage" {
+		t.Errorf("Unresolved() = %+v", got)
+	}
+}
+
+func TestAttempts(t *testing.T) {
+	base := time.Now()
+	at := func(minutes int) time.Time { return base.Add(time.Duration(minutes) * time.Minute) }
+
+	entries := []Entry{
+		{Time: at(0), Task: "Login", Outcome: OutcomeFailed},
+		{Time: at(1), Task: "Login", Outcome: OutcomeCompleted},
+		{Time: at(2), Task: "Login", Outcome: OutcomeIncomplete},
+		{Time: at(3), Task: "Search", Outcome: OutcomeFailed},
+		{Time: at(4), Task: "Login", Outcome: OutcomeFailed, Classification: ClassInterrupted},
+		{Time: at(5), Task: "Login", Outcome: OutcomeFailed, Classification: ClassTimeout},
+		{Time: at(6), Task: "Search", Outcome: OutcomeIncomplete},
+	}
+	if got := Attempts(entries, nil, "Login"); got != 2 {
+		t.Errorf("Attempts(Login) = %d, want the 2 failed runs since it completed", got)
+	}
+	decisions := []Decision{{Time: at(3), Task: "Search", Action: "retry"}}
+	if got := Attempts(entries, decisions, "Search"); got != 1 {
+		t.Errorf("Attempts(Search) = %d, want only the run after its triage", got)
+	}
+	if got := Attempts(entries, nil, "Docs"); got != 0 {
+		t.Errorf("Attempts() of a task that never ran = %d", got)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		outcome  string
+		err      string
+		output   string
+		expected string
+	}{
+		{OutcomeCompleted, "", "anything", ""},
+		{OutcomeFailed, "cursor-agent not found: exec: \"cursor-agent\": executable file not found in $PATH", "", ClassAgentMissing},
+		{OutcomeFailed, "exit status 1", "Error: 429 Too Many Requests", ClassRateLimit},
+		{OutcomeFailed, "exit status 2", "--- FAIL: TestParse (0.00s)", ClassTests},
+		{OutcomeFailed, "exit status 2", "./main.go:10:2: undefined: foo", ClassBuild},
+		{OutcomeIncomplete, "", "All done for now.", ClassIncomplete},
+		{OutcomeIncomplete, "", "I can't proceed without the Stripe API key.", ClassNeedsHuman},
+		{OutcomeFailed, "exit status 1", "", ClassUnknown},
+		{OutcomeFailed, "agent interrupted", "Running go test ./...", ClassInterrupted},
+		{OutcomeIncomplete, "", "  \n", ClassRefusal},
+		{OutcomeIncomplete, "", "I’m sorry, but I can’t help with that request.", ClassRefusal},
+		{OutcomeFailed, "exit status 1", "I cannot assist with this.", ClassRefusal},
+		{OutcomeCompleted, "", "", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Classify(tt.outcome, tt.err, tt.output); got != tt.expected {
+			t.Errorf("Classify(%q, %q, %q) = %q, want %q", tt.outcome, tt.err, tt.output, got, tt.expected)
+		}
+	}
+}
+
+func TestFindBlocker(t *testing.T) {
+	patterns, err := CompileBlockerPatterns([]string{`(?i)\bmissing credentials\b`})
+	if err != nil {
+		t.Fatalf("CompileBlockerPatterns() error = %v", err)
+	}
+	tests := []struct {
+		name     string
+		output   string
+		expected string
+	}{
+		{"none", "Implemented the parser. All tests pass.", ""},
+		{"need from human", "Updated the schema.\nI need the production database URL from a human before running the migration. Stopping here.", "I need the production database URL from a human before running the migration."},
+		{"last statement wins", "Blocked on the API design.\nResolved that.\n- Cannot continue until the staging bucket exists", "Cannot continue until the staging bucket exists"},
+		{"custom pattern", "Deploy step skipped: missing credentials for the registry", "Deploy step skipped: missing credentials for the registry"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FindBlocker(tt.output, patterns); got != tt.expected {
+				t.Errorf("FindBlocker() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+
+	if _, err := CompileBlockerPatterns([]string{"("}); err == nil {
+		t.Errorf("Expected an error for an invalid pattern")
+	}
+}
@@ -0,0 +1,209 @@
+package journal
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stats periods
+const (
+	PeriodAll   = ""
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+// NoLabel groups runs of tasks without labels when stats are split by label
+const NoLabel = "-"
+
+var reTokens = regexp.MustCompile(`(?i)(?:tokens used|total[ _]tokens)"?\s*[:=]?\s*([\d,]+)`)
+
+// ParseTokens returns the token count an agent reported at the end of its
+// output ("tokens used: 12,345" or "total_tokens": 12345), or 0 if it
+// reported none
+func ParseTokens(output string) int64 {
+	matches := reTokens.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 {
+		return 0
+	}
+	n, _ := strconv.ParseInt(strings.ReplaceAll(matches[len(matches)-1][1], ",", ""), 10, 64)
+	return n
+}
+
+// StatsKey identifies a group of runs
+type StatsKey struct {
+	Period  string // start of the period, e.g. "2025-01-06" for a week
+	Backend string
+	Model   string
+	Label   string // empty unless split by label
+}
+
+// Stats aggregates the runs of one group
+type Stats struct {
+	StatsKey
+	Runs       int
+	Completed  int
+	Failed     int
+	Incomplete int
+	Duration   time.Duration
+	Tokens     int64
+
+	tasks map[string]bool // tasks completed in this group
+}
+
+// SuccessRate is the share of runs that completed their task
+func (s Stats) SuccessRate() float64 {
+	if s.Runs == 0 {
+		return 0
+	}
+	return float64(s.Completed) / float64(s.Runs)
+}
+
+// AvgAttempts is the number of runs per completed task, or 0 if no task
+// was completed
+func (s Stats) AvgAttempts() float64 {
+	if len(s.tasks) == 0 {
+		return 0
+	}
+	return float64(s.Runs) / float64(len(s.tasks))
+}
+
+// AvgDuration is the mean run duration
+func (s Stats) AvgDuration() time.Duration {
+	if s.Runs == 0 {
+		return 0
+	}
+	return s.Duration / time.Duration(s.Runs)
+}
+
+// Cost estimates the spend of the group from a price per million tokens
+func (s Stats) Cost(pricePerMillion float64) float64 {
+	return float64(s.Tokens) / 1e6 * pricePerMillion
+}
+
+// StatsOptions selects and groups the runs to aggregate
+type StatsOptions struct {
+	Since   time.Time // ignore runs before this time
+	Period  string    // PeriodAll, PeriodDay, PeriodWeek or PeriodMonth
+	ByLabel bool      // split groups by task label
+}
+
+// PeriodStart returns the label of the period t falls in
+func PeriodStart(t time.Time, period string) string {
+	t = t.Local()
+	switch period {
+	case PeriodDay:
+		return t.Format("2006-01-02")
+	case PeriodWeek:
+		offset := (int(t.Weekday()) + 6) % 7 // weeks start on Monday
+		return t.AddDate(0, 0, -offset).Format("2006-01-02")
+	case PeriodMonth:
+		return t.Format("2006-01")
+	}
+	return ""
+}
+
+// ValidPeriod reports whether period is a known stats period
+func ValidPeriod(period string) error {
+	switch period {
+	case PeriodAll, PeriodDay, PeriodWeek, PeriodMonth:
+		return nil
+	}
+	return fmt.Errorf("unknown period %q (use day, week or month)", period)
+}
+
+// ComputeStats aggregates journal entries per period, backend, model and
+// optionally label. Groups are sorted by period, then backend, model and label.
+func ComputeStats(entries []Entry, opts StatsOptions) []Stats {
+	groups := make(map[StatsKey]*Stats)
+	for _, e := range entries {
+		if e.Time.Before(opts.Since) {
+			continue
+		}
+		labels := []string{""}
+		if opts.ByLabel {
+			labels = e.Labels
+			if len(labels) == 0 {
+				labels = []string{NoLabel}
+			}
+		}
+		for _, label := range labels {
+			key := StatsKey{Period: PeriodStart(e.Time, opts.Period), Backend: e.Backend, Model: e.Model, Label: label}
+			s, ok := groups[key]
+			if !ok {
+				s = &Stats{StatsKey: key, tasks: make(map[string]bool)}
+				groups[key] = s
+			}
+			s.Runs++
+			s.Duration += e.Duration()
+			s.Tokens += e.Tokens
+			switch e.Outcome {
+			case OutcomeCompleted:
+				s.Completed++
+				s.tasks[e.Task] = true
+			case OutcomeFailed:
+				s.Failed++
+			default:
+				s.Incomplete++
+			}
+		}
+	}
+
+	stats := make([]Stats, 0, len(groups))
+	for _, s := range groups {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		a, b := stats[i].StatsKey, stats[j].StatsKey
+		if a.Period != b.Period {
+			return a.Period < b.Period
+		}
+		if a.Backend != b.Backend {
+			return a.Backend < b.Backend
+		}
+		if a.Model != b.Model {
+			return a.Model < b.Model
+		}
+		return a.Label < b.Label
+	})
+	return stats
+}
+
+// EstimateDuration returns the median duration of completed runs similar to
+// a new one: runs of the same task, else runs sharing one of its labels on
+// the same backend, else any run on the backend. It returns 0 without
+// history.
+func EstimateDuration(entries []Entry, task string, labels []string, backend string) time.Duration {
+	sameTask := func(e Entry) bool { return e.Task == task }
+	sameLabel := func(e Entry) bool {
+		if e.Backend != backend {
+			return false
+		}
+		for _, l := range e.Labels {
+			for _, want := range labels {
+				if l == want {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	sameBackend := func(e Entry) bool { return e.Backend == backend }
+	for _, similar := range []func(Entry) bool{sameTask, sameLabel, sameBackend} {
+		var durations []time.Duration
+		for _, e := range entries {
+			if e.Outcome == OutcomeCompleted && similar(e) {
+				durations = append(durations, e.Duration())
+			}
+		}
+		if len(durations) > 0 {
+			sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+			return durations[len(durations)/2]
+		}
+	}
+	return 0
+}
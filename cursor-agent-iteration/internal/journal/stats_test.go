@@ -0,0 +1,190 @@
+package journal
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestParseTokens(t *testing.T) {
+	tests := []struct {
+		output   string
+		expected int64
+	}{
+		{"working...\ntokens used: 12,345\n", 12345},
+		{"Total tokens: 10\n...\ntotal tokens: 42", 42},
+		{"no usage reported", 0},
+		{`{"type":"result","usage":{"total_tokens": 1500}}`, 1500},
+	}
+	for _, tt := range tests {
+		if got := ParseTokens(tt.output); got != tt.expected {
+			t.Errorf("ParseTokens(%q) = %d, want %d", tt.output, got, tt.expected)
+		}
+	}
+}
+
+func TestParseCost(t *testing.T) {
+	tests := []struct {
+		output   string
+		expected float64
+	}{
+		{"Total cost: $0.42\n", 0.42},
+		{`{"total_cost_usd": 1.5}` + "\ncost: $2", 2},
+		{"cut the cost: 3 queries instead of 5", 0},
+		{"no usage reported", 0},
+	}
+	for _, tt := range tests {
+		if got := ParseCost(tt.output); got != tt.expected {
+			t.Errorf("ParseCost(%q) = %v, want %v", tt.output, got, tt.expected)
+		}
+	}
+}
+
+func TestTaskCosts(t *testing.T) {
+	now := time.Date(2025, 1, 6, 12, 0, 0, 0, time.Local)
+	prices := map[string]float64{"gpt-5": 10}
+	entries := []Entry{
+		{Time: now.Add(-48 * time.Hour), RunID: "r0", Task: "Old", Model: "gpt-5", Tokens: 1e6},
+		{Time: now, RunID: "r1", Task: "A", Model: "gpt-5", Tokens: 100000},
+		{Time: now, RunID: "r1", Task: "B", Model: "auto", Tokens: 4000, CostUSD: 3},
+		{Time: now, RunID: "r2", Task: "A", Model: "gpt-5", Tokens: 50000, TokensEstimated: true},
+		{Time: now, RunID: "r2", Task: "C", Model: "auto", Tokens: 800},
+	}
+	costs := TaskCosts(entries, now.Add(-time.Hour), "", prices)
+	expected := []TaskCost{
+		{Task: "B", Runs: 1, Tokens: 4000, USD: 3},
+		{Task: "A", Runs: 2, Tokens: 150000, USD: 1.5, Estimated: true},
+		{Task: "C", Runs: 1, Tokens: 800, Unpriced: 1},
+	}
+	if !reflect.DeepEqual(costs, expected) {
+		t.Errorf("TaskCosts() = %+v, want %+v", costs, expected)
+	}
+	if got := TaskCosts(entries, time.Time{}, "r2", prices); len(got) != 2 || got[0].Task != "A" || got[0].USD != 0.5 {
+		t.Errorf("TaskCosts() of one loop = %+v", got)
+	}
+	if got := Spent(entries, "r1", prices); got != 4 {
+		t.Errorf("Spent() = %v, want 4", got)
+	}
+	if got := EstimateTokens(4000, 3); got != 1001 {
+		t.Errorf("EstimateTokens() = %d, want 1001", got)
+	}
+}
+
+func TestComputeStats(t *testing.T) {
+	monday := time.Date(2025, 1, 6, 12, 0, 0, 0, time.Local)
+	entries := []Entry{
+		{Time: monday, Task: "A", Backend: "cursor-agent", Model: "auto", Outcome: OutcomeFailed, DurationMs: 1000, Labels: []string{"type:bug"}},
+		{Time: monday.Add(time.Hour), Task: "A", Backend: "cursor-agent", Model: "auto", Outcome: OutcomeCompleted, DurationMs: 3000, Tokens: 2000, Labels: []string{"type:bug"}},
+		{Time: monday.Add(2 * time.Hour), Task: "B", Backend: "cursor-agent", Model: "auto", Outcome: OutcomeIncomplete, DurationMs: 2000},
+		{Time: monday.AddDate(0, 0, 8), Task: "C", Backend: "codex", Model: "gpt-5-codex", Outcome: OutcomeCompleted, DurationMs: 4000},
+		{Time: monday.AddDate(0, 0, -30), Task: "old", Backend: "codex", Model: "gpt-5-codex", Outcome: OutcomeFailed},
+	}
+
+	stats := ComputeStats(entries, StatsOptions{Since: monday.AddDate(0, 0, -1)})
+	if len(stats) != 2 {
+		t.Fatalf("Expected 2 groups, got %+v", stats)
+	}
+	auto := stats[1]
+	if auto.Model != "auto" || auto.Runs != 3 || auto.Completed != 1 || auto.Failed != 1 || auto.Incomplete != 1 {
+		t.Errorf("Unexpected auto stats %+v", auto)
+	}
+	if auto.AvgAttempts() != 3 || auto.AvgDuration() != 2*time.Second || auto.Tokens != 2000 {
+		t.Errorf("AvgAttempts() = %v, AvgDuration() = %v, Tokens = %d", auto.AvgAttempts(), auto.AvgDuration(), auto.Tokens)
+	}
+	if rate := auto.SuccessRate(); rate < 0.33 || rate > 0.34 {
+		t.Errorf("SuccessRate() = %v", rate)
+	}
+	if cost := auto.Cost(5); cost != 0.01 {
+		t.Errorf("Cost(5) = %v, want 0.01", cost)
+	}
+
+	weekly := ComputeStats(entries, StatsOptions{Period: PeriodWeek, Since: monday})
+	if len(weekly) != 2 || weekly[0].Period != "2025-01-06" || weekly[1].Period != "2025-01-13" {
+		t.Errorf("Unexpected weekly groups %+v", weekly)
+	}
+
+	byLabel := ComputeStats(entries[:3], StatsOptions{ByLabel: true})
+	if len(byLabel) != 2 || byLabel[0].Label != NoLabel || byLabel[1].Label != "type:bug" || byLabel[1].Runs != 2 {
+		t.Errorf("Unexpected label groups %+v", byLabel)
+	}
+}
+
+func TestEstimateDuration(t *testing.T) {
+	run := func(task, backend string, minutes int64, outcome string, labels ...string) Entry {
+		return Entry{Task: task, Backend: backend, DurationMs: minutes * 60000, Outcome: outcome, Labels: labels}
+	}
+	entries := []Entry{
+		run("Login", "codex", 10, OutcomeCompleted, "type:feature"),
+		run("Login", "codex", 30, OutcomeFailed, "type:feature"),
+		run("Signup", "codex", 4, OutcomeCompleted, "type:feature"),
+		run("Signup", "codex", 6, OutcomeCompleted, "type:feature"),
+		run("Fix typo", "codex", 1, OutcomeCompleted, "type:bug"),
+		run("Docs", "cursor-agent", 2, OutcomeCompleted),
+	}
+	tests := []struct {
+		name     string
+		task     string
+		labels   []string
+		backend  string
+		expected time.Duration
+	}{
+		{"same task", "Login", nil, "codex", 10 * time.Minute},
+		{"shared label", "Search", []string{"type:feature"}, "codex", 6 * time.Minute},
+		{"same backend", "Search", []string{"type:perf"}, "codex", 6 * time.Minute},
+		{"other backend", "Search", nil, "cursor-agent", 2 * time.Minute},
+		{"no history", "Search", nil, "claude", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EstimateDuration(entries, tt.task, tt.labels, tt.backend); got != tt.expected {
+				t.Errorf("EstimateDuration() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHeatmap(t *testing.T) {
+	at := time.Date(2025, 1, 8, 12, 0, 0, 0, time.UTC)
+	run := func(task, outcome, after string) Entry {
+		return Entry{Time: at, Task: task, Outcome: outcome, HeadBefore: "a", HeadAfter: after}
+	}
+	entries := []Entry{
+		{Time: at.AddDate(0, 0, -30), Task: "Old", Outcome: OutcomeCompleted, HeadBefore: "a", HeadAfter: "old"},
+		run("Parser", OutcomeFailed, "a"),
+		run("Parser", OutcomeIncomplete, "p1"),
+		run("Parser", OutcomeCompleted, "p2"),
+		run("Docs", OutcomeCompleted, "d1"),
+	}
+	files := map[string][]string{
+		"old": {"legacy/x.go"},
+		"p1":  {"internal/parser/lex.go", "internal/parser/parse.go"},
+		"p2":  {"internal/parser/parse.go", "go.mod"},
+		"d1":  {"README.md", "docs/guide.md"},
+	}
+	areas := Heatmap(entries, at.AddDate(0, 0, -7), 2, func(e Entry) []string { return files[e.HeadAfter] })
+
+	expected := []Area{
+		{Path: "internal/parser/", Changes: 2, Tasks: 1, Runs: 3, Failed: 2},
+		{Path: ".", Changes: 2, Tasks: 2, Runs: 4, Failed: 2},
+		{Path: "docs/", Changes: 1, Tasks: 1, Runs: 1, Failed: 0},
+	}
+	sortAreas := func(a []Area) {
+		sort.Slice(a, func(i, j int) bool { return a[i].Path < a[j].Path })
+	}
+	sortAreas(areas)
+	sortAreas(expected)
+	if !reflect.DeepEqual(areas, expected) {
+		t.Fatalf("Heatmap() = %+v, want %+v", areas, expected)
+	}
+	if !areas[0].Fragile() || areas[1].Fragile() || !areas[2].Fragile() || areas[2].Retries() != 2 {
+		t.Errorf("Unexpected fragility or retries: %+v", areas)
+	}
+
+	if got := AreaOf("a/b/c/d.go", 0); got != "a/b/c/d.go" {
+		t.Errorf("AreaOf(depth 0) = %q", got)
+	}
+	if got := AreaOf("a/b/c/d.go", 1); got != "a/" {
+		t.Errorf("AreaOf(depth 1) = %q", got)
+	}
+}
@@ -0,0 +1,91 @@
+// Package jsonlog writes the events of an iterate loop as JSON lines, one
+// object per event, so log shippers such as Loki or Datadog can index them
+// instead of scraping the human-readable output.
+package jsonlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Log formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Event names
+const (
+	LoopStarted    = "loop_started"
+	LoopFinished   = "loop_finished"
+	IterationTick  = "iteration_tick"
+	TaskStarted    = "task_started"
+	TaskCompleted  = "task_completed"
+	TaskIncomplete = "task_incomplete"
+	TaskFailed     = "task_failed"
+	TaskBlocked    = "task_blocked"
+	TaskExhausted  = "task_exhausted" // a task was moved to Failed after its last allowed attempt
+	AgentRetry     = "agent_retry"
+)
+
+// Fields are the attributes of an event. The "time" and "event" keys are
+// written by the Logger and must not be set.
+type Fields map[string]any
+
+// Logger writes events to a writer, one JSON object per line
+type Logger struct {
+	mu  sync.Mutex
+	w   io.Writer
+	now func() time.Time
+}
+
+// New returns a Logger writing to w
+func New(w io.Writer) *Logger {
+	return &Logger{w: w, now: time.Now}
+}
+
+// ParseFormat validates a --log-format value
+func ParseFormat(format string) (string, error) {
+	switch format {
+	case FormatText, FormatJSON:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown log format %q (supported: %s, %s)", format, FormatText, FormatJSON)
+}
+
+// Log writes one event. A nil Logger writes nothing, so callers can log
+// whether or not JSON output is on.
+func (l *Logger) Log(event string, fields Fields) {
+	if l == nil {
+		return
+	}
+	line, err := Encode(l.now(), event, fields)
+	if err != nil {
+		line, _ = Encode(l.now(), event, Fields{"encode_error": err.Error()})
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(append(line, '\n'))
+}
+
+// Encode renders an event as a JSON object whose first keys are the UTC time
+// and the event name, followed by the fields in key order
+func Encode(at time.Time, event string, fields Fields) ([]byte, error) {
+	head, err := json.Marshal(struct {
+		Time  string `json:"time"`
+		Event string `json:"event"`
+	}{at.UTC().Format(time.RFC3339Nano), event})
+	if err != nil || len(fields) == 0 {
+		return head, err
+	}
+	rest, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	// Splice the two objects: drop the closing brace of the head and the
+	// opening brace of the fields
+	return append(append(head[:len(head)-1], ','), rest[1:]...), nil
+}
@@ -0,0 +1,58 @@
+package jsonlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncode(t *testing.T) {
+	at := time.Date(2025, 1, 8, 19, 4, 5, 0, time.FixedZone("CET", 3600))
+	got, err := Encode(at, TaskCompleted, Fields{"task": "Login form", "duration_ms": 252400})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"time":"2025-01-08T18:04:05Z","event":"task_completed","duration_ms":252400,"task":"Login form"}`
+	if string(got) != want {
+		t.Errorf("Encode() = %s, want %s", got, want)
+	}
+	if got, _ := Encode(at, IterationTick, nil); string(got) != `{"time":"2025-01-08T18:04:05Z","event":"iteration_tick"}` {
+		t.Errorf("Encode(no fields) = %s", got)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf)
+	l.Log(TaskStarted, Fields{"task": "A"})
+	l.Log(AgentRetry, Fields{"bad": func() {}})
+	var nilLogger *Logger
+	nilLogger.Log(TaskStarted, nil) // must not panic
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %q", buf.String())
+	}
+	for _, line := range lines {
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Errorf("invalid JSON %q: %v", line, err)
+		}
+	}
+	if !strings.Contains(lines[1], `"event":"agent_retry","encode_error"`) {
+		t.Errorf("Expected an encode error, got %s", lines[1])
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, f := range []string{FormatText, FormatJSON} {
+		if got, err := ParseFormat(f); err != nil || got != f {
+			t.Errorf("ParseFormat(%q) = %q, %v", f, got, err)
+		}
+	}
+	if _, err := ParseFormat("yaml"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
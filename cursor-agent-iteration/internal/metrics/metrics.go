@@ -0,0 +1,230 @@
+// Package metrics keeps counters, gauges and histograms and serves them in
+// the Prometheus text exposition format, without a client library
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Metric types, as written on their TYPE lines
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// Registry holds metrics and writes them in the order they were registered
+type Registry struct {
+	mu      sync.Mutex
+	metrics []*metric
+}
+
+// NewRegistry returns an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// metric is one named metric with a series per combination of label values
+type metric struct {
+	name, help, kind string
+	labels           []string
+	buckets          []float64 // histograms only
+	fn               func() float64
+	series           map[string]*series
+}
+
+// series is the state of one combination of label values
+type series struct {
+	values []string
+	value  float64  // counters and gauges
+	counts []uint64 // histograms: observations per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// Counter is a value that only goes up
+type Counter struct {
+	r *Registry
+	m *metric
+}
+
+// Gauge is a value that goes up and down
+type Gauge struct {
+	r *Registry
+	m *metric
+}
+
+// Histogram counts observations in buckets
+type Histogram struct {
+	r *Registry
+	m *metric
+}
+
+func (r *Registry) register(m *metric) *metric {
+	m.series = make(map[string]*series)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+	return m
+}
+
+// Counter registers a counter with the given label names
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	return &Counter{r, r.register(&metric{name: name, help: help, kind: typeCounter, labels: labels})}
+}
+
+// Gauge registers a gauge with the given label names
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r, r.register(&metric{name: name, help: help, kind: typeGauge, labels: labels})}
+}
+
+// GaugeFunc registers a gauge without labels whose value is read from f on
+// every scrape
+func (r *Registry) GaugeFunc(name, help string, f func() float64) {
+	r.register(&metric{name: name, help: help, kind: typeGauge, fn: f})
+}
+
+// Histogram registers a histogram with the given upper bucket bounds, in
+// increasing order, and label names
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return &Histogram{r, r.register(&metric{name: name, help: help, kind: typeHistogram, labels: labels, buckets: buckets})}
+}
+
+// seriesFor returns the series of the label values, creating it. Must be
+// called with the registry's mutex held.
+func (m *metric) seriesFor(values []string) *series {
+	if len(values) != len(m.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", m.name, len(m.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := m.series[key]
+	if !ok {
+		s = &series{values: append([]string(nil), values...)}
+		if m.kind == typeHistogram {
+			s.counts = make([]uint64, len(m.buckets))
+		}
+		m.series[key] = s
+	}
+	return s
+}
+
+// Add adds v to the counter of the label values
+func (c *Counter) Add(v float64, values ...string) {
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+	c.m.seriesFor(values).value += v
+}
+
+// Inc adds one to the counter of the label values
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Set sets the gauge of the label values
+func (g *Gauge) Set(v float64, values ...string) {
+	g.r.mu.Lock()
+	defer g.r.mu.Unlock()
+	g.m.seriesFor(values).value = v
+}
+
+// Observe records one observation in the histogram of the label values
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.r.mu.Lock()
+	defer h.r.mu.Unlock()
+	s := h.m.seriesFor(values)
+	for i, bound := range h.m.buckets {
+		if v <= bound {
+			s.counts[i]++
+			break
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+// WriteTo writes every metric in the text exposition format. Series are
+// sorted by their label values.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	r.mu.Lock()
+	for _, m := range r.metrics {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", m.name, escapeHelp(m.help), m.name, m.kind)
+		if m.fn != nil {
+			fmt.Fprintf(&b, "%s %s\n", m.name, formatValue(m.fn()))
+			continue
+		}
+		keys := make([]string, 0, len(m.series))
+		for k := range m.series {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			s := m.series[k]
+			if m.kind != typeHistogram {
+				fmt.Fprintf(&b, "%s%s %s\n", m.name, labelSet(m.labels, s.values, "", ""), formatValue(s.value))
+				continue
+			}
+			var cumulative uint64
+			for i, bound := range m.buckets {
+				cumulative += s.counts[i]
+				fmt.Fprintf(&b, "%s_bucket%s %d\n", m.name, labelSet(m.labels, s.values, "le", formatValue(bound)), cumulative)
+			}
+			fmt.Fprintf(&b, "%s_bucket%s %d\n", m.name, labelSet(m.labels, s.values, "le", "+Inf"), s.count)
+			fmt.Fprintf(&b, "%s_sum%s %s\n", m.name, labelSet(m.labels, s.values, "", ""), formatValue(s.sum))
+			fmt.Fprintf(&b, "%s_count%s %d\n", m.name, labelSet(m.labels, s.values, "", ""), s.count)
+		}
+	}
+	r.mu.Unlock()
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Handler serves the registry to Prometheus scrapes
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteTo(w)
+	})
+}
+
+// labelSet renders {name="value",...}, with an extra label when extraName is
+// set, or "" when there are no labels
+func labelSet(names, values []string, extraName, extraValue string) string {
+	var parts []string
+	for i, name := range names {
+		parts = append(parts, name+`="`+escapeLabel(values[i])+`"`)
+	}
+	if extraName != "" {
+		parts = append(parts, extraName+`="`+extraValue+`"`)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func escapeHelp(s string) string { return helpEscaper.Replace(s) }
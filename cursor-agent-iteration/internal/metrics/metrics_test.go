@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	r := NewRegistry()
+	runs := r.Counter("runs_total", "Agent runs.", "backend", "outcome")
+	r.GaugeFunc("active", "Running tasks.", func() float64 { return 2 })
+	tasks := r.Gauge("tasks", "Tasks by status.", "status")
+	durations := r.Histogram("duration_seconds", "Run durations.", []float64{10, 60}, "backend")
+
+	runs.Inc("codex", "failed")
+	runs.Add(2, "cursor-agent", "completed")
+	tasks.Set(3, "pending")
+	tasks.Set(1, "pending")
+	durations.Observe(5, "codex")
+	durations.Observe(30, "codex")
+	durations.Observe(90, "codex")
+
+	var b strings.Builder
+	r.WriteTo(&b)
+	want := `# HELP runs_total Agent runs.
+# TYPE runs_total counter
+runs_total{backend="codex",outcome="failed"} 1
+runs_total{backend="cursor-agent",outcome="completed"} 2
+# HELP active Running tasks.
+# TYPE active gauge
+active 2
+# HELP tasks Tasks by status.
+# TYPE tasks gauge
+tasks{status="pending"} 1
+# HELP duration_seconds Run durations.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{backend="codex",le="10"} 1
+duration_seconds_bucket{backend="codex",le="60"} 2
+duration_seconds_bucket{backend="codex",le="+Inf"} 3
+duration_seconds_sum{backend="codex"} 125
+duration_seconds_count{backend="codex"} 3
+`
+	if b.String() != want {
+		t.Errorf("WriteTo() =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("c", "Help with \\ and\nnewline.", "task").Inc("say \"hi\"\n")
+	var b strings.Builder
+	r.WriteTo(&b)
+	for _, want := range []string{`# HELP c Help with \\ and\nnewline.`, `c{task="say \"hi\"\n"} 1`} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("Expected %s in:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("retries_total", "Retries.").Inc()
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") || !strings.Contains(string(body), "retries_total 1") {
+		t.Errorf("scrape = %s %q", rec.Header().Get("Content-Type"), body)
+	}
+}
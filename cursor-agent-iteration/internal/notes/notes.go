@@ -0,0 +1,140 @@
+// Package notes turns finished task runs into short implementation notes,
+// building a searchable record of the work done by the agents
+package notes
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultDir is where notes are written, relative to the repository root
+const DefaultDir = "docs/autopilot"
+
+// indexFile lists the notes in a directory
+const indexFile = "README.md"
+
+// Limits on how much of the diff and transcript go into the prompt
+const (
+	maxDiff       = 40000
+	maxTranscript = 16000
+)
+
+// Input is what a note is written from
+type Input struct {
+	Title      string
+	Details    string // the task block from tasks.md
+	Diff       string // the changes made while working on the task
+	Transcript string // the agent's output
+}
+
+// Prompt asks an agent to summarize a finished task as a note
+func Prompt(in Input) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Write a short implementation note for the completed task %q.\n\n", in.Title)
+	b.WriteString(`Reply with Markdown only and do not modify any files. Use this structure:
+
+# <task title>
+
+## What changed
+The files and behaviour that changed, in a few bullets.
+
+## Why
+The reasoning behind the approach, including alternatives that were rejected.
+
+## Gotchas
+Anything surprising a future maintainer should know: edge cases, workarounds, follow-ups. Write "None" if there are none.
+
+Keep it under 300 words and only state what the material below supports.
+`)
+	if in.Details != "" {
+		fmt.Fprintf(&b, "\n## Task\n\n%s\n", strings.TrimSpace(in.Details))
+	}
+	if in.Diff != "" {
+		fmt.Fprintf(&b, "\n## Diff\n\n```diff\n%s\n```\n", head(strings.TrimSpace(in.Diff), maxDiff))
+	}
+	if in.Transcript != "" {
+		fmt.Fprintf(&b, "\n## Agent transcript (end)\n\n```\n%s\n```\n", tail(strings.TrimSpace(in.Transcript), maxTranscript))
+	}
+	return b.String()
+}
+
+// Clean extracts the note from an agent's reply: it drops anything before
+// the first heading and a surrounding code fence, and makes sure the note
+// starts with the task title
+func Clean(title string, output string) string {
+	text := strings.TrimSpace(output)
+	if fenced, ok := strings.CutPrefix(text, "```"); ok {
+		if _, rest, found := strings.Cut(fenced, "\n"); found {
+			text = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(rest), "```"))
+		}
+	}
+	if i := strings.Index(text, "\n#"); i >= 0 && !strings.HasPrefix(text, "#") {
+		text = text[i+1:]
+	}
+	if !strings.HasPrefix(text, "# ") {
+		text = "# " + title + "\n\n" + text
+	}
+	return text + "\n"
+}
+
+// Write saves a note as <slug>.md in dir and refreshes the index
+func Write(dir string, slug string, note string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, slug+".md")
+	if err := os.WriteFile(path, []byte(note), 0644); err != nil {
+		return "", err
+	}
+	return path, Index(dir)
+}
+
+// Index rewrites the README.md of dir with a link to every note, by title
+func Index(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.md"))
+	if err != nil {
+		return err
+	}
+	type entry struct{ title, file string }
+	var entries []entry
+	for _, f := range files {
+		name := filepath.Base(f)
+		if name == indexFile {
+			continue
+		}
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		title := strings.TrimSuffix(name, ".md")
+		if line, _, _ := strings.Cut(string(data), "\n"); strings.HasPrefix(line, "# ") {
+			title = strings.TrimSpace(strings.TrimPrefix(line, "# "))
+		}
+		entries = append(entries, entry{title, name})
+	}
+	sort.Slice(entries, func(i, j int) bool { return strings.ToLower(entries[i].title) < strings.ToLower(entries[j].title) })
+
+	var b strings.Builder
+	b.WriteString("# Autopilot notes\n\nImplementation notes for tasks completed by cursor-iter, one file per task.\n\n")
+	for _, e := range entries {
+		fmt.Fprintf(&b, "- [%s](%s)\n", e.title, e.file)
+	}
+	return os.WriteFile(filepath.Join(dir, indexFile), []byte(b.String()), 0644)
+}
+
+func head(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "\n... (truncated)"
+}
+
+func tail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return "(truncated) ...\n" + s[len(s)-n:]
+}
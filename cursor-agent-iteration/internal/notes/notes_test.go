@@ -0,0 +1,60 @@
+package notes
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrompt(t *testing.T) {
+	prompt := Prompt(Input{
+		Title:      "Add login",
+		Details:    "### Task: Add login\n* [x] Users can log in",
+		Diff:       "+func Login() {}",
+		Transcript: strings.Repeat("x", maxTranscript+10) + "done",
+	})
+	for _, want := range []string{`"Add login"`, "do not modify any files", "## Gotchas", "* [x] Users can log in", "+func Login() {}", "(truncated) ...", "done"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("Expected prompt to contain %q", want)
+		}
+	}
+	if strings.Contains(Prompt(Input{Title: "t"}), "## Diff") {
+		t.Errorf("Expected no diff section without a diff")
+	}
+}
+
+func TestClean(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{"plain", "# Add login\n\n## Why\nBecause", "# Add login\n\n## Why\nBecause\n"},
+		{"chatter", "Here is the note:\n# Add login\nBody", "# Add login\nBody\n"},
+		{"fenced", "```markdown\n# Add login\nBody\n```", "# Add login\nBody\n"},
+		{"no heading", "## Why\nBecause", "# Add login\n\n## Why\nBecause\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Clean("Add login", tt.output); got != tt.want {
+				t.Errorf("Clean() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteAndIndex(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "docs", "autopilot")
+	if _, err := Write(dir, "zeta", "# Zeta task\n"); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	path, err := Write(dir, "alpha", "# Alpha task\n")
+	if err != nil || path != filepath.Join(dir, "alpha.md") {
+		t.Fatalf("Write() = %q, %v", path, err)
+	}
+	index, _ := os.ReadFile(filepath.Join(dir, indexFile))
+	if !strings.Contains(string(index), "- [Alpha task](alpha.md)\n- [Zeta task](zeta.md)\n") {
+		t.Errorf("Unexpected index:\n%s", index)
+	}
+}
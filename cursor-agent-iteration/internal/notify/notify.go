@@ -0,0 +1,187 @@
+// Package notify posts messages about a running backlog to a chat webhook,
+// so a loop running overnight reports what went wrong as it happens. Slack
+// and Discord webhooks get the message in their own payload format; any
+// other URL gets a JSON object with the event and its fields.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Events a webhook can be told about
+const (
+	Started   = "started"   // a task run started
+	Completed = "completed" // a task was completed
+	Failed    = "failed"    // a task failed several runs in a row or ran out of attempts
+	Blocked   = "blocked"   // a task needs a human
+	Finished  = "finished"  // the loop stopped
+)
+
+// AllEvents are the events, in the order they are documented
+var AllEvents = []string{Started, Completed, Failed, Blocked, Finished}
+
+// Kinds of webhook
+const (
+	Slack   = "slack"
+	Discord = "discord"
+	Generic = "generic"
+)
+
+// Timeout bounds a post to the webhook
+const Timeout = 10 * time.Second
+
+// Message is one notification
+type Message struct {
+	Event string
+	Text  string
+	// Fields are sent as they are to generic webhooks only
+	Fields map[string]any
+}
+
+// KindOf tells a webhook's kind from its URL
+func KindOf(webhook string) string {
+	u, err := url.Parse(webhook)
+	if err != nil {
+		return Generic
+	}
+	host := strings.ToLower(u.Hostname())
+	switch {
+	case host == "hooks.slack.com":
+		return Slack
+	case (host == "discord.com" || host == "discordapp.com" || strings.HasSuffix(host, ".discord.com")) && strings.HasPrefix(u.Path, "/api/webhooks/"):
+		return Discord
+	}
+	return Generic
+}
+
+// Payload renders a message for a webhook of the given kind
+func Payload(kind string, m Message) ([]byte, error) {
+	switch kind {
+	case Slack:
+		return json.Marshal(map[string]string{"text": m.Text})
+	case Discord:
+		return json.Marshal(map[string]string{"content": m.Text})
+	}
+	body := map[string]any{}
+	for k, v := range m.Fields {
+		body[k] = v
+	}
+	body["event"] = m.Event
+	body["text"] = m.Text
+	return json.Marshal(body)
+}
+
+// ParseEvents reads a comma-separated list of events, e.g. "failed,finished";
+// "all" is every event
+func ParseEvents(spec string) (map[string]bool, error) {
+	events := make(map[string]bool)
+	for _, e := range strings.Split(spec, ",") {
+		e = strings.ToLower(strings.TrimSpace(e))
+		switch {
+		case e == "":
+		case e == "all":
+			for _, all := range AllEvents {
+				events[all] = true
+			}
+		case isEvent(e):
+			events[e] = true
+		default:
+			return nil, fmt.Errorf("unknown event %q (want %s or all)", e, strings.Join(AllEvents, ", "))
+		}
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("no events in %q", spec)
+	}
+	return events, nil
+}
+
+func isEvent(e string) bool {
+	for _, known := range AllEvents {
+		if e == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Notifier posts messages to a webhook in the background, so a slow or
+// unreachable webhook never holds up the loop. Posts that fail are passed
+// to OnError.
+type Notifier struct {
+	URL    string
+	Kind   string
+	Events map[string]bool
+	// OnError is told about posts that failed; nil ignores them
+	OnError func(Message, error)
+	HTTP    *http.Client
+
+	wg sync.WaitGroup
+}
+
+// New returns a notifier posting the given events to webhook
+func New(webhook string, events map[string]bool) *Notifier {
+	return &Notifier{URL: webhook, Kind: KindOf(webhook), Events: events, HTTP: &http.Client{Timeout: Timeout}}
+}
+
+// Wants reports whether the notifier posts event
+func (n *Notifier) Wants(event string) bool {
+	return n != nil && n.Events[event]
+}
+
+// Send posts m in the background when its event is wanted
+func (n *Notifier) Send(m Message) {
+	if !n.Wants(m.Event) {
+		return
+	}
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		if err := n.Post(m); err != nil && n.OnError != nil {
+			n.OnError(m, err)
+		}
+	}()
+}
+
+// Wait waits for the posts in flight, each of which gives up after Timeout
+func (n *Notifier) Wait() {
+	if n != nil {
+		n.wg.Wait()
+	}
+}
+
+// Post sends m to the webhook and waits for the answer
+func (n *Notifier) Post(m Message) error {
+	body, err := Payload(n.Kind, m)
+	if err != nil {
+		return err
+	}
+	resp, err := n.HTTP.Post(n.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return fmt.Errorf("webhook answered %s %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// Describe lists the events of a notifier, e.g. "completed, failed"
+func Describe(events map[string]bool) string {
+	var names []string
+	for _, e := range AllEvents {
+		if events[e] {
+			names = append(names, e)
+		}
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,95 @@
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestKindOf(t *testing.T) {
+	for url, want := range map[string]string{
+		"https://hooks.slack.com/services/T0/B0/xyz":       Slack,
+		"https://discord.com/api/webhooks/1/abc":           Discord,
+		"https://ptb.discord.com/api/webhooks/1/abc":       Discord,
+		"https://discord.com/channels/1":                   Generic,
+		"https://chat.example.com/hooks/abc":               Generic,
+		"https://hooks.slack.com.example.com/services/abc": Generic,
+	} {
+		if got := KindOf(url); got != want {
+			t.Errorf("KindOf(%q) = %s, want %s", url, got, want)
+		}
+	}
+}
+
+func TestPayload(t *testing.T) {
+	m := Message{Event: Completed, Text: "✅ Done", Fields: map[string]any{"task": "Login"}}
+	for kind, want := range map[string]string{
+		Slack:   `{"text":"✅ Done"}`,
+		Discord: `{"content":"✅ Done"}`,
+		Generic: `{"event":"completed","task":"Login","text":"✅ Done"}`,
+	} {
+		if got, err := Payload(kind, m); err != nil || string(got) != want {
+			t.Errorf("Payload(%s) = %s, %v; want %s", kind, got, err, want)
+		}
+	}
+}
+
+func TestParseEvents(t *testing.T) {
+	events, err := ParseEvents(" Failed, finished")
+	if err != nil || Describe(events) != "failed, finished" {
+		t.Errorf("ParseEvents() = %v, %v", events, err)
+	}
+	if events, _ := ParseEvents("all"); len(events) != len(AllEvents) {
+		t.Errorf("ParseEvents(all) = %v", events)
+	}
+	for _, bad := range []string{"done", ",", ""} {
+		if _, err := ParseEvents(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestNotifier(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload map[string]any
+		json.Unmarshal(body, &payload)
+		if payload["text"] == "boom" {
+			http.Error(w, "no such channel", http.StatusNotFound)
+			return
+		}
+		mu.Lock()
+		got = append(got, payload["text"].(string))
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	events, _ := ParseEvents("completed,finished")
+	n := New(srv.URL, events)
+	var failures []error
+	n.OnError = func(m Message, err error) {
+		mu.Lock()
+		failures = append(failures, err)
+		mu.Unlock()
+	}
+	n.Send(Message{Event: Started, Text: "not wanted"})
+	n.Send(Message{Event: Completed, Text: "done"})
+	n.Send(Message{Event: Finished, Text: "boom"})
+	n.Wait()
+	if strings.Join(got, ",") != "done" {
+		t.Errorf("Webhook got %q, want only the wanted event", got)
+	}
+	if len(failures) != 1 || !strings.Contains(failures[0].Error(), "404") {
+		t.Errorf("OnError got %v", failures)
+	}
+
+	var off *Notifier
+	off.Send(Message{Event: Completed})
+	off.Wait()
+}
@@ -0,0 +1,117 @@
+// Package prbody keeps a section of a pull request description in sync with
+// its task: the acceptance criteria as a checklist, checked off as the task
+// progresses, followed by the results of the task's agent runs. The rest of
+// the description is left as its authors wrote it.
+package prbody
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// Markers around the managed section. Edits between them are replaced on
+// the next sync.
+const (
+	StartMarker = "<!-- cursor-iter:criteria -->"
+	EndMarker   = "<!-- /cursor-iter:criteria -->"
+)
+
+// Section renders the managed section for a task's criteria and the
+// verification results of its runs, oldest first
+func Section(criteria []tasks.Criterion, results []string) string {
+	var b strings.Builder
+	b.WriteString(StartMarker + "\n")
+	checked := 0
+	for _, c := range criteria {
+		if c.Checked {
+			checked++
+		}
+	}
+	fmt.Fprintf(&b, "### Acceptance criteria (%d/%d)\n\n", checked, len(criteria))
+	if len(criteria) == 0 {
+		b.WriteString("_The task lists no acceptance criteria._\n")
+	}
+	for _, c := range criteria {
+		box := " "
+		if c.Checked {
+			box = "x"
+		}
+		text := c.Text
+		if c.Category != tasks.CategoryFunctional {
+			text = "[" + c.Category + "] " + text
+		}
+		fmt.Fprintf(&b, "- [%s] %s\n", box, text)
+	}
+	if len(results) > 0 {
+		b.WriteString("\n### Verification\n\n")
+		for _, r := range results {
+			b.WriteString("- " + r + "\n")
+		}
+	}
+	b.WriteString(EndMarker)
+	return b.String()
+}
+
+// Sync replaces the managed section of body with a new one, or appends the
+// section when body has none
+func Sync(body string, criteria []tasks.Criterion, results []string) string {
+	section := Section(criteria, results)
+	start := strings.Index(body, StartMarker)
+	if start >= 0 {
+		if end := strings.Index(body[start:], EndMarker); end >= 0 {
+			return body[:start] + section + body[start+end+len(EndMarker):]
+		}
+	}
+	body = strings.TrimRight(body, "\n")
+	if body == "" {
+		return section + "\n"
+	}
+	return body + "\n\n" + section + "\n"
+}
+
+// Describe writes the description of a new pull request for a task: its
+// context, the managed section and the notes of its progress.md entry.
+// Empty context and notes are left out.
+func Describe(context, notes string, criteria []tasks.Criterion, results []string) string {
+	var b strings.Builder
+	if context = strings.TrimSpace(context); context != "" {
+		b.WriteString("### Context\n\n" + context + "\n\n")
+	}
+	b.WriteString(Section(criteria, results) + "\n")
+	if notes = strings.TrimSpace(notes); notes != "" {
+		b.WriteString("\n### Progress notes\n\n" + notes + "\n")
+	}
+	return b.String()
+}
+
+// RunResult describes a journaled run on one line, e.g. "2025-01-08 19:04
+// claude (sonnet): completed in 4m12s"
+func RunResult(e journal.Entry) string {
+	line := fmt.Sprintf("%s %s", e.Time.Local().Format("2006-01-02 15:04"), e.Backend)
+	if e.Model != "" && e.Model != "auto" {
+		line += " (" + e.Model + ")"
+	}
+	line += fmt.Sprintf(": %s in %s", e.Outcome, e.Duration().Round(time.Second))
+	if e.Failed() && e.Classification != "" {
+		line += ", " + e.Classification
+	}
+	if e.Blocker != "" {
+		line += ", needs: " + e.Blocker
+	}
+	return line
+}
+
+// Results returns the verification lines of a task's runs in the journal
+func Results(entries []journal.Entry, task string) []string {
+	var results []string
+	for _, e := range entries {
+		if strings.EqualFold(e.Task, task) {
+			results = append(results, RunResult(e))
+		}
+	}
+	return results
+}
@@ -0,0 +1,68 @@
+package prbody
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+func TestSync(t *testing.T) {
+	criteria := []tasks.Criterion{
+		{Text: "Form validates email", Checked: true, Category: tasks.CategoryFunctional},
+		{Text: "Update README", Category: "docs"},
+	}
+	body := Sync("Adds the login form.\n", criteria, nil)
+	want := "Adds the login form.\n\n" + StartMarker + "\n### Acceptance criteria (1/2)\n\n- [x] Form validates email\n- [ ] [docs] Update README\n" + EndMarker + "\n"
+	if body != want {
+		t.Fatalf("Sync() =\n%s\nwant\n%s", body, want)
+	}
+
+	// A reviewer's notes after the section survive the next sync
+	body += "\nReviewer notes: looks good\n"
+	criteria[1].Checked = true
+	body = Sync(body, criteria, []string{"2025-01-08 19:04 claude (sonnet): completed in 4m12s"})
+	for _, want := range []string{"Adds the login form.", "(2/2)", "- [x] [docs] Update README", "### Verification\n\n- 2025-01-08 19:04 claude", "Reviewer notes: looks good"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in:\n%s", want, body)
+		}
+	}
+	if strings.Count(body, StartMarker) != 1 {
+		t.Errorf("Expected one managed section:\n%s", body)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	criteria := []tasks.Criterion{{Text: "Form validates email", Checked: true, Category: tasks.CategoryFunctional}}
+	body := Describe("Users can't sign in yet.", "Added the form and its validation.\n", criteria, nil)
+	want := "### Context\n\nUsers can't sign in yet.\n\n" + StartMarker + "\n### Acceptance criteria (1/1)\n\n- [x] Form validates email\n" + EndMarker + "\n\n### Progress notes\n\nAdded the form and its validation.\n"
+	if body != want {
+		t.Fatalf("Describe() =\n%s\nwant\n%s", body, want)
+	}
+	// The managed section can be synced later like any other
+	if synced := Sync(body, criteria, nil); synced != body {
+		t.Errorf("Sync() changed a fresh description:\n%s", synced)
+	}
+	if body := Describe("", " ", criteria, nil); strings.Contains(body, "Progress notes") || !strings.HasPrefix(body, StartMarker) {
+		t.Errorf("Describe() without context or notes =\n%s", body)
+	}
+}
+
+func TestResults(t *testing.T) {
+	at := time.Date(2025, 1, 8, 19, 4, 0, 0, time.Local)
+	entries := []journal.Entry{
+		{Time: at, Task: "Login form", Backend: "cursor-agent", Model: "auto", Outcome: journal.OutcomeFailed, Classification: "timeout", DurationMs: 61_000},
+		{Time: at, Task: "Signup", Backend: "codex", Outcome: journal.OutcomeCompleted},
+		{Time: at.Add(time.Hour), Task: "login form", Backend: "claude", Model: "sonnet", Outcome: journal.OutcomeCompleted, DurationMs: 252_400},
+	}
+	got := Results(entries, "Login form")
+	want := []string{
+		"2025-01-08 19:04 cursor-agent: failed in 1m1s, timeout",
+		"2025-01-08 20:04 claude (sonnet): completed in 4m12s",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Results() = %q, want %q", got, want)
+	}
+}
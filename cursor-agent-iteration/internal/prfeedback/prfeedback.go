@@ -0,0 +1,122 @@
+// Package prfeedback turns the reviews of a task's pull request that request
+// changes into acceptance criteria for the task, so what a human reviewer
+// asked for goes back to the agent like any other unfinished work. Reviews
+// and comments are read as the GitHub REST API returns them.
+package prfeedback
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/textutil"
+)
+
+// Category tags the criteria taken from reviews, e.g. "[review] ..."
+const Category = "review"
+
+// StateChangesRequested is the state of a review that requests changes
+const StateChangesRequested = "CHANGES_REQUESTED"
+
+// maxLen caps the text of a criterion taken from a comment
+const maxLen = 300
+
+var (
+	reListItem = regexp.MustCompile(`^(?:[-*+]|\d+[.)])\s+(?:\[[ xX]\]\s+)?(.+)$`)
+	reFence    = regexp.MustCompile("(?s)```.*?(```|$)")
+	reComment  = regexp.MustCompile(`(?s)<!--.*?-->`)
+)
+
+// User is the author of a review or comment
+type User struct {
+	Login string `json:"login"`
+}
+
+// Review is a review of a pull request
+type Review struct {
+	ID    int64  `json:"id"`
+	State string `json:"state"`
+	Body  string `json:"body"`
+	User  User   `json:"user"`
+}
+
+// Comment is a comment on a line of a pull request's diff
+type Comment struct {
+	ID        int64  `json:"id"`
+	ReviewID  int64  `json:"pull_request_review_id"`
+	InReplyTo int64  `json:"in_reply_to_id"`
+	Path      string `json:"path"`
+	Line      int    `json:"line"`
+	Body      string `json:"body"`
+	User      User   `json:"user"`
+}
+
+// Criteria returns the criteria asked for by the reviews requesting changes
+// that aren't in seen, and the IDs of those reviews. Each item of a list in
+// a review's body becomes a criterion, or the whole body when it has no
+// list, and so does each comment the review left on the diff; replies are
+// discussion and are left out.
+func Criteria(reviews []Review, comments []Comment, seen map[int64]bool) (criteria []string, ids []int64) {
+	requested := make(map[int64]bool)
+	for _, r := range reviews {
+		if r.State != StateChangesRequested || seen[r.ID] {
+			continue
+		}
+		requested[r.ID] = true
+		ids = append(ids, r.ID)
+		for _, item := range items(r.Body) {
+			criteria = append(criteria, fmt.Sprintf("[%s] @%s: %s", Category, r.User.Login, item))
+		}
+	}
+	for _, c := range comments {
+		if !requested[c.ReviewID] || c.InReplyTo != 0 {
+			continue
+		}
+		text := clean(c.Body)
+		if text == "" {
+			continue
+		}
+		where := c.Path
+		if c.Line > 0 {
+			where = fmt.Sprintf("%s:%d", c.Path, c.Line)
+		}
+		criteria = append(criteria, fmt.Sprintf("[%s] @%s on `%s`: %s", Category, c.User.Login, where, text))
+	}
+	return criteria, ids
+}
+
+// items splits a review body into its list items, or returns it whole
+func items(body string) []string {
+	body = strip(body)
+	var list []string
+	for _, line := range strings.Split(body, "\n") {
+		if m := reListItem.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			list = append(list, textutil.Shorten(strings.TrimSpace(m[1]), maxLen))
+		}
+	}
+	if len(list) > 0 {
+		return list
+	}
+	if text := clean(body); text != "" {
+		return []string{text}
+	}
+	return nil
+}
+
+// clean makes a comment one line of text
+func clean(body string) string {
+	var words []string
+	for _, line := range strings.Split(strip(body), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, ">") {
+			words = append(words, strings.Fields(line)...)
+		}
+	}
+	return textutil.Shorten(strings.Join(words, " "), maxLen)
+}
+
+// strip drops HTML comments and replaces code blocks, such as suggested
+// changes, with a pointer to them
+func strip(body string) string {
+	body = reComment.ReplaceAllString(strings.ReplaceAll(body, "\r\n", "\n"), "")
+	return reFence.ReplaceAllString(body, "(see the code in the comment)")
+}
@@ -0,0 +1,43 @@
+package prfeedback
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCriteria(t *testing.T) {
+	reviews := []Review{
+		{ID: 1, State: "APPROVED", Body: "Looks good", User: User{"bob"}},
+		{ID: 2, State: StateChangesRequested, Body: "A few things:\n\n- Handle an empty password\n* [ ] Add a test for it\n<!-- template -->", User: User{"alice"}},
+		{ID: 3, State: StateChangesRequested, Body: "", User: User{"carol"}},
+		{ID: 4, State: StateChangesRequested, Body: "Old review", User: User{"alice"}},
+	}
+	comments := []Comment{
+		{ID: 10, ReviewID: 3, Path: "web/login.go", Line: 42, Body: "> err := nil\n\nThis error is dropped.\n```suggestion\nreturn err\n```", User: User{"carol"}},
+		{ID: 11, ReviewID: 3, InReplyTo: 10, Path: "web/login.go", Line: 42, Body: "Agreed", User: User{"alice"}},
+		{ID: 12, ReviewID: 1, Path: "web/login.go", Line: 7, Body: "Nit: naming", User: User{"bob"}},
+		{ID: 13, ReviewID: 3, Path: "README.md", Body: "Document the flag", User: User{"carol"}},
+	}
+	criteria, ids := Criteria(reviews, comments, map[int64]bool{4: true})
+	want := []string{
+		"[review] @alice: Handle an empty password",
+		"[review] @alice: Add a test for it",
+		"[review] @carol on `web/login.go:42`: This error is dropped. (see the code in the comment)",
+		"[review] @carol on `README.md`: Document the flag",
+	}
+	if !reflect.DeepEqual(criteria, want) {
+		t.Errorf("Criteria() =\n%s\nwant\n%s", strings.Join(criteria, "\n"), strings.Join(want, "\n"))
+	}
+	if !reflect.DeepEqual(ids, []int64{2, 3}) {
+		t.Errorf("Criteria() review IDs = %v, want [2 3]", ids)
+	}
+
+	whole, _ := Criteria([]Review{{ID: 5, State: StateChangesRequested, Body: "Please split\nthis function.", User: User{"dan"}}}, nil, nil)
+	if len(whole) != 1 || whole[0] != "[review] @dan: Please split this function." {
+		t.Errorf("Criteria() of a body without a list = %q", whole)
+	}
+	if long := clean(strings.Repeat("word ", 100)); len([]rune(long)) > maxLen+1 || !strings.HasSuffix(long, "…") {
+		t.Errorf("clean() = %q", long)
+	}
+}
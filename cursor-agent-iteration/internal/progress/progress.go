@@ -0,0 +1,154 @@
+// Package progress renders a live status line per running task at the
+// bottom of the terminal while other output scrolls above it
+package progress
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/textutil"
+)
+
+var spinner = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// Task is a running task as shown in the display
+type Task struct {
+	Title    string
+	Backend  string
+	Start    time.Time
+	Estimate time.Duration // typical duration of similar runs, 0 if unknown
+}
+
+// Render returns the status lines for the running tasks, cut to width
+// columns
+func Render(tasks []Task, now time.Time, frame int, width int) []string {
+	lines := make([]string, 0, len(tasks))
+	for _, t := range tasks {
+		elapsed := now.Sub(t.Start).Truncate(time.Second)
+		status := elapsed.String() + " elapsed"
+		switch {
+		case t.Estimate <= 0:
+		case elapsed < t.Estimate:
+			status += fmt.Sprintf(", ~%s left", (t.Estimate - elapsed).Round(time.Second))
+		default:
+			status += fmt.Sprintf(", %s over the usual %s", (elapsed - t.Estimate).Round(time.Second), t.Estimate.Round(time.Second))
+		}
+		line := fmt.Sprintf("%s %s", spinner[frame%len(spinner)], t.Title)
+		if t.Backend != "" {
+			line += " [" + t.Backend + "]"
+		}
+		line += "  " + status
+		if width > 1 {
+			// The last column is left free so the line can't wrap
+			line = textutil.Truncate(line, width-1)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Display keeps the status lines of the running tasks at the bottom of a
+// terminal. All other output must be written through the Display so the
+// status block can be cleared before and redrawn after it.
+type Display struct {
+	mu     sync.Mutex
+	out    io.Writer
+	source func() []Task
+	width  int
+
+	lines   int  // status lines currently on screen
+	midLine bool // the last output didn't end with a newline
+	frame   int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New creates a display on out showing the tasks returned by source
+func New(out io.Writer, source func() []Task, width int) *Display {
+	return &Display{out: out, source: source, width: width}
+}
+
+// Start redraws the status block every interval until Stop is called
+func (d *Display) Start(interval time.Duration) {
+	d.stop = make(chan struct{})
+	d.done = make(chan struct{})
+	go func() {
+		defer close(d.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-d.stop:
+				return
+			case <-ticker.C:
+				d.mu.Lock()
+				d.frame++
+				d.redraw()
+				d.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// Stop stops the redraws and removes the status block
+func (d *Display) Stop() {
+	if d.stop != nil {
+		close(d.stop)
+		<-d.done
+		d.stop = nil
+	}
+	d.mu.Lock()
+	d.clear()
+	d.mu.Unlock()
+}
+
+// Write prints output above the status block
+func (d *Display) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.clear()
+	n, err := d.out.Write(p)
+	if len(p) > 0 {
+		d.midLine = p[len(p)-1] != '\n'
+	}
+	d.redraw()
+	return n, err
+}
+
+// clear erases the status block, leaving the cursor where it started
+func (d *Display) clear() {
+	if d.lines > 0 {
+		fmt.Fprintf(d.out, "\033[%dA\033[J", d.lines)
+		d.lines = 0
+	}
+}
+
+// redraw replaces the status block with the current one. Nothing is drawn
+// while a line of output is incomplete.
+func (d *Display) redraw() {
+	if d.midLine {
+		return
+	}
+	d.clear()
+	lines := Render(d.source(), time.Now(), d.frame, d.width)
+	if len(lines) == 0 {
+		return
+	}
+	var b bytes.Buffer
+	for _, line := range lines {
+		b.WriteString("\033[2m" + line + "\033[0m\n")
+	}
+	d.out.Write(b.Bytes())
+	d.lines = len(lines)
+}
+
+// Enabled reports whether a live display makes sense for a terminal: it
+// needs an interactive terminal that understands escape sequences
+func Enabled(isTerminal bool, term string) bool {
+	return isTerminal && term != "" && !strings.EqualFold(term, "dumb")
+}
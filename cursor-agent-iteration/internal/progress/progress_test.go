@@ -0,0 +1,66 @@
+package progress
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	now := time.Date(2025, 1, 8, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		task     Task
+		width    int
+		expected string
+	}{
+		{"no estimate", Task{Title: "Login", Start: now.Add(-90 * time.Second)}, 80, "⠋ Login  1m30s elapsed"},
+		{"with estimate", Task{Title: "Login", Backend: "codex", Start: now.Add(-2 * time.Minute), Estimate: 5 * time.Minute}, 80, "⠋ Login [codex]  2m0s elapsed, ~3m0s left"},
+		{"over estimate", Task{Title: "Login", Start: now.Add(-7 * time.Minute), Estimate: 5 * time.Minute}, 80, "⠋ Login  7m0s elapsed, 2m0s over the usual 5m0s"},
+		{"truncated", Task{Title: strings.Repeat("x", 40), Start: now}, 20, "⠋ " + strings.Repeat("x", 16) + "…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := Render([]Task{tt.task}, now, 0, tt.width)
+			if len(lines) != 1 || lines[0] != tt.expected {
+				t.Errorf("Render() = %q, want %q", lines, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDisplayWrite(t *testing.T) {
+	var out strings.Builder
+	running := []Task{{Title: "Login", Start: time.Now()}}
+	d := New(&out, func() []Task { return running }, 80)
+
+	d.Write([]byte("first\n"))
+	if !strings.HasPrefix(out.String(), "first\n") || !strings.Contains(out.String(), "Login") || d.lines != 1 {
+		t.Fatalf("Expected output followed by the status block, got %q", out.String())
+	}
+
+	out.Reset()
+	d.Write([]byte("partial"))
+	if !strings.HasPrefix(out.String(), "\033[1A\033[J") || strings.Contains(out.String(), "Login") {
+		t.Errorf("Expected the block cleared and not redrawn mid-line, got %q", out.String())
+	}
+
+	out.Reset()
+	d.Write([]byte(" line\n"))
+	if out.String() == "" || !strings.Contains(out.String(), "Login") {
+		t.Errorf("Expected the block redrawn after the line ended, got %q", out.String())
+	}
+
+	out.Reset()
+	running = nil
+	d.Stop()
+	if out.String() != "\033[1A\033[J" || d.lines != 0 {
+		t.Errorf("Expected Stop to clear the block, got %q", out.String())
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	if !Enabled(true, "xterm-256color") || Enabled(false, "xterm") || Enabled(true, "dumb") || Enabled(true, "") {
+		t.Errorf("Enabled() mismatch")
+	}
+}
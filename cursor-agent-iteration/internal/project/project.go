@@ -0,0 +1,242 @@
+// Package project describes the kind of repository the autopilot works on.
+// Prompts and completion rules assume a code base with tests and quality
+// gates; documentation sites and infrastructure repositories get
+// instructions and defaults of their own.
+package project
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Type is the kind of repository
+type Type string
+
+const (
+	Code  Type = "code"  // source code with tests and quality gates
+	Docs  Type = "docs"  // a documentation site, usually without tests
+	Infra Type = "infra" // infrastructure as code: Terraform, Helm, Ansible
+)
+
+// Auto asks for the type to be detected from the repository
+const Auto = "auto"
+
+// aliases maps alternative spellings to a type
+var aliases = map[string]Type{
+	"documentation":  Docs,
+	"doc":            Docs,
+	"infrastructure": Infra,
+	"iac":            Infra,
+}
+
+// Parse parses a project type
+func Parse(s string) (Type, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch t := Type(s); t {
+	case Code, Docs, Infra:
+		return t, nil
+	}
+	if t, ok := aliases[s]; ok {
+		return t, nil
+	}
+	return "", fmt.Errorf("unknown project type %q (want %s, %s, %s or %s)", s, Code, Docs, Infra, Auto)
+}
+
+// Resolve parses a project type, detecting it from the repository at root
+// when spec is empty or "auto"
+func Resolve(spec, root string) (Type, error) {
+	if s := strings.TrimSpace(spec); s == "" || strings.EqualFold(s, Auto) {
+		return Detect(root), nil
+	}
+	return Parse(spec)
+}
+
+// Marker files that settle the type on their own
+var (
+	docsMarkers = []string{
+		"mkdocs.yml", "mkdocs.yaml", "book.toml", "antora.yml",
+		"docusaurus.config.js", "docusaurus.config.ts", "conf.py",
+	}
+	infraMarkers = []string{
+		"Chart.yaml", "helmfile.yaml", "kustomization.yaml", "ansible.cfg",
+		"Pulumi.yaml", "terragrunt.hcl",
+	}
+)
+
+// Extensions counted when no marker file is found
+var (
+	docsExts  = map[string]bool{".md": true, ".mdx": true, ".rst": true, ".adoc": true, ".txt": true}
+	infraExts = map[string]bool{".tf": true, ".hcl": true, ".tfvars": true}
+	codeExts  = map[string]bool{
+		".go": true, ".py": true, ".js": true, ".jsx": true, ".ts": true, ".tsx": true,
+		".rs": true, ".java": true, ".kt": true, ".scala": true, ".rb": true, ".php": true,
+		".c": true, ".h": true, ".cc": true, ".cpp": true, ".hpp": true, ".cs": true,
+		".swift": true, ".m": true, ".ex": true, ".exs": true, ".clj": true, ".hs": true,
+		".vue": true, ".svelte": true, ".dart": true, ".lua": true, ".zig": true,
+	}
+)
+
+// detectLimit caps how many files Detect looks at in large repositories
+const detectLimit = 5000
+
+// Detect guesses the type of the repository at root. A marker file such as
+// mkdocs.yml or Chart.yaml decides it; otherwise a repository without any
+// source files is docs or infra by what it holds most, and anything else is
+// code.
+func Detect(root string) Type {
+	for _, name := range docsMarkers {
+		if exists(filepath.Join(root, name)) {
+			return Docs
+		}
+	}
+	for _, name := range infraMarkers {
+		if exists(filepath.Join(root, name)) {
+			return Infra
+		}
+	}
+
+	var docs, infra, code, seen int
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != root && (strings.HasPrefix(name, ".") || name == "node_modules" || name == "vendor") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if seen++; seen > detectLimit {
+			return filepath.SkipAll
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		switch {
+		case docsExts[ext]:
+			docs++
+		case infraExts[ext]:
+			infra++
+		case codeExts[ext]:
+			code++
+		}
+		return nil
+	})
+	switch {
+	case code > 0:
+		return Code
+	case infra > 0 && infra >= docs:
+		return Infra
+	case docs > 0:
+		return Docs
+	}
+	return Code
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// DeferredCategories are the criteria categories that may stay unchecked by
+// default: a docs site has no test suite to add tests to, and neither docs
+// nor infrastructure changes can be load tested by the agent
+func (t Type) DeferredCategories() string {
+	switch t {
+	case Docs:
+		return "tests,perf"
+	case Infra:
+		return "perf"
+	}
+	return ""
+}
+
+// Instructions are the parts of the task prompt that depend on the project
+// type
+type Instructions struct {
+	// Implement lists the steps of implementing a task
+	Implement string
+	// Quality lists the quality requirements
+	Quality string
+	// Gates reminds the agent what must pass before completing a task
+	Gates string
+	// Lean is the one-line instruction of the simplified prompts
+	Lean string
+}
+
+var instructions = map[Type]Instructions{
+	Code: {
+		Implement: `   - Plan your implementation approach
+   - Write the code with comprehensive logging and comments
+   - Create/update tests to verify functionality
+   - Run quality gates (linting, formatting, type checking, tests)
+   - Update documentation as needed
+   - Commit changes with conventional commit messages`,
+		Quality: `   - All tests must pass
+   - Code must pass linting and formatting checks
+   - Follow existing code patterns and conventions
+   - Add detailed code comments explaining complex logic
+   - Include logging for debugging and monitoring`,
+		Gates: "Ensure all quality gates pass before marking complete",
+		Lean:  "Implement this in the current repository and add tests for it.",
+	},
+	Docs: {
+		Implement: `   - This is a documentation repository: there is no test suite to add tests to
+   - Plan which pages change and where new content belongs in the navigation
+   - Write the content, following the voice, structure and formatting of the existing pages
+   - Check that links, anchors, images and code samples are correct
+   - Build the site if the repository has a build command (e.g. mkdocs build, npm run build)
+   - Commit changes with conventional commit messages`,
+		Quality: `   - Content is accurate and consistent with the rest of the documentation
+   - Links and cross-references resolve
+   - Markdown renders correctly and matches the existing formatting
+   - The docs build, if the repository has a build, completes without new warnings`,
+		Gates: "Ensure links resolve and the docs build, if there is one, succeeds before marking complete",
+		Lean:  "Make this change to the documentation in the current repository.",
+	},
+	Infra: {
+		Implement: `   - Plan the change and which resources, modules or charts it touches
+   - Write the configuration following the existing module layout and naming
+   - Validate it with the repository's tools (e.g. terraform fmt and validate, helm lint, ansible-lint)
+   - Never apply changes to real environments: no terraform apply, helm install or playbook runs against hosts
+   - Update documentation as needed
+   - Commit changes with conventional commit messages`,
+		Quality: `   - Formatting and validation checks pass
+   - Changes are backwards compatible, or the migration is documented
+   - No secrets or credentials are committed
+   - Follow existing module patterns and conventions`,
+		Gates: "Ensure formatting and validation pass, without applying anything, before marking complete",
+		Lean:  "Make this change in the current repository and validate it without applying it.",
+	},
+}
+
+// Instructions returns the prompt instructions of the type; unknown types
+// get those of code
+func (t Type) Instructions() Instructions {
+	if in, ok := instructions[t]; ok {
+		return in
+	}
+	return instructions[Code]
+}
+
+// PromptNote tells agents of free-form prompts, such as add-feature and
+// run-agent, what replaces tests and quality gates. It is empty for code.
+func (t Type) PromptNote() string {
+	switch t {
+	case Docs:
+		return `
+
+## Project Type
+
+This is a documentation repository. Wherever these instructions mention tests, linting or quality gates, check instead that the content is accurate, links resolve and the docs build, if the repository has a build. Don't write acceptance criteria that require tests, builds of application code or benchmarks.`
+	case Infra:
+		return `
+
+## Project Type
+
+This is an infrastructure repository. Wherever these instructions mention tests or quality gates, use the repository's formatting and validation tools (e.g. terraform validate, helm lint) instead, and never apply changes to real environments. Acceptance criteria should be checkable without applying anything.`
+	}
+	return ""
+}
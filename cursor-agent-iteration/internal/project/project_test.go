@@ -0,0 +1,107 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected Type
+		wantErr  bool
+	}{
+		{"code", Code, false},
+		{"docs", Docs, false},
+		{" Infra ", Infra, false},
+		{"documentation", Docs, false},
+		{"iac", Infra, false},
+		{"auto", "", true},
+		{"website", "", true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.spec)
+		if (err != nil) != tt.wantErr || got != tt.expected {
+			t.Errorf("Parse(%q) = %q, %v; want %q, error %v", tt.spec, got, err, tt.expected, tt.wantErr)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    []string
+		expected Type
+	}{
+		{"empty", nil, Code},
+		{"mkdocs", []string{"mkdocs.yml", "docs/index.md", "hooks.py"}, Docs},
+		{"docusaurus", []string{"docusaurus.config.js", "src/pages/index.js"}, Docs},
+		{"markdown only", []string{"README.md", "guide/install.md", "guide/usage.rst"}, Docs},
+		{"helm chart", []string{"Chart.yaml", "templates/deployment.yaml"}, Infra},
+		{"terraform", []string{"main.tf", "variables.tf", "README.md"}, Infra},
+		{"go service", []string{"main.go", "README.md", "docs/design.md", "docs/api.md"}, Code},
+		{"terraform with a lambda", []string{"main.tf", "lambda/handler.py"}, Code},
+		{"sources in hidden dirs are ignored", []string{"README.md", ".github/scripts/check.py", "node_modules/x/index.js"}, Docs},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for _, f := range tt.files {
+				path := filepath.Join(root, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := Detect(root); got != tt.expected {
+				t.Errorf("Detect() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "mkdocs.yml"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, spec := range []string{"", "auto", "AUTO"} {
+		if got, err := Resolve(spec, root); err != nil || got != Docs {
+			t.Errorf("Resolve(%q) = %q, %v; want docs", spec, got, err)
+		}
+	}
+	if got, err := Resolve("infra", root); err != nil || got != Infra {
+		t.Errorf("Resolve(infra) = %q, %v", got, err)
+	}
+	if _, err := Resolve("nope", root); err == nil {
+		t.Error("Expected an error for an unknown type")
+	}
+}
+
+func TestInstructions(t *testing.T) {
+	for _, kind := range []Type{Code, Docs, Infra} {
+		in := kind.Instructions()
+		if in.Implement == "" || in.Quality == "" || in.Gates == "" || in.Lean == "" {
+			t.Errorf("%s: incomplete instructions %+v", kind, in)
+		}
+	}
+	if strings.Contains(Docs.Instructions().Quality, "tests") {
+		t.Error("Expected docs quality requirements without tests")
+	}
+	if !strings.Contains(Infra.Instructions().Implement, "Never apply") {
+		t.Error("Expected infra instructions to forbid applying changes")
+	}
+	if Type("").Instructions() != Code.Instructions() {
+		t.Error("Expected unknown types to fall back to code")
+	}
+	if Code.PromptNote() != "" || Code.DeferredCategories() != "" {
+		t.Error("Expected code to keep the default prompts and policy")
+	}
+	if !strings.Contains(Docs.PromptNote(), "documentation repository") || Docs.DeferredCategories() != "tests,perf" {
+		t.Error("Expected docs to have a prompt note and deferred tests")
+	}
+}
@@ -0,0 +1,117 @@
+// Package prompt builds the prompts sent to agents from text/template
+// templates. Every built-in template can be replaced by a file of the same
+// name in an override directory, so instructions such as company-specific
+// quality gates can be changed without forking.
+package prompt
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/*.md
+var builtin embed.FS
+
+// Template names
+const (
+	TaskTemplate = "task.md" // the prompt of a task dispatch
+)
+
+// Names lists the templates
+var Names = []string{TaskTemplate}
+
+// Task is the data of the task template
+type Task struct {
+	Type      string   // the project type: code, docs or infra
+	Details   string   // the task block from tasks.md, with matching glossary entries
+	Implement string   // implementation steps for the project type
+	Quality   string   // quality requirements for the project type
+	Gates     string   // the note on quality gates for the project type
+	Notes     []string // extra notes, e.g. the commit policy
+}
+
+// Templates are parsed prompt templates
+type Templates struct {
+	byName map[string]*template.Template
+	// Overridden are the names of the templates read from the override
+	// directory
+	Overridden []string
+}
+
+// Default returns the text of a built-in template
+func Default(name string) (string, error) {
+	data, err := builtin.ReadFile("templates/" + name)
+	if err != nil {
+		return "", fmt.Errorf("no built-in template %q", name)
+	}
+	return string(data), nil
+}
+
+// Builtin returns the built-in templates
+func Builtin() *Templates {
+	t, err := Load("")
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Load parses the templates, reading each from dir when it has a file of
+// that name and using the built-in one otherwise. A template that doesn't
+// parse, or refers to data the template doesn't have, is an error naming its
+// file.
+func Load(dir string) (*Templates, error) {
+	t := &Templates{byName: make(map[string]*template.Template)}
+	for _, name := range Names {
+		text, err := Default(name)
+		if err != nil {
+			return nil, err
+		}
+		source := "built-in " + name
+		if dir != "" {
+			path := filepath.Join(dir, name)
+			data, err := os.ReadFile(path)
+			switch {
+			case err == nil:
+				text, source = string(data), path
+				t.Overridden = append(t.Overridden, name)
+			case !errors.Is(err, fs.ErrNotExist):
+				return nil, err
+			}
+		}
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", source, err)
+		}
+		// Fields are only checked when a template runs; try it on sample
+		// data so a typo fails here rather than at dispatch
+		if err := tmpl.Execute(io.Discard, Task{Notes: []string{"note"}}); err != nil {
+			return nil, fmt.Errorf("%s: %v", source, err)
+		}
+		t.byName[name] = tmpl
+	}
+	return t, nil
+}
+
+// Task renders the prompt of a task. Empty notes are left out.
+func (t *Templates) Task(data Task) (string, error) {
+	var notes []string
+	for _, note := range data.Notes {
+		if note != "" {
+			notes = append(notes, note)
+		}
+	}
+	data.Notes = notes
+	var b strings.Builder
+	if err := t.byName[TaskTemplate].Execute(&b, data); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
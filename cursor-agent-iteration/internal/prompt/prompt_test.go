@@ -0,0 +1,66 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinTask(t *testing.T) {
+	msg, err := Builtin().Task(Task{
+		Type:      "code",
+		Details:   "### Task: Login",
+		Implement: "   - Write the code",
+		Quality:   "   - Tests pass",
+		Gates:     "All gates must pass",
+		Notes:     []string{"First note", "", "Second note"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"## Your Task\n\n### Task: Login\n\n## Instructions", "   - Write the code\n", "   - Tests pass\n", "- All gates must pass\n", "- First note\n- Second note\n\nWork on this task"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Expected %q in the task prompt:\n%s", want, msg)
+		}
+	}
+	if strings.Contains(msg, "- \n") || strings.HasSuffix(msg, "\n") {
+		t.Errorf("Expected no empty note and no trailing newline:\n%s", msg)
+	}
+}
+
+func TestLoadOverride(t *testing.T) {
+	dir := t.TempDir()
+	t1, err := Load(dir)
+	if err != nil || len(t1.Overridden) != 0 {
+		t.Fatalf("Load() of an empty directory = %v, %v; want the built-in templates", t1, err)
+	}
+
+	os.WriteFile(filepath.Join(dir, TaskTemplate), []byte("{{.Details}}\nRun `make acme-check`.{{range .Notes}}\n* {{.}}{{end}}\n"), 0644)
+	t2, err := Load(dir)
+	if err != nil || len(t2.Overridden) != 1 {
+		t.Fatalf("Load() = %v, %v; want the local task template", t2, err)
+	}
+	msg, _ := t2.Task(Task{Details: "### Task: Login", Notes: []string{"Be brief"}})
+	if msg != "### Task: Login\nRun `make acme-check`.\n* Be brief" {
+		t.Errorf("Unexpected prompt from the local template:\n%s", msg)
+	}
+
+	for _, broken := range []string{"{{.Details", "{{.Detials}}", "{{range .Notes}}"} {
+		os.WriteFile(filepath.Join(dir, TaskTemplate), []byte(broken), 0644)
+		if _, err := Load(dir); err == nil || !strings.Contains(err.Error(), filepath.Join(dir, TaskTemplate)) {
+			t.Errorf("Load() of %q = %v; want an error naming the file", broken, err)
+		}
+	}
+}
+
+func TestDefault(t *testing.T) {
+	for _, name := range Names {
+		if text, err := Default(name); err != nil || text == "" {
+			t.Errorf("Default(%q) = %q, %v", name, text, err)
+		}
+	}
+	if _, err := Default("nope.md"); err == nil {
+		t.Error("Expected an error for an unknown template")
+	}
+}
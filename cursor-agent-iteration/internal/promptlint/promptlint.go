@@ -0,0 +1,187 @@
+// Package promptlint checks prompt templates, in particular customized local
+// copies, for mistakes that silently degrade agent runs
+package promptlint
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Severities
+const (
+	Error   = "error"
+	Warning = "warning"
+)
+
+// Rules
+const (
+	RuleVariable = "unresolved-variable"
+	RuleLength   = "length"
+	RuleConflict = "conflicting-instructions"
+	RuleSection  = "missing-section"
+)
+
+// DefaultMaxBytes is the size above which a template is reported as too long
+const DefaultMaxBytes = 32 * 1024
+
+// Issue is one problem found in a template
+type Issue struct {
+	File     string
+	Line     int // 0 for issues about the whole file
+	Severity string
+	Rule     string
+	Message  string
+}
+
+func (i Issue) String() string {
+	loc := i.File
+	if i.Line > 0 {
+		loc = fmt.Sprintf("%s:%d", i.File, i.Line)
+	}
+	return fmt.Sprintf("%s: %s: %s [%s]", loc, i.Severity, i.Message, i.Rule)
+}
+
+// Spec describes what a template must and may contain
+type Spec struct {
+	// Variables are the {{NAME}} placeholders cursor-iter fills in. All of
+	// them must be present; any other placeholder is sent to the agent as is.
+	Variables []string
+	// Sections are headings (matched case-insensitively as substrings) the
+	// template must keep
+	Sections []string
+	MaxBytes int
+}
+
+// Specs are the templates cursor-iter uses, by file name. Other files are
+// linted without variable and section rules.
+var Specs = map[string]Spec{
+	"add-feature.md": {
+		Variables: []string{"FEATURE_DESCRIPTION"},
+		Sections:  []string{"SYSTEM", "Feature Specification", "Task Structure"},
+	},
+	"initialize-iteration-universal.md": {
+		Sections: []string{"SYSTEM", "Control files", "Specification for `tasks.md`"},
+	},
+}
+
+var (
+	reVariable = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.]*)\s*\}\}`)
+	reHeading  = regexp.MustCompile(`^#{1,6}\s+(.+?)\s*$`)
+	reIgnore   = regexp.MustCompile(`<!--\s*promptlint:ignore\s+([a-z-]+)\s*-->`)
+)
+
+// conflicts are instructions that contradict each other when a template
+// contains both
+var conflicts = []struct {
+	topic    string
+	positive *regexp.Regexp
+	negative *regexp.Regexp
+}{
+	{"committing", regexp.MustCompile(`(?i)\bcommit\b`), regexp.MustCompile(`(?i)\b(do not|don't|never|must not)\s+(git\s+)?commit\b`)},
+	{"pushing", regexp.MustCompile(`(?i)\bpush\b`), regexp.MustCompile(`(?i)\b(do not|don't|never|must not)\s+(git\s+)?push\b`)},
+	{"running tests", regexp.MustCompile(`(?i)\brun (the |all |relevant )*tests\b`), regexp.MustCompile(`(?i)\b(do not|don't|never|must not)\s+run\s+(any\s+|the\s+)?tests\b`)},
+}
+
+// Lint checks one template. Variable and section rules only apply when the
+// spec lists variables or sections. A `<!-- promptlint:ignore <rule> -->`
+// comment in the template turns a rule off for it.
+func Lint(file string, content string, spec Spec) []Issue {
+	ignored := make(map[string]bool)
+	for _, m := range reIgnore.FindAllStringSubmatch(content, -1) {
+		ignored[m[1]] = true
+	}
+	var issues []Issue
+	add := func(line int, severity, rule, format string, args ...interface{}) {
+		if !ignored[rule] {
+			issues = append(issues, Issue{File: file, Line: line, Severity: severity, Rule: rule, Message: fmt.Sprintf(format, args...)})
+		}
+	}
+	lines := strings.Split(content, "\n")
+
+	// Placeholders
+	known := make(map[string]bool)
+	for _, v := range spec.Variables {
+		known[v] = true
+	}
+	seen := make(map[string]bool)
+	for n, line := range lines {
+		for _, m := range reVariable.FindAllStringSubmatch(line, -1) {
+			seen[m[1]] = true
+			if !known[m[1]] {
+				severity := Error
+				if spec.Variables == nil {
+					// Unknown template: the placeholder may be filled elsewhere
+					severity = Warning
+				}
+				add(n+1, severity, RuleVariable, "placeholder %s is never filled in", m[0])
+			}
+		}
+	}
+	for _, v := range spec.Variables {
+		if !seen[v] {
+			add(0, Error, RuleVariable, "missing placeholder {{%s}}; its value never reaches the agent", v)
+		}
+	}
+
+	// Length
+	max := spec.MaxBytes
+	if max == 0 {
+		max = DefaultMaxBytes
+	}
+	if len(content) > max {
+		add(0, Warning, RuleLength, "template is %d bytes, over the %d byte limit; long prompts crowd out the task context", len(content), max)
+	}
+
+	// Conflicting instructions
+	for _, c := range conflicts {
+		negative, positive := 0, 0
+		for n, line := range lines {
+			if c.negative.MatchString(line) {
+				if negative == 0 {
+					negative = n + 1
+				}
+			} else if c.positive.MatchString(line) && positive == 0 {
+				positive = n + 1
+			}
+		}
+		if negative > 0 && positive > 0 {
+			add(negative, Error, RuleConflict, "instructions about %s contradict line %d", c.topic, positive)
+		}
+	}
+
+	// Required sections
+	var headings []string
+	for _, line := range lines {
+		if m := reHeading.FindStringSubmatch(line); m != nil {
+			headings = append(headings, strings.ToLower(m[1]))
+		}
+	}
+	for _, section := range spec.Sections {
+		found := false
+		for _, h := range headings {
+			if strings.Contains(h, strings.ToLower(section)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			add(0, Error, RuleSection, "missing required section %q", section)
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Line < issues[j].Line })
+	return issues
+}
+
+// HasErrors reports whether any issue fails the lint; with strict set
+// warnings fail it too
+func HasErrors(issues []Issue, strict bool) bool {
+	for _, i := range issues {
+		if i.Severity == Error || strict {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package promptlint
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	spec := Spec{Variables: []string{"FEATURE_DESCRIPTION"}, Sections: []string{"SYSTEM", "Task Structure"}}
+	valid := "## SYSTEM\n\n# Task Structure\n\n{{FEATURE_DESCRIPTION}}\n\nCommit and push when done.\n"
+
+	tests := []struct {
+		name     string
+		content  string
+		spec     Spec
+		expected []string // rule@line of every issue
+	}{
+		{"valid", valid, spec, nil},
+		{"unknown placeholder", valid + "Use {{ FEATURE_NAME }}\n", spec, []string{RuleVariable + "@8"}},
+		{"missing placeholder", strings.Replace(valid, "{{FEATURE_DESCRIPTION}}", "", 1), spec, []string{RuleVariable + "@0"}},
+		{"missing section", strings.Replace(valid, "# Task Structure", "# Tasks", 1), spec, []string{RuleSection + "@0"}},
+		{"too long", strings.Repeat("x", 100), Spec{MaxBytes: 50}, []string{RuleLength + "@0"}},
+		{"conflicting commit", valid + "Do not commit anything.\n", spec, []string{RuleConflict + "@8"}},
+		{"conflicting tests", "Run the tests.\nDo not run tests for docs.\n", Spec{}, []string{RuleConflict + "@2"}},
+		{"ignored rule", valid + "Don't commit.\n<!-- promptlint:ignore conflicting-instructions -->\n", spec, nil},
+		{"placeholder in unknown template", "Hello {{NAME}}\n", Spec{}, []string{RuleVariable + "@1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, issue := range Lint("p.md", tt.content, tt.spec) {
+				got = append(got, issue.Rule+"@"+strconv.Itoa(issue.Line))
+			}
+			if strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Lint() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+
+	issues := Lint("p.md", "Hello {{NAME}}\n", Spec{})
+	if HasErrors(issues, false) || !HasErrors(issues, true) || issues[0].String() != "p.md:1: warning: placeholder {{NAME}} is never filled in [unresolved-variable]" {
+		t.Errorf("Unexpected warning handling: %v", issues)
+	}
+}
+
+// TestBundledPrompts keeps the prompts shipped with cursor-iter lint clean
+func TestBundledPrompts(t *testing.T) {
+	for name, spec := range Specs {
+		content, err := os.ReadFile(filepath.Join("..", "..", "prompts", name))
+		if err != nil {
+			t.Fatalf("reading bundled prompt: %v", err)
+		}
+		for _, issue := range Lint(name, string(content), spec) {
+			t.Errorf("%s", issue)
+		}
+	}
+}
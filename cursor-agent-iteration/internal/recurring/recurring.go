@@ -0,0 +1,184 @@
+// Package recurring materializes recurring maintenance tasks, such as a
+// weekly dependency update, into tasks.md when they are due. Definitions are
+// task blocks with a **Schedule:** line; each instance gets the date it was
+// created in its title, and a new instance is only created once the previous
+// one is completed.
+package recurring
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// instanceDate is the date format in instance titles
+const instanceDate = "2006-01-02"
+
+var (
+	reHeader   = regexp.MustCompile(`^###\s+Task:\s+(.+?)\s*$`)
+	reSchedule = regexp.MustCompile(`^\*\*Schedule:\*\*\s*(.+?)\s*$`)
+)
+
+// Definition is a recurring task
+type Definition struct {
+	Title    string
+	Spec     string
+	Schedule Schedule
+	// Body is the task block without its header and Schedule line
+	Body string
+}
+
+// Parse reads the definitions of a tasks-recurring.md file. Every task block
+// needs a Schedule line.
+func Parse(md string) ([]Definition, error) {
+	var defs []Definition
+	var current *Definition
+	var body []string
+	finish := func() error {
+		if current == nil {
+			return nil
+		}
+		if current.Spec == "" {
+			return fmt.Errorf("recurring task %q has no **Schedule:** line", current.Title)
+		}
+		current.Body = strings.Trim(strings.Join(body, "\n"), "\n")
+		defs = append(defs, *current)
+		current, body = nil, nil
+		return nil
+	}
+	for _, line := range strings.Split(md, "\n") {
+		if m := reHeader.FindStringSubmatch(line); m != nil {
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			current = &Definition{Title: m[1]}
+			continue
+		}
+		if strings.HasPrefix(line, "## ") || strings.HasPrefix(line, "# ") || strings.HasPrefix(line, "### ") {
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if m := reSchedule.FindStringSubmatch(line); m != nil {
+			schedule, err := ParseSchedule(m[1])
+			if err != nil {
+				return nil, fmt.Errorf("recurring task %q: %v", current.Title, err)
+			}
+			current.Spec, current.Schedule = m[1], schedule
+			continue
+		}
+		body = append(body, line)
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	return defs, nil
+}
+
+// InstanceTitle is the title of the instance of d created at t
+func (d Definition) InstanceTitle(t time.Time) string {
+	return fmt.Sprintf("%s (%s)", d.Title, t.Format(instanceDate))
+}
+
+// IsInstance reports whether a task title is an instance of d
+func (d Definition) IsInstance(title string) bool {
+	rest, ok := strings.CutPrefix(title, d.Title+" (")
+	if !ok || !strings.HasSuffix(rest, ")") {
+		return false
+	}
+	_, err := time.Parse(instanceDate, strings.TrimSuffix(rest, ")"))
+	return err == nil
+}
+
+// Instance renders the tasks.md block of the instance of d created at t
+func (d Definition) Instance(t time.Time) string {
+	block := "### Task: " + d.InstanceTitle(t) + "\n"
+	if d.Body != "" {
+		block += "\n" + d.Body
+	}
+	return block
+}
+
+// State records when each definition last had an instance created
+type State map[string]time.Time
+
+// LoadState reads the state file; a missing file is an empty state
+func LoadState(path string) (State, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return State{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := State{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return state, nil
+}
+
+// Save writes the state file
+func (s State) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Due reports whether d should get a new instance at now: it never had one,
+// or its schedule fired since the last one
+func (s State) Due(d Definition, now time.Time) bool {
+	last, ok := s[d.Title]
+	if !ok {
+		return true
+	}
+	next := d.Schedule.Next(last)
+	return !next.IsZero() && !next.After(now)
+}
+
+// Materialize returns the definitions due at now whose previous instance is
+// no longer open, with their instance blocks, and records them in the state.
+// open reports whether a task title is in tasks.md and not completed.
+func Materialize(defs []Definition, state State, now time.Time, titles []string, open func(title string) bool) (due []Definition, blocks []string) {
+	for _, d := range defs {
+		if !state.Due(d, now) {
+			continue
+		}
+		pending := false
+		for _, title := range titles {
+			if d.IsInstance(title) && open(title) {
+				pending = true
+				break
+			}
+		}
+		if pending || containsTitle(titles, d.InstanceTitle(now)) {
+			continue
+		}
+		state[d.Title] = now
+		due = append(due, d)
+		blocks = append(blocks, d.Instance(now))
+	}
+	return due, blocks
+}
+
+func containsTitle(titles []string, title string) bool {
+	for _, t := range titles {
+		if t == title {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package recurring
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleDefinitions = `# Recurring Tasks
+
+### Task: Update dependencies
+**Schedule:** weekly
+**Context:** Keep dependencies current.
+**Acceptance Criteria:**
+
+* [ ] Dependencies updated
+
+### Task: Prune dead code
+**Schedule:** 0 9 1 * *
+**Context:** Remove unused code.
+**Acceptance Criteria:**
+
+* [ ] Dead code removed
+`
+
+func TestParse(t *testing.T) {
+	defs, err := Parse(sampleDefinitions)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(defs) != 2 || defs[0].Title != "Update dependencies" || defs[1].Spec != "0 9 1 * *" {
+		t.Fatalf("Parse() = %+v", defs)
+	}
+	if strings.Contains(defs[0].Body, "Schedule") || !strings.HasPrefix(defs[0].Body, "**Context:**") || !strings.HasSuffix(defs[0].Body, "* [ ] Dependencies updated") {
+		t.Errorf("Unexpected body %q", defs[0].Body)
+	}
+
+	for _, bad := range []string{"### Task: No schedule\n**Context:** x\n", "### Task: Bad\n**Schedule:** sometimes\n"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestInstances(t *testing.T) {
+	defs, _ := Parse(sampleDefinitions)
+	d := defs[0]
+	at := time.Date(2025, 1, 13, 0, 5, 0, 0, time.UTC)
+	if title := d.InstanceTitle(at); title != "Update dependencies (2025-01-13)" || !d.IsInstance(title) {
+		t.Errorf("InstanceTitle() = %q", title)
+	}
+	for _, title := range []string{"Update dependencies", "Update dependencies (soon)", "Update dependencies now (2025-01-13)"} {
+		if d.IsInstance(title) {
+			t.Errorf("Expected %q not to be an instance", title)
+		}
+	}
+	if block := d.Instance(at); !strings.HasPrefix(block, "### Task: Update dependencies (2025-01-13)\n\n**Context:**") {
+		t.Errorf("Instance() = %q", block)
+	}
+}
+
+func TestMaterialize(t *testing.T) {
+	defs, _ := Parse(sampleDefinitions)
+	monday := time.Date(2025, 1, 13, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		state    State
+		titles   []string
+		open     bool
+		now      time.Time
+		expected []string
+	}{
+		{"first run creates all", State{}, nil, false, monday, []string{"Update dependencies", "Prune dead code"}},
+		{"not due yet", State{"Update dependencies": monday, "Prune dead code": monday}, nil, false, monday.Add(24 * time.Hour), nil},
+		{"due next week", State{"Update dependencies": monday, "Prune dead code": monday}, nil, false, monday.Add(7 * 24 * time.Hour), []string{"Update dependencies"}},
+		{"previous still open", State{"Update dependencies": monday, "Prune dead code": monday}, []string{"Update dependencies (2025-01-13)"}, true, monday.Add(7 * 24 * time.Hour), nil},
+		{"previous completed", State{"Update dependencies": monday, "Prune dead code": monday}, []string{"Update dependencies (2025-01-13)"}, false, monday.Add(7 * 24 * time.Hour), []string{"Update dependencies"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			due, blocks := Materialize(defs, tt.state, tt.now, tt.titles, func(string) bool { return tt.open })
+			var names []string
+			for _, d := range due {
+				names = append(names, d.Title)
+				if !tt.state[d.Title].Equal(tt.now) {
+					t.Errorf("Expected the state of %q to be updated", d.Title)
+				}
+			}
+			if !reflect.DeepEqual(names, tt.expected) || len(blocks) != len(due) {
+				t.Errorf("Materialize() = %v, want %v", names, tt.expected)
+			}
+		})
+	}
+}
+
+func TestState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "recurring.json")
+	state, err := LoadState(path)
+	if err != nil || len(state) != 0 {
+		t.Fatalf("LoadState() of a missing file = %v, %v", state, err)
+	}
+	at := time.Date(2025, 1, 13, 8, 0, 0, 0, time.UTC)
+	state["Update dependencies"] = at
+	if err := state.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded, err := LoadState(path)
+	if err != nil || !loaded["Update dependencies"].Equal(at) {
+		t.Errorf("LoadState() = %v, %v", loaded, err)
+	}
+}
@@ -0,0 +1,143 @@
+package recurring
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSearch bounds the search for the next fire time of schedules that
+// can never fire, such as February 30th
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+// shorthands are the named schedules, as cron expressions
+var shorthands = map[string]string{
+	"hourly":  "0 * * * *",
+	"daily":   "0 0 * * *",
+	"weekly":  "0 0 * * 1",
+	"monthly": "0 0 1 * *",
+	"yearly":  "0 0 1 1 *",
+}
+
+// Schedule is a cron-like schedule: minute, hour, day of month, month and
+// day of week, each a set of allowed values
+type Schedule struct {
+	minute, hour, dom, month, dow map[int]bool
+	// domAny and dowAny are set for "*" fields; when both day fields are
+	// restricted a day matching either one fires, as in cron
+	domAny, dowAny bool
+}
+
+// ParseSchedule parses a five-field cron expression such as "0 9 * * 1", or
+// one of hourly, daily, weekly (Mondays), monthly and yearly, with or
+// without a leading "@"
+func ParseSchedule(spec string) (Schedule, error) {
+	expr := strings.TrimSpace(spec)
+	if cron, ok := shorthands[strings.ToLower(strings.TrimPrefix(expr, "@"))]; ok {
+		expr = cron
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return Schedule{}, fmt.Errorf("schedule %q: want 5 cron fields or one of hourly, daily, weekly, monthly, yearly", spec)
+	}
+	var s Schedule
+	var err error
+	parts := []struct {
+		set      *map[int]bool
+		name     string
+		min, max int
+	}{
+		{&s.minute, "minute", 0, 59},
+		{&s.hour, "hour", 0, 23},
+		{&s.dom, "day of month", 1, 31},
+		{&s.month, "month", 1, 12},
+		{&s.dow, "day of week", 0, 7},
+	}
+	for i, p := range parts {
+		if *p.set, err = parseField(fields[i], p.min, p.max); err != nil {
+			return Schedule{}, fmt.Errorf("schedule %q: %s: %v", spec, p.name, err)
+		}
+	}
+	if s.dow[7] {
+		s.dow[0] = true
+	}
+	s.domAny, s.dowAny = fields[2] == "*", fields[4] == "*"
+	return s, nil
+}
+
+// parseField parses a comma-separated list of values, ranges ("1-5"), "*"
+// and steps ("*/15", "1-10/2")
+func parseField(field string, min, max int) (map[int]bool, error) {
+	set := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid step %q", stepStr)
+			}
+			step = n
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			from, to, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(from); err != nil {
+				return nil, fmt.Errorf("invalid value %q", from)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(to); err != nil {
+					return nil, fmt.Errorf("invalid value %q", to)
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return nil, fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+func (s Schedule) dayMatches(t time.Time) bool {
+	dom, dow := s.dom[t.Day()], s.dow[int(t.Weekday())]
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// Next returns the first time the schedule fires after t, or the zero time
+// if it never does
+func (s Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxSearch)
+	for t.Before(limit) {
+		switch {
+		case !s.month[int(t.Month())]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !s.hour[t.Hour()]:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !s.minute[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,50 @@
+package recurring
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	// Wednesday
+	from := time.Date(2025, 1, 8, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"hourly", time.Date(2025, 1, 8, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2025, 1, 9, 0, 0, 0, 0, time.UTC)},
+		{"weekly", time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)},
+		{"monthly", time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, 1, 8, 10, 45, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2025, 1, 9, 9, 0, 0, 0, time.UTC)},
+		{"30 10 * * 3", time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * 0", time.Date(2025, 1, 12, 0, 0, 0, 0, time.UTC)}, // 1st of the month or Sunday
+		{"0 0 * * 7", time.Date(2025, 1, 12, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := ParseSchedule(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseSchedule() error = %v", err)
+			}
+			if got := s.Next(from); !got.Equal(tt.expected) {
+				t.Errorf("Next() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+
+	never, _ := ParseSchedule("0 0 30 2 *")
+	if got := never.Next(from); !got.IsZero() {
+		t.Errorf("Expected February 30th never to fire, got %v", got)
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, spec := range []string{"", "fortnightly", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("Expected an error for %q", spec)
+		}
+	}
+}
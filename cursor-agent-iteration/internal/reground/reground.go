@@ -0,0 +1,203 @@
+package reground
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// maxRenameHops bounds how many successive renames are followed for one path
+const maxRenameHops = 5
+
+// FileState describes what happened to a file listed by a task
+type FileState string
+
+const (
+	FileExists  FileState = "exists"
+	FileRenamed FileState = "renamed"
+	FileDeleted FileState = "deleted"
+	// FileMissing means the file doesn't exist and history doesn't say why;
+	// it is often a file the task is meant to create
+	FileMissing FileState = "missing"
+)
+
+// FileCheck is the result of checking one listed path
+type FileCheck struct {
+	Path    string
+	State   FileState
+	NewPath string // set when renamed
+	Commit  string // commit that renamed or deleted the file
+}
+
+// Report is the freshness of all files listed by a task
+type Report struct {
+	Files []FileCheck
+}
+
+// History looks up what happened to a path that no longer exists. It returns
+// the path it was renamed to ("" if it was deleted) and the commit, or
+// found=false when history has no record of its removal.
+type History func(path string) (newPath string, commit string, found bool)
+
+// Check verifies each listed file under root, following renames via history
+func Check(root string, files []string, history History) Report {
+	var r Report
+	for _, f := range files {
+		r.Files = append(r.Files, checkFile(root, f, history))
+	}
+	return r
+}
+
+func checkFile(root, path string, history History) FileCheck {
+	c := FileCheck{Path: path, State: FileExists}
+	if exists(root, path) {
+		return c
+	}
+	c.State = FileMissing
+	if history == nil {
+		return c
+	}
+
+	current := path
+	for hop := 0; hop < maxRenameHops; hop++ {
+		next, commit, found := history(current)
+		if !found {
+			break
+		}
+		c.Commit = commit
+		if next == "" {
+			c.State = FileDeleted
+			c.NewPath = ""
+			return c
+		}
+		c.State = FileRenamed
+		c.NewPath = next
+		if exists(root, next) {
+			return c
+		}
+		current = next
+	}
+	if c.State == FileRenamed && !exists(root, c.NewPath) {
+		// Renamed, then removed without a record we could follow
+		c.State = FileDeleted
+		c.NewPath = ""
+	}
+	return c
+}
+
+func exists(root, path string) bool {
+	_, err := os.Stat(filepath.Join(root, path))
+	return err == nil
+}
+
+// Renames maps renamed paths to their current location
+func (r Report) Renames() map[string]string {
+	renames := make(map[string]string)
+	for _, f := range r.Files {
+		if f.State == FileRenamed {
+			renames[f.Path] = f.NewPath
+		}
+	}
+	return renames
+}
+
+// Stale reports whether any listed file was renamed or deleted
+func (r Report) Stale() bool {
+	for _, f := range r.Files {
+		if f.State == FileRenamed || f.State == FileDeleted {
+			return true
+		}
+	}
+	return false
+}
+
+// Obsolete reports whether the task appears to target code that is gone:
+// it lists files, none of them still exist under any name, and at least one
+// was deliberately deleted
+func (r Report) Obsolete() bool {
+	deleted := false
+	for _, f := range r.Files {
+		switch f.State {
+		case FileExists, FileRenamed:
+			return false
+		case FileDeleted:
+			deleted = true
+		}
+	}
+	return deleted
+}
+
+// PromptNote describes renamed and deleted files for the agent, or "" when
+// every listed file is where the task says it is
+func (r Report) PromptNote() string {
+	var parts []string
+	for _, f := range r.Files {
+		switch f.State {
+		case FileRenamed:
+			parts = append(parts, fmt.Sprintf("%s was moved to %s%s", f.Path, f.NewPath, commitSuffix(f.Commit)))
+		case FileDeleted:
+			parts = append(parts, fmt.Sprintf("%s was deleted%s", f.Path, commitSuffix(f.Commit)))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	note := "The task's file list is out of date: " + strings.Join(parts, "; ") + "."
+	if r.Obsolete() {
+		note += " None of the listed files exist anymore, so the task may be obsolete: confirm it still applies before changing code, and if it doesn't, record that in .cursor-iter/progress.md instead of implementing it."
+	} else {
+		note += " Work on the current paths."
+	}
+	return note
+}
+
+func commitSuffix(commit string) string {
+	if commit == "" {
+		return ""
+	}
+	if len(commit) > 8 {
+		commit = commit[:8]
+	}
+	return " in " + commit
+}
+
+// GitHistory returns a History backed by the git repository at root. The
+// latest commit that removed a path is inspected with rename detection to
+// tell moves apart from deletions.
+func GitHistory(root string) History {
+	return func(path string) (string, string, bool) {
+		out, err := git(root, "log", "-1", "--format=%H", "--diff-filter=D", "--", path)
+		commit := strings.TrimSpace(out)
+		if err != nil || commit == "" {
+			return "", "", false
+		}
+		out, err = git(root, "show", "-M", "--relative", "--name-status", "--format=", commit)
+		if err != nil {
+			return "", commit, true
+		}
+		return parseRename(out, path), commit, true
+	}
+}
+
+// parseRename finds path's destination in `git show --name-status` output
+func parseRename(nameStatus, path string) string {
+	for _, line := range strings.Split(nameStatus, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) == 3 && strings.HasPrefix(fields[0], "R") && fields[1] == path {
+			return fields[2]
+		}
+	}
+	return ""
+}
+
+func git(root string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = root
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	err := cmd.Run()
+	return stdout.String(), err
+}
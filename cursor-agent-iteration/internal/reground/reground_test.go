@@ -0,0 +1,133 @@
+package reground
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeHistory maps a removed path to its new path ("" when deleted)
+func fakeHistory(moves map[string]string) History {
+	return func(path string) (string, string, bool) {
+		next, ok := moves[path]
+		return next, "abc1234def", ok
+	}
+}
+
+func TestCheck(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "internal/api"), 0755)
+	os.WriteFile(filepath.Join(root, "main.go"), []byte("package main"), 0644)
+	os.WriteFile(filepath.Join(root, "internal/api/api.go"), []byte("package api"), 0644)
+
+	history := fakeHistory(map[string]string{
+		"src/api.go":     "src/api/api.go",
+		"src/api/api.go": "internal/api/api.go",
+		"old/util.go":    "",
+	})
+
+	r := Check(root, []string{"main.go", "src/api.go", "old/util.go", "new/feature.go"}, history)
+
+	expected := []FileCheck{
+		{Path: "main.go", State: FileExists},
+		{Path: "src/api.go", State: FileRenamed, NewPath: "internal/api/api.go", Commit: "abc1234def"},
+		{Path: "old/util.go", State: FileDeleted, Commit: "abc1234def"},
+		{Path: "new/feature.go", State: FileMissing},
+	}
+	if len(r.Files) != len(expected) {
+		t.Fatalf("Expected %d checks, got %d", len(expected), len(r.Files))
+	}
+	for i, want := range expected {
+		if r.Files[i] != want {
+			t.Errorf("Files[%d] = %+v, want %+v", i, r.Files[i], want)
+		}
+	}
+
+	if !r.Stale() {
+		t.Errorf("Expected report to be stale")
+	}
+	if r.Obsolete() {
+		t.Errorf("Expected task with existing files not to be obsolete")
+	}
+	if renames := r.Renames(); renames["src/api.go"] != "internal/api/api.go" || len(renames) != 1 {
+		t.Errorf("Renames() = %v", renames)
+	}
+
+	note := r.PromptNote()
+	for _, want := range []string{
+		"src/api.go was moved to internal/api/api.go in abc1234d",
+		"old/util.go was deleted in abc1234d",
+		"Work on the current paths",
+	} {
+		if !strings.Contains(note, want) {
+			t.Errorf("PromptNote() missing %q: %s", want, note)
+		}
+	}
+}
+
+func TestObsolete(t *testing.T) {
+	root := t.TempDir()
+	history := fakeHistory(map[string]string{"gone.go": ""})
+
+	tests := []struct {
+		name     string
+		files    []string
+		obsolete bool
+	}{
+		{name: "all listed files deleted", files: []string{"gone.go"}, obsolete: true},
+		{name: "deleted plus not yet created", files: []string{"gone.go", "todo.go"}, obsolete: true},
+		{name: "only files to create", files: []string{"todo.go"}, obsolete: false},
+		{name: "no files", files: nil, obsolete: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Check(root, tt.files, history)
+			if r.Obsolete() != tt.obsolete {
+				t.Errorf("Obsolete() = %v, want %v", r.Obsolete(), tt.obsolete)
+			}
+			if tt.obsolete && !strings.Contains(r.PromptNote(), "may be obsolete") {
+				t.Errorf("Expected obsolete note, got %q", r.PromptNote())
+			}
+		})
+	}
+
+	if note := Check(root, []string{"todo.go"}, history).PromptNote(); note != "" {
+		t.Errorf("Expected no note for files yet to be created, got %q", note)
+	}
+}
+
+func TestGitHistory(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found in PATH, skipping test")
+	}
+	root := t.TempDir()
+	run := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = root
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	run("init", "-q")
+	os.MkdirAll(filepath.Join(root, "src"), 0755)
+	os.WriteFile(filepath.Join(root, "src/a.go"), []byte("package src\n\nfunc A() {}\n"), 0644)
+	os.WriteFile(filepath.Join(root, "src/b.go"), []byte("package src\n\nfunc B() {}\n"), 0644)
+	run("add", ".")
+	run("commit", "-qm", "init")
+	run("mv", "src/a.go", "src/alpha.go")
+	run("commit", "-qm", "rename")
+	run("mv", "src/alpha.go", "alpha.go")
+	run("rm", "-q", "src/b.go")
+	run("commit", "-qm", "move and delete")
+
+	r := Check(root, []string{"src/a.go", "src/b.go"}, GitHistory(root))
+	if r.Files[0].State != FileRenamed || r.Files[0].NewPath != "alpha.go" {
+		t.Errorf("src/a.go check = %+v, want renamed to alpha.go", r.Files[0])
+	}
+	if r.Files[1].State != FileDeleted {
+		t.Errorf("src/b.go check = %+v, want deleted", r.Files[1])
+	}
+}
@@ -0,0 +1,301 @@
+// Package review has a second agent, usually running a different model,
+// review the diff of a completed task. Findings are graded by severity; a
+// policy decides whether they go back to the task's agent as fix requests or
+// hold the task for a human to sign off. Every review round is recorded in a
+// per-task Markdown file.
+package review
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Severity grades a finding; higher is worse
+type Severity int
+
+const (
+	None Severity = iota // disables a policy threshold
+	Low
+	Medium
+	High
+	Critical
+)
+
+var severityNames = []string{"none", "low", "medium", "high", "critical"}
+
+func (s Severity) String() string {
+	if s < None || int(s) >= len(severityNames) {
+		return "unknown"
+	}
+	return severityNames[s]
+}
+
+// ParseSeverity parses a severity name
+func ParseSeverity(name string) (Severity, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for i, n := range severityNames {
+		if n == name {
+			return Severity(i), nil
+		}
+	}
+	return None, fmt.Errorf("unknown severity %q (want low, medium, high, critical or none)", name)
+}
+
+// Finding is one issue raised by the reviewer
+type Finding struct {
+	Severity Severity
+	// Location is a file or file:line, if the reviewer gave one
+	Location string
+	Message  string
+}
+
+func (f Finding) String() string {
+	if f.Location == "" {
+		return fmt.Sprintf("[%s] %s", f.Severity, f.Message)
+	}
+	return fmt.Sprintf("[%s] %s - %s", f.Severity, f.Location, f.Message)
+}
+
+// maxDiff limits how much of the diff goes into the prompt
+const maxDiff = 60000
+
+// Input is what a review is written from
+type Input struct {
+	Title   string
+	Details string // the task block from tasks.md
+	Diff    string
+}
+
+// Prompt asks the reviewer for findings in the format Parse reads
+func Prompt(in Input) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Review the changes made for the task %q.\n\n", in.Title)
+	b.WriteString(`Do not modify any files. Look for bugs, security problems, missing error handling, missing tests and acceptance criteria the diff doesn't meet. Ignore style nits a formatter or linter would catch.
+
+Reply with one line per finding, worst first, in exactly this format:
+
+- [severity] path/to/file:line - what is wrong and how to fix it
+
+where severity is low, medium, high or critical. Leave out the location if the finding isn't about one place. If there is nothing worth fixing, reply with "No findings".
+`)
+	if in.Details != "" {
+		fmt.Fprintf(&b, "\n## Task\n\n%s\n", strings.TrimSpace(in.Details))
+	}
+	diff := strings.TrimSpace(in.Diff)
+	if len(diff) > maxDiff {
+		diff = diff[:maxDiff] + "\n... (truncated)"
+	}
+	fmt.Fprintf(&b, "\n## Diff\n\n```diff\n%s\n```\n", diff)
+	return b.String()
+}
+
+// reFinding matches "- [high] file.go:12 - message"; the location, which
+// may be in backticks, is optional
+var reFinding = regexp.MustCompile("^\\s*(?:[-*]|\\d+\\.)\\s+\\*{0,2}\\[(?i:(low|medium|high|critical))\\]\\*{0,2}\\s+(?:`?([^\\s`]+)`?\\s+[-—–:]\\s+)?(.+?)\\s*$")
+
+// Parse reads the findings of a reviewer's reply. Lines that aren't
+// findings are ignored, so a reply without any is a clean review.
+func Parse(reply string) []Finding {
+	var findings []Finding
+	for _, line := range strings.Split(reply, "\n") {
+		m := reFinding.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		severity, _ := ParseSeverity(m[1])
+		location := m[2]
+		if location != "" && !looksLikePath(location) {
+			// The "location" was the first word of a message with a dash
+			m[3] = location + " - " + m[3]
+			location = ""
+		}
+		findings = append(findings, Finding{Severity: severity, Location: location, Message: m[3]})
+	}
+	return findings
+}
+
+// looksLikePath reports whether a word is a file or file:line
+func looksLikePath(word string) bool {
+	return strings.ContainsAny(word, "/.")
+}
+
+// Worst returns the highest severity of the findings
+func Worst(findings []Finding) Severity {
+	worst := None
+	for _, f := range findings {
+		if f.Severity > worst {
+			worst = f.Severity
+		}
+	}
+	return worst
+}
+
+// Action is what happens to a task after a review
+type Action string
+
+const (
+	ActionAccept  Action = "accepted"          // the task stays completed
+	ActionFix     Action = "fix requested"     // the task goes back to its agent
+	ActionSignOff Action = "sign-off required" // the task waits for a human
+	ActionSigned  Action = "signed off"        // a human accepted the task
+)
+
+// Policy decides the action for the findings of a review
+type Policy struct {
+	// Fix is the lowest severity sent back to the agent
+	Fix Severity
+	// SignOff is the lowest severity that needs a human
+	SignOff Severity
+	// Rounds is how many fix rounds a task gets before findings at the fix
+	// level need a human too
+	Rounds int
+}
+
+// DefaultPolicy sends high findings back to the agent, twice at most, and
+// holds critical ones for a human
+var DefaultPolicy = Policy{Fix: High, SignOff: Critical, Rounds: 2}
+
+// ParsePolicy parses a policy such as "fix=medium;signoff=critical;rounds=1".
+// Unset fields keep their defaults; "none" turns a threshold off.
+func ParsePolicy(spec string) (Policy, error) {
+	p := DefaultPolicy
+	for _, part := range strings.FieldsFunc(spec, func(r rune) bool { return r == ';' || r == ',' }) {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return p, fmt.Errorf("expected key=value, got %q", part)
+		}
+		var err error
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "fix":
+			p.Fix, err = ParseSeverity(value)
+		case "signoff", "sign-off":
+			p.SignOff, err = ParseSeverity(value)
+		case "rounds":
+			p.Rounds, err = strconv.Atoi(strings.TrimSpace(value))
+			if err == nil && p.Rounds < 0 {
+				err = fmt.Errorf("rounds must not be negative")
+			}
+		default:
+			err = fmt.Errorf("unknown setting %q (want fix, signoff or rounds)", key)
+		}
+		if err != nil {
+			return p, err
+		}
+	}
+	return p, nil
+}
+
+// Decide returns the action for the findings of a review, given how many
+// fix rounds the task has had
+func (p Policy) Decide(findings []Finding, fixRounds int) Action {
+	worst := Worst(findings)
+	switch {
+	case worst == None:
+		return ActionAccept
+	case p.SignOff != None && worst >= p.SignOff:
+		return ActionSignOff
+	case p.Fix != None && worst >= p.Fix:
+		if fixRounds < p.Rounds {
+			return ActionFix
+		}
+		if p.SignOff != None {
+			return ActionSignOff
+		}
+	}
+	return ActionAccept
+}
+
+// Round is one review of a task, as recorded in its review file
+type Round struct {
+	At       time.Time
+	Reviewer string
+	Action   Action
+	Findings []Finding
+}
+
+// reRound matches the header of a recorded round
+var reRound = regexp.MustCompile(`^## Round \d+ - .+ - (.+)$`)
+
+// Record appends a round to the review file of a task
+func Record(md string, title string, r Round) string {
+	if strings.TrimSpace(md) == "" {
+		md = "# Review: " + title + "\n"
+	}
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(md, "\n"))
+	fmt.Fprintf(&b, "\n\n## Round %d - %s - %s\n", Rounds(md)+1, r.At.Format("2006-01-02 15:04"), r.Action)
+	if r.Reviewer != "" {
+		fmt.Fprintf(&b, "\nReviewer: %s\n", r.Reviewer)
+	}
+	b.WriteString("\n")
+	if len(r.Findings) == 0 && r.Action != ActionSigned {
+		b.WriteString("No findings.\n")
+	}
+	for _, f := range r.Findings {
+		b.WriteString("- " + f.String() + "\n")
+	}
+	return b.String()
+}
+
+// Rounds counts the rounds recorded in a review file
+func Rounds(md string) int {
+	n := 0
+	for _, line := range strings.Split(md, "\n") {
+		if reRound.MatchString(line) {
+			n++
+		}
+	}
+	return n
+}
+
+// FixRounds counts the rounds that sent the task back to its agent
+func FixRounds(md string) int {
+	n := 0
+	for _, line := range strings.Split(md, "\n") {
+		if m := reRound.FindStringSubmatch(line); m != nil && Action(m[1]) == ActionFix {
+			n++
+		}
+	}
+	return n
+}
+
+// reRoundHeader matches the header of a recorded round with its time
+var reRoundHeader = regexp.MustCompile(`^## Round \d+ - (\d{4}-\d{2}-\d{2} \d{2}:\d{2}) - (.+)$`)
+
+// ParseRounds reads the rounds recorded in a review file, oldest first.
+// Round times are read in loc, as Record writes them without a zone.
+func ParseRounds(md string, loc *time.Location) []Round {
+	var rounds []Round
+	for _, line := range strings.Split(md, "\n") {
+		if m := reRoundHeader.FindStringSubmatch(line); m != nil {
+			at, _ := time.ParseInLocation("2006-01-02 15:04", m[1], loc)
+			rounds = append(rounds, Round{At: at, Action: Action(m[2])})
+			continue
+		}
+		if len(rounds) == 0 {
+			continue
+		}
+		r := &rounds[len(rounds)-1]
+		if reviewer, ok := strings.CutPrefix(line, "Reviewer: "); ok && r.Reviewer == "" {
+			r.Reviewer = strings.TrimSpace(reviewer)
+		} else {
+			r.Findings = append(r.Findings, Parse(line)...)
+		}
+	}
+	return rounds
+}
+
+// LastAction returns the action of the last recorded round, or "" if there
+// is none
+func LastAction(md string) Action {
+	var last Action
+	for _, line := range strings.Split(md, "\n") {
+		if m := reRound.FindStringSubmatch(line); m != nil {
+			last = Action(m[1])
+		}
+	}
+	return last
+}
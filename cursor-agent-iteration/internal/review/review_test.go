@@ -0,0 +1,122 @@
+package review
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	reply := "Here is my review.\n\n" +
+		"- [critical] internal/auth/login.go:42 - password compared with ==\n" +
+		"* **[High]** `api/handler.go` — missing error check on Close\n" +
+		"1. [medium] no tests for the retry path\n" +
+		"- [low] naming - the helper could be clearer\n" +
+		"- [blocker] not a severity\n" +
+		"Overall looks fine.\n"
+	expected := []Finding{
+		{Severity: Critical, Location: "internal/auth/login.go:42", Message: "password compared with =="},
+		{Severity: High, Location: "api/handler.go", Message: "missing error check on Close"},
+		{Severity: Medium, Message: "no tests for the retry path"},
+		{Severity: Low, Message: "naming - the helper could be clearer"},
+	}
+	if got := Parse(reply); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Parse() = %+v, want %+v", got, expected)
+	}
+	if got := Parse("No findings"); len(got) != 0 {
+		t.Errorf("Expected no findings, got %+v", got)
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected Policy
+		wantErr  bool
+	}{
+		{"", DefaultPolicy, false},
+		{"fix=medium", Policy{Fix: Medium, SignOff: Critical, Rounds: 2}, false},
+		{"fix=high; signoff=none, rounds=0", Policy{Fix: High, SignOff: None, Rounds: 0}, false},
+		{"sign-off=high", Policy{Fix: High, SignOff: High, Rounds: 2}, false},
+		{"fix=urgent", Policy{}, true},
+		{"rounds=-1", Policy{}, true},
+		{"fix", Policy{}, true},
+		{"level=high", Policy{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePolicy(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePolicy(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.expected {
+			t.Errorf("ParsePolicy(%q) = %+v, want %+v", tt.spec, got, tt.expected)
+		}
+	}
+}
+
+func TestDecide(t *testing.T) {
+	low := []Finding{{Severity: Low}}
+	high := []Finding{{Severity: Low}, {Severity: High}}
+	critical := []Finding{{Severity: Critical}}
+	tests := []struct {
+		name      string
+		policy    Policy
+		findings  []Finding
+		fixRounds int
+		expected  Action
+	}{
+		{"no findings", DefaultPolicy, nil, 0, ActionAccept},
+		{"below fix level", DefaultPolicy, low, 0, ActionAccept},
+		{"fix level", DefaultPolicy, high, 1, ActionFix},
+		{"out of fix rounds", DefaultPolicy, high, 2, ActionSignOff},
+		{"sign-off level", DefaultPolicy, critical, 0, ActionSignOff},
+		{"sign-off off", Policy{Fix: High, Rounds: 1}, critical, 0, ActionFix},
+		{"out of rounds without sign-off", Policy{Fix: High, Rounds: 1}, critical, 1, ActionAccept},
+		{"everything off", Policy{}, critical, 0, ActionAccept},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.Decide(tt.findings, tt.fixRounds); got != tt.expected {
+			t.Errorf("%s: Decide() = %q, want %q", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestRecord(t *testing.T) {
+	at := time.Date(2025, 1, 8, 19, 0, 0, 0, time.UTC)
+	md := Record("", "Add login", Round{At: at, Reviewer: "gpt-5", Action: ActionFix, Findings: []Finding{{Severity: High, Location: "login.go:3", Message: "unchecked error"}}})
+	md = Record(md, "Add login", Round{At: at, Reviewer: "gpt-5", Action: ActionAccept})
+
+	expected := "# Review: Add login\n\n" +
+		"## Round 1 - 2025-01-08 19:00 - fix requested\n\nReviewer: gpt-5\n\n- [high] login.go:3 - unchecked error\n\n" +
+		"## Round 2 - 2025-01-08 19:00 - accepted\n\nReviewer: gpt-5\n\nNo findings.\n"
+	if md != expected {
+		t.Errorf("Record() =\n%s\nwant\n%s", md, expected)
+	}
+	if Rounds(md) != 2 || FixRounds(md) != 1 || LastAction(md) != ActionAccept {
+		t.Errorf("Rounds = %d, FixRounds = %d, LastAction = %q", Rounds(md), FixRounds(md), LastAction(md))
+	}
+	if LastAction("") != "" {
+		t.Error("Expected no action without a review file")
+	}
+
+	rounds := ParseRounds(Record(md, "Add login", Round{At: at.Add(time.Hour), Reviewer: "alice", Action: ActionSigned}), time.UTC)
+	want := []Round{
+		{At: at, Reviewer: "gpt-5", Action: ActionFix, Findings: []Finding{{Severity: High, Location: "login.go:3", Message: "unchecked error"}}},
+		{At: at, Reviewer: "gpt-5", Action: ActionAccept},
+		{At: at.Add(time.Hour), Reviewer: "alice", Action: ActionSigned},
+	}
+	if !reflect.DeepEqual(rounds, want) {
+		t.Errorf("ParseRounds() = %+v, want %+v", rounds, want)
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	prompt := Prompt(Input{Title: "Add login", Details: "### Task: Add login\n", Diff: strings.Repeat("+x\n", maxDiff)})
+	for _, want := range []string{`"Add login"`, "- [severity] path/to/file:line", "## Task\n\n### Task: Add login", "... (truncated)"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("Expected prompt to contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Backend identifies an agent CLI that can work on a prompt
+type Backend string
+
+const (
+	BackendCursorAgent Backend = "cursor-agent"
+	BackendCodex       Backend = "codex"
+	BackendClaude      Backend = "claude"
+)
+
+// knownBackends lists the backends RunPrompt can drive
+var knownBackends = []Backend{BackendCursorAgent, BackendCodex, BackendClaude}
+
+// ParseBackend converts a backend name into a Backend
+func ParseBackend(name string) (Backend, error) {
+	name = strings.TrimSpace(strings.ToLower(name))
+	for _, b := range knownBackends {
+		if string(b) == name {
+			return b, nil
+		}
+	}
+	return "", fmt.Errorf("unknown agent backend %q (supported: %s)", name, joinBackends(knownBackends))
+}
+
+// ParseChain parses a comma-separated, ordered list of backends such as
+// "cursor-agent,codex". Duplicates are dropped, keeping the first occurrence.
+func ParseChain(spec string) ([]Backend, error) {
+	var chain []Backend
+	seen := make(map[Backend]bool)
+	for _, part := range strings.Split(spec, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		b, err := ParseBackend(part)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[b] {
+			seen[b] = true
+			chain = append(chain, b)
+		}
+	}
+	return chain, nil
+}
+
+// DefaultModel returns the model to use for a backend when model is "auto"
+func DefaultModel(backend Backend, model string) string {
+	if backend == BackendCodex && (model == "" || model == "auto") {
+		return "gpt-5-codex"
+	}
+	if model == "" {
+		return "auto"
+	}
+	return model
+}
+
+// RunPrompt sends a prompt to the given backend in non-interactive mode
+func RunPrompt(opts Options, backend Backend, model string, prompt string) error {
+	switch backend {
+	case BackendCodex:
+		return CodexWithOptions(opts, DefaultModel(backend, model), prompt)
+	case BackendCursorAgent:
+		return CursorAgentWithOptions(opts, "--print", "--force", prompt)
+	case BackendClaude:
+		return ClaudeWithOptions(opts, DefaultModel(backend, model), prompt)
+	default:
+		return fmt.Errorf("unknown agent backend %q", backend)
+	}
+}
+
+// RunChain sends a prompt to each backend of the chain in order, giving every
+// backend up to attempts tries before falling back to the next one. The model
+// only applies to the first backend; fallbacks use their default model.
+// It returns the backend that succeeded, or the last error.
+func RunChain(opts Options, chain []Backend, attempts int, model string, prompt string) (Backend, error) {
+	if len(chain) == 0 {
+		return "", fmt.Errorf("empty agent backend chain")
+	}
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var lastErr error
+	for i, backend := range chain {
+		backendModel := model
+		if i > 0 {
+			backendModel = "auto"
+		}
+		for attempt := 1; attempt <= attempts; attempt++ {
+			if err := opts.interrupted(); err != nil {
+				return "", err
+			}
+			lastErr = RunPrompt(opts, backend, backendModel, prompt)
+			if lastErr == nil {
+				return backend, nil
+			}
+			fmt.Printf("[%s] ⚠️ %s attempt %d/%d failed: %v\n", timestamp(), backend, attempt, attempts, lastErr)
+			if attempt < attempts {
+				opts.retry(backend, attempt+1, lastErr)
+			}
+		}
+		if i+1 < len(chain) {
+			fmt.Printf("[%s] 🔀 Falling back from %s to %s\n", timestamp(), backend, chain[i+1])
+			opts.retry(chain[i+1], 1, lastErr)
+		}
+	}
+	return "", fmt.Errorf("all agent backends failed (%s): %w", joinBackends(chain), lastErr)
+}
+
+func joinBackends(chain []Backend) string {
+	names := make([]string, len(chain))
+	for i, b := range chain {
+		names[i] = string(b)
+	}
+	return strings.Join(names, ", ")
+}
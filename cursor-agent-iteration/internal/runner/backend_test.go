@@ -0,0 +1,172 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseChain(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		expected []Backend
+		wantErr  bool
+	}{
+		{
+			name:     "empty spec",
+			spec:     "",
+			expected: nil,
+		},
+		{
+			name:     "ordered chain",
+			spec:     "cursor-agent, codex",
+			expected: []Backend{BackendCursorAgent, BackendCodex},
+		},
+		{
+			name:     "duplicates dropped",
+			spec:     "codex,CODEX,cursor-agent",
+			expected: []Backend{BackendCodex, BackendCursorAgent},
+		},
+		{
+			name:     "claude",
+			spec:     "claude,cursor-agent",
+			expected: []Backend{BackendClaude, BackendCursorAgent},
+		},
+		{
+			name:    "unknown backend",
+			spec:    "codex,gemini",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, err := ParseChain(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseChain(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if len(chain) != len(tt.expected) {
+				t.Fatalf("ParseChain(%q) = %v, want %v", tt.spec, chain, tt.expected)
+			}
+			for i := range chain {
+				if chain[i] != tt.expected[i] {
+					t.Errorf("ParseChain(%q)[%d] = %s, want %s", tt.spec, i, chain[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultModel(t *testing.T) {
+	tests := []struct {
+		backend  Backend
+		model    string
+		expected string
+	}{
+		{BackendCodex, "auto", "gpt-5-codex"},
+		{BackendCodex, "", "gpt-5-codex"},
+		{BackendCodex, "o3", "o3"},
+		{BackendCursorAgent, "", "auto"},
+		{BackendCursorAgent, "gpt-4o", "gpt-4o"},
+		{BackendClaude, "auto", "auto"},
+		{BackendClaude, "opus", "opus"},
+	}
+
+	for _, tt := range tests {
+		if got := DefaultModel(tt.backend, tt.model); got != tt.expected {
+			t.Errorf("DefaultModel(%s, %q) = %q, want %q", tt.backend, tt.model, got, tt.expected)
+		}
+	}
+}
+
+func TestRunChainAllBackendsMissing(t *testing.T) {
+	originalPath := os.Getenv("PATH")
+	defer os.Setenv("PATH", originalPath)
+	os.Setenv("PATH", "")
+
+	var retries []string
+	opts := Options{OnRetry: func(b Backend, attempt int, err error) {
+		retries = append(retries, fmt.Sprintf("%s#%d", b, attempt))
+	}}
+	backend, err := RunChain(opts, []Backend{BackendCursorAgent, BackendCodex}, 2, "auto", "prompt")
+	if got := strings.Join(retries, " "); got != "cursor-agent#2 codex#1 codex#2" {
+		t.Errorf("retries = %q", got)
+	}
+	if err == nil {
+		t.Fatalf("Expected error when no backend is installed")
+	}
+	if backend != "" {
+		t.Errorf("Expected no successful backend, got %s", backend)
+	}
+	if !contains(err.Error(), "all agent backends failed") {
+		t.Errorf("Expected chain failure message, got: %v", err)
+	}
+}
+
+func TestRunChainEmpty(t *testing.T) {
+	if _, err := RunChain(Options{}, nil, 1, "auto", "prompt"); err == nil {
+		t.Errorf("Expected error for empty chain")
+	}
+}
+
+func TestRunPromptUnknownBackend(t *testing.T) {
+	if err := RunPrompt(Options{}, Backend("nope"), "auto", "prompt"); err == nil {
+		t.Errorf("Expected error for unknown backend")
+	}
+}
+
+func TestParseModelList(t *testing.T) {
+	output := "\x1b[1mAvailable models:\x1b[0m\n\n  - auto (default)\n  - gpt-5 - OpenAI\n  * sonnet-4-thinking\n  opus-4.1     Anthropic\n\nTo use a model run cursor-agent --model <id>\n- gpt-5\n"
+	want := []string{"auto", "gpt-5", "sonnet-4-thinking", "opus-4.1"}
+	if got := ParseModelList(output); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("ParseModelList() = %v, want %v", got, want)
+	}
+	if got := ParseModelList("Error: not logged in"); len(got) != 0 {
+		t.Errorf("ParseModelList() of an error = %v", got)
+	}
+}
+
+func TestProbeBackend(t *testing.T) {
+	dir := t.TempDir()
+	script := "#!/bin/sh\ncase \"$1\" in\n--version) echo 'cursor-agent 2025.10.02-abc' ;;\nmodels) printf 'Available models:\\n- auto\\n- gpt-5\\n' ;;\nesac\n"
+	if err := os.WriteFile(dir+"/cursor-agent", []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	p := ProbeBackend(context.Background(), BackendCursorAgent)
+	if !p.Installed() || p.Version != "2025.10.02-abc" || p.ModelsFrom != ModelsFromCLI || strings.Join(p.Models, ",") != "auto,gpt-5" {
+		t.Errorf("ProbeBackend(cursor-agent) = %+v", p)
+	}
+	if p := ProbeBackend(context.Background(), BackendClaude); p.Installed() || p.ModelsFrom != ModelsFromBuiltin || len(p.Models) == 0 {
+		t.Errorf("ProbeBackend() of a missing CLI = %+v", p)
+	}
+}
+
+func TestCursorAgentConfigRace(t *testing.T) {
+	dir := t.TempDir()
+	// The first start races on cli-config.json, the second succeeds
+	script := "#!/bin/sh\nif [ ! -f " + dir + "/raced ]; then : > " + dir + "/raced; echo \"ENOENT: rename cli-config.json.tmp\" >&2; exit 1; fi\n"
+	os.WriteFile(filepath.Join(dir, "cursor-agent"), []byte(script), 0755)
+	t.Setenv("PATH", dir)
+	t.Setenv("CURSOR_AGENT_NO_STAGGER", "1")
+
+	var races int
+	opts := Options{Stdout: io.Discard, Stderr: io.Discard, OnRetry: func(b Backend, attempt int, err error) {
+		if errors.Is(err, ErrConfigRace) {
+			races++
+		}
+	}}
+	if err := CursorAgentWithOptions(opts, "-p", "prompt"); err != nil {
+		t.Fatalf("Expected the retry to succeed, got %v", err)
+	}
+	if races != 1 {
+		t.Errorf("Expected one retry reported as a config race, got %d", races)
+	}
+}
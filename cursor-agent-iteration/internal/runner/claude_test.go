@@ -0,0 +1,79 @@
+//go:build unix
+
+package runner
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClaude puts a claude script in dir, the only directory on the PATH,
+// that logs its arguments to calls.log and then runs body with $dir set
+func fakeClaude(t *testing.T, body string) string {
+	t.Helper()
+	dir := t.TempDir()
+	script := "#!/bin/sh\ndir=" + dir + "\necho \"$@\" >> \"$dir/calls.log\"\n" + body + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "claude"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+	return dir
+}
+
+func readCalls(t *testing.T, dir string) []string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, "calls.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestClaudeArgs(t *testing.T) {
+	dir := fakeClaude(t, "echo done")
+	var out bytes.Buffer
+	if err := RunPrompt(Options{Stdout: &out}, BackendClaude, "auto", "fix the bug"); err != nil {
+		t.Fatalf("RunPrompt() = %v", err)
+	}
+	if err := RunPrompt(Options{Stdout: &out}, BackendClaude, "sonnet", "fix the bug"); err != nil {
+		t.Fatalf("RunPrompt() = %v", err)
+	}
+	want := []string{
+		"--print --dangerously-skip-permissions fix the bug",
+		"--print --dangerously-skip-permissions --model sonnet fix the bug",
+	}
+	if got := readCalls(t, dir); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("claude calls = %q, want %q", got, want)
+	}
+	if out.String() != "done\ndone\n" {
+		t.Errorf("output = %q", out.String())
+	}
+}
+
+func TestClaudeRetriesWhenOverloaded(t *testing.T) {
+	defer func(backoff time.Duration) { claudeRetryBackoff = backoff }(claudeRetryBackoff)
+	claudeRetryBackoff = time.Millisecond
+	t.Setenv("CLAUDE_MAX_RETRIES", "2")
+
+	// Overloaded on the first call only
+	dir := fakeClaude(t, `if [ ! -f "$dir/seen" ]; then : > "$dir/seen"; echo 'API Error: 529 {"type":"overloaded_error"}' >&2; exit 1; fi; echo done`)
+	if err := ClaudeWithOptions(Options{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}}, "auto", "prompt"); err != nil {
+		t.Fatalf("ClaudeWithOptions() = %v; want success on retry", err)
+	}
+	if calls := readCalls(t, dir); len(calls) != 2 {
+		t.Errorf("claude ran %d times, want 2", len(calls))
+	}
+
+	// Other failures are not retried
+	dir = fakeClaude(t, "echo 'Error: not logged in' >&2; exit 1")
+	if err := ClaudeWithOptions(Options{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}}, "auto", "prompt"); err == nil {
+		t.Fatal("ClaudeWithOptions() = nil, want the failure")
+	}
+	if calls := readCalls(t, dir); len(calls) != 1 {
+		t.Errorf("claude ran %d times, want 1", len(calls))
+	}
+}
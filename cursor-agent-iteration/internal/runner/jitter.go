@@ -0,0 +1,52 @@
+package runner
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Jitter randomizes the startup stagger of agent processes. Implementations
+// must be safe for concurrent use, since task runners start agents from
+// several goroutines.
+type Jitter interface {
+	// Jitter returns a duration in [0, max)
+	Jitter(max time.Duration) time.Duration
+}
+
+// lockedJitter is a seeded random source guarded by a mutex
+type lockedJitter struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// NewJitter returns a concurrency-safe jitter source; the same seed gives
+// the same sequence of delays
+func NewJitter(seed int64) Jitter {
+	return &lockedJitter{rnd: rand.New(rand.NewSource(seed))}
+}
+
+func (j *lockedJitter) Jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return time.Duration(j.rnd.Int63n(int64(max)))
+}
+
+// defaultJitter is shared by every run that doesn't set Options.Jitter
+var defaultJitter = NewJitter(time.Now().UnixNano())
+
+// staggerSpread is the random part of the startup stagger
+const staggerSpread = 150 * time.Millisecond
+
+// staggerDelay is the pause before starting cursor-agent on an attempt. It
+// grows on retries so racing processes drift apart.
+func staggerDelay(attempt int, j Jitter) time.Duration {
+	base := 50 * time.Millisecond
+	if attempt > 0 {
+		base = time.Duration(200+attempt*100) * time.Millisecond
+	}
+	return base + j.Jitter(staggerSpread)
+}
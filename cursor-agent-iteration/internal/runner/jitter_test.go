@@ -0,0 +1,68 @@
+package runner
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fixedJitter always returns the same share of the requested range
+type fixedJitter float64
+
+func (f fixedJitter) Jitter(max time.Duration) time.Duration {
+	return time.Duration(float64(max) * float64(f))
+}
+
+func TestStaggerDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempt  int
+		jitter   Jitter
+		expected time.Duration
+	}{
+		{"first attempt", 0, fixedJitter(0), 50 * time.Millisecond},
+		{"first attempt with jitter", 0, fixedJitter(0.5), 125 * time.Millisecond},
+		{"retry", 1, fixedJitter(0), 300 * time.Millisecond},
+		{"second retry", 2, fixedJitter(0.2), 430 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := staggerDelay(tt.attempt, tt.jitter); got != tt.expected {
+				t.Errorf("staggerDelay() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNewJitter(t *testing.T) {
+	a, b := NewJitter(42), NewJitter(42)
+	for i := 0; i < 10; i++ {
+		da, db := a.Jitter(staggerSpread), b.Jitter(staggerSpread)
+		if da != db {
+			t.Fatalf("Expected the same seed to give the same delays, got %v and %v", da, db)
+		}
+		if da < 0 || da >= staggerSpread {
+			t.Fatalf("Jitter() = %v, want [0, %v)", da, staggerSpread)
+		}
+	}
+	if d := a.Jitter(0); d != 0 {
+		t.Errorf("Jitter(0) = %v, want 0", d)
+	}
+
+	// Safe for concurrent use (run with -race)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				defaultJitter.Jitter(time.Second)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if (Options{}).jitter() != defaultJitter || (Options{Jitter: fixedJitter(0)}).jitter() != fixedJitter(0) {
+		t.Errorf("Expected Options.Jitter to override the default source")
+	}
+}
@@ -0,0 +1,112 @@
+package runner
+
+import (
+	"context"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/toolenv"
+)
+
+// Where the models of a probe come from
+const (
+	ModelsFromCLI     = "cli"      // the backend's CLI listed them
+	ModelsFromBuiltin = "built-in" // the CLI can't list them, or failed to
+)
+
+// builtinModels are the models each backend is known to take, for CLIs that
+// can't list them. "auto" lets cursor-agent pick.
+var builtinModels = map[Backend][]string{
+	BackendCursorAgent: {"auto", "gpt-5", "sonnet-4", "sonnet-4-thinking", "opus-4.1"},
+	BackendCodex:       {"gpt-5-codex", "gpt-5"},
+	BackendClaude:      {"sonnet", "opus", "haiku"},
+}
+
+// modelListArgs are the arguments that make a backend's CLI print its
+// models, for the CLIs that can
+var modelListArgs = map[Backend][]string{
+	BackendCursorAgent: {"models"},
+}
+
+// probeTimeout bounds each command of a probe, so a CLI waiting for a login
+// can't hang it
+const probeTimeout = 10 * time.Second
+
+// KnownBackends returns the backends RunPrompt can drive
+func KnownBackends() []Backend {
+	return append([]Backend(nil), knownBackends...)
+}
+
+// Probe is what could be found out about a backend without running a task
+type Probe struct {
+	Backend Backend
+	// Path is where its CLI is installed, empty when it isn't on the PATH
+	Path    string
+	Version string
+	Models  []string
+	// ModelsFrom says where Models come from, ModelsFromCLI or
+	// ModelsFromBuiltin
+	ModelsFrom string
+}
+
+// Installed reports whether the backend's CLI is on the PATH
+func (p Probe) Installed() bool {
+	return p.Path != ""
+}
+
+// ProbeBackend looks for a backend's CLI, asks it for its version and, when
+// it can list them, its models. Models fall back to the built-in list.
+func ProbeBackend(ctx context.Context, b Backend) Probe {
+	p := Probe{Backend: b, Models: builtinModels[b], ModelsFrom: ModelsFromBuiltin}
+	path, err := exec.LookPath(string(b))
+	if err != nil {
+		return p
+	}
+	p.Path = path
+	if out, err := probeOutput(ctx, path, "--version"); err == nil {
+		p.Version = toolenv.Version(out)
+	}
+	if args, ok := modelListArgs[b]; ok {
+		if out, err := probeOutput(ctx, path, args...); err == nil {
+			if models := ParseModelList(out); len(models) > 0 {
+				p.Models, p.ModelsFrom = models, ModelsFromCLI
+			}
+		}
+	}
+	return p
+}
+
+func probeOutput(ctx context.Context, path string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, args...).CombinedOutput()
+	return string(out), err
+}
+
+var (
+	reANSI    = regexp.MustCompile(`\x1b\[[0-9;]*[a-zA-Z]`)
+	reModelID = regexp.MustCompile(`^[a-z0-9][a-z0-9._/-]*$`)
+)
+
+// ParseModelList picks the model IDs out of a CLI's model listing: lines,
+// after any bullet, that start with an ID alone, followed by a column gap,
+// " - " and a description, or a note in parentheses. Headings such as
+// "Available models:" and prose are skipped.
+func ParseModelList(output string) []string {
+	var models []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(reANSI.ReplaceAllString(output, ""), "\n") {
+		line = strings.TrimLeft(strings.TrimSpace(line), "-*•> ")
+		id, rest, _ := strings.Cut(line, " ")
+		id = strings.TrimSuffix(id, ",")
+		listed := rest == "" || strings.HasPrefix(rest, " ") || strings.HasPrefix(rest, "- ") || strings.HasPrefix(rest, "(")
+		if !listed || !reModelID.MatchString(id) || seen[id] {
+			continue
+		}
+		seen[id] = true
+		models = append(models, id)
+	}
+	return models
+}
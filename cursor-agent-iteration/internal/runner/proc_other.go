@@ -0,0 +1,18 @@
+//go:build !unix
+
+package runner
+
+import "os/exec"
+
+// setProcessGroup is a no-op where process groups aren't available
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminateGroup kills cmd; there is no gentler signal to send here
+func terminateGroup(cmd *exec.Cmd) {
+	_ = cmd.Process.Kill()
+}
+
+// killGroup kills cmd
+func killGroup(cmd *exec.Cmd) {
+	_ = cmd.Process.Kill()
+}
@@ -2,8 +2,10 @@ package runner
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"math/rand"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
@@ -15,6 +17,80 @@ func timestamp() string {
 	return time.Now().Format("15:04:05")
 }
 
+// Options controls how an agent process is launched
+type Options struct {
+	Debug bool
+	// Stdout and Stderr receive the agent's output; they default to the
+	// process's own stdout/stderr when nil
+	Stdout io.Writer
+	Stderr io.Writer
+	// Env holds extra KEY=value variables for the agent process, on top of
+	// the process's own environment
+	Env []string
+	// Jitter randomizes the startup stagger; nil uses a shared, randomly
+	// seeded source
+	Jitter Jitter
+	// Timeout kills the agent, and every process it started, when it runs
+	// longer; 0 means no limit
+	Timeout time.Duration
+	// Context stops the agent, and every process it started, when it is
+	// done; nil lets the agent run until it exits
+	Context context.Context
+	// OnRetry is called before a backend is started again after a failed
+	// attempt, with the number of the attempt about to run
+	OnRetry func(backend Backend, attempt int, err error)
+	// Dir is the directory the agent runs in; "" is the current directory
+	Dir string
+}
+
+// retry reports an upcoming attempt to OnRetry, if set
+func (o Options) retry(backend Backend, attempt int, err error) {
+	if o.OnRetry != nil {
+		o.OnRetry(backend, attempt, err)
+	}
+}
+
+// interrupted returns ErrInterrupted once the context of the options is done
+func (o Options) interrupted() error {
+	if o.Context != nil && o.Context.Err() != nil {
+		return ErrInterrupted
+	}
+	return nil
+}
+
+func (o Options) jitter() Jitter {
+	if o.Jitter != nil {
+		return o.Jitter
+	}
+	return defaultJitter
+}
+
+// env returns the agent process's environment, or nil to inherit it
+func (o Options) env() []string {
+	if len(o.Env) == 0 {
+		return nil
+	}
+	return append(os.Environ(), o.Env...)
+}
+
+func (o Options) stdout() io.Writer {
+	if o.Stdout != nil {
+		return o.Stdout
+	}
+	return os.Stdout
+}
+
+func (o Options) stderr() io.Writer {
+	if o.Stderr != nil {
+		return o.Stderr
+	}
+	return os.Stderr
+}
+
+// ErrConfigRace wraps the errors OnRetry is given for cursor-agent startups
+// that raced another agent on cli-config.json
+var ErrConfigRace = errors.New("cli-config.json race")
+
 // isRaceConditionError checks if the error message indicates a race condition
 func isRaceConditionError(stderr string) bool {
 	return strings.Contains(stderr, "cli-config.json.tmp") ||
@@ -27,6 +103,13 @@ func isRaceConditionError(stderr string) bool {
 // Set CURSOR_AGENT_NO_STAGGER=1 to disable startup delay.
 // Set CURSOR_AGENT_MAX_RETRIES=N to change max retries (default: 3).
 func CursorAgentWithDebug(debug bool, args ...string) error {
+	return CursorAgentWithOptions(Options{Debug: debug}, args...)
+}
+
+// CursorAgentWithOptions runs cursor-agent like CursorAgentWithDebug, writing
+// the agent's output to the writers in opts
+func CursorAgentWithOptions(opts Options, args ...string) error {
+	debug := opts.Debug
 	// Check that cursor-agent exists
 	if _, err := exec.LookPath("cursor-agent"); err != nil {
 		return fmt.Errorf("cursor-agent not found: %w", err)
@@ -48,11 +131,14 @@ func CursorAgentWithDebug(debug bool, args ...string) error {
 	var stderrCapture bytes.Buffer
 
 	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if err := opts.interrupted(); err != nil {
+			return err
+		}
 		if attempt > 0 {
 			// Exponential backoff: 500ms, 1s, 2s
 			backoff := time.Duration(500*(1<<uint(attempt-1))) * time.Millisecond
 			if debug {
-				fmt.Printf("[%s] 🔄 Retry attempt %d/%d after %v (race condition detected)\n", 
+				fmt.Printf("[%s] 🔄 Retry attempt %d/%d after %v (race condition detected)\n",
 					timestamp(), attempt, maxRetries, backoff)
 			}
 			time.Sleep(backoff)
@@ -61,31 +147,28 @@ func CursorAgentWithDebug(debug bool, args ...string) error {
 		// Add a small random delay to stagger startups and avoid config file race conditions
 		// This prevents multiple cursor-agent processes from writing cli-config.json simultaneously
 		if os.Getenv("CURSOR_AGENT_NO_STAGGER") != "1" {
-			baseDelay := 50
-			if attempt > 0 {
-				// Increase base delay on retries
-				baseDelay = 200 + (attempt * 100)
-			}
-			staggerDelay := time.Duration(baseDelay+rand.Intn(150)) * time.Millisecond
+			delay := staggerDelay(attempt, opts.jitter())
 			if debug {
-				fmt.Printf("[%s] ⏱️  Startup stagger: %v (prevents config race condition)\n", timestamp(), staggerDelay)
+				fmt.Printf("[%s] ⏱️  Startup stagger: %v (prevents config race condition)\n", timestamp(), delay)
 			}
-			time.Sleep(staggerDelay)
+			time.Sleep(delay)
 		}
 
 		startTime := time.Now()
-		
+
 		// Capture stderr to detect race condition errors
 		stderrCapture.Reset()
 		cmd := exec.Command("cursor-agent", args...)
-		cmd.Stdout = os.Stdout
+		cmd.Env = opts.env()
+		cmd.Dir = opts.Dir
+		cmd.Stdout = opts.stdout()
 		cmd.Stderr = &stderrCapture
-		
-		err := cmd.Run()
-		
+
+		err := runCommand(opts.Context, cmd, opts.Timeout)
+
 		// Also print stderr to user
 		if stderrCapture.Len() > 0 {
-			fmt.Fprint(os.Stderr, stderrCapture.String())
+			fmt.Fprint(opts.stderr(), stderrCapture.String())
 		}
 
 		duration := time.Since(startTime)
@@ -93,10 +176,10 @@ func CursorAgentWithDebug(debug bool, args ...string) error {
 		if err == nil {
 			if debug {
 				if attempt > 0 {
-					fmt.Printf("[%s] ✅ cursor-agent succeeded on retry %d (duration: %v)\n", 
+					fmt.Printf("[%s] ✅ cursor-agent succeeded on retry %d (duration: %v)\n",
 						timestamp(), attempt, duration)
 				} else {
-					fmt.Printf("[%s] ✅ cursor-agent process completed successfully (duration: %v)\n", 
+					fmt.Printf("[%s] ✅ cursor-agent process completed successfully (duration: %v)\n",
 						timestamp(), duration)
 				}
 			}
@@ -107,9 +190,10 @@ func CursorAgentWithDebug(debug bool, args ...string) error {
 		stderrStr := stderrCapture.String()
 		if isRaceConditionError(stderrStr) && attempt < maxRetries {
 			if debug {
-				fmt.Printf("[%s] ⚠️  Race condition detected in attempt %d, will retry...\n", 
+				fmt.Printf("[%s] ⚠️  Race condition detected in attempt %d, will retry...\n",
 					timestamp(), attempt+1)
 			}
+			opts.retry(BackendCursorAgent, attempt+1, fmt.Errorf("%w: %v", ErrConfigRace, err))
 			lastErr = err
 			continue
 		}
@@ -130,6 +214,13 @@ func CursorAgentWithDebug(debug bool, args ...string) error {
 
 // CodexWithDebug runs codex with the specified model; when debug is enabled, streams stdout/stderr.
 func CodexWithDebug(debug bool, model string, args ...string) error {
+	return CodexWithOptions(Options{Debug: debug}, model, args...)
+}
+
+// CodexWithOptions runs codex like CodexWithDebug, writing the agent's output
+// to the writers in opts
+func CodexWithOptions(opts Options, model string, args ...string) error {
+	debug := opts.Debug
 	if _, err := exec.LookPath("codex"); err != nil {
 		return fmt.Errorf("codex CLI not found: %w", err)
 	}
@@ -145,9 +236,11 @@ func CodexWithDebug(debug bool, model string, args ...string) error {
 
 	startTime := time.Now()
 	cmd := exec.Command("codex", cmdArgs...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err := cmd.Run()
+	cmd.Env = opts.env()
+	cmd.Dir = opts.Dir
+	cmd.Stdout = opts.stdout()
+	cmd.Stderr = opts.stderr()
+	err := runCommand(opts.Context, cmd, opts.Timeout)
 
 	if debug {
 		duration := time.Since(startTime)
@@ -161,12 +254,106 @@ func CodexWithDebug(debug bool, model string, args ...string) error {
 	return err
 }
 
+// ClaudeWithDebug runs the claude CLI (Claude Code) with the specified model;
+// when debug is enabled, logs the process start and end.
+func ClaudeWithDebug(debug bool, model string, args ...string) error {
+	return ClaudeWithOptions(Options{Debug: debug}, model, args...)
+}
+
+// claudeRetryBackoff is the wait before the first retry of an overloaded
+// claude run; it doubles with every retry
+var claudeRetryBackoff = 5 * time.Second
+
+// isOverloadedError reports whether claude's output says the API was
+// overloaded, which is worth retrying
+func isOverloadedError(output string) bool {
+	return strings.Contains(output, "overloaded_error") || strings.Contains(output, "API Error: 529")
+}
+
+// ClaudeWithOptions runs claude in non-interactive print mode, writing the
+// agent's output to the writers in opts. Like cursor-agent --force, the
+// agent may edit files and run commands without asking. A model of "auto"
+// leaves the choice to claude. Runs that fail because the API is overloaded
+// are retried with exponential backoff.
+// Set CLAUDE_MAX_RETRIES=N to change max retries (default: 3).
+func ClaudeWithOptions(opts Options, model string, args ...string) error {
+	debug := opts.Debug
+	if _, err := exec.LookPath("claude"); err != nil {
+		return fmt.Errorf("claude CLI not found: %w", err)
+	}
+	if debug {
+		// Set DEBUG env to propagate verbosity
+		_ = os.Setenv("DEBUG", "1")
+		fmt.Printf("[%s] 🤖 Starting claude process (model: %s)...\n", timestamp(), model)
+	}
+
+	cmdArgs := []string{"--print", "--dangerously-skip-permissions"}
+	if model != "" && model != "auto" {
+		cmdArgs = append(cmdArgs, "--model", model)
+	}
+	cmdArgs = append(cmdArgs, args...)
+
+	maxRetries := 3
+	if envRetries := os.Getenv("CLAUDE_MAX_RETRIES"); envRetries != "" {
+		fmt.Sscanf(envRetries, "%d", &maxRetries)
+	}
+
+	var err error
+	var outputCapture bytes.Buffer
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if err := opts.interrupted(); err != nil {
+			return err
+		}
+		if attempt > 0 {
+			backoff := claudeRetryBackoff << uint(attempt-1)
+			fmt.Printf("[%s] 🔄 claude API overloaded, retry %d/%d after %v\n", timestamp(), attempt, maxRetries, backoff)
+			time.Sleep(backoff)
+		}
+
+		startTime := time.Now()
+		outputCapture.Reset()
+		cmd := exec.Command("claude", cmdArgs...)
+		cmd.Env = opts.env()
+		cmd.Dir = opts.Dir
+		cmd.Stdout = io.MultiWriter(opts.stdout(), &outputCapture)
+		cmd.Stderr = io.MultiWriter(opts.stderr(), &outputCapture)
+		err = runCommand(opts.Context, cmd, opts.Timeout)
+
+		if debug {
+			duration := time.Since(startTime)
+			if err != nil {
+				fmt.Printf("[%s] ❌ claude process failed after %v: %v\n", timestamp(), duration, err)
+			} else {
+				fmt.Printf("[%s] ✅ claude process completed successfully (duration: %v)\n", timestamp(), duration)
+			}
+		}
+		if err == nil || !isOverloadedError(outputCapture.String()) {
+			return err
+		}
+	}
+	return fmt.Errorf("claude failed after %d retries: %w", maxRetries, err)
+}
+
 // AgentRunner runs either cursor-agent or codex based on the useCodex flag
 func AgentRunnerWithDebug(debug bool, useCodex bool, model string, args ...string) error {
 	if useCodex {
+		return RunBackendWithDebug(debug, BackendCodex, model, args...)
+	}
+	return RunBackendWithDebug(debug, BackendCursorAgent, model, args...)
+}
+
+// RunBackendWithDebug runs the CLI of any backend with raw arguments
+func RunBackendWithDebug(debug bool, backend Backend, model string, args ...string) error {
+	switch backend {
+	case BackendCodex:
 		return CodexWithDebug(debug, model, args...)
+	case BackendClaude:
+		return ClaudeWithDebug(debug, model, args...)
+	case BackendCursorAgent:
+		return CursorAgentWithDebug(debug, args...)
+	default:
+		return fmt.Errorf("unknown agent backend %q", backend)
 	}
-	return CursorAgentWithDebug(debug, args...)
 }
 
 // CursorAgentWithOutput runs cursor-agent and captures output
@@ -311,3 +311,13 @@ func contains(s, substr string) bool {
 		return false
 	})()
 }
+
+func TestOptionsEnv(t *testing.T) {
+	if env := (Options{}).env(); env != nil {
+		t.Errorf("Expected no Env to inherit the environment, got %d variables", len(env))
+	}
+	env := Options{Env: []string{"GIT_AUTHOR_NAME=Bot"}}.env()
+	if len(env) != len(os.Environ())+1 || env[len(env)-1] != "GIT_AUTHOR_NAME=Bot" {
+		t.Errorf("Expected the extra variable after the environment, got %v", env[len(env)-1])
+	}
+}
@@ -0,0 +1,89 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// ErrTimeout is returned when an agent is killed for running longer than
+// Options.Timeout
+var ErrTimeout = errors.New("agent timed out")
+
+// ErrInterrupted is returned when an agent is stopped because
+// Options.Context was canceled
+var ErrInterrupted = errors.New("agent interrupted")
+
+// killGrace is how long a stopped agent gets to exit after SIGTERM before
+// its process group is killed
+var killGrace = 10 * time.Second
+
+// pipeGrace is how long Wait waits for the output pipes to close once the
+// agent has exited. A grandchild that left the agent's process group, such
+// as a daemon it started, escapes the kill and may hold them open forever.
+var pipeGrace = 5 * time.Second
+
+// runCommand runs cmd, stopping it and the processes it started once it runs
+// longer than timeout or ctx is done. A timeout of 0 means no limit and a
+// nil ctx is never done.
+func runCommand(ctx context.Context, cmd *exec.Cmd, timeout time.Duration) error {
+	if timeout <= 0 && ctx == nil {
+		return cmd.Run()
+	}
+	// The agent's own children (language servers, test runners...) would
+	// otherwise survive it and keep its output pipes open
+	setProcessGroup(cmd)
+	if cmd.WaitDelay == 0 {
+		cmd.WaitDelay = pipeGrace
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		if errors.Is(err, exec.ErrWaitDelay) {
+			// The agent itself exited cleanly; only the output of the
+			// processes it left behind is lost
+			err = nil
+		}
+		done <- err
+	}()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	var canceled <-chan struct{}
+	if ctx != nil {
+		canceled = ctx.Done()
+	}
+
+	name := filepath.Base(cmd.Path)
+	var stopErr error
+	select {
+	case err := <-done:
+		return err
+	case <-expired:
+		fmt.Printf("[%s] ⏱️ %s ran longer than %v, stopping it\n", timestamp(), name, timeout)
+		stopErr = fmt.Errorf("%w after %v", ErrTimeout, timeout)
+	case <-canceled:
+		fmt.Printf("[%s] 🛑 Stopping %s\n", timestamp(), name)
+		stopErr = ErrInterrupted
+	}
+
+	terminateGroup(cmd)
+	select {
+	case <-done:
+	case <-time.After(killGrace):
+		fmt.Printf("[%s] 💀 %s ignored SIGTERM for %v, killing it\n", timestamp(), name, killGrace)
+		killGroup(cmd)
+		<-done
+	}
+	return stopErr
+}
@@ -0,0 +1,99 @@
+//go:build unix
+
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestRunCommandTimeout(t *testing.T) {
+	defer func(grace time.Duration) { killGrace = grace }(killGrace)
+	killGrace = 200 * time.Millisecond
+
+	tests := []struct {
+		name    string
+		script  string
+		timeout time.Duration
+		wantErr error
+	}{
+		{"finishes in time", "echo done", time.Second, nil},
+		{"no limit", "sleep 0.1", 0, nil},
+		// The background sleep holds the output pipe open: Wait only
+		// returns if the whole process group is stopped
+		{"hung with children", "sleep 30 & sleep 30", 100 * time.Millisecond, ErrTimeout},
+		{"ignores SIGTERM", "trap '' TERM; sleep 30", 100 * time.Millisecond, ErrTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			cmd := exec.Command("sh", "-c", tt.script)
+			cmd.Stdout = &out
+			start := time.Now()
+			err := runCommand(nil, cmd, tt.timeout)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("runCommand() = %v, want %v", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("runCommand() took %v; the agent wasn't killed", elapsed)
+			}
+		})
+	}
+}
+
+func TestRunCommandEscapedChild(t *testing.T) {
+	if _, err := exec.LookPath("setsid"); err != nil {
+		t.Skip("setsid not available")
+	}
+	defer func(grace, pipes time.Duration) { killGrace, pipeGrace = grace, pipes }(killGrace, pipeGrace)
+	killGrace, pipeGrace = time.Second, 100*time.Millisecond
+
+	// setsid moves the child out of the agent's process group, so it
+	// survives the kill and holds the output pipe open
+	tests := []struct {
+		script  string
+		timeout time.Duration
+		wantErr error
+	}{
+		{"setsid sleep 3 & echo done", time.Second, nil},
+		{"setsid sleep 3 & sleep 30", 100 * time.Millisecond, ErrTimeout},
+	}
+	for _, tt := range tests {
+		cmd := exec.Command("sh", "-c", tt.script)
+		cmd.Stdout = &bytes.Buffer{}
+		start := time.Now()
+		if err := runCommand(nil, cmd, tt.timeout); !errors.Is(err, tt.wantErr) {
+			t.Errorf("runCommand(%q) = %v, want %v", tt.script, err, tt.wantErr)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("runCommand(%q) took %v; Wait didn't give up on the pipes", tt.script, elapsed)
+		}
+	}
+}
+
+func TestRunCommandInterrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	cmd := exec.Command("sh", "-c", "sleep 30 & sleep 30")
+	cmd.Stdout = &bytes.Buffer{}
+	start := time.Now()
+	if err := runCommand(ctx, cmd, time.Hour); !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("runCommand() = %v, want ErrInterrupted", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("runCommand() took %v; the agent wasn't stopped", elapsed)
+	}
+
+	// Once interrupted, a chain starts no further attempt or backend
+	opts := Options{Context: ctx}
+	if err := opts.interrupted(); !errors.Is(err, ErrInterrupted) {
+		t.Errorf("interrupted() = %v, want ErrInterrupted", err)
+	}
+	if _, err := RunChain(opts, []Backend{BackendCursorAgent, BackendCodex}, 3, "auto", "prompt"); !errors.Is(err, ErrInterrupted) {
+		t.Errorf("RunChain() = %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// OpenAPIVersion is the OpenAPI dialect of generated API documents; 3.1 uses
+// the same JSON Schema draft as the file schemas
+const OpenAPIVersion = "3.1.0"
+
+// Operation describes one endpoint of an HTTP API
+type Operation struct {
+	// ID names the operation in generated clients, e.g. listTasks
+	ID      string
+	Method  string
+	Path    string // e.g. /tasks/{title}; path parameters are strings
+	Summary string
+	// Request is a value of the Go type of the JSON request body, or nil
+	Request any
+	// Response is a value of the Go type of the JSON response body, or nil
+	// for an empty response
+	Response any
+	// ResponseType is the media type of the response, application/json by
+	// default
+	ResponseType string
+}
+
+// API describes an HTTP API
+type API struct {
+	Title       string
+	Version     string
+	Description string
+	Operations  []Operation
+	// Error is a value of the Go type of error responses, or nil
+	Error any
+}
+
+var rePathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// OpenAPI renders the API as an indented OpenAPI document. Named struct
+// types of request and response bodies become shared components.
+func (a API) OpenAPI() ([]byte, error) {
+	c := componentSet{schemas: map[string]any{}, types: map[string]reflect.Type{}}
+	paths := map[string]map[string]any{}
+	var errorRef map[string]any
+	if a.Error != nil {
+		ref, err := c.ref(reflect.TypeOf(a.Error))
+		if err != nil {
+			return nil, fmt.Errorf("error type: %v", err)
+		}
+		errorRef = ref
+	}
+	for _, op := range a.Operations {
+		method := strings.ToLower(op.Method)
+		if paths[op.Path] == nil {
+			paths[op.Path] = map[string]any{}
+		}
+		if _, dup := paths[op.Path][method]; dup {
+			return nil, fmt.Errorf("duplicate operation %s %s", op.Method, op.Path)
+		}
+		doc := map[string]any{"operationId": op.ID}
+		if op.Summary != "" {
+			doc["summary"] = op.Summary
+		}
+		var params []any
+		for _, m := range rePathParam.FindAllStringSubmatch(op.Path, -1) {
+			params = append(params, map[string]any{
+				"name": m[1], "in": "path", "required": true, "schema": map[string]any{"type": "string"},
+			})
+		}
+		if len(params) > 0 {
+			doc["parameters"] = params
+		}
+		if op.Request != nil {
+			ref, err := c.ref(reflect.TypeOf(op.Request))
+			if err != nil {
+				return nil, fmt.Errorf("%s request: %v", op.ID, err)
+			}
+			doc["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{"application/json": map[string]any{"schema": ref}},
+			}
+		}
+		responses := map[string]any{}
+		if op.Response == nil {
+			responses["204"] = map[string]any{"description": "No content"}
+		} else {
+			mediaType := op.ResponseType
+			if mediaType == "" {
+				mediaType = "application/json"
+			}
+			ref, err := c.ref(reflect.TypeOf(op.Response))
+			if err != nil {
+				return nil, fmt.Errorf("%s response: %v", op.ID, err)
+			}
+			responses["200"] = map[string]any{
+				"description": "OK",
+				"content":     map[string]any{mediaType: map[string]any{"schema": ref}},
+			}
+		}
+		if errorRef != nil {
+			responses["default"] = map[string]any{
+				"description": "Error",
+				"content":     map[string]any{"application/json": map[string]any{"schema": errorRef}},
+			}
+		}
+		doc["responses"] = responses
+		paths[op.Path][method] = doc
+	}
+
+	info := map[string]any{"title": a.Title, "version": a.Version}
+	if a.Description != "" {
+		info["description"] = a.Description
+	}
+	doc := map[string]any{
+		"openapi": OpenAPIVersion,
+		"info":    info,
+		"paths":   paths,
+	}
+	if len(c.schemas) > 0 {
+		doc["components"] = map[string]any{"schemas": c.schemas}
+	}
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// componentSet collects the shared schemas of an API document
+type componentSet struct {
+	schemas map[string]any
+	types   map[string]reflect.Type
+}
+
+// ref returns the schema of a body type, adding named structs to the
+// components and referring to them by name
+func (c componentSet) ref(t reflect.Type) (map[string]any, error) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8:
+		items, err := c.ref(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "array", "items": items}, nil
+	case t.Kind() == reflect.Struct && t.Name() != "" && t != timeType:
+		name := t.Name()
+		if other, done := c.types[name]; done && other != t {
+			return nil, fmt.Errorf("types %s and %s are both named %s", other, t, name)
+		} else if !done {
+			s, err := typeSchema(t, nil)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+			c.schemas[name] = s
+			c.types[name] = t
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}, nil
+	}
+	return typeSchema(t, nil)
+}
@@ -0,0 +1,87 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type apiTask struct {
+	Title  string `json:"title"`
+	Status string `json:"status"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func TestOpenAPI(t *testing.T) {
+	api := API{
+		Title:   "sample",
+		Version: "1",
+		Error:   apiError{},
+		Operations: []Operation{
+			{ID: "listTasks", Method: "GET", Path: "/tasks", Summary: "List tasks", Response: []apiTask{}},
+			{ID: "getTask", Method: "GET", Path: "/tasks/{title}", Response: &apiTask{}},
+			{ID: "pause", Method: "POST", Path: "/pause"},
+			{ID: "logs", Method: "GET", Path: "/logs", Response: "", ResponseType: "text/event-stream"},
+		},
+	}
+	data, err := api.OpenAPI()
+	if err != nil {
+		t.Fatalf("OpenAPI() = %v", err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if doc["openapi"] != OpenAPIVersion || !reflect.DeepEqual(doc["info"], map[string]any{"title": "sample", "version": "1"}) {
+		t.Errorf("Unexpected header: %v %v", doc["openapi"], doc["info"])
+	}
+
+	schemas := doc["components"].(map[string]any)["schemas"].(map[string]any)
+	if len(schemas) != 2 || schemas["apiTask"] == nil || schemas["apiError"] == nil {
+		t.Errorf("components = %v", schemas)
+	}
+	paths := doc["paths"].(map[string]any)
+	list := paths["/tasks"].(map[string]any)["get"].(map[string]any)
+	listSchema := list["responses"].(map[string]any)["200"].(map[string]any)["content"].(map[string]any)["application/json"].(map[string]any)["schema"]
+	if !reflect.DeepEqual(listSchema, map[string]any{"type": "array", "items": map[string]any{"$ref": "#/components/schemas/apiTask"}}) {
+		t.Errorf("listTasks schema = %v", listSchema)
+	}
+	get := paths["/tasks/{title}"].(map[string]any)["get"].(map[string]any)
+	if params := get["parameters"].([]any); len(params) != 1 || params[0].(map[string]any)["name"] != "title" {
+		t.Errorf("getTask parameters = %v", get["parameters"])
+	}
+	pause := paths["/pause"].(map[string]any)["post"].(map[string]any)["responses"].(map[string]any)
+	if pause["204"] == nil || pause["default"] == nil {
+		t.Errorf("pause responses = %v", pause)
+	}
+	if !strings.Contains(string(data), `"text/event-stream"`) {
+		t.Error("Expected the logs media type")
+	}
+}
+
+func TestOpenAPIErrors(t *testing.T) {
+	outer := apiTask{}
+	// A different type with the same name
+	type apiTask struct {
+		ID int `json:"id"`
+	}
+	tests := []struct {
+		name string
+		ops  []Operation
+		err  string
+	}{
+		{"duplicate", []Operation{{ID: "a", Method: "GET", Path: "/x"}, {ID: "b", Method: "get", Path: "/x"}}, "duplicate operation"},
+		{"name clash", []Operation{{ID: "a", Method: "GET", Path: "/a", Response: outer}, {ID: "b", Method: "POST", Path: "/b", Request: apiTask{}}}, "both named apiTask"},
+		{"unsupported", []Operation{{ID: "a", Method: "GET", Path: "/a", Response: make(chan int)}}, "a response: unsupported type"},
+	}
+	for _, tt := range tests {
+		_, err := API{Operations: tt.ops}.OpenAPI()
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: OpenAPI() error = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
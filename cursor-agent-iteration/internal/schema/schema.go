@@ -0,0 +1,154 @@
+// Package schema derives JSON Schemas from the Go types behind cursor-iter's
+// machine-readable files, so editors and other tools can validate them
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Draft is the JSON Schema dialect of the generated schemas
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// BaseID prefixes the $id of every schema
+const BaseID = "https://github.com/cheddarwhizzy/cursor-autopilot/schemas/"
+
+// Spec describes one published file format
+type Spec struct {
+	Name        string
+	Description string
+	// Type is a value of the Go type the file is decoded into
+	Type any
+	// Enums lists the allowed values of fields, keyed by JSON field name
+	Enums map[string][]string
+}
+
+var registry = map[string]Spec{}
+
+// Register publishes a file format. Packages register their formats in init.
+func Register(s Spec) {
+	if _, dup := registry[s.Name]; dup {
+		panic("schema: duplicate registration of " + s.Name)
+	}
+	registry[s.Name] = s
+}
+
+// Names returns the registered schema names in order
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns a registered schema by name
+func Lookup(name string) (Spec, bool) {
+	s, ok := registry[name]
+	return s, ok
+}
+
+// FileName is the file a schema is published as
+func FileName(name string) string {
+	return name + ".schema.json"
+}
+
+// JSON renders the spec as an indented JSON Schema document
+func (s Spec) JSON() ([]byte, error) {
+	doc, err := typeSchema(reflect.TypeOf(s.Type), s.Enums)
+	if err != nil {
+		return nil, fmt.Errorf("schema %s: %v", s.Name, err)
+	}
+	doc["$schema"] = Draft
+	doc["$id"] = BaseID + FileName(s.Name)
+	doc["title"] = s.Name
+	if s.Description != "" {
+		doc["description"] = s.Description
+	}
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func typeSchema(t reflect.Type, enums map[string][]string) (map[string]any, error) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}, nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}, nil
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := typeSchema(t.Elem(), nil)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "array", "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("map key %s is not a string", t.Key())
+		}
+		values, err := typeSchema(t.Elem(), nil)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "object", "additionalProperties": values}, nil
+	case reflect.Interface:
+		return map[string]any{}, nil
+	case reflect.Struct:
+		return structSchema(t, enums)
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+func structSchema(t reflect.Type, enums map[string][]string) (map[string]any, error) {
+	props := map[string]any{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		prop, err := typeSchema(f.Type, nil)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", f.Name, err)
+		}
+		if values, ok := enums[name]; ok {
+			prop["enum"] = values
+		}
+		props[name] = prop
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	doc := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		doc["required"] = required
+	}
+	return doc, nil
+}
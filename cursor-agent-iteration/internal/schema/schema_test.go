@@ -0,0 +1,58 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type sample struct {
+	Time   time.Time         `json:"time"`
+	Kind   string            `json:"kind"`
+	Count  int64             `json:"count,omitempty"`
+	Tags   []string          `json:"tags,omitempty"`
+	Extra  map[string]string `json:"extra,omitempty"`
+	Nested struct {
+		OK bool `json:"ok"`
+	} `json:"nested"`
+	Ignored string `json:"-"`
+	hidden  string
+}
+
+func TestSpecJSON(t *testing.T) {
+	spec := Spec{Name: "sample", Description: "A sample", Type: sample{}, Enums: map[string][]string{"kind": {"a", "b"}}}
+	data, err := spec.JSON()
+	if err != nil {
+		t.Fatalf("JSON() = %v", err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if doc["$schema"] != Draft || doc["$id"] != BaseID+"sample.schema.json" || doc["title"] != "sample" || doc["description"] != "A sample" {
+		t.Errorf("Unexpected header: %v", doc)
+	}
+	if !reflect.DeepEqual(doc["required"], []any{"time", "kind", "nested"}) {
+		t.Errorf("required = %v", doc["required"])
+	}
+
+	props := doc["properties"].(map[string]any)
+	expected := map[string]any{
+		"time":   map[string]any{"type": "string", "format": "date-time"},
+		"kind":   map[string]any{"type": "string", "enum": []any{"a", "b"}},
+		"count":  map[string]any{"type": "integer"},
+		"tags":   map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		"extra":  map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}},
+		"nested": map[string]any{"type": "object", "properties": map[string]any{"ok": map[string]any{"type": "boolean"}}, "required": []any{"ok"}},
+	}
+	if !reflect.DeepEqual(props, expected) {
+		t.Errorf("properties = %v\nwant %v", props, expected)
+	}
+}
+
+func TestUnsupportedType(t *testing.T) {
+	if _, err := (Spec{Name: "bad", Type: struct{ C chan int }{}}).JSON(); err == nil {
+		t.Errorf("Expected an error for channel fields")
+	}
+}
@@ -0,0 +1,186 @@
+// Package scope checks that an agent run only changed the files its task
+// declares on the "**Files to Modify:**" line, and reverts the changes it
+// made elsewhere
+package scope
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+// DefaultAllowed are globs any task may change besides its declared files:
+// tests and docs
+var DefaultAllowed = []string{"*_test.go", "*.test.*", "*.spec.*", "**/test/**", "**/tests/**", "docs/**", "*.md"}
+
+// Scope is the set of files a task run may change
+type Scope struct {
+	Declared []string // paths, directories (ending in /) and globs from the task
+	Allowed  []string // globs allowed for every task
+}
+
+// Contains reports whether file, a slash-separated path relative to the
+// repository root, is in scope
+func (s Scope) Contains(file string) bool {
+	for _, p := range s.Declared {
+		if Match(p, file) {
+			return true
+		}
+	}
+	for _, p := range s.Allowed {
+		if Match(p, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// OutOfScope returns the files that are not in scope
+func (s Scope) OutOfScope(files []string) []string {
+	var out []string
+	for _, f := range files {
+		if !s.Contains(f) {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// Match reports whether file matches pattern. A pattern without wildcards
+// matches the file itself and everything below it as a directory. "**"
+// matches any number of directories, and a pattern without a slash is also
+// matched against the base name, so "*_test.go" matches tests anywhere.
+func Match(pattern, file string) bool {
+	pattern = strings.TrimPrefix(strings.TrimSpace(pattern), "./")
+	file = strings.TrimPrefix(file, "./")
+	if pattern == "" {
+		return false
+	}
+	if !strings.ContainsAny(pattern, "*?[") {
+		dir := strings.TrimSuffix(pattern, "/")
+		return file == dir || strings.HasPrefix(file, dir+"/")
+	}
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(file))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(file, "/"))
+}
+
+// Overlaps reports whether two entries of a file list can cover a common
+// file, e.g. "src/payments/..." and "src/payments/api.go". Globs are
+// compared by the directory before their first wildcard, and a glob without
+// a directory, such as "*.go", by matching it against the other entry.
+func Overlaps(a, b string) bool {
+	a, b = normalizeEntry(a), normalizeEntry(b)
+	if a == "" || b == "" {
+		return false
+	}
+	da, db := fixedDir(a), fixedDir(b)
+	if da == "" || db == "" {
+		return Match(a, b) || Match(b, a)
+	}
+	return Match(da, db) || Match(db, da)
+}
+
+// normalizeEntry trims an entry and turns "dir/..." into "dir/"
+func normalizeEntry(entry string) string {
+	entry = strings.TrimPrefix(strings.TrimSpace(entry), "./")
+	return strings.TrimSuffix(entry, "...")
+}
+
+// fixedDir is the part of an entry before its first wildcard segment,
+// without a trailing slash
+func fixedDir(entry string) string {
+	var fixed []string
+	for _, seg := range strings.Split(strings.TrimSuffix(entry, "/"), "/") {
+		if strings.ContainsAny(seg, "*?[") {
+			break
+		}
+		fixed = append(fixed, seg)
+	}
+	return strings.Join(fixed, "/")
+}
+
+func matchSegments(pattern, file []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(file); i++ {
+				if matchSegments(pattern[1:], file[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(file) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], file[0]); !ok {
+			return false
+		}
+		pattern, file = pattern[1:], file[1:]
+	}
+	return len(file) == 0
+}
+
+// ChangedFiles lists the files changed between two commits at root
+func ChangedFiles(root, from, to string) ([]string, error) {
+	out, err := git(root, nil, "diff", "--name-only", "--no-renames", "-z", from, to)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, f := range strings.Split(out, "\x00") {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+// Revert undoes the changes commits made to files, hunk by hunk, and
+// commits the result with message. Changes made to the files by other
+// commits are kept. It fails without changing anything when the files have
+// uncommitted changes that conflict.
+func Revert(root string, commits, files []string, message string) error {
+	if len(commits) == 0 || len(files) == 0 {
+		return nil
+	}
+	// The newest commit is undone first; git apply takes the patches in
+	// order, all or none
+	var patch strings.Builder
+	for i := len(commits) - 1; i >= 0; i-- {
+		diff, err := git(root, nil, append([]string{"diff", "--binary", "--no-renames", commits[i] + "^", commits[i], "--"}, files...)...)
+		if err != nil {
+			return err
+		}
+		patch.WriteString(diff)
+	}
+	if patch.Len() == 0 {
+		return nil
+	}
+	if _, err := git(root, strings.NewReader(patch.String()), "apply", "-R", "--index"); err != nil {
+		return fmt.Errorf("could not revert: %v", err)
+	}
+	if _, err := git(root, nil, append([]string{"commit", "--quiet", "-m", message, "--"}, files...)...); err != nil {
+		return fmt.Errorf("could not commit the revert: %v", err)
+	}
+	return nil
+}
+
+func git(root string, stdin io.Reader, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = root
+	cmd.Stdin = stdin
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil && stderr.Len() > 0 {
+		err = fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), err
+}
@@ -0,0 +1,143 @@
+package scope
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		file    string
+		want    bool
+	}{
+		{"src/api.go", "src/api.go", true},
+		{"./src/api.go", "src/api.go", true},
+		{"src/api.go", "src/api.go.bak", false},
+		{"src/auth/", "src/auth/token.go", true},
+		{"src/auth", "src/auth/token.go", true},
+		{"src/auth", "src/authz.go", false},
+		{"*_test.go", "internal/api/api_test.go", true},
+		{"*.md", "README.md", true},
+		{"src/*.go", "src/api.go", true},
+		{"src/*.go", "src/sub/api.go", false},
+		{"**/tests/**", "tests/unit/a.py", true},
+		{"**/tests/**", "pkg/tests/a.py", true},
+		{"docs/**", "docs/guide/intro.md", true},
+		{"docs/**", "src/docs.go", false},
+		{"", "src/api.go", false},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.file); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestOverlaps(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"src/payments/...", "src/payments/api.go", true},
+		{"src/payments/", "src/", true},
+		{"src/payments", "src/paymentsx/api.go", false},
+		{"src/payments/...", "src/auth/token.go", false},
+		{"src/payments/*.go", "src/payments/", true},
+		{"src/**/refund.go", "src/payments/refund.go", true},
+		{"*.sql", "db/payments.sql", true},
+		{"*.sql", "src/payments/", false},
+		{"", "src/", false},
+	}
+	for _, tt := range tests {
+		if got := Overlaps(tt.a, tt.b); got != tt.want {
+			t.Errorf("Overlaps(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := Overlaps(tt.b, tt.a); got != tt.want {
+			t.Errorf("Overlaps(%q, %q) = %v, want %v", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestOutOfScope(t *testing.T) {
+	s := Scope{Declared: []string{"src/api.go", "src/auth/"}, Allowed: DefaultAllowed}
+	files := []string{"src/api.go", "src/api_test.go", "src/auth/token.go", "docs/api.md", "src/db.go", "go.mod"}
+	if got, want := s.OutOfScope(files), []string{"src/db.go", "go.mod"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("OutOfScope() = %v, want %v", got, want)
+	}
+}
+
+func TestRevert(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found in PATH, skipping test")
+	}
+	for _, v := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(v, "test")
+	}
+	for _, v := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(v, "test@example.com")
+	}
+	root := t.TempDir()
+	run := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = root
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	write := func(name, content string) {
+		os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0755)
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run("init", "-q")
+	write("api.go", "package api\n")
+	write("db.go", "package db\n\nfunc Open() {}\n\n\n\n\n\nfunc Close() {}\n")
+	run("add", ".")
+	run("commit", "-qm", "init")
+	base := run("rev-parse", "HEAD")
+
+	// The run changes its own file, edits db.go and adds cache.go
+	write("api.go", "package api\n\nfunc Serve() {}\n")
+	write("db.go", "package db\n\nfunc Open() { println() }\n\n\n\n\n\nfunc Close() {}\n")
+	write("cache.go", "package cache\n")
+	run("add", ".")
+	run("commit", "-qm", "task work")
+	work := run("rev-parse", "HEAD")
+	// Another task changes db.go meanwhile
+	write("db.go", "package db\n\nfunc Open() { println() }\n\n\n\n\n\nfunc Close() { println() }\n")
+	run("commit", "-qam", "other task")
+	head := run("rev-parse", "HEAD")
+
+	files, err := ChangedFiles(root, base, work)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := Scope{Declared: []string{"api.go"}}.OutOfScope(files)
+	if !reflect.DeepEqual(out, []string{"cache.go", "db.go"}) {
+		t.Fatalf("OutOfScope() = %v", out)
+	}
+	if err := Revert(root, []string{work}, out, "revert: out-of-scope changes"); err != nil {
+		t.Fatalf("Revert() = %v", err)
+	}
+
+	if got := run("diff", "--name-only", base, "HEAD"); got != "api.go\ndb.go" {
+		t.Errorf("Expected api.go and db.go to differ from the base after the revert, got %q", got)
+	}
+	if got := run("diff", head, "HEAD", "--", "db.go"); !strings.Contains(got, "-func Open() { println() }") || strings.Contains(got, "Close") {
+		t.Errorf("Expected only the run's change to db.go reverted, got\n%s", got)
+	}
+	if got := run("status", "--porcelain"); got != "" {
+		t.Errorf("Expected a clean tree, got %q", got)
+	}
+	if got := run("log", "-1", "--format=%s"); got != "revert: out-of-scope changes" {
+		t.Errorf("Revert commit = %q", got)
+	}
+}
@@ -0,0 +1,109 @@
+// Package seal encrypts control files at rest with AES-256-GCM. A sealed
+// file is a text file: a header line followed by the base64 of the nonce
+// and ciphertext, so it survives tools that expect text and is recognized
+// without the key.
+package seal
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Header starts every sealed file
+const Header = "cursor-iter-sealed v1\n"
+
+// KeySize is the size of a key in bytes
+const KeySize = 32
+
+// lineWidth wraps the base64 body
+const lineWidth = 76
+
+// ErrWrongKey is returned when a file was sealed with another key or was
+// modified after sealing
+var ErrWrongKey = errors.New("wrong key or corrupted file")
+
+// IsSealed reports whether data is a sealed file
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(Header))
+}
+
+// GenerateKey returns a new random key, base64 encoded
+func GenerateKey() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// ParseKey decodes a base64 key, as printed by GenerateKey
+func ParseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		if key, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "=")); err != nil {
+			return nil, fmt.Errorf("key is not base64")
+		}
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key is %d bytes, want %d", len(key), KeySize)
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal encrypts plaintext with key
+func Seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	// The header is authenticated so it can't be swapped for a later version
+	body := base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, plaintext, []byte(Header)))
+	var b bytes.Buffer
+	b.WriteString(Header)
+	for len(body) > lineWidth {
+		b.WriteString(body[:lineWidth] + "\n")
+		body = body[lineWidth:]
+	}
+	b.WriteString(body + "\n")
+	return b.Bytes(), nil
+}
+
+// Open decrypts a sealed file with key
+func Open(key, data []byte) ([]byte, error) {
+	if !IsSealed(data) {
+		return nil, fmt.Errorf("not a sealed file")
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	body := strings.Join(strings.Fields(string(data[len(Header):])), "")
+	raw, err := base64.StdEncoding.DecodeString(body)
+	if err != nil || len(raw) < gcm.NonceSize() {
+		return nil, ErrWrongKey
+	}
+	plaintext, err := gcm.Open(nil, raw[:gcm.NonceSize()], raw[gcm.NonceSize():], []byte(Header))
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,80 @@
+package seal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	encoded, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ParseKey(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := []byte("# Architecture\n\n" + strings.Repeat("Secret roadmap. ", 20))
+
+	sealed, err := Seal(key, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSealed(sealed) || IsSealed(plaintext) {
+		t.Error("IsSealed() doesn't tell sealed files apart")
+	}
+	if bytes.Contains(sealed, []byte("roadmap")) {
+		t.Error("Expected the plaintext not to appear in the sealed file")
+	}
+	for _, line := range strings.Split(string(sealed), "\n") {
+		if len(line) > lineWidth {
+			t.Errorf("Line longer than %d: %q", lineWidth, line)
+		}
+	}
+	opened, err := Open(key, sealed)
+	if err != nil || !bytes.Equal(opened, plaintext) {
+		t.Errorf("Open() = %q, %v", opened, err)
+	}
+
+	// Sealing twice gives different files
+	again, _ := Seal(key, plaintext)
+	if bytes.Equal(again, sealed) {
+		t.Error("Expected a fresh nonce per seal")
+	}
+
+	otherKey, _ := GenerateKey()
+	other, _ := ParseKey(otherKey)
+	if _, err := Open(other, sealed); err != ErrWrongKey {
+		t.Errorf("Open() with another key = %v, want ErrWrongKey", err)
+	}
+	tampered := bytes.Replace(sealed, []byte(Header), []byte(Header+"AAAA"), 1)
+	if _, err := Open(key, tampered); err != ErrWrongKey {
+		t.Errorf("Open() of a modified file = %v, want ErrWrongKey", err)
+	}
+	if _, err := Open(key, plaintext); err == nil {
+		t.Error("Expected an error opening a plain file")
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		wantErr bool
+	}{
+		{"AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8=", false},
+		{"  AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8=\n", false},
+		{"AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8", false},
+		{"AAECAwQFBgcICQoLDA0ODw==", true},
+		{"not a key!", true},
+	}
+	for _, tt := range tests {
+		key, err := ParseKey(tt.key)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseKey(%q) error = %v, wantErr %v", tt.key, err, tt.wantErr)
+		}
+		if err == nil && (len(key) != KeySize || key[31] != 31) {
+			t.Errorf("ParseKey(%q) = %v", tt.key, key)
+		}
+	}
+}
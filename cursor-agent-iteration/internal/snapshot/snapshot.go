@@ -0,0 +1,166 @@
+package snapshot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultKeep is the number of snapshots retained by Take
+const DefaultKeep = 20
+
+// NewID returns a sortable snapshot/run identifier for the given time
+func NewID(t time.Time) string {
+	return t.Format("20060102-150405")
+}
+
+// Take copies the given control files into dir/<id>/ and prunes old snapshots
+// so that at most keep remain. files maps the name stored in the snapshot to
+// the path it is read from; missing files are skipped.
+func Take(dir string, id string, files map[string]string, keep int) (string, error) {
+	if strings.ContainsAny(id, `/\`) || id == "" || id == "." || id == ".." {
+		return "", fmt.Errorf("invalid snapshot id %q", id)
+	}
+	target := filepath.Join(dir, id)
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return "", fmt.Errorf("failed to create snapshot directory: %v", err)
+	}
+
+	for name, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return "", fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		if err := os.WriteFile(filepath.Join(target, name), data, 0644); err != nil {
+			return "", fmt.Errorf("failed to write snapshot of %s: %v", name, err)
+		}
+	}
+
+	if keep > 0 {
+		if err := prune(dir, keep); err != nil {
+			return target, err
+		}
+	}
+	return target, nil
+}
+
+// List returns snapshot ids in dir, oldest first. Snapshots are ordered by
+// creation time so that named snapshots sort alongside run ids.
+func List(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	type entry struct {
+		id      string
+		modTime time.Time
+	}
+	var found []entry
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, entry{id: e.Name(), modTime: info.ModTime()})
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if !found[i].modTime.Equal(found[j].modTime) {
+			return found[i].modTime.Before(found[j].modTime)
+		}
+		return found[i].id < found[j].id
+	})
+	ids := make([]string, len(found))
+	for i, e := range found {
+		ids[i] = e.id
+	}
+	return ids, nil
+}
+
+// Resolve turns a --since value into a snapshot id. "" and "latest" select the
+// newest snapshot, "previous" the one before it; anything else must name an
+// existing snapshot, or be a unique prefix of one.
+func Resolve(dir string, since string) (string, error) {
+	ids, err := List(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(ids) == 0 {
+		return "", fmt.Errorf("no snapshots found in %s", dir)
+	}
+
+	switch since {
+	case "", "latest":
+		return ids[len(ids)-1], nil
+	case "previous":
+		if len(ids) < 2 {
+			return "", fmt.Errorf("only one snapshot available")
+		}
+		return ids[len(ids)-2], nil
+	}
+
+	var matches []string
+	for _, id := range ids {
+		if id == since {
+			return id, nil
+		}
+		if strings.HasPrefix(id, since) {
+			matches = append(matches, id)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("snapshot %q not found", since)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("snapshot %q is ambiguous (%s)", since, strings.Join(matches, ", "))
+	}
+}
+
+// Load reads the files stored in a snapshot, keyed by name
+func Load(dir string, id string) (map[string]string, error) {
+	target := filepath.Join(dir, id)
+	entries, err := os.ReadDir(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s: %v", id, err)
+	}
+	files := make(map[string]string)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(target, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		files[e.Name()] = string(data)
+	}
+	return files, nil
+}
+
+// prune removes the oldest snapshots beyond keep
+func prune(dir string, keep int) error {
+	ids, err := List(dir)
+	if err != nil {
+		return err
+	}
+	for len(ids) > keep {
+		if err := os.RemoveAll(filepath.Join(dir, ids[0])); err != nil {
+			return fmt.Errorf("failed to prune snapshot %s: %v", ids[0], err)
+		}
+		ids = ids[1:]
+	}
+	return nil
+}
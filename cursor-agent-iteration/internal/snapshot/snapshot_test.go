@@ -0,0 +1,124 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestNewID(t *testing.T) {
+	id := NewID(time.Date(2025, 1, 8, 19, 0, 5, 0, time.UTC))
+	if id != "20250108-190005" {
+		t.Errorf("NewID() = %s, want 20250108-190005", id)
+	}
+}
+
+func TestTakeAndLoad(t *testing.T) {
+	src := t.TempDir()
+	dir := filepath.Join(t.TempDir(), "snapshots")
+	writeFile(t, filepath.Join(src, "tasks.md"), "tasks v1")
+	writeFile(t, filepath.Join(src, "progress.md"), "progress v1")
+
+	files := map[string]string{
+		"tasks.md":    filepath.Join(src, "tasks.md"),
+		"progress.md": filepath.Join(src, "progress.md"),
+		"missing.md":  filepath.Join(src, "missing.md"),
+	}
+	if _, err := Take(dir, "20250108-190000", files, DefaultKeep); err != nil {
+		t.Fatalf("Take() error = %v", err)
+	}
+
+	loaded, err := Load(dir, "20250108-190000")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(loaded) != 2 {
+		t.Errorf("Expected 2 files in snapshot, got %d", len(loaded))
+	}
+	if loaded["tasks.md"] != "tasks v1" {
+		t.Errorf("tasks.md = %q, want %q", loaded["tasks.md"], "tasks v1")
+	}
+}
+
+func TestTakeInvalidID(t *testing.T) {
+	for _, id := range []string{"", "..", "a/b"} {
+		if _, err := Take(t.TempDir(), id, nil, 0); err == nil {
+			t.Errorf("Expected error for id %q", id)
+		}
+	}
+}
+
+func TestTakePrunes(t *testing.T) {
+	dir := t.TempDir()
+	for _, id := range []string{"20250101-000000", "20250102-000000", "20250103-000000"} {
+		if _, err := Take(dir, id, nil, 2); err != nil {
+			t.Fatalf("Take() error = %v", err)
+		}
+	}
+	ids, _ := List(dir)
+	if len(ids) != 2 || ids[0] != "20250102-000000" {
+		t.Errorf("Expected the two newest snapshots to remain, got %v", ids)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Resolve(dir, ""); err == nil {
+		t.Errorf("Expected error when no snapshots exist")
+	}
+
+	base := time.Now().Add(-time.Hour)
+	for i, id := range []string{"20250101-100000", "20250102-100000", "20250102-110000"} {
+		path := filepath.Join(dir, id)
+		os.MkdirAll(path, 0755)
+		stamp := base.Add(time.Duration(i) * time.Minute)
+		os.Chtimes(path, stamp, stamp)
+	}
+
+	tests := []struct {
+		since    string
+		expected string
+		wantErr  bool
+	}{
+		{"", "20250102-110000", false},
+		{"latest", "20250102-110000", false},
+		{"previous", "20250102-100000", false},
+		{"20250101-100000", "20250101-100000", false},
+		{"20250101", "20250101-100000", false},
+		{"20250102", "", true}, // ambiguous
+		{"2024", "", true},     // not found
+	}
+	for _, tt := range tests {
+		got, err := Resolve(dir, tt.since)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Resolve(%q) error = %v, wantErr %v", tt.since, err, tt.wantErr)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("Resolve(%q) = %q, want %q", tt.since, got, tt.expected)
+		}
+	}
+}
+
+func TestListOrdersByCreation(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Now().Add(-time.Hour)
+	for i, id := range []string{"20250102-000000", "before-refactor"} {
+		path := filepath.Join(dir, id)
+		os.MkdirAll(path, 0755)
+		stamp := base.Add(time.Duration(i) * time.Minute)
+		os.Chtimes(path, stamp, stamp)
+	}
+	latest, err := Resolve(dir, "latest")
+	if err != nil || latest != "before-refactor" {
+		t.Errorf("Resolve(latest) = %q, %v; want the most recently created snapshot", latest, err)
+	}
+}
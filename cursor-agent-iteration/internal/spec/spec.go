@@ -0,0 +1,156 @@
+// Package spec turns product specs fetched from a URL, such as Notion
+// exports or wiki pages, into feature descriptions and tasks
+package spec
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/textutil"
+)
+
+// maxTitleLen keeps titles derived from long first lines readable
+const maxTitleLen = 80
+
+// maxSummaryLen caps the part of a spec quoted in its task's Context
+const maxSummaryLen = 500
+
+// maxCriteria caps the criteria taken from a spec's own list
+const maxCriteria = 15
+
+var (
+	reComments = regexp.MustCompile(`(?s)<!--.*?-->`)
+	reHidden   = regexp.MustCompile(`(?is)<(script|style|head|nav)\b.*?</(script|style|head|nav)>`)
+	reHeading  = regexp.MustCompile(`(?is)<h([1-6])\b[^>]*>(.*?)</h[1-6]>`)
+	reListItem = regexp.MustCompile(`(?i)<li\b[^>]*>`)
+	reBlock    = regexp.MustCompile(`(?i)</?(p|div|br|hr|tr|ul|ol|li|table|section|article|blockquote|pre|figure|details|summary)\b[^>]*>`)
+	reTag      = regexp.MustCompile(`<[^>]*>`)
+	reSpaces   = regexp.MustCompile(`[ \t\r\f\v]+`)
+
+	reMdHeading = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	reListLine  = regexp.MustCompile(`^(?:[-*+]|\d+[.)])\s+(?:\[[ xX]\]\s+)?(.+)$`)
+	reCriteria  = regexp.MustCompile(`(?i)^(acceptance criteria|requirements|definition of done)\b`)
+)
+
+// IsHTML reports whether a response is an HTML page rather than text or
+// Markdown, from its Content-Type or, failing that, its first tag
+func IsHTML(contentType string, body []byte) bool {
+	if strings.Contains(strings.ToLower(contentType), "html") {
+		return true
+	}
+	start := strings.ToLower(strings.TrimSpace(string(body[:min(len(body), 512)])))
+	return strings.HasPrefix(start, "<!doctype html") || strings.HasPrefix(start, "<html")
+}
+
+// Text turns an HTML page into Markdown-ish text: headings become # lines,
+// list items - lines, and everything else plain paragraphs
+func Text(page string) string {
+	page = reComments.ReplaceAllString(page, "")
+	page = reHidden.ReplaceAllString(page, "")
+	page = reHeading.ReplaceAllStringFunc(page, func(h string) string {
+		m := reHeading.FindStringSubmatch(h)
+		level := int(m[1][0] - '0')
+		return "\n\n" + strings.Repeat("#", level) + " " + strings.Join(strings.Fields(reTag.ReplaceAllString(m[2], "")), " ") + "\n\n"
+	})
+	page = reListItem.ReplaceAllString(page, "\n- ")
+	page = reBlock.ReplaceAllString(page, "\n")
+	page = html.UnescapeString(reTag.ReplaceAllString(page, ""))
+
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(page, "\n") {
+		line = strings.TrimSpace(reSpaces.ReplaceAllString(line, " "))
+		if line == "" {
+			blank = true
+			continue
+		}
+		// Items of one list stay together
+		if blank && !(strings.HasPrefix(line, "- ") && len(lines) > 0 && strings.HasPrefix(lines[len(lines)-1], "- ")) {
+			lines = append(lines, "")
+		}
+		lines = append(lines, line)
+		blank = false
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")) + "\n"
+}
+
+// Title is a spec's first heading, or its first line when it has none
+func Title(text string) string {
+	first := ""
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if m := reMdHeading.FindStringSubmatch(line); m != nil && m[2] != "" {
+			return textutil.Shorten(m[2], maxTitleLen)
+		}
+		if first == "" && line != "" {
+			first = line
+		}
+	}
+	return textutil.Shorten(first, maxTitleLen)
+}
+
+// Summary is a spec's first paragraph that isn't a heading, cut short when
+// it is long
+func Summary(text string) string {
+	for _, para := range strings.Split(text, "\n\n") {
+		para = strings.TrimSpace(para)
+		if para == "" || reMdHeading.MatchString(para) {
+			continue
+		}
+		return textutil.Shorten(strings.Join(strings.Fields(para), " "), maxSummaryLen)
+	}
+	return ""
+}
+
+// Criteria returns the list items under a spec's "Acceptance Criteria",
+// "Requirements" or "Definition of Done" heading, if it has one
+func Criteria(text string) []string {
+	var criteria []string
+	in := false
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		heading := ""
+		if m := reMdHeading.FindStringSubmatch(line); m != nil {
+			heading = m[2]
+		} else if strings.HasPrefix(line, "**") && strings.HasSuffix(strings.TrimSuffix(line, ":"), "**") {
+			heading = strings.Trim(line, "*: ")
+		}
+		if heading != "" {
+			if in {
+				break
+			}
+			in = reCriteria.MatchString(heading)
+			continue
+		}
+		if m := reListLine.FindStringSubmatch(line); in && m != nil {
+			criteria = append(criteria, strings.TrimRight(m[1], "."))
+			if len(criteria) == maxCriteria {
+				break
+			}
+		}
+	}
+	return criteria
+}
+
+// TaskBlock is the tasks.md entry for a spec fetched from url and saved at
+// path, which the agent is told to read in full
+func TaskBlock(title, url, path, text string) string {
+	context := fmt.Sprintf("From the spec at %s, saved in %s. Read the whole spec before starting; it is the description of this task.", url, path)
+	if summary := Summary(text); summary != "" {
+		context += "\n\n> " + summary
+	}
+	criteria := Criteria(text)
+	if len(criteria) == 0 {
+		criteria = []string{"The behaviour described in the spec is implemented"}
+	}
+	return tasks.Draft{
+		Title:    title,
+		Context:  context,
+		Criteria: append(criteria, "Tests cover the change"),
+		Source:   url,
+		Labels:   []string{"source:spec"},
+	}.Block()
+}
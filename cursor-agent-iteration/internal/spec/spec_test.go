@@ -0,0 +1,92 @@
+package spec
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+const samplePage = `<!DOCTYPE html>
+<html><head><title>Export</title><style>h1 { color: red }</style></head>
+<body>
+<!-- exported from the wiki -->
+<h1 class="page-title">Saved   searches</h1>
+<p>Users can save a search and run it again from the sidebar.
+It replaces the &quot;recent searches&quot; list.</p>
+<h2>Acceptance Criteria</h2>
+<ul>
+<li>A search can be saved with a name.</li>
+<li><b>Saved searches</b> are listed in the sidebar</li>
+</ul>
+<h2>Out of scope</h2>
+<ul><li>Sharing searches</li></ul>
+<script>track()</script>
+</body></html>`
+
+func TestText(t *testing.T) {
+	if !IsHTML("text/html; charset=utf-8", nil) || !IsHTML("", []byte(samplePage)) || IsHTML("text/markdown", []byte("# Spec")) {
+		t.Error("IsHTML() misjudged a response")
+	}
+	got := Text(samplePage)
+	want := `# Saved searches
+
+Users can save a search and run it again from the sidebar.
+It replaces the "recent searches" list.
+
+## Acceptance Criteria
+
+- A search can be saved with a name.
+- Saved searches are listed in the sidebar
+
+## Out of scope
+
+- Sharing searches
+`
+	if got != want {
+		t.Errorf("Text() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestTitleSummaryCriteria(t *testing.T) {
+	text := Text(samplePage)
+	if got := Title(text); got != "Saved searches" {
+		t.Errorf("Title() = %q", got)
+	}
+	if got := Title("\nPlain first line\n\nMore"); got != "Plain first line" {
+		t.Errorf("Title() without a heading = %q", got)
+	}
+	if got := Title(strings.Repeat("word ", 40)); len([]rune(got)) > maxTitleLen+1 || !strings.HasSuffix(got, "…") {
+		t.Errorf("Title() of a long line = %q", got)
+	}
+	if got := Summary(text); got != `Users can save a search and run it again from the sidebar. It replaces the "recent searches" list.` {
+		t.Errorf("Summary() = %q", got)
+	}
+	if got := Criteria(text); !reflect.DeepEqual(got, []string{"A search can be saved with a name", "Saved searches are listed in the sidebar"}) {
+		t.Errorf("Criteria() = %q", got)
+	}
+	md := "# Export\n\n**Requirements:**\n\n1. CSV download\n- [ ] JSON download\n\n**Notes**\n- later"
+	if got := Criteria(md); !reflect.DeepEqual(got, []string{"CSV download", "JSON download"}) {
+		t.Errorf("Criteria() of bold headings = %q", got)
+	}
+	if got := Criteria("# Idea\n\n- just a list"); got != nil {
+		t.Errorf("Criteria() without a criteria heading = %q", got)
+	}
+}
+
+func TestTaskBlock(t *testing.T) {
+	block := TaskBlock("Saved searches", "https://wiki.example.com/saved", ".cursor-iter/specs/saved-searches.md", Text(samplePage))
+	parsed := tasks.ParseTasks("## Current Tasks\n\n" + block)
+	if len(parsed) != 1 || parsed[0].Title != "Saved searches" || parsed[0].ACTotal != 3 {
+		t.Fatalf("ParseTasks() of the block = %+v", parsed)
+	}
+	for _, want := range []string{"saved in .cursor-iter/specs/saved-searches.md", "\n> Users can save", "* [ ] A search can be saved with a name\n", "* [ ] Tests cover the change", "**Source:** https://wiki.example.com/saved"} {
+		if !strings.Contains(block, want) {
+			t.Errorf("Expected %q in the task:\n%s", want, block)
+		}
+	}
+	if !strings.Contains(TaskBlock("Idea", "u", "p", "Just words"), "* [ ] The behaviour described in the spec is implemented") {
+		t.Error("Expected a default criterion for a spec without criteria")
+	}
+}
@@ -0,0 +1,206 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// settleWindow is how recently a file may have been modified before its
+// content is compared instead of trusting its size and mtime. Some
+// filesystems only record mtimes to the second, so two writes within the
+// same second can otherwise look identical.
+const settleWindow = 2 * time.Second
+
+// Snapshot is a consistent, parsed view of tasks.md and progress.md. A
+// snapshot is shared between readers and must not be modified.
+type Snapshot struct {
+	// Version increases every time the content of either file changes
+	Version    uint64
+	LoadedAt   time.Time
+	TasksMd    string
+	ProgressMd string
+	Tasks      []tasks.Task
+	Progress   map[string]tasks.ProgressEntry
+}
+
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+	exists  bool
+}
+
+func stat(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{size: info.Size(), modTime: info.ModTime(), exists: true}
+}
+
+// Store keeps the parsed control files in memory so HTTP handlers and the
+// scheduler can read them without re-reading and re-parsing on every call.
+// Snapshot is cheap and never touches the filesystem; Refresh and Watch bring
+// the model up to date.
+type Store struct {
+	tasksPath    string
+	progressPath string
+
+	mu       sync.RWMutex
+	snap     *Snapshot
+	stamps   [2]fileStamp
+	raw      [2][]byte
+	subs     map[int]chan *Snapshot
+	nextSub  int
+	reloadMu sync.Mutex // serializes reloads
+}
+
+// NewStore creates a store for the given files and loads them once
+func NewStore(tasksPath, progressPath string) *Store {
+	s := &Store{
+		tasksPath:    tasksPath,
+		progressPath: progressPath,
+		subs:         make(map[int]chan *Snapshot),
+	}
+	s.Refresh()
+	return s
+}
+
+// Snapshot returns the current model
+func (s *Store) Snapshot() *Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.snap
+}
+
+// Refresh reloads the files if they changed on disk and returns the current
+// snapshot, reporting whether a new version was produced. Missing files are
+// treated as empty.
+func (s *Store) Refresh() (*Snapshot, bool) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	s.mu.RLock()
+	current, stamps, raw := s.snap, s.stamps, s.raw
+	s.mu.RUnlock()
+
+	paths := [2]string{s.tasksPath, s.progressPath}
+	var newStamps [2]fileStamp
+	var newRaw [2][]byte
+	changed := current == nil
+	for i, path := range paths {
+		newStamps[i] = stat(path)
+		if current != nil && newStamps[i] == stamps[i] && time.Since(newStamps[i].modTime) > settleWindow {
+			newRaw[i] = raw[i]
+			continue
+		}
+		data, _ := os.ReadFile(path)
+		newRaw[i] = data
+		if !bytes.Equal(data, raw[i]) {
+			changed = true
+		}
+	}
+
+	if !changed {
+		s.mu.Lock()
+		s.stamps = newStamps
+		s.mu.Unlock()
+		return current, false
+	}
+
+	var version uint64 = 1
+	if current != nil {
+		version = current.Version + 1
+	}
+	parsed := tasks.ParseTasks(string(newRaw[0]))
+	titles := make([]string, len(parsed))
+	for i, t := range parsed {
+		titles[i] = t.Title
+	}
+	snap := &Snapshot{
+		Version:    version,
+		LoadedAt:   time.Now(),
+		TasksMd:    string(newRaw[0]),
+		ProgressMd: string(newRaw[1]),
+		Tasks:      parsed,
+		Progress:   tasks.ParseProgressFor(string(newRaw[1]), titles),
+	}
+
+	s.mu.Lock()
+	s.snap = snap
+	s.stamps = newStamps
+	s.raw = newRaw
+	for _, ch := range s.subs {
+		select {
+		case ch <- snap:
+		default:
+			// Subscriber hasn't consumed the previous version; it will see
+			// this one through Snapshot
+		}
+	}
+	s.mu.Unlock()
+	return snap, true
+}
+
+// Watch polls the files every interval until ctx is done. A change is only
+// loaded once the files have stopped changing for debounce, so an agent
+// rewriting a file in several steps produces a single new version.
+func (s *Store) Watch(ctx context.Context, interval, debounce time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var pending [2]fileStamp
+	var pendingSince time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := [2]fileStamp{stat(s.tasksPath), stat(s.progressPath)}
+		s.mu.RLock()
+		loaded := s.stamps
+		s.mu.RUnlock()
+
+		if now == loaded {
+			pendingSince = time.Time{}
+			continue
+		}
+		if pendingSince.IsZero() || now != pending {
+			pending = now
+			pendingSince = time.Now()
+			continue
+		}
+		if time.Since(pendingSince) >= debounce {
+			s.Refresh()
+			pendingSince = time.Time{}
+		}
+	}
+}
+
+// Subscribe returns a channel that receives each new snapshot. A subscriber
+// that falls behind misses intermediate versions rather than blocking the
+// store. cancel unregisters the channel and closes it.
+func (s *Store) Subscribe() (<-chan *Snapshot, func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch := make(chan *Snapshot, 1)
+	id := s.nextSub
+	s.nextSub++
+	s.subs[id] = ch
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			delete(s.subs, id)
+			close(ch)
+		})
+	}
+}
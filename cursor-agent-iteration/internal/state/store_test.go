@@ -0,0 +1,133 @@
+package state
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+const tasksV1 = `## Current Tasks
+
+### Task: A
+
+**Acceptance Criteria:**
+* [ ] one
+`
+
+const tasksV2 = tasksV1 + `
+### Task: B
+
+**Acceptance Criteria:**
+* [ ] two
+`
+
+func newTestStore(t *testing.T) (*Store, string, string) {
+	dir := t.TempDir()
+	tasksPath := filepath.Join(dir, "tasks.md")
+	progressPath := filepath.Join(dir, "progress.md")
+	os.WriteFile(tasksPath, []byte(tasksV1), 0644)
+	return NewStore(tasksPath, progressPath), tasksPath, progressPath
+}
+
+func TestStoreLoadsOnCreate(t *testing.T) {
+	s, _, _ := newTestStore(t)
+	snap := s.Snapshot()
+	if snap == nil || snap.Version != 1 {
+		t.Fatalf("Expected version 1 snapshot, got %+v", snap)
+	}
+	if len(snap.Tasks) != 1 || snap.Tasks[0].Title != "A" {
+		t.Errorf("Expected parsed task A, got %+v", snap.Tasks)
+	}
+	if snap.ProgressMd != "" || len(snap.Progress) != 0 {
+		t.Errorf("Expected missing progress.md to load as empty")
+	}
+}
+
+func TestStoreRefresh(t *testing.T) {
+	s, tasksPath, progressPath := newTestStore(t)
+
+	if _, changed := s.Refresh(); changed {
+		t.Errorf("Expected no new version without changes")
+	}
+
+	os.WriteFile(tasksPath, []byte(tasksV2), 0644)
+	snap, changed := s.Refresh()
+	if !changed || snap.Version != 2 || len(snap.Tasks) != 2 {
+		t.Errorf("Expected version 2 with two tasks, got changed=%v %+v", changed, snap)
+	}
+
+	// Same size, same second: content must still be compared
+	os.WriteFile(progressPath, []byte("## In Progress\n\n- 🔄 [2025-01-01 10:00] A\n"), 0644)
+	snap, _ = s.Refresh()
+	if snap.Progress["A"].Status != "in-progress" {
+		t.Errorf("Expected A in progress after refresh, got %+v", snap.Progress)
+	}
+
+	if s.Snapshot() != snap {
+		t.Errorf("Expected Snapshot to return the latest refreshed snapshot")
+	}
+}
+
+func TestStoreSnapshotsAreStable(t *testing.T) {
+	s, tasksPath, _ := newTestStore(t)
+	old := s.Snapshot()
+
+	os.WriteFile(tasksPath, []byte(tasksV2), 0644)
+	s.Refresh()
+
+	if old.Version != 1 || len(old.Tasks) != 1 {
+		t.Errorf("Expected earlier snapshot to be unaffected by refresh, got %+v", old)
+	}
+}
+
+func TestStoreWatchDebounces(t *testing.T) {
+	s, tasksPath, _ := newTestStore(t)
+	updates, cancel := s.Subscribe()
+	defer cancel()
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go s.Watch(ctx, 10*time.Millisecond, 50*time.Millisecond)
+
+	os.WriteFile(tasksPath, []byte(tasksV2), 0644)
+
+	select {
+	case snap := <-updates:
+		if len(snap.Tasks) != 2 {
+			t.Errorf("Expected watched snapshot to contain two tasks, got %d", len(snap.Tasks))
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Timed out waiting for watched update")
+	}
+}
+
+func TestStoreConcurrentReaders(t *testing.T) {
+	s, tasksPath, _ := newTestStore(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				snap := s.Snapshot()
+				if len(snap.Tasks) == 0 {
+					t.Errorf("Expected every snapshot to be fully parsed")
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		content := tasksV1
+		if i%2 == 0 {
+			content = tasksV2
+		}
+		os.WriteFile(tasksPath, []byte(content), 0644)
+		s.Refresh()
+	}
+	wg.Wait()
+}
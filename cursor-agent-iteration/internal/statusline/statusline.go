@@ -0,0 +1,175 @@
+// Package statusline condenses the backlog into one short line for shell
+// prompts, tmux and editor status bars. The counts are kept in a small cache
+// file, keyed by the size and modification time of tasks.md and
+// progress.md, so a status bar that redraws every few seconds doesn't
+// re-parse the control files each time.
+package statusline
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/textutil"
+)
+
+// RunningStaleAfter is how long the running count of a loop is trusted
+// without a heartbeat, so a crashed loop doesn't show agents forever
+const RunningStaleAfter = 2 * time.Minute
+
+// settleWindow is how recently a file may have been modified before its
+// stamp is trusted; some filesystems only record mtimes to the second, so
+// two writes within the same second can otherwise look identical
+const settleWindow = 2 * time.Second
+
+// Stamp identifies a version of a file without reading it
+type Stamp struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// StampOf returns the stamp of a file; a missing file has the zero stamp
+func StampOf(path string) Stamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Stamp{}
+	}
+	return Stamp{Size: info.Size(), ModTime: info.ModTime()}
+}
+
+// Cache is the content of the cache file
+type Cache struct {
+	Tasks    Stamp `json:"tasks"`
+	Progress Stamp `json:"progress"`
+
+	Done       int    `json:"done"`
+	Total      int    `json:"total"`
+	InProgress int    `json:"in_progress"`
+	Blocked    int    `json:"blocked"`
+	Current    string `json:"current,omitempty"`
+	Next       string `json:"next,omitempty"`
+
+	// Running is the number of agents an iterate-loop had running when it
+	// last wrote RunningAt
+	Running   int       `json:"running"`
+	RunningAt time.Time `json:"running_at,omitempty"`
+}
+
+// Load reads a cache file; a missing or unreadable one gives an empty cache
+func Load(path string) Cache {
+	var c Cache
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &c)
+	}
+	return c
+}
+
+// Save writes the cache file, replacing it in one step so readers never see
+// half of it
+func (c Cache) Save(path string) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Fresh reports whether the counts were taken from the current versions of
+// the control files
+func (c Cache) Fresh(tasksStamp, progressStamp Stamp, now time.Time) bool {
+	return c.Total > 0 && c.Tasks.same(tasksStamp, now) && c.Progress.same(progressStamp, now)
+}
+
+func (s Stamp) same(o Stamp, now time.Time) bool {
+	return s.Size == o.Size && s.ModTime.Equal(o.ModTime) && now.Sub(o.ModTime) > settleWindow
+}
+
+// Update takes the counts from a status summary of the files with the given
+// stamps, keeping the running count
+func (c *Cache) Update(s tasks.StatusSummary, tasksStamp, progressStamp Stamp) {
+	c.Tasks, c.Progress = tasksStamp, progressStamp
+	c.Done = s.Totals.Completed
+	c.Total = s.Totals.Total
+	c.InProgress = s.Totals.InProgress
+	c.Blocked = s.Totals.Blocked
+	c.Current = s.Current
+	c.Next = s.Next
+}
+
+// Refresh returns the cache for the control files, parsing them only when
+// they changed since the cache was written, in which case it saves the new
+// counts. The label defaults are merged into every task.
+func Refresh(cachePath, tasksPath, progressPath string, defaults tasks.LabelDefaults) Cache {
+	c := Load(cachePath)
+	tasksStamp, progressStamp := StampOf(tasksPath), StampOf(progressPath)
+	if c.Fresh(tasksStamp, progressStamp, time.Now()) {
+		return c
+	}
+	tasksMd, _ := os.ReadFile(tasksPath)
+	progressMd, _ := os.ReadFile(progressPath)
+	c.Update(tasks.SummarizeWith(string(tasksMd), string(progressMd), tasks.StatusOptions{Defaults: defaults}), tasksStamp, progressStamp)
+	c.Save(cachePath)
+	return c
+}
+
+// SetRunning records how many agents a loop has running, as its heartbeat
+func SetRunning(cachePath string, running int, now time.Time) error {
+	c := Load(cachePath)
+	c.Running, c.RunningAt = running, now
+	return c.Save(cachePath)
+}
+
+// Line renders the cache, e.g. "7/30 done | 2 running | next: OAuth
+// refresh". The task title is shortened so the line fits in width
+// characters; 0 means no limit.
+func (c Cache) Line(now time.Time, width int) string {
+	if c.Total == 0 {
+		return "no tasks"
+	}
+	parts := []string{fmt.Sprintf("%d/%d done", c.Done, c.Total)}
+	if c.Running > 0 && now.Sub(c.RunningAt) < RunningStaleAfter {
+		parts = append(parts, fmt.Sprintf("%d running", c.Running))
+	} else if c.InProgress > 0 {
+		parts = append(parts, fmt.Sprintf("%d in progress", c.InProgress))
+	}
+	if c.Blocked > 0 {
+		parts = append(parts, fmt.Sprintf("%d blocked", c.Blocked))
+	}
+	line := strings.Join(parts, " | ")
+
+	task := ""
+	switch {
+	case c.Current != "":
+		task = "now: " + c.Current
+	case c.Next != "":
+		task = "next: " + c.Next
+	case c.Done == c.Total:
+		task = "all complete"
+	}
+	if width <= 0 {
+		if task == "" {
+			return line
+		}
+		return line + " | " + task
+	}
+	// The counts matter most; the task gets whatever room is left
+	room := width - len([]rune(line)) - len(" | ")
+	if task == "" || room < minTaskWidth {
+		return textutil.Truncate(line, width)
+	}
+	return line + " | " + textutil.Truncate(task, room)
+}
+
+// minTaskWidth is the least room worth showing a shortened task in
+const minTaskWidth = 8
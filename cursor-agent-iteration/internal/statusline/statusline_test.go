@@ -0,0 +1,86 @@
+package statusline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLine(t *testing.T) {
+	now := time.Date(2025, 1, 8, 19, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		cache Cache
+		width int
+		want  string
+	}{
+		{"running loop", Cache{Done: 7, Total: 30, InProgress: 2, Running: 2, RunningAt: now.Add(-time.Second), Current: "OAuth refresh"}, 0, "7/30 done | 2 running | now: OAuth refresh"},
+		{"no loop", Cache{Done: 7, Total: 30, InProgress: 1, Current: "OAuth refresh"}, 0, "7/30 done | 1 in progress | now: OAuth refresh"},
+		{"stale heartbeat", Cache{Done: 7, Total: 30, Running: 3, RunningAt: now.Add(-time.Hour), Next: "OAuth refresh"}, 0, "7/30 done | next: OAuth refresh"},
+		{"blocked", Cache{Done: 7, Total: 30, Blocked: 2, Next: "OAuth refresh"}, 0, "7/30 done | 2 blocked | next: OAuth refresh"},
+		{"all complete", Cache{Done: 30, Total: 30}, 0, "30/30 done | all complete"},
+		{"only blocked left", Cache{Done: 29, Total: 30, Blocked: 1}, 0, "29/30 done | 1 blocked"},
+		{"no tasks", Cache{}, 0, "no tasks"},
+		{"shortened task", Cache{Done: 7, Total: 30, Next: "OAuth refresh token rotation"}, 30, "7/30 done | next: OAuth refre…"},
+		{"no room for the task", Cache{Done: 7, Total: 30, Blocked: 2, Next: "OAuth refresh"}, 25, "7/30 done | 2 blocked"},
+		{"no room for the counts", Cache{Done: 7, Total: 30, Blocked: 2}, 12, "7/30 done |…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.cache.Line(now, tt.width)
+			if got != tt.want {
+				t.Errorf("Line() = %q, want %q", got, tt.want)
+			}
+			if tt.width > 0 && len([]rune(got)) > tt.width {
+				t.Errorf("Line() is %d characters, want at most %d", len([]rune(got)), tt.width)
+			}
+		})
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, "statusline.json")
+	tasksPath := filepath.Join(dir, "tasks.md")
+	progressPath := filepath.Join(dir, "progress.md")
+	old := time.Now().Add(-time.Minute)
+	write := func(path, content string) {
+		os.WriteFile(path, []byte(content), 0644)
+		os.Chtimes(path, old, old)
+	}
+	write(tasksPath, "## Current Tasks\n\n### Task: Signup\n\n### Task: Billing\n")
+	write(progressPath, "# Progress Log\n\n## Completed Tasks\n\n- ✅ [2025-01-08 19:00] Signup\n")
+
+	c := Refresh(cachePath, tasksPath, progressPath, nil)
+	if c.Done != 1 || c.Total != 2 || c.Next != "Billing" {
+		t.Fatalf("Refresh() = %+v", c)
+	}
+
+	// Unchanged files are not parsed again: the cached counts are used
+	c.Done = 2
+	c.Save(cachePath)
+	if got := Refresh(cachePath, tasksPath, progressPath, nil); got.Done != 2 {
+		t.Errorf("Expected the cached counts, got %+v", got)
+	}
+
+	// A changed file is
+	write(progressPath, "# Progress Log\n\n## In Progress\n\n- 🔄 [2025-01-08 19:05] Billing\n\n## Completed Tasks\n\n- ✅ [2025-01-08 19:00] Signup\n")
+	if got := Refresh(cachePath, tasksPath, progressPath, nil); got.Done != 1 || got.InProgress != 1 || got.Current != "Billing" {
+		t.Errorf("Expected the counts of the changed file, got %+v", got)
+	}
+
+	// The running count survives refreshes
+	now := time.Now()
+	if err := SetRunning(cachePath, 1, now); err != nil {
+		t.Fatal(err)
+	}
+	write(tasksPath, "## Current Tasks\n\n### Task: Signup\n\n### Task: Billing\n\n### Task: Search\n")
+	got := Refresh(cachePath, tasksPath, progressPath, nil)
+	if got.Total != 3 || got.Running != 1 || !got.RunningAt.Equal(now) {
+		t.Errorf("Refresh() = %+v", got)
+	}
+	if line := got.Line(now, 0); line != "1/3 done | 1 running | now: Billing" {
+		t.Errorf("Line() = %q", line)
+	}
+}
@@ -0,0 +1,102 @@
+package stream
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+)
+
+// reLogTimestamp matches the "[15:04:05] " prefix of a log record so records
+// that differ only in their timestamp are treated as identical
+var reLogTimestamp = regexp.MustCompile(`^\[\d{2}:\d{2}:\d{2}\] `)
+
+// DedupWriter forwards complete lines to an underlying writer, collapsing runs
+// of identical consecutive lines into the first line followed by a single
+// "repeated N times" note. Partial lines are buffered until their newline
+// arrives or the writer is flushed.
+type DedupWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	partial []byte
+	last    []byte // comparison key of the last line written
+	repeats int
+}
+
+// NewDedupWriter wraps w
+func NewDedupWriter(w io.Writer) *DedupWriter {
+	return &DedupWriter{w: w}
+}
+
+// Write implements io.Writer. It always reports len(p) bytes written.
+func (d *DedupWriter) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.partial = append(d.partial, p...)
+	for {
+		i := bytes.IndexByte(d.partial, '\n')
+		if i < 0 {
+			break
+		}
+		line := d.partial[:i+1]
+		if err := d.writeLine(line); err != nil {
+			return len(p), err
+		}
+		d.partial = d.partial[i+1:]
+	}
+	// Drop the consumed prefix so the buffer doesn't grow without bound
+	if len(d.partial) == 0 {
+		d.partial = nil
+	}
+	return len(p), nil
+}
+
+func (d *DedupWriter) writeLine(line []byte) error {
+	key := reLogTimestamp.ReplaceAll(bytes.TrimRight(line, "\r\n"), nil)
+	if d.last != nil && bytes.Equal(key, d.last) {
+		d.repeats++
+		return nil
+	}
+	if err := d.flushRepeats(); err != nil {
+		return err
+	}
+	d.last = append(d.last[:0], key...)
+	_, err := d.w.Write(line)
+	return err
+}
+
+func (d *DedupWriter) flushRepeats() error {
+	if d.repeats == 0 {
+		return nil
+	}
+	n := d.repeats
+	d.repeats = 0
+	_, err := fmt.Fprintf(d.w, "    … previous line repeated %d more time%s\n", n, plural(n))
+	return err
+}
+
+// Flush writes any pending repeat note and buffered partial line
+func (d *DedupWriter) Flush() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.flushRepeats(); err != nil {
+		return err
+	}
+	if len(d.partial) > 0 {
+		_, err := d.w.Write(d.partial)
+		d.partial = nil
+		d.last = nil
+		return err
+	}
+	return nil
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
@@ -0,0 +1,59 @@
+package stream
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDedupWriter(t *testing.T) {
+	tests := []struct {
+		name     string
+		writes   []string
+		expected string
+	}{
+		{
+			name:     "distinct lines pass through",
+			writes:   []string{"a\n", "b\n", "a\n"},
+			expected: "a\nb\na\n",
+		},
+		{
+			name:     "identical consecutive lines collapse",
+			writes:   []string{"same\n", "same\n", "same\n", "next\n"},
+			expected: "same\n    … previous line repeated 2 more times\nnext\n",
+		},
+		{
+			name:     "timestamps are ignored when comparing",
+			writes:   []string{"[10:00:00] waiting\n", "[10:00:05] waiting\n", "[10:00:09] done\n"},
+			expected: "[10:00:00] waiting\n    … previous line repeated 1 more time\n[10:00:09] done\n",
+		},
+		{
+			name:     "lines split across writes",
+			writes:   []string{"hel", "lo\nhello\n", "wor", "ld\n"},
+			expected: "hello\n    … previous line repeated 1 more time\nworld\n",
+		},
+		{
+			name:     "pending repeats and partial line are flushed",
+			writes:   []string{"x\n", "x\n", "tail"},
+			expected: "x\n    … previous line repeated 1 more time\ntail",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			d := NewDedupWriter(&out)
+			for _, w := range tt.writes {
+				n, err := d.Write([]byte(w))
+				if err != nil || n != len(w) {
+					t.Fatalf("Write(%q) = %d, %v", w, n, err)
+				}
+			}
+			if err := d.Flush(); err != nil {
+				t.Fatalf("Flush() = %v", err)
+			}
+			if got := out.String(); got != tt.expected {
+				t.Errorf("output = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package stream
+
+import (
+	"sync"
+)
+
+// DefaultCapacity is the number of bytes of agent output retained per task
+const DefaultCapacity = 64 * 1024
+
+// RingBuffer keeps the most recent output of a running agent in memory and
+// fans new writes out to live subscribers (dashboard, TUI).
+//
+// Writes never block: when the buffer is full the oldest bytes are
+// overwritten, and when a subscriber's channel is full the chunk is dropped
+// for that subscriber only. A slow reader can therefore never stall the agent
+// process whose output is being recorded.
+type RingBuffer struct {
+	mu      sync.Mutex
+	buf     []byte
+	start   int // index of the oldest byte
+	size    int // number of valid bytes in buf
+	total   int64
+	subs    map[int]*subscriber
+	nextSub int
+	closed  bool
+}
+
+type subscriber struct {
+	ch      chan []byte
+	dropped int64
+}
+
+// NewRingBuffer creates a ring buffer holding at most capacity bytes
+func NewRingBuffer(capacity int) *RingBuffer {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	return &RingBuffer{
+		buf:  make([]byte, capacity),
+		subs: make(map[int]*subscriber),
+	}
+}
+
+// Write records p and forwards a copy to every subscriber. It always reports
+// len(p) bytes written so it can be used inside an io.MultiWriter.
+func (r *RingBuffer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed || len(p) == 0 {
+		return len(p), nil
+	}
+	r.total += int64(len(p))
+
+	data := p
+	if len(data) >= len(r.buf) {
+		// Only the tail fits; keep the newest bytes
+		data = data[len(data)-len(r.buf):]
+		copy(r.buf, data)
+		r.start = 0
+		r.size = len(r.buf)
+	} else {
+		for _, c := range data {
+			end := (r.start + r.size) % len(r.buf)
+			r.buf[end] = c
+			if r.size < len(r.buf) {
+				r.size++
+			} else {
+				r.start = (r.start + 1) % len(r.buf)
+			}
+		}
+	}
+
+	if len(r.subs) > 0 {
+		chunk := make([]byte, len(p))
+		copy(chunk, p)
+		for _, s := range r.subs {
+			select {
+			case s.ch <- chunk:
+			default:
+				s.dropped++
+			}
+		}
+	}
+	return len(p), nil
+}
+
+// Snapshot returns a copy of the currently retained output, oldest first
+func (r *RingBuffer) Snapshot() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make([]byte, r.size)
+	first := len(r.buf) - r.start
+	if first > r.size {
+		first = r.size
+	}
+	copy(out, r.buf[r.start:r.start+first])
+	copy(out[first:], r.buf[:r.size-first])
+	return out
+}
+
+// Total returns the number of bytes ever written, including overwritten ones
+func (r *RingBuffer) Total() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.total
+}
+
+// Subscribe registers a live reader. The returned channel receives every chunk
+// written after the call, up to depth pending chunks; further chunks are
+// dropped until the reader catches up. The cancel function unregisters the
+// reader and closes the channel, and reports how many chunks were dropped.
+func (r *RingBuffer) Subscribe(depth int) (<-chan []byte, func() int64) {
+	if depth <= 0 {
+		depth = 64
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := &subscriber{ch: make(chan []byte, depth)}
+	if r.closed {
+		close(s.ch)
+		return s.ch, func() int64 { return 0 }
+	}
+	id := r.nextSub
+	r.nextSub++
+	r.subs[id] = s
+
+	var once sync.Once
+	return s.ch, func() int64 {
+		once.Do(func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if _, ok := r.subs[id]; ok {
+				delete(r.subs, id)
+				close(s.ch)
+			}
+		})
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return s.dropped
+	}
+}
+
+// Close ends all subscriptions. The retained output remains readable via
+// Snapshot; further writes are discarded.
+func (r *RingBuffer) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	r.closed = true
+	for id, s := range r.subs {
+		close(s.ch)
+		delete(r.subs, id)
+	}
+}
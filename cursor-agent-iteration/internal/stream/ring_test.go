@@ -0,0 +1,162 @@
+package stream
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestRingBufferSnapshot(t *testing.T) {
+	tests := []struct {
+		name     string
+		capacity int
+		writes   []string
+		expected string
+	}{
+		{
+			name:     "fits in buffer",
+			capacity: 16,
+			writes:   []string{"hello ", "world"},
+			expected: "hello world",
+		},
+		{
+			name:     "wraps and keeps newest bytes",
+			capacity: 8,
+			writes:   []string{"abcdef", "ghij"},
+			expected: "cdefghij",
+		},
+		{
+			name:     "single write larger than capacity",
+			capacity: 4,
+			writes:   []string{"0123456789"},
+			expected: "6789",
+		},
+		{
+			name:     "empty",
+			capacity: 4,
+			writes:   nil,
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRingBuffer(tt.capacity)
+			for _, w := range tt.writes {
+				n, err := r.Write([]byte(w))
+				if err != nil || n != len(w) {
+					t.Fatalf("Write(%q) = %d, %v", w, n, err)
+				}
+			}
+			if got := string(r.Snapshot()); got != tt.expected {
+				t.Errorf("Snapshot() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRingBufferTotal(t *testing.T) {
+	r := NewRingBuffer(4)
+	r.Write([]byte("abcdef"))
+	r.Write([]byte("gh"))
+	if r.Total() != 8 {
+		t.Errorf("Total() = %d, want 8", r.Total())
+	}
+}
+
+func TestRingBufferSubscribe(t *testing.T) {
+	r := NewRingBuffer(64)
+	r.Write([]byte("before "))
+
+	ch, cancel := r.Subscribe(4)
+	r.Write([]byte("one"))
+	r.Write([]byte("two"))
+
+	if got := string(<-ch); got != "one" {
+		t.Errorf("first chunk = %q, want %q", got, "one")
+	}
+	if got := string(<-ch); got != "two" {
+		t.Errorf("second chunk = %q, want %q", got, "two")
+	}
+
+	if dropped := cancel(); dropped != 0 {
+		t.Errorf("dropped = %d, want 0", dropped)
+	}
+	if _, ok := <-ch; ok {
+		t.Errorf("Expected channel to be closed after cancel")
+	}
+}
+
+func TestRingBufferBackpressure(t *testing.T) {
+	r := NewRingBuffer(1024)
+	_, cancel := r.Subscribe(2)
+
+	// Nobody reads the channel; writes must not block
+	for i := 0; i < 10; i++ {
+		r.Write([]byte(fmt.Sprintf("chunk %d\n", i)))
+	}
+
+	if dropped := cancel(); dropped != 8 {
+		t.Errorf("dropped = %d, want 8", dropped)
+	}
+	if got := string(r.Snapshot()); len(got) == 0 {
+		t.Errorf("Expected snapshot to retain output despite slow subscriber")
+	}
+}
+
+func TestRingBufferClose(t *testing.T) {
+	r := NewRingBuffer(16)
+	ch, cancel := r.Subscribe(1)
+	r.Write([]byte("data"))
+	r.Close()
+
+	// Buffered chunk is still delivered, then the channel closes
+	if got := string(<-ch); got != "data" {
+		t.Errorf("chunk = %q, want %q", got, "data")
+	}
+	if _, ok := <-ch; ok {
+		t.Errorf("Expected channel to be closed after Close")
+	}
+	cancel() // must not panic after Close
+
+	r.Write([]byte("ignored"))
+	if got := string(r.Snapshot()); got != "data" {
+		t.Errorf("Snapshot() after Close = %q, want %q", got, "data")
+	}
+
+	late, _ := r.Subscribe(1)
+	if _, ok := <-late; ok {
+		t.Errorf("Expected subscription on closed buffer to be closed")
+	}
+}
+
+func TestRingBufferConcurrentWriters(t *testing.T) {
+	r := NewRingBuffer(128)
+	ch, cancel := r.Subscribe(8)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				r.Write([]byte(fmt.Sprintf("%d:%d ", n, j)))
+			}
+		}(i)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for range ch {
+		}
+		close(done)
+	}()
+	wg.Wait()
+	r.Close()
+	<-done
+
+	if len(r.Snapshot()) != 128 {
+		t.Errorf("Expected full buffer after concurrent writes, got %d bytes", len(r.Snapshot()))
+	}
+}
@@ -0,0 +1,127 @@
+package tasks
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ArchiveFilter narrows archive-completed to some of the completed tasks, so
+// a finished milestone can be archived while recent completions stay in
+// progress.md. The zero filter matches every completed task.
+type ArchiveFilter struct {
+	Milestone string    // the task's [milestone:...] label
+	Before    time.Time // completed before this time, compared to the entry's wall clock
+	Label     string    // a "key:value" label, or a bare key matching any value
+	// Defaults are the label defaults merged into every task
+	Defaults LabelDefaults
+}
+
+// IsZero reports whether the filter matches every completed task
+func (f ArchiveFilter) IsZero() bool {
+	return f.Milestone == "" && f.Before.IsZero() && f.Label == ""
+}
+
+// String describes the filter, e.g. "milestone auth, completed before
+// 2025-01-01"
+func (f ArchiveFilter) String() string {
+	var parts []string
+	if f.Milestone != "" {
+		parts = append(parts, "milestone "+f.Milestone)
+	}
+	if f.Label != "" {
+		parts = append(parts, "label "+f.Label)
+	}
+	if !f.Before.IsZero() {
+		parts = append(parts, "completed before "+f.Before.Format("2006-01-02"))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Matches reports whether a completed entry passes the filter. Its task is
+// nil when the task is no longer in tasks.md; such entries only match
+// filters that don't look at labels.
+func (f ArchiveFilter) Matches(entry ProgressEntry, task *Task) bool {
+	if !f.Before.IsZero() && (entry.CompletedAt.IsZero() || !entry.CompletedAt.Before(f.Before)) {
+		return false
+	}
+	if f.Milestone == "" && f.Label == "" {
+		return true
+	}
+	if task == nil {
+		return false
+	}
+	if f.Milestone != "" && !strings.EqualFold(TaskMilestone(*task), f.Milestone) {
+		return false
+	}
+	if f.Label != "" && !HasLabel(task.Labels, f.Label) {
+		return false
+	}
+	return true
+}
+
+// ArchivableTasks returns the titles of the completed tasks that pass the
+// filter, in tasks.md order followed by completions no longer in tasks.md
+func ArchivableTasks(tasksMd string, progressMd string, filter ArchiveFilter) []string {
+	all := parseTasksN(tasksMd, -1, filter.Defaults)
+	entries := ParseProgressFor(progressMd, taskTitles(all))
+	byTitle := make(map[string]*Task, len(all))
+	for i := range all {
+		byTitle[all[i].Title] = &all[i]
+	}
+
+	var titles []string
+	seen := make(map[string]bool)
+	for _, t := range all {
+		if entry, ok := entries[t.Title]; ok && entry.Status == "completed" && filter.Matches(entry, byTitle[t.Title]) {
+			titles = append(titles, t.Title)
+			seen[t.Title] = true
+		}
+	}
+	for _, line := range unwrapEntries(strings.Split(progressMd, "\n")) {
+		title, ok := completedLineTitle(line, taskTitles(all))
+		if !ok || seen[title] {
+			continue
+		}
+		if entry := entries[title]; entry.Status == "completed" && filter.Matches(entry, byTitle[title]) {
+			titles = append(titles, title)
+			seen[title] = true
+		}
+	}
+	return titles
+}
+
+// completedLineTitle returns the title of a "- ✅ [date] Title" line
+func completedLineTitle(line string, titles []string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "- ✅") && !strings.HasPrefix(trimmed, "* ✅") {
+		return "", false
+	}
+	_, rest, ok := strings.Cut(line, "]")
+	if !ok {
+		return "", false
+	}
+	title, _, _ := splitEntry(rest, titles...)
+	return title, title != ""
+}
+
+// ParseArchiveBefore parses the --before date of archive-completed
+func ParseArchiveBefore(spec string) (time.Time, error) {
+	if spec == "" {
+		return time.Time{}, nil
+	}
+	// Progress timestamps carry no zone and are parsed as UTC, so the
+	// cutoff is too
+	t, err := time.Parse("2006-01-02", spec)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --before %q, want e.g. 2025-01-31", spec)
+	}
+	return t, nil
+}
+
+// ParseArchive reads the completions of an archive file written by
+// ArchiveCompletedTasks, which lists them without a section header, keyed
+// by title like ParseProgressFor
+func ParseArchive(archiveMd string, titles []string) map[string]ProgressEntry {
+	return ParseProgressFor("## Completed Tasks\n"+archiveMd, titles)
+}
@@ -0,0 +1,116 @@
+package tasks
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const archiveTasks = `## Current Tasks
+
+### Task: Login form
+
+**Labels:** ` + "`[milestone:auth] [type:feature]`" + `
+
+### Task: Session cookies
+
+**Labels:** ` + "`[milestone:auth] [type:bug]`" + `
+
+### Task: Search index
+
+**Labels:** ` + "`[milestone:search] [type:feature]`" + `
+
+### Task: Search ranking
+
+**Labels:** ` + "`[milestone:search]`" + `
+`
+
+const archiveProgress = `# Progress Log
+
+## In Progress
+
+- 🔄 [2025-01-09 09:00] Search ranking
+
+## Completed Tasks
+
+- ✅ [2024-12-20 10:00] Login form - done
+- ✅ [2025-01-03 11:00] Session cookies
+- ✅ [2025-01-08 15:30] Search index
+- ✅ [2024-11-02 08:00] Old cleanup - task already gone from tasks.md
+`
+
+func TestArchivableTasks(t *testing.T) {
+	before, err := ParseArchiveBefore("2025-01-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		filter ArchiveFilter
+		want   []string
+	}{
+		{"everything", ArchiveFilter{}, []string{"Login form", "Session cookies", "Search index", "Old cleanup"}},
+		{"milestone", ArchiveFilter{Milestone: "Auth"}, []string{"Login form", "Session cookies"}},
+		{"before", ArchiveFilter{Before: before}, []string{"Login form", "Old cleanup"}},
+		{"label with value", ArchiveFilter{Label: "type:feature"}, []string{"Login form", "Search index"}},
+		{"bare label", ArchiveFilter{Label: "type"}, []string{"Login form", "Session cookies", "Search index"}},
+		{"combined", ArchiveFilter{Milestone: "auth", Before: before}, []string{"Login form"}},
+		{"no match", ArchiveFilter{Milestone: "billing"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ArchivableTasks(archiveTasks, archiveProgress, tt.filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ArchivableTasks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArchiveCompletedTasksMatching(t *testing.T) {
+	filter := ArchiveFilter{Milestone: "auth"}
+	archived, remainingProgress, updatedTasks, _, err := ArchiveCompletedTasksMatching(archiveTasks, archiveProgress, t.TempDir(), filter)
+	if err != nil {
+		t.Fatalf("ArchiveCompletedTasksMatching() error = %v", err)
+	}
+	for _, want := range []string{"Filter: milestone auth", "- ✅ [2024-12-20 10:00] Login form - done | milestone: auth\n", "Session cookies"} {
+		if !strings.Contains(archived, want) {
+			t.Errorf("Archive should contain %q:\n%s", want, archived)
+		}
+	}
+	if strings.Contains(archived, "Search index") {
+		t.Errorf("Archive should leave out other milestones:\n%s", archived)
+	}
+
+	for _, gone := range []string{"Login form", "Session cookies"} {
+		if strings.Contains(remainingProgress, gone) || strings.Contains(updatedTasks, gone) {
+			t.Errorf("Expected %q removed from progress.md and tasks.md", gone)
+		}
+	}
+	for _, kept := range []string{"Search index", "Old cleanup", "🔄 [2025-01-09 09:00] Search ranking"} {
+		if !strings.Contains(remainingProgress, kept) {
+			t.Errorf("Expected %q kept in progress.md:\n%s", kept, remainingProgress)
+		}
+	}
+	if !strings.Contains(updatedTasks, "### Task: Search index") {
+		t.Errorf("Expected other milestones kept in tasks.md:\n%s", updatedTasks)
+	}
+
+	entries := ParseArchive(archived, []string{"Login form", "Search index"})
+	if e, ok := entries["Login form"]; !ok || e.Status != "completed" || e.Notes != "done" {
+		t.Errorf("ParseArchive() Login form = %+v, %v", e, ok)
+	} else if m, _ := e.Field("milestone"); m != "auth" {
+		t.Errorf("Expected the archived entry to keep milestone auth, got %q", m)
+	}
+	if _, ok := entries["Search index"]; ok {
+		t.Error("ParseArchive() found a task the archive leaves out")
+	}
+}
+
+func TestParseArchiveBefore(t *testing.T) {
+	if _, err := ParseArchiveBefore("01/01/2025"); err == nil {
+		t.Error("Expected an error for a non-ISO date")
+	}
+	if got, err := ParseArchiveBefore(""); err != nil || !got.IsZero() {
+		t.Errorf("ParseArchiveBefore(\"\") = %v, %v", got, err)
+	}
+}
@@ -0,0 +1,203 @@
+package tasks
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Entry marks of the sections that hold a reason
+const (
+	blockedMark = "⛔"
+	failedMark  = "❌"
+)
+
+// parseBlockedLine parses "- ⛔ [2025-01-08 19:00] Task Title - reason",
+// matching the title against titles as splitEntry does
+func parseBlockedLine(line string, titles ...string) (title string, notes string, fields []Field, at time.Time, ok bool) {
+	return parseReasonLine(line, blockedMark, titles...)
+}
+
+// parseReasonLine parses a blocked or failed entry, the one with mark
+func parseReasonLine(line string, mark string, titles ...string) (title string, notes string, fields []Field, at time.Time, ok bool) {
+	var rest string
+	switch {
+	case strings.HasPrefix(line, "- "+mark+" ["):
+		rest = strings.TrimPrefix(line, "- "+mark+" [")
+	case strings.HasPrefix(line, "* "+mark+" ["):
+		rest = strings.TrimPrefix(line, "* "+mark+" [")
+	default:
+		return "", "", nil, time.Time{}, false
+	}
+	parts := strings.SplitN(rest, "]", 2)
+	if len(parts) != 2 {
+		return "", "", nil, time.Time{}, false
+	}
+	at, _ = time.Parse("2006-01-02 15:04", parts[0])
+	title, notes, fields = splitEntry(parts[1], titles...)
+	return title, notes, fields, at, title != ""
+}
+
+// progressLineTitle returns the task title of an in-progress, blocked,
+// failed or completed progress.md entry, or "" for other lines. Titles are
+// matched against titles as splitEntry does.
+func progressLineTitle(line string, titles ...string) string {
+	trimmed := strings.TrimSpace(line)
+	for _, mark := range []string{blockedMark, failedMark} {
+		if title, _, _, _, ok := parseReasonLine(trimmed, mark, titles...); ok {
+			return title
+		}
+	}
+	for _, prefix := range []string{"- 🔄 [", "* 🔄 [", "- ✅ [", "* ✅ ["} {
+		if strings.HasPrefix(trimmed, prefix) {
+			parts := strings.SplitN(trimmed, "]", 2)
+			if len(parts) == 2 {
+				title, _, _ := splitEntry(parts[1], titles...)
+				return title
+			}
+		}
+	}
+	return ""
+}
+
+// IsTaskBlocked checks if a task is marked as blocked in progress.md
+func IsTaskBlocked(progressMd string, taskTitle string) bool {
+	entry, exists := ParseProgressFor(progressMd, []string{taskTitle})[taskTitle]
+	return exists && entry.Status == "blocked"
+}
+
+// GetBlockedTasks returns the titles of tasks blocked in progress.md
+func GetBlockedTasks(progressMd string) []string {
+	var titles []string
+	for title, entry := range ParseProgress(progressMd) {
+		if entry.Status == "blocked" {
+			titles = append(titles, title)
+		}
+	}
+	return titles
+}
+
+// OnlyBlockedRemain reports whether every task that isn't completed is
+// blocked or failed, and at least one is. Pending tasks that depend on such
+// a task can't start either, so they don't count as remaining work.
+func OnlyBlockedRemain(tasksMd string, progressMd string) bool {
+	all := parseTasks(tasksMd)
+	entries := ParseProgressFor(progressMd, taskTitles(all))
+	graph := NewDependencyGraph(all)
+	blocked := 0
+	for _, t := range all {
+		switch entries[t.Title].Status {
+		case "completed":
+		case "blocked", "failed":
+			blocked++
+		case "":
+			if !graph.Stuck(t.Title, entries) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return blocked > 0
+}
+
+// MarkTaskBlocked moves a task into the "## Blocked" section of progress.md,
+// creating the section before "## Completed Tasks" if needed. Blocked tasks
+// are neither resumed nor picked up as pending until they are unblocked.
+func MarkTaskBlocked(progressMd string, taskTitle string, reason string) string {
+	return markWithReason(progressMd, "## Blocked", blockedMark, taskTitle, reason)
+}
+
+// markWithReason moves a task into section, as an entry with mark and the
+// reason, creating the section before "## Completed Tasks" if needed
+func markWithReason(progressMd string, section string, mark string, taskTitle string, reason string) string {
+	progressMd = removeProgressEntry(progressMd, taskTitle)
+
+	entry := fmt.Sprintf("- %s [%s] %s", mark, time.Now().Format("2006-01-02 15:04"), entryTitle(taskTitle))
+	if reason != "" {
+		entry += " - " + reason
+	}
+
+	if strings.TrimSpace(progressMd) == "" {
+		progressMd = "# Progress Log\n\n## In Progress\n\n## Completed Tasks\n\n"
+	}
+	if !hasSection(progressMd, section) {
+		if strings.Contains(progressMd, "## Completed Tasks") {
+			progressMd = strings.Replace(progressMd, "## Completed Tasks", section+"\n\n## Completed Tasks", 1)
+		} else {
+			progressMd = strings.TrimRight(progressMd, "\n") + "\n\n" + section + "\n\n"
+		}
+	}
+
+	lines := strings.Split(progressMd, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != section {
+			continue
+		}
+		rest := lines[i+1:]
+		if len(rest) > 0 && strings.TrimSpace(rest[0]) == "" {
+			rest = rest[1:]
+		}
+		result := append([]string{}, lines[:i+1]...)
+		result = append(result, "", entry)
+		if len(rest) > 0 && strings.HasPrefix(strings.TrimSpace(rest[0]), "## ") {
+			result = append(result, "")
+		}
+		result = append(result, rest...)
+		return strings.Join(result, "\n")
+	}
+	return progressMd
+}
+
+// hasSection reports whether progress.md has a "## " section line
+func hasSection(progressMd string, section string) bool {
+	for _, line := range strings.Split(progressMd, "\n") {
+		if strings.TrimSpace(line) == section {
+			return true
+		}
+	}
+	return false
+}
+
+// UnblockTask removes a task from the "## Blocked" and "## Failed" sections
+// so it is treated as pending again
+func UnblockTask(progressMd string, taskTitle string) string {
+	return removeEntries(progressMd, taskTitle, "## Blocked", "## Failed")
+}
+
+// removeProgressEntry drops a task's in-progress, blocked or failed entry
+func removeProgressEntry(progressMd string, taskTitle string) string {
+	return removeEntries(progressMd, taskTitle, "## In Progress", "## Blocked", "## Failed")
+}
+
+// ReopenTask moves a completed, blocked or failed task back to
+// "## In Progress", so it is resumed even though it was completed before
+func ReopenTask(progressMd string, taskTitle string) string {
+	progressMd = removeEntries(progressMd, taskTitle, "## In Progress", "## Blocked", "## Failed", "## Completed Tasks")
+	return MarkTaskInProgress(progressMd, taskTitle)
+}
+
+// ReleaseTask drops a task's in-progress entries so it is pending again,
+// for a run that was stopped before its agent finished
+func ReleaseTask(progressMd string, taskTitle string) string {
+	return removeEntries(progressMd, taskTitle, "## In Progress")
+}
+
+// removeEntries drops a task's entries from the given sections
+func removeEntries(progressMd string, taskTitle string, sections ...string) string {
+	lines := unwrapEntries(strings.Split(progressMd, "\n"))
+	var result []string
+	section := ""
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "## ") {
+			section = trimmed
+		}
+		if slices.Contains(sections, section) && progressLineTitle(line, taskTitle) == taskTitle {
+			continue
+		}
+		result = append(result, line)
+	}
+	return strings.Join(result, "\n")
+}
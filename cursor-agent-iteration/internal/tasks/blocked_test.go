@@ -0,0 +1,118 @@
+package tasks
+
+import (
+	"strings"
+	"testing"
+)
+
+const blockedProgress = `# Progress Log
+
+## In Progress
+
+- 🔄 [2025-01-08 19:00] Task A
+- 🔄 [2025-01-08 19:05] Task B
+
+## Completed Tasks
+
+- ✅ [2025-01-08 18:00] Task C
+`
+
+func TestMarkTaskBlocked(t *testing.T) {
+	updated := MarkTaskBlocked(blockedProgress, "Task A", "needs schema decision")
+
+	if !IsTaskBlocked(updated, "Task A") {
+		t.Fatalf("Expected Task A to be blocked:\n%s", updated)
+	}
+	if IsTaskInProgress(updated, "Task A") {
+		t.Errorf("Expected Task A to leave In Progress")
+	}
+	if !IsTaskInProgress(updated, "Task B") || !IsTaskCompleted(updated, "Task C") {
+		t.Errorf("Expected other tasks to keep their status:\n%s", updated)
+	}
+	if entry := ParseProgress(updated)["Task A"]; entry.Notes != "needs schema decision" {
+		t.Errorf("Blocked reason = %q", entry.Notes)
+	}
+	if !strings.Contains(updated, "## Blocked\n\n- ⛔ [") || !strings.Contains(updated, "needs schema decision\n\n## Completed Tasks") {
+		t.Errorf("Unexpected layout:\n%s", updated)
+	}
+
+	// Blocking a second task keeps the first
+	updated = MarkTaskBlocked(updated, "Task B", "")
+	if got := GetBlockedTasks(updated); len(got) != 2 {
+		t.Errorf("Expected two blocked tasks, got %v", got)
+	}
+}
+
+func TestBlockedTasksAreNotScheduled(t *testing.T) {
+	tasksMd := "## Current Tasks\n\n### Task: Task A\n\n### Task: Task D\n"
+	progress := MarkTaskBlocked(blockedProgress, "Task A", "")
+
+	if next := GetNextPendingTaskWithProgress(tasksMd, progress); next == nil || next.Title != "Task D" {
+		t.Errorf("Expected Task D to be next, got %+v", next)
+	}
+	for _, task := range GetAllInProgressTasks(tasksMd, progress) {
+		if task.Title == "Task A" {
+			t.Errorf("Expected blocked task not to be resumed")
+		}
+	}
+	if !strings.Contains(StatusReportWithProgress(tasksMd, progress), "⛔ Blocked: 1") {
+		t.Errorf("Expected blocked count in status report")
+	}
+}
+
+func TestUnblockTask(t *testing.T) {
+	progress := MarkTaskBlocked(blockedProgress, "Task A", "waiting")
+	progress = UnblockTask(progress, "Task A")
+
+	if _, exists := ParseProgress(progress)["Task A"]; exists {
+		t.Errorf("Expected Task A to be pending after unblocking:\n%s", progress)
+	}
+	if !IsTaskInProgress(progress, "Task B") {
+		t.Errorf("Expected Task B untouched")
+	}
+}
+
+func TestReopenTask(t *testing.T) {
+	progress := ReopenTask(blockedProgress, "Task C")
+	if !IsTaskInProgress(progress, "Task C") || IsTaskCompleted(progress, "Task C") {
+		t.Errorf("Expected Task C back in progress:\n%s", progress)
+	}
+	if strings.Count(progress, "Task C") != 1 {
+		t.Errorf("Expected a single entry for Task C:\n%s", progress)
+	}
+
+	progress = ReopenTask(MarkTaskBlocked(blockedProgress, "Task A", "needs sign-off"), "Task A")
+	if !IsTaskInProgress(progress, "Task A") || IsTaskBlocked(progress, "Task A") {
+		t.Errorf("Expected Task A back in progress:\n%s", progress)
+	}
+	if !IsTaskInProgress(progress, "Task B") || !IsTaskCompleted(progress, "Task C") {
+		t.Errorf("Expected other tasks untouched:\n%s", progress)
+	}
+}
+
+func TestReleaseTask(t *testing.T) {
+	progress := ReleaseTask(MarkTaskInProgress(blockedProgress, "Task A"), "Task A")
+	if strings.Contains(progress, "Task A") {
+		t.Errorf("Expected every in-progress entry of Task A dropped:\n%s", progress)
+	}
+	if !IsTaskInProgress(progress, "Task B") || !IsTaskCompleted(progress, "Task C") {
+		t.Errorf("Expected other tasks untouched:\n%s", progress)
+	}
+	if got := ReleaseTask(blockedProgress, "Task C"); got != blockedProgress {
+		t.Errorf("Expected a completed task to stay completed:\n%s", got)
+	}
+}
+
+func TestOnlyBlockedRemain(t *testing.T) {
+	tasksMd := "## Current Tasks\n\n### Task: Task A\n\n### Task: Task C\n"
+	progress := MarkTaskBlocked(blockedProgress, "Task A", "")
+	if !OnlyBlockedRemain(tasksMd, progress) {
+		t.Errorf("Expected only blocked tasks to remain")
+	}
+	if OnlyBlockedRemain(tasksMd+"\n### Task: Task D\n", progress) {
+		t.Errorf("Expected pending Task D to count as remaining work")
+	}
+	if OnlyBlockedRemain(tasksMd, blockedProgress) {
+		t.Errorf("Expected in-progress Task A to count as remaining work")
+	}
+}
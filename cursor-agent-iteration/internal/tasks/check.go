@@ -78,8 +78,17 @@ type ValidationResult struct {
 	Warnings []string
 }
 
+// DefaultMaxTaskLines is the task block size above which validation warns
+const DefaultMaxTaskLines = 150
+
 // ValidateTasksStructure validates that tasks.md has the correct structure
 func ValidateTasksStructure(md string) ValidationResult {
+	return ValidateTasksStructureWithLimit(md, DefaultMaxTaskLines)
+}
+
+// ValidateTasksStructureWithLimit validates tasks.md, warning about task
+// blocks longer than maxTaskLines. A limit of 0 disables the warning.
+func ValidateTasksStructureWithLimit(md string, maxTaskLines int) ValidationResult {
 	result := ValidationResult{Valid: true, Errors: []string{}, Warnings: []string{}}
 
 	lines := strings.Split(md, "\n")
@@ -113,6 +122,14 @@ func ValidateTasksStructure(md string) ValidationResult {
 			continue
 		}
 
+		// Any other priority would quietly be scheduled as the default
+		if m := rePriorityLine.FindStringSubmatch(line); m != nil {
+			if _, ok := ParsePriority(m[1]); !ok {
+				result.Errors = append(result.Errors, fmt.Sprintf("Line %d: Invalid priority %q; use P0, P1 or P2", i+1, strings.TrimSpace(m[1])))
+				result.Valid = false
+			}
+		}
+
 		// Check for task headers
 		if taskHeaderRegex.MatchString(line) {
 			taskCount++
@@ -130,6 +147,9 @@ func ValidateTasksStructure(md string) ValidationResult {
 				result.Errors = append(result.Errors, fmt.Sprintf("Line %d: Task '%s' is missing required structure (Context, Acceptance Criteria, or checkbox items)", i+1, taskTitle))
 				result.Valid = false
 			}
+			if size := taskBlockSize(lines, i+1); maxTaskLines > 0 && size > maxTaskLines {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("Line %d: Task '%s' is %d lines long (limit %d); consider splitting it or moving context into docs", i+1, taskTitle, size, maxTaskLines))
+			}
 		}
 	}
 
@@ -144,24 +164,43 @@ func ValidateTasksStructure(md string) ValidationResult {
 		result.Warnings = append(result.Warnings, "No tasks found in Current Tasks section")
 	}
 
+	// Tasks in a dependency cycle wait on each other forever
+	for _, cycle := range NewDependencyGraph(parseTasks(md)).Cycles() {
+		result.Errors = append(result.Errors, fmt.Sprintf("Dependency cycle: %s; these tasks can never start", FormatCycle(cycle)))
+		result.Valid = false
+	}
+
 	return result
 }
 
-// validateTaskStructure checks if a task has the required structure
+// isBlockHeader reports whether a line starts the next task or section
+func isBlockHeader(line string) bool {
+	return strings.HasPrefix(line, "### ") || strings.HasPrefix(line, "## ")
+}
+
+// taskBlockSize counts the lines of the task body starting at startLine, up
+// to the next task or section header and without trailing blank lines
+func taskBlockSize(lines []string, startLine int) int {
+	size := 0
+	for i := startLine; i < len(lines) && !isBlockHeader(lines[i]); i++ {
+		if strings.TrimSpace(lines[i]) != "" {
+			size = i - startLine + 1
+		}
+	}
+	return size
+}
+
+// validateTaskStructure checks if a task has the required structure. The
+// whole task body is scanned, up to the next task or section header, so
+// tasks with long context validate like short ones.
 func validateTaskStructure(lines []string, startLine int, contextRegex, acceptanceCriteriaRegex, checkboxRegex *regexp.Regexp) bool {
 	hasContext := false
 	hasAcceptanceCriteria := false
 	hasCheckboxes := false
 
-	// Look at the next 20 lines for task structure
-	for i := startLine; i < len(lines) && i < startLine+20; i++ {
+	for i := startLine; i < len(lines) && !isBlockHeader(lines[i]); i++ {
 		line := lines[i]
 
-		// Stop if we hit another task or section
-		if strings.HasPrefix(line, "### ") || strings.HasPrefix(line, "## ") {
-			break
-		}
-
 		if contextRegex.MatchString(line) {
 			hasContext = true
 		}
@@ -180,7 +219,13 @@ func validateTaskStructure(lines []string, startLine int, contextRegex, acceptan
 
 // ValidateAndFixTasksStructure validates and attempts to fix common structure issues
 func ValidateAndFixTasksStructure(md string) (string, ValidationResult) {
-	result := ValidateTasksStructure(md)
+	return ValidateAndFixTasksStructureWithLimit(md, DefaultMaxTaskLines)
+}
+
+// ValidateAndFixTasksStructureWithLimit is ValidateAndFixTasksStructure with
+// a custom task block size limit
+func ValidateAndFixTasksStructureWithLimit(md string, maxTaskLines int) (string, ValidationResult) {
+	result := ValidateTasksStructureWithLimit(md, maxTaskLines)
 
 	if result.Valid {
 		return md, result
@@ -472,3 +472,38 @@ func TestValidationResult(t *testing.T) {
 		t.Errorf("Expected 1 warning, got %d", len(result.Warnings))
 	}
 }
+
+func TestValidateTasksStructureWithLimit(t *testing.T) {
+	longTask := "## Current Tasks\n\n### Task: Long Task\n**Context:**\n" +
+		strings.Repeat("More background on the task.\n", 40) +
+		"\n**Acceptance Criteria:**\n- [ ] It works\n\n### Task: Short Task\n**Context:**\nShort.\n\n**Acceptance Criteria:**\n- [ ] Done\n"
+
+	tests := []struct {
+		name         string
+		maxTaskLines int
+		warningCount int
+	}{
+		{"under limit", DefaultMaxTaskLines, 0},
+		{"over limit", 20, 1},
+		{"disabled", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ValidateTasksStructureWithLimit(longTask, tt.maxTaskLines)
+			if !result.Valid || len(result.Errors) != 0 {
+				t.Errorf("Expected tasks with long context to be valid, got %v", result.Errors)
+			}
+			if len(result.Warnings) != tt.warningCount {
+				t.Errorf("Warnings = %v, want %d", result.Warnings, tt.warningCount)
+			}
+			if tt.warningCount > 0 && !strings.Contains(result.Warnings[0], "'Long Task' is 44 lines long (limit 20)") {
+				t.Errorf("Unexpected warning %q", result.Warnings[0])
+			}
+		})
+	}
+
+	fixed, result := ValidateAndFixTasksStructureWithLimit(longTask, 20)
+	if fixed != longTask || !result.Valid || len(result.Warnings) != 1 {
+		t.Errorf("ValidateAndFixTasksStructureWithLimit() = %v", result)
+	}
+}
@@ -0,0 +1,39 @@
+package tasks
+
+import (
+	"sort"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/scope"
+)
+
+// Claims are the files running tasks may change, by task title, from their
+// "**Files to Modify:**" lines. Tasks whose claims overlap would likely
+// conflict when their changes are merged, so they don't run at the same
+// time.
+type Claims map[string][]string
+
+// Conflict returns the first claiming task other than title, in title
+// order, that claims a file of files, or "" when there is none. A task
+// without a file list claims nothing and conflicts with nothing.
+func (c Claims) Conflict(title string, files []string) string {
+	if len(files) == 0 {
+		return ""
+	}
+	titles := make([]string, 0, len(c))
+	for t := range c {
+		if t != title {
+			titles = append(titles, t)
+		}
+	}
+	sort.Strings(titles)
+	for _, t := range titles {
+		for _, claimed := range c[t] {
+			for _, f := range files {
+				if scope.Overlaps(claimed, f) {
+					return t
+				}
+			}
+		}
+	}
+	return ""
+}
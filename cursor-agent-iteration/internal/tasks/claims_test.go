@@ -0,0 +1,57 @@
+package tasks
+
+import "testing"
+
+const claimTasks = `# Tasks
+
+## Current Tasks
+
+### Task: Login form
+**Files to Modify:** ` + "`src/auth/login.go`, `src/auth/login_test.go`" + `
+
+### Task: Session cookies
+**Files to Modify:** ` + "`src/auth/...`" + `
+
+### Task: Search index
+**Files to Modify:** ` + "`src/search/*.go`" + `
+
+### Task: Docs
+`
+
+func TestClaims(t *testing.T) {
+	all := ParseTasks(claimTasks)
+	if got := all[1].FileScope; len(got) != 1 || got[0] != "src/auth/" {
+		t.Fatalf("FileScope = %v", got)
+	}
+	if got := all[2].Files; len(got) != 0 {
+		t.Errorf("Expected globs to stay out of Files, got %v", got)
+	}
+
+	claims := Claims{"Login form": all[0].FileScope, "Docs": nil}
+	for _, tt := range []struct {
+		task Task
+		want string
+	}{
+		{all[1], "Login form"},
+		{all[2], ""},
+		{all[3], ""},
+		{all[0], ""}, // a task doesn't conflict with its own claim
+	} {
+		if got := claims.Conflict(tt.task.Title, tt.task.FileScope); got != tt.want {
+			t.Errorf("Conflict(%q) = %q, want %q", tt.task.Title, got, tt.want)
+		}
+	}
+
+	// The picker passes over tasks that conflict with running ones
+	progress := MarkTaskInProgress("", "Login form")
+	if next := (Fairness{}).NextPendingTask(claimTasks, progress, []string{"Login form"}, 4, claims); next == nil || next.Title != "Search index" {
+		t.Errorf("Expected Search index to start next, got %+v", next)
+	}
+	interleave := Fairness{Policy: FairnessInterleave}
+	if next := interleave.NextPendingTask(claimTasks, progress, []string{"Login form"}, 4, claims); next == nil || next.Title != "Search index" {
+		t.Errorf("Expected interleaving to honour claims, got %+v", next)
+	}
+	if next := (Fairness{}).NextPendingTask(claimTasks, progress, []string{"Login form"}, 4, nil); next == nil || next.Title != "Session cookies" {
+		t.Errorf("Expected Session cookies without claims, got %+v", next)
+	}
+}
@@ -0,0 +1,358 @@
+package tasks
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/scope"
+)
+
+// ClusterOptions limits the clusters SuggestClusters proposes
+type ClusterOptions struct {
+	// MaxCriteria is the most acceptance criteria a task may have to be
+	// combined with others
+	MaxCriteria int
+	// MaxSize is the most tasks one cluster combines
+	MaxSize int
+	// Defaults are the label defaults merged into every task, e.g. an
+	// implied [exclusive:true] keeps a task out of clusters
+	Defaults LabelDefaults
+}
+
+// Cluster is pending tasks small and related enough to be done in a single
+// agent run, which saves the setup every run pays for
+type Cluster struct {
+	Titles []string
+	// Reasons say why the tasks belong together, e.g. "both change
+	// api/login.go"
+	Reasons []string
+}
+
+// Title is the title of the task combining the cluster
+func (c Cluster) Title() string {
+	return strings.Join(c.Titles, " + ")
+}
+
+// unrelatedLabelKeys are label keys that don't make tasks related: they are
+// shared by unrelated tasks, or keep a task from being combined at all
+var unrelatedLabelKeys = map[string]bool{"milestone": true, "type": true, "priority": true, "model": true, LabelExclusive: true, "source": true}
+
+// clusterable reports whether a task may be combined with others: it is
+// small, not exclusive, and not imported from a tracker whose sync follows
+// it by title
+func clusterable(t Task, opts ClusterOptions) bool {
+	if t.ACTotal == 0 || t.ACTotal > opts.MaxCriteria {
+		return false
+	}
+	if v, _ := LabelValue(t.Labels, LabelExclusive); strings.EqualFold(v, "true") {
+		return false
+	}
+	_, imported := LabelValue(t.Labels, "source")
+	return !imported
+}
+
+// compatible reports whether two tasks may share a run: the same milestone
+// and the same model
+func compatible(a, b Task) bool {
+	modelA, _ := LabelValue(a.Labels, "model")
+	modelB, _ := LabelValue(b.Labels, "model")
+	return strings.EqualFold(TaskMilestone(a), TaskMilestone(b)) && strings.EqualFold(modelA, modelB)
+}
+
+// relation says why two tasks are related, or "" when they aren't: they
+// change the same files, or share a label such as area:auth
+func relation(a, b Task) string {
+	for _, fa := range a.FileScope {
+		for _, fb := range b.FileScope {
+			if scope.Overlaps(fa, fb) {
+				if len(fb) > len(fa) {
+					fa = fb
+				}
+				return "both change " + fa
+			}
+		}
+	}
+	for _, la := range a.Labels {
+		if unrelatedLabelKeys[labelKey(la)] {
+			continue
+		}
+		for _, lb := range b.Labels {
+			if normalizeLabel(la) == normalizeLabel(lb) {
+				return "both labeled " + la
+			}
+		}
+	}
+	return ""
+}
+
+// SuggestClusters groups the pending tasks of tasksMd, those without an
+// entry in progressMd, into clusters of up to opts.MaxSize related tasks,
+// in tasks.md order. Tasks join the first cluster they are related to and
+// compatible with; a cluster whose combined task would wait on a task that
+// waits on one of its own isn't formed. Only clusters of two or more tasks
+// are returned.
+func SuggestClusters(tasksMd, progressMd string, opts ClusterOptions) []Cluster {
+	all := parseTasksN(tasksMd, -1, opts.Defaults)
+	entries := ParseProgressFor(progressMd, taskTitles(all))
+	deps := dependencyMap(all)
+
+	type group struct {
+		tasks   []Task
+		reasons []string
+	}
+	var groups []*group
+	for _, t := range all {
+		if _, started := entries[t.Title]; started || !clusterable(t, opts) {
+			continue
+		}
+		var joined *group
+		for _, g := range groups {
+			if len(g.tasks) >= opts.MaxSize || !compatible(g.tasks[0], t) {
+				continue
+			}
+			reason := ""
+			for _, m := range g.tasks {
+				if reason = relation(m, t); reason != "" {
+					break
+				}
+			}
+			if reason == "" || waitsOnItself(append(append([]Task{}, g.tasks...), t), deps) {
+				continue
+			}
+			g.tasks = append(g.tasks, t)
+			if !containsFold(g.reasons, reason) {
+				g.reasons = append(g.reasons, reason)
+			}
+			joined = g
+			break
+		}
+		if joined == nil {
+			groups = append(groups, &group{tasks: []Task{t}})
+		}
+	}
+
+	var clusters []Cluster
+	for _, g := range groups {
+		if len(g.tasks) < 2 {
+			continue
+		}
+		c := Cluster{Reasons: g.reasons}
+		for _, t := range g.tasks {
+			c.Titles = append(c.Titles, t.Title)
+		}
+		clusters = append(clusters, c)
+	}
+	return clusters
+}
+
+// dependencyMap maps each task, lower-cased, to its dependencies,
+// lower-cased
+func dependencyMap(all []Task) map[string][]string {
+	deps := make(map[string][]string, len(all))
+	for _, t := range all {
+		for _, d := range t.Dependencies {
+			deps[strings.ToLower(t.Title)] = append(deps[strings.ToLower(t.Title)], strings.ToLower(d))
+		}
+	}
+	return deps
+}
+
+// waitsOnItself reports whether combining members would make a cycle: one
+// of them depends, through other tasks, on a task that depends on a member
+func waitsOnItself(members []Task, deps map[string][]string) bool {
+	inside := make(map[string]bool, len(members))
+	for _, m := range members {
+		inside[strings.ToLower(m.Title)] = true
+	}
+	seen := make(map[string]bool)
+	var reaches func(title string) bool
+	reaches = func(title string) bool {
+		if seen[title] {
+			return false
+		}
+		seen[title] = true
+		for _, d := range deps[title] {
+			if inside[d] || reaches(d) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, m := range members {
+		for _, d := range deps[strings.ToLower(m.Title)] {
+			if !inside[d] && reaches(d) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsFold reports whether list holds s, ignoring case
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClusterDraft is the task combining a cluster of tasksMd: its context
+// lists each task's context, and its criteria are theirs, each starting
+// with the task it comes from. Files, labels and the dependencies on
+// other tasks are merged; where tasks set a label key differently, the
+// first task's value is kept.
+func ClusterDraft(tasksMd string, c Cluster) (Draft, error) {
+	lines := strings.Split(tasksMd, "\n")
+	blocks, _ := currentTaskBlocks(lines)
+	byTitle := make(map[string]Task)
+	for _, t := range parseTasks(tasksMd) {
+		byTitle[cleanTaskTitle(t.Title)] = t
+	}
+	members := make(map[string]bool, len(c.Titles))
+	for _, title := range c.Titles {
+		members[strings.ToLower(cleanTaskTitle(title))] = true
+	}
+
+	d := Draft{Title: c.Title()}
+	context := []string{fmt.Sprintf("Combines %d small related tasks into one run", len(c.Titles))}
+	if len(c.Reasons) > 0 {
+		context[0] += " (" + strings.Join(c.Reasons, "; ") + ")"
+	}
+	context[0] += ". Do every part; each criterion starts with the task it comes from."
+	labelKeys := make(map[string]bool)
+	for _, title := range c.Titles {
+		t, ok := byTitle[cleanTaskTitle(title)]
+		if !ok {
+			return Draft{}, fmt.Errorf("no task %q in Current Tasks", title)
+		}
+		if t.Context != "" {
+			context = append(context, fmt.Sprintf("- **%s:** %s", t.Title, t.Context))
+		}
+		for _, cr := range t.Criteria {
+			text := t.Title + ": " + cr.Text
+			if cr.Category != CategoryFunctional {
+				text = "[" + cr.Category + "] " + text
+			}
+			d.Criteria = append(d.Criteria, text)
+		}
+		for _, f := range t.FileScope {
+			if !containsFold(d.Files, f) {
+				d.Files = append(d.Files, f)
+			}
+		}
+		for _, dep := range t.Dependencies {
+			if !members[strings.ToLower(dep)] && !containsFold(d.Dependencies, dep) {
+				d.Dependencies = append(d.Dependencies, dep)
+			}
+		}
+		for _, b := range blocks {
+			if b.title != cleanTaskTitle(title) {
+				continue
+			}
+			for _, label := range rawLabels(lines[b.start:b.end]) {
+				if key := labelKey(label); !labelKeys[key] {
+					labelKeys[key] = true
+					d.Labels = append(d.Labels, label)
+				}
+			}
+		}
+	}
+	d.Context = strings.Join(context, "\n")
+	return d, d.Check()
+}
+
+// rawLabels returns the labels written on the "**Labels:**" line of a task
+// block, without those the label defaults imply
+func rawLabels(block []string) []string {
+	var labels []string
+	for _, line := range block {
+		m := reLabelsLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		for _, l := range reLabel.FindAllStringSubmatch(m[1], -1) {
+			if label := strings.TrimSpace(l[1]); label != "" {
+				labels = append(labels, label)
+			}
+		}
+		break
+	}
+	return labels
+}
+
+// ApplyCluster adds the task combining a cluster to tasksMd, right before
+// the first of its tasks, and points the dependencies of other tasks on
+// them at it. The tasks themselves are left for the caller to remove.
+func ApplyCluster(tasksMd string, c Cluster) (string, error) {
+	d, err := ClusterDraft(tasksMd, c)
+	if err != nil {
+		return tasksMd, err
+	}
+	lines := strings.Split(tasksMd, "\n")
+	blocks, _ := currentTaskBlocks(lines)
+	first := -1
+	for _, b := range blocks {
+		if strings.EqualFold(b.title, cleanTaskTitle(d.Title)) {
+			return tasksMd, fmt.Errorf("%q already has a task", d.Title)
+		}
+		if first < 0 && containsFold(c.Titles, b.title) {
+			first = b.start
+		}
+	}
+	if first < 0 {
+		return tasksMd, fmt.Errorf("no task %q in Current Tasks", c.Titles[0])
+	}
+	out := append(append([]string{}, lines[:first]...), strings.Split(d.Block(), "\n")...)
+	out = append(append(out, ""), lines[first:]...)
+
+	renames := make(map[string]string, len(c.Titles))
+	for _, title := range c.Titles {
+		renames[strings.ToLower(cleanTaskTitle(title))] = d.Title
+	}
+	return renameDependencies(strings.Join(out, "\n"), renames), nil
+}
+
+// reDroppedDependency is a dependency dropped by renameDependencies, with
+// the comma that separates it from the next or the previous one
+var reDroppedDependency = regexp.MustCompile("\x00\\s*,\\s*|\\s*,?\\s*\x00")
+
+// renameDependencies points the "**Dependencies:**" lines of the tasks at
+// new titles, by lower-cased old title. A line that ends up naming a task
+// twice names it once.
+func renameDependencies(tasksMd string, renames map[string]string) string {
+	lines := strings.Split(tasksMd, "\n")
+	for i, line := range lines {
+		m := reDependenciesLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		seen := make(map[string]bool)
+		rename := func(item string, quote string) string {
+			name := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(strings.Trim(item, "`")), "Task:"))
+			if title, ok := renames[strings.ToLower(name)]; ok {
+				lead := item[:len(item)-len(strings.TrimLeft(item, " "))]
+				name, item = title, lead+quote+title+quote
+			}
+			if seen[strings.ToLower(name)] {
+				return "\x00"
+			}
+			seen[strings.ToLower(name)] = true
+			return item
+		}
+		var items string
+		if reBackticked.MatchString(m[1]) {
+			items = reBackticked.ReplaceAllStringFunc(m[1], func(q string) string { return rename(q, "`") })
+		} else {
+			parts := strings.Split(m[1], ",")
+			for j, part := range parts {
+				parts[j] = rename(part, "")
+			}
+			items = strings.Join(parts, ",")
+		}
+		lines[i] = line[:len(line)-len(m[1])] + strings.TrimSpace(reDroppedDependency.ReplaceAllString(items, ""))
+	}
+	return strings.Join(lines, "\n")
+}
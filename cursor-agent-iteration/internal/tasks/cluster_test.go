@@ -0,0 +1,170 @@
+package tasks
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const clusterTasksMd = `# Tasks
+
+## Current Tasks
+
+### Task: Rate limit login
+**Context:** Brute-force attempts show up in the logs.
+**Acceptance Criteria:**
+* [ ] Logins are rate limited
+**Files to Modify:** ` + "`api/login.go`" + `
+**Labels:** [milestone:auth] [priority:high]
+
+### Task: Signup form
+**Context:** New users need an account.
+**Acceptance Criteria:**
+* [ ] Form exists
+* [ ] Form validates
+**Files to Modify:** web/signup.tsx
+**Labels:** [milestone:auth]
+
+### Task: Lock accounts
+**Context:** After repeated failures.
+**Acceptance Criteria:**
+* [ ] Locks after 5 failures
+* [ ] [docs] Lockout documented
+**Files to Modify:** api/login.go, api/accounts.go
+**Labels:** [milestone:auth] [priority:low] [area:login]
+**Dependencies:** ` + "`Add user model`" + `
+
+### Task: Log failed logins
+**Context:** For the audit trail.
+**Acceptance Criteria:**
+* [ ] Failures are logged
+**Files to Modify:** api/audit.go
+**Labels:** [milestone:auth] [area:login]
+
+### Task: Search index
+**Context:** Faster search.
+**Acceptance Criteria:**
+* [ ] Index built
+**Files to Modify:** api/login.go
+**Labels:** [milestone:search]
+
+### Task: Big refactor
+**Context:** Untangle the login code.
+**Acceptance Criteria:**
+* [ ] a
+* [ ] b
+* [ ] c
+* [ ] d
+**Files to Modify:** api/login.go
+**Labels:** [milestone:auth]
+
+### Task: Session audit
+**Context:** Audit sessions.
+**Acceptance Criteria:**
+* [ ] Sessions audited
+**Files to Modify:** api/audit.go
+**Labels:** [milestone:auth]
+**Dependencies:** ` + "`Lock accounts`, `Rate limit login`" + `, ADR-012
+
+### Task: Add user model
+**Context:** Store users.
+**Acceptance Criteria:**
+* [ ] Model exists
+**Files to Modify:** api/user.go
+`
+
+func TestSuggestClusters(t *testing.T) {
+	opts := ClusterOptions{MaxCriteria: 3, MaxSize: 3}
+	got := SuggestClusters(clusterTasksMd, "", opts)
+	want := []Cluster{{
+		Titles:  []string{"Rate limit login", "Lock accounts", "Log failed logins"},
+		Reasons: []string{"both change api/login.go", "both labeled area:login"},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("SuggestClusters() = %+v, want %+v", got, want)
+	}
+
+	// Started tasks stay as they are
+	progress := "## In Progress\n\n- 🔄 [2025-01-08 10:00] Log failed logins\n"
+	got = SuggestClusters(clusterTasksMd, progress, opts)
+	if len(got) != 1 || !reflect.DeepEqual(got[0].Titles, []string{"Rate limit login", "Lock accounts"}) {
+		t.Errorf("Expected the started task left out, got %+v", got)
+	}
+	// Add user model waiting on Rate limit login would make the combined
+	// task wait on itself
+	cyclic := strings.Replace(clusterTasksMd, "**Files to Modify:** api/user.go", "**Files to Modify:** api/user.go\n**Dependencies:** `Rate limit login`", 1)
+	for _, c := range SuggestClusters(cyclic, "", opts) {
+		if containsFold(c.Titles, "Rate limit login") && containsFold(c.Titles, "Lock accounts") {
+			t.Errorf("Expected no cluster that would wait on itself, got %+v", c)
+		}
+	}
+
+	got = SuggestClusters(clusterTasksMd, "", ClusterOptions{MaxCriteria: 3, MaxSize: 2})
+	if len(got) != 2 || !reflect.DeepEqual(got[1].Titles, []string{"Log failed logins", "Session audit"}) {
+		t.Errorf("Expected clusters of at most 2 tasks, got %+v", got)
+	}
+}
+
+func TestApplyCluster(t *testing.T) {
+	c := SuggestClusters(clusterTasksMd, "", ClusterOptions{MaxCriteria: 3, MaxSize: 3})[0]
+	md, err := ApplyCluster(clusterTasksMd, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := ValidateTasksStructure(md); !result.Valid {
+		t.Fatalf("Expected a valid tasks.md, got %v:\n%s", result.Errors, md)
+	}
+	all := ParseTasks(md)
+	if all[0].Title != c.Title() || all[1].Title != "Rate limit login" {
+		t.Fatalf("Expected the combined task before its first task, got %q and %q", all[0].Title, all[1].Title)
+	}
+	combined := all[0]
+	wantCriteria := []string{"Rate limit login: Logins are rate limited", "Lock accounts: Locks after 5 failures", "Lock accounts: Lockout documented", "Log failed logins: Failures are logged"}
+	var criteria []string
+	for _, cr := range combined.Criteria {
+		criteria = append(criteria, cr.Text)
+	}
+	if !reflect.DeepEqual(criteria, wantCriteria) || combined.Criteria[2].Category != "docs" {
+		t.Errorf("Criteria = %+v", combined.Criteria)
+	}
+	if !reflect.DeepEqual(combined.Files, []string{"api/login.go", "api/accounts.go", "api/audit.go"}) {
+		t.Errorf("Files = %q", combined.Files)
+	}
+	if !reflect.DeepEqual(combined.Labels, []string{"milestone:auth", "priority:high", "area:login"}) {
+		t.Errorf("Labels = %q", combined.Labels)
+	}
+	if !reflect.DeepEqual(combined.Dependencies, []string{"Add user model"}) {
+		t.Errorf("Dependencies = %q", combined.Dependencies)
+	}
+	details := ExtractTaskDetails(md, c.Title())
+	for _, want := range []string{"(both change api/login.go; both labeled area:login)", "- **Lock accounts:** After repeated failures."} {
+		if !strings.Contains(details, want) {
+			t.Errorf("Expected %q in the combined task:\n%s", want, details)
+		}
+	}
+	if !strings.Contains(md, "**Dependencies:** `"+c.Title()+"`, ADR-012\n") {
+		t.Errorf("Expected dependencies on the tasks to name the combined task once:\n%s", md)
+	}
+
+	if _, err := ApplyCluster(md, c); err == nil {
+		t.Error("Expected a cluster that was already combined to be refused")
+	}
+	if _, err := ApplyCluster(clusterTasksMd, Cluster{Titles: []string{"Rate limit login", "Missing"}}); err == nil {
+		t.Error("Expected a cluster naming a missing task to be refused")
+	}
+}
+
+func TestRenameDependencies(t *testing.T) {
+	renames := map[string]string{"a": "A + B", "b": "A + B"}
+	for in, want := range map[string]string{
+		"**Dependencies:** `B`, `C`, `a`":  "**Dependencies:** `A + B`, `C`",
+		"**Dependencies:** `C`, `a`, `B`":  "**Dependencies:** `C`, `A + B`",
+		"**Dependencies:** A, B":           "**Dependencies:** A + B",
+		"**Dependencies:** None":           "**Dependencies:** None",
+		"**Dependencies:** `Task: B`, X-1": "**Dependencies:** `A + B`, X-1",
+	} {
+		if got := renameDependencies(in, renames); got != want {
+			t.Errorf("renameDependencies(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package tasks
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// CategoryFunctional is the category of criteria without a category tag
+const CategoryFunctional = "functional"
+
+// reCriterionTag matches an optional leading category tag such as "[docs]"
+// right after the checkbox of an acceptance criterion
+var reCriterionTag = regexp.MustCompile(`^[*-] \[( |x|X)\]\s*\[([A-Za-z][A-Za-z0-9_-]*)\]\s*(.*)$`)
+
+// categoryAliases maps alternative spellings to a canonical category name
+var categoryAliases = map[string]string{
+	"test":        "tests",
+	"doc":         "docs",
+	"performance": "perf",
+	"func":        CategoryFunctional,
+}
+
+// Criterion is a single acceptance criterion of a task
+type Criterion struct {
+	Text     string
+	Checked  bool
+	Category string // "functional" unless tagged, e.g. "* [ ] [docs] Update README"
+}
+
+// parseCriterion parses an acceptance criterion checklist line
+func parseCriterion(line string) Criterion {
+	c := Criterion{
+		Checked:  reACChecked.MatchString(line),
+		Category: CategoryFunctional,
+	}
+	if m := reCriterionTag.FindStringSubmatch(line); m != nil {
+		c.Category = NormalizeCategory(m[2])
+		c.Text = strings.TrimSpace(m[3])
+		return c
+	}
+	c.Text = strings.TrimSpace(reACItem.ReplaceAllString(line, ""))
+	return c
+}
+
+// NormalizeCategory lower-cases a category and resolves aliases
+func NormalizeCategory(category string) string {
+	category = strings.ToLower(strings.TrimSpace(category))
+	if alias, ok := categoryAliases[category]; ok {
+		return alias
+	}
+	return category
+}
+
+// CompletionPolicy adjusts which criteria must be checked for a task to
+// count as done
+type CompletionPolicy struct {
+	// Deferred categories may stay unchecked without blocking completion
+	Deferred []string
+}
+
+// ParseCompletionPolicy builds a policy from a comma-separated list of
+// deferrable categories, e.g. "docs,perf"
+func ParseCompletionPolicy(deferred string) CompletionPolicy {
+	var p CompletionPolicy
+	for _, c := range strings.Split(deferred, ",") {
+		if c = NormalizeCategory(c); c != "" {
+			p.Deferred = append(p.Deferred, c)
+		}
+	}
+	return p
+}
+
+// IsDeferred reports whether criteria of the category may be left unchecked
+func (p CompletionPolicy) IsDeferred(category string) bool {
+	category = NormalizeCategory(category)
+	for _, c := range p.Deferred {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// RequiredCriteria counts the checked and total criteria of a task that the
+// policy does not defer
+func (t Task) RequiredCriteria(p CompletionPolicy) (checked int, total int) {
+	for _, c := range t.Criteria {
+		if p.IsDeferred(c.Category) {
+			continue
+		}
+		total++
+		if c.Checked {
+			checked++
+		}
+	}
+	return checked, total
+}
+
+// SatisfiesPolicy reports whether every required criterion of the task is
+// checked. Tasks without any criteria never satisfy a policy.
+func (t Task) SatisfiesPolicy(p CompletionPolicy) bool {
+	if len(t.Criteria) == 0 {
+		return false
+	}
+	checked, total := t.RequiredCriteria(p)
+	return checked == total
+}
+
+// CompleteWithPolicy is like Complete but ignores criteria in deferred categories
+func CompleteWithPolicy(md string, p CompletionPolicy) bool {
+	ts := parseTasks(md)
+	if len(ts) == 0 {
+		return false
+	}
+	for _, t := range ts {
+		if !t.SatisfiesPolicy(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// CategoryCount is the completion state of one criteria category
+type CategoryCount struct {
+	Category string
+	Checked  int
+	Total    int
+}
+
+// CategorySummary counts checked and total criteria per category across all tasks,
+// functional first and the rest alphabetically
+func CategorySummary(tasksMd string) []CategoryCount {
+	counts := make(map[string]*CategoryCount)
+	for _, t := range parseTasks(tasksMd) {
+		for _, c := range t.Criteria {
+			cc, ok := counts[c.Category]
+			if !ok {
+				cc = &CategoryCount{Category: c.Category}
+				counts[c.Category] = cc
+			}
+			cc.Total++
+			if c.Checked {
+				cc.Checked++
+			}
+		}
+	}
+
+	out := make([]CategoryCount, 0, len(counts))
+	for _, cc := range counts {
+		out = append(out, *cc)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if (out[i].Category == CategoryFunctional) != (out[j].Category == CategoryFunctional) {
+			return out[i].Category == CategoryFunctional
+		}
+		return out[i].Category < out[j].Category
+	})
+	return out
+}
+
+// CategoryReport renders per-category criteria completion for task-status
+func CategoryReport(tasksMd string, p CompletionPolicy) string {
+	summary := CategorySummary(tasksMd)
+	if len(summary) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("📂 Criteria by Category:\n")
+	for _, cc := range summary {
+		line := fmt.Sprintf("  - %s: %d/%d", cc.Category, cc.Checked, cc.Total)
+		if p.IsDeferred(cc.Category) {
+			line += " (deferred)"
+		}
+		b.WriteString(line + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// DeferredCriteriaNote explains a policy to the agent; it is empty when no
+// categories are deferred
+func DeferredCriteriaNote(p CompletionPolicy) string {
+	if len(p.Deferred) == 0 {
+		return ""
+	}
+	tags := make([]string, len(p.Deferred))
+	for i, c := range p.Deferred {
+		tags[i] = "[" + c + "]"
+	}
+	return fmt.Sprintf("Criteria tagged %s may be deferred: the task counts as complete once all other criteria are checked", strings.Join(tags, ", "))
+}
+
+// AddCriteria appends unchecked acceptance criteria, e.g. "[review] Handle
+// empty input", to the named task after its last criterion, skipping those
+// it already has. It returns the updated tasks.md and the criteria added;
+// a task without an "**Acceptance Criteria:**" section gets none.
+func AddCriteria(tasksMd string, taskTitle string, criteria []string) (string, []string) {
+	lines := strings.Split(tasksMd, "\n")
+	inTask, inCriteria := false, false
+	header, last := -1, -1
+	existing := make(map[Criterion]bool)
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if m := reTaskHeader.FindStringSubmatch(line); m != nil {
+			if inTask {
+				break
+			}
+			inTask = cleanTaskTitle(m[1]) == taskTitle
+			continue
+		}
+		if !inTask {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(trimmed, "## "):
+			inTask = false
+		case reACHeader.MatchString(trimmed):
+			inCriteria, header = true, i
+		case inCriteria && reACItem.MatchString(trimmed):
+			c := parseCriterion(trimmed)
+			c.Checked = false
+			existing[c] = true
+			last = i
+		case inCriteria && strings.HasPrefix(trimmed, "**"):
+			inCriteria = false
+		}
+		if !inTask {
+			break
+		}
+	}
+	if header < 0 {
+		return tasksMd, nil
+	}
+
+	var added, newLines []string
+	for _, text := range criteria {
+		line := "* [ ] " + strings.Join(strings.Fields(text), " ")
+		c := parseCriterion(line)
+		if c.Text == "" || existing[c] {
+			continue
+		}
+		existing[c] = true
+		added = append(added, text)
+		newLines = append(newLines, line)
+	}
+	if len(added) == 0 {
+		return tasksMd, nil
+	}
+	at := last + 1
+	if last < 0 {
+		at = header + 1
+		newLines = append([]string{""}, newLines...)
+	}
+	lines = append(lines[:at], append(newLines, lines[at:]...)...)
+	return strings.Join(lines, "\n"), added
+}
+
+// TickCriteria checks the unchecked acceptance criteria of the named task
+// that the policy requires, for a task someone finished by hand. It
+// returns the updated tasks.md and the criteria ticked.
+func TickCriteria(tasksMd string, taskTitle string, p CompletionPolicy) (string, []string) {
+	lines := strings.Split(tasksMd, "\n")
+	inTask, inCriteria := false, false
+	var ticked []string
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if m := reTaskHeader.FindStringSubmatch(line); m != nil {
+			if inTask {
+				break
+			}
+			inTask = cleanTaskTitle(m[1]) == taskTitle
+			continue
+		}
+		if !inTask {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(trimmed, "## "):
+			inTask = false
+		case reACHeader.MatchString(trimmed):
+			inCriteria = true
+		case inCriteria && reACItem.MatchString(trimmed):
+			c := parseCriterion(trimmed)
+			if c.Checked || p.IsDeferred(c.Category) {
+				continue
+			}
+			lines[i] = strings.Replace(line, "[ ]", "[x]", 1)
+			ticked = append(ticked, c.Text)
+		case inCriteria && strings.HasPrefix(trimmed, "**"):
+			inCriteria = false
+		}
+		if !inTask {
+			break
+		}
+	}
+	return strings.Join(lines, "\n"), ticked
+}
@@ -0,0 +1,190 @@
+package tasks
+
+import (
+	"strings"
+	"testing"
+)
+
+const categorizedSample = `## Current Tasks
+
+### Task: Categorized Task
+
+**Context:** Task with tagged criteria
+**Acceptance Criteria:**
+
+* [x] Login endpoint returns a token
+* [x] [test] Unit tests cover token expiry
+* [ ] [docs] README documents the login flow
+* [ ] [Perf] Login completes under 100ms
+
+### Task: Plain Task
+
+**Context:** Task without tags
+**Acceptance Criteria:**
+
+- [x] Works
+- [ ] [doc] Documented
+`
+
+func TestParseCriterion(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected Criterion
+	}{
+		{"* [ ] Plain criterion", Criterion{Text: "Plain criterion", Category: CategoryFunctional}},
+		{"* [x] [test] Has tests", Criterion{Text: "Has tests", Checked: true, Category: "tests"}},
+		{"- [X] [Docs] Documented", Criterion{Text: "Documented", Checked: true, Category: "docs"}},
+		{"* [ ] [perf] Fast", Criterion{Text: "Fast", Category: "perf"}},
+		{"* [ ] [security-review] Reviewed", Criterion{Text: "Reviewed", Category: "security-review"}},
+	}
+
+	for _, tt := range tests {
+		got := parseCriterion(tt.line)
+		if got != tt.expected {
+			t.Errorf("parseCriterion(%q) = %+v, want %+v", tt.line, got, tt.expected)
+		}
+	}
+}
+
+func TestParseTasksCriteria(t *testing.T) {
+	ts := parseTasks(categorizedSample)
+	if len(ts) != 2 {
+		t.Fatalf("Expected 2 tasks, got %d", len(ts))
+	}
+	if len(ts[0].Criteria) != 4 {
+		t.Fatalf("Expected 4 criteria, got %d", len(ts[0].Criteria))
+	}
+	categories := []string{CategoryFunctional, "tests", "docs", "perf"}
+	for i, want := range categories {
+		if ts[0].Criteria[i].Category != want {
+			t.Errorf("criterion %d category = %q, want %q", i, ts[0].Criteria[i].Category, want)
+		}
+	}
+	if ts[0].ACTotal != 4 || ts[0].ACChecked != 2 {
+		t.Errorf("Expected AC counts 2/4, got %d/%d", ts[0].ACChecked, ts[0].ACTotal)
+	}
+}
+
+func TestCompletionPolicy(t *testing.T) {
+	ts := parseTasks(categorizedSample)
+
+	strict := CompletionPolicy{}
+	if ts[0].SatisfiesPolicy(strict) {
+		t.Errorf("Expected task with unchecked criteria to fail the strict policy")
+	}
+
+	deferDocs := ParseCompletionPolicy("docs")
+	if ts[0].SatisfiesPolicy(deferDocs) {
+		t.Errorf("Expected unchecked perf criterion to still block completion")
+	}
+	if !ts[1].SatisfiesPolicy(deferDocs) {
+		t.Errorf("Expected task with only docs unchecked to satisfy the docs-deferred policy")
+	}
+
+	deferBoth := ParseCompletionPolicy(" doc , performance ")
+	checked, total := ts[0].RequiredCriteria(deferBoth)
+	if checked != 2 || total != 2 {
+		t.Errorf("RequiredCriteria() = %d/%d, want 2/2", checked, total)
+	}
+	if !CompleteWithPolicy(categorizedSample, deferBoth) {
+		t.Errorf("Expected all tasks complete when docs and perf are deferred")
+	}
+	if CompleteWithPolicy(categorizedSample, strict) {
+		t.Errorf("Expected tasks incomplete under the strict policy")
+	}
+	if CompleteWithPolicy("", deferBoth) {
+		t.Errorf("Expected no tasks to never be complete")
+	}
+}
+
+func TestCategorySummary(t *testing.T) {
+	summary := CategorySummary(categorizedSample)
+	expected := []CategoryCount{
+		{Category: CategoryFunctional, Checked: 2, Total: 2},
+		{Category: "docs", Checked: 0, Total: 2},
+		{Category: "perf", Checked: 0, Total: 1},
+		{Category: "tests", Checked: 1, Total: 1},
+	}
+	if len(summary) != len(expected) {
+		t.Fatalf("CategorySummary() = %+v, want %+v", summary, expected)
+	}
+	for i := range expected {
+		if summary[i] != expected[i] {
+			t.Errorf("CategorySummary()[%d] = %+v, want %+v", i, summary[i], expected[i])
+		}
+	}
+}
+
+func TestCategoryReport(t *testing.T) {
+	report := CategoryReport(categorizedSample, ParseCompletionPolicy("docs"))
+	if !strings.Contains(report, "docs: 0/2 (deferred)") {
+		t.Errorf("Expected deferred docs line in report, got:\n%s", report)
+	}
+	if !strings.Contains(report, "functional: 2/2") {
+		t.Errorf("Expected functional line in report, got:\n%s", report)
+	}
+	if CategoryReport(emptyTasksSample, CompletionPolicy{}) != "" {
+		t.Errorf("Expected empty report without criteria")
+	}
+}
+
+func TestDeferredCriteriaNote(t *testing.T) {
+	if note := DeferredCriteriaNote(CompletionPolicy{}); note != "" {
+		t.Errorf("Expected empty note without deferred categories, got %q", note)
+	}
+	note := DeferredCriteriaNote(ParseCompletionPolicy("docs,perf"))
+	if !strings.Contains(note, "[docs], [perf]") {
+		t.Errorf("Expected note to list deferred tags, got %q", note)
+	}
+}
+
+func TestAddCriteria(t *testing.T) {
+	md := categorizedSample + "\n**Files to Modify:** `docs.md`\n"
+	updated, added := AddCriteria(md, "Plain Task", []string{"[review] Handle  empty input", "[doc] Documented", "[review] Handle empty input"})
+	if len(added) != 1 {
+		t.Fatalf("Expected only the new criterion to be added, got %q", added)
+	}
+	if !strings.Contains(updated, "- [ ] [doc] Documented\n* [ ] [review] Handle empty input\n\n**Files to Modify:**") {
+		t.Errorf("Expected the criterion after the last one:\n%s", updated)
+	}
+	var plain Task
+	for _, task := range ParseTasks(updated) {
+		if task.Title == "Plain Task" {
+			plain = task
+		}
+	}
+	if len(plain.Criteria) != 3 || plain.Criteria[2].Category != "review" {
+		t.Errorf("Criteria after AddCriteria = %+v", plain.Criteria)
+	}
+	if again, added := AddCriteria(updated, "Plain Task", []string{"[review] Handle empty input"}); again != updated || len(added) != 0 {
+		t.Error("Expected an existing criterion not to be added twice")
+	}
+	if other, _ := AddCriteria(md, "Categorized Task", []string{"Fix the typo"}); !strings.Contains(other, "* [ ] [Perf] Login completes under 100ms\n* [ ] Fix the typo\n\n### Task: Plain Task") {
+		t.Errorf("Expected only the named task to change:\n%s", other)
+	}
+	if same, added := AddCriteria(md, "Missing", []string{"Anything"}); same != md || added != nil {
+		t.Error("Expected an unknown task to be left alone")
+	}
+}
+
+func TestTickCriteria(t *testing.T) {
+	md := categorizedSample
+	updated, ticked := TickCriteria(md, "Categorized Task", ParseCompletionPolicy("perf"))
+	var task Task
+	for _, tk := range ParseTasks(updated) {
+		if tk.Title == "Categorized Task" {
+			task = tk
+		}
+	}
+	for _, c := range task.Criteria {
+		if c.Checked == (c.Category == "perf") {
+			t.Errorf("Expected only the required criteria ticked, got %+v", c)
+		}
+	}
+	if len(ticked) == 0 || !task.SatisfiesPolicy(ParseCompletionPolicy("perf")) {
+		t.Errorf("Expected the task to satisfy the policy, ticked %q", ticked)
+	}
+	if again, ticked := TickCriteria(updated, "Categorized Task", ParseCompletionPolicy("perf")); again != updated || len(ticked) != 0 {
+		t.Error("Expected nothing left to tick")
+	}
+}
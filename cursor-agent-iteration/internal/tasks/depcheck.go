@@ -0,0 +1,356 @@
+package tasks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// renameSimilarity is how alike a stale dependency name and a task title
+// must be for the title to be suggested as its new name
+const renameSimilarity = 0.6
+
+// DepRef is an item of a task's "**Dependencies:**" line
+type DepRef struct {
+	Task       string
+	Dependency string
+	// Resolved is the task the item names, "" when it names none
+	Resolved string
+	// Archived is set when Resolved was archived by archive-completed
+	Archived bool
+	// Suggestion is the task an unresolved item most likely named before the
+	// task was renamed, "" when no title is close enough
+	Suggestion string
+}
+
+// DepReport is what CheckDependencies finds
+type DepReport struct {
+	// Tasks are the tasks of tasks.md with their status from progress.md
+	Tasks  []Task
+	Refs   []DepRef
+	Cycles [][]string
+	// Unreachable are the tasks that can never start without a human: a
+	// prerequisite, directly or further down, is blocked, failed or in a
+	// cycle. The tasks of the cycles themselves are in Cycles.
+	Unreachable []string
+}
+
+// Unresolved returns the dependencies that name neither a task nor an
+// archived one
+func (r DepReport) Unresolved() []DepRef {
+	var refs []DepRef
+	for _, ref := range r.Refs {
+		if ref.Resolved == "" {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// OK reports whether every dependency resolves and every task can start
+func (r DepReport) OK() bool {
+	return len(r.Unresolved()) == 0 && len(r.Cycles) == 0 && len(r.Unreachable) == 0
+}
+
+// CheckDependencies resolves the dependencies of the tasks in tasksMd to
+// tasks, or to the archived tasks, and finds the cycles and the tasks that
+// can never start. Names match case-insensitively, like NewDependencyGraph.
+func CheckDependencies(tasksMd, progressMd string, archived []string) DepReport {
+	all := parseTasks(tasksMd)
+	entries := ParseProgressFor(progressMd, taskTitles(all))
+	byName := make(map[string]string)
+	var titles []string
+	for i, t := range all {
+		if e, ok := entries[t.Title]; ok {
+			all[i].Status = e.Status
+		}
+		byName[strings.ToLower(cleanTaskTitle(t.Title))] = t.Title
+		titles = append(titles, t.Title)
+	}
+	archivedByName := make(map[string]string)
+	for _, title := range archived {
+		if _, ok := byName[strings.ToLower(cleanTaskTitle(title))]; !ok {
+			archivedByName[strings.ToLower(cleanTaskTitle(title))] = title
+		}
+	}
+
+	r := DepReport{Tasks: all}
+	for _, t := range all {
+		for _, dep := range t.Dependencies {
+			ref := DepRef{Task: t.Title, Dependency: dep}
+			key := strings.ToLower(cleanTaskTitle(dep))
+			if title, ok := byName[key]; ok {
+				ref.Resolved = title
+			} else if title, ok := archivedByName[key]; ok {
+				ref.Resolved, ref.Archived = title, true
+			} else {
+				ref.Suggestion = closestTitle(dep, titles, t.Title)
+			}
+			r.Refs = append(r.Refs, ref)
+		}
+	}
+
+	g := NewDependencyGraph(all)
+	r.Cycles = g.Cycles()
+	inCycle := make(map[string]bool)
+	for _, cycle := range r.Cycles {
+		for _, title := range cycle {
+			inCycle[title] = true
+		}
+	}
+	for _, t := range all {
+		if !inCycle[t.Title] && entries[t.Title].Status != "completed" && g.Stuck(t.Title, entries) {
+			r.Unreachable = append(r.Unreachable, t.Title)
+		}
+	}
+	return r
+}
+
+// closestTitle returns the title most like name other than self, "" when
+// none is alike enough or two are equally alike
+func closestTitle(name string, titles []string, self string) string {
+	best, bestScore, tie := "", 0.0, false
+	for _, title := range titles {
+		if title == self {
+			continue
+		}
+		score := titleSimilarity(name, title)
+		switch {
+		case score > bestScore:
+			best, bestScore, tie = title, score, false
+		case score == bestScore:
+			tie = true
+		}
+	}
+	if bestScore < renameSimilarity || tie {
+		return ""
+	}
+	return best
+}
+
+// titleSimilarity scores how alike two titles are from 0 to 1: the better
+// of their shared words and their edit distance, ignoring case
+func titleSimilarity(a, b string) float64 {
+	a, b = strings.ToLower(cleanTaskTitle(a)), strings.ToLower(cleanTaskTitle(b))
+	wordsA, wordsB := make(map[string]bool), make(map[string]bool)
+	for _, w := range strings.Fields(a) {
+		wordsA[w] = true
+	}
+	for _, w := range strings.Fields(b) {
+		wordsB[w] = true
+	}
+	shared := 0
+	for w := range wordsA {
+		if wordsB[w] {
+			shared++
+		}
+	}
+	words := 0.0
+	if union := len(wordsA) + len(wordsB) - shared; union > 0 {
+		words = float64(shared) / float64(union)
+	}
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 0
+	}
+	return max(words, 1-float64(editDistance(ra, rb))/float64(longest))
+}
+
+// editDistance is the Levenshtein distance of two strings
+func editDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// FixDependencies rewrites the unresolved dependencies of the report that
+// have a suggestion to the suggested title, on the Dependencies lines of
+// their tasks, and returns the updated tasks.md with the fixes made
+func FixDependencies(tasksMd string, r DepReport) (string, []DepRef) {
+	fixes := make(map[string]map[string]DepRef)
+	for _, ref := range r.Unresolved() {
+		if ref.Suggestion == "" {
+			continue
+		}
+		if fixes[ref.Task] == nil {
+			fixes[ref.Task] = make(map[string]DepRef)
+		}
+		fixes[ref.Task][ref.Dependency] = ref
+	}
+	if len(fixes) == 0 {
+		return tasksMd, nil
+	}
+
+	lines := strings.Split(tasksMd, "\n")
+	var fixed []DepRef
+	current := ""
+	for i, line := range lines {
+		if m := reTaskHeader.FindStringSubmatch(line); m != nil {
+			current = strings.TrimSpace(m[1])
+			continue
+		}
+		m := reDependenciesLine.FindStringSubmatch(line)
+		if m == nil || fixes[current] == nil {
+			continue
+		}
+		prefix := line[:len(line)-len(m[1])]
+		items := m[1]
+		if reBackticked.MatchString(items) {
+			items = reBackticked.ReplaceAllStringFunc(items, func(q string) string {
+				ref, ok := fixes[current][strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(strings.Trim(q, "`")), "Task:"))]
+				if !ok {
+					return q
+				}
+				fixed = append(fixed, ref)
+				return "`" + ref.Suggestion + "`"
+			})
+		} else {
+			parts := strings.Split(items, ",")
+			for j, part := range parts {
+				ref, ok := fixes[current][strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(part), "Task:"))]
+				if !ok {
+					continue
+				}
+				fixed = append(fixed, ref)
+				lead := part[:len(part)-len(strings.TrimLeft(part, " "))]
+				parts[j] = lead + ref.Suggestion
+			}
+			items = strings.Join(parts, ",")
+		}
+		lines[i] = prefix + items
+		delete(fixes, current)
+	}
+	return strings.Join(lines, "\n"), fixed
+}
+
+// depNodes numbers the tasks and the dependencies that aren't tasks for a
+// graph: tasks first, then archived and missing ones by name
+func (r DepReport) depNodes() (ids map[string]string, extra []DepRef) {
+	ids = make(map[string]string)
+	for _, t := range r.Tasks {
+		ids[t.Title] = fmt.Sprintf("n%d", len(ids))
+	}
+	seen := make(map[string]bool)
+	for _, ref := range r.Refs {
+		name := ref.Resolved
+		if name == "" {
+			name = ref.Dependency
+		}
+		if _, ok := ids[name]; ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		extra = append(extra, ref)
+	}
+	sort.Slice(extra, func(i, j int) bool { return depName(extra[i]) < depName(extra[j]) })
+	for _, ref := range extra {
+		ids[depName(ref)] = fmt.Sprintf("n%d", len(ids))
+	}
+	return ids, extra
+}
+
+func depName(ref DepRef) string {
+	if ref.Resolved != "" {
+		return ref.Resolved
+	}
+	return ref.Dependency
+}
+
+// cycleEdges returns the edges of the cycles as "prerequisite\x00task"
+func (r DepReport) cycleEdges() map[string]bool {
+	edges := make(map[string]bool)
+	for _, cycle := range r.Cycles {
+		for i := 0; i+1 < len(cycle); i++ {
+			edges[cycle[i+1]+"\x00"+cycle[i]] = true
+		}
+	}
+	return edges
+}
+
+// depColors fill the nodes of a graph by task status
+var depColors = map[string]string{
+	"completed":   "#d4edda",
+	"in-progress": "#fff3cd",
+	"blocked":     "#f8d7da",
+	"failed":      "#f8d7da",
+	"pending":     "#ffffff",
+}
+
+// DOT renders the dependencies as a Graphviz graph, with an arrow from each
+// prerequisite to the task waiting for it. Tasks are filled by status,
+// archived prerequisites dashed, missing ones and cycles red.
+func (r DepReport) DOT() string {
+	ids, extra := r.depNodes()
+	quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", " ").Replace
+	var b strings.Builder
+	b.WriteString("digraph dependencies {\n  rankdir=LR;\n  node [shape=box, style=\"rounded,filled\", fillcolor=\"#ffffff\"];\n")
+	for _, t := range r.Tasks {
+		fmt.Fprintf(&b, "  %s [label=\"%s\", fillcolor=\"%s\"];\n", ids[t.Title], quote(t.Title), depColors[t.Status])
+	}
+	for _, ref := range extra {
+		if ref.Archived {
+			fmt.Fprintf(&b, "  %s [label=\"%s (archived)\", style=\"rounded,dashed\"];\n", ids[depName(ref)], quote(ref.Resolved))
+		} else {
+			fmt.Fprintf(&b, "  %s [label=\"%s (missing)\", style=\"rounded,dashed\", color=red, fontcolor=red];\n", ids[depName(ref)], quote(ref.Dependency))
+		}
+	}
+	cycles := r.cycleEdges()
+	for _, ref := range r.Refs {
+		attrs := ""
+		if cycles[ref.Resolved+"\x00"+ref.Task] {
+			attrs = " [color=red]"
+		}
+		fmt.Fprintf(&b, "  %s -> %s%s;\n", ids[depName(ref)], ids[ref.Task], attrs)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Mermaid renders the dependencies as a Mermaid flowchart, like DOT
+func (r DepReport) Mermaid() string {
+	ids, extra := r.depNodes()
+	quote := strings.NewReplacer(`"`, "#quot;", "\n", " ").Replace
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for _, t := range r.Tasks {
+		fmt.Fprintf(&b, "    %s[\"%s\"]:::%s\n", ids[t.Title], quote(t.Title), strings.ReplaceAll(t.Status, "-", ""))
+	}
+	for _, ref := range extra {
+		if ref.Archived {
+			fmt.Fprintf(&b, "    %s[\"%s (archived)\"]:::archived\n", ids[depName(ref)], quote(ref.Resolved))
+		} else {
+			fmt.Fprintf(&b, "    %s[\"%s (missing)\"]:::missing\n", ids[depName(ref)], quote(ref.Dependency))
+		}
+	}
+	cycles := r.cycleEdges()
+	var red []string
+	for i, ref := range r.Refs {
+		fmt.Fprintf(&b, "    %s --> %s\n", ids[depName(ref)], ids[ref.Task])
+		if cycles[ref.Resolved+"\x00"+ref.Task] {
+			red = append(red, fmt.Sprint(i))
+		}
+	}
+	for _, status := range []string{"completed", "in-progress", "blocked", "failed", "pending"} {
+		fmt.Fprintf(&b, "    classDef %s fill:%s\n", strings.ReplaceAll(status, "-", ""), depColors[status])
+	}
+	b.WriteString("    classDef archived stroke-dasharray: 5 5\n    classDef missing stroke:#d00,color:#d00,stroke-dasharray: 5 5\n")
+	if len(red) > 0 {
+		fmt.Fprintf(&b, "    linkStyle %s stroke:#d00\n", strings.Join(red, ","))
+	}
+	return b.String()
+}
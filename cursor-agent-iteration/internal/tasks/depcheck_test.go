@@ -0,0 +1,97 @@
+package tasks
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const depTasks = `## Current Tasks
+
+### Task: Add user data model
+
+**Dependencies:** None
+
+### Task: Login form
+
+**Dependencies:** ` + "`Add user model`, `Session store`, ADR-012" + `
+
+### Task: Password reset
+
+**Dependencies:** Login form, Email sender
+
+### Task: Email sender
+
+**Dependencies:** Password reset
+
+### Task: Audit log
+
+**Dependencies:** Email sender
+
+### Task: Rate limits
+
+**Dependencies:** Billing
+`
+
+const depProgress = `# Progress Log
+
+## Blocked
+
+- ⛔ [2025-01-09 10:00] Add user data model - needs a schema review
+`
+
+func TestCheckDependencies(t *testing.T) {
+	r := CheckDependencies(depTasks, depProgress, []string{"Session store"})
+	var unresolved []string
+	for _, ref := range r.Unresolved() {
+		unresolved = append(unresolved, ref.Task+": "+ref.Dependency+" → "+ref.Suggestion)
+	}
+	want := []string{"Login form: Add user model → Add user data model", "Rate limits: Billing → "}
+	if !reflect.DeepEqual(unresolved, want) {
+		t.Errorf("Unresolved() = %q, want %q", unresolved, want)
+	}
+	if ref := r.Refs[1]; ref.Resolved != "Session store" || !ref.Archived {
+		t.Errorf("Archived dependency = %+v", ref)
+	}
+	if len(r.Cycles) != 1 || FormatCycle(r.Cycles[0]) != "'Password reset' → 'Email sender' → 'Password reset'" {
+		t.Errorf("Cycles = %v", r.Cycles)
+	}
+	// Audit log waits on the cycle; Login form is reachable once the stale
+	// name is fixed, but its prerequisite is blocked
+	if !reflect.DeepEqual(r.Unreachable, []string{"Audit log"}) {
+		t.Errorf("Unreachable = %v, want [Audit log]", r.Unreachable)
+	}
+	if r.OK() {
+		t.Error("OK() = true for a report with problems")
+	}
+
+	fixedMd, fixed := FixDependencies(depTasks, r)
+	if len(fixed) != 1 || !strings.Contains(fixedMd, "**Dependencies:** `Add user data model`, `Session store`, ADR-012") {
+		t.Fatalf("FixDependencies() fixed %v:\n%s", fixed, fixedMd)
+	}
+	after := CheckDependencies(fixedMd, depProgress, []string{"Session store"})
+	if !reflect.DeepEqual(after.Unreachable, []string{"Login form", "Audit log"}) {
+		t.Errorf("Unreachable after the fix = %v", after.Unreachable)
+	}
+
+	plain := "## Current Tasks\n\n### Task: Add user data model\n\n### Task: Login form\n\n**Dependencies:** Task: Add user model, ADR-012\n"
+	if got, _ := FixDependencies(plain, CheckDependencies(plain, "", nil)); !strings.Contains(got, "**Dependencies:** Add user data model, ADR-012") {
+		t.Errorf("FixDependencies() of an unquoted line:\n%s", got)
+	}
+}
+
+func TestDependencyGraphs(t *testing.T) {
+	r := CheckDependencies(depTasks, depProgress, []string{"Session store"})
+	dot := r.DOT()
+	for _, want := range []string{"digraph dependencies {", `n0 [label="Add user data model", fillcolor="#f8d7da"];`, `[label="Session store (archived)", style="rounded,dashed"]`, `[label="Billing (missing)"`, "n3 -> n2 [color=red];", "n2 -> n3 [color=red];", "n3 -> n4;"} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT() should contain %q:\n%s", want, dot)
+		}
+	}
+	mermaid := r.Mermaid()
+	for _, want := range []string{"flowchart LR", `n0["Add user data model"]:::blocked`, `["Billing (missing)"]:::missing`, "n3 --> n4", "linkStyle 3,4 stroke:#d00"} {
+		if !strings.Contains(mermaid, want) {
+			t.Errorf("Mermaid() should contain %q:\n%s", want, mermaid)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package tasks
+
+import (
+	"regexp"
+	"strings"
+)
+
+var reDependenciesLine = regexp.MustCompile(`^\s*\*\*Dependencies:\*\*\s*(.*)$`)
+
+// ParseDependencies returns the items on the "**Dependencies:**" line of a
+// task block, e.g. "**Dependencies:** `Add user model`, ADR-012" gives
+// ["Add user model"]. Backticked items are preferred; otherwise the line is
+// split on commas. "None" and "n/a" give no items.
+func ParseDependencies(taskDetails string) []string {
+	for _, line := range strings.Split(taskDetails, "\n") {
+		m := reDependenciesLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		var items []string
+		if quoted := reBackticked.FindAllStringSubmatch(m[1], -1); len(quoted) > 0 {
+			for _, q := range quoted {
+				items = append(items, q[1])
+			}
+		} else {
+			items = strings.Split(m[1], ",")
+		}
+
+		var deps []string
+		for _, item := range items {
+			item = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(item), "Task:"))
+			if item == "" || item == "-" || strings.EqualFold(item, "none") || strings.EqualFold(item, "n/a") {
+				continue
+			}
+			deps = append(deps, item)
+		}
+		return deps
+	}
+	return nil
+}
+
+// DependencyGraph links tasks to the tasks they depend on. Dependencies that
+// name no task in tasks.md, such as ADRs and external systems, are left out.
+type DependencyGraph struct {
+	order   []string
+	prereqs map[string][]string
+}
+
+// NewDependencyGraph builds the graph of the tasks, matching dependencies to
+// task titles case-insensitively
+func NewDependencyGraph(all []Task) DependencyGraph {
+	g := DependencyGraph{prereqs: make(map[string][]string)}
+	byName := make(map[string]string)
+	for _, t := range all {
+		g.order = append(g.order, t.Title)
+		byName[strings.ToLower(cleanTaskTitle(t.Title))] = t.Title
+	}
+	for _, t := range all {
+		for _, dep := range t.Dependencies {
+			if title, ok := byName[strings.ToLower(cleanTaskTitle(dep))]; ok {
+				g.prereqs[t.Title] = append(g.prereqs[t.Title], title)
+			}
+		}
+	}
+	return g
+}
+
+// Prerequisites returns the tasks the task depends on
+func (g DependencyGraph) Prerequisites(title string) []string {
+	return g.prereqs[title]
+}
+
+// Waiting returns the prerequisites of the task that progress.md doesn't
+// record as completed yet; the task may start once there are none
+func (g DependencyGraph) Waiting(title string, entries map[string]ProgressEntry) []string {
+	var waiting []string
+	for _, p := range g.prereqs[title] {
+		if entries[p].Status != "completed" {
+			waiting = append(waiting, p)
+		}
+	}
+	return waiting
+}
+
+// Stuck reports whether the task can never start without a human: one of its
+// prerequisites, directly or further down, is blocked, failed or part of a
+// cycle
+func (g DependencyGraph) Stuck(title string, entries map[string]ProgressEntry) bool {
+	return g.stuck(title, entries, make(map[string]bool))
+}
+
+func (g DependencyGraph) stuck(title string, entries map[string]ProgressEntry, visiting map[string]bool) bool {
+	if visiting[title] {
+		return true
+	}
+	visiting[title] = true
+	defer delete(visiting, title)
+	for _, p := range g.prereqs[title] {
+		switch entries[p].Status {
+		case "completed":
+		case "blocked", "failed":
+			return true
+		default:
+			if g.stuck(p, entries, visiting) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Cycles returns the dependency cycles, each as the titles along it with
+// the first title repeated at the end, e.g. [A B A]
+func (g DependencyGraph) Cycles() [][]string {
+	const (
+		unvisited = iota
+		onPath
+		done
+	)
+	state := make(map[string]int)
+	var path []string
+	var cycles [][]string
+	var visit func(title string)
+	visit = func(title string) {
+		state[title] = onPath
+		path = append(path, title)
+		for _, p := range g.prereqs[title] {
+			switch state[p] {
+			case unvisited:
+				visit(p)
+			case onPath:
+				for i := len(path) - 1; i >= 0; i-- {
+					if path[i] == p {
+						cycle := append([]string{}, path[i:]...)
+						cycles = append(cycles, append(cycle, p))
+						break
+					}
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[title] = done
+	}
+	for _, title := range g.order {
+		if state[title] == unvisited {
+			visit(title)
+		}
+	}
+	return cycles
+}
+
+// FormatCycle renders a cycle from Cycles, e.g. "'A' → 'B' → 'A'"
+func FormatCycle(cycle []string) string {
+	quoted := make([]string, len(cycle))
+	for i, title := range cycle {
+		quoted[i] = "'" + title + "'"
+	}
+	return strings.Join(quoted, " → ")
+}
@@ -0,0 +1,109 @@
+package tasks
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDependencies(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"**Dependencies:** None", nil},
+		{"  **Dependencies:** n/a", nil},
+		{"**Dependencies:** `Add user model`, `Task: Login form`", []string{"Add user model", "Login form"}},
+		{"**Dependencies:** Add user model, ADR-012", []string{"Add user model", "ADR-012"}},
+		{"**Labels:** `[type:feature]`", nil},
+	}
+	for _, tt := range tests {
+		if got := ParseDependencies(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseDependencies(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+const depsTasks = `## Current Tasks
+
+### Task: Add user model
+
+**Dependencies:** ADR-003
+
+### Task: Login form
+
+**Dependencies:** ` + "`add user model`" + `
+
+### Task: Session cookies
+
+**Dependencies:** ` + "`Login form`, `Add user model`" + `
+
+### Task: Docs
+
+**Dependencies:** None
+`
+
+func TestDependencyScheduling(t *testing.T) {
+	all := ParseTasks(depsTasks)
+	if got := all[2].Dependencies; !reflect.DeepEqual(got, []string{"Login form", "Add user model"}) {
+		t.Fatalf("Dependencies = %v", got)
+	}
+	g := NewDependencyGraph(all)
+	if got := g.Prerequisites("Login form"); !reflect.DeepEqual(got, []string{"Add user model"}) {
+		t.Errorf("Prerequisites = %v; want the title matched case-insensitively and ADRs left out", got)
+	}
+	if got := g.Prerequisites("Add user model"); got != nil {
+		t.Errorf("Expected an external dependency to be ignored, got %v", got)
+	}
+
+	// Only Add user model and Docs may start at first
+	progress := MarkTaskInProgress(blockedProgress, "Add user model")
+	if next := GetNextPendingTaskWithProgress(depsTasks, progress); next == nil || next.Title != "Docs" {
+		t.Errorf("Expected Docs next while the user model is in progress, got %+v", next)
+	}
+	entries := ParseProgress(progress)
+	if got := g.Waiting("Session cookies", entries); len(got) != 2 {
+		t.Errorf("Waiting = %v", got)
+	}
+
+	progress = MoveTaskToCompleted(progress, "Add user model", "")
+	if next := GetNextPendingTaskWithProgress(depsTasks, progress); next == nil || next.Title != "Login form" {
+		t.Errorf("Expected Login form once its prerequisite completed, got %+v", next)
+	}
+	interleave := Fairness{Policy: FairnessInterleave}
+	if next := interleave.NextPendingTask(depsTasks, progress, nil, 4, nil); next == nil || next.Title != "Login form" {
+		t.Errorf("Expected interleaving to honour dependencies, got %+v", next)
+	}
+}
+
+func TestDependencyCycles(t *testing.T) {
+	tasksMd := depsTasks + "\n### Task: A\n\n**Dependencies:** `B`\n\n### Task: B\n\n**Dependencies:** `A`\n\n### Task: C\n\n**Dependencies:** `C`\n"
+	cycles := NewDependencyGraph(ParseTasks(tasksMd)).Cycles()
+	var got []string
+	for _, c := range cycles {
+		got = append(got, FormatCycle(c))
+	}
+	want := []string{"'A' → 'B' → 'A'", "'C' → 'C'"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Cycles = %v, want %v", got, want)
+	}
+	if cycles := NewDependencyGraph(ParseTasks(depsTasks)).Cycles(); cycles != nil {
+		t.Errorf("Expected no cycles, got %v", cycles)
+	}
+
+	result := ValidateTasksStructureWithLimit(tasksMd, 0)
+	if result.Valid || !strings.Contains(strings.Join(result.Errors, "\n"), "Dependency cycle: 'A' → 'B' → 'A'") {
+		t.Errorf("Expected validation to report the cycle, got %v", result.Errors)
+	}
+}
+
+func TestOnlyBlockedRemainWithDependents(t *testing.T) {
+	tasksMd := "## Current Tasks\n\n### Task: Task A\n\n### Task: Task D\n\n**Dependencies:** `Task A`\n"
+	progress := MarkTaskBlocked(blockedProgress, "Task A", "")
+	if !OnlyBlockedRemain(tasksMd, progress) {
+		t.Errorf("Expected a task waiting on a blocked task not to count as remaining work")
+	}
+	if OnlyBlockedRemain(tasksMd+"\n### Task: Task E\n", progress) {
+		t.Errorf("Expected independent Task E to count as remaining work")
+	}
+}
@@ -0,0 +1,109 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Draft is a task written by hand rather than planned by an agent, as
+// add-task takes it. Tasks imported from issues, specs, TODOs and other
+// sources are rendered through it too, so every one has the same layout.
+type Draft struct {
+	Title    string
+	Context  string
+	Criteria []string
+	// Checked marks the criteria, by index, that are already met
+	Checked      []bool
+	Files        []string
+	Tests        string   // the kind of tests the task needs, e.g. regression
+	Source       string   // where the task came from, e.g. an issue URL
+	Dependencies []string // task titles or other prerequisites, such as ADRs
+	Labels       []string
+}
+
+// Check reports what keeps the draft from becoming a valid task: a title
+// on one line, a context, at least one criterion, and no line that would
+// start another task or section
+func (d Draft) Check() error {
+	title := normalizeTitle(d.Title)
+	switch {
+	case title == "":
+		return errors.New("the task has no title")
+	case strings.ContainsAny(d.Title, "\r\n"):
+		return fmt.Errorf("the title %q spans several lines", d.Title)
+	case strings.TrimSpace(d.Context) == "":
+		return fmt.Errorf("the task %q has no context", title)
+	}
+	for _, line := range strings.Split(d.Context, "\n") {
+		if isBlockHeader(strings.TrimSpace(line)) {
+			return fmt.Errorf("the context line %q would start a new task or section", strings.TrimSpace(line))
+		}
+	}
+	if len(d.Criteria) == 0 {
+		return fmt.Errorf("the task %q has no acceptance criteria", title)
+	}
+	for _, items := range [][]string{d.Criteria, d.Files, d.Dependencies, d.Labels, {d.Tests, d.Source}} {
+		for _, item := range items {
+			if strings.ContainsAny(item, "\r\n") {
+				return fmt.Errorf("%q spans several lines", item)
+			}
+		}
+	}
+	for _, item := range append(append([]string{}, d.Files...), d.Dependencies...) {
+		if strings.Contains(item, "`") {
+			return fmt.Errorf("%q contains a backtick", item)
+		}
+	}
+	for _, label := range d.Labels {
+		if strings.ContainsAny(label, "[]") {
+			return fmt.Errorf("the label %q contains a bracket", label)
+		}
+	}
+	return nil
+}
+
+// Block renders the draft as a tasks.md block. Files and dependencies are
+// backticked so paths and titles with commas read back whole.
+func (d Draft) Block() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### Task: %s\n\n", normalizeTitle(d.Title))
+	fmt.Fprintf(&b, "**Context:** %s\n", strings.TrimSpace(d.Context))
+	b.WriteString("**Acceptance Criteria:**\n\n")
+	for i, c := range d.Criteria {
+		mark := " "
+		if i < len(d.Checked) && d.Checked[i] {
+			mark = "x"
+		}
+		fmt.Fprintf(&b, "* [%s] %s\n", mark, strings.TrimSpace(c))
+	}
+	fmt.Fprintf(&b, "\n**Files to Modify:** %s\n", backtickedList(d.Files))
+	if d.Tests != "" {
+		fmt.Fprintf(&b, "**Tests:** %s\n", d.Tests)
+	}
+	if d.Source != "" {
+		fmt.Fprintf(&b, "**Source:** %s\n", d.Source)
+	}
+	if len(d.Labels) > 0 {
+		labels := make([]string, len(d.Labels))
+		for i, l := range d.Labels {
+			labels[i] = "[" + strings.TrimSpace(l) + "]"
+		}
+		fmt.Fprintf(&b, "**Labels:** %s\n", strings.Join(labels, " "))
+	}
+	fmt.Fprintf(&b, "**Dependencies:** %s", backtickedList(d.Dependencies))
+	return b.String()
+}
+
+// backtickedList renders items as a comma-separated list of code spans, or
+// None
+func backtickedList(items []string) string {
+	if len(items) == 0 {
+		return "None"
+	}
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = "`" + strings.TrimSpace(item) + "`"
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,80 @@
+package tasks
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDraftBlock(t *testing.T) {
+	d := Draft{
+		Title:        "  Rate limit the login endpoint ",
+		Context:      "Brute-force attempts show up in the logs.",
+		Criteria:     []string{"5 failed logins lock the account for 15 minutes", " A locked account gets a 429 "},
+		Files:        []string{"api/login.go", "api/limits.go"},
+		Dependencies: []string{"Add user model, with roles"},
+		Labels:       []string{"type:feature", "milestone:auth"},
+	}
+	if err := d.Check(); err != nil {
+		t.Fatal(err)
+	}
+	md := AppendTasks("# Tasks\n\n## Current Tasks\n", []string{d.Block()})
+	if result := ValidateTasksStructure(md); !result.Valid {
+		t.Fatalf("Expected a valid task, got %v:\n%s", result.Errors, md)
+	}
+	all := ParseTasks(md)
+	if len(all) != 1 {
+		t.Fatalf("Expected 1 task, got %d:\n%s", len(all), md)
+	}
+	task := all[0]
+	if task.Title != "Rate limit the login endpoint" || task.Context != "Brute-force attempts show up in the logs." || task.ACTotal != 2 {
+		t.Errorf("Unexpected task %+v", task)
+	}
+	if !reflect.DeepEqual(task.Files, []string{"api/login.go", "api/limits.go"}) {
+		t.Errorf("Files = %q", task.Files)
+	}
+	if !reflect.DeepEqual(task.Dependencies, []string{"Add user model, with roles"}) {
+		t.Errorf("Dependencies = %q", task.Dependencies)
+	}
+	if !reflect.DeepEqual(task.Labels, []string{"type:feature", "milestone:auth"}) {
+		t.Errorf("Labels = %q", task.Labels)
+	}
+
+	bare := Draft{Title: "Fix typo", Context: "In the README", Criteria: []string{"Typo fixed"}}.Block()
+	if !strings.Contains(bare, "**Files to Modify:** None\n**Dependencies:** None") || strings.Contains(bare, "**Labels:**") {
+		t.Errorf("Unexpected block without files, labels or dependencies:\n%s", bare)
+	}
+
+	imported := Draft{Title: "Login", Context: "From GitHub issue #7.", Criteria: []string{"Form", "Errors"}, Checked: []bool{true},
+		Tests: "unit", Source: "https://github.com/o/r/issues/7", Labels: []string{"source:github"}}.Block()
+	if !strings.Contains(imported, "* [x] Form\n* [ ] Errors\n\n**Files to Modify:** None\n**Tests:** unit\n**Source:** https://github.com/o/r/issues/7\n**Labels:** [source:github]\n") {
+		t.Errorf("Unexpected imported block:\n%s", imported)
+	}
+	if task := ParseTasks("## Current Tasks\n\n" + imported)[0]; task.ACChecked != 1 || task.ACTotal != 2 {
+		t.Errorf("Unexpected imported task %+v", task)
+	}
+}
+
+func TestDraftCheck(t *testing.T) {
+	valid := Draft{Title: "Fix typo", Context: "In the README", Criteria: []string{"Typo fixed"}}
+	for name, edit := range map[string]func(*Draft){
+		"no title":         func(d *Draft) { d.Title = " \u200b" },
+		"two-line title":   func(d *Draft) { d.Title = "Fix\ntypo" },
+		"no context":       func(d *Draft) { d.Context = "" },
+		"header context":   func(d *Draft) { d.Context = "See below\n## Notes" },
+		"no criteria":      func(d *Draft) { d.Criteria = nil },
+		"two-line item":    func(d *Draft) { d.Criteria = []string{"a\nb"} },
+		"backticked file":  func(d *Draft) { d.Files = []string{"`a.go`"} },
+		"bracketed label":  func(d *Draft) { d.Labels = []string{"[type:bug]"} },
+		"backticked title": func(d *Draft) { d.Dependencies = []string{"`Other`"} },
+	} {
+		d := valid
+		edit(&d)
+		if err := d.Check(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if err := valid.Check(); err != nil {
+		t.Errorf("Expected a valid draft, got %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// TaskEdit is a change to an existing task. Empty fields leave the task's
+// own alone; Labels replaces the labels when it isn't nil, so an empty
+// non-nil list removes them.
+type TaskEdit struct {
+	Title   string
+	Context string
+	// Criteria replaces the acceptance criteria. A criterion that was
+	// already there keeps its tick.
+	Criteria []string
+	Labels   []string
+}
+
+// check reports what keeps the edit from leaving a valid task, as
+// Draft.Check does for new tasks
+func (e TaskEdit) check() error {
+	if strings.ContainsAny(e.Title, "\r\n") {
+		return fmt.Errorf("the title %q spans several lines", e.Title)
+	}
+	if e.Title != "" && normalizeTitle(e.Title) == "" {
+		return errors.New("the new title is empty")
+	}
+	if strings.ContainsAny(e.Context, "\r\n") {
+		return errors.New("the context must be a single line")
+	}
+	if e.Criteria != nil && len(e.Criteria) == 0 {
+		return errors.New("a task needs at least one acceptance criterion")
+	}
+	for _, items := range [][]string{e.Criteria, e.Labels} {
+		for _, item := range items {
+			if strings.ContainsAny(item, "\r\n") {
+				return fmt.Errorf("%q spans several lines", item)
+			}
+			if strings.TrimSpace(item) == "" {
+				return errors.New("criteria and labels can't be empty")
+			}
+		}
+	}
+	for _, label := range e.Labels {
+		if strings.ContainsAny(label, "[]") {
+			return fmt.Errorf("the label %q contains a bracket", label)
+		}
+	}
+	return nil
+}
+
+// EditTask applies an edit to a task in "## Current Tasks". A new title is
+// carried over to the task's progress.md entries and to the dependencies
+// of other tasks on it. The edit is refused when the task doesn't exist,
+// another task has the new title, or tasks.md would fail validation where
+// it didn't before.
+func EditTask(tasksMd string, progressMd string, taskTitle string, e TaskEdit) (newTasks string, newProgress string, err error) {
+	if err := e.check(); err != nil {
+		return tasksMd, progressMd, err
+	}
+	title := cleanTaskTitle(taskTitle)
+	newTitle := normalizeTitle(e.Title)
+	lines := strings.Split(tasksMd, "\n")
+	blocks, _ := currentTaskBlocks(lines)
+	k := -1
+	for i, b := range blocks {
+		if b.title == title {
+			k = i
+		} else if newTitle != "" && strings.EqualFold(b.title, newTitle) {
+			return tasksMd, progressMd, fmt.Errorf("%q already has a task", b.title)
+		}
+	}
+	if k < 0 {
+		return tasksMd, progressMd, fmt.Errorf("no task %q in Current Tasks", taskTitle)
+	}
+
+	block, err := editBlock(lines[blocks[k].start:blocks[k].end], newTitle, e)
+	if err != nil {
+		return tasksMd, progressMd, fmt.Errorf("task %q: %v", title, err)
+	}
+	edited := append(append(append([]string{}, lines[:blocks[k].start]...), block...), lines[blocks[k].end:]...)
+	newTasks = strings.Join(edited, "\n")
+	newProgress = progressMd
+	if newTitle != "" && newTitle != title {
+		newTasks = renameDependencies(newTasks, map[string]string{strings.ToLower(title): newTitle})
+		newProgress = renameProgressEntries(progressMd, title, newTitle)
+	}
+	if err := CheckStructureKept(tasksMd, newTasks); err != nil {
+		return tasksMd, progressMd, err
+	}
+	return newTasks, newProgress, nil
+}
+
+// editBlock applies an edit to the lines of a task block. A context or
+// labels line the task lacks is added before its criteria or dependencies.
+func editBlock(block []string, newTitle string, e TaskEdit) ([]string, error) {
+	checked := make(map[Criterion]bool)
+	var out []string
+	contextDone, labelsDone := e.Context == "", e.Labels == nil
+	criteriaAt, inCriteria := -1, false
+	for i, line := range block {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "**") && !reACHeader.MatchString(trimmed) {
+			inCriteria = false
+		}
+		switch {
+		case i == 0:
+			if m := reTaskHeader.FindStringSubmatchIndex(line); m != nil && newTitle != "" {
+				line = line[:m[2]] + newTitle
+			}
+		case !contextDone && reContext.MatchString(trimmed):
+			line, contextDone = "**Context:** "+strings.TrimSpace(e.Context), true
+		case !labelsDone && reLabelsLine.MatchString(line):
+			labelsDone = true
+			if len(e.Labels) == 0 {
+				continue
+			}
+			line = labelsLine(e.Labels)
+		case reACHeader.MatchString(trimmed):
+			inCriteria = true
+		case inCriteria && e.Criteria != nil && reACItem.MatchString(trimmed):
+			c := parseCriterion(trimmed)
+			if c.Checked {
+				c.Checked = false
+				checked[c] = true
+			}
+			// The new criteria take the place of the first old one
+			if criteriaAt < 0 {
+				criteriaAt = len(out)
+			}
+			continue
+		}
+		out = append(out, line)
+	}
+
+	if e.Criteria != nil {
+		if criteriaAt < 0 {
+			header := firstLine(out, func(line string) bool { return reACHeader.MatchString(strings.TrimSpace(line)) })
+			if header < 0 {
+				return nil, errors.New("it has no **Acceptance Criteria:** list")
+			}
+			out = insertLines(out, header+1, "")
+			criteriaAt = header + 2
+		}
+		out = insertLines(out, criteriaAt, criteriaLines(e.Criteria, checked)...)
+	}
+	if !labelsDone && len(e.Labels) > 0 {
+		at := firstLine(out, reDependenciesLine.MatchString)
+		if at < 0 {
+			at = len(out)
+			for at > 1 && strings.TrimSpace(out[at-1]) == "" {
+				at--
+			}
+		}
+		out = insertLines(out, at, labelsLine(e.Labels))
+	}
+	if !contextDone {
+		at := firstLine(out, func(line string) bool { return reACHeader.MatchString(strings.TrimSpace(line)) })
+		if at < 0 {
+			at = 1
+		}
+		out = insertLines(out, at, "**Context:** "+strings.TrimSpace(e.Context))
+	}
+	return out, nil
+}
+
+// criteriaLines renders criteria as checklist items, ticking those in
+// checked
+func criteriaLines(criteria []string, checked map[Criterion]bool) []string {
+	lines := make([]string, len(criteria))
+	for i, text := range criteria {
+		line := "* [ ] " + strings.Join(strings.Fields(text), " ")
+		if checked[parseCriterion(line)] {
+			line = "* [x] " + strings.TrimPrefix(line, "* [ ] ")
+		}
+		lines[i] = line
+	}
+	return lines
+}
+
+// labelsLine renders a "**Labels:**" line
+func labelsLine(labels []string) string {
+	bracketed := make([]string, len(labels))
+	for i, l := range labels {
+		bracketed[i] = "[" + strings.TrimSpace(l) + "]"
+	}
+	return "**Labels:** " + strings.Join(bracketed, " ")
+}
+
+// firstLine returns the index of the first line matching, or -1
+func firstLine(lines []string, match func(string) bool) int {
+	for i, line := range lines {
+		if match(line) {
+			return i
+		}
+	}
+	return -1
+}
+
+// insertLines inserts items into lines at index at
+func insertLines(lines []string, at int, items ...string) []string {
+	return append(append(append([]string{}, lines[:at]...), items...), lines[at:]...)
+}
+
+// renameProgressEntries rewrites the title of a task's progress.md entries,
+// keeping their timestamps, notes and fields. Wrapped entries end up on one
+// line.
+func renameProgressEntries(progressMd string, oldTitle string, newTitle string) string {
+	lines := unwrapEntries(strings.Split(progressMd, "\n"))
+	for i, line := range lines {
+		if progressLineTitle(line, oldTitle) != oldTitle {
+			continue
+		}
+		at := strings.Index(line, "]")
+		if rest, ok := matchTitle(strings.TrimSpace(line[at+1:]), oldTitle); ok {
+			lines[i] = line[:at+1] + " " + entryTitle(newTitle) + rest
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// reErrorLine is the line number a validation error starts with
+var reErrorLine = regexp.MustCompile(`^Line \d+: `)
+
+// CheckStructureKept reports the validation errors a change to tasks.md
+// adds, so it isn't blamed for problems that were already there. Errors
+// are compared without their line numbers, which the change may shift.
+func CheckStructureKept(before string, after string) error {
+	had := make(map[string]int)
+	for _, e := range ValidateTasksStructure(before).Errors {
+		had[reErrorLine.ReplaceAllString(e, "")]++
+	}
+	var added []string
+	for _, e := range ValidateTasksStructure(after).Errors {
+		if key := reErrorLine.ReplaceAllString(e, ""); had[key] > 0 {
+			had[key]--
+		} else {
+			added = append(added, e)
+		}
+	}
+	if len(added) > 0 {
+		return fmt.Errorf("the change would leave tasks.md invalid: %s", strings.Join(added, "; "))
+	}
+	return nil
+}
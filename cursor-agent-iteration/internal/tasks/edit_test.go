@@ -0,0 +1,113 @@
+package tasks
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const editTasksMd = `# Tasks
+
+## Current Tasks
+
+### Task: Rate limit login
+
+**Context:** Brute-force attempts show up in the logs.
+**Acceptance Criteria:**
+
+* [x] Logins are rate limited
+* [ ] [docs] Limits documented
+
+**Files to Modify:** api/login.go
+**Labels:** [milestone:auth]
+
+### Task: Lock accounts
+
+**Context:** After repeated failures.
+**Acceptance Criteria:**
+
+* [ ] Locks after 5 failures
+
+**Dependencies:** ` + "`Rate limit login`" + `, ADR-012
+`
+
+func TestEditTask(t *testing.T) {
+	progress := "# Progress Log\n\n## Completed Tasks\n\n- ✅ [2025-01-08 10:00] Rate limit login - first pass | ticket: SEC-1\n- ✅ [2025-01-08 11:00] Rate limit login page - unrelated\n"
+	md, newProgress, err := EditTask(editTasksMd, progress, "Rate limit login", TaskEdit{
+		Title:    "Rate limit login - per IP",
+		Context:  "Attackers rotate accounts, not addresses.",
+		Criteria: []string{"Logins are rate limited", "[docs] Limits documented", "Limits are per IP"},
+		Labels:   []string{"milestone:auth", "area:login"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := ParseTasks(md)
+	task := all[0]
+	if task.Title != "Rate limit login - per IP" || task.Context != "Attackers rotate accounts, not addresses." {
+		t.Errorf("Unexpected task %+v", task)
+	}
+	wantCriteria := []Criterion{
+		{Text: "Logins are rate limited", Checked: true, Category: CategoryFunctional},
+		{Text: "Limits documented", Category: "docs"},
+		{Text: "Limits are per IP", Category: CategoryFunctional},
+	}
+	if !reflect.DeepEqual(task.Criteria, wantCriteria) {
+		t.Errorf("Criteria = %+v", task.Criteria)
+	}
+	if !reflect.DeepEqual(task.Labels, []string{"milestone:auth", "area:login"}) {
+		t.Errorf("Labels = %q", task.Labels)
+	}
+	if !reflect.DeepEqual(all[1].Dependencies, []string{"Rate limit login - per IP"}) {
+		t.Errorf("Expected the dependency to follow the rename, got %q", all[1].Dependencies)
+	}
+	entries := ParseProgressFor(newProgress, []string{"Rate limit login - per IP", "Rate limit login page"})
+	if e := entries["Rate limit login - per IP"]; e.Notes != "first pass" || len(e.Fields) != 1 {
+		t.Errorf("Expected the progress entry to follow the rename, got %+v:\n%s", e, newProgress)
+	}
+	if _, ok := entries["Rate limit login page"]; !ok {
+		t.Errorf("Expected the entry of another task to stay:\n%s", newProgress)
+	}
+
+	// Removing the labels, and adding them back to a task that has none
+	md, _, err = EditTask(editTasksMd, "", "Rate limit login", TaskEdit{Labels: []string{}})
+	if err != nil || strings.Contains(md, "**Labels:**") {
+		t.Errorf("Expected the labels removed, got %v:\n%s", err, md)
+	}
+	md, _, err = EditTask(editTasksMd, "", "Lock accounts", TaskEdit{Labels: []string{"area:login"}})
+	if err != nil || !strings.Contains(md, "**Labels:** [area:login]\n**Dependencies:**") {
+		t.Errorf("Expected the labels before the dependencies, got %v:\n%s", err, md)
+	}
+}
+
+func TestEditTaskRefused(t *testing.T) {
+	for name, tc := range map[string]struct {
+		title string
+		edit  TaskEdit
+	}{
+		"missing task":     {"Missing", TaskEdit{Context: "x"}},
+		"taken title":      {"Lock accounts", TaskEdit{Title: "rate limit login"}},
+		"empty criteria":   {"Lock accounts", TaskEdit{Criteria: []string{}}},
+		"two-line context": {"Lock accounts", TaskEdit{Context: "a\nb"}},
+		"bracketed label":  {"Lock accounts", TaskEdit{Labels: []string{"[area:x]"}}},
+		"no criteria list": {"Lock accounts", TaskEdit{Criteria: []string{"a"}}},
+	} {
+		md := editTasksMd
+		if name == "no criteria list" {
+			md = strings.Replace(md, "**Acceptance Criteria:**\n\n* [ ] Locks after 5 failures\n", "", 1)
+		}
+		if _, _, err := EditTask(md, "", tc.title, tc.edit); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestCheckStructureKept(t *testing.T) {
+	broken := strings.Replace(editTasksMd, "**Context:** After repeated failures.\n", "", 1)
+	if err := CheckStructureKept(editTasksMd, broken); err == nil {
+		t.Error("Expected a task that lost its context to be reported")
+	}
+	if err := CheckStructureKept(broken, "\n\n"+broken); err != nil {
+		t.Errorf("Expected an error that was already there to be let through, got %v", err)
+	}
+}
@@ -0,0 +1,236 @@
+package tasks
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Custom fields follow the core of a progress entry as " | name: value"
+// segments, as in "- ✅ [2025-01-08 19:00] Title - notes | ticket: ABC-123"
+const fieldSeparator = " | "
+
+// DefaultCompletionFormat is the completion entry used when none is configured
+const DefaultCompletionFormat = "- ✅ [{date}] {title} - {notes}"
+
+// completionCore is the part every completion format must start with, so
+// entries stay parseable
+const completionCore = "- ✅ [{date}] {title}"
+
+var (
+	reFieldSegment = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9_ -]*?):\s*(.*)$`)
+	rePlaceholder  = regexp.MustCompile(`\{([a-z][a-z0-9_-]*)\}`)
+)
+
+// Field is a custom field of a progress entry
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Field returns the value of a custom field of the entry
+func (e ProgressEntry) Field(name string) (string, bool) {
+	for _, f := range e.Fields {
+		if strings.EqualFold(f.Name, name) {
+			return f.Value, true
+		}
+	}
+	return "", false
+}
+
+// splitEntry splits the part of a progress entry after its timestamp into
+// the task title, the notes and any custom fields. Only trailing segments of
+// the form "name: value" are fields; other " | " text stays in the notes.
+//
+// When one of the known task titles starts the entry, bare or quoted, it is
+// the title even if it contains separators; the longest such title wins. A
+// wrapped entry is matched as a whole and then by its first line, the rest
+// being notes. Otherwise a quoted title is unwrapped, and an unquoted one
+// ends at the first " - ", " — " or " – ".
+func splitEntry(remainder string, titles ...string) (title string, notes string, fields []Field) {
+	remainder = strings.TrimSpace(remainder)
+	first, wrapped, _ := strings.Cut(remainder, "\n")
+	for _, entry := range []string{remainder, first} {
+		match, rest := "", ""
+		for _, t := range titles {
+			if after, ok := matchTitle(entry, t); ok && len(t) > len(match) {
+				match, rest = t, after
+			}
+		}
+		if match != "" {
+			if entry == first && wrapped != "" {
+				rest += " " + normalizeTitle(wrapped)
+			}
+			notes, fields = splitNotes(rest)
+			return match, notes, fields
+		}
+	}
+
+	remainder = normalizeTitle(remainder)
+	if t, rest, ok := unquoteTitle(remainder); ok {
+		notes, fields = splitNotes(rest)
+		return strings.TrimSpace(t), notes, fields
+	}
+	core, fields := splitFields(remainder)
+	title, notes = cutSeparator(core)
+	return strings.TrimSpace(title), strings.TrimSpace(notes), fields
+}
+
+// splitNotes splits the text after an entry's title into notes and fields
+func splitNotes(rest string) (notes string, fields []Field) {
+	core, fields := splitFields(rest)
+	core = strings.TrimPrefix(core, fieldSeparator)
+	for _, sep := range entrySeparators {
+		if after, ok := strings.CutPrefix(core, sep); ok {
+			core = after
+			break
+		}
+	}
+	return strings.TrimSpace(core), fields
+}
+
+// splitFields splits the trailing custom field segments off an entry
+func splitFields(text string) (core string, fields []Field) {
+	segments := strings.Split(text, fieldSeparator)
+	n := len(segments)
+	for n > 1 && reFieldSegment.MatchString(strings.TrimSpace(segments[n-1])) {
+		n--
+	}
+	for _, segment := range segments[n:] {
+		m := reFieldSegment.FindStringSubmatch(strings.TrimSpace(segment))
+		fields = append(fields, Field{Name: strings.TrimSpace(m[1]), Value: strings.TrimSpace(m[2])})
+	}
+	return strings.Join(segments[:n], fieldSeparator), fields
+}
+
+// formatFields renders custom fields as trailing segments
+func formatFields(fields []Field) string {
+	var b strings.Builder
+	for _, f := range fields {
+		if f.Value != "" {
+			b.WriteString(fieldSeparator + f.Name + ": " + f.Value)
+		}
+	}
+	return b.String()
+}
+
+// CompletionFormat renders completion entries from a template such as
+// "- ✅ [{date}] {title} - {notes} | ticket: {ticket} | reviewer: {reviewer}".
+// The template starts with the core "- ✅ [{date}] {title}", optionally
+// followed by " - {notes}", and then by custom field segments.
+type CompletionFormat struct {
+	notes  bool
+	fields []Field // values are templates
+}
+
+// ParseCompletionFormat checks a completion template; "" is the default format
+func ParseCompletionFormat(template string) (CompletionFormat, error) {
+	if strings.TrimSpace(template) == "" {
+		template = DefaultCompletionFormat
+	}
+	rest, ok := strings.CutPrefix(strings.TrimSpace(template), completionCore)
+	if !ok {
+		return CompletionFormat{}, fmt.Errorf("completion format must start with %q", completionCore)
+	}
+	var f CompletionFormat
+	segments := strings.Split(rest, fieldSeparator)
+	switch strings.TrimSpace(segments[0]) {
+	case "":
+	case "- {notes}":
+		f.notes = true
+	default:
+		return CompletionFormat{}, fmt.Errorf("completion format: expected \" - {notes}\" or \" | name: value\" after the title, got %q", segments[0])
+	}
+	for _, segment := range segments[1:] {
+		m := reFieldSegment.FindStringSubmatch(strings.TrimSpace(segment))
+		if m == nil || m[2] == "" {
+			return CompletionFormat{}, fmt.Errorf("completion format: field %q is not \"name: value\"", segment)
+		}
+		for _, p := range rePlaceholder.FindAllStringSubmatch(m[2], -1) {
+			if p[1] == "date" || p[1] == "title" || p[1] == "notes" {
+				return CompletionFormat{}, fmt.Errorf("completion format: {%s} can't be used in field %q", p[1], m[1])
+			}
+		}
+		f.fields = append(f.fields, Field{Name: strings.TrimSpace(m[1]), Value: m[2]})
+	}
+	return f, nil
+}
+
+// Custom reports whether the format has fields beyond the default entry
+func (f CompletionFormat) Custom() bool {
+	return len(f.fields) > 0 || !f.notes
+}
+
+// Format renders a completion entry. Placeholders in fields are filled from
+// values; fields left empty are omitted.
+func (f CompletionFormat) Format(at time.Time, title string, notes string, values map[string]string) string {
+	entry := fmt.Sprintf("- ✅ [%s] %s", at.Format("2006-01-02 15:04"), entryTitle(title))
+	if f.notes && notes != "" {
+		entry += " - " + notes
+	}
+	var fields []Field
+	for _, field := range f.fields {
+		value := rePlaceholder.ReplaceAllStringFunc(field.Value, func(p string) string {
+			return values[strings.Trim(p, "{}")]
+		})
+		fields = append(fields, Field{Name: field.Name, Value: strings.TrimSpace(value)})
+	}
+	return entry + formatFields(fields)
+}
+
+// Example shows the format with descriptive placeholders, for prompts
+func (f CompletionFormat) Example() string {
+	entry := "- ✅ [YYYY-MM-DD HH:MM] Task Title"
+	if f.notes {
+		entry += " - completion notes"
+	}
+	var fields []Field
+	for _, field := range f.fields {
+		fields = append(fields, Field{Name: field.Name, Value: rePlaceholder.ReplaceAllString(field.Value, "<$1>")})
+	}
+	return entry + formatFields(fields)
+}
+
+// PromptNote tells agents to write completion entries in a custom format,
+// or is "" for the default format
+func (f CompletionFormat) PromptNote() string {
+	if !f.Custom() {
+		return ""
+	}
+	return fmt.Sprintf("Write the completion entry in .cursor-iter/progress.md as \"%s\" instead of the format above. Fill in each field after a \" | \"; leave out fields you have no value for.", f.Example())
+}
+
+// SetCompletionFields sets custom fields of a task's completion entry,
+// replacing fields of the same names and appending the others. progressMd
+// is returned unchanged when the task has no completion entry.
+func SetCompletionFields(progressMd string, taskTitle string, fields []Field) string {
+	lines := unwrapEntries(strings.Split(progressMd, "\n"))
+	inCompleted := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "## ") {
+			inCompleted = trimmed == "## Completed Tasks"
+			continue
+		}
+		if !inCompleted || !strings.Contains(line, "✅") || progressLineTitle(line, taskTitle) != taskTitle {
+			continue
+		}
+		core, existing := splitFields(strings.TrimRight(line, " "))
+		for _, f := range fields {
+			replaced := false
+			for j := range existing {
+				if strings.EqualFold(existing[j].Name, f.Name) {
+					existing[j].Value = f.Value
+					replaced = true
+				}
+			}
+			if !replaced {
+				existing = append(existing, f)
+			}
+		}
+		lines[i] = core + formatFields(existing)
+		return strings.Join(lines, "\n")
+	}
+	return progressMd
+}
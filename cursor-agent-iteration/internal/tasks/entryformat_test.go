@@ -0,0 +1,110 @@
+package tasks
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseProgressFields(t *testing.T) {
+	progress := `# Progress Log
+
+## In Progress
+
+- 🔄 [2025-01-08 10:00] Search | ticket: ABC-7
+
+## Blocked
+
+- ⛔ [2025-01-08 11:00] Deploy - needs creds | owner: ops
+
+## Completed Tasks
+
+- ✅ [2025-01-08 19:00] Login - done, see PR | tests | ticket: ABC-123 | reviewer: @sam
+- ✅ [2025-01-08 20:00] Logout - shipped
+`
+	entries := ParseProgress(progress)
+	login := entries["Login"]
+	if login.Status != "completed" || login.Notes != "done, see PR | tests" {
+		t.Errorf("Login = %+v", login)
+	}
+	expected := []Field{{Name: "ticket", Value: "ABC-123"}, {Name: "reviewer", Value: "@sam"}}
+	if !reflect.DeepEqual(login.Fields, expected) {
+		t.Errorf("Login fields = %+v, want %+v", login.Fields, expected)
+	}
+	if v, ok := entries["Search"].Field("Ticket"); !ok || v != "ABC-7" || entries["Search"].Status != "in-progress" {
+		t.Errorf("Search = %+v", entries["Search"])
+	}
+	if deploy := entries["Deploy"]; deploy.Status != "blocked" || deploy.Notes != "needs creds" || len(deploy.Fields) != 1 {
+		t.Errorf("Deploy = %+v", deploy)
+	}
+	if logout := entries["Logout"]; logout.Notes != "shipped" || logout.Fields != nil {
+		t.Errorf("Logout = %+v", logout)
+	}
+	if !IsTaskCompleted(progress, "Login") || !IsTaskBlocked(progress, "Deploy") {
+		t.Errorf("Expected statuses to survive custom fields")
+	}
+}
+
+func TestCompletionFormat(t *testing.T) {
+	at := time.Date(2025, 1, 8, 19, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		template string
+		notes    string
+		values   map[string]string
+		expected string
+		example  string
+	}{
+		{"default", "", "done", nil, "- ✅ [2025-01-08 19:00] Login - done", "- ✅ [YYYY-MM-DD HH:MM] Task Title - completion notes"},
+		{"fields", DefaultCompletionFormat + " | ticket: {ticket} | reviewer: {reviewer}", "done", map[string]string{"ticket": "ABC-1"},
+			"- ✅ [2025-01-08 19:00] Login - done | ticket: ABC-1", "- ✅ [YYYY-MM-DD HH:MM] Task Title - completion notes | ticket: <ticket> | reviewer: <reviewer>"},
+		{"no notes", "- ✅ [{date}] {title} | team: core", "ignored", nil, "- ✅ [2025-01-08 19:00] Login | team: core", "- ✅ [YYYY-MM-DD HH:MM] Task Title | team: core"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseCompletionFormat(tt.template)
+			if err != nil {
+				t.Fatalf("ParseCompletionFormat() error = %v", err)
+			}
+			if got := f.Format(at, "Login", tt.notes, tt.values); got != tt.expected {
+				t.Errorf("Format() = %q, want %q", got, tt.expected)
+			}
+			if got := f.Example(); got != tt.example {
+				t.Errorf("Example() = %q, want %q", got, tt.example)
+			}
+			if (f.PromptNote() != "") != (tt.template != "") {
+				t.Errorf("PromptNote() = %q", f.PromptNote())
+			}
+		})
+	}
+
+	for _, bad := range []string{"- [{date}] {title}", "- ✅ [{date}] {title} ({notes})", "- ✅ [{date}] {title} | ticket", "- ✅ [{date}] {title} | when: {date}"} {
+		if _, err := ParseCompletionFormat(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+
+	// Entries written in a custom format parse back
+	f, _ := ParseCompletionFormat(DefaultCompletionFormat + " | ticket: {ticket}")
+	entry := f.Format(at, "Login", "done", map[string]string{"ticket": "ABC-1"})
+	parsed := ParseProgress("## Completed Tasks\n\n" + entry + "\n")["Login"]
+	if v, _ := parsed.Field("ticket"); v != "ABC-1" || parsed.Notes != "done" || !strings.HasPrefix(entry, "- ✅ [2025-01-08 19:00] Login") {
+		t.Errorf("Round trip = %+v", parsed)
+	}
+}
+
+func TestSetCompletionFields(t *testing.T) {
+	progress := "## In Progress\n\n- 🔄 [2025-01-08 18:00] Search\n\n## Completed Tasks\n\n- ✅ [2025-01-08 19:00] Login - done | ticket: ABC-1 | cost: $0.10\n"
+	updated := SetCompletionFields(progress, "Login", []Field{{Name: "tokens", Value: "12,000"}, {Name: "cost", Value: "$0.42"}})
+	if !strings.Contains(updated, "- ✅ [2025-01-08 19:00] Login - done | ticket: ABC-1 | cost: $0.42 | tokens: 12,000\n") {
+		t.Errorf("Expected the cost replaced and tokens added, got:\n%s", updated)
+	}
+	login := ParseProgress(updated)["Login"]
+	if v, _ := login.Field("tokens"); v != "12,000" || login.Notes != "done" {
+		t.Errorf("Updated entry parses as %+v", login)
+	}
+	if got := SetCompletionFields(progress, "Search", []Field{{Name: "cost", Value: "$1"}}); got != progress {
+		t.Errorf("Expected tasks without a completion entry left alone, got:\n%s", got)
+	}
+}
@@ -0,0 +1,26 @@
+package tasks
+
+// MarkTaskFailed moves a task that used up its attempts into the
+// "## Failed" section of progress.md, creating the section before
+// "## Completed Tasks" if needed. Like blocked tasks, failed tasks are
+// neither resumed nor picked up as pending until they are retried.
+func MarkTaskFailed(progressMd string, taskTitle string, reason string) string {
+	return markWithReason(progressMd, "## Failed", failedMark, taskTitle, reason)
+}
+
+// IsTaskFailed checks if a task is marked as failed in progress.md
+func IsTaskFailed(progressMd string, taskTitle string) bool {
+	entry, exists := ParseProgressFor(progressMd, []string{taskTitle})[taskTitle]
+	return exists && entry.Status == "failed"
+}
+
+// GetFailedTasks returns the titles of tasks failed in progress.md
+func GetFailedTasks(progressMd string) []string {
+	var titles []string
+	for title, entry := range ParseProgress(progressMd) {
+		if entry.Status == "failed" {
+			titles = append(titles, title)
+		}
+	}
+	return titles
+}
@@ -0,0 +1,46 @@
+package tasks
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkTaskFailed(t *testing.T) {
+	updated := MarkTaskFailed(MarkTaskBlocked(blockedProgress, "Task B", "waiting"), "Task A", "gave up after 5 failed attempts")
+
+	if !IsTaskFailed(updated, "Task A") || IsTaskInProgress(updated, "Task A") || IsTaskBlocked(updated, "Task A") {
+		t.Fatalf("Expected Task A to be failed:\n%s", updated)
+	}
+	if !IsTaskBlocked(updated, "Task B") || !IsTaskCompleted(updated, "Task C") {
+		t.Errorf("Expected other tasks to keep their status:\n%s", updated)
+	}
+	if entry := ParseProgress(updated)["Task A"]; entry.Notes != "gave up after 5 failed attempts" {
+		t.Errorf("Failed reason = %q", entry.Notes)
+	}
+	if !strings.Contains(updated, "## Failed\n\n- ❌ [") || !strings.Contains(updated, "attempts\n\n## Completed Tasks") {
+		t.Errorf("Unexpected layout:\n%s", updated)
+	}
+	if got := GetFailedTasks(updated); len(got) != 1 || got[0] != "Task A" {
+		t.Errorf("GetFailedTasks() = %v", got)
+	}
+
+	// Failed tasks aren't scheduled, and neither are the tasks waiting on them
+	tasksMd := "## Current Tasks\n\n### Task: Task A\n\n### Task: Task B\n\n### Task: Task C\n\n### Task: Task D\n**Dependencies:** Task A\n"
+	if next := GetNextPendingTaskWithProgress(tasksMd, updated); next != nil {
+		t.Errorf("Expected nothing to schedule, got %q", next.Title)
+	}
+	if !OnlyBlockedRemain(tasksMd, updated) {
+		t.Error("Expected only blocked and failed tasks to remain")
+	}
+	if totals := Summarize(tasksMd, updated).Totals; totals.Failed != 1 || totals.Blocked != 1 || totals.Pending != 1 {
+		t.Errorf("Summarize() totals = %+v", totals)
+	}
+	if report := StatusReportWithProgress(tasksMd, updated); !strings.Contains(report, "❌ Failed: 1") || !strings.Contains(report, "  - ❌ Task A - gave up after 5 failed attempts") {
+		t.Errorf("Expected the failed task in the report:\n%s", report)
+	}
+
+	// A retry makes it pending again
+	if retried := UnblockTask(updated, "Task A"); IsTaskFailed(retried, "Task A") || strings.Contains(retried, "❌") {
+		t.Errorf("Expected Task A to be pending after a retry:\n%s", retried)
+	}
+}
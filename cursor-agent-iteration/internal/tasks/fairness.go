@@ -0,0 +1,124 @@
+package tasks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Fairness policies for choosing the next pending task
+const (
+	FairnessFIFO       = "fifo"       // tasks in tasks.md order
+	FairnessInterleave = "interleave" // the milestone with the fewest running tasks goes first
+	FairnessReserve    = "reserve"    // every milestone is guaranteed a share of the slots
+)
+
+// Fairness decides which milestone gets the next free concurrency slot, so
+// that a long milestone early in tasks.md doesn't starve the later ones
+type Fairness struct {
+	Policy string
+	// Reserve is the fraction of the slots guaranteed to each milestone with
+	// pending tasks under FairnessReserve
+	Reserve float64
+	// Defaults are the label defaults merged into every task, so a
+	// milestone or priority a label implies counts
+	Defaults LabelDefaults
+}
+
+// ParseFairness parses "fifo", "interleave" or "reserve[=fraction]". The
+// default reserve is a quarter of the slots.
+func ParseFairness(spec string) (Fairness, error) {
+	name, value, hasValue := strings.Cut(strings.ToLower(strings.TrimSpace(spec)), "=")
+	switch name {
+	case "", FairnessFIFO:
+		return Fairness{Policy: FairnessFIFO}, nil
+	case FairnessInterleave:
+		return Fairness{Policy: FairnessInterleave}, nil
+	case FairnessReserve:
+		f := Fairness{Policy: FairnessReserve, Reserve: 0.25}
+		if hasValue {
+			r, err := strconv.ParseFloat(value, 64)
+			if err != nil || r <= 0 || r > 1 {
+				return Fairness{}, fmt.Errorf("reserve must be a fraction between 0 and 1, got %q", value)
+			}
+			f.Reserve = r
+		}
+		return f, nil
+	}
+	return Fairness{}, fmt.Errorf("unknown fairness policy %q: want fifo, interleave or reserve=<fraction>", spec)
+}
+
+// TaskMilestone returns the milestone of a task from its [milestone:...]
+// label, or DefaultMilestone
+func TaskMilestone(t Task) string {
+	if m, ok := LabelValue(t.Labels, "milestone"); ok && m != "" {
+		return m
+	}
+	return DefaultMilestone
+}
+
+// NextPendingTask returns the pending task to start next, given the titles
+// of the running tasks and the total number of slots. Tasks whose files
+// overlap claims wait for the claiming tasks to finish; nil claims nothing.
+// Within a milestone, and between milestones the policy treats alike, the
+// higher priority goes first.
+func (f Fairness) NextPendingTask(tasksMd string, progressMd string, running []string, slots int, claims Claims) *Task {
+	if f.Policy == "" || f.Policy == FairnessFIFO {
+		return nextPendingTask(tasksMd, progressMd, claims, f.Defaults)
+	}
+
+	all := parseTasksN(tasksMd, -1, f.Defaults)
+	progressEntries := ParseProgressFor(progressMd, taskTitles(all))
+	graph := NewDependencyGraph(all)
+	isRunning := make(map[string]bool)
+	for _, title := range running {
+		isRunning[title] = true
+	}
+
+	// Milestones in tasks.md order, with their highest-priority pending
+	// task
+	var order []string
+	first := make(map[string]*Task)
+	active := make(map[string]int)
+	for i := range all {
+		t := &all[i]
+		m := TaskMilestone(*t)
+		if isRunning[t.Title] {
+			active[m]++
+		}
+		if _, exists := progressEntries[t.Title]; exists || (first[m] != nil && !morePressing(t, first[m])) {
+			continue
+		}
+		if len(graph.Waiting(t.Title, progressEntries)) > 0 || claims.Conflict(t.Title, t.FileScope) != "" {
+			continue
+		}
+		if first[m] == nil {
+			order = append(order, m)
+		}
+		first[m] = t
+	}
+	if len(order) == 0 {
+		return nil
+	}
+
+	if f.Policy == FairnessReserve {
+		reserved := int(f.Reserve * float64(slots))
+		if reserved < 1 {
+			reserved = 1
+		}
+		for _, m := range order {
+			if active[m] < reserved {
+				return first[m]
+			}
+		}
+		return nextPendingTask(tasksMd, progressMd, claims, f.Defaults)
+	}
+
+	best := order[0]
+	for _, m := range order[1:] {
+		if active[m] < active[best] || (active[m] == active[best] && morePressing(first[m], first[best])) {
+			best = m
+		}
+	}
+	return first[best]
+}
@@ -0,0 +1,89 @@
+package tasks
+
+import (
+	"reflect"
+	"testing"
+)
+
+const fairnessTasks = `# Tasks
+
+## Current Tasks
+
+### Task: A1
+**Labels:** ` + "`[milestone:a]`" + `
+
+### Task: A2
+**Labels:** ` + "`[milestone:a]`" + `
+
+### Task: A3
+**Labels:** ` + "`[milestone:a]`" + `
+
+### Task: B1
+**Labels:** ` + "`[milestone:b]`" + `
+
+### Task: Loose
+`
+
+func TestParseFairness(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Fairness
+		wantErr bool
+	}{
+		{"", Fairness{Policy: FairnessFIFO}, false},
+		{"interleave", Fairness{Policy: FairnessInterleave}, false},
+		{"reserve", Fairness{Policy: FairnessReserve, Reserve: 0.25}, false},
+		{"Reserve=0.5", Fairness{Policy: FairnessReserve, Reserve: 0.5}, false},
+		{"reserve=2", Fairness{}, true},
+		{"random", Fairness{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFairness(tt.spec)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseFairness(%q) = %+v, %v", tt.spec, got, err)
+		}
+	}
+}
+
+func TestNextPendingTask(t *testing.T) {
+	inProgress := func(titles ...string) string {
+		md := "# Progress Log\n\n## In Progress\n\n"
+		for _, title := range titles {
+			md += "- 🔄 [2025-01-08 19:00] " + title + "\n"
+		}
+		return md + "\n## Completed Tasks\n"
+	}
+	tests := []struct {
+		name     string
+		policy   string
+		running  []string
+		slots    int
+		expected string
+	}{
+		{"fifo takes the next task in order", "fifo", []string{"A1"}, 4, "A2"},
+		{"interleave starts an idle milestone", "interleave", []string{"A1"}, 4, "B1"},
+		{"interleave reaches unlabelled tasks", "interleave", []string{"A1", "B1"}, 4, "Loose"},
+		{"interleave prefers the earlier milestone on ties", "interleave", []string{"A1", "B1", "Loose"}, 4, "A2"},
+		{"reserve fills reservations first", "reserve=0.25", []string{"A1"}, 4, "B1"},
+		{"reserve fills earlier reservations first", "reserve=0.5", []string{"A1"}, 4, "A2"},
+		{"reserve falls back to fifo", "reserve=0.25", []string{"A1", "B1", "Loose"}, 4, "A2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseFairness(tt.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			next := f.NextPendingTask(fairnessTasks, inProgress(tt.running...), tt.running, tt.slots, nil)
+			if next == nil || next.Title != tt.expected {
+				t.Errorf("NextPendingTask() = %+v, want %s", next, tt.expected)
+			}
+		})
+	}
+
+	f, _ := ParseFairness("interleave")
+	all := inProgress("A1", "A2", "A3", "B1", "Loose")
+	if next := f.NextPendingTask(fairnessTasks, all, nil, 4, nil); next != nil {
+		t.Errorf("Expected no pending task, got %+v", next)
+	}
+}
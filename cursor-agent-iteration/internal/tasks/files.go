@@ -0,0 +1,113 @@
+package tasks
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	reFilesLine  = regexp.MustCompile(`^(\s*\*\*Files to Modify:\*\*\s*)(.*)$`)
+	reBackticked = regexp.MustCompile("`([^`]+)`")
+)
+
+// ParseFiles returns the paths listed on the "**Files to Modify:**" line of a
+// task block. Backticked items are preferred; otherwise the line is split on
+// commas. Placeholders such as "src/..." or "<module>" and globs are skipped.
+func ParseFiles(taskDetails string) []string {
+	var files []string
+	for _, item := range fileItems(taskDetails) {
+		if isConcretePath(item) {
+			files = append(files, item)
+		}
+	}
+	return files
+}
+
+// ParseFileScope returns the paths and globs a task may change, from its
+// "**Files to Modify:**" line. Unlike ParseFiles it keeps globs, and turns
+// "src/auth/..." into the directory "src/auth/"; other placeholders are
+// skipped.
+func ParseFileScope(taskDetails string) []string {
+	var scope []string
+	for _, item := range fileItems(taskDetails) {
+		if dir, ok := strings.CutSuffix(item, "..."); ok && strings.HasSuffix(dir, "/") {
+			item = dir
+		}
+		if item == "" || strings.EqualFold(item, "none") || strings.EqualFold(item, "n/a") ||
+			strings.ContainsAny(item, "<> ") || strings.Contains(item, "...") {
+			continue
+		}
+		scope = append(scope, item)
+	}
+	return scope
+}
+
+// fileItems splits the "**Files to Modify:**" line into its trimmed items
+func fileItems(taskDetails string) []string {
+	for _, line := range strings.Split(taskDetails, "\n") {
+		m := reFilesLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		var items []string
+		if quoted := reBackticked.FindAllStringSubmatch(m[2], -1); len(quoted) > 0 {
+			for _, q := range quoted {
+				items = append(items, strings.TrimSpace(q[1]))
+			}
+		} else {
+			for _, item := range strings.Split(m[2], ",") {
+				items = append(items, strings.TrimSpace(item))
+			}
+		}
+		return items
+	}
+	return nil
+}
+
+func isConcretePath(item string) bool {
+	if item == "" || strings.EqualFold(item, "none") || strings.EqualFold(item, "n/a") {
+		return false
+	}
+	return !strings.ContainsAny(item, "<>*?[] ") && !strings.Contains(item, "...")
+}
+
+// ReplaceTaskFiles rewrites paths on the "**Files to Modify:**" line of the
+// named task, mapping each old path in renames to its new path. Other tasks
+// and lines are left untouched.
+func ReplaceTaskFiles(tasksMd string, taskTitle string, renames map[string]string) string {
+	if len(renames) == 0 {
+		return tasksMd
+	}
+	lines := strings.Split(tasksMd, "\n")
+	inTask := false
+	for i, line := range lines {
+		if m := reTaskHeader.FindStringSubmatch(line); m != nil {
+			inTask = cleanTaskTitle(m[1]) == taskTitle
+			continue
+		}
+		if !inTask {
+			continue
+		}
+		m := reFilesLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		list := m[2]
+		for oldPath, newPath := range renames {
+			re := regexp.MustCompile("(^|[\\s,`])" + regexp.QuoteMeta(oldPath) + "($|[\\s,`])")
+			list = re.ReplaceAllString(list, "${1}"+strings.ReplaceAll(newPath, "$", "$$")+"${2}")
+		}
+		lines[i] = m[1] + list
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cleanTaskTitle strips status emojis from a task header title, matching how
+// ExtractTaskDetails identifies tasks
+func cleanTaskTitle(title string) string {
+	title = strings.TrimSpace(title)
+	for _, emoji := range []string{"🔄", "✅", "⚠️"} {
+		title = strings.TrimSpace(strings.Replace(title, emoji, "", 1))
+	}
+	return title
+}
@@ -0,0 +1,91 @@
+package tasks
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		details  string
+		expected []string
+	}{
+		{
+			name:     "backticked paths",
+			details:  "### Task: A\n  **Files to Modify:** `src/api.go`, `src/api_test.go`",
+			expected: []string{"src/api.go", "src/api_test.go"},
+		},
+		{
+			name:     "comma separated paths",
+			details:  "**Files to Modify:** file1.go, pkg/file2.go",
+			expected: []string{"file1.go", "pkg/file2.go"},
+		},
+		{
+			name:     "placeholders and globs are skipped",
+			details:  "**Files to Modify:** `src/...`, `tests/*.py`, `<module>/main.go`, `README.md`",
+			expected: []string{"README.md"},
+		},
+		{
+			name:     "no files line",
+			details:  "### Task: A\n**Context:** nothing here",
+			expected: nil,
+		},
+		{
+			name:     "none",
+			details:  "**Files to Modify:** None",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseFiles(tt.details); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("ParseFiles() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseFileScope(t *testing.T) {
+	details := "**Files to Modify:** `src/api.go`, `src/auth/...`, `tests/*.py`, `<module>/main.go`, `None`"
+	want := []string{"src/api.go", "src/auth/", "tests/*.py"}
+	if got := ParseFileScope(details); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseFileScope() = %v, want %v", got, want)
+	}
+}
+
+func TestParseTasksFiles(t *testing.T) {
+	ts := parseTasks(sample)
+	if len(ts) < 2 {
+		t.Fatalf("Expected tasks in sample, got %d", len(ts))
+	}
+	if !reflect.DeepEqual(ts[0].Files, []string{"file1.go"}) {
+		t.Errorf("Task A files = %v", ts[0].Files)
+	}
+	if !reflect.DeepEqual(ts[1].Files, []string{"file2.go"}) {
+		t.Errorf("Task B files = %v", ts[1].Files)
+	}
+}
+
+func TestReplaceTaskFiles(t *testing.T) {
+	md := `## Current Tasks
+
+### Task: Move API
+
+**Files to Modify:** ` + "`src/api.go`, `src/api.go.orig`" + `
+
+### Task: Other
+
+**Files to Modify:** ` + "`src/api.go`" + `
+`
+	got := ReplaceTaskFiles(md, "Move API", map[string]string{"src/api.go": "internal/api/api.go"})
+
+	if !strings.Contains(got, "**Files to Modify:** `internal/api/api.go`, `src/api.go.orig`") {
+		t.Errorf("Expected renamed path in target task, got:\n%s", got)
+	}
+	if !strings.Contains(got, "### Task: Other\n\n**Files to Modify:** `src/api.go`") {
+		t.Errorf("Expected other task to be untouched, got:\n%s", got)
+	}
+}
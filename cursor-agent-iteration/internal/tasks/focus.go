@@ -0,0 +1,35 @@
+package tasks
+
+import "strings"
+
+// OnlyTasks keeps the named tasks in "## Current Tasks" and drops the rest,
+// so whatever picks the next task from the result can only pick one of them.
+// Titles match case-insensitively. Dependencies on a dropped task no longer
+// hold a named task back, which lets a task deep in the backlog jump the
+// queue. It also returns the titles that match no task.
+func OnlyTasks(tasksMd string, titles []string) (string, []string) {
+	keep := make(map[string]bool)
+	for _, title := range titles {
+		keep[strings.ToLower(cleanTaskTitle(title))] = true
+	}
+	lines := strings.Split(tasksMd, "\n")
+	blocks, _ := currentTaskBlocks(lines)
+	found := make(map[string]bool)
+	for k := len(blocks) - 1; k >= 0; k-- {
+		key := strings.ToLower(blocks[k].title)
+		if keep[key] {
+			found[key] = true
+			continue
+		}
+		// Going backwards, removing a block leaves the earlier ones in place
+		start, end := blockRange(lines, blocks, k)
+		lines = append(lines[:start], lines[end:]...)
+	}
+	var missing []string
+	for _, title := range titles {
+		if !found[strings.ToLower(cleanTaskTitle(title))] {
+			missing = append(missing, title)
+		}
+	}
+	return strings.Join(lines, "\n"), missing
+}
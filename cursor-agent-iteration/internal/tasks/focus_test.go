@@ -0,0 +1,36 @@
+package tasks
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOnlyTasks(t *testing.T) {
+	md, missing := OnlyTasks(clusterTasksMd, []string{"session audit", "Signup form", "Missing"})
+	if !reflect.DeepEqual(missing, []string{"Missing"}) {
+		t.Errorf("missing = %q", missing)
+	}
+	var titles []string
+	for _, task := range ParseTasks(md) {
+		titles = append(titles, task.Title)
+	}
+	if !reflect.DeepEqual(titles, []string{"Signup form", "Session audit"}) {
+		t.Fatalf("Expected only the named tasks, got %q:\n%s", titles, md)
+	}
+	if result := ValidateTasksStructure(md); !result.Valid {
+		t.Errorf("Expected a valid tasks.md, got %v:\n%s", result.Errors, md)
+	}
+	// Session audit waits on tasks that were dropped, so it may start now
+	next := GetNextPendingTaskWithProgress(md, "")
+	if next == nil || next.Title != "Signup form" {
+		t.Fatalf("Expected Signup form next, got %+v", next)
+	}
+	progress := "## In Progress\n\n- 🔄 [2025-01-08 10:00] Signup form\n"
+	if next := GetNextPendingTaskWithProgress(md, progress); next == nil || next.Title != "Session audit" {
+		t.Errorf("Expected Session audit to no longer wait on the dropped tasks, got %+v", next)
+	}
+
+	if md, missing := OnlyTasks(clusterTasksMd, []string{"Rate limit login", "Add user model"}); len(missing) != 0 || len(ParseTasks(md)) != 2 {
+		t.Errorf("Expected the first and last task kept, got %q:\n%s", missing, md)
+	}
+}
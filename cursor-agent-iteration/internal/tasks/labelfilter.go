@@ -0,0 +1,139 @@
+package tasks
+
+import "strings"
+
+// LabelFilter picks tasks by their labels, e.g. to run only the bug fixes
+// tonight. A task passes when it has one of the Include labels, or any
+// labels at all when there are none, and none of the Exclude labels. Each
+// label is "key:value", or a bare key matching any value.
+type LabelFilter struct {
+	Include []string
+	Exclude []string
+	// Defaults are the label defaults merged into every task, so a task
+	// passes or fails by the labels its own imply too
+	Defaults LabelDefaults
+}
+
+// ParseLabelFilter reads comma-separated --label and --exclude-label values
+func ParseLabelFilter(include, exclude string) LabelFilter {
+	return LabelFilter{Include: splitLabels(include), Exclude: splitLabels(exclude)}
+}
+
+func splitLabels(s string) []string {
+	var labels []string
+	for _, l := range strings.Split(s, ",") {
+		if l = strings.Trim(strings.TrimSpace(l), "[]"); l != "" {
+			labels = append(labels, l)
+		}
+	}
+	return labels
+}
+
+// IsZero reports whether the filter passes every task
+func (f LabelFilter) IsZero() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0
+}
+
+// String describes the filter, e.g. "labeled type:bugfix, not labeled
+// area:infra"
+func (f LabelFilter) String() string {
+	var parts []string
+	if len(f.Include) > 0 {
+		parts = append(parts, "labeled "+strings.Join(f.Include, " or "))
+	}
+	if len(f.Exclude) > 0 {
+		parts = append(parts, "not labeled "+strings.Join(f.Exclude, " or "))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Matches reports whether a task with these labels passes the filter
+func (f LabelFilter) Matches(labels []string) bool {
+	for _, l := range f.Exclude {
+		if HasLabel(labels, l) {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, l := range f.Include {
+		if HasLabel(labels, l) {
+			return true
+		}
+	}
+	return false
+}
+
+// Keep returns tasks.md with only the tasks that pass the filter
+func (f LabelFilter) Keep(tasksMd string) string {
+	if f.IsZero() {
+		return tasksMd
+	}
+	var titles []string
+	for _, t := range parseTasksN(tasksMd, -1, f.Defaults) {
+		if f.Matches(t.Labels) {
+			titles = append(titles, t.Title)
+		}
+	}
+	kept, _ := OnlyTasks(tasksMd, titles)
+	return kept
+}
+
+// KeepRunnable is Keep for picking the next task. A task that passes but
+// waits, directly or further down, on an unfinished task that doesn't can't
+// start while the filter holds, so it is left out too and returned in held.
+// Prerequisites that pass stay, so the order among the tasks kept holds.
+func (f LabelFilter) KeepRunnable(tasksMd string, progressMd string) (kept string, held []string) {
+	if f.IsZero() {
+		return tasksMd, nil
+	}
+	all := parseTasksN(tasksMd, -1, f.Defaults)
+	graph := NewDependencyGraph(all)
+	entries := ParseProgressFor(progressMd, taskTitles(all))
+	passes := make(map[string]bool)
+	for _, t := range all {
+		passes[t.Title] = f.Matches(t.Labels)
+	}
+	// A task is held back by a prerequisite left out, or held itself
+	memo := make(map[string]bool)
+	var heldBack func(title string, visiting map[string]bool) bool
+	heldBack = func(title string, visiting map[string]bool) bool {
+		if v, ok := memo[title]; ok {
+			return v
+		}
+		if visiting[title] {
+			// Tasks in a cycle never start anyway
+			return false
+		}
+		visiting[title] = true
+		defer delete(visiting, title)
+		result := false
+		for _, p := range graph.Prerequisites(title) {
+			if entries[p].Status == "completed" {
+				continue
+			}
+			if !passes[p] || heldBack(p, visiting) {
+				result = true
+				break
+			}
+		}
+		memo[title] = result
+		return result
+	}
+
+	var titles []string
+	for _, t := range all {
+		if !passes[t.Title] {
+			continue
+		}
+		// Tasks already started or done stay as they are
+		if status := entries[t.Title].Status; status != "completed" && status != "in-progress" && heldBack(t.Title, make(map[string]bool)) {
+			held = append(held, t.Title)
+			continue
+		}
+		titles = append(titles, t.Title)
+	}
+	kept, _ = OnlyTasks(tasksMd, titles)
+	return kept, held
+}
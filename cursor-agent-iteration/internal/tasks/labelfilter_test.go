@@ -0,0 +1,66 @@
+package tasks
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLabelFilterMatches(t *testing.T) {
+	labels := []string{"type:bugfix", "area:login", "area:api", "urgent"}
+	for spec, want := range map[[2]string]bool{
+		{"", ""}:                        true,
+		{"type:bugfix", ""}:             true,
+		{"TYPE:BugFix", ""}:             true,
+		{"type:feature", ""}:            false,
+		{"type:feature,area:api", ""}:   true,
+		{"area", ""}:                    true,
+		{"urgent", ""}:                  true,
+		{"", "area:api"}:                false,
+		{"type:bugfix", "[area:infra]"}: true,
+		{"type", "urgent"}:              false,
+	} {
+		f := ParseLabelFilter(spec[0], spec[1])
+		if got := f.Matches(labels); got != want {
+			t.Errorf("%s: Matches() = %v, want %v", f, got, want)
+		}
+	}
+}
+
+func TestLabelFilterKeep(t *testing.T) {
+	f := ParseLabelFilter("area:login", "")
+	var titles []string
+	for _, task := range ParseTasks(f.Keep(clusterTasksMd)) {
+		titles = append(titles, task.Title)
+	}
+	if !reflect.DeepEqual(titles, []string{"Lock accounts", "Log failed logins"}) {
+		t.Errorf("Keep() kept %q", titles)
+	}
+	if md := (LabelFilter{}).Keep(clusterTasksMd); md != clusterTasksMd {
+		t.Error("Expected the zero filter to keep everything")
+	}
+}
+
+func TestLabelFilterKeepRunnable(t *testing.T) {
+	// Lock accounts waits on Add user model, which has no milestone label;
+	// Session audit waits on Lock accounts
+	f := ParseLabelFilter("milestone:auth", "")
+	md, held := f.KeepRunnable(clusterTasksMd, "")
+	if !reflect.DeepEqual(held, []string{"Lock accounts", "Session audit"}) {
+		t.Errorf("held = %q", held)
+	}
+	if strings.Contains(md, "### Task: Lock accounts") || !strings.Contains(md, "### Task: Rate limit login") {
+		t.Errorf("Expected the held task left out:\n%s", md)
+	}
+
+	// Once Add user model is done they may start, in order
+	progress := "## Completed Tasks\n\n- ✅ [2025-01-08 10:00] Add user model\n"
+	md, held = f.KeepRunnable(clusterTasksMd, progress)
+	if len(held) != 0 {
+		t.Errorf("Expected nothing held, got %q", held)
+	}
+	all := ParseTasks(md)
+	if len(all) != 6 || !reflect.DeepEqual(all[5].Dependencies, []string{"Lock accounts", "Rate limit login"}) {
+		t.Errorf("Expected the milestone's tasks with their dependencies, got %+v", all)
+	}
+}
@@ -0,0 +1,184 @@
+package tasks
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	reLabelsLine = regexp.MustCompile(`^\s*\*\*Labels:\*\*\s*(.*)$`)
+	reLabel      = regexp.MustCompile(`\[([^\[\]]+)\]`)
+)
+
+// LabelExclusive marks a task that must not run alongside any other task,
+// e.g. schema migrations and large refactors: "[exclusive:true]"
+const LabelExclusive = "exclusive"
+
+// LabelDefaults maps a label to the labels it implies, e.g. "area:infra" to
+// "milestone:infra" and "exclusive:true", so tasks don't need every label
+// spelled out. An implied "priority:P0" also sets the priority of tasks
+// without a "**Priority:**" line. Keys are matched ignoring case.
+type LabelDefaults map[string][]string
+
+// ParseLabels returns the labels of a task block followed by the labels
+// they imply
+func (d LabelDefaults) ParseLabels(taskDetails string) []string {
+	return d.Apply(ParseLabels(taskDetails))
+}
+
+// ParseTasks is ParseTasks with the defaults merged into every task
+func (d LabelDefaults) ParseTasks(md string) []Task {
+	return parseTasksN(md, -1, d)
+}
+
+// Apply returns labels followed by the labels they imply, in order. An
+// implied label is left out when the task already has a label with its key,
+// so a task can override a default; implied labels don't imply others.
+func (d LabelDefaults) Apply(labels []string) []string {
+	if len(d) == 0 {
+		return labels
+	}
+	has := make(map[string]bool)
+	for _, l := range labels {
+		has[labelKey(l)] = true
+	}
+	implies := make(map[string][]string, len(d))
+	for label, implied := range d {
+		implies[normalizeLabel(label)] = implied
+	}
+	merged := append([]string(nil), labels...)
+	for _, l := range labels {
+		for _, implied := range implies[normalizeLabel(l)] {
+			if implied = strings.TrimSpace(implied); implied == "" || has[labelKey(implied)] {
+				continue
+			}
+			has[labelKey(implied)] = true
+			merged = append(merged, implied)
+		}
+	}
+	return merged
+}
+
+// labelKey is the lower-cased key of a "key:value" or bare label
+func labelKey(label string) string {
+	k, _, _ := strings.Cut(label, ":")
+	return strings.ToLower(strings.TrimSpace(k))
+}
+
+// normalizeLabel lower-cases a label and drops spaces around its colon
+func normalizeLabel(label string) string {
+	k, v, found := strings.Cut(label, ":")
+	if !found {
+		return labelKey(label)
+	}
+	return labelKey(k) + ":" + strings.ToLower(strings.TrimSpace(v))
+}
+
+// ParseLabels returns the labels on the "**Labels:**" line of a task block,
+// e.g. "**Labels:** `[type:feature] [exclusive:true]`" gives
+// ["type:feature", "exclusive:true"]
+func ParseLabels(taskDetails string) []string {
+	for _, line := range strings.Split(taskDetails, "\n") {
+		m := reLabelsLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		var labels []string
+		for _, l := range reLabel.FindAllStringSubmatch(m[1], -1) {
+			if label := strings.TrimSpace(l[1]); label != "" {
+				labels = append(labels, label)
+			}
+		}
+		return labels
+	}
+	return nil
+}
+
+// LabelValue returns the value of a "key:value" label, matching the key
+// case-insensitively. A bare "key" label has the value "true".
+func LabelValue(labels []string, key string) (string, bool) {
+	for _, label := range labels {
+		k, v, found := strings.Cut(label, ":")
+		if !strings.EqualFold(strings.TrimSpace(k), key) {
+			continue
+		}
+		if !found {
+			return "true", true
+		}
+		return strings.TrimSpace(v), true
+	}
+	return "", false
+}
+
+// HasLabel reports whether labels include want, a "key:value" label or a
+// bare key matching any value, ignoring case. Unlike LabelValue it looks
+// past the first label with the key.
+func HasLabel(labels []string, want string) bool {
+	key, value, hasValue := strings.Cut(want, ":")
+	for _, label := range labels {
+		if v, ok := LabelValue([]string{label}, strings.TrimSpace(key)); ok && (!hasValue || strings.EqualFold(v, strings.TrimSpace(value))) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsExclusive reports whether a task's labels include [exclusive:true]
+func IsExclusive(labels []string) bool {
+	v, ok := LabelValue(labels, LabelExclusive)
+	return ok && strings.EqualFold(v, "true")
+}
+
+// SetTaskLabel sets a "key:value" label on the named task in tasks.md,
+// replacing any existing label with that key. A "**Labels:**" line is added
+// at the end of the task block if it has none.
+func SetTaskLabel(tasksMd string, taskTitle string, key string, value string) string {
+	label := "[" + key + ":" + value + "]"
+	lines := strings.Split(tasksMd, "\n")
+	start, end := -1, len(lines)
+	for i, line := range lines {
+		if m := reTaskHeader.FindStringSubmatch(line); m != nil {
+			if start >= 0 {
+				end = i
+				break
+			}
+			if cleanTaskTitle(m[1]) == taskTitle {
+				start = i
+			}
+		} else if start >= 0 && strings.HasPrefix(strings.TrimSpace(line), "## ") {
+			end = i
+			break
+		}
+	}
+	if start < 0 {
+		return tasksMd
+	}
+
+	for i := start + 1; i < end; i++ {
+		m := reLabelsLine.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+		prefix := lines[i][:len(lines[i])-len(m[1])]
+		var kept []string
+		for _, l := range reLabel.FindAllStringSubmatch(m[1], -1) {
+			k, _, _ := strings.Cut(l[1], ":")
+			if !strings.EqualFold(strings.TrimSpace(k), key) {
+				kept = append(kept, "["+strings.TrimSpace(l[1])+"]")
+			}
+		}
+		kept = append(kept, label)
+		lines[i] = prefix + "`" + strings.Join(kept, " ") + "`"
+		return strings.Join(lines, "\n")
+	}
+
+	// No labels line: add one after the last non-blank line of the block
+	insert := end
+	for insert > start+1 && strings.TrimSpace(lines[insert-1]) == "" {
+		insert--
+	}
+	out := append([]string{}, lines[:insert]...)
+	out = append(out, "**Labels:** `"+label+"`")
+	out = append(out, lines[insert:]...)
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,181 @@
+package tasks
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLabels(t *testing.T) {
+	tests := []struct {
+		name     string
+		details  string
+		expected []string
+	}{
+		{
+			name:     "backticked labels",
+			details:  "### Task: A\n  **Labels:** `[type:feature] [area:db] [exclusive:true]`",
+			expected: []string{"type:feature", "area:db", "exclusive:true"},
+		},
+		{
+			name:     "plain labels",
+			details:  "**Labels:** [type:feature]",
+			expected: []string{"type:feature"},
+		},
+		{
+			name:     "no labels line",
+			details:  "**Context:** [not:a-label]",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseLabels(tt.details); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("ParseLabels() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIsExclusive(t *testing.T) {
+	tests := []struct {
+		details  string
+		expected bool
+	}{
+		{"**Labels:** `[type:migration] [exclusive:true]`", true},
+		{"**Labels:** `[Exclusive: TRUE]`", true},
+		{"**Labels:** `[exclusive]`", true},
+		{"**Labels:** `[exclusive:false]`", false},
+		{"**Labels:** `[type:feature]`", false},
+		{"### Task: no labels", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsExclusive(ParseLabels(tt.details)); got != tt.expected {
+			t.Errorf("IsExclusive(%q) = %v, want %v", tt.details, got, tt.expected)
+		}
+	}
+}
+
+func TestParseTasksLabels(t *testing.T) {
+	ts := parseTasks(sample)
+	if len(ts) == 0 || !reflect.DeepEqual(ts[0].Labels, []string{"type:feature"}) {
+		t.Errorf("Expected Task A labels [type:feature], got %+v", ts)
+	}
+}
+
+func TestSetTaskLabel(t *testing.T) {
+	md := "## Current Tasks\n\n### Task: A\n\n**Labels:** `[type:feature] [model:auto]`\n\n### Task: B\n\n**Context:** no labels\n\n## Archive\n"
+
+	got := SetTaskLabel(md, "A", "model", "gpt-5")
+	if labels := ParseLabels(ExtractTaskDetails(got, "A")); !reflect.DeepEqual(labels, []string{"type:feature", "model:gpt-5"}) {
+		t.Errorf("Task A labels = %v", labels)
+	}
+
+	got = SetTaskLabel(got, "B", "model", "sonnet")
+	if labels := ParseLabels(ExtractTaskDetails(got, "B")); !reflect.DeepEqual(labels, []string{"model:sonnet"}) {
+		t.Errorf("Task B labels = %v\n%s", labels, got)
+	}
+	if !strings.Contains(got, "**Context:** no labels\n**Labels:** `[model:sonnet]`\n\n## Archive") {
+		t.Errorf("Expected labels line at end of task B:\n%s", got)
+	}
+
+	if SetTaskLabel(md, "Missing", "model", "x") != md {
+		t.Errorf("Expected unknown task to leave tasks.md unchanged")
+	}
+}
+
+func TestLabelDefaults(t *testing.T) {
+	defaults := LabelDefaults{
+		"Area:Infra": {"priority:P0", "milestone:infra", "exclusive:true"},
+		"type:docs":  {"model:haiku"},
+	}
+
+	tests := []struct {
+		name     string
+		details  string
+		expected []string
+	}{
+		{
+			name:     "implied labels follow the task's own",
+			details:  "**Labels:** `[area:infra] [type:chore]`",
+			expected: []string{"area:infra", "type:chore", "priority:P0", "milestone:infra", "exclusive:true"},
+		},
+		{
+			name:     "the task's own label wins",
+			details:  "**Labels:** `[area: infra] [milestone:q3] [Exclusive:false]`",
+			expected: []string{"area: infra", "milestone:q3", "Exclusive:false", "priority:P0"},
+		},
+		{
+			name:     "no defaults for other labels",
+			details:  "**Labels:** `[area:web]`",
+			expected: []string{"area:web"},
+		},
+		{
+			name:     "no labels line",
+			details:  "### Task: A",
+			expected: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := defaults.ParseLabels(tt.details); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("ParseLabels() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+
+	// Tasks parsed with the defaults get them, tasks.md keeps its own
+	md := "## Current Tasks\n\n### Task: Terraform state\n\n**Labels:** `[area:infra]`\n"
+	if tasks := defaults.ParseTasks(md); len(tasks) != 1 || !IsExclusive(tasks[0].Labels) {
+		t.Errorf("Expected the implied [exclusive:true], got %v", tasks)
+	} else if m, _ := LabelValue(tasks[0].Labels, "milestone"); m != "infra" {
+		t.Errorf("Expected the implied milestone, got %v", tasks[0].Labels)
+	}
+	if tasks := ParseTasks(md); len(tasks) != 1 || IsExclusive(tasks[0].Labels) {
+		t.Errorf("Expected no defaults without them, got %v", tasks)
+	}
+	if !strings.Contains(SetTaskLabel(md, "Terraform state", "model", "opus"), "`[area:infra] [model:opus]`") {
+		t.Errorf("Expected SetTaskLabel to leave the implied labels out of tasks.md")
+	}
+}
+
+func TestLabelDefaultsPriority(t *testing.T) {
+	defaults := LabelDefaults{"area:infra": {"priority:P0"}, "area:docs": {"priority:P2"}}
+	md := "## Current Tasks\n\n" +
+		"### Task: Guide\n**Labels:** `[area:docs]`\n\n" +
+		"### Task: Search\n\n" +
+		"### Task: Terraform state\n**Labels:** `[area:infra]`\n\n" +
+		"### Task: DNS\n**Labels:** `[area:infra]`\n**Priority:** P2\n"
+
+	priorities := make(map[string]string)
+	for _, task := range defaults.ParseTasks(md) {
+		priorities[task.Title] = task.Priority
+	}
+	// The Priority line wins over the implied label
+	want := map[string]string{"Guide": PriorityP2, "Search": "", "Terraform state": PriorityP0, "DNS": PriorityP2}
+	if !reflect.DeepEqual(priorities, want) {
+		t.Errorf("Priorities = %v, want %v", priorities, want)
+	}
+
+	// The implied priority moves the infra task ahead, and the docs task
+	// behind the task without one
+	if next := defaults.NextPendingTask(md, ""); next == nil || next.Title != "Terraform state" {
+		t.Errorf("NextPendingTask() = %v, want the implied P0 task", next)
+	}
+	progress := "## Completed Tasks\n\n- ✅ [2025-01-08 15:30] Terraform state\n"
+	if next := defaults.NextPendingTask(md, progress); next == nil || next.Title != "Search" {
+		t.Errorf("NextPendingTask() = %v, want the P1 task before the implied P2 one", next)
+	}
+	if s := SummarizeWith(md, "", StatusOptions{Defaults: defaults}); s.Next != "Terraform state" {
+		t.Errorf("SummarizeWith().Next = %q, want the implied P0 task", s.Next)
+	}
+	if next := (Fairness{Policy: FairnessInterleave, Defaults: defaults}).NextPendingTask(md, "", nil, 2, nil); next == nil || next.Title != "Terraform state" {
+		t.Errorf("Fairness.NextPendingTask() = %v, want the implied P0 task", next)
+	}
+	// Without the defaults tasks.md order holds
+	if next := GetNextPendingTaskWithProgress(md, ""); next == nil || next.Title != "Guide" {
+		t.Errorf("GetNextPendingTaskWithProgress() = %v, want the first task", next)
+	}
+}
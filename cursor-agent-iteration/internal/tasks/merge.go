@@ -0,0 +1,137 @@
+package tasks
+
+import "strings"
+
+// progressKey identifies an entry of progress.md for merging: a task in a
+// section. A task moved to another section is removed from one key and
+// added to another.
+type progressKey struct {
+	section string
+	title   string
+}
+
+// progressSide is the entries of one version of progress.md, in order
+type progressSide struct {
+	keys  []progressKey
+	lines map[progressKey]string
+}
+
+// parseProgressSide collects the entries of progress.md by section and task,
+// keeping the first when a task is listed twice in one section
+func parseProgressSide(progressMd string) progressSide {
+	side := progressSide{lines: make(map[progressKey]string)}
+	section := ""
+	for _, line := range unwrapEntries(strings.Split(progressMd, "\n")) {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "## ") {
+			section = trimmed
+			continue
+		}
+		title := progressLineTitle(line)
+		if section == "" || title == "" {
+			continue
+		}
+		key := progressKey{section, title}
+		if _, seen := side.lines[key]; !seen {
+			side.keys = append(side.keys, key)
+			side.lines[key] = trimmed
+		}
+	}
+	return side
+}
+
+// MergeProgress reconciles progress.md when it changed on disk (theirs),
+// usually by an agent, after cursor-iter read it (base) and made changes of
+// its own (ours), entry by entry instead of letting the last writer win.
+// Each task's entry in each section comes from the side that changed it, so
+// the completed and in-progress lines both sides added are kept and an
+// entry removed on one side and untouched on the other goes; where both
+// sides changed an entry differently, ours wins. Everything but the entries
+// is taken from theirs, and entries only ours has are placed after the
+// entry they follow in ours.
+func MergeProgress(base, ours, theirs string) string {
+	if ours == theirs || theirs == base {
+		return ours
+	}
+	if ours == base {
+		return theirs
+	}
+	b, o, t := parseProgressSide(base), parseProgressSide(ours), parseProgressSide(theirs)
+
+	// merged maps each key to its entry, or "" where it goes
+	merged := make(map[progressKey]string)
+	for _, side := range []progressSide{o, t, b} {
+		for _, key := range side.keys {
+			if _, done := merged[key]; done {
+				continue
+			}
+			if o.lines[key] == b.lines[key] {
+				merged[key] = t.lines[key]
+			} else {
+				merged[key] = o.lines[key]
+			}
+		}
+	}
+
+	// Theirs with its entries replaced or dropped as merged
+	var lines []string
+	section := ""
+	for _, line := range unwrapEntries(strings.Split(theirs, "\n")) {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "## ") {
+			section = trimmed
+		}
+		if title := progressLineTitle(line); section != "" && title != "" {
+			key := progressKey{section, title}
+			if entry, ok := merged[key]; ok && t.lines[key] == trimmed {
+				if entry == "" {
+					continue
+				}
+				if entry != trimmed {
+					line = entry
+				}
+			}
+		}
+		lines = append(lines, line)
+	}
+	result := strings.Join(lines, "\n")
+
+	// Then the entries only ours has, in its order
+	for i, key := range o.keys {
+		entry := merged[key]
+		if entry == "" || t.lines[key] != "" {
+			continue
+		}
+		after := ""
+		for j := i - 1; j >= 0; j-- {
+			if prev := o.keys[j]; prev.section == key.section && merged[prev] != "" {
+				after = merged[prev]
+				break
+			}
+		}
+		result = insertProgressLineAfter(result, key.section, after, entry)
+	}
+	return result
+}
+
+// insertProgressLineAfter adds an entry to a progress.md section right
+// after the entry after, or at the top of the section when after is empty
+// or not in it
+func insertProgressLineAfter(progressMd string, section string, after string, entry string) string {
+	if after != "" {
+		lines := unwrapEntries(strings.Split(progressMd, "\n"))
+		current := ""
+		for i, line := range lines {
+			trimmed := strings.TrimSpace(line)
+			if strings.HasPrefix(trimmed, "## ") {
+				current = trimmed
+				continue
+			}
+			if current == section && trimmed == after {
+				result := append(append(append([]string{}, lines[:i+1]...), entry), lines[i+1:]...)
+				return strings.Join(result, "\n")
+			}
+		}
+	}
+	return insertProgressLine(progressMd, section, entry)
+}
@@ -0,0 +1,73 @@
+package tasks
+
+import (
+	"strings"
+	"testing"
+)
+
+const mergeBase = `# Progress Log
+
+## In Progress
+
+- 🔄 [2025-01-08 09:00] Login form
+- 🔄 [2025-01-08 09:05] Search index
+
+## Completed Tasks
+
+- ✅ [2025-01-07 17:00] Setup - scaffolding
+`
+
+func TestMergeProgress(t *testing.T) {
+	// cursor-iter marks a task in progress and adds the cost of a completion
+	ours := strings.Replace(mergeBase, "- 🔄 [2025-01-08 09:05] Search index\n", "- 🔄 [2025-01-08 09:05] Search index\n- 🔄 [2025-01-08 09:10] Dark mode\n", 1)
+	ours = strings.Replace(ours, "Setup - scaffolding", "Setup - scaffolding | cost: $0.12", 1)
+	// while an agent completes its task and notes what it left for later
+	theirs := strings.Replace(mergeBase, "- 🔄 [2025-01-08 09:00] Login form\n", "", 1)
+	theirs = strings.Replace(theirs, "- ✅ [2025-01-07 17:00] Setup - scaffolding\n", "- ✅ [2025-01-07 17:00] Setup - scaffolding\n- ✅ [2025-01-08 10:00] Login form - done\n\nLeft the password reset for a follow-up.\n", 1)
+
+	want := `# Progress Log
+
+## In Progress
+
+- 🔄 [2025-01-08 09:05] Search index
+- 🔄 [2025-01-08 09:10] Dark mode
+
+## Completed Tasks
+
+- ✅ [2025-01-07 17:00] Setup - scaffolding | cost: $0.12
+- ✅ [2025-01-08 10:00] Login form - done
+
+Left the password reset for a follow-up.
+`
+	if got := MergeProgress(mergeBase, ours, theirs); got != want {
+		t.Errorf("MergeProgress() =\n%s\nwant\n%s", got, want)
+	}
+	if got := MergeProgress(mergeBase, ours, mergeBase); got != ours {
+		t.Errorf("Expected ours when theirs is unchanged, got\n%s", got)
+	}
+	if got := MergeProgress(mergeBase, mergeBase, theirs); got != theirs {
+		t.Errorf("Expected theirs when ours is unchanged, got\n%s", got)
+	}
+}
+
+func TestMergeProgressConflicts(t *testing.T) {
+	// cursor-iter releases a task the agent completed meanwhile, and
+	// reopens one whose notes the agent rewrote
+	ours := strings.Replace(mergeBase, "- 🔄 [2025-01-08 09:05] Search index\n", "", 1)
+	ours = ReopenTask(ours, "Setup")
+	theirs := strings.Replace(mergeBase, "- 🔄 [2025-01-08 09:05] Search index\n", "", 1)
+	theirs = strings.Replace(theirs, "Setup - scaffolding", "Setup - scaffolding and CI", 1)
+	theirs = strings.Replace(theirs, "## Completed Tasks\n\n", "## Completed Tasks\n\n- ✅ [2025-01-08 11:00] Search index\n", 1)
+
+	got := MergeProgress(mergeBase, ours, theirs)
+	entries := ParseProgress(got)
+	if entries["Search index"].Status != "completed" {
+		t.Errorf("Expected the agent's completion kept:\n%s", got)
+	}
+	if entries["Setup"].Status != "in-progress" || strings.Contains(got, "scaffolding and CI") {
+		t.Errorf("Expected cursor-iter's reopen to win over the agent's edit:\n%s", got)
+	}
+	if entries["Login form"].Status != "in-progress" {
+		t.Errorf("Expected untouched entries kept:\n%s", got)
+	}
+}
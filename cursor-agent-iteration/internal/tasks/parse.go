@@ -14,16 +14,62 @@ var (
 	reACHeader   = regexp.MustCompile(`^\*\*Acceptance Criteria:\*\*\s*$`)
 	reACItem     = regexp.MustCompile(`^[*-] \[( |x|X)\]`)
 	reACChecked  = regexp.MustCompile(`\[(x|X)\]`)
+	reContext    = regexp.MustCompile(`^\*\*Context:\*\*\s*(.*)$`)
 )
 
 type Task struct {
 	Title     string
+	Context   string // the "**Context:**" line, without its label
 	ACTotal   int
 	ACChecked int
 	Status    string // "pending", "in-progress", "completed", "blocked"
+	Criteria  []Criterion
+	Files     []string // paths from the "**Files to Modify:**" line
+	// FileScope is the same line with its directories and globs, see
+	// ParseFileScope; it is what the task claims while it runs
+	FileScope []string
+	Labels    []string // e.g. "type:feature" from the "**Labels:**" line
+	// Dependencies are the items of the "**Dependencies:**" line; those that
+	// name another task must complete before this one starts
+	Dependencies []string
+	// Priority is P0, P1 or P2 from the "**Priority:**" line, or else from
+	// a "priority:" label; "" without a valid one
+	Priority string
+}
+
+// ParseTasks returns the tasks in the "## Current Tasks" section of tasks.md.
+// Status comes from tasks.md alone; combine with ParseProgress for the
+// status recorded in progress.md.
+func ParseTasks(md string) []Task {
+	return parseTasks(md)
 }
 
 func parseTasks(md string) []Task {
+	return parseTasksN(md, -1, nil)
+}
+
+// parseTasksN parses the first limit tasks, or every task when limit is
+// negative, and stops reading there. The label defaults are merged into
+// each task.
+func parseTasksN(md string, limit int, defaults LabelDefaults) []Task {
+	if limit == 0 {
+		return nil
+	}
+	tasks := scanTasks(md, limit)
+	for i := range tasks {
+		t := &tasks[i]
+		t.Labels = defaults.Apply(t.Labels)
+		if t.Priority == "" {
+			if v, ok := LabelValue(t.Labels, "priority"); ok {
+				t.Priority, _ = ParsePriority(v)
+			}
+		}
+	}
+	return tasks
+}
+
+// scanTasks reads the tasks of md as they are written, see parseTasksN
+func scanTasks(md string, limit int) []Task {
 	lines := strings.Split(md, "\n")
 	var tasks []Task
 	var cur *Task
@@ -50,6 +96,9 @@ func parseTasks(md string) []Task {
 		if m := reTaskHeader.FindStringSubmatch(line); m != nil {
 			if cur != nil {
 				tasks = append(tasks, *cur)
+				if len(tasks) == limit {
+					return tasks
+				}
 			}
 			title := strings.TrimSpace(m[1])
 
@@ -62,6 +111,10 @@ func parseTasks(md string) []Task {
 		if cur == nil {
 			continue
 		}
+		if m := reContext.FindStringSubmatch(line); m != nil {
+			cur.Context = strings.TrimSpace(m[1])
+			continue
+		}
 		if reACHeader.MatchString(line) {
 			inAC = true
 			continue
@@ -71,6 +124,24 @@ func parseTasks(md string) []Task {
 			if reACChecked.MatchString(line) {
 				cur.ACChecked++
 			}
+			cur.Criteria = append(cur.Criteria, parseCriterion(line))
+			continue
+		}
+		if reFilesLine.MatchString(line) {
+			cur.Files = ParseFiles(line)
+			cur.FileScope = ParseFileScope(line)
+			continue
+		}
+		if reLabelsLine.MatchString(line) {
+			cur.Labels = ParseLabels(line)
+			continue
+		}
+		if reDependenciesLine.MatchString(line) {
+			cur.Dependencies = ParseDependencies(line)
+			continue
+		}
+		if m := rePriorityLine.FindStringSubmatch(line); m != nil {
+			cur.Priority, _ = ParsePriority(m[1])
 			continue
 		}
 		if strings.HasPrefix(line, "### ") && !reTaskHeader.MatchString(line) {
@@ -78,6 +149,9 @@ func parseTasks(md string) []Task {
 			if cur != nil {
 				tasks = append(tasks, *cur)
 				cur = nil
+				if len(tasks) == limit {
+					return tasks
+				}
 			}
 			inAC = false
 			continue
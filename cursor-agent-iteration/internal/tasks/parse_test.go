@@ -130,6 +130,9 @@ func TestParseTasks(t *testing.T) {
 	if taskA.Status != "pending" {
 		t.Errorf("Expected task A status 'pending', got '%s'", taskA.Status)
 	}
+	if taskA.Context != "Test context A" {
+		t.Errorf("Expected task A context 'Test context A', got '%s'", taskA.Context)
+	}
 
 	// Test task B (in progress)
 	taskB := tasks[1]
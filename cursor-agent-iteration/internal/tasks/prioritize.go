@@ -0,0 +1,88 @@
+package tasks
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MoveToFront moves the named tasks to the top of the "## Current Tasks"
+// section, in the order given, so they are dispatched before the rest of
+// their priority; SetPriority puts them ahead of every other task. Titles
+// that name no task are ignored.
+func MoveToFront(tasksMd string, titles []string) string {
+	lines := strings.Split(tasksMd, "\n")
+	blocks, _ := currentTaskBlocks(lines)
+	if len(blocks) == 0 {
+		return tasksMd
+	}
+	index := make(map[string]int, len(blocks))
+	for i, b := range blocks {
+		index[b.title] = i
+	}
+	moved := make(map[int]bool)
+	var order []int
+	for _, title := range titles {
+		if i, ok := index[cleanTaskTitle(title)]; ok && !moved[i] {
+			moved[i] = true
+			order = append(order, i)
+		}
+	}
+	for i := range blocks {
+		if !moved[i] {
+			order = append(order, i)
+		}
+	}
+
+	texts := make([]string, len(order))
+	for n, i := range order {
+		texts[n] = strings.Join(lines[blocks[i].start:blocks[i].end], "\n")
+	}
+	head := strings.Join(lines[:blocks[0].start], "\n")
+	tail := strings.Join(lines[blocks[len(blocks)-1].end:], "\n")
+	return head + "\n" + strings.Join(texts, "\n\n") + "\n" + tail
+}
+
+// PrependTasks inserts task blocks at the top of the "## Current Tasks"
+// section, creating the section if needed
+func PrependTasks(tasksMd string, blocks []string) string {
+	if len(blocks) == 0 {
+		return tasksMd
+	}
+	lines := strings.Split(tasksMd, "\n")
+	existing, sectionEnd := currentTaskBlocks(lines)
+	if sectionEnd < 0 || len(existing) == 0 {
+		return AppendTasks(tasksMd, blocks)
+	}
+	start := existing[0].start
+	out := append([]string{}, lines[:start]...)
+	out = append(out, strings.Join(blocks, "\n\n"), "")
+	out = append(out, lines[start:]...)
+	return strings.Join(out, "\n")
+}
+
+// HotfixTask renders a task block for fixing a production incident in the
+// given paths, at priority P0 so it is dispatched ahead of every other task
+func HotfixTask(title string, paths []string, incident string) string {
+	quoted := make([]string, len(paths))
+	for i, p := range paths {
+		quoted[i] = "`" + p + "`"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "### Task: %s\n\n", title)
+	context := "Hotfix for a production incident in " + strings.Join(quoted, ", ") + "."
+	if incident = strings.TrimSpace(incident); incident != "" {
+		context += " " + strings.ReplaceAll(incident, "\n", " ")
+	}
+	fmt.Fprintf(&b, "**Context:** %s\n", context)
+	b.WriteString("**Acceptance Criteria:**\n\n")
+	b.WriteString("* [ ] The incident is reproduced by a failing test\n")
+	b.WriteString("* [ ] The root cause is fixed with the smallest safe change\n")
+	b.WriteString("* [ ] The new test and all existing tests pass\n")
+	b.WriteString("* [ ] Changes committed to git with descriptive message\n\n")
+	fmt.Fprintf(&b, "**Files to Modify:** %s\n", strings.Join(quoted, ", "))
+	b.WriteString("**Tests:** regression\n")
+	b.WriteString("**Labels:** [type:hotfix]\n")
+	fmt.Fprintf(&b, "**Priority:** %s\n", PriorityP0)
+	b.WriteString("**Dependencies:** None")
+	return b.String()
+}
@@ -0,0 +1,67 @@
+package tasks
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const prioritizeTasksMd = `# Tasks
+
+## Current Tasks
+
+### Task: Signup form
+**Acceptance Criteria:**
+* [ ] one
+
+
+### Task: Refund endpoint
+**Acceptance Criteria:**
+* [ ] two
+
+### Task: Payment retries
+**Acceptance Criteria:**
+* [ ] three
+
+## Notes
+
+Keep this.
+`
+
+func taskOrder(md string) []string {
+	var titles []string
+	for _, t := range ParseTasks(md) {
+		titles = append(titles, t.Title)
+	}
+	return titles
+}
+
+func TestMoveToFront(t *testing.T) {
+	got := MoveToFront(prioritizeTasksMd, []string{"Payment retries", "Refund endpoint", "Missing"})
+	if order, want := taskOrder(got), []string{"Payment retries", "Refund endpoint", "Signup form"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+	if !strings.Contains(got, "* [ ] one\n\n## Notes\n\nKeep this.\n") || !strings.HasPrefix(got, "# Tasks\n\n## Current Tasks\n\n### Task: Payment retries\n") {
+		t.Errorf("MoveToFront() =\n%s", got)
+	}
+	if again := MoveToFront(got, []string{"Payment retries"}); again != got {
+		t.Errorf("Expected no change for the first task:\n%s", again)
+	}
+}
+
+func TestPrependHotfixTask(t *testing.T) {
+	block := HotfixTask("Hotfix: double refunds", []string{"src/payments/..."}, "INC-42: refunds ran twice")
+	got := PrependTasks(prioritizeTasksMd, []string{block})
+	tasks := ParseTasks(got)
+	if len(tasks) != 4 || tasks[0].Title != "Hotfix: double refunds" {
+		t.Fatalf("tasks = %v", taskOrder(got))
+	}
+	hotfix := tasks[0]
+	if hotfix.ACTotal != 4 || !reflect.DeepEqual(ParseFileScope(block), []string{"src/payments/"}) || !reflect.DeepEqual(hotfix.Labels, []string{"type:hotfix"}) {
+		t.Errorf("hotfix task = %+v", hotfix)
+	}
+	if !strings.Contains(got, "**Context:** Hotfix for a production incident in `src/payments/...`. INC-42: refunds ran twice\n") ||
+		!strings.Contains(block, "tests pass\n* [ ] Changes committed to git with descriptive message\n\n**Files to Modify:** `src/payments/...`\n**Tests:** regression\n**Labels:** [type:hotfix]\n") {
+		t.Errorf("PrependTasks() =\n%s", got)
+	}
+}
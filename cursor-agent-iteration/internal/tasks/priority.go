@@ -0,0 +1,81 @@
+package tasks
+
+import (
+	"regexp"
+	"strings"
+)
+
+var rePriorityLine = regexp.MustCompile(`^\s*\*\*Priority:\*\*\s*(.*)$`)
+
+// Task priorities from the "**Priority:**" line, most urgent first. A task
+// without the line is DefaultPriority, so marking one task P0 is enough to
+// have it dispatched ahead of the rest, and P2 leaves a task for later.
+const (
+	PriorityP0      = "P0"
+	PriorityP1      = "P1"
+	PriorityP2      = "P2"
+	DefaultPriority = PriorityP1
+)
+
+// priorities lists the priorities, most urgent first
+var priorities = []string{PriorityP0, PriorityP1, PriorityP2}
+
+// ParsePriority reads the value of a "**Priority:**" line, ignoring case and
+// backticks; ok is false for anything but P0, P1 or P2
+func ParsePriority(value string) (priority string, ok bool) {
+	value = strings.ToUpper(strings.Trim(strings.TrimSpace(value), "`"))
+	for _, p := range priorities {
+		if value == p {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// priorityRank orders priorities, 0 being the most urgent. No priority, or
+// one that isn't valid, ranks as DefaultPriority.
+func priorityRank(priority string) int {
+	if priority == "" {
+		priority = DefaultPriority
+	}
+	for i, p := range priorities {
+		if p == priority {
+			return i
+		}
+	}
+	return priorityRank(DefaultPriority)
+}
+
+// morePressing reports whether a should be dispatched before b: it has a
+// higher priority. Between equal priorities the caller keeps the task that
+// comes first in tasks.md.
+func morePressing(a, b *Task) bool {
+	return priorityRank(a.Priority) < priorityRank(b.Priority)
+}
+
+// SetPriority sets the "**Priority:**" line of the named task, adding one
+// at the end of its block when it has none
+func SetPriority(tasksMd string, taskTitle string, priority string) string {
+	lines := strings.Split(tasksMd, "\n")
+	blocks, _ := currentTaskBlocks(lines)
+	for _, b := range blocks {
+		if b.title != cleanTaskTitle(taskTitle) {
+			continue
+		}
+		for i := b.start + 1; i < b.end; i++ {
+			if m := rePriorityLine.FindStringSubmatch(lines[i]); m != nil {
+				lines[i] = lines[i][:len(lines[i])-len(m[1])] + priority
+				return strings.Join(lines, "\n")
+			}
+		}
+		insert := b.end
+		for insert > b.start+1 && strings.TrimSpace(lines[insert-1]) == "" {
+			insert--
+		}
+		out := append([]string{}, lines[:insert]...)
+		out = append(out, "**Priority:** "+priority)
+		out = append(out, lines[insert:]...)
+		return strings.Join(out, "\n")
+	}
+	return tasksMd
+}
@@ -0,0 +1,126 @@
+package tasks
+
+import (
+	"strings"
+	"testing"
+)
+
+const priorityTasksMd = `# Tasks
+
+## Current Tasks
+
+### Task: Docs pass
+**Context:** Tidy the docs.
+**Acceptance Criteria:**
+* [ ] Docs tidied
+**Priority:** P2
+
+### Task: Search index
+**Context:** Faster search.
+**Acceptance Criteria:**
+* [ ] Index built
+**Labels:** [milestone:search]
+
+### Task: Fix login crash
+**Context:** Users can't log in.
+**Acceptance Criteria:**
+* [ ] No crash
+**Priority:** ` + "`p0`" + `
+**Dependencies:** ` + "`Add user model`" + `
+
+### Task: Add user model
+**Context:** Store users.
+**Acceptance Criteria:**
+* [ ] Model exists
+**Priority:** P1
+
+### Task: Fix signup crash
+**Context:** Signups fail.
+**Acceptance Criteria:**
+* [ ] No crash
+**Priority:** P0
+`
+
+func TestParsePriority(t *testing.T) {
+	for in, want := range map[string]string{"P0": "P0", " p1 ": "P1", "`P2`": "P2", "P3": "", "high": "", "": ""} {
+		got, ok := ParsePriority(in)
+		if got != want || ok != (want != "") {
+			t.Errorf("ParsePriority(%q) = %q, %v, want %q", in, got, ok, want)
+		}
+	}
+	if all := ParseTasks(priorityTasksMd); all[0].Priority != PriorityP2 || all[1].Priority != "" || all[2].Priority != PriorityP0 {
+		t.Errorf("Unexpected priorities %q, %q, %q", all[0].Priority, all[1].Priority, all[2].Priority)
+	}
+}
+
+func TestNextPendingTaskByPriority(t *testing.T) {
+	// Fix login crash waits on Add user model, so the other P0 goes first
+	next := GetNextPendingTaskWithProgress(priorityTasksMd, "")
+	if next == nil || next.Title != "Fix signup crash" {
+		t.Fatalf("Expected the P0 task that may start, got %+v", next)
+	}
+	progress := "## In Progress\n\n- 🔄 [2025-01-08 10:00] Fix signup crash\n"
+	if next := GetNextPendingTaskWithProgress(priorityTasksMd, progress); next == nil || next.Title != "Search index" {
+		t.Errorf("Expected the first task of the default priority, got %+v", next)
+	}
+	progress = "## In Progress\n\n- 🔄 [2025-01-08 10:00] Fix signup crash\n\n## Completed Tasks\n\n- ✅ [2025-01-08 10:00] Add user model\n"
+	if next := GetNextPendingTaskWithProgress(priorityTasksMd, progress); next == nil || next.Title != "Fix login crash" {
+		t.Errorf("Expected the P0 task once its dependency completed, got %+v", next)
+	}
+
+	// The interleave policy picks the highest priority within a milestone
+	interleave := Fairness{Policy: FairnessInterleave}
+	if next := interleave.NextPendingTask(priorityTasksMd, "", nil, 2, nil); next == nil || next.Title != "Fix signup crash" {
+		t.Errorf("Expected the P0 task of the milestone tied for slots, got %+v", next)
+	}
+	if next := interleave.NextPendingTask(priorityTasksMd, "", []string{"Fix signup crash"}, 2, nil); next == nil || next.Title != "Search index" {
+		t.Errorf("Expected the milestone with fewer running tasks, got %+v", next)
+	}
+}
+
+func TestValidatePriority(t *testing.T) {
+	if result := ValidateTasksStructure(priorityTasksMd); !result.Valid {
+		t.Errorf("Expected valid priorities, got %v", result.Errors)
+	}
+	result := ValidateTasksStructure(strings.Replace(priorityTasksMd, "**Priority:** P2", "**Priority:** high", 1))
+	if result.Valid || len(result.Errors) != 1 || !strings.Contains(result.Errors[0], `Invalid priority "high"`) {
+		t.Errorf("Expected the invalid priority reported, got %v", result.Errors)
+	}
+}
+
+func TestSummaryNextByPriority(t *testing.T) {
+	for name, s := range map[string]StatusSummary{
+		"Summarize":   Summarize(priorityTasksMd, ""),
+		"counts only": SummarizeWith(priorityTasksMd, "", StatusOptions{CountsOnly: true}),
+		"page":        SummarizeWith(priorityTasksMd, "", StatusOptions{Page: 1, PageSize: 2}),
+	} {
+		if s.Next != "Fix signup crash" {
+			t.Errorf("%s: Next = %q, want the task iterate picks", name, s.Next)
+		}
+	}
+	progress := "## In Progress\n\n- 🔄 [2025-01-08 10:00] Search index\n"
+	if s := SummarizeWith(priorityTasksMd, progress, StatusOptions{CountsOnly: true}); s.Current != "Search index" || s.Next != "" {
+		t.Errorf("Expected the current task and no next task, got %q, %q", s.Current, s.Next)
+	}
+}
+
+func TestSetPriority(t *testing.T) {
+	md := SetPriority(priorityTasksMd, "Docs pass", PriorityP0)
+	md = SetPriority(md, "Search index", PriorityP0)
+	if next := GetNextPendingTaskWithProgress(md, ""); next == nil || next.Title != "Docs pass" {
+		t.Errorf("Expected the raised task first, got %+v", next)
+	}
+	if !strings.Contains(md, "* [ ] Docs tidied\n**Priority:** P0\n\n### Task: Search index") ||
+		!strings.Contains(md, "**Labels:** [milestone:search]\n**Priority:** P0\n\n### Task: Fix login crash") {
+		t.Errorf("SetPriority() =\n%s", md)
+	}
+	if got := SetPriority(priorityTasksMd, "Missing", PriorityP0); got != priorityTasksMd {
+		t.Errorf("Expected no change for a missing task:\n%s", got)
+	}
+
+	// A hotfix goes ahead of the tasks that were already P0
+	md = PrependTasks(priorityTasksMd, []string{HotfixTask("Hotfix: login", []string{"src/auth/"}, "")})
+	if next := GetNextPendingTaskWithProgress(md, ""); next == nil || next.Title != "Hotfix: login" || next.Priority != PriorityP0 {
+		t.Errorf("Expected the hotfix first, got %+v", next)
+	}
+}
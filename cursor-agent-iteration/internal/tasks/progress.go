@@ -10,35 +10,61 @@ import (
 // ProgressEntry represents a task status entry in progress.md
 type ProgressEntry struct {
 	TaskTitle   string
-	Status      string // "in-progress" or "completed"
+	Status      string // "in-progress", "completed", "blocked" or "failed"
 	StartedAt   time.Time
 	CompletedAt time.Time
 	Notes       string
+	// Fields are custom fields such as tickets or reviewers, in the order
+	// they appear in the entry
+	Fields []Field
 }
 
 // ParseProgress reads progress.md and returns task status entries
 func ParseProgress(progressMd string) map[string]ProgressEntry {
+	return ParseProgressFor(progressMd, nil)
+}
+
+// ParseProgressFor reads progress.md like ParseProgress, keying the entries
+// of the given task titles by those titles even when an entry spells one
+// differently: quoted, wrapped over several lines, with other spacing, or
+// unquoted with a " - " inside
+func ParseProgressFor(progressMd string, titles []string) map[string]ProgressEntry {
 	entries := make(map[string]ProgressEntry)
-	lines := strings.Split(progressMd, "\n")
+	lines := unwrapEntries(strings.Split(progressMd, "\n"))
 
 	inCompletedSection := false
 	inProgressSection := false
+	inBlockedSection := false
+	inFailedSection := false
 
 	for _, line := range lines {
 		trimmed := strings.TrimSpace(line)
 
 		// Check for section headers
-		if trimmed == "## In Progress" {
-			inProgressSection = true
-			inCompletedSection = false
-			continue
-		} else if trimmed == "## Completed Tasks" {
-			inCompletedSection = true
-			inProgressSection = false
+		if strings.HasPrefix(trimmed, "## ") {
+			inProgressSection = trimmed == "## In Progress"
+			inCompletedSection = trimmed == "## Completed Tasks"
+			inBlockedSection = trimmed == "## Blocked"
+			inFailedSection = trimmed == "## Failed"
 			continue
-		} else if strings.HasPrefix(trimmed, "## ") {
-			inProgressSection = false
-			inCompletedSection = false
+		}
+
+		// Parse blocked tasks: "- ⛔ [2025-01-08 19:00] Task Title - reason"
+		// and failed ones: "- ❌ [2025-01-08 19:00] Task Title - reason"
+		if inBlockedSection || inFailedSection {
+			mark, status := blockedMark, "blocked"
+			if inFailedSection {
+				mark, status = failedMark, "failed"
+			}
+			if title, notes, fields, at, ok := parseReasonLine(trimmed, mark, titles...); ok {
+				entries[title] = ProgressEntry{
+					TaskTitle: title,
+					Status:    status,
+					StartedAt: at,
+					Notes:     notes,
+					Fields:    fields,
+				}
+			}
 			continue
 		}
 
@@ -46,13 +72,7 @@ func ParseProgress(progressMd string) map[string]ProgressEntry {
 		if inProgressSection && (strings.HasPrefix(trimmed, "- 🔄") || strings.HasPrefix(trimmed, "* 🔄")) {
 			parts := strings.SplitN(line, "]", 2)
 			if len(parts) == 2 {
-				remainder := strings.TrimSpace(parts[1])
-				titleParts := strings.SplitN(remainder, " - ", 2)
-				taskTitle := strings.TrimSpace(titleParts[0])
-				notes := ""
-				if len(titleParts) > 1 {
-					notes = strings.TrimSpace(titleParts[1])
-				}
+				taskTitle, notes, fields := splitEntry(parts[1], titles...)
 
 				timestamp := strings.TrimPrefix(strings.TrimSpace(parts[0]), "- 🔄 [")
 				timestamp = strings.TrimPrefix(timestamp, "* 🔄 [")
@@ -63,6 +83,7 @@ func ParseProgress(progressMd string) map[string]ProgressEntry {
 					Status:    "in-progress",
 					StartedAt: startedAt,
 					Notes:     notes,
+					Fields:    fields,
 				}
 			}
 		}
@@ -71,13 +92,7 @@ func ParseProgress(progressMd string) map[string]ProgressEntry {
 		if inCompletedSection && (strings.HasPrefix(trimmed, "- ✅") || strings.HasPrefix(trimmed, "* ✅")) {
 			parts := strings.SplitN(line, "]", 2)
 			if len(parts) == 2 {
-				remainder := strings.TrimSpace(parts[1])
-				titleParts := strings.SplitN(remainder, " - ", 2)
-				taskTitle := strings.TrimSpace(titleParts[0])
-				notes := ""
-				if len(titleParts) > 1 {
-					notes = strings.TrimSpace(titleParts[1])
-				}
+				taskTitle, notes, fields := splitEntry(parts[1], titles...)
 
 				timestamp := strings.TrimPrefix(strings.TrimSpace(parts[0]), "- ✅ [")
 				timestamp = strings.TrimPrefix(timestamp, "* ✅ [")
@@ -88,6 +103,7 @@ func ParseProgress(progressMd string) map[string]ProgressEntry {
 					Status:      "completed",
 					CompletedAt: completedAt,
 					Notes:       notes,
+					Fields:      fields,
 				}
 			}
 		}
@@ -99,7 +115,7 @@ func ParseProgress(progressMd string) map[string]ProgressEntry {
 // LogTaskCompletion adds a task completion entry to progress.md
 func LogTaskCompletion(progressMd string, taskTitle string, notes string) string {
 	timestamp := time.Now().Format("2006-01-02 15:04")
-	entry := fmt.Sprintf("- ✅ [%s] %s", timestamp, taskTitle)
+	entry := fmt.Sprintf("- ✅ [%s] %s", timestamp, entryTitle(taskTitle))
 	if notes != "" {
 		entry += fmt.Sprintf(" - %s", notes)
 	}
@@ -153,10 +169,21 @@ func LogTaskCompletion(progressMd string, taskTitle string, notes string) string
 	return strings.Join(result, "\n")
 }
 
+// CompleteManually records that someone finished a task by hand: its
+// in-progress, blocked and failed entries are dropped and a completion
+// entry with the notes is added, attributed to by in a "by" field
+func CompleteManually(progressMd string, taskTitle string, notes string, by string) string {
+	progressMd = LogTaskCompletion(removeProgressEntry(progressMd, taskTitle), taskTitle, notes)
+	if by == "" {
+		return progressMd
+	}
+	return SetCompletionFields(progressMd, taskTitle, []Field{{Name: "by", Value: by}})
+}
+
 // MarkTaskInProgress adds a task to the "In Progress" section of progress.md
 func MarkTaskInProgress(progressMd string, taskTitle string) string {
 	timestamp := time.Now().Format("2006-01-02 15:04")
-	entry := fmt.Sprintf("- 🔄 [%s] %s\n", timestamp, taskTitle)
+	entry := fmt.Sprintf("- 🔄 [%s] %s\n", timestamp, entryTitle(taskTitle))
 
 	// If progress.md is empty or doesn't have headers, create structure
 	if strings.TrimSpace(progressMd) == "" {
@@ -216,13 +243,13 @@ func MarkTaskInProgress(progressMd string, taskTitle string) string {
 // MoveTaskToCompleted moves a task from "In Progress" to "Completed" in progress.md
 func MoveTaskToCompleted(progressMd string, taskTitle string, notes string) string {
 	timestamp := time.Now().Format("2006-01-02 15:04")
-	completedEntry := fmt.Sprintf("- ✅ [%s] %s", timestamp, taskTitle)
+	completedEntry := fmt.Sprintf("- ✅ [%s] %s", timestamp, entryTitle(taskTitle))
 	if notes != "" {
 		completedEntry += fmt.Sprintf(" - %s", notes)
 	}
 	completedEntry += "\n"
 
-	lines := strings.Split(progressMd, "\n")
+	lines := unwrapEntries(strings.Split(progressMd, "\n"))
 	var result []string
 	inProgressSection := false
 	completedSection := false
@@ -248,7 +275,7 @@ func MoveTaskToCompleted(progressMd string, taskTitle string, notes string) stri
 		}
 
 		// Remove from In Progress section
-		if inProgressSection && strings.Contains(line, taskTitle) && strings.Contains(line, "🔄") {
+		if inProgressSection && strings.Contains(line, "🔄") && progressLineTitle(line, taskTitle) == taskTitle {
 			continue // Skip this line
 		}
 
@@ -273,14 +300,14 @@ func MoveTaskToCompleted(progressMd string, taskTitle string, notes string) stri
 
 // IsTaskCompleted checks if a task is marked as completed in progress.md
 func IsTaskCompleted(progressMd string, taskTitle string) bool {
-	entries := ParseProgress(progressMd)
+	entries := ParseProgressFor(progressMd, []string{taskTitle})
 	entry, exists := entries[taskTitle]
 	return exists && entry.Status == "completed"
 }
 
 // IsTaskInProgress checks if a task is marked as in-progress in progress.md
 func IsTaskInProgress(progressMd string, taskTitle string) bool {
-	entries := ParseProgress(progressMd)
+	entries := ParseProgressFor(progressMd, []string{taskTitle})
 	entry, exists := entries[taskTitle]
 	return exists && entry.Status == "in-progress"
 }
@@ -309,28 +336,58 @@ func GetInProgressTasks(progressMd string) []string {
 	return titles
 }
 
-// GetNextPendingTaskWithProgress returns the first task that's not in progress.md
+// GetNextPendingTaskWithProgress returns the highest-priority task that's
+// not in progress.md, the first in tasks.md among equals
 func GetNextPendingTaskWithProgress(tasksMd string, progressMd string) *Task {
-	tasks := parseTasks(tasksMd)
-	progressEntries := ParseProgress(progressMd)
+	return nextPendingTask(tasksMd, progressMd, nil, nil)
+}
 
-	for _, t := range tasks {
+// NextPendingTask is GetNextPendingTaskWithProgress with the label defaults
+// merged into every task
+func (d LabelDefaults) NextPendingTask(tasksMd string, progressMd string) *Task {
+	return nextPendingTask(tasksMd, progressMd, nil, d)
+}
+
+// nextPendingTask returns the highest-priority pending task whose files
+// don't overlap the claims of the running tasks, the first in tasks.md
+// among equals
+func nextPendingTask(tasksMd string, progressMd string, claims Claims, defaults LabelDefaults) *Task {
+	tasks := parseTasksN(tasksMd, -1, defaults)
+	return pickNext(tasks, ParseProgressFor(progressMd, taskTitles(tasks)), claims)
+}
+
+// pickNext is nextPendingTask over parsed tasks and their progress entries
+func pickNext(tasks []Task, progressEntries map[string]ProgressEntry, claims Claims) *Task {
+	graph := NewDependencyGraph(tasks)
+
+	var next *Task
+	for i := range tasks {
+		t := &tasks[i]
 		// Skip tasks that are in progress.md (either in-progress or completed)
 		if _, exists := progressEntries[t.Title]; exists {
 			continue
 		}
+		// Skip tasks whose prerequisites haven't completed
+		if len(graph.Waiting(t.Title, progressEntries)) > 0 {
+			continue
+		}
+		// Skip tasks that would change the files of a running task
+		if claims.Conflict(t.Title, t.FileScope) != "" {
+			continue
+		}
 
-		// Return the first task not in progress.md (pending)
-		return &t
+		if next == nil || morePressing(t, next) {
+			next = t
+		}
 	}
 
-	return nil
+	return next
 }
 
 // GetCurrentTaskWithProgress returns the first in-progress task from progress.md
 func GetCurrentTaskWithProgress(tasksMd string, progressMd string) *Task {
 	tasks := parseTasks(tasksMd)
-	progressEntries := ParseProgress(progressMd)
+	progressEntries := ParseProgressFor(progressMd, taskTitles(tasks))
 
 	for _, t := range tasks {
 		// Check if task is in-progress in progress.md
@@ -349,7 +406,7 @@ func CompleteAllChecked(tasksMd string, progressMd string) bool {
 		return false
 	}
 
-	progressEntries := ParseProgress(progressMd)
+	progressEntries := ParseProgressFor(progressMd, taskTitles(tasks))
 
 	for _, t := range tasks {
 		// Check if task is marked as completed in progress.md
@@ -365,14 +422,16 @@ func CompleteAllChecked(tasksMd string, progressMd string) bool {
 // StatusReportWithProgress generates a status report using both tasks.md and progress.md
 func StatusReportWithProgress(tasksMd string, progressMd string) string {
 	tasks := parseTasks(tasksMd)
-	progressEntries := ParseProgress(progressMd)
+	progressEntries := ParseProgressFor(progressMd, taskTitles(tasks))
 
 	total := len(tasks)
 	done := 0
 	prog := 0
 	pend := 0
+	blocked := 0
+	failed := 0
 
-	var doneL, progL, pendL []string
+	var doneL, progL, pendL, blockedL, failedL []string
 
 	for _, t := range tasks {
 		// Check task status in progress.md
@@ -381,6 +440,16 @@ func StatusReportWithProgress(tasksMd string, progressMd string) string {
 		if exists && entry.Status == "completed" {
 			done++
 			doneL = append(doneL, fmt.Sprintf("  - %s", t.Title))
+		} else if exists && entry.Status == "blocked" {
+			blocked++
+			blockedL = append(blockedL, fmt.Sprintf("  - ⛔ %s", t.Title))
+		} else if exists && entry.Status == "failed" {
+			failed++
+			line := fmt.Sprintf("  - ❌ %s", t.Title)
+			if entry.Notes != "" {
+				line += " - " + entry.Notes
+			}
+			failedL = append(failedL, line)
 		} else if exists && entry.Status == "in-progress" {
 			prog++
 			progL = append(progL, fmt.Sprintf("  - %s (%d/%d criteria completed)", t.Title, t.ACChecked, t.ACTotal))
@@ -412,7 +481,14 @@ func StatusReportWithProgress(tasksMd string, progressMd string) string {
 	b.WriteString(fmt.Sprintf("Total Tasks: %d (from tasks.md)\n", total))
 	b.WriteString(fmt.Sprintf("✅ Completed: %d (from progress.md)\n", done))
 	b.WriteString(fmt.Sprintf("🔄 In Progress: %d (from progress.md)\n", prog))
-	b.WriteString(fmt.Sprintf("⏳ Pending: %d (not in progress.md)\n\n", pend))
+	b.WriteString(fmt.Sprintf("⏳ Pending: %d (not in progress.md)\n", pend))
+	if blocked > 0 {
+		b.WriteString(fmt.Sprintf("⛔ Blocked: %d (from progress.md)\n", blocked))
+	}
+	if failed > 0 {
+		b.WriteString(fmt.Sprintf("❌ Failed: %d (from progress.md)\n", failed))
+	}
+	b.WriteString("\n")
 
 	if done > 0 {
 		b.WriteString("✅ Completed Tasks (from progress.md):\n")
@@ -426,6 +502,18 @@ func StatusReportWithProgress(tasksMd string, progressMd string) string {
 		b.WriteString("\n\n")
 	}
 
+	if blocked > 0 {
+		b.WriteString("⛔ Blocked Tasks (from progress.md):\n")
+		b.WriteString(strings.Join(blockedL, "\n"))
+		b.WriteString("\n\n")
+	}
+
+	if failed > 0 {
+		b.WriteString("❌ Failed Tasks (from progress.md, retry with 'cursor-iter triage'):\n")
+		b.WriteString(strings.Join(failedL, "\n"))
+		b.WriteString("\n\n")
+	}
+
 	if pend > 0 {
 		b.WriteString("⏳ Pending Tasks (next 5):\n")
 		if len(pendL) > 5 {
@@ -473,7 +561,7 @@ func CountInProgressTasks(tasksMd string, progressMd string) int {
 // GetAllInProgressTasks returns all tasks marked as in-progress from progress.md
 func GetAllInProgressTasks(tasksMd string, progressMd string) []*Task {
 	tasks := parseTasks(tasksMd)
-	progressEntries := ParseProgress(progressMd)
+	progressEntries := ParseProgressFor(progressMd, taskTitles(tasks))
 	var inProgress []*Task
 
 	for i, t := range tasks {
@@ -492,6 +580,13 @@ func GetAllInProgressTasks(tasksMd string, progressMd string) []*Task {
 // 2. Removing completed tasks from tasks.md
 // Returns: archived content, remaining progress.md, updated tasks.md, archive file path, error
 func ArchiveCompletedTasks(tasksMd string, progressMd string, outdir string) (archived string, remainingProgress string, updatedTasks string, archiveFile string, err error) {
+	return ArchiveCompletedTasksMatching(tasksMd, progressMd, outdir, ArchiveFilter{})
+}
+
+// ArchiveCompletedTasksMatching archives like ArchiveCompletedTasks, but
+// only the completed tasks that pass the filter. Other completions stay in
+// progress.md and tasks.md.
+func ArchiveCompletedTasksMatching(tasksMd string, progressMd string, outdir string, filter ArchiveFilter) (archived string, remainingProgress string, updatedTasks string, archiveFile string, err error) {
 	// Create output directory
 	if err := osMkdirAll(outdir); err != nil {
 		return "", "", "", "", fmt.Errorf("failed to create archive directory: %v", err)
@@ -501,33 +596,49 @@ func ArchiveCompletedTasks(tasksMd string, progressMd string, outdir string) (ar
 	archiveFile = filepath.Join(outdir, fmt.Sprintf("completed_%s.md", ts))
 
 	// Parse progress.md to get completed tasks
-	progressEntries := ParseProgress(progressMd)
+	all := parseTasks(tasksMd)
+	titles := taskTitles(all)
+	milestones := make(map[string]string)
+	for _, t := range all {
+		if m := TaskMilestone(t); m != DefaultMilestone {
+			milestones[t.Title] = m
+		}
+	}
+	progressEntries := ParseProgressFor(progressMd, titles)
 	completedTitles := make(map[string]bool)
 
 	var archivedLines []string
 	archivedLines = append(archivedLines, "# Archived Completed Tasks")
 	archivedLines = append(archivedLines, "")
 	archivedLines = append(archivedLines, fmt.Sprintf("Archived on: %s", time.Now().Format("2006-01-02 15:04")))
+	if !filter.IsZero() {
+		archivedLines = append(archivedLines, fmt.Sprintf("Filter: %s", filter))
+	}
 	archivedLines = append(archivedLines, "")
 
 	// Collect completed tasks for archiving
-	for title, entry := range progressEntries {
-		if entry.Status == "completed" {
-			completedTitles[title] = true
-			completedAt := entry.CompletedAt.Format("2006-01-02 15:04")
-			archivedLine := fmt.Sprintf("- ✅ [%s] %s", completedAt, title)
-			if entry.Notes != "" {
-				archivedLine += fmt.Sprintf(" - %s", entry.Notes)
-			}
-			archivedLines = append(archivedLines, archivedLine)
+	for _, title := range ArchivableTasks(tasksMd, progressMd, filter) {
+		entry := progressEntries[title]
+		completedTitles[title] = true
+		completedAt := entry.CompletedAt.Format("2006-01-02 15:04")
+		archivedLine := fmt.Sprintf("- ✅ [%s] %s", completedAt, entryTitle(title))
+		if entry.Notes != "" {
+			archivedLine += fmt.Sprintf(" - %s", entry.Notes)
 		}
+		// The task leaves tasks.md, so its milestone goes with the entry
+		fields := entry.Fields
+		if _, ok := entry.Field("milestone"); !ok && milestones[title] != "" {
+			fields = append(fields[:len(fields):len(fields)], Field{Name: "milestone", Value: milestones[title]})
+		}
+		archivedLine += formatFields(fields)
+		archivedLines = append(archivedLines, archivedLine)
 	}
 
 	archived = strings.Join(archivedLines, "\n")
 
 	// Remove completed tasks from progress.md (keep only in-progress)
 	var remainingLines []string
-	lines := strings.Split(progressMd, "\n")
+	lines := unwrapEntries(strings.Split(progressMd, "\n"))
 	inCompletedSection := false
 
 	for _, line := range lines {
@@ -548,9 +659,11 @@ func ArchiveCompletedTasks(tasksMd string, progressMd string, outdir string) (ar
 			continue
 		}
 
-		// Skip completed task lines
+		// Skip completed task lines, keeping those the filter leaves out
 		if inCompletedSection && (strings.HasPrefix(trimmed, "- ✅") || strings.HasPrefix(trimmed, "* ✅")) {
-			continue // Don't add this line
+			if title, _ := completedLineTitle(line, titles); filter.IsZero() || completedTitles[title] {
+				continue // Don't add this line
+			}
 		}
 
 		remainingLines = append(remainingLines, line)
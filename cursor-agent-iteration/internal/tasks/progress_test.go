@@ -147,6 +147,21 @@ func TestLogTaskCompletion(t *testing.T) {
 	}
 }
 
+func TestCompleteManually(t *testing.T) {
+	progress := "# Progress Log\n\n## In Progress\n\n- 🔄 [2025-01-08 10:00] Login\n\n## Completed Tasks\n\n## Failed\n\n- ❌ [2025-01-08 09:00] Login - exit status 1\n"
+	result := CompleteManually(progress, "Login", "done manually", "alice")
+	entry, ok := ParseProgress(result)["Login"]
+	if !ok || entry.Status != "completed" || entry.Notes != "done manually" {
+		t.Fatalf("Expected a completion entry, got %+v:\n%s", entry, result)
+	}
+	if by, _ := entry.Field("by"); by != "alice" {
+		t.Errorf("Expected the completion attributed to alice, got %q", by)
+	}
+	if strings.Contains(result, "🔄") || strings.Contains(result, "❌ [") {
+		t.Errorf("Expected the in-progress and failed entries dropped:\n%s", result)
+	}
+}
+
 func TestMarkTaskInProgress(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -0,0 +1,294 @@
+package tasks
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultMilestone groups staged tasks that carry no [milestone:...] label
+const DefaultMilestone = "unassigned"
+
+var reMilestoneHeader = regexp.MustCompile(`^##\s+Milestone:\s*(.+?)\s*$`)
+
+const stagedHeader = "# Staged Tasks\n\nGenerated tasks waiting for approval. Move a milestone into tasks.md with `cursor-iter accept-tasks --milestone <name>`.\n"
+
+// StagedTask is a generated task block waiting for approval
+type StagedTask struct {
+	Title     string
+	Milestone string
+	Block     string // the full "### Task:" block
+}
+
+// Milestone is a group of staged tasks
+type Milestone struct {
+	Name  string
+	Tasks []StagedTask
+}
+
+type taskBlock struct {
+	title      string
+	start, end int // line range [start, end) in the source
+}
+
+// currentTaskBlocks finds the task blocks in the "## Current Tasks" section.
+// A block runs from its "### Task:" header to the next task or section
+// header, without trailing blank lines.
+func currentTaskBlocks(lines []string) (blocks []taskBlock, sectionEnd int) {
+	section := -1
+	sectionEnd = -1
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "## Current Tasks" {
+			section = i
+			continue
+		}
+		if section < 0 {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "## ") {
+			sectionEnd = i
+			break
+		}
+		if m := reTaskHeader.FindStringSubmatch(line); m != nil {
+			blocks = append(blocks, taskBlock{title: cleanTaskTitle(m[1]), start: i})
+		}
+	}
+	if section < 0 {
+		return nil, -1
+	}
+	if sectionEnd < 0 {
+		sectionEnd = len(lines)
+	}
+	for i := range blocks {
+		end := sectionEnd
+		if i+1 < len(blocks) {
+			end = blocks[i+1].start
+		}
+		for end > blocks[i].start+1 && strings.TrimSpace(lines[end-1]) == "" {
+			end--
+		}
+		blocks[i].end = end
+	}
+	return blocks, sectionEnd
+}
+
+// blockRange is the line range to drop when removing the k-th block: the
+// block with the blank lines that separate it from the previous block, or
+// from the next one if it is the first
+func blockRange(lines []string, blocks []taskBlock, k int) (start, end int) {
+	start, end = blocks[k].start, blocks[k].end
+	if k > 0 {
+		for start > 0 && strings.TrimSpace(lines[start-1]) == "" {
+			start--
+		}
+	} else {
+		for end < len(lines) && strings.TrimSpace(lines[end]) == "" {
+			end++
+		}
+	}
+	return start, end
+}
+
+// TakeNewTasks removes the tasks in tasksMd whose titles were not in
+// previous (tasks.md before add-feature ran) and returns them with the rest
+// of tasks.md. The milestone of each task comes from its [milestone:...]
+// label, falling back to defaultMilestone.
+func TakeNewTasks(previous string, tasksMd string, defaultMilestone string) (remaining string, taken []StagedTask) {
+	existing := make(map[string]bool)
+	for _, t := range parseTasks(previous) {
+		existing[cleanTaskTitle(t.Title)] = true
+	}
+	if defaultMilestone == "" {
+		defaultMilestone = DefaultMilestone
+	}
+
+	lines := strings.Split(tasksMd, "\n")
+	blocks, _ := currentTaskBlocks(lines)
+	drop := make(map[int]bool)
+	for k, b := range blocks {
+		if existing[b.title] {
+			continue
+		}
+		block := strings.Join(lines[b.start:b.end], "\n")
+		milestone, ok := LabelValue(ParseLabels(block), "milestone")
+		if !ok || milestone == "" {
+			milestone = defaultMilestone
+		}
+		taken = append(taken, StagedTask{Title: b.title, Milestone: milestone, Block: block})
+		start, end := blockRange(lines, blocks, k)
+		for i := start; i < end; i++ {
+			drop[i] = true
+		}
+	}
+	if len(taken) == 0 {
+		return tasksMd, nil
+	}
+	var kept []string
+	for i, line := range lines {
+		if !drop[i] {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n"), taken
+}
+
+// ParseStaged returns the milestones of the staging file in order. Tasks
+// before the first milestone header belong to DefaultMilestone.
+func ParseStaged(stagedMd string) []Milestone {
+	var milestones []Milestone
+	index := make(map[string]int)
+	current := DefaultMilestone
+	var block []string
+	flush := func() {
+		if len(block) == 0 {
+			return
+		}
+		text := strings.TrimRight(strings.Join(block, "\n"), "\n ")
+		title := cleanTaskTitle(reTaskHeader.FindStringSubmatch(block[0])[1])
+		i, ok := index[current]
+		if !ok {
+			i = len(milestones)
+			index[current] = i
+			milestones = append(milestones, Milestone{Name: current})
+		}
+		milestones[i].Tasks = append(milestones[i].Tasks, StagedTask{Title: title, Milestone: current, Block: text})
+		block = nil
+	}
+	for _, line := range strings.Split(stagedMd, "\n") {
+		if m := reMilestoneHeader.FindStringSubmatch(line); m != nil {
+			flush()
+			current = m[1]
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "## ") || strings.HasPrefix(line, "# ") {
+			flush()
+			continue
+		}
+		if reTaskHeader.MatchString(line) {
+			flush()
+			block = []string{line}
+			continue
+		}
+		if block != nil {
+			block = append(block, line)
+		}
+	}
+	flush()
+	return milestones
+}
+
+// FormatStaged renders milestones as a staging file
+func FormatStaged(milestones []Milestone) string {
+	var b strings.Builder
+	b.WriteString(stagedHeader)
+	for _, m := range milestones {
+		if len(m.Tasks) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n## Milestone: %s\n", m.Name)
+		for _, t := range m.Tasks {
+			b.WriteString("\n" + t.Block + "\n")
+		}
+	}
+	return b.String()
+}
+
+// AddStaged adds tasks to the staging file, grouped by milestone. Tasks
+// already staged under the same title are replaced.
+func AddStaged(stagedMd string, staged []StagedTask) string {
+	milestones := ParseStaged(stagedMd)
+	for _, t := range staged {
+		for i := range milestones {
+			milestones[i].Tasks = removeStaged(milestones[i].Tasks, t.Title)
+		}
+		found := false
+		for i := range milestones {
+			if milestones[i].Name == t.Milestone {
+				milestones[i].Tasks = append(milestones[i].Tasks, t)
+				found = true
+				break
+			}
+		}
+		if !found {
+			milestones = append(milestones, Milestone{Name: t.Milestone, Tasks: []StagedTask{t}})
+		}
+	}
+	return FormatStaged(milestones)
+}
+
+func removeStaged(list []StagedTask, title string) []StagedTask {
+	var kept []StagedTask
+	for _, t := range list {
+		if t.Title != title {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// AcceptMilestone moves the tasks of a staged milestone to the end of the
+// "## Current Tasks" section of tasks.md. Tasks whose title is already in
+// tasks.md are left out of tasks.md and reported as skipped.
+func AcceptMilestone(stagedMd string, tasksMd string, milestone string) (newStaged string, newTasks string, accepted []string, skipped []string, err error) {
+	milestones := ParseStaged(stagedMd)
+	var selected *Milestone
+	var rest []Milestone
+	for i := range milestones {
+		if strings.EqualFold(milestones[i].Name, milestone) {
+			selected = &milestones[i]
+		} else {
+			rest = append(rest, milestones[i])
+		}
+	}
+	if selected == nil {
+		return stagedMd, tasksMd, nil, nil, fmt.Errorf("no staged milestone %q", milestone)
+	}
+
+	existing := make(map[string]bool)
+	for _, t := range parseTasks(tasksMd) {
+		existing[cleanTaskTitle(t.Title)] = true
+	}
+	var blocks []string
+	for _, t := range selected.Tasks {
+		if existing[t.Title] {
+			skipped = append(skipped, t.Title)
+			continue
+		}
+		existing[t.Title] = true
+		blocks = append(blocks, t.Block)
+		accepted = append(accepted, t.Title)
+	}
+	return FormatStaged(rest), AppendTasks(tasksMd, blocks), accepted, skipped, nil
+}
+
+// AppendTasks inserts task blocks at the end of the
+// "## Current Tasks" section, creating the section if needed
+func AppendTasks(tasksMd string, blocks []string) string {
+	if len(blocks) == 0 {
+		return tasksMd
+	}
+	lines := strings.Split(tasksMd, "\n")
+	_, sectionEnd := currentTaskBlocks(lines)
+	if sectionEnd < 0 {
+		return strings.TrimRight(tasksMd, "\n") + "\n\n## Current Tasks\n\n" + strings.Join(blocks, "\n\n") + "\n"
+	}
+	insert := sectionEnd
+	for insert > 0 && strings.TrimSpace(lines[insert-1]) == "" {
+		insert--
+	}
+	var out []string
+	out = append(out, lines[:insert]...)
+	for _, block := range blocks {
+		out = append(out, "", block)
+	}
+	if sectionEnd < len(lines) {
+		out = append(out, "")
+	}
+	out = append(out, lines[sectionEnd:]...)
+	result := strings.Join(out, "\n")
+	if sectionEnd == len(lines) && !strings.HasSuffix(result, "\n") {
+		result += "\n"
+	}
+	return result
+}
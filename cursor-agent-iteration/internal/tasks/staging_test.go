@@ -0,0 +1,114 @@
+package tasks
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const stagingBefore = `# Tasks
+
+## Current Tasks
+
+### Task: Existing
+**Acceptance Criteria:**
+* [ ] Works
+
+## Archive
+`
+
+const stagingAfter = `# Tasks
+
+## Current Tasks
+
+### Task: Existing
+**Acceptance Criteria:**
+* [ ] Works
+
+### Task: Login
+**Labels:** ` + "`[milestone:auth]`" + `
+**Acceptance Criteria:**
+* [ ] Users can log in
+
+### Task: Invoices
+**Acceptance Criteria:**
+* [ ] Invoices are emailed
+
+### Task: Logout
+**Labels:** ` + "`[milestone:auth]`" + `
+**Acceptance Criteria:**
+* [ ] Users can log out
+
+## Archive
+`
+
+func TestTakeNewTasks(t *testing.T) {
+	remaining, taken := TakeNewTasks(stagingBefore, stagingAfter, "")
+	if remaining != stagingBefore {
+		t.Errorf("Expected tasks.md restored to its previous content, got:\n%s", remaining)
+	}
+	var got [][2]string
+	for _, st := range taken {
+		got = append(got, [2]string{st.Title, st.Milestone})
+	}
+	want := [][2]string{{"Login", "auth"}, {"Invoices", DefaultMilestone}, {"Logout", "auth"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TakeNewTasks() took %v, want %v", got, want)
+	}
+	if !strings.HasPrefix(taken[0].Block, "### Task: Login\n") || !strings.HasSuffix(taken[0].Block, "* [ ] Users can log in") {
+		t.Errorf("Unexpected block %q", taken[0].Block)
+	}
+
+	if remaining, taken := TakeNewTasks(stagingAfter, stagingAfter, ""); remaining != stagingAfter || taken != nil {
+		t.Errorf("Expected nothing to take when no tasks were added")
+	}
+}
+
+func TestStageAndAccept(t *testing.T) {
+	_, taken := TakeNewTasks(stagingBefore, stagingAfter, "billing")
+	staged := AddStaged("", taken)
+
+	milestones := ParseStaged(staged)
+	if len(milestones) != 2 || milestones[0].Name != "auth" || len(milestones[0].Tasks) != 2 || milestones[1].Name != "billing" {
+		t.Fatalf("ParseStaged() = %+v", milestones)
+	}
+	if !strings.Contains(staged, "## Milestone: auth\n\n### Task: Login") {
+		t.Errorf("Expected milestone sections in staging file:\n%s", staged)
+	}
+
+	// Re-staging a task replaces it instead of duplicating it
+	if again := ParseStaged(AddStaged(staged, taken[:1])); len(again[0].Tasks) != 2 {
+		t.Errorf("Expected re-staged task to replace the old copy, got %+v", again)
+	}
+
+	newStaged, newTasks, accepted, skipped, err := AcceptMilestone(staged, stagingBefore, "Auth")
+	if err != nil {
+		t.Fatalf("AcceptMilestone() = %v", err)
+	}
+	if !reflect.DeepEqual(accepted, []string{"Login", "Logout"}) || skipped != nil {
+		t.Errorf("accepted %v, skipped %v", accepted, skipped)
+	}
+	titles := []string{}
+	for _, task := range ParseTasks(newTasks) {
+		titles = append(titles, task.Title)
+	}
+	if !reflect.DeepEqual(titles, []string{"Existing", "Login", "Logout"}) {
+		t.Errorf("tasks.md titles = %v\n%s", titles, newTasks)
+	}
+	if !strings.Contains(newTasks, "* [ ] Users can log out\n\n## Archive") {
+		t.Errorf("Expected accepted tasks before the next section:\n%s", newTasks)
+	}
+	if rest := ParseStaged(newStaged); len(rest) != 1 || rest[0].Name != "billing" {
+		t.Errorf("Expected only billing left staged, got %+v", rest)
+	}
+
+	// Accepting again skips tasks that are already queued
+	_, _, accepted, skipped, _ = AcceptMilestone(staged, newTasks, "auth")
+	if len(accepted) != 0 || len(skipped) != 2 {
+		t.Errorf("Expected duplicates to be skipped, accepted %v, skipped %v", accepted, skipped)
+	}
+
+	if _, _, _, _, err := AcceptMilestone(staged, stagingBefore, "missing"); err == nil {
+		t.Errorf("Expected error for unknown milestone")
+	}
+}
@@ -0,0 +1,284 @@
+package tasks
+
+import (
+	"strings"
+	"time"
+)
+
+// StatusTask is one task of a status summary
+type StatusTask struct {
+	Title       string     `json:"title"`
+	Status      string     `json:"status"` // "pending", "in-progress", "completed", "blocked" or "failed"
+	ACChecked   int        `json:"ac_checked"`
+	ACTotal     int        `json:"ac_total"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	Notes       string     `json:"notes,omitempty"` // completion notes or the reason a task is blocked or failed
+}
+
+// StatusTotals counts the tasks of a status summary by status
+type StatusTotals struct {
+	Total      int `json:"total"`
+	Completed  int `json:"completed"`
+	InProgress int `json:"in_progress"`
+	Pending    int `json:"pending"`
+	Blocked    int `json:"blocked"`
+	Failed     int `json:"failed"`
+}
+
+// StatusSummary is the machine-readable form of StatusReportWithProgress
+type StatusSummary struct {
+	Current string       `json:"current,omitempty"` // the first task in progress
+	Next    string       `json:"next,omitempty"`    // the pending task iterate picks next, when none is in progress
+	Tasks   []StatusTask `json:"tasks"`
+	Totals  StatusTotals `json:"totals"`
+	// Page says which tasks Tasks holds when it is one page of them
+	Page *StatusPage `json:"page,omitempty"`
+	// Groups count the tasks per milestone or label, when asked for
+	Groups []StatusGroup `json:"groups,omitempty"`
+}
+
+// StatusPage describes one page of a status summary
+type StatusPage struct {
+	Number int `json:"number"` // starting at 1
+	Size   int `json:"size"`
+	Pages  int `json:"pages"`
+	First  int `json:"first"` // position of the page's first task in tasks.md, from 1; 0 for an empty page
+}
+
+// StatusGroup counts the tasks of one milestone or label
+type StatusGroup struct {
+	Name   string       `json:"name"`
+	Totals StatusTotals `json:"totals"`
+}
+
+// Status groupings besides a label key
+const (
+	GroupByLabel = "label" // every label is a group
+	// NoGroup collects the tasks without the label grouped by
+	NoGroup = "none"
+)
+
+// StatusOptions trim a status summary for large backlogs
+type StatusOptions struct {
+	// CountsOnly leaves the tasks out; tasks.md is then only scanned for
+	// titles, not parsed, unless no task is in progress and the next one is
+	// picked
+	CountsOnly bool
+	// Page lists only one page of PageSize tasks, in tasks.md order, and
+	// parses tasks.md no further than that page. Pages start at 1; 0 lists
+	// every task.
+	Page     int
+	PageSize int
+	// GroupBy counts the tasks per value of a label key, e.g. milestone or
+	// type, or per label with GroupByLabel
+	GroupBy string
+	// Defaults are the label defaults merged into every task
+	Defaults LabelDefaults
+}
+
+// Summarize returns the status of every task in tasks.md, taken from
+// progress.md like StatusReportWithProgress
+func Summarize(tasksMd string, progressMd string) StatusSummary {
+	return summarize(tasksMd, progressMd, nil)
+}
+
+// summarize is Summarize with label defaults
+func summarize(tasksMd string, progressMd string, defaults LabelDefaults) StatusSummary {
+	tasks := parseTasksN(tasksMd, -1, defaults)
+	progressEntries := ParseProgressFor(progressMd, taskTitles(tasks))
+
+	s := StatusSummary{Tasks: make([]StatusTask, 0, len(tasks))}
+	for _, t := range tasks {
+		st := statusTask(t, progressEntries)
+		s.count(t.Title, st.Status)
+		s.Tasks = append(s.Tasks, st)
+	}
+	if s.Current == "" {
+		if next := pickNext(tasks, progressEntries, nil); next != nil {
+			s.Next = next.Title
+		}
+	}
+	return s
+}
+
+// SummarizeWith is Summarize trimmed by opts. The totals, current and next
+// tasks always cover every task.
+func SummarizeWith(tasksMd string, progressMd string, opts StatusOptions) StatusSummary {
+	if !opts.CountsOnly && opts.Page <= 0 && opts.GroupBy == "" {
+		return summarize(tasksMd, progressMd, opts.Defaults)
+	}
+	heads := scanTaskHeads(tasksMd, opts.GroupBy != "")
+	titles := make([]string, len(heads))
+	for i, h := range heads {
+		titles[i] = h.Title
+	}
+	progressEntries := ParseProgressFor(progressMd, titles)
+
+	s := StatusSummary{Tasks: []StatusTask{}}
+	groups := make(map[string]*StatusTotals)
+	var groupOrder []string
+	for _, h := range heads {
+		status := "pending"
+		if entry, exists := progressEntries[h.Title]; exists {
+			status = entry.Status
+		}
+		s.count(h.Title, status)
+		for _, name := range taskGroups(opts.Defaults.Apply(h.Labels), opts.GroupBy) {
+			if groups[name] == nil {
+				groups[name] = &StatusTotals{}
+				groupOrder = append(groupOrder, name)
+			}
+			countStatus(groups[name], status)
+		}
+	}
+	// Picking the next task takes the priorities and dependencies of every
+	// task, so only an idle backlog is parsed for it
+	if s.Current == "" && s.Totals.Pending > 0 {
+		if next := nextPendingTask(tasksMd, progressMd, nil, opts.Defaults); next != nil {
+			s.Next = next.Title
+		}
+	}
+	for _, name := range groupOrder {
+		s.Groups = append(s.Groups, StatusGroup{Name: name, Totals: *groups[name]})
+	}
+
+	switch {
+	case opts.Page > 0:
+		size := opts.PageSize
+		if size <= 0 {
+			size = DefaultPageSize
+		}
+		page := &StatusPage{Number: opts.Page, Size: size, Pages: (len(heads) + size - 1) / size}
+		start := (opts.Page - 1) * size
+		if start < len(heads) {
+			end := min(start+size, len(heads))
+			page.First = start + 1
+			parsed := parseTasksN(tasksMd, end, opts.Defaults)
+			for _, t := range parsed[start:] {
+				s.Tasks = append(s.Tasks, statusTask(t, progressEntries))
+			}
+		}
+		s.Page = page
+	case !opts.CountsOnly:
+		for _, t := range parseTasksN(tasksMd, -1, opts.Defaults) {
+			s.Tasks = append(s.Tasks, statusTask(t, progressEntries))
+		}
+	}
+	return s
+}
+
+// DefaultPageSize is the page size of StatusOptions without one
+const DefaultPageSize = 50
+
+// statusTask is the status summary entry of a parsed task
+func statusTask(t Task, progressEntries map[string]ProgressEntry) StatusTask {
+	st := StatusTask{Title: t.Title, Status: "pending", ACChecked: t.ACChecked, ACTotal: t.ACTotal}
+	if entry, exists := progressEntries[t.Title]; exists {
+		st.Status = entry.Status
+		st.StartedAt = timePtr(entry.StartedAt)
+		st.CompletedAt = timePtr(entry.CompletedAt)
+		st.Notes = entry.Notes
+	}
+	return st
+}
+
+// count adds a task to the totals, and notes it as the current task if it
+// is the first in progress
+func (s *StatusSummary) count(title, status string) {
+	countStatus(&s.Totals, status)
+	if status == "in-progress" && s.Current == "" {
+		s.Current = title
+	}
+}
+
+// countStatus adds a task of the given status to totals
+func countStatus(totals *StatusTotals, status string) {
+	totals.Total++
+	switch status {
+	case "completed":
+		totals.Completed++
+	case "in-progress":
+		totals.InProgress++
+	case "blocked":
+		totals.Blocked++
+	case "failed":
+		totals.Failed++
+	default:
+		totals.Pending++
+	}
+}
+
+// taskGroups returns the groups a task with the given labels counts in
+func taskGroups(labels []string, groupBy string) []string {
+	switch {
+	case groupBy == "":
+		return nil
+	case groupBy == GroupByLabel:
+		if len(labels) == 0 {
+			return []string{NoGroup}
+		}
+		return labels
+	case strings.EqualFold(groupBy, "milestone"):
+		return []string{TaskMilestone(Task{Labels: labels})}
+	}
+	if v, ok := LabelValue(labels, groupBy); ok && v != "" {
+		return []string{v}
+	}
+	return []string{NoGroup}
+}
+
+// taskHead is the title and labels of a task, all a summary needs
+type taskHead struct {
+	Title  string
+	Labels []string
+}
+
+// scanTaskHeads finds the tasks of the "## Current Tasks" section like
+// parseTasks, but only reads their titles, and their labels if asked, which
+// is much cheaper for large backlogs
+func scanTaskHeads(md string, withLabels bool) []taskHead {
+	var heads []taskHead
+	inCurrentTasks, open := false, false
+	for len(md) > 0 {
+		line, rest, _ := strings.Cut(md, "\n")
+		md = rest
+		trimmed := strings.TrimSpace(line)
+		isLabels := withLabels && open && strings.Contains(line, "**Labels:**")
+		if !strings.HasPrefix(trimmed, "#") && !isLabels {
+			continue
+		}
+		if trimmed == "## Current Tasks" {
+			inCurrentTasks = true
+			continue
+		}
+		if !inCurrentTasks {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "## ") {
+			break
+		}
+		if m := reTaskHeader.FindStringSubmatch(line); m != nil {
+			heads = append(heads, taskHead{Title: strings.TrimSpace(m[1])})
+			open = true
+			continue
+		}
+		if isLabels && reLabelsLine.MatchString(line) {
+			heads[len(heads)-1].Labels = ParseLabels(line)
+			continue
+		}
+		if strings.HasPrefix(line, "### ") {
+			// end section
+			open = false
+		}
+	}
+	return heads
+}
+
+// timePtr returns nil for the zero time, so unset timestamps are omitted
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,137 @@
+package tasks
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSummarize(t *testing.T) {
+	tasksMd := "## Current Tasks\n\n" +
+		"### Task: Task A\n**Acceptance Criteria:**\n* [x] one\n* [ ] two\n\n" +
+		"### Task: Task B\n\n" +
+		"### Task: Task C\n**Acceptance Criteria:**\n* [x] one\n\n" +
+		"### Task: Task D\n"
+	progressMd := MarkTaskBlocked(blockedProgress, "Task B", "needs credentials")
+
+	s := Summarize(tasksMd, progressMd)
+	if s.Current != "Task A" || s.Next != "" {
+		t.Errorf("Current = %q, Next = %q", s.Current, s.Next)
+	}
+	expected := StatusTotals{Total: 4, Completed: 1, InProgress: 1, Pending: 1, Blocked: 1}
+	if s.Totals != expected {
+		t.Errorf("Totals = %+v, want %+v", s.Totals, expected)
+	}
+	if len(s.Tasks) != 4 {
+		t.Fatalf("Expected 4 tasks, got %+v", s.Tasks)
+	}
+
+	a, b, c, d := s.Tasks[0], s.Tasks[1], s.Tasks[2], s.Tasks[3]
+	startedA := time.Date(2025, 1, 8, 19, 0, 0, 0, time.UTC)
+	if a.Status != "in-progress" || a.ACChecked != 1 || a.ACTotal != 2 || a.StartedAt == nil || !a.StartedAt.Equal(startedA) || a.CompletedAt != nil {
+		t.Errorf("Task A = %+v", a)
+	}
+	if b.Status != "blocked" || b.Notes != "needs credentials" {
+		t.Errorf("Task B = %+v", b)
+	}
+	if c.Status != "completed" || c.CompletedAt == nil {
+		t.Errorf("Task C = %+v", c)
+	}
+	if d.Status != "pending" || d.StartedAt != nil || d.CompletedAt != nil {
+		t.Errorf("Task D = %+v", d)
+	}
+
+	if s := Summarize(tasksMd, ""); s.Current != "" || s.Next != "Task A" || s.Totals.Pending != 4 {
+		t.Errorf("Without progress: %+v", s)
+	}
+	if s := Summarize("", ""); s.Tasks == nil || s.Totals.Total != 0 {
+		t.Errorf("Expected an empty task list, got %+v", s)
+	}
+}
+
+func TestSummarizeWith(t *testing.T) {
+	tasksMd := "## Current Tasks\n\n" +
+		"### Task: Task A\n**Labels:** [milestone:auth] [type:bug]\n**Acceptance Criteria:**\n* [x] one\n* [ ] two\n\n" +
+		"### Task: Task B\n**Labels:** [milestone:auth]\n\n" +
+		"### Task: Task C\n**Acceptance Criteria:**\n* [x] one\n\n" +
+		"### Notes\n**Labels:** [milestone:ignored]\n\n" +
+		"### Task: Task D\n**Labels:** [type:bug]\n\n" +
+		"## Completed Tasks\n\n### Task: Old\n"
+	progressMd := MarkTaskBlocked(blockedProgress, "Task B", "needs credentials")
+	all := Summarize(tasksMd, progressMd)
+
+	if s := SummarizeWith(tasksMd, progressMd, StatusOptions{}); !reflect.DeepEqual(s, all) {
+		t.Errorf("Without options = %+v, want %+v", s, all)
+	}
+
+	s := SummarizeWith(tasksMd, progressMd, StatusOptions{CountsOnly: true})
+	if s.Totals != all.Totals || s.Current != all.Current || s.Next != all.Next || len(s.Tasks) != 0 || s.Tasks == nil || s.Page != nil {
+		t.Errorf("Counts only = %+v, want the totals of %+v", s, all)
+	}
+
+	pages := []struct {
+		page, size int
+		titles     []string
+		expected   StatusPage
+	}{
+		{1, 3, []string{"Task A", "Task B", "Task C"}, StatusPage{Number: 1, Size: 3, Pages: 2, First: 1}},
+		{2, 3, []string{"Task D"}, StatusPage{Number: 2, Size: 3, Pages: 2, First: 4}},
+		{2, 2, []string{"Task C", "Task D"}, StatusPage{Number: 2, Size: 2, Pages: 2, First: 3}},
+		{3, 2, nil, StatusPage{Number: 3, Size: 2, Pages: 2}},
+		{1, 0, []string{"Task A", "Task B", "Task C", "Task D"}, StatusPage{Number: 1, Size: DefaultPageSize, Pages: 1, First: 1}},
+	}
+	for _, p := range pages {
+		s := SummarizeWith(tasksMd, progressMd, StatusOptions{Page: p.page, PageSize: p.size})
+		var titles []string
+		for i, task := range s.Tasks {
+			titles = append(titles, task.Title)
+			if want := all.Tasks[p.expected.First-1+i]; !reflect.DeepEqual(task, want) {
+				t.Errorf("Page %d/%d: %+v, want %+v", p.page, p.size, task, want)
+			}
+		}
+		if !reflect.DeepEqual(titles, p.titles) || s.Page == nil || *s.Page != p.expected || s.Totals != all.Totals {
+			t.Errorf("Page %d/%d = %v %+v, want %v %+v", p.page, p.size, titles, s.Page, p.titles, p.expected)
+		}
+	}
+
+	groups := []struct {
+		by       string
+		expected []StatusGroup
+	}{
+		{"milestone", []StatusGroup{
+			{Name: "auth", Totals: StatusTotals{Total: 2, InProgress: 1, Blocked: 1}},
+			{Name: DefaultMilestone, Totals: StatusTotals{Total: 2, Completed: 1, Pending: 1}},
+		}},
+		{"type", []StatusGroup{
+			{Name: "bug", Totals: StatusTotals{Total: 2, InProgress: 1, Pending: 1}},
+			{Name: NoGroup, Totals: StatusTotals{Total: 2, Completed: 1, Blocked: 1}},
+		}},
+		{GroupByLabel, []StatusGroup{
+			{Name: "milestone:auth", Totals: StatusTotals{Total: 2, InProgress: 1, Blocked: 1}},
+			{Name: "type:bug", Totals: StatusTotals{Total: 2, InProgress: 1, Pending: 1}},
+			{Name: NoGroup, Totals: StatusTotals{Total: 1, Completed: 1}},
+		}},
+	}
+	for _, g := range groups {
+		s := SummarizeWith(tasksMd, progressMd, StatusOptions{GroupBy: g.by, CountsOnly: true})
+		if !reflect.DeepEqual(s.Groups, g.expected) {
+			t.Errorf("Grouped by %s = %+v, want %+v", g.by, s.Groups, g.expected)
+		}
+	}
+	if s := SummarizeWith(tasksMd, progressMd, StatusOptions{GroupBy: "milestone"}); len(s.Tasks) != 4 || len(s.Groups) != 2 {
+		t.Errorf("Grouped with tasks = %+v", s)
+	}
+}
+
+func TestParseTasksN(t *testing.T) {
+	for _, limit := range []int{0, 1, 2, 3, 4} {
+		got := parseTasksN(sample, limit, nil)
+		want := parseTasks(sample)
+		if limit < len(want) {
+			want = want[:limit]
+		}
+		if len(got) != len(want) || (len(want) > 0 && !reflect.DeepEqual(got, want)) {
+			t.Errorf("parseTasksN(%d) = %+v, want %+v", limit, got, want)
+		}
+	}
+}
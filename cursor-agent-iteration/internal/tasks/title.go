@@ -0,0 +1,195 @@
+package tasks
+
+import (
+	"strings"
+	"unicode"
+)
+
+// entrySeparators end the title of a progress entry and start its notes.
+// Agents often type an em or en dash instead of " - ".
+var entrySeparators = []string{" - ", " — ", " – "}
+
+// titleQuotes may wrap the title of a progress entry, keeping separators in
+// the title from splitting it. Markdown emphasis and code spans count too.
+var titleQuotes = [][2]string{{`"`, `"`}, {"“", "”"}, {"**", "**"}, {"__", "__"}, {"`", "`"}}
+
+// entryMarkers start the in-progress, completed and blocked entries of
+// progress.md
+var entryMarkers = []string{"- 🔄", "* 🔄", "- ✅", "* ✅", "- ⛔", "* ⛔"}
+
+// normalizeTitle makes titles typed by different hands comparable: unicode
+// spaces such as NBSP become single spaces, zero-width spaces, word joiners
+// and byte order marks are dropped, and the ends are trimmed. Combining
+// characters are left alone, so precomposed and decomposed accents still
+// differ.
+func normalizeTitle(title string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range title {
+		switch {
+		case r == '\u200b' || r == '\u2060' || r == '\ufeff':
+			continue
+		case unicode.IsSpace(r):
+			space = true
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// entryTitle renders a title for a progress entry, quoting it when it would
+// otherwise not read back whole. The quotes are a pair that doesn't close
+// inside the title.
+func entryTitle(title string) string {
+	padded := " " + title + " "
+	quote := strings.Contains(padded, fieldSeparator)
+	for _, sep := range entrySeparators {
+		quote = quote || strings.Contains(padded, sep)
+	}
+	for _, q := range titleQuotes {
+		quote = quote || strings.HasPrefix(title, q[0])
+	}
+	if !quote {
+		return title
+	}
+	for _, q := range titleQuotes {
+		if !strings.Contains(title, q[1]) {
+			return q[0] + title + q[1]
+		}
+	}
+	return `"` + title + `"`
+}
+
+// endsTitle reports whether the text after a candidate title starts the
+// notes or fields of an entry, or is empty
+func endsTitle(rest string) bool {
+	if rest == "" || strings.HasPrefix(rest, fieldSeparator) {
+		return true
+	}
+	for _, sep := range entrySeparators {
+		if strings.HasPrefix(rest, sep) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchTitle reports whether a progress entry, after its timestamp, starts
+// with title, bare or quoted, and returns the text after it. Spacing is
+// compared after normalizeTitle.
+func matchTitle(entry string, title string) (rest string, ok bool) {
+	want := normalizeTitle(title)
+	if want == "" {
+		return "", false
+	}
+	for _, q := range append(titleQuotes, [2]string{"", ""}) {
+		inner, ok := strings.CutPrefix(entry, q[0])
+		if !ok {
+			continue
+		}
+		rest, ok := strings.CutPrefix(normalizeTitle(inner), want)
+		if ok && q[1] != "" {
+			rest, ok = strings.CutPrefix(strings.TrimLeft(rest, " "), q[1])
+		}
+		if ok && endsTitle(rest) {
+			return rest, true
+		}
+	}
+	return "", false
+}
+
+// unquoteTitle unwraps a title quoted at the start of a progress entry and
+// returns the text after it
+func unquoteTitle(entry string) (title string, rest string, ok bool) {
+	for _, q := range titleQuotes {
+		inner, ok := strings.CutPrefix(entry, q[0])
+		if !ok {
+			continue
+		}
+		for from := 0; from < len(inner); {
+			at := strings.Index(inner[from:], q[1])
+			if at < 0 {
+				break
+			}
+			end := from + at
+			if end > 0 && endsTitle(inner[end+len(q[1]):]) {
+				return inner[:end], inner[end+len(q[1]):], true
+			}
+			from = end + len(q[1])
+		}
+	}
+	return "", "", false
+}
+
+// cutSeparator splits text at its first entry separator
+func cutSeparator(text string) (before string, after string) {
+	at, sep := -1, ""
+	for _, s := range entrySeparators {
+		if i := strings.Index(text, s); i >= 0 && (at < 0 || i < at) {
+			at, sep = i, s
+		}
+	}
+	if at < 0 {
+		return text, ""
+	}
+	return text[:at], text[at+len(sep):]
+}
+
+// isContinuation reports whether a line continues the progress entry above
+// it: indented text that isn't a list item or header. Long entries get
+// wrapped this way by editors and agents.
+func isContinuation(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || trimmed == line || !(line[0] == ' ' || line[0] == '\t') {
+		return false
+	}
+	for _, prefix := range []string{"- ", "* ", "+ ", "#"} {
+		if strings.HasPrefix(trimmed, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// isEntryLine reports whether a line starts a progress entry
+func isEntryLine(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	for _, marker := range entryMarkers {
+		if strings.HasPrefix(trimmed, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// unwrapEntries joins each progress entry with the continuation lines it
+// was wrapped onto, separated by newlines, so entries can be handled one
+// element at a time and still written back unchanged
+func unwrapEntries(lines []string) []string {
+	var joined []string
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if isEntryLine(line) {
+			for i+1 < len(lines) && isContinuation(lines[i+1]) {
+				line += "\n" + lines[i+1]
+				i++
+			}
+		}
+		joined = append(joined, line)
+	}
+	return joined
+}
+
+// taskTitles returns the titles of tasks
+func taskTitles(tasks []Task) []string {
+	titles := make([]string, len(tasks))
+	for i, t := range tasks {
+		titles[i] = t.Title
+	}
+	return titles
+}
@@ -0,0 +1,206 @@
+package tasks
+
+import (
+	"strings"
+	"testing"
+)
+
+// titleCorpus holds titles that have broken progress parsing: separators,
+// markdown, quotes, odd spacing and non-Latin scripts
+var titleCorpus = []string{
+	"Add login",
+	"Fix: crash on startup",
+	"Docs: API: describe pagination",
+	"Login - OAuth",
+	"Login — OAuth",
+	"Login – OAuth",
+	"Deploy | env: prod",
+	"**Bold** title",
+	"`--json` flag for run-agent",
+	"__init__ cleanup",
+	`"Quoted" start`,
+	`Say "hi" - politely`,
+	"“Curly” quotes",
+	"Rate-limit API calls",
+	"Handle [brackets] in titles",
+	"添加用户登录功能",
+	"ユーザー設定を保存する",
+	"사용자 인증 추가",
+	"إضافة تسجيل الدخول",
+	"הוספת התחברות",
+	"Добавить вход: OAuth — Google",
+	"Ελληνικά - δοκιμή",
+	"हिंदी अनुवाद जोड़ें",
+	"Café crème",
+	"Emoji 🚀 launch ✅",
+	"Non\u00a0breaking space",
+	"Zero\u200bwidth",
+	"Tab\tseparated",
+	"Trailing dash -",
+	"- Leading dash",
+	"50% - 100%",
+}
+
+func TestNormalizeTitle(t *testing.T) {
+	tests := []struct {
+		title    string
+		expected string
+	}{
+		{"Add login", "Add login"},
+		{"  Add   login  ", "Add login"},
+		{"Add\u00a0login", "Add login"},
+		{"Add\u202flogin", "Add login"},
+		{"Add\u200b login", "Add login"},
+		{"\ufeffAdd login", "Add login"},
+		{"Add\u2060login", "Addlogin"},
+		{"添加\u3000登录", "添加 登录"},
+		{"Café", "Café"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeTitle(tt.title); got != tt.expected {
+			t.Errorf("normalizeTitle(%q) = %q, want %q", tt.title, got, tt.expected)
+		}
+	}
+}
+
+func TestTitleCorpusRoundTrip(t *testing.T) {
+	for _, title := range titleCorpus {
+		t.Run(title, func(t *testing.T) {
+			progress := LogTaskCompletion("", title, "done - see PR")
+			if !IsTaskCompleted(progress, title) {
+				t.Fatalf("not completed:\n%s", progress)
+			}
+			entry := ParseProgressFor(progress, []string{title})[title]
+			if entry.Notes != "done - see PR" {
+				t.Errorf("notes = %q", entry.Notes)
+			}
+			// Without the task list, the written entry still reads back
+			if _, ok := ParseProgress(progress)[normalizeTitle(title)]; !ok {
+				t.Errorf("ParseProgress lost the title: %+v", ParseProgress(progress))
+			}
+
+			progress = MarkTaskInProgress("", title)
+			if !IsTaskInProgress(progress, title) {
+				t.Fatalf("not in progress:\n%s", progress)
+			}
+			progress = MoveTaskToCompleted(progress, title, "")
+			if IsTaskInProgress(progress, title) || !IsTaskCompleted(progress, title) {
+				t.Errorf("not moved to completed:\n%s", progress)
+			}
+
+			progress = MarkTaskBlocked(MarkTaskInProgress("", title), title, "waiting")
+			if !IsTaskBlocked(progress, title) || IsTaskInProgress(progress, title) {
+				t.Fatalf("not blocked:\n%s", progress)
+			}
+			if IsTaskBlocked(UnblockTask(progress, title), title) {
+				t.Errorf("still blocked after unblocking")
+			}
+		})
+	}
+}
+
+func TestSplitEntryAgentSpellings(t *testing.T) {
+	titles := []string{"Login", "Login - OAuth", "Deploy | env: prod", "添加用户登录功能", "Non breaking space"}
+	tests := []struct {
+		name    string
+		entry   string
+		title   string
+		notes   string
+		nFields int
+	}{
+		{"unquoted separator in title", "Login - OAuth - done", "Login - OAuth", "done", 0},
+		{"shorter title", "Login - done", "Login", "done", 0},
+		{"em dash notes", "Login — done", "Login", "done", 0},
+		{"en dash notes", "Login – done", "Login", "done", 0},
+		{"bold title", "**Login - OAuth** - done", "Login - OAuth", "done", 0},
+		{"code title", "`Login` - done", "Login", "done", 0},
+		{"curly quotes", "“Login - OAuth” — done", "Login - OAuth", "done", 0},
+		{"title looks like a field", "Deploy | env: prod | ticket: OPS-1", "Deploy | env: prod", "", 1},
+		{"CJK", "添加用户登录功能 - 完成", "添加用户登录功能", "完成", 0},
+		{"NBSP typed as space", "Non breaking\u00a0space - ok", "Non breaking space", "ok", 0},
+		{"zero width", "Log\u200bin - done", "Login", "done", 0},
+		{"wrapped title", "Login -\n    OAuth - done", "Login - OAuth", "done", 0},
+		{"wrapped notes", "Login - done\n    and tested", "Login", "done and tested", 0},
+		{"detail line", "Login\n    see PR 12", "Login", "see PR 12", 0},
+		{"unknown title", "Signup — done | ticket: A-1", "Signup", "done", 1},
+		{"unknown quoted title", `"Signup - email" - done`, "Signup - email", "done", 0},
+		{"not a prefix match", "Login page - done", "Login page", "done", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			title, notes, fields := splitEntry(tt.entry, titles...)
+			if title != tt.title || notes != tt.notes || len(fields) != tt.nFields {
+				t.Errorf("splitEntry(%q) = %q, %q, %v; want %q, %q, %d fields", tt.entry, title, notes, fields, tt.title, tt.notes, tt.nFields)
+			}
+		})
+	}
+}
+
+func TestWrappedProgressEntries(t *testing.T) {
+	progress := `# Progress Log
+
+## In Progress
+
+- 🔄 [2025-01-08 10:00] Translate the onboarding flow
+    into Japanese and Korean
+- 🔄 [2025-01-08 11:00] Other
+
+## Completed Tasks
+
+- ✅ [2025-01-08 09:00] Login - done
+  - PR 12
+`
+	long := "Translate the onboarding flow into Japanese and Korean"
+	tasksMd := "## Current Tasks\n\n### Task: " + long + "\n\n### Task: Other\n\n### Task: Login\n"
+	if got := GetCurrentTaskWithProgress(tasksMd, progress); got == nil || got.Title != long {
+		t.Fatalf("GetCurrentTaskWithProgress = %+v", got)
+	}
+	if entry := ParseProgress(progress)["Login"]; entry.Notes != "done" {
+		t.Errorf("a nested list item was read as part of the entry: %+v", entry)
+	}
+
+	moved := MoveTaskToCompleted(progress, long, "")
+	if strings.Contains(moved, "    into Japanese") {
+		t.Errorf("wrapped line left behind:\n%s", moved)
+	}
+	if !IsTaskCompleted(moved, long) || !IsTaskInProgress(moved, "Other") {
+		t.Errorf("MoveTaskToCompleted:\n%s", moved)
+	}
+}
+
+// FuzzTitleRoundTrip checks that any single-line title written to
+// progress.md reads back as the same task
+func FuzzTitleRoundTrip(f *testing.F) {
+	for _, title := range titleCorpus {
+		f.Add(title)
+	}
+	f.Fuzz(func(t *testing.T, title string) {
+		if strings.ContainsAny(title, "\n\r") || normalizeTitle(title) == "" {
+			t.Skip()
+		}
+		progress := LogTaskCompletion("", title, "notes")
+		if !IsTaskCompleted(progress, title) {
+			t.Fatalf("%q: not completed:\n%s", title, progress)
+		}
+		if notes := ParseProgressFor(progress, []string{title})[title].Notes; notes != "notes" {
+			t.Fatalf("%q: notes = %q", title, notes)
+		}
+		progress = MoveTaskToCompleted(MarkTaskInProgress("", title), title, "")
+		if IsTaskInProgress(progress, title) {
+			t.Fatalf("%q: still in progress:\n%s", title, progress)
+		}
+	})
+}
+
+// FuzzParseProgress checks that arbitrary progress.md content parses
+// without panicking
+func FuzzParseProgress(f *testing.F) {
+	f.Add("## Completed Tasks\n\n- ✅ [2025-01-08 19:00] Login - done | ticket: A-1\n")
+	f.Add("## In Progress\n\n- 🔄 [2025-01-08 19:00] \"Login - OAuth\n  wrapped\n")
+	f.Add("## Blocked\n\n- ⛔ [] **\n")
+	f.Fuzz(func(t *testing.T, progress string) {
+		ParseProgressFor(progress, []string{"Login", "Login - OAuth", ""})
+		removeProgressEntry(progress, "Login")
+	})
+}
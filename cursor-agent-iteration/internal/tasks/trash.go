@@ -0,0 +1,225 @@
+package tasks
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const trashTimeFormat = "2006-01-02 15:04"
+
+var (
+	reTrashHeader   = regexp.MustCompile(`^##\s+Trashed:\s*(\d{4}-\d{2}-\d{2} \d{2}:\d{2})(?:\s+-\s+(.*?))?\s*$`)
+	reTrashProgress = regexp.MustCompile(`^<!-- progress: (## .+?) \| (.+) -->$`)
+)
+
+const trashHeader = "# Trash\n\nDeleted tasks. Restore one with `cursor-iter trash restore --task \"<title>\"`; entries past the retention period are purged.\n"
+
+// TrashedTask is a deleted task block together with the progress.md
+// entries that referenced it
+type TrashedTask struct {
+	Title     string
+	TrashedAt time.Time
+	Reason    string
+	Progress  []ProgressLine
+	Block     string // the full "### Task:" block
+}
+
+// ProgressLine is a progress.md entry and the section it was in
+type ProgressLine struct {
+	Section string
+	Line    string
+}
+
+// ParseTrash returns the trashed tasks in the order they were deleted
+func ParseTrash(trashMd string) []TrashedTask {
+	var items []TrashedTask
+	var current *TrashedTask
+	var block []string
+	flush := func() {
+		if current != nil && len(block) > 0 {
+			current.Title = cleanTaskTitle(reTaskHeader.FindStringSubmatch(block[0])[1])
+			current.Block = strings.TrimRight(strings.Join(block, "\n"), "\n ")
+			items = append(items, *current)
+		}
+		current, block = nil, nil
+	}
+	for _, line := range strings.Split(trashMd, "\n") {
+		if m := reTrashHeader.FindStringSubmatch(line); m != nil {
+			flush()
+			at, _ := time.ParseInLocation(trashTimeFormat, m[1], time.Local)
+			current = &TrashedTask{TrashedAt: at, Reason: m[2]}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if block == nil {
+			if m := reTrashProgress.FindStringSubmatch(line); m != nil {
+				current.Progress = append(current.Progress, ProgressLine{Section: m[1], Line: m[2]})
+				continue
+			}
+			if reTaskHeader.MatchString(line) {
+				block = []string{line}
+			}
+			continue
+		}
+		block = append(block, line)
+	}
+	flush()
+	return items
+}
+
+// FormatTrash renders trashed tasks as a trash file
+func FormatTrash(items []TrashedTask) string {
+	var b strings.Builder
+	b.WriteString(trashHeader)
+	for _, t := range items {
+		fmt.Fprintf(&b, "\n## Trashed: %s", t.TrashedAt.Format(trashTimeFormat))
+		if t.Reason != "" {
+			b.WriteString(" - " + t.Reason)
+		}
+		b.WriteString("\n")
+		for _, p := range t.Progress {
+			fmt.Fprintf(&b, "<!-- progress: %s | %s -->\n", p.Section, p.Line)
+		}
+		b.WriteString("\n" + t.Block + "\n")
+	}
+	return b.String()
+}
+
+// TrashTask moves a task from tasks.md to the trash. Its in-progress,
+// blocked and completed entries are taken out of progress.md and kept with
+// it, so nothing refers to a task that no longer exists.
+func TrashTask(tasksMd string, progressMd string, trashMd string, taskTitle string, reason string, now time.Time) (newTasks string, newProgress string, newTrash string, err error) {
+	title := cleanTaskTitle(taskTitle)
+	lines := strings.Split(tasksMd, "\n")
+	blocks, _ := currentTaskBlocks(lines)
+	k := -1
+	for i, b := range blocks {
+		if b.title == title {
+			k = i
+			break
+		}
+	}
+	if k < 0 {
+		return tasksMd, progressMd, trashMd, fmt.Errorf("no task %q in Current Tasks", taskTitle)
+	}
+
+	item := TrashedTask{
+		Title:     title,
+		TrashedAt: now,
+		Reason:    strings.ReplaceAll(reason, "\n", " "),
+		Block:     strings.Join(lines[blocks[k].start:blocks[k].end], "\n"),
+	}
+	newProgress, item.Progress = takeProgressEntries(progressMd, title)
+
+	start, end := blockRange(lines, blocks, k)
+	kept := append(append([]string{}, lines[:start]...), lines[end:]...)
+	newTasks = strings.Join(kept, "\n")
+
+	items := append(removeTrashed(ParseTrash(trashMd), title), item)
+	return newTasks, newProgress, FormatTrash(items), nil
+}
+
+// RestoreTask moves a trashed task back to the end of "## Current Tasks"
+// and puts its progress.md entries back
+func RestoreTask(tasksMd string, progressMd string, trashMd string, taskTitle string) (newTasks string, newProgress string, newTrash string, err error) {
+	title := cleanTaskTitle(taskTitle)
+	items := ParseTrash(trashMd)
+	var restored *TrashedTask
+	for i := range items {
+		if items[i].Title == title {
+			restored = &items[i]
+		}
+	}
+	if restored == nil {
+		return tasksMd, progressMd, trashMd, fmt.Errorf("no trashed task %q", taskTitle)
+	}
+	for _, t := range parseTasks(tasksMd) {
+		if cleanTaskTitle(t.Title) == title {
+			return tasksMd, progressMd, trashMd, fmt.Errorf("tasks.md already has a task %q", taskTitle)
+		}
+	}
+
+	newProgress = progressMd
+	for _, p := range restored.Progress {
+		newProgress = insertProgressLine(newProgress, p.Section, p.Line)
+	}
+	newTasks = AppendTasks(tasksMd, []string{restored.Block})
+	return newTasks, newProgress, FormatTrash(removeTrashed(items, title)), nil
+}
+
+// PurgeTrash permanently removes trashed tasks: the one named taskTitle, or
+// with an empty title every task trashed before cutoff
+func PurgeTrash(trashMd string, taskTitle string, cutoff time.Time) (newTrash string, purged []string) {
+	title := cleanTaskTitle(taskTitle)
+	var kept []TrashedTask
+	for _, t := range ParseTrash(trashMd) {
+		if (title != "" && t.Title == title) || (title == "" && t.TrashedAt.Before(cutoff)) {
+			purged = append(purged, t.Title)
+			continue
+		}
+		kept = append(kept, t)
+	}
+	if len(purged) == 0 {
+		return trashMd, nil
+	}
+	return FormatTrash(kept), purged
+}
+
+func removeTrashed(items []TrashedTask, title string) []TrashedTask {
+	var kept []TrashedTask
+	for _, t := range items {
+		if t.Title != title {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// takeProgressEntries removes every progress.md entry for a task and
+// returns them with their sections
+func takeProgressEntries(progressMd string, taskTitle string) (string, []ProgressLine) {
+	var result []string
+	var taken []ProgressLine
+	section := ""
+	for _, line := range unwrapEntries(strings.Split(progressMd, "\n")) {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "## ") {
+			section = trimmed
+		}
+		if section != "" && progressLineTitle(line, taskTitle) == taskTitle {
+			taken = append(taken, ProgressLine{Section: section, Line: trimmed})
+			continue
+		}
+		result = append(result, line)
+	}
+	return strings.Join(result, "\n"), taken
+}
+
+// insertProgressLine adds an entry at the top of a progress.md section,
+// appending the section if it is missing
+func insertProgressLine(progressMd string, section string, entry string) string {
+	if strings.TrimSpace(progressMd) == "" {
+		progressMd = "# Progress Log\n\n## In Progress\n\n## Completed Tasks\n\n"
+	}
+	lines := strings.Split(progressMd, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != section {
+			continue
+		}
+		rest := lines[i+1:]
+		if len(rest) > 0 && strings.TrimSpace(rest[0]) == "" {
+			rest = rest[1:]
+		}
+		result := append([]string{}, lines[:i+1]...)
+		result = append(result, "", entry)
+		if len(rest) > 0 && strings.HasPrefix(strings.TrimSpace(rest[0]), "## ") {
+			result = append(result, "")
+		}
+		return strings.Join(append(result, rest...), "\n")
+	}
+	return strings.TrimRight(progressMd, "\n") + "\n\n" + section + "\n\n" + entry + "\n"
+}
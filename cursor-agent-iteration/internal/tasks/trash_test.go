@@ -0,0 +1,109 @@
+package tasks
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const trashTasks = `# Tasks
+
+## Current Tasks
+
+### Task: Keep
+**Acceptance Criteria:**
+* [ ] Works
+
+### Task: Remove me
+**Acceptance Criteria:**
+* [x] Done by mistake
+
+## Archive
+`
+
+const trashProgress = `# Progress Log
+
+## In Progress
+
+- 🔄 [2025-01-08 19:00] Keep
+
+## Completed Tasks
+
+- ✅ [2025-01-08 18:00] Remove me - shipped
+`
+
+func TestTrashAndRestore(t *testing.T) {
+	now := time.Date(2025, 1, 10, 9, 30, 0, 0, time.Local)
+	tasksMd, progressMd, trashMd, err := TrashTask(trashTasks, trashProgress, "", "Remove me", "duplicate", now)
+	if err != nil {
+		t.Fatalf("TrashTask() = %v", err)
+	}
+	if strings.Contains(tasksMd, "Remove me") || !strings.Contains(tasksMd, "### Task: Keep") || !strings.Contains(tasksMd, "* [ ] Works\n\n## Archive") {
+		t.Errorf("Unexpected tasks.md after trashing:\n%s", tasksMd)
+	}
+	if strings.Contains(progressMd, "Remove me") || !IsTaskInProgress(progressMd, "Keep") {
+		t.Errorf("Expected only the trashed task's progress entry removed:\n%s", progressMd)
+	}
+
+	items := ParseTrash(trashMd)
+	if len(items) != 1 {
+		t.Fatalf("ParseTrash() = %+v", items)
+	}
+	item := items[0]
+	if item.Title != "Remove me" || item.Reason != "duplicate" || !item.TrashedAt.Equal(now) || len(item.Progress) != 1 || item.Progress[0].Section != "## Completed Tasks" {
+		t.Errorf("Unexpected trashed task %+v", item)
+	}
+	if item.Block != "### Task: Remove me\n**Acceptance Criteria:**\n* [x] Done by mistake" {
+		t.Errorf("Unexpected block %q", item.Block)
+	}
+
+	if _, _, _, err := TrashTask(tasksMd, progressMd, trashMd, "Missing", "", now); err == nil {
+		t.Errorf("Expected an error for an unknown task")
+	}
+
+	tasksMd, progressMd, trashMd, err = RestoreTask(tasksMd, progressMd, trashMd, "Remove me")
+	if err != nil {
+		t.Fatalf("RestoreTask() = %v", err)
+	}
+	if len(ParseTrash(trashMd)) != 0 {
+		t.Errorf("Expected an empty trash, got:\n%s", trashMd)
+	}
+	if tasksMd != trashTasks {
+		t.Errorf("Expected tasks.md restored, got:\n%s", tasksMd)
+	}
+	if !IsTaskCompleted(progressMd, "Remove me") || !IsTaskInProgress(progressMd, "Keep") {
+		t.Errorf("Expected progress entries restored:\n%s", progressMd)
+	}
+	if _, _, _, err := RestoreTask(tasksMd, progressMd, trashMd, "Remove me"); err == nil {
+		t.Errorf("Expected an error restoring a task that isn't trashed")
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	old := time.Date(2025, 1, 1, 12, 0, 0, 0, time.Local)
+	recent := old.AddDate(0, 0, 20)
+	trashMd := FormatTrash([]TrashedTask{
+		{Title: "Old", TrashedAt: old, Block: "### Task: Old"},
+		{Title: "Recent", TrashedAt: recent, Block: "### Task: Recent"},
+	})
+
+	tests := []struct {
+		name   string
+		title  string
+		cutoff time.Time
+		purged []string
+		left   int
+	}{
+		{"past retention", "", old.AddDate(0, 0, 10), []string{"Old"}, 1},
+		{"nothing expired", "", old, nil, 2},
+		{"by title", "Recent", time.Time{}, []string{"Recent"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTrash, purged := PurgeTrash(trashMd, tt.title, tt.cutoff)
+			if strings.Join(purged, ",") != strings.Join(tt.purged, ",") || len(ParseTrash(newTrash)) != tt.left {
+				t.Errorf("PurgeTrash() purged %v leaving:\n%s", purged, newTrash)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+// Package textutil shortens text for task titles, contexts and terminal
+// lines
+package textutil
+
+import "strings"
+
+// Shorten cuts s to at most n runes at a word boundary, marking the cut
+func Shorten(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	cut := string(r[:n])
+	if i := strings.LastIndex(cut, " "); i > n/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,.;:") + "…"
+}
+
+// Truncate cuts s to at most width runes, a rune counting as a terminal
+// column, and marks the cut with "…". Nothing fits in a width below 1.
+func Truncate(s string, width int) string {
+	r := []rune(s)
+	if len(r) <= width {
+		return s
+	}
+	if width < 1 {
+		return ""
+	}
+	return strings.TrimRight(string(r[:width-1]), " ") + "…"
+}
@@ -0,0 +1,39 @@
+package textutil
+
+import "testing"
+
+func TestShorten(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"Rate limit the login endpoint, per user", 31, "Rate limit the login endpoint…"},
+		{"Refunds ran twice; see INC-42", 19, "Refunds ran twice…"},
+		{"abcdefghijklmnopqrstuvwxyz", 10, "abcdefghij…"},
+		{"déjà vu déjà vu", 9, "déjà vu…"},
+	} {
+		if got := Shorten(tt.in, tt.n); got != tt.want {
+			t.Errorf("Shorten(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	for _, tt := range []struct {
+		in    string
+		width int
+		want  string
+	}{
+		{"7/30 done", 20, "7/30 done"},
+		{"7/30 done", 9, "7/30 done"},
+		{"next: OAuth refresh", 12, "next: OAuth…"},
+		{"déjà vu", 5, "déjà…"},
+		{"anything", 0, ""},
+	} {
+		if got := Truncate(tt.in, tt.width); got != tt.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tt.in, tt.width, got, tt.want)
+		}
+	}
+}
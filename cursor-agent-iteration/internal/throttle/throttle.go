@@ -0,0 +1,115 @@
+// Package throttle lowers how many agents a loop starts at once while their
+// startups keep racing on cursor-agent's cli-config.json, and raises it back
+// once they stop. Like TCP congestion control it backs off fast and recovers
+// slowly: a spike of races halves the limit and doubles the stagger between
+// starts, and every quiet Cooldown gives one agent back and halves the
+// stagger again, until the configured values are reached.
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// Defaults
+const (
+	// Threshold race retries within Window make a spike
+	Threshold = 3
+	Window    = 2 * time.Minute
+	// Cooldown is how long the loop must go without a spike before each
+	// step back up
+	Cooldown = 2 * time.Minute
+	// MinStagger is the stagger a spike starts from when none is configured
+	MinStagger = 2 * time.Second
+	// MaxStagger caps the stagger however many spikes there are
+	MaxStagger = 30 * time.Second
+)
+
+// Throttle tracks the race retries of a loop and the limits they leave it.
+// It is safe for concurrent use, since retries are reported from the
+// goroutines running agents.
+type Throttle struct {
+	max     int
+	stagger time.Duration
+
+	mu    sync.Mutex
+	races []time.Time
+	// limit and delay are the current limits, lowered by spikes
+	limit int
+	delay time.Duration
+	// changed is when the limits last moved
+	changed time.Time
+}
+
+// New returns a throttle for a loop running up to max agents with stagger
+// between their starts
+func New(max int, stagger time.Duration) *Throttle {
+	if max < 1 {
+		max = 1
+	}
+	return &Throttle{max: max, stagger: stagger, limit: max, delay: stagger}
+}
+
+// Race records a race retry at now and reports whether it made a spike that
+// lowered the limits
+func (t *Throttle) Race(now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	recent := t.races[:0]
+	for _, at := range t.races {
+		if now.Sub(at) < Window {
+			recent = append(recent, at)
+		}
+	}
+	t.races = append(recent, now)
+	if len(t.races) < Threshold {
+		return false
+	}
+	t.races = nil
+	t.limit = max(1, t.limit/2)
+	t.delay = min(max(2*t.delay, MinStagger), MaxStagger)
+	t.changed = now
+	return true
+}
+
+// Recover raises the limits one step when they are lowered and there was
+// no spike for Cooldown, reporting whether it did
+func (t *Throttle) Recover(now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.throttled() || now.Sub(t.changed) < Cooldown {
+		return false
+	}
+	t.limit = min(t.limit+1, t.max)
+	t.delay = max(t.delay/2, t.stagger)
+	if t.delay < MinStagger {
+		t.delay = t.stagger
+	}
+	t.changed = now
+	return true
+}
+
+// Limit is how many agents may run at once
+func (t *Throttle) Limit() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.limit
+}
+
+// Stagger is the delay between agent starts
+func (t *Throttle) Stagger() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.delay
+}
+
+// Throttled reports whether the limits are below the configured ones
+func (t *Throttle) Throttled() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.throttled()
+}
+
+func (t *Throttle) throttled() bool {
+	return t.limit < t.max || t.delay > t.stagger
+}
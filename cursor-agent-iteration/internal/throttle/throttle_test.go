@@ -0,0 +1,58 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThrottle(t *testing.T) {
+	now := time.Date(2025, 1, 8, 12, 0, 0, 0, time.UTC)
+	th := New(8, 0)
+
+	// Races spread out over more than Window don't make a spike
+	for i := 0; i < 4; i++ {
+		if th.Race(now.Add(time.Duration(i) * Window / 2)) {
+			t.Fatalf("Expected race %d to be too far from the others for a spike", i)
+		}
+	}
+	if th.Throttled() {
+		t.Fatal("Expected no throttling without a spike")
+	}
+
+	now = now.Add(time.Hour)
+	th.Race(now)
+	th.Race(now.Add(time.Second))
+	if !th.Race(now.Add(2*time.Second)) || th.Limit() != 4 || th.Stagger() != MinStagger {
+		t.Fatalf("Expected a spike to halve the limit to 4 and stagger by %v, got %d and %v", MinStagger, th.Limit(), th.Stagger())
+	}
+	now = now.Add(10 * time.Second)
+	for i := 0; i < Threshold; i++ {
+		th.Race(now)
+	}
+	if th.Limit() != 2 || th.Stagger() != 2*MinStagger {
+		t.Fatalf("Expected a second spike to lower the limits to 2 and %v, got %d and %v", 2*MinStagger, th.Limit(), th.Stagger())
+	}
+
+	if th.Recover(now.Add(Cooldown - time.Second)) {
+		t.Error("Expected no recovery before the cooldown")
+	}
+	now = now.Add(Cooldown)
+	if !th.Recover(now) || th.Limit() != 3 || th.Stagger() != MinStagger {
+		t.Fatalf("Expected one step back up to 3 and %v, got %d and %v", MinStagger, th.Limit(), th.Stagger())
+	}
+	for th.Recover(now.Add(Cooldown)) {
+		now = now.Add(Cooldown)
+	}
+	if th.Throttled() || th.Limit() != 8 || th.Stagger() != 0 {
+		t.Errorf("Expected the configured limits back, got %d and %v", th.Limit(), th.Stagger())
+	}
+
+	// The limit never drops below one agent, and the stagger is capped
+	one := New(1, 20*time.Second)
+	for i := 0; i < 2*Threshold; i++ {
+		one.Race(now)
+	}
+	if one.Limit() != 1 || one.Stagger() != MaxStagger {
+		t.Errorf("Expected 1 agent and %v, got %d and %v", MaxStagger, one.Limit(), one.Stagger())
+	}
+}
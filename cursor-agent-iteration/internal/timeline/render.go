@@ -0,0 +1,196 @@
+package timeline
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+)
+
+// mermaidTime is the dateFormat of the Mermaid chart
+const mermaidTime = "2006-01-02T15:04:05"
+
+// Mermaid renders the timeline as a Mermaid Gantt chart with a section per
+// task. Each run is a bar tagged by outcome, followed by its verification.
+func (t Timeline) Mermaid() string {
+	var b strings.Builder
+	b.WriteString("gantt\n")
+	fmt.Fprintf(&b, "    title %s\n", mermaidText("Run "+t.RunID))
+	b.WriteString("    dateFormat YYYY-MM-DDTHH:mm:ss\n")
+	b.WriteString("    axisFormat %H:%M\n")
+	for _, task := range t.tasks() {
+		fmt.Fprintf(&b, "    section %s\n", mermaidText(task))
+		for i, bar := range t.Bars {
+			if bar.Task != task {
+				continue
+			}
+			tags := ""
+			switch bar.Outcome {
+			case journal.OutcomeCompleted:
+				tags = "done, "
+			case journal.OutcomeFailed:
+				tags = "crit, "
+			}
+			fmt.Fprintf(&b, "    %s :%sr%d, %s, %s\n", mermaidText(bar.label()), tags, i, bar.Start.Format(mermaidTime), bar.AgentEnd.Format(mermaidTime))
+			if bar.Verify() >= time.Second {
+				fmt.Fprintf(&b, "    verify :r%dv, %s, %s\n", i, bar.AgentEnd.Format(mermaidTime), bar.End.Format(mermaidTime))
+			}
+		}
+	}
+	return b.String()
+}
+
+// tasks returns the tasks of the timeline in the order they first started
+func (t Timeline) tasks() []string {
+	var tasks []string
+	seen := make(map[string]bool)
+	for _, bar := range t.Bars {
+		if !seen[bar.Task] {
+			seen[bar.Task] = true
+			tasks = append(tasks, bar.Task)
+		}
+	}
+	return tasks
+}
+
+// label names a bar by its attempt, backend and outcome
+func (b Bar) label() string {
+	label := b.Backend
+	if b.Attempt > 1 {
+		label = fmt.Sprintf("retry %d %s", b.Attempt-1, b.Backend)
+	}
+	if b.Outcome != journal.OutcomeCompleted && b.Outcome != "" {
+		label += " " + b.Outcome
+		if b.Classification != "" {
+			label += " (" + b.Classification + ")"
+		}
+	}
+	return label
+}
+
+// mermaidText drops the characters that end a Mermaid task name or title
+var mermaidText = strings.NewReplacer(":", " ", ";", ",", "#", "", "\n", " ").Replace
+
+// htmlRow is one bar of the HTML chart, positioned in percent of the run
+type htmlRow struct {
+	Task    string
+	Label   string
+	Outcome string
+	Left    float64
+	Width   float64
+	Verify  float64
+	Tooltip string
+	First   bool
+}
+
+// htmlTick is a mark on the time axis
+type htmlTick struct {
+	Left  float64
+	Label string
+}
+
+var htmlPage = template.Must(template.New("timeline").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Timeline of run {{.RunID}}</title>
+<style>
+body { font: 13px -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; margin: 24px; color: #1f2328; }
+h1 { font-size: 18px; margin: 0 0 4px; }
+.summary { color: #59636e; margin-bottom: 16px; }
+.chart { display: grid; grid-template-columns: 280px 1fr; row-gap: 4px; }
+.task { overflow: hidden; text-overflow: ellipsis; white-space: nowrap; padding-right: 8px; line-height: 20px; }
+.task.retry { color: #59636e; padding-left: 16px; }
+.track { position: relative; height: 20px; background: #f6f8fa; }
+.bar { position: absolute; top: 2px; height: 16px; border-radius: 3px; display: flex; overflow: hidden; }
+.bar .agent { flex: 1; }
+.bar .verify { background: repeating-linear-gradient(45deg, #bbb, #bbb 3px, #ddd 3px, #ddd 6px); }
+.completed .agent { background: #2da44e; }
+.incomplete .agent { background: #d4a72c; }
+.failed .agent { background: #cf222e; }
+.axis { position: relative; height: 18px; color: #59636e; }
+.axis span { position: absolute; transform: translateX(-50%); }
+.legend span { display: inline-block; width: 12px; height: 12px; margin: 0 4px 0 12px; vertical-align: -2px; border-radius: 2px; }
+</style>
+</head>
+<body>
+<h1>Run {{.RunID}}</h1>
+<div class="summary">{{.Summary}}</div>
+<div class="chart">
+<div></div><div class="axis">{{range .Ticks}}<span style="left: {{printf "%.2f" .Left}}%">{{.Label}}</span>{{end}}</div>
+{{range .Rows}}<div class="task{{if not .First}} retry{{end}}" title="{{.Task}}">{{if .First}}{{.Task}}{{else}}↳ {{.Label}}{{end}}</div>
+<div class="track"><div class="bar {{.Outcome}}" style="left: {{printf "%.2f" .Left}}%; width: {{printf "%.2f" .Width}}%" title="{{.Tooltip}}"><div class="agent"></div>{{if .Verify}}<div class="verify" style="width: {{printf "%.2f" .Verify}}%"></div>{{end}}</div></div>
+{{end}}</div>
+<p class="legend"><span style="background: #2da44e"></span>completed<span style="background: #d4a72c"></span>incomplete<span style="background: #cf222e"></span>failed<span class="verify" style="background: repeating-linear-gradient(45deg, #bbb, #bbb 3px, #ddd 3px, #ddd 6px)"></span>verification</p>
+</body>
+</html>
+`))
+
+// HTML renders the timeline as a self-contained HTML page with a row per
+// run, grouped by task. Hovering a bar shows its timing and the tasks that
+// ran in parallel.
+func (t Timeline) HTML(w io.Writer) error {
+	total := t.Duration()
+	if total <= 0 {
+		total = time.Second
+	}
+	pct := func(d time.Duration) float64 {
+		return float64(d) * 100 / float64(total)
+	}
+
+	var rows []htmlRow
+	for _, task := range t.tasks() {
+		first := true
+		for _, bar := range t.Bars {
+			if bar.Task != task {
+				continue
+			}
+			row := htmlRow{
+				Task:    bar.Task,
+				Label:   bar.label(),
+				Outcome: bar.Outcome,
+				Left:    pct(bar.Start.Sub(t.Start)),
+				Width:   pct(bar.End.Sub(bar.Start)),
+				First:   first,
+			}
+			if run := bar.End.Sub(bar.Start); run > 0 {
+				row.Verify = float64(bar.Verify()) * 100 / float64(run)
+			}
+			tooltip := fmt.Sprintf("%s\n%s %s\n%s - %s\nagent %s, verification %s",
+				bar.Task, bar.label(), bar.Model, bar.Start.Format("15:04:05"), bar.End.Format("15:04:05"),
+				bar.Agent().Round(time.Second), bar.Verify().Round(time.Second))
+			if len(bar.Parallel) > 0 {
+				tooltip += "\nin parallel with: " + strings.Join(bar.Parallel, ", ")
+			}
+			row.Tooltip = tooltip
+			rows = append(rows, row)
+			first = false
+		}
+	}
+
+	var ticks []htmlTick
+	step := tickStep(total)
+	for at := t.Start.Truncate(step); !at.After(t.End); at = at.Add(step) {
+		if at.Before(t.Start) {
+			continue
+		}
+		ticks = append(ticks, htmlTick{Left: pct(at.Sub(t.Start)), Label: at.Format("15:04")})
+	}
+
+	summary := fmt.Sprintf("%d runs of %d tasks from %s to %s (%s), at most %d at once",
+		len(t.Bars), t.Tasks(), t.Start.Format("2006-01-02 15:04"), t.End.Format("15:04"), total.Round(time.Second), t.Lanes)
+	return htmlPage.Execute(w, map[string]any{"RunID": t.RunID, "Summary": summary, "Rows": rows, "Ticks": ticks})
+}
+
+// tickStep picks a time axis step that gives roughly ten marks
+func tickStep(total time.Duration) time.Duration {
+	for _, step := range []time.Duration{time.Minute, 5 * time.Minute, 10 * time.Minute, 15 * time.Minute, 30 * time.Minute, time.Hour, 2 * time.Hour, 6 * time.Hour} {
+		if total/step <= 12 {
+			return step
+		}
+	}
+	return 24 * time.Hour
+}
@@ -0,0 +1,168 @@
+// Package timeline lays out the task runs of one iterate or iterate-loop
+// run over time, from the run journal, and renders them as Gantt charts:
+// which tasks ran when, which ran in parallel, their retries, and the
+// verification after each agent exits.
+package timeline
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+)
+
+// Bar is one agent run of a task
+type Bar struct {
+	Task    string
+	Attempt int // 1 for the first run of the task in the run, 2 for its first retry...
+	Backend string
+	Model   string
+	Outcome string
+	// Classification is why a failed run failed
+	Classification string
+	Start          time.Time
+	// AgentEnd is when the agent exited; the run was verified until End
+	AgentEnd time.Time
+	End      time.Time
+	// Lane is the row of the bar when runs are packed so that only runs
+	// that overlap in time need separate rows
+	Lane int
+	// Parallel lists the other tasks that ran at the same time
+	Parallel []string
+}
+
+// Agent is how long the agent ran
+func (b Bar) Agent() time.Duration {
+	return b.AgentEnd.Sub(b.Start)
+}
+
+// Verify is how long the run was checked after the agent exited
+func (b Bar) Verify() time.Duration {
+	return b.End.Sub(b.AgentEnd)
+}
+
+// Timeline is the layout of one run
+type Timeline struct {
+	RunID string
+	Start time.Time
+	End   time.Time
+	Bars  []Bar // ordered by start
+	// Lanes is the most runs that were in flight at once
+	Lanes int
+}
+
+// Duration is the wall-clock time from the first start to the last end
+func (t Timeline) Duration() time.Duration {
+	return t.End.Sub(t.Start)
+}
+
+// Tasks counts the distinct tasks of the timeline
+func (t Timeline) Tasks() int {
+	return len(t.tasks())
+}
+
+// Runs returns the run IDs in the journal, oldest first
+func Runs(entries []journal.Entry) []string {
+	var runs []string
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		if e.RunID != "" && !seen[e.RunID] {
+			seen[e.RunID] = true
+			runs = append(runs, e.RunID)
+		}
+	}
+	return runs
+}
+
+// Resolve picks a run: "latest", a run ID or a unique prefix of one
+func Resolve(runs []string, spec string) (string, error) {
+	if len(runs) == 0 {
+		return "", fmt.Errorf("no runs recorded yet")
+	}
+	if spec == "" || spec == "latest" {
+		return runs[len(runs)-1], nil
+	}
+	var matches []string
+	for _, id := range runs {
+		if id == spec {
+			return id, nil
+		}
+		if strings.HasPrefix(id, spec) {
+			matches = append(matches, id)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no run %q (see --list)", spec)
+	case 1:
+		return matches[0], nil
+	}
+	return "", fmt.Errorf("run %q is ambiguous: %s", spec, strings.Join(matches, ", "))
+}
+
+// Build lays out the entries of a run. Entries from journals older than
+// the start time are placed by their duration and have no verification.
+func Build(entries []journal.Entry, runID string) Timeline {
+	t := Timeline{RunID: runID}
+	attempts := make(map[string]int)
+	for _, e := range entries {
+		if e.RunID != runID {
+			continue
+		}
+		attempts[e.Task]++
+		b := Bar{
+			Task:           e.Task,
+			Attempt:        attempts[e.Task],
+			Backend:        e.Backend,
+			Model:          e.Model,
+			Outcome:        e.Outcome,
+			Classification: e.Classification,
+			Start:          e.Started,
+			End:            e.Time,
+		}
+		if b.Start.IsZero() {
+			b.Start = e.Time.Add(-e.Duration())
+		}
+		b.AgentEnd = b.Start.Add(e.Duration())
+		if b.AgentEnd.After(b.End) {
+			b.AgentEnd = b.End
+		}
+		t.Bars = append(t.Bars, b)
+	}
+	if len(t.Bars) == 0 {
+		return t
+	}
+	sort.SliceStable(t.Bars, func(i, j int) bool { return t.Bars[i].Start.Before(t.Bars[j].Start) })
+
+	t.Start, t.End = t.Bars[0].Start, t.Bars[0].End
+	var laneEnds []time.Time
+	for i := range t.Bars {
+		b := &t.Bars[i]
+		if b.End.After(t.End) {
+			t.End = b.End
+		}
+		b.Lane = -1
+		for lane, end := range laneEnds {
+			if !end.After(b.Start) {
+				b.Lane = lane
+				laneEnds[lane] = b.End
+				break
+			}
+		}
+		if b.Lane < 0 {
+			b.Lane = len(laneEnds)
+			laneEnds = append(laneEnds, b.End)
+		}
+		for j := range t.Bars {
+			o := t.Bars[j]
+			if j != i && o.Task != b.Task && o.Start.Before(b.End) && b.Start.Before(o.End) && !slices.Contains(b.Parallel, o.Task) {
+				b.Parallel = append(b.Parallel, o.Task)
+			}
+		}
+	}
+	t.Lanes = len(laneEnds)
+	return t
+}
@@ -0,0 +1,155 @@
+package timeline
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+)
+
+var t0 = time.Date(2025, 1, 8, 19, 0, 0, 0, time.UTC)
+
+// run is a journal entry for a run that started at start minutes, ran for
+// agent minutes and was recorded verify seconds after the agent exited
+func run(runID, task, outcome string, start, agent, verify int) journal.Entry {
+	started := t0.Add(time.Duration(start) * time.Minute)
+	duration := time.Duration(agent) * time.Minute
+	return journal.Entry{
+		RunID:      runID,
+		Task:       task,
+		Backend:    "cursor-agent",
+		Outcome:    outcome,
+		Started:    started,
+		DurationMs: duration.Milliseconds(),
+		Time:       started.Add(duration + time.Duration(verify)*time.Second),
+	}
+}
+
+func sampleEntries() []journal.Entry {
+	return []journal.Entry{
+		run("r1", "Old", journal.OutcomeCompleted, 0, 5, 0),
+		run("r2", "Add login", journal.OutcomeFailed, 0, 10, 5),
+		run("r2", "Docs", journal.OutcomeCompleted, 2, 4, 30),
+		run("r2", "Add login", journal.OutcomeCompleted, 11, 9, 10),
+		run("r2", "Cache", journal.OutcomeIncomplete, 7, 6, 0),
+	}
+}
+
+func TestBuild(t *testing.T) {
+	tl := Build(sampleEntries(), "r2")
+	if len(tl.Bars) != 4 || tl.Tasks() != 3 {
+		t.Fatalf("Expected 4 runs of 3 tasks, got %+v", tl.Bars)
+	}
+	if !tl.Start.Equal(t0) || !tl.End.Equal(t0.Add(20*time.Minute+10*time.Second)) {
+		t.Errorf("Start = %v, End = %v", tl.Start, tl.End)
+	}
+	if tl.Lanes != 2 {
+		t.Errorf("Lanes = %d, want 2", tl.Lanes)
+	}
+
+	var order []string
+	for _, b := range tl.Bars {
+		order = append(order, b.Task)
+	}
+	if !reflect.DeepEqual(order, []string{"Add login", "Docs", "Cache", "Add login"}) {
+		t.Errorf("Bars ordered %v, want by start", order)
+	}
+	first, docs, cache, retry := tl.Bars[0], tl.Bars[1], tl.Bars[2], tl.Bars[3]
+	if first.Attempt != 1 || retry.Attempt != 2 {
+		t.Errorf("Attempts = %d, %d", first.Attempt, retry.Attempt)
+	}
+	if docs.Agent() != 4*time.Minute || docs.Verify() != 30*time.Second {
+		t.Errorf("Docs agent %v, verify %v", docs.Agent(), docs.Verify())
+	}
+	if first.Lane != 0 || docs.Lane != 1 || cache.Lane != 1 || retry.Lane != 0 {
+		t.Errorf("Lanes = %d %d %d %d", first.Lane, docs.Lane, cache.Lane, retry.Lane)
+	}
+	if !reflect.DeepEqual(first.Parallel, []string{"Docs", "Cache"}) || !reflect.DeepEqual(retry.Parallel, []string{"Cache"}) || docs.Parallel[0] != "Add login" {
+		t.Errorf("Parallel = %v, %v, %v", first.Parallel, retry.Parallel, docs.Parallel)
+	}
+
+	// Entries written before start times were journaled end when recorded
+	old := run("r3", "Old", journal.OutcomeCompleted, 0, 5, 0)
+	old.Started = time.Time{}
+	if tl := Build([]journal.Entry{old}, "r3"); !tl.Bars[0].Start.Equal(t0) || tl.Bars[0].Verify() != 0 {
+		t.Errorf("Old entry bar = %+v", tl.Bars[0])
+	}
+	if tl := Build(sampleEntries(), "missing"); len(tl.Bars) != 0 || tl.Duration() != 0 {
+		t.Errorf("Expected an empty timeline, got %+v", tl)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	runs := Runs(sampleEntries())
+	if !reflect.DeepEqual(runs, []string{"r1", "r2"}) {
+		t.Fatalf("Runs() = %v", runs)
+	}
+	tests := []struct {
+		spec     string
+		expected string
+		err      string
+	}{
+		{"latest", "r2", ""},
+		{"", "r2", ""},
+		{"r1", "r1", ""},
+		{"r", "", "ambiguous"},
+		{"x", "", "no run"},
+	}
+	for _, tt := range tests {
+		got, err := Resolve(runs, tt.spec)
+		if got != tt.expected || (tt.err == "") != (err == nil) || (err != nil && !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("Resolve(%q) = %q, %v", tt.spec, got, err)
+		}
+	}
+	if _, err := Resolve(nil, "latest"); err == nil {
+		t.Error("Expected an error without runs")
+	}
+}
+
+func TestMermaid(t *testing.T) {
+	entries := sampleEntries()
+	entries[2].Task = "Docs: fix #12; links"
+	got := Build(entries, "r2").Mermaid()
+	for _, want := range []string{
+		"gantt\n    title Run r2\n    dateFormat YYYY-MM-DDTHH:mm:ss\n",
+		"    section Add login\n    cursor-agent failed :crit, r0, 2025-01-08T19:00:00, 2025-01-08T19:10:00\n    verify :r0v, 2025-01-08T19:10:00, 2025-01-08T19:10:05\n",
+		"    retry 1 cursor-agent :done, r3, 2025-01-08T19:11:00, 2025-01-08T19:20:00\n",
+		"    section Docs  fix 12, links\n",
+		"    cursor-agent incomplete :r2, 2025-01-08T19:07:00, 2025-01-08T19:13:00\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected Mermaid chart to contain %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "r2v") {
+		t.Errorf("Expected no verification bar for a run recorded right away:\n%s", got)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	entries := sampleEntries()
+	entries[4].Task = "Cache <script>"
+	var b strings.Builder
+	if err := Build(entries, "r2").HTML(&b); err != nil {
+		t.Fatal(err)
+	}
+	page := b.String()
+	for _, want := range []string{
+		"<title>Timeline of run r2</title>",
+		"4 runs of 3 tasks from 2025-01-08 19:00 to 19:20 (20m10s), at most 2 at once",
+		`class="bar failed" style="left: 0.00%; width: 50.00%"`,
+		"in parallel with: Docs, Cache &lt;script&gt;",
+		"↳ retry 1 cursor-agent",
+		"Cache &lt;script&gt;",
+		`<span style="left: 24.79%">19:05</span>`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("Expected HTML to contain %q", want)
+		}
+	}
+	if strings.Contains(page, "<script>") {
+		t.Error("Expected task titles to be escaped")
+	}
+}
@@ -0,0 +1,178 @@
+// Package tips picks a usage tip to show after a command: rules look at
+// the state of the repository's tasks and run journal, and the first one
+// that applies names the command that helps, e.g. triage for blocked tasks.
+// Tips are computed from local files only and nothing is sent anywhere. A
+// tip isn't shown again within Cooldown, so they take turns.
+package tips
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// StaleAfter is how long a task may be in progress before it counts as
+// stale
+const StaleAfter = 24 * time.Hour
+
+// Cooldown is how long a tip isn't shown again after it was
+const Cooldown = 24 * time.Hour
+
+// State is what the rules look at
+type State struct {
+	Now time.Time
+	// Command is the command that just ran
+	Command string
+	Totals  tasks.StatusTotals
+	// Stale are the tasks in progress for longer than StaleAfter
+	Stale []string
+	// WithoutFiles counts the pending tasks without a Files to Modify line
+	WithoutFiles int
+	// Runs counts the agent runs in the journal, and Refusals and Timeouts
+	// those classified as such
+	Runs     int
+	Refusals int
+	Timeouts int
+	// HasConfig is set when the repository has a config file
+	HasConfig bool
+	// ControlTokens estimates the tokens of the control files
+	ControlTokens int
+}
+
+// Build returns the state of tasks.md, progress.md and the journal at now.
+// The caller fills in the rest.
+func Build(tasksMd, progressMd string, entries []journal.Entry, now time.Time) State {
+	summary := tasks.Summarize(tasksMd, progressMd)
+	s := State{Now: now, Totals: summary.Totals}
+	pending := make(map[string]bool)
+	for _, t := range summary.Tasks {
+		switch t.Status {
+		case "in-progress":
+			if t.StartedAt != nil && now.Sub(*t.StartedAt) > StaleAfter {
+				s.Stale = append(s.Stale, t.Title)
+			}
+		case "pending":
+			pending[t.Title] = true
+		}
+	}
+	for _, t := range tasks.ParseTasks(tasksMd) {
+		if pending[t.Title] && len(t.FileScope) == 0 {
+			s.WithoutFiles++
+		}
+	}
+	for _, e := range entries {
+		s.Runs++
+		switch e.Classification {
+		case journal.ClassRefusal:
+			s.Refusals++
+		case journal.ClassTimeout:
+			s.Timeouts++
+		}
+	}
+	return s
+}
+
+// Rule is a tip and when it applies
+type Rule struct {
+	ID string
+	// Suggests is the command the tip points to; it isn't shown right after
+	// that command
+	Suggests string
+	// Tip returns the tip for the state, or "" when it doesn't apply
+	Tip func(State) string
+}
+
+// Rules are the tips, most pressing first
+var Rules = []Rule{
+	{ID: "stale", Suggests: "timeline", Tip: func(s State) string {
+		if len(s.Stale) == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%d task(s) have been in progress for over %s, e.g. '%s'; 'cursor-iter timeline' shows how their runs went", len(s.Stale), hours(StaleAfter), s.Stale[0])
+	}},
+	{ID: "failed", Suggests: "triage", Tip: func(s State) string {
+		if s.Totals.Failed == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%d task(s) ran out of attempts; 'cursor-iter triage --list' shows why, and triage retries or drops them", s.Totals.Failed)
+	}},
+	{ID: "blocked", Suggests: "triage", Tip: func(s State) string {
+		if s.Totals.Blocked == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%d task(s) are blocked; unblock them with 'cursor-iter triage'", s.Totals.Blocked)
+	}},
+	{ID: "empty", Suggests: "add-feature", Tip: func(s State) string {
+		if s.Totals.Total > 0 {
+			return ""
+		}
+		return "No tasks yet; describe a feature with 'cursor-iter add-feature --prompt \"...\"' to plan some"
+	}},
+	{ID: "refusals", Suggests: "model-stats", Tip: func(s State) string {
+		if s.Refusals < 3 {
+			return ""
+		}
+		return fmt.Sprintf("Models declined tasks %d times; 'cursor-iter model-stats' compares how each model does", s.Refusals)
+	}},
+	{ID: "timeouts", Suggests: "timeline", Tip: func(s State) string {
+		if s.Timeouts < 3 {
+			return ""
+		}
+		return fmt.Sprintf("%d runs timed out; 'cursor-iter timeline' shows how long runs take, then raise --task-timeout or split the tasks", s.Timeouts)
+	}},
+	{ID: "archive", Suggests: "archive-completed", Tip: func(s State) string {
+		if s.Totals.Completed < 20 {
+			return ""
+		}
+		return fmt.Sprintf("%d completed tasks are still in tasks.md; 'cursor-iter archive-completed' moves them out and keeps prompts small", s.Totals.Completed)
+	}},
+	{ID: "context", Suggests: "compress-context", Tip: func(s State) string {
+		if s.ControlTokens < 50_000 {
+			return ""
+		}
+		return fmt.Sprintf("The control files hold about %dk tokens; 'cursor-iter compress-context' condenses them for agents", s.ControlTokens/1000)
+	}},
+	{ID: "files", Tip: func(s State) string {
+		if s.WithoutFiles == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%d pending task(s) have no **Files to Modify:** line, so --file-claims can't keep parallel agents off each other's files", s.WithoutFiles)
+	}},
+	{ID: "config", Tip: func(s State) string {
+		if s.HasConfig || s.Runs == 0 {
+			return ""
+		}
+		return "Flags you pass every time can go in .cursor-iter.yaml, e.g. 'model: gpt-5'; see Configuration in 'cursor-iter --help'"
+	}},
+	{ID: "agents", Suggests: "agents", Tip: func(s State) string {
+		if s.Runs < 20 {
+			return ""
+		}
+		return fmt.Sprintf("'cursor-iter agents list' shows the success rate of each model over your %d runs", s.Runs)
+	}},
+}
+
+// hours renders a duration of whole hours, e.g. "24h"
+func hours(d time.Duration) string {
+	return fmt.Sprintf("%dh", int(d.Hours()))
+}
+
+// Pick returns the first rule that applies to the state and its tip,
+// skipping rules shown within Cooldown according to shown and those that
+// suggest the command that just ran. ok is false when no tip applies.
+func Pick(rules []Rule, s State, shown map[string]time.Time) (rule Rule, tip string, ok bool) {
+	for _, r := range rules {
+		if r.Suggests != "" && r.Suggests == s.Command {
+			continue
+		}
+		if last, seen := shown[r.ID]; seen && s.Now.Sub(last) < Cooldown {
+			continue
+		}
+		if tip := r.Tip(s); tip != "" {
+			return r, tip, true
+		}
+	}
+	return Rule{}, "", false
+}
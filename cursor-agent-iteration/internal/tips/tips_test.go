@@ -0,0 +1,70 @@
+package tips
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/journal"
+)
+
+const tasksMd = `## Current Tasks
+
+### Task: Login
+
+**Files to Modify:** login.go
+
+### Task: Logout
+
+### Task: Signup
+`
+
+func TestBuild(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.Local)
+	progressMd := "# Progress Log\n\n## In Progress\n\n- 🔄 [2025-01-08 15:30] Login\n\n## Blocked\n\n- ⛔ [2025-01-09 10:00] Signup - needs an API key\n"
+	entries := []journal.Entry{
+		{Task: "Login", Classification: journal.ClassRefusal},
+		{Task: "Login", Classification: journal.ClassTimeout},
+		{Task: "Login"},
+	}
+	s := Build(tasksMd, progressMd, entries, now)
+	if strings.Join(s.Stale, ",") != "Login" {
+		t.Errorf("Stale = %v, want [Login]", s.Stale)
+	}
+	if s.Totals.Blocked != 1 || s.WithoutFiles != 1 {
+		t.Errorf("Blocked = %d, WithoutFiles = %d; want 1 and 1", s.Totals.Blocked, s.WithoutFiles)
+	}
+	if s.Runs != 3 || s.Refusals != 1 || s.Timeouts != 1 {
+		t.Errorf("Runs, Refusals, Timeouts = %d, %d, %d", s.Runs, s.Refusals, s.Timeouts)
+	}
+}
+
+func TestPick(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.Local)
+	s := State{Now: now, Command: "task-status", Stale: []string{"Login"}}
+	s.Totals.Total, s.Totals.Blocked = 3, 1
+
+	r, tip, ok := Pick(Rules, s, nil)
+	if !ok || r.ID != "stale" || !strings.Contains(tip, "'Login'") || !strings.Contains(tip, "cursor-iter timeline") {
+		t.Fatalf("Pick() = %s, %q, %v; want the stale tip", r.ID, tip, ok)
+	}
+	// Once shown, the next tip gets its turn until the cooldown is over
+	shown := map[string]time.Time{"stale": now.Add(-time.Hour)}
+	if r, _, _ := Pick(Rules, s, shown); r.ID != "blocked" {
+		t.Errorf("Pick() after the stale tip = %s, want blocked", r.ID)
+	}
+	shown["stale"] = now.Add(-Cooldown - time.Minute)
+	if r, _, _ := Pick(Rules, s, shown); r.ID != "stale" {
+		t.Errorf("Pick() after the cooldown = %s, want stale", r.ID)
+	}
+	// A tip isn't shown right after the command it suggests
+	s.Command = "timeline"
+	if r, _, _ := Pick(Rules, s, nil); r.ID != "blocked" {
+		t.Errorf("Pick() after timeline = %s, want blocked", r.ID)
+	}
+	quiet := State{Now: now, HasConfig: true}
+	quiet.Totals.Total = 3
+	if _, _, ok := Pick(Rules, quiet, nil); ok {
+		t.Error("Expected no tip when nothing applies")
+	}
+}
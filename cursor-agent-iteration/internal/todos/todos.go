@@ -0,0 +1,256 @@
+// Package todos turns specially tagged TODO comments in source files into
+// tasks, and resolves the comments once their tasks are done
+package todos
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+// DefaultMarker tags the TODO comments that become tasks
+const DefaultMarker = "TODO(autopilot):"
+
+// What happens to a comment when its task completes
+const (
+	Annotate = "annotate" // the TODO becomes a DONE
+	Remove   = "remove"   // the comment is deleted
+)
+
+// ContextLines is how many lines around a TODO are quoted in its task
+const ContextLines = 3
+
+// maxFileSize skips generated and data files
+const maxFileSize = 1 << 20
+
+// maxTitleLen keeps titles derived from long comments readable
+const maxTitleLen = 80
+
+// skipDirs are never scanned
+var skipDirs = map[string]bool{
+	".git": true, ".cursor-iter": true, "node_modules": true, "vendor": true,
+	"dist": true, "build": true, "target": true, ".venv": true, "__pycache__": true,
+}
+
+// commentEnds are stripped from the end of a TODO's text
+var commentEnds = []string{"*/", "-->", "#}", "%>"}
+
+// commentStarts precede a TODO on its line, longest first
+var commentStarts = []string{"<!--", "<%#", "{#", "/*", "//", "--", "#", ";", "*"}
+
+// Todo is one tagged comment
+type Todo struct {
+	File    string // slash-separated, relative to the scanned directory
+	Line    int
+	Comment string // the marker and the text after it
+	Text    string // the text after the marker
+	// Context holds the lines around the comment, starting at line ContextLine
+	Context     string
+	ContextLine int
+}
+
+// Scan finds the comments tagged with marker in the text files under root
+func Scan(root, marker string) ([]Todo, error) {
+	var found []Todo
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && (skipDirs[d.Name()] || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info, err := d.Info(); err != nil || !info.Mode().IsRegular() || info.Size() > maxFileSize {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil || !bytes.Contains(content, []byte(marker)) || bytes.IndexByte(content, 0) >= 0 {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		found = append(found, ScanContent(filepath.ToSlash(rel), string(content), marker)...)
+		return nil
+	})
+	return found, err
+}
+
+// ScanContent finds the comments tagged with marker in one file
+func ScanContent(file, content, marker string) []Todo {
+	lines := strings.Split(content, "\n")
+	var found []Todo
+	for i, line := range lines {
+		at := strings.Index(line, marker)
+		if at < 0 || !isComment(line[:at]) {
+			continue
+		}
+		comment := trimCommentEnd(line[at:])
+		from, to := max(0, i-ContextLines), min(len(lines), i+ContextLines+1)
+		found = append(found, Todo{
+			File:        file,
+			Line:        i + 1,
+			Comment:     comment,
+			Text:        strings.TrimSpace(strings.TrimPrefix(comment, marker)),
+			Context:     strings.TrimRight(strings.Join(lines[from:to], "\n"), "\n "),
+			ContextLine: from + 1,
+		})
+	}
+	return found
+}
+
+// isComment reports whether the text before a marker opens a comment, so
+// markers quoted in strings or docs about the marker are ignored
+func isComment(before string) bool {
+	before = strings.TrimSpace(before)
+	for _, start := range commentStarts {
+		if strings.HasSuffix(before, start) {
+			return true
+		}
+	}
+	return false
+}
+
+func trimCommentEnd(comment string) string {
+	comment = strings.TrimSpace(comment)
+	for _, end := range commentEnds {
+		comment = strings.TrimSpace(strings.TrimSuffix(comment, end))
+	}
+	return comment
+}
+
+// TaskTitle is the title of the task for a TODO
+func TaskTitle(t Todo) string {
+	title := strings.TrimRight(t.Text, ".")
+	if title == "" {
+		return fmt.Sprintf("Resolve TODO at %s:%d", t.File, t.Line)
+	}
+	if len(title) > maxTitleLen {
+		title = strings.TrimSpace(title[:maxTitleLen]) + "..."
+	}
+	return title
+}
+
+// TaskBlock renders the tasks.md entry for a TODO. onComplete is Annotate
+// or Remove and is recorded in the Source line, so the comment can be
+// resolved when the task completes.
+func TaskBlock(t Todo, onComplete string) string {
+	// Numbered lines keep code such as "## comment" from reading as headers
+	var context strings.Builder
+	fmt.Fprintf(&context, "From a tagged TODO comment in %s at line %d:\n\n", t.File, t.Line)
+	lines := strings.Split(t.Context, "\n")
+	width := len(strconv.Itoa(t.ContextLine + len(lines)))
+	for i, line := range lines {
+		fmt.Fprintf(&context, "    %*d | %s\n", width, t.ContextLine+i, strings.TrimRight(line, " \t\r"))
+	}
+	resolved := "removed"
+	if onComplete == Annotate {
+		resolved = fmt.Sprintf("changed to %q", Done(t.Comment))
+	}
+	return tasks.Draft{
+		Title:   TaskTitle(t),
+		Context: context.String(),
+		Criteria: []string{
+			strings.TrimRight(t.Text, "."),
+			"Tests cover the change",
+			fmt.Sprintf("The %q comment is %s", t.Comment, resolved),
+		},
+		Files:  []string{t.File},
+		Source: FormatSource(t, onComplete),
+		Labels: []string{"source:todo"},
+	}.Block()
+}
+
+// Source points a task at the TODO it came from
+type Source struct {
+	File       string
+	Line       int
+	Comment    string
+	OnComplete string
+}
+
+var reSource = regexp.MustCompile(`^\*\*Source:\*\* (\S+):(\d+) (".*") \((` + Annotate + `|` + Remove + `) when done\)\s*$`)
+
+// FormatSource renders the Source line value of a TODO's task
+func FormatSource(t Todo, onComplete string) string {
+	return fmt.Sprintf("%s:%d %q (%s when done)", t.File, t.Line, t.Comment, onComplete)
+}
+
+// ParseSource finds the Source line in a task's details
+func ParseSource(details string) (Source, bool) {
+	for _, line := range strings.Split(details, "\n") {
+		m := reSource.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(m[2])
+		comment, err := strconv.Unquote(m[3])
+		if err != nil {
+			continue
+		}
+		return Source{File: m[1], Line: n, Comment: comment, OnComplete: m[4]}, true
+	}
+	return Source{}, false
+}
+
+// Done is the annotated form of a comment: its TODO becomes a DONE
+func Done(comment string) string {
+	if rest, ok := strings.CutPrefix(comment, "TODO"); ok {
+		return "DONE" + rest
+	}
+	return "DONE " + comment
+}
+
+// Resolve applies s.OnComplete to the comment in a file's content. The
+// comment is looked up nearest to its original line, since edits may have
+// moved it. It reports false when the comment is gone.
+func Resolve(content string, s Source) (string, bool) {
+	lines := strings.Split(content, "\n")
+	at := -1
+	for i, line := range lines {
+		if strings.Contains(line, s.Comment) && (at < 0 || abs(i+1-s.Line) < abs(at+1-s.Line)) {
+			at = i
+		}
+	}
+	if at < 0 {
+		return content, false
+	}
+	line := lines[at]
+	if s.OnComplete == Annotate {
+		lines[at] = strings.Replace(line, s.Comment, Done(s.Comment), 1)
+		return strings.Join(lines, "\n"), true
+	}
+
+	// Remove the comment, and the line when nothing else is on it
+	before := line[:strings.Index(line, s.Comment)]
+	for _, start := range commentStarts {
+		if trimmed := strings.TrimSpace(before); strings.HasSuffix(trimmed, start) {
+			before = strings.TrimRight(strings.TrimSuffix(trimmed, start), " \t")
+			before = line[:len(line)-len(strings.TrimLeft(line, " \t"))] + strings.TrimLeft(before, " \t")
+			break
+		}
+	}
+	if strings.TrimSpace(before) == "" {
+		lines = append(lines[:at], lines[at+1:]...)
+	} else {
+		lines[at] = before
+	}
+	return strings.Join(lines, "\n"), true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,128 @@
+package todos
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/tasks"
+)
+
+const sampleSource = `package app
+
+func Load() {
+	// TODO(autopilot): retry on transient errors.
+	fetch()
+	x := 1 // TODO(autopilot): make configurable
+	msg := "TODO(autopilot): not a comment"
+}
+`
+
+func TestScanContent(t *testing.T) {
+	found := ScanContent("app/load.go", sampleSource, DefaultMarker)
+	if len(found) != 2 {
+		t.Fatalf("ScanContent() found %d TODOs, want 2: %+v", len(found), found)
+	}
+	first := found[0]
+	if first.Line != 4 || first.Comment != "TODO(autopilot): retry on transient errors." || first.Text != "retry on transient errors." {
+		t.Errorf("Unexpected TODO %+v", first)
+	}
+	if first.ContextLine != 1 || !strings.HasPrefix(first.Context, "package app") || !strings.HasSuffix(first.Context, `msg := "TODO(autopilot): not a comment"`) {
+		t.Errorf("Unexpected context starting at %d: %q", first.ContextLine, first.Context)
+	}
+	if found[1].Line != 6 || found[1].Text != "make configurable" {
+		t.Errorf("Unexpected TODO %+v", found[1])
+	}
+
+	for _, line := range []string{"# TODO(autopilot): python", "<!-- TODO(autopilot): html -->", "/* TODO(autopilot): block */", "-- TODO(autopilot): sql"} {
+		got := ScanContent("f", line, DefaultMarker)
+		if len(got) != 1 || strings.ContainsAny(got[0].Text, "*>-") {
+			t.Errorf("ScanContent(%q) = %+v", line, got)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "app"), 0755)
+	os.MkdirAll(filepath.Join(dir, "node_modules", "lib"), 0755)
+	os.WriteFile(filepath.Join(dir, "app", "load.go"), []byte(sampleSource), 0644)
+	os.WriteFile(filepath.Join(dir, "node_modules", "lib", "index.js"), []byte("// TODO(autopilot): vendored\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "blob.bin"), []byte("// TODO(autopilot): binary\x00"), 0644)
+
+	found, err := Scan(dir, DefaultMarker)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if len(found) != 2 || found[0].File != "app/load.go" {
+		t.Errorf("Scan() = %+v", found)
+	}
+}
+
+func TestTaskBlock(t *testing.T) {
+	todo := ScanContent("app/load.go", "## TODO(autopilot): python-style heading comment\nx = 1\n", DefaultMarker)[0]
+	block := TaskBlock(todo, Annotate)
+
+	md := "## Current Tasks\n\n" + block + "\n"
+	if result := tasks.ValidateTasksStructure(md); !result.Valid {
+		t.Errorf("Expected a valid task, got %v:\n%s", result.Errors, block)
+	}
+	parsed := tasks.ParseTasks(md)
+	if len(parsed) != 1 || parsed[0].Title != "python-style heading comment" || parsed[0].ACTotal != 3 {
+		t.Errorf("ParseTasks() = %+v", parsed)
+	}
+	if !strings.Contains(block, `"DONE(autopilot): python-style heading comment"`) {
+		t.Errorf("Expected the annotation in the criteria:\n%s", block)
+	}
+
+	source, ok := ParseSource(block)
+	expected := Source{File: "app/load.go", Line: 1, Comment: todo.Comment, OnComplete: Annotate}
+	if !ok || !reflect.DeepEqual(source, expected) {
+		t.Errorf("ParseSource() = %+v, %v, want %+v", source, ok, expected)
+	}
+	if _, ok := ParseSource("**Files to Modify:** app/load.go"); ok {
+		t.Errorf("Expected no source in a regular task")
+	}
+
+	long := Todo{File: "a.go", Line: 3, Text: strings.Repeat("word ", 30)}
+	if title := TaskTitle(long); len(title) > maxTitleLen+3 || !strings.HasSuffix(title, "...") {
+		t.Errorf("TaskTitle() = %q", title)
+	}
+	if title := TaskTitle(Todo{File: "a.go", Line: 3}); title != "Resolve TODO at a.go:3" {
+		t.Errorf("TaskTitle() = %q", title)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	moved := "// header added later\n" + sampleSource
+	tests := []struct {
+		name     string
+		source   Source
+		expected string
+		removed  int // lines
+	}{
+		{"annotate", Source{Line: 4, Comment: "TODO(autopilot): retry on transient errors.", OnComplete: Annotate},
+			"\t// DONE(autopilot): retry on transient errors.\n\tfetch()", 0},
+		{"remove line", Source{Line: 4, Comment: "TODO(autopilot): retry on transient errors.", OnComplete: Remove},
+			"func Load() {\n\tfetch()", 1},
+		{"remove trailing", Source{Line: 6, Comment: "TODO(autopilot): make configurable", OnComplete: Remove},
+			"\tx := 1\n\tmsg", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Resolve(moved, tt.source)
+			if !ok || !strings.Contains(got, tt.expected) {
+				t.Errorf("Resolve() = %v:\n%s\nwant to contain %q", ok, got, tt.expected)
+			}
+			if strings.Count(got, "\n") != strings.Count(moved, "\n")-tt.removed {
+				t.Errorf("Unexpected line count:\n%s", got)
+			}
+		})
+	}
+
+	if _, ok := Resolve("package app\n", Source{Line: 1, Comment: "TODO(autopilot): gone", OnComplete: Remove}); ok {
+		t.Errorf("Expected a missing comment not to resolve")
+	}
+}
@@ -0,0 +1,166 @@
+// Package toolenv records the toolchain an agent runs under: the OS, the
+// versions of common language toolchains and a few environment variables
+// that change how builds and tests behave. A snapshot is taken each time a
+// task is dispatched, so a task that passed once and fails on retry can be
+// checked for an upgraded compiler or a changed NODE_ENV.
+package toolenv
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tool is a toolchain whose version is recorded
+type Tool struct {
+	Name string
+	Args []string // the arguments that print its version
+}
+
+// DefaultTools are the toolchains recorded when they are on the PATH
+var DefaultTools = []Tool{
+	{"go", []string{"version"}},
+	{"node", []string{"--version"}},
+	{"python3", []string{"--version"}},
+	{"rustc", []string{"--version"}},
+	{"java", []string{"-version"}},
+}
+
+// DefaultVars are the environment variables recorded. Values are stored as
+// they are, so only variables that never hold secrets belong here.
+var DefaultVars = []string{
+	"CI", "GOFLAGS", "GOTOOLCHAIN", "CGO_ENABLED", "NODE_ENV", "NODE_OPTIONS", "VIRTUAL_ENV", "JAVA_HOME",
+}
+
+// versionTimeout bounds each version command, so a broken shim can't hold
+// up a dispatch
+const versionTimeout = 5 * time.Second
+
+// Env is a snapshot of the toolchain environment
+type Env struct {
+	OS    string            `json:"os"`              // e.g. linux/amd64
+	Tools map[string]string `json:"tools,omitempty"` // tool name to version, for tools on the PATH
+	Vars  map[string]string `json:"vars,omitempty"`  // the set variables among those recorded
+}
+
+// Capture takes a snapshot of the tools and variables, reading variables
+// with getenv
+func Capture(tools []Tool, vars []string, getenv func(string) string) Env {
+	env := Env{OS: runtime.GOOS + "/" + runtime.GOARCH}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, tool := range tools {
+		path, err := exec.LookPath(tool.Name)
+		if err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(tool Tool, path string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), versionTimeout)
+			defer cancel()
+			// java prints its version to stderr
+			out, err := exec.CommandContext(ctx, path, tool.Args...).CombinedOutput()
+			if err != nil {
+				return
+			}
+			if v := Version(string(out)); v != "" {
+				mu.Lock()
+				if env.Tools == nil {
+					env.Tools = make(map[string]string)
+				}
+				env.Tools[tool.Name] = v
+				mu.Unlock()
+			}
+		}(tool, path)
+	}
+	wg.Wait()
+
+	for _, name := range vars {
+		if v := getenv(name); v != "" {
+			if env.Vars == nil {
+				env.Vars = make(map[string]string)
+			}
+			env.Vars[name] = v
+		}
+	}
+	return env
+}
+
+// Version picks the version out of the output of a version command: the
+// first word of the first line that contains a digit, e.g. "go1.22.1" from
+// "go version go1.22.1 linux/amd64" or "21.0.1" from `openjdk version
+// "21.0.1" 2023-10-17`
+func Version(output string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(output), "\n")
+	for _, word := range strings.Fields(line) {
+		if strings.ContainsAny(word, "0123456789") {
+			return strings.Trim(word, `"',()`)
+		}
+	}
+	return ""
+}
+
+// Diff lists what changed from e to other, e.g. "node v20.11.0 → v22.1.0"
+// or "NODE_ENV unset → production"
+func (e Env) Diff(other Env) []string {
+	var changes []string
+	if e.OS != other.OS {
+		changes = append(changes, fmt.Sprintf("os %s → %s", e.OS, other.OS))
+	}
+	changes = append(changes, diffMaps(e.Tools, other.Tools, "missing")...)
+	changes = append(changes, diffMaps(e.Vars, other.Vars, "unset")...)
+	return changes
+}
+
+func diffMaps(before, after map[string]string, absent string) []string {
+	var changes []string
+	for _, name := range unionKeys(before, after) {
+		b, a := before[name], after[name]
+		if b == a {
+			continue
+		}
+		if b == "" {
+			b = absent
+		}
+		if a == "" {
+			a = absent
+		}
+		changes = append(changes, fmt.Sprintf("%s %s → %s", name, b, a))
+	}
+	return changes
+}
+
+func unionKeys(maps ...map[string]string) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, m := range maps {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// String summarizes the snapshot on one line, e.g. "linux/amd64, go
+// go1.22.1, node v20.11.0, CI=true"
+func (e Env) String() string {
+	parts := []string{e.OS}
+	for _, name := range unionKeys(e.Tools) {
+		parts = append(parts, name+" "+e.Tools[name])
+	}
+	for _, name := range unionKeys(e.Vars) {
+		parts = append(parts, name+"="+e.Vars[name])
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,61 @@
+package toolenv
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"go version go1.22.1 linux/amd64\n", "go1.22.1"},
+		{"v20.11.0\n", "v20.11.0"},
+		{"Python 3.12.1\n", "3.12.1"},
+		{"rustc 1.75.0 (82e1608df 2023-12-21)\n", "1.75.0"},
+		{"openjdk version \"21.0.1\" 2023-10-17\nOpenJDK Runtime Environment (build 21.0.1+12)\n", "21.0.1"},
+		{"command not found\n", ""},
+	}
+	for _, tt := range tests {
+		if got := Version(tt.output); got != tt.want {
+			t.Errorf("Version(%q) = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	before := Env{
+		OS:    "linux/amd64",
+		Tools: map[string]string{"go": "go1.22.1", "node": "v20.11.0"},
+		Vars:  map[string]string{"CI": "true"},
+	}
+	if got := before.Diff(before); got != nil {
+		t.Errorf("Diff(same) = %v", got)
+	}
+	after := Env{
+		OS:    "linux/amd64",
+		Tools: map[string]string{"go": "go1.23.0", "python3": "3.12.1"},
+		Vars:  map[string]string{"CI": "true", "NODE_ENV": "production"},
+	}
+	want := []string{"go go1.22.1 → go1.23.0", "node v20.11.0 → missing", "python3 missing → 3.12.1", "NODE_ENV unset → production"}
+	if got := before.Diff(after); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() = %v, want %v", got, want)
+	}
+	if got := before.String(); got != "linux/amd64, go go1.22.1, node v20.11.0, CI=true" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestCapture(t *testing.T) {
+	getenv := func(name string) string {
+		return map[string]string{"CI": "1"}[name]
+	}
+	env := Capture([]Tool{{"no-such-tool-xyz", []string{"--version"}}}, []string{"CI", "NODE_ENV"}, getenv)
+	if env.OS == "" || env.Tools != nil {
+		t.Errorf("Capture() = %+v; want the OS and no missing tools", env)
+	}
+	if !reflect.DeepEqual(env.Vars, map[string]string{"CI": "1"}) {
+		t.Errorf("Vars = %v; want only the set variables", env.Vars)
+	}
+}
@@ -0,0 +1,149 @@
+package tui
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Keys the dashboard acts on
+const (
+	KeyUp    = "up"
+	KeyDown  = "down"
+	KeyPause = "pause"
+	KeySkip  = "skip"
+	KeyRetry = "retry"
+	KeyQuit  = "quit"
+)
+
+// keyNames map the bytes a terminal sends to keys
+var keyNames = map[string]string{
+	"\x1b[A": KeyUp, "\x1bOA": KeyUp, "k": KeyUp,
+	"\x1b[B": KeyDown, "\x1bOB": KeyDown, "j": KeyDown, "\t": KeyDown,
+	"p": KeyPause, "s": KeySkip, "r": KeyRetry, "q": KeyQuit,
+}
+
+// Keys decodes the keys in a read from the terminal, dropping the ones the
+// dashboard doesn't use
+func Keys(input []byte) []string {
+	var keys []string
+	for s := string(input); s != ""; {
+		seq := strings.ToLower(s[:1])
+		if s[0] == '\x1b' && len(s) >= 3 {
+			seq = s[:3]
+		}
+		if key, ok := keyNames[seq]; ok {
+			keys = append(keys, key)
+		}
+		s = s[len(seq):]
+	}
+	return keys
+}
+
+// Screen redraws a view on the alternate screen of a terminal, so the
+// dashboard leaves the scrollback as it found it
+type Screen struct {
+	out    io.Writer
+	source func() View
+	size   func() (width, height int)
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewScreen creates a screen on out showing the views returned by source,
+// sized by size
+func NewScreen(out io.Writer, source func() View, size func() (width, height int)) *Screen {
+	return &Screen{out: out, source: source, size: size}
+}
+
+// Start switches to the alternate screen and redraws every interval until
+// Stop is called
+func (s *Screen) Start(interval time.Duration) {
+	io.WriteString(s.out, "\033[?1049h\033[?25l")
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			s.Redraw()
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Redraw draws the current view now, e.g. right after a key
+func (s *Screen) Redraw() {
+	width, height := s.size()
+	lines := Render(s.source(), width, height)
+	var b strings.Builder
+	b.WriteString("\033[H")
+	for i, line := range lines {
+		b.WriteString(line + "\033[K")
+		if i < len(lines)-1 {
+			b.WriteString("\r\n")
+		}
+	}
+	b.WriteString("\033[J")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	io.WriteString(s.out, b.String())
+}
+
+// Stop stops the redraws and returns to the normal screen
+func (s *Screen) Stop() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	io.WriteString(s.out, "\033[?25h\033[?1049l")
+}
+
+// stty runs stty on the terminal f
+func stty(f *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = f
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// Size returns the width and height of the terminal f, or 100x30 when it
+// can't be read
+func Size(f *os.File) (width, height int) {
+	out, err := stty(f, "size")
+	rows, cols, _ := strings.Cut(out, " ")
+	h, errH := strconv.Atoi(rows)
+	w, errW := strconv.Atoi(cols)
+	if err != nil || errH != nil || errW != nil || w == 0 || h == 0 {
+		return 100, 30
+	}
+	return w, h
+}
+
+// RawInput makes the terminal f pass each key on as it is typed, without
+// echoing it, and returns the function that restores its settings. Ctrl-C
+// still sends SIGINT.
+func RawInput(f *os.File) (restore func(), err error) {
+	saved, err := stty(f, "-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty(f, "-icanon", "-echo", "min", "1", "time", "0"); err != nil {
+		return nil, err
+	}
+	return func() { stty(f, saved) }, nil
+}
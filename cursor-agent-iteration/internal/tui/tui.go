@@ -0,0 +1,261 @@
+// Package tui draws the full-screen dashboard of iterate-loop: the tasks by
+// status, a scrolling output pane per running agent and the loop's own log,
+// and reads the keys that pause the loop or skip and retry a task.
+package tui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/textutil"
+)
+
+// Pane is a running agent as shown in the dashboard
+type Pane struct {
+	Title   string
+	Backend string
+	Elapsed time.Duration
+	// Lines are the last lines of the agent's output
+	Lines []string
+}
+
+// View is everything one frame of the dashboard shows
+type View struct {
+	Header     string // e.g. the run and the number of running agents
+	Paused     bool
+	Pending    []string
+	InProgress []string
+	Completed  []string
+	Blocked    int
+	Failed     int
+	Panes      []Pane
+	Selected   int      // index of the selected pane
+	Log        []string // the loop's last lines of output
+	Notice     string   // what the last key did
+}
+
+// Help lists the keys, for the footer
+const Help = "↑/↓ select · p pause · s skip · r retry · q quit"
+
+// logLines is the most of the loop's log shown below the panes
+const logLines = 4
+
+// Render returns the frame of a view as exactly height lines of at most
+// width columns
+func Render(v View, width, height int) []string {
+	if width < 20 {
+		width = 20
+	}
+	if height < 8 {
+		height = 8
+	}
+	var lines []string
+
+	header := " cursor-iter iterate-loop"
+	if v.Paused {
+		header += " · ⏸ PAUSED"
+	}
+	if v.Header != "" {
+		header += " · " + v.Header
+	}
+	if v.Blocked > 0 {
+		header += fmt.Sprintf(" · ⛔ %d blocked", v.Blocked)
+	}
+	if v.Failed > 0 {
+		header += fmt.Sprintf(" · ❌ %d failed", v.Failed)
+	}
+	lines = append(lines, "\033[7m"+pad(header, width)+"\033[0m")
+
+	// The columns take up to a third of the screen
+	rows := max(len(v.Pending), len(v.InProgress), len(v.Completed))
+	rows = min(max(rows, 1), height/3)
+	colWidth := (width - 6) / 3
+	columns := [][]string{
+		column(fmt.Sprintf("PENDING (%d)", len(v.Pending)), v.Pending, rows, colWidth),
+		column(fmt.Sprintf("IN PROGRESS (%d)", len(v.InProgress)), v.InProgress, rows, colWidth),
+		column(fmt.Sprintf("COMPLETED (%d)", len(v.Completed)), v.Completed, rows, colWidth),
+	}
+	for i := range columns[0] {
+		lines = append(lines, columns[0][i]+" │ "+columns[1][i]+" │ "+columns[2][i])
+	}
+
+	logHeight := min(len(v.Log), logLines) + 1
+	paneHeight := height - len(lines) - logHeight - 1
+	lines = append(lines, panes(v.Panes, v.Selected, width, paneHeight)...)
+
+	lines = append(lines, "\033[2m"+pad("── log ", width)+"\033[0m")
+	for _, line := range v.Log[max(len(v.Log)-(logHeight-1), 0):] {
+		lines = append(lines, textutil.Truncate(line, width))
+	}
+
+	footer := Help
+	if v.Notice != "" {
+		footer = v.Notice + "  │  " + footer
+	}
+	footerLine := "\033[7m" + pad(" "+footer, width) + "\033[0m"
+	// On a tiny screen the panes and the log give way to the footer
+	if len(lines) >= height {
+		lines = lines[:height-1]
+	}
+	return append(lines, footerLine)
+}
+
+// column returns a titled column of items, rows lines long after the title.
+// Items that don't fit are counted on the last line.
+func column(title string, items []string, rows, width int) []string {
+	lines := []string{"\033[1m" + pad(title, width) + "\033[0m"}
+	for i := 0; i < rows; i++ {
+		switch {
+		case i == rows-1 && len(items) > rows:
+			lines = append(lines, pad(fmt.Sprintf("… +%d more", len(items)-i), width))
+		case i < len(items):
+			lines = append(lines, pad(items[i], width))
+		default:
+			lines = append(lines, pad("", width))
+		}
+	}
+	return lines
+}
+
+// panes returns exactly height lines of output panes, side by side in two
+// columns on wide screens. Panes that don't fit are counted on the last line,
+// keeping the selected one on screen.
+func panes(ps []Pane, selected, width, height int) []string {
+	var lines []string
+	if len(ps) == 0 {
+		lines = append(lines, "\033[2m"+pad("── no agents running", width)+"\033[0m")
+	}
+	cols := 1
+	if width >= 160 && len(ps) > 1 {
+		cols = 2
+	}
+	colWidth := width
+	if cols == 2 {
+		colWidth = (width - 3) / 2
+	}
+
+	// Each pane needs a title line and at least two lines of output
+	fit := max(height/3, 1) * cols
+	first := 0
+	if len(ps) > fit {
+		fit -= cols // the last line counts the rest
+		if fit < 1 {
+			fit = 1
+		}
+		first = min(max(selected-fit+1, 0), len(ps)-fit)
+	}
+	shown := ps[first:min(first+fit, len(ps))]
+	rows := (len(shown) + cols - 1) / cols
+	paneHeight := height
+	if rows > 0 {
+		paneHeight = (height - boolInt(len(shown) < len(ps))) / rows
+	}
+	for r := 0; r < rows; r++ {
+		var blocks [][]string
+		for c := 0; c < cols; c++ {
+			i := r*cols + c
+			if i >= len(shown) {
+				blocks = append(blocks, blank(paneHeight, colWidth))
+				continue
+			}
+			blocks = append(blocks, pane(shown[i], first+i == selected, colWidth, paneHeight))
+		}
+		for l := 0; l < paneHeight; l++ {
+			parts := make([]string, len(blocks))
+			for c, b := range blocks {
+				parts[c] = b[l]
+			}
+			lines = append(lines, strings.Join(parts, " │ "))
+		}
+	}
+	if len(shown) < len(ps) {
+		lines = append(lines, pad(fmt.Sprintf("  +%d more running (↑/↓ to select)", len(ps)-len(shown)), width))
+	}
+	for len(lines) < height {
+		lines = append(lines, "")
+	}
+	return lines[:max(height, 0)]
+}
+
+// pane returns an agent's title line and the tail of its output, height
+// lines of width columns
+func pane(p Pane, selected bool, width, height int) []string {
+	if height < 1 {
+		return nil
+	}
+	title := fmt.Sprintf("── %s", p.Title)
+	if p.Backend != "" {
+		title += " [" + p.Backend + "]"
+	}
+	title += " " + p.Elapsed.Truncate(time.Second).String() + " "
+	style := "\033[2m"
+	if selected {
+		title = "▶" + strings.TrimPrefix(title, "─")
+		style = "\033[1;36m"
+	}
+	lines := []string{style + pad(title, width) + "\033[0m"}
+	out := p.Lines[max(len(p.Lines)-(height-1), 0):]
+	for _, line := range out {
+		lines = append(lines, pad(line, width))
+	}
+	for len(lines) < height {
+		lines = append(lines, pad("", width))
+	}
+	return lines[:height]
+}
+
+func blank(height, width int) []string {
+	lines := make([]string, height)
+	for i := range lines {
+		lines[i] = pad("", width)
+	}
+	return lines
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// pad truncates or pads s to exactly width columns
+func pad(s string, width int) string {
+	s = textutil.Truncate(s, width)
+	return s + strings.Repeat(" ", max(width-len([]rune(s)), 0))
+}
+
+// reEscape matches terminal escape sequences in agent output
+var reEscape = regexp.MustCompile(`\x1b(\[[0-9;?]*[ -/]*[@-~]|\][^\x07\x1b]*(\x07|\x1b\\)|[@-Z\\-_])`)
+
+// Lines splits output into the lines to show in a pane: escape sequences
+// are dropped, a carriage return keeps only what was written after it and
+// tabs become spaces. At most n of the last lines are returned.
+func Lines(output string, n int) []string {
+	output = strings.TrimRight(reEscape.ReplaceAllString(output, ""), "\n")
+	if output == "" {
+		return nil
+	}
+	all := strings.Split(output, "\n")
+	all = all[max(len(all)-n, 0):]
+	lines := make([]string, 0, len(all))
+	for _, line := range all {
+		line = strings.TrimRight(line, "\r")
+		if i := strings.LastIndex(line, "\r"); i >= 0 {
+			line = line[i+1:]
+		}
+		line = strings.Map(func(r rune) rune {
+			switch {
+			case r == '\t':
+				return ' '
+			case r < ' ' || r == 0x7f:
+				return -1
+			}
+			return r
+		}, line)
+		lines = append(lines, line)
+	}
+	return lines
+}
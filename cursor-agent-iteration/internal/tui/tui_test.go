@@ -0,0 +1,111 @@
+package tui
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var reStyle = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// plain drops the styling of rendered lines
+func plain(lines []string) []string {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[i] = reStyle.ReplaceAllString(line, "")
+	}
+	return out
+}
+
+func TestRender(t *testing.T) {
+	var output []string
+	for i := 0; i < 30; i++ {
+		output = append(output, fmt.Sprintf("line %d", i))
+	}
+	v := View{
+		Header:     "run 1 · 2/3 agents",
+		Paused:     true,
+		Pending:    []string{"Docs", "Search", "Export", "Import", "Billing"},
+		InProgress: []string{"Login (1/2)", "Signup (0/3)"},
+		Completed:  []string{"Setup"},
+		Blocked:    1,
+		Panes: []Pane{
+			{Title: "Login", Backend: "codex", Elapsed: 90 * time.Second, Lines: output},
+			{Title: "Signup", Elapsed: time.Second, Lines: []string{"starting"}},
+		},
+		Selected: 1,
+		Log:      []string{"one", "two", "three", "four", "five"},
+		Notice:   "Restarting 'Login'",
+	}
+
+	for _, size := range []struct{ width, height int }{{100, 30}, {180, 40}, {60, 12}, {10, 3}} {
+		lines := plain(Render(v, size.width, size.height))
+		width, height := max(size.width, 20), max(size.height, 8)
+		if len(lines) != height {
+			t.Errorf("%dx%d: got %d lines", size.width, size.height, len(lines))
+		}
+		for _, line := range lines {
+			if n := len([]rune(line)); n > width {
+				t.Errorf("%dx%d: line of %d columns: %q", size.width, size.height, n, line)
+			}
+		}
+		if !strings.Contains(lines[len(lines)-1], "p pause") && width >= 60 {
+			t.Errorf("%dx%d: expected the footer last, got %q", size.width, size.height, lines[len(lines)-1])
+		}
+	}
+
+	lines := plain(Render(v, 100, 30))
+	text := strings.Join(lines, "\n")
+	for _, want := range []string{"⏸ PAUSED · run 1 · 2/3 agents · ⛔ 1 blocked", "PENDING (5)", "IN PROGRESS (2)", "COMPLETED (1)", "── Login [codex] 1m30s", "▶─ Signup 1s", "line 29", "starting", "── log", "five", "Restarting 'Login'"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected the frame to contain %q:\n%s", want, text)
+		}
+	}
+	if small := strings.Join(plain(Render(v, 100, 12)), "\n"); !strings.Contains(small, "… +2 more") {
+		t.Errorf("Expected the items that don't fit to be counted:\n%s", small)
+	}
+	if strings.Contains(text, "line 0\n") || strings.Contains(text, "\none\n") {
+		t.Errorf("Expected only the tails of the output and log:\n%s", text)
+	}
+
+	// Panes that don't fit are counted, keeping the selected one shown
+	v.Panes = nil
+	for i := 0; i < 10; i++ {
+		v.Panes = append(v.Panes, Pane{Title: fmt.Sprintf("Task %d", i)})
+	}
+	v.Selected = 9
+	text = strings.Join(plain(Render(v, 100, 30)), "\n")
+	if !strings.Contains(text, "▶─ Task 9") || !strings.Contains(text, "more running") || strings.Contains(text, "Task 0 ") {
+		t.Errorf("Expected the selected pane and a count of the others:\n%s", text)
+	}
+
+	v.Panes = nil
+	if text := strings.Join(plain(Render(v, 100, 30)), "\n"); !strings.Contains(text, "no agents running") {
+		t.Errorf("Expected a note without agents:\n%s", text)
+	}
+}
+
+func TestLines(t *testing.T) {
+	output := "\x1b[32mgreen\x1b[0m\n\tindented\nprogress 10%\rprogress 90%\n\x1b]0;title\x07bell\x07\n\n"
+	expected := []string{"green", " indented", "progress 90%", "bell", ""}
+	if got := Lines(output, 10); !reflect.DeepEqual(got, expected[:4]) {
+		t.Errorf("Lines() = %q, want %q", got, expected[:4])
+	}
+	if got := Lines(output, 2); !reflect.DeepEqual(got, expected[2:4]) {
+		t.Errorf("Lines(2) = %q, want %q", got, expected[2:4])
+	}
+	if got := Lines("", 5); got != nil {
+		t.Errorf("Lines(\"\") = %q", got)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	got := Keys([]byte("\x1b[Ak\x1b[BjP\tsrqx\x1b[C"))
+	expected := []string{KeyUp, KeyUp, KeyDown, KeyDown, KeyPause, KeyDown, KeySkip, KeyRetry, KeyQuit}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Keys() = %q, want %q", got, expected)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package verify
+
+import "os/exec"
+
+// setProcessGroup is a no-op where process groups aren't available
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killGroup kills cmd
+func killGroup(cmd *exec.Cmd) {
+	_ = cmd.Process.Kill()
+}
@@ -0,0 +1,160 @@
+// Package verify runs a project's checks in tiers: quick ones that gate the
+// completion of each task, and a slow full suite batched across several
+// completed tasks, whose failures are blamed on the task most likely to
+// have caused them
+package verify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The tiers
+const (
+	Fast = "fast" // lint and build, after every task
+	Full = "full" // the whole test suite, after a batch of tasks
+)
+
+// GateOrder is the order of the usual quality gates; a gate of another
+// name runs after them, in name order
+var GateOrder = []string{"lint", "typecheck", "build", "test"}
+
+// Gate is a named command that must pass before a task counts as completed
+type Gate struct {
+	Name    string
+	Command string
+}
+
+// Gates returns the gates of a name-to-command map, such as the gates
+// section of the config file, cheapest first: in GateOrder, then by name.
+// Gates without a command are left out.
+func Gates(commands map[string]string) []Gate {
+	var gates []Gate
+	for name, command := range commands {
+		if command = strings.TrimSpace(command); command != "" {
+			gates = append(gates, Gate{Name: name, Command: command})
+		}
+	}
+	rank := func(name string) int {
+		if i := slices.Index(GateOrder, name); i >= 0 {
+			return i
+		}
+		return len(GateOrder)
+	}
+	sort.Slice(gates, func(i, j int) bool {
+		if ri, rj := rank(gates[i].Name), rank(gates[j].Name); ri != rj {
+			return ri < rj
+		}
+		return gates[i].Name < gates[j].Name
+	})
+	return gates
+}
+
+// RunGates runs gates in order until one fails, returning the result of the
+// last gate run and whether every gate passed
+func RunGates(ctx context.Context, gates []Gate, timeout time.Duration) (Result, bool) {
+	var r Result
+	for _, g := range gates {
+		r = Run(ctx, Fast, g.Command, timeout)
+		r.Gate = g.Name
+		if !r.Passed {
+			return r, false
+		}
+	}
+	return r, true
+}
+
+// Result is the outcome of running a tier's command
+type Result struct {
+	Tier string
+	// Gate names the quality gate the command is, if it is one
+	Gate     string
+	Command  string
+	Passed   bool
+	Output   string
+	Duration time.Duration
+	// TimedOut is set when the command was killed at its timeout
+	TimedOut bool
+}
+
+// Run runs command with sh in the working directory, killing it after
+// timeout unless timeout is 0. The output holds stdout and stderr.
+func Run(ctx context.Context, tier, command string, timeout time.Duration) Result {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	// The timeout stops everything the command started, such as test
+	// binaries, not just the shell
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		killGroup(cmd)
+		return nil
+	}
+	cmd.WaitDelay = time.Second
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	return Result{
+		Tier:     tier,
+		Command:  command,
+		Passed:   err == nil,
+		Output:   out.String(),
+		Duration: time.Since(start),
+		TimedOut: errors.Is(ctx.Err(), context.DeadlineExceeded),
+	}
+}
+
+// Change is what one completed task changed, from the commits between its
+// run's start and end
+type Change struct {
+	Task  string
+	Files []string
+}
+
+// Blame picks the task among changes, oldest first, that a failure's output
+// points at most: a changed file named by its path counts most, then by its
+// base name, then its directory as a package. Ties, and output that names
+// none of the files, go to the latest task.
+func Blame(output string, changes []Change) string {
+	best, bestScore := "", -1
+	for _, c := range changes {
+		score := 0
+		dirs := make(map[string]bool)
+		for _, f := range c.Files {
+			switch dir := path.Dir(f); {
+			case strings.Contains(output, f):
+				score += 3
+			case strings.Contains(output, path.Base(f)):
+				score += 2
+			case dir != "." && !dirs[dir] && strings.Contains(output, dir):
+				dirs[dir] = true
+				score++
+			}
+		}
+		if score >= bestScore {
+			best, bestScore = c.Task, score
+		}
+	}
+	return best
+}
+
+// Tail returns the last n lines of output
+func Tail(output string, n int) string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
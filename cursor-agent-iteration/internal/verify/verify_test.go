@@ -0,0 +1,73 @@
+package verify
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	r := Run(context.Background(), Fast, "echo built; echo warned >&2", 0)
+	if !r.Passed || r.TimedOut || r.Tier != Fast || !strings.Contains(r.Output, "built") || !strings.Contains(r.Output, "warned") {
+		t.Errorf("Run() of a passing command = %+v", r)
+	}
+	if r := Run(context.Background(), Full, "echo FAIL; exit 1", 0); r.Passed || r.Output != "FAIL\n" {
+		t.Errorf("Run() of a failing command = %+v", r)
+	}
+	if r := Run(context.Background(), Full, "sleep 5", 50*time.Millisecond); r.Passed || !r.TimedOut || r.Duration > 4*time.Second {
+		t.Errorf("Run() past its timeout = %+v", r)
+	}
+}
+
+func TestGates(t *testing.T) {
+	gates := Gates(map[string]string{"test": "go test ./...", "docs": "mkdocs build", "lint": "golangci-lint run", "build": "go build ./...", "typecheck": " "})
+	var names []string
+	for _, g := range gates {
+		names = append(names, g.Name)
+	}
+	if strings.Join(names, ",") != "lint,build,test,docs" {
+		t.Errorf("Gates() ran in the order %v", names)
+	}
+
+	r, passed := RunGates(context.Background(), []Gate{{"lint", "true"}, {"build", "echo broken; false"}, {"test", "echo never"}}, 0)
+	if passed || r.Gate != "build" || r.Output != "broken\n" {
+		t.Errorf("RunGates() = %+v, %v; want the build gate to fail", r, passed)
+	}
+	if r, passed := RunGates(context.Background(), gates[:0], 0); !passed || r.Gate != "" {
+		t.Errorf("RunGates() without gates = %+v, %v", r, passed)
+	}
+}
+
+func TestBlame(t *testing.T) {
+	changes := []Change{
+		{Task: "Login", Files: []string{"internal/auth/login.go", "internal/auth/login_test.go"}},
+		{Task: "Search", Files: []string{"internal/search/index.go"}},
+		{Task: "Docs", Files: []string{"README.md"}},
+	}
+	for _, tt := range []struct {
+		output string
+		want   string
+	}{
+		{"--- FAIL: TestLogin (0.00s)\n    login_test.go:12: bad password accepted\nFAIL\tgithub.com/acme/app/internal/auth\t0.01s", "Login"},
+		{"internal/search/index.go:40:2: undefined: tokenize", "Search"},
+		{"ok  \tgithub.com/acme/app/internal/auth\nFAIL\tgithub.com/acme/app/internal/search [build failed]", "Search"},
+		{"segmentation fault", "Docs"},
+	} {
+		if got := Blame(tt.output, changes); got != tt.want {
+			t.Errorf("Blame(%q) = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+	if got := Blame("anything", nil); got != "" {
+		t.Errorf("Blame() without changes = %q", got)
+	}
+}
+
+func TestTail(t *testing.T) {
+	if got := Tail("a\nb\nc\nd\n", 2); got != "c\nd" {
+		t.Errorf("Tail() = %q", got)
+	}
+	if got := Tail("only\n", 5); got != "only" {
+		t.Errorf("Tail() of a short output = %q", got)
+	}
+}
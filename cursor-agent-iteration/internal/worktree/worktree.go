@@ -0,0 +1,236 @@
+// Package worktree gives each task of a parallel loop a git worktree and a
+// branch of its own, so agents can't trample each other's changes, and
+// brings a task's work back once it completes: by merging its branch, or by
+// pushing it and opening a pull request.
+package worktree
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/cheddarwhizzy/cursor-autopilot/cursor-agent-iteration/internal/conflict"
+)
+
+// BranchPrefix starts the name of every task branch
+const BranchPrefix = "cursor-iter/"
+
+// Tree is the worktree of a task
+type Tree struct {
+	// Path is the absolute path of the worktree
+	Path   string
+	Branch string
+}
+
+// ConflictError is returned by Merge when a branch doesn't merge cleanly.
+// The merge has been aborted, leaving the repository as it was.
+type ConflictError struct {
+	Branch string
+	Files  []string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("merging %s conflicts in %s", e.Branch, strings.Join(e.Files, ", "))
+}
+
+// Open returns the worktree at path on branch, adding it from repo's HEAD
+// when it doesn't exist yet. An existing worktree or branch is reused, so a
+// retried task continues from the work of its earlier runs.
+func Open(repo, path, branch string) (Tree, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Tree{}, err
+	}
+	t := Tree{Path: abs, Branch: branch}
+	// Forget worktrees whose directory was deleted by hand
+	if _, err := git(repo, "worktree", "prune"); err != nil {
+		return t, fmt.Errorf("not a git repository: %v", err)
+	}
+	if exists, err := registered(repo, abs); err != nil || exists {
+		return t, err
+	}
+	if err := os.MkdirAll(filepath.Dir(abs), 0755); err != nil {
+		return t, err
+	}
+	args := []string{"worktree", "add", "-q", abs, branch}
+	if _, err := git(repo, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch); err != nil {
+		args = []string{"worktree", "add", "-q", "-b", branch, abs, "HEAD"}
+	}
+	if _, err := git(repo, args...); err != nil {
+		return t, fmt.Errorf("could not add worktree %s: %v", abs, err)
+	}
+	return t, nil
+}
+
+// registered reports whether git knows a worktree at path
+func registered(repo, path string) (bool, error) {
+	out, err := git(repo, "worktree", "list", "--porcelain")
+	if err != nil {
+		return false, err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if p, ok := strings.CutPrefix(line, "worktree "); ok && samePath(p, path) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// samePath compares paths after resolving symlinks, since git reports
+// worktrees by their real path
+func samePath(a, b string) bool {
+	if a == b {
+		return true
+	}
+	ra, errA := filepath.EvalSymlinks(a)
+	rb, errB := filepath.EvalSymlinks(b)
+	return errA == nil && errB == nil && ra == rb
+}
+
+// Commit commits every change left uncommitted in the worktree and reports
+// whether there were any
+func Commit(t Tree, message string) (bool, error) {
+	status, err := git(t.Path, "status", "--porcelain")
+	if err != nil {
+		return false, err
+	}
+	if strings.TrimSpace(status) == "" {
+		return false, nil
+	}
+	if _, err := git(t.Path, "add", "-A"); err != nil {
+		return false, err
+	}
+	if _, err := git(t.Path, "commit", "-q", "-m", message); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Ahead counts the commits of the worktree's branch that repo's HEAD doesn't
+// have
+func Ahead(repo string, t Tree) (int, error) {
+	out, err := git(repo, "rev-list", "--count", "HEAD.."+t.Branch)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	_, err = fmt.Sscanf(strings.TrimSpace(out), "%d", &n)
+	return n, err
+}
+
+// Merge merges the worktree's branch into the branch checked out in repo,
+// with a merge commit. When it conflicts, the merge is aborted and a
+// *ConflictError returned.
+func Merge(repo string, t Tree, message string) error {
+	_, err := git(repo, "merge", "--no-ff", "-q", "-m", message, t.Branch)
+	if err == nil {
+		return nil
+	}
+	st, inspectErr := conflict.Inspect(repo)
+	if inspectErr != nil || st.Op != conflict.OpMerge {
+		return fmt.Errorf("could not merge %s: %v", t.Branch, err)
+	}
+	if _, abortErr := git(repo, "merge", "--abort"); abortErr != nil {
+		return fmt.Errorf("could not abort the conflicting merge of %s: %v", t.Branch, abortErr)
+	}
+	return &ConflictError{Branch: t.Branch, Files: st.Files}
+}
+
+// CherryPick puts commits, oldest first, on branch: on top of it when it
+// exists, else on a new branch started at base. It works in a worktree of
+// its own that it removes again, so the checkout of repo isn't touched.
+// When a commit doesn't apply, nothing is changed.
+func CherryPick(repo, branch, base string, commits []string) error {
+	tmp, err := os.MkdirTemp("", "cursor-iter-pick-")
+	if err != nil {
+		return err
+	}
+	os.Remove(tmp) // git worktree add creates it
+	defer os.RemoveAll(tmp)
+	_, exists := git(repo, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch)
+	args := []string{"worktree", "add", "-q", "--detach", tmp, base}
+	if exists == nil {
+		args = []string{"worktree", "add", "-q", "--detach", tmp, branch}
+	}
+	if _, err := git(repo, args...); err != nil {
+		return fmt.Errorf("could not add a worktree for %s: %v", branch, err)
+	}
+	defer git(repo, "worktree", "remove", "--force", tmp)
+	if _, err := git(tmp, append([]string{"cherry-pick", "--allow-empty"}, commits...)...); err != nil {
+		git(tmp, "cherry-pick", "--abort")
+		return fmt.Errorf("could not put the commits on %s: %v", branch, err)
+	}
+	head, err := git(tmp, "rev-parse", "HEAD")
+	if err != nil {
+		return err
+	}
+	if _, err := git(repo, "branch", "-f", branch, strings.TrimSpace(head)); err != nil {
+		return fmt.Errorf("could not update %s: %v", branch, err)
+	}
+	return nil
+}
+
+// PullRequest pushes branch from the repository at dir to remote and opens
+// a pull request for it against base with the GitHub CLI, returning its URL.
+// When the branch already has an open pull request, the push updates it and
+// its URL is returned.
+func PullRequest(dir, remote, branch, base, title, body string) (string, error) {
+	if _, err := exec.LookPath("gh"); err != nil {
+		return "", fmt.Errorf("gh CLI not found: %w", err)
+	}
+	if _, err := git(dir, "push", "-q", "-u", remote, branch); err != nil {
+		return "", fmt.Errorf("could not push %s to %s: %v", branch, remote, err)
+	}
+	if out, err := run(dir, "gh", "pr", "view", branch, "--json", "url,state", "--jq", `select(.state == "OPEN") | .url`); err == nil && strings.TrimSpace(out) != "" {
+		return strings.TrimSpace(out), nil
+	}
+	out, err := run(dir, "gh", "pr", "create", "--base", base, "--head", branch, "--title", title, "--body", body)
+	if err != nil {
+		return "", fmt.Errorf("could not open a pull request for %s: %v", branch, err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	return lines[len(lines)-1], nil
+}
+
+// Remove deletes the worktree and, with deleteBranch, its branch
+func Remove(repo string, t Tree, deleteBranch bool) error {
+	if _, err := git(repo, "worktree", "remove", "--force", t.Path); err != nil {
+		return fmt.Errorf("could not remove worktree %s: %v", t.Path, err)
+	}
+	if deleteBranch {
+		if _, err := git(repo, "branch", "-q", "-D", t.Branch); err != nil {
+			return fmt.Errorf("could not delete branch %s: %v", t.Branch, err)
+		}
+	}
+	return nil
+}
+
+// CurrentBranch returns the branch checked out in repo; it fails on a
+// detached HEAD
+func CurrentBranch(repo string) (string, error) {
+	out, err := git(repo, "symbolic-ref", "--quiet", "--short", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("no branch is checked out in %s: %v", repo, err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+func git(dir string, args ...string) (string, error) {
+	return run(dir, "git", args...)
+}
+
+func run(dir, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil && stderr.Len() > 0 {
+		err = fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), err
+}
@@ -0,0 +1,157 @@
+package worktree
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testRepo creates a repository with one commit of app.go on main
+func testRepo(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found in PATH, skipping test")
+	}
+	for _, kv := range [][2]string{{"GIT_AUTHOR_NAME", "test"}, {"GIT_AUTHOR_EMAIL", "test@example.com"}, {"GIT_COMMITTER_NAME", "test"}, {"GIT_COMMITTER_EMAIL", "test@example.com"}} {
+		t.Setenv(kv[0], kv[1])
+	}
+	root := t.TempDir()
+	mustGit(t, root, "init", "-q", "-b", "main")
+	write(t, root, "app.go", "package app\n\nconst Name = \"base\"\n")
+	mustGit(t, root, "add", ".")
+	mustGit(t, root, "commit", "-qm", "base")
+	return root
+}
+
+func mustGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := git(dir, args...)
+	if err != nil {
+		t.Fatalf("git %v: %v", args, err)
+	}
+	return out
+}
+
+func write(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenCommitMerge(t *testing.T) {
+	repo := testRepo(t)
+	path := filepath.Join(repo, ".cursor-iter", "worktrees", "add-api")
+
+	tree, err := Open(repo, path, BranchPrefix+"add-api")
+	if err != nil {
+		t.Fatalf("Open() = %v", err)
+	}
+	write(t, tree.Path, "api.go", "package app\n")
+
+	// Reopening keeps the work of the earlier run
+	again, err := Open(repo, path, BranchPrefix+"add-api")
+	if err != nil || again.Path != tree.Path {
+		t.Fatalf("Open() again = %+v, %v", again, err)
+	}
+	if _, err := os.Stat(filepath.Join(again.Path, "api.go")); err != nil {
+		t.Fatalf("reopened worktree lost its changes: %v", err)
+	}
+
+	if committed, err := Commit(tree, "Add API"); err != nil || !committed {
+		t.Fatalf("Commit() = %v, %v", committed, err)
+	}
+	if committed, err := Commit(tree, "Nothing"); err != nil || committed {
+		t.Fatalf("Commit() of a clean worktree = %v, %v", committed, err)
+	}
+	if n, err := Ahead(repo, tree); err != nil || n != 1 {
+		t.Fatalf("Ahead() = %d, %v", n, err)
+	}
+
+	if err := Merge(repo, tree, "Merge task: Add API"); err != nil {
+		t.Fatalf("Merge() = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "api.go")); err != nil {
+		t.Fatalf("merge didn't bring api.go: %v", err)
+	}
+	if n, _ := Ahead(repo, tree); n != 0 {
+		t.Fatalf("Ahead() after merge = %d", n)
+	}
+
+	if err := Remove(repo, tree, true); err != nil {
+		t.Fatalf("Remove() = %v", err)
+	}
+	if _, err := os.Stat(tree.Path); !os.IsNotExist(err) {
+		t.Fatalf("worktree still exists: %v", err)
+	}
+	if out := mustGit(t, repo, "branch", "--list", tree.Branch); strings.TrimSpace(out) != "" {
+		t.Fatalf("branch still exists: %q", out)
+	}
+	if b, err := CurrentBranch(repo); err != nil || b != "main" {
+		t.Fatalf("CurrentBranch() = %q, %v", b, err)
+	}
+}
+
+func TestMergeConflict(t *testing.T) {
+	repo := testRepo(t)
+	tree, err := Open(repo, filepath.Join(t.TempDir(), "rename"), BranchPrefix+"rename")
+	if err != nil {
+		t.Fatalf("Open() = %v", err)
+	}
+	write(t, tree.Path, "app.go", "package app\n\nconst Name = \"task\"\n")
+	if _, err := Commit(tree, "Rename"); err != nil {
+		t.Fatal(err)
+	}
+	write(t, repo, "app.go", "package app\n\nconst Name = \"main\"\n")
+	mustGit(t, repo, "commit", "-qam", "main change")
+
+	err = Merge(repo, tree, "Merge task: Rename")
+	var conflictErr *ConflictError
+	if !errors.As(err, &conflictErr) || strings.Join(conflictErr.Files, ",") != "app.go" {
+		t.Fatalf("Merge() = %v, want a conflict in app.go", err)
+	}
+	// The merge was aborted
+	if status := mustGit(t, repo, "status", "--porcelain"); strings.TrimSpace(status) != "" {
+		t.Fatalf("repository left dirty after the conflict:\n%s", status)
+	}
+}
+
+func TestCherryPick(t *testing.T) {
+	repo := testRepo(t)
+	base := strings.TrimSpace(mustGit(t, repo, "rev-parse", "HEAD"))
+	write(t, repo, "api.go", "package app\n")
+	mustGit(t, repo, "add", ".")
+	mustGit(t, repo, "commit", "-qm", "Add API")
+	first := strings.TrimSpace(mustGit(t, repo, "rev-parse", "HEAD"))
+
+	if err := CherryPick(repo, "feature/api", base, []string{first}); err != nil {
+		t.Fatalf("CherryPick() = %v", err)
+	}
+	write(t, repo, "api.go", "package app\n\nfunc API() {}\n")
+	mustGit(t, repo, "commit", "-qam", "Fill in API")
+	second := strings.TrimSpace(mustGit(t, repo, "rev-parse", "HEAD"))
+	// A later run adds its commits to the existing branch
+	if err := CherryPick(repo, "feature/api", base, []string{second}); err != nil {
+		t.Fatalf("CherryPick() onto the branch = %v", err)
+	}
+	if log := mustGit(t, repo, "log", "--format=%s", base+"..feature/api"); log != "Fill in API\nAdd API\n" {
+		t.Fatalf("feature/api has:\n%s", log)
+	}
+
+	// A commit that doesn't apply leaves the branch as it was
+	tip := mustGit(t, repo, "rev-parse", "feature/api")
+	if err := CherryPick(repo, "feature/api", base, []string{second}); err == nil {
+		t.Fatal("CherryPick() of a conflicting commit succeeded")
+	}
+	if got := mustGit(t, repo, "rev-parse", "feature/api"); got != tip {
+		t.Errorf("feature/api moved to %s", got)
+	}
+	if b, _ := CurrentBranch(repo); b != "main" {
+		t.Errorf("CurrentBranch() = %q", b)
+	}
+	if out := mustGit(t, repo, "worktree", "list"); strings.Count(out, "\n") != 1 {
+		t.Errorf("temporary worktree left behind:\n%s", out)
+	}
+}
@@ -0,0 +1,11 @@
+// Package prompts holds the prompt templates that iterate-init and
+// add-feature copy to .cursor-iter/prompts, built into cursor-iter for
+// machines that can't reach GitHub
+package prompts
+
+import "embed"
+
+// FS holds the prompt files by name, e.g. "add-feature.md"
+//
+//go:embed *.md
+var FS embed.FS